@@ -0,0 +1,332 @@
+// Package logger : 他のGoのWebアプリからこのロガーへアクセスやログを送るクライアント
+//
+//	http.ListenAndServe(":8080", logger.Middleware(mux))
+//	logger.Send(ctx, logger.Entry{Level: "error", Message: "payment failed", Fields: map[string]any{"order_id": 123}})
+//
+// パッケージの関数は環境変数 GO_LOGGER_URL（例: https://dev.aliceindex.jp/go）と
+// GO_LOGGER_API_KEY から作るクライアントを使う。複数の送信先がある場合は New で作る。
+// Enqueue した分は、溜まっていれば POST /api/logs/batch でまとめて送る（WithBatchSize）。
+//
+// 元のリクエストの送信元IPは X-Forwarded-For で渡すので、サーバーが信用するのは
+// 同じホストやプライベートネットワークから送った場合だけ（それ以外は送信元のサーバーのIPになる）。
+package logger
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// エントリとクライアント
+// ==========================================
+
+// Entry : POST /api/logs で送る1件
+type Entry struct {
+	Level   string         `json:"level,omitempty"` // debug / info / warn / error / fatal（省略時 info）
+	Message string         `json:"message"`
+	Fields  map[string]any `json:"fields,omitempty"`
+	TTL     string         `json:"ttl,omitempty"` // "24h" "7d" など（省略時はサーバーの設定に従う）
+
+	// 記録するリクエストの応答ステータスと処理時間（GET /api/stats の latency で集計される）
+	Status     int     `json:"status,omitempty"`
+	DurationMS float64 `json:"duration_ms,omitempty"`
+
+	// 元のリクエストの情報（ヘッダーとして送り、サーバー側のUA・IP・リファラーになる）
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+	Referrer  string `json:"-"`
+}
+
+// Client : ロガーサーバーへの送信を担当する
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+	batchSize  int
+	onError    func(error)
+
+	mu      sync.Mutex
+	queue   chan Entry
+	started bool // worker を起動したか
+	closed  bool
+	done    chan struct{}
+}
+
+// Option : New の設定
+type Option func(*Client)
+
+// WithAPIKey : プロジェクトキー（X-API-Key）
+func WithAPIKey(key string) Option { return func(c *Client) { c.apiKey = key } }
+
+// WithHTTPClient : 送信に使うHTTPクライアント（既定はタイムアウト5秒）
+func WithHTTPClient(hc *http.Client) Option { return func(c *Client) { c.httpClient = hc } }
+
+// WithRetries : 失敗時の再試行回数と最初の待ち時間（2回目以降は倍にする）
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = n, backoff }
+}
+
+// WithBufferSize : 非同期送信のキューの長さ（溢れた分は捨てる）
+func WithBufferSize(n int) Option { return func(c *Client) { c.queue = make(chan Entry, n) } }
+
+// WithBatchSize : 非同期送信でキューに溜まった分を1回の POST /api/logs/batch にまとめる件数（1なら1件ずつ送る）
+func WithBatchSize(n int) Option { return func(c *Client) { c.batchSize = n } }
+
+// WithErrorHandler : 非同期送信で最終的に失敗した時に呼ばれる
+func WithErrorHandler(fn func(error)) Option { return func(c *Client) { c.onError = fn } }
+
+// New : baseURL はロガーの公開URL（/api/logs の手前まで）
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		retries:    3,
+		backoff:    200 * time.Millisecond,
+		batchSize:  100,
+		queue:      make(chan Entry, 1000),
+		done:       make(chan struct{}),
+		onError:    func(err error) { fmt.Fprintln(os.Stderr, "go-logger client:", err) },
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ==========================================
+// 送信 (同期 / 非同期)
+// ==========================================
+
+var (
+	// errPermanent : 再試行しても成功しない失敗（4xx）
+	errPermanent = errors.New("rejected by server")
+	// errNoBatch : サーバーが POST /api/logs/batch に対応していない（古いサーバー）
+	errNoBatch = errors.New("batch endpoint not available")
+)
+
+// Send : 1件を送信し、結果を待つ（5xx・通信エラーは再試行する）
+func (c *Client) Send(ctx context.Context, e Entry) error {
+	if c.baseURL == "" {
+		return errors.New("go-logger client: no server URL configured")
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return c.postWithRetry(ctx, "/api/logs", body, e)
+}
+
+// SendBatch : 複数件を POST /api/logs/batch で1回に送信し、結果を待つ
+// 元のリクエストの情報 (UserAgent / IP / Referrer) は先頭の1件のものを使う
+// 1件ずつの検証エラーはサーバーの応答の results に入り、ここではエラーにしない
+func (c *Client) SendBatch(ctx context.Context, entries []Entry) error {
+	if c.baseURL == "" {
+		return errors.New("go-logger client: no server URL configured")
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	err = c.postWithRetry(ctx, "/api/logs/batch", body, entries[0])
+	if !errors.Is(err, errNoBatch) {
+		return err
+	}
+	// まとめて送れないサーバーには1件ずつ送る
+	var errs []error
+	for _, e := range entries {
+		errs = append(errs, c.Send(ctx, e))
+	}
+	return errors.Join(errs...)
+}
+
+// postWithRetry : 5xx・通信エラーは待ち時間を倍にしながら再試行する
+// 再試行には同じ Idempotency-Key を付け、届いていた分をサーバーが二重に保存しないようにする
+func (c *Client) postWithRetry(ctx context.Context, path string, body []byte, e Entry) error {
+	key := newIdempotencyKey()
+	wait := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := c.post(ctx, path, key, body, e)
+		if err == nil || errors.Is(err, errPermanent) || attempt >= c.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// newIdempotencyKey : 1回の送信（再試行を含む）ごとのキー
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// post : 1回分のPOST（元のリクエストの情報は e のものをヘッダーにする）
+func (c *Client) post(ctx context.Context, path, idempotencyKey string, body []byte, e Entry) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if e.UserAgent != "" {
+		req.Header.Set("User-Agent", e.UserAgent)
+	}
+	if e.IP != "" {
+		req.Header.Set("X-Forwarded-For", e.IP)
+	}
+	if e.Referrer != "" {
+		req.Header.Set("Referer", e.Referrer)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return fmt.Errorf("%w: %w: %v", errPermanent, errNoBatch, err)
+	}
+	// 409 は同じキーの前の試行がまだ処理中なので、待って送り直す
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusConflict {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	return err
+}
+
+// Enqueue : 待たずに送る（キューが一杯、または Close 後なら false を返して捨てる）
+func (c *Client) Enqueue(e Entry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	if !c.started {
+		c.started = true
+		go c.worker()
+	}
+	select {
+	case c.queue <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+// worker : キューの中身を順番に送る
+// 溜まっている分は batchSize 件まで、元のリクエストの情報が同じものを SendBatch でまとめて送る
+func (c *Client) worker() {
+	defer close(c.done)
+	for e := range c.queue {
+		batch := []Entry{e}
+	drain:
+		for len(batch) < c.batchSize {
+			select {
+			case next, ok := <-c.queue:
+				if !ok {
+					break drain
+				}
+				if !sameOrigin(next, batch[0]) {
+					c.flush(batch)
+					batch = batch[:0]
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+		c.flush(batch)
+	}
+}
+
+// sameOrigin : 同じヘッダーで送れるか（元のリクエストの情報が同じ）
+func sameOrigin(a, b Entry) bool {
+	return a.UserAgent == b.UserAgent && a.IP == b.IP && a.Referrer == b.Referrer
+}
+
+// flush : worker が溜めた分を送る
+func (c *Client) flush(batch []Entry) {
+	var err error
+	if len(batch) == 1 {
+		err = c.Send(context.Background(), batch[0])
+	} else {
+		err = c.SendBatch(context.Background(), batch)
+	}
+	if err != nil {
+		c.onError(err)
+	}
+}
+
+// Close : キューに残っている分を送り終えるまで待つ（ctx で打ち切り）
+func (c *Client) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	started := c.started
+	c.mu.Unlock()
+	if !started {
+		return nil
+	}
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ==========================================
+// 環境変数から作る既定のクライアント
+// ==========================================
+
+var (
+	defaultOnce   sync.Once
+	defaultClient *Client
+)
+
+// Default : GO_LOGGER_URL / GO_LOGGER_API_KEY から作ったクライアント
+func Default() *Client {
+	defaultOnce.Do(func() {
+		defaultClient = New(os.Getenv("GO_LOGGER_URL"), WithAPIKey(os.Getenv("GO_LOGGER_API_KEY")))
+	})
+	return defaultClient
+}
+
+// Send : 既定のクライアントで同期送信する
+func Send(ctx context.Context, e Entry) error { return Default().Send(ctx, e) }
+
+// Enqueue : 既定のクライアントで非同期送信する
+func Enqueue(e Entry) bool { return Default().Enqueue(e) }
+
+// Close : 既定のクライアントのキューを送り切る（終了前に呼ぶ）
+func Close(ctx context.Context) error { return Default().Close(ctx) }
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// アクセス記録用のミドルウェア
+// ==========================================
+
+// statusRecorder : ハンドラーが返したステータスを覚えておく
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap : http.ResponseController が Flush などを辿れるようにする
+func (s *statusRecorder) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+// Middleware : 既定のクライアントでアクセスを記録する
+func Middleware(next http.Handler) http.Handler { return Default().Middleware(next) }
+
+// Middleware : リクエストごとにメソッド・パス・ステータス・処理時間を非同期で送る
+// 5xx は error、4xx は warn、それ以外は info として記録する
+func (c *Client) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		level := "info"
+		switch {
+		case rec.status >= 500:
+			level = "error"
+		case rec.status >= 400:
+			level = "warn"
+		}
+		c.Enqueue(Entry{
+			Level:   level,
+			Message: fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, rec.status),
+			Fields: map[string]any{
+				"kind":   "access",
+				"method": r.Method,
+				"path":   r.URL.Path,
+				"host":   r.Host,
+			},
+			Status:     rec.status,
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			UserAgent:  r.UserAgent(),
+			IP:         remoteIP(r),
+			Referrer:   r.Referer(),
+		})
+	})
+}
+
+// remoteIP : 元のリクエストの送信元（手前にプロキシがあれば X-Forwarded-For の先頭）
+func remoteIP(r *http.Request) string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		first, _, _ := strings.Cut(xff, ",")
+		return strings.TrimSpace(first)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
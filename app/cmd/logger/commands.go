@@ -0,0 +1,543 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"go-logger/internal/archive"
+	"go-logger/internal/clock"
+	"go-logger/internal/config"
+	"go-logger/internal/enrich"
+	"go-logger/internal/idgen"
+	"go-logger/internal/model"
+	"go-logger/internal/redact"
+	"go-logger/internal/server"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 管理用サブコマンド (実行して終了する。SQL を直接書かずに済むように)
+// ==========================================
+
+// cliClock : サブコマンドが使う時計（削除・集計・書き出しの基準の時刻もここから取る）
+var cliClock clock.Clock = clock.System{}
+
+// connectStore : DB_* の設定で接続する
+func connectStore(ctx context.Context) (*store.Postgres, error) {
+	db := store.NewPostgres(store.ConnStrFromEnv(), cliClock)
+	if err := db.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	return db, nil
+}
+
+// newMigrateCommand : `main migrate [status]`
+func newMigrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "未適用のマイグレーションを適用する",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := connectStore(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			if err := db.Migrate(cmd.Context()); err != nil {
+				return fmt.Errorf("migration failed: %w", err)
+			}
+			fmt.Println("Migrations are up to date")
+			return nil
+		},
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "適用済み・未適用のマイグレーションを表示する",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := connectStore(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			return db.MigrationStatus(cmd.Context())
+		},
+	})
+	return cmd
+}
+
+// newPurgeCommand : `main purge [--older-than 30d]`
+// 定期処理を待たずに、期限切れのログと保存期間を過ぎたログを削除する（ARCHIVE_FORMAT があれば書き出してから）
+func newPurgeCommand() *cobra.Command {
+	var olderThan string
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "期限切れ・保存期間切れのログを今すぐ削除する",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			cfg := server.ConfigFromEnv()
+			var cutoff time.Time
+			if olderThan != "" {
+				age, err := parseAge(olderThan)
+				if err != nil {
+					return err
+				}
+				cutoff = cliClock.Now().Add(-age)
+			} else if cfg.RetentionDays > 0 {
+				cutoff = cliClock.Now().AddDate(0, 0, -cfg.RetentionDays)
+			}
+
+			db, err := connectStore(ctx)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			archiver, err := archive.FromEnv()
+			if err != nil {
+				return fmt.Errorf("invalid archive settings: %w", err)
+			}
+
+			srv := server.New(cfg, server.Deps{Store: db, Archiver: archiver})
+			// /api/settings の retention_tiers も定期処理と同じく当てる
+			if err := srv.LoadSettings(ctx); err != nil {
+				return fmt.Errorf("loading settings: %w", err)
+			}
+			n, err := srv.Purge(ctx, cutoff)
+			fmt.Printf("Removed %d events\n", n)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "これより古いログを削除する（例: 90d, 12h。既定は RETENTION_DAYS、0 なら期限付きのログだけ。retention_tiers に当てはまるログはそちらに従う）")
+	return cmd
+}
+
+// parseAge : time.ParseDuration に日数 ("90d") を加えたもの（"0" ならゼロ）
+func parseAge(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	}
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q (use e.g. 90d or 12h)", s)
+	}
+	return d, nil
+}
+
+// logQueryFlags : tail・stats・export で共通の絞り込み
+type logQueryFlags struct {
+	project   int
+	eventType string
+	level     string
+}
+
+func (f *logQueryFlags) register(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&f.project, "project", 0, "プロジェクトID（0 なら全て）")
+	cmd.Flags().StringVar(&f.eventType, "type", "", "イベント種別")
+	cmd.Flags().StringVar(&f.level, "level", "", "このレベル以上だけ（debug / info / warn / error / fatal）")
+}
+
+func (f *logQueryFlags) filter() (store.LogFilter, error) {
+	lf := store.LogFilter{ProjectID: f.project, EventType: f.eventType}
+	if f.level != "" {
+		level, err := model.NormalizeLevel(f.level)
+		if err != nil {
+			return lf, err
+		}
+		lf.MinLevel = level
+	}
+	return lf, nil
+}
+
+// newTailCommand : `main tail [-n 20] [-f] [--type access] [--level warn] [--filter ua~curl] [--server URL]`
+// --server を付けると DB ではなく、動いているインスタンスから新着ログを受け取る（SSH 先から様子を見る用）
+func newTailCommand() *cobra.Command {
+	var (
+		lines    int
+		follow   bool
+		interval time.Duration
+		query    logQueryFlags
+		filter   string
+		noColor  bool
+		remote   tailRemote
+	)
+	cmd := &cobra.Command{
+		Use:   "tail",
+		Short: "新しいログを表示する（-f で届いた順に表示し続ける）",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			f, err := query.filter()
+			if err != nil {
+				return err
+			}
+			printer := entryPrinter{color: useColor(noColor)}
+			if filter != "" {
+				if printer.filter, err = store.ParseQuery(filter); err != nil {
+					return fmt.Errorf("--filter: %w", err)
+				}
+			}
+			// 接続先からは新着だけが届く（-n と --project は使わない。プロジェクトは --key で決まる）
+			if remote.server != "" {
+				remote.query, remote.printLog = query, printer.print
+				return remote.follow(ctx)
+			}
+
+			db, err := connectStore(ctx)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			// DB から読む時は検索式ごと DB に渡す
+			f.Query, printer.filter = printer.filter, nil
+			f.Limit = lines
+			lastID := 0
+			for {
+				entries, err := db.QueryLogs(ctx, f)
+				if err != nil {
+					return err
+				}
+				// 新しい順に返るので、古いものから表示する
+				slices.Reverse(entries)
+				for _, e := range entries {
+					if e.ID > lastID {
+						printer.print(e)
+						lastID = e.ID
+					}
+				}
+				if !follow {
+					return nil
+				}
+				f.Limit = 500
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-cliClock.After(interval):
+				}
+			}
+		},
+	}
+	cmd.Flags().IntVarP(&lines, "lines", "n", 20, "最初に表示する件数")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "新しいログを表示し続ける")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "-f の時に読み直す間隔")
+	cmd.Flags().StringVar(&filter, "filter", "", "検索式で絞り込む（ua~curl path:/api/* level>=warn）")
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "レベルで色分けしない（NO_COLOR でも同じ）")
+	cmd.Flags().StringVar(&remote.server, "server", os.Getenv("LOGGER_URL"), "新着を受け取るインスタンスのURL（例: http://localhost:8081）")
+	cmd.Flags().StringVar(&remote.key, "key", os.Getenv("LOGGER_KEY"), "--server のプロジェクトキー")
+	cmd.Flags().StringVar(&remote.user, "user", os.Getenv("LOGGER_USER"), "--server のダッシュボードのログイン（user:password）")
+	query.register(cmd)
+	return cmd
+}
+
+// newStatsCommand : `main stats [--since 24h] [--json]`
+func newStatsCommand() *cobra.Command {
+	var (
+		since  string
+		asJSON bool
+		query  logQueryFlags
+	)
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "種別・レベルごとの件数を表示する",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			f, err := query.filter()
+			if err != nil {
+				return err
+			}
+			if since != "" {
+				age, err := parseAge(since)
+				if err != nil {
+					return err
+				}
+				f.Since = cliClock.Now().Add(-age)
+			}
+			db, err := connectStore(ctx)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			stats, err := db.Stats(ctx, f, cliClock.Now().Add(-24*time.Hour))
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(stats)
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(tw, "Total\t%d\n", stats.Total)
+			fmt.Fprintf(tw, "Last 24h\t%d\n", stats.Last24h)
+			printCounts(tw, "Type", stats.ByType)
+			printCounts(tw, "Level", stats.ByLevel)
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&since, "since", "", "この期間だけ数える（例: 7d, 12h。既定は全期間）")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "JSON で出力する（GET /api/stats と同じ形）")
+	query.register(cmd)
+	return cmd
+}
+
+// newExportCommand : `main export [--format parquet] [--out FILE] [--since 7d] [--until 1d] [--type access] [--level warn]`
+// DuckDB / Athena などで分析するために、古い順に全件を1つのファイルへ書き出す（ARCHIVE_FORMAT と同じ形式）
+// 項目は REDACT_RULES の REDACT_EXPORT_SCOPE の範囲で隠す（アーカイブ・スナップショットと同じ）
+func newExportCommand() *cobra.Command {
+	var (
+		format, out, since, until string
+		batch                     int
+		query                     logQueryFlags
+	)
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "ログを Parquet / NDJSON のファイルに書き出す",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			f, err := query.filter()
+			if err != nil {
+				return err
+			}
+			now := cliClock.Now()
+			if since != "" {
+				age, err := parseAge(since)
+				if err != nil {
+					return err
+				}
+				f.Since = now.Add(-age)
+			}
+			if until != "" {
+				age, err := parseAge(until)
+				if err != nil {
+					return err
+				}
+				f.Until = now.Add(-age)
+			}
+			if batch <= 0 {
+				return errors.New("--batch must be positive")
+			}
+			policy, err := redact.FromEnv()
+			if err != nil {
+				return fmt.Errorf("invalid redaction settings: %w", err)
+			}
+			scope := redact.Admin
+			if policy != nil {
+				scope = policy.ExportScope
+			}
+
+			// 書き出し先（"-" なら標準出力）
+			if out == "" {
+				out = "go-logger-export-" + now.Format("20060102T150405Z") + ".parquet"
+				if format == archive.FormatNDJSON {
+					out = strings.TrimSuffix(out, ".parquet") + ".ndjson.gz"
+				}
+			}
+			dest := os.Stdout
+			if out != "-" {
+				file, err := os.Create(out)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				dest = file
+			}
+			enc, err := archive.NewEncoder(dest, format)
+			if err != nil {
+				return err
+			}
+
+			db, err := connectStore(ctx)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			// id の古い順に batch 件ずつ読む（書き出している間に届いたログも含める）
+			f.Sort, f.Ascending, f.Limit = store.SortID, true, batch
+			total := 0
+			for {
+				logs, err := db.QueryLogs(ctx, f)
+				if err != nil {
+					enc.Close()
+					return fmt.Errorf("export failed after %d events: %w", total, err)
+				}
+				if len(logs) == 0 {
+					break
+				}
+				if err := enc.Write(policy.Entries(logs, scope)); err != nil {
+					enc.Close()
+					return err
+				}
+				total += len(logs)
+				f.AfterID = logs[len(logs)-1].ID
+				if len(logs) < batch {
+					break
+				}
+			}
+			if err := enc.Close(); err != nil {
+				return err
+			}
+			if out != "-" {
+				fmt.Printf("Exported %d events to %s\n", total, out)
+			}
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&format, "format", archive.FormatParquet, "形式（parquet: 列ごとに型の付いた Parquet / ndjson: gzip した NDJSON）")
+	flags.StringVar(&out, "out", "", "書き出し先のファイル（- なら標準出力。既定は go-logger-export-<時刻>.parquet）")
+	flags.StringVar(&since, "since", "", "この期間より新しいログだけ（例: 30d, 12h。既定は全期間）")
+	flags.StringVar(&until, "until", "", "この期間より古いログだけ（例: 1d）")
+	flags.IntVar(&batch, "batch", 5000, "1回に読み込む件数")
+	query.register(cmd)
+	return cmd
+}
+
+// printCounts : 件数の多い順に表示する
+func printCounts(tw *tabwriter.Writer, title string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(a, b)
+	})
+	fmt.Fprintf(tw, "\n%s\tEvents\n", title)
+	for _, k := range keys {
+		label := k
+		if label == "" {
+			label = "(none)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\n", label, counts[k])
+	}
+}
+
+// newReindexCommand : `main reindex [--target DSN] [--batch 500]`
+// エンリッチャーを追加した後、過去の行を埋め直すために使う
+// --target を省略すると同じDBの行をその場で更新する
+func newReindexCommand() *cobra.Command {
+	var (
+		target string
+		batch  int
+	)
+	cmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "既存のイベントをエンリッチし直す",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// 1. 読み込み元
+			clk := cliClock
+			source, err := connectStore(ctx)
+			if err != nil {
+				return fmt.Errorf("source: %w", err)
+			}
+			defer source.Close()
+
+			// 2. 書き込み先
+			dest := source
+			if target != "" {
+				dest = store.NewPostgres(target, clk)
+				if err := dest.Open(ctx); err != nil {
+					return fmt.Errorf("connect target: %w", err)
+				}
+				defer dest.Close()
+			}
+
+			// 3. エンリッチし直し、ID_STRATEGY を切り替えた後は uid のない過去の行にも作成時刻から付ける
+			enricher, ids := enrich.FromEnv(), idgen.FromEnv()
+			if err := store.Reindex(ctx, source, dest, batch, func(w *model.Write) {
+				enricher.Enrich(w)
+				if w.UID == "" {
+					w.UID = ids.NewID(w.CreatedAt)
+				}
+			}); err != nil {
+				return fmt.Errorf("reindex failed: %w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&target, "target", os.Getenv("REINDEX_TARGET_DSN"), "書き込み先DBの接続文字列（省略時は同じDB）")
+	cmd.Flags().IntVar(&batch, "batch", 500, "1回に読み込む件数")
+	return cmd
+}
+
+// newBootstrapCommand : `main bootstrap --admin-dsn DSN [--db logger_db] [--user logger] [--dml-only] [--out .env.db]`
+// 管理者の接続文字列は一度だけ使い、アプリが使う DB_* の設定を --out（省略時は標準出力）に書き出す
+// --password を省略するとランダムなパスワードを作る
+func newBootstrapCommand() *cobra.Command {
+	var (
+		adminDSN, database, user, password, host, out string
+		dmlOnly                                       bool
+	)
+	cmd := &cobra.Command{
+		Use:   "bootstrap",
+		Short: "初回だけ DB・アプリ用ロール・権限を作る",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if adminDSN == "" {
+				return errors.New("--admin-dsn (or BOOTSTRAP_ADMIN_DSN) is required")
+			}
+			if password == "" {
+				b := make([]byte, 18)
+				if _, err := rand.Read(b); err != nil {
+					return err
+				}
+				password = hex.EncodeToString(b)
+			}
+
+			if err := store.Bootstrap(cmd.Context(), adminDSN, store.BootstrapOptions{
+				Database: database, Role: user, Password: password, DMLOnly: dmlOnly,
+			}); err != nil {
+				return fmt.Errorf("bootstrap failed: %w", err)
+			}
+
+			env := fmt.Sprintf("DB_HOST=%s\nDB_USER=%s\nDB_PASSWORD=%s\nDB_NAME=%s\n", host, user, password, database)
+			if dmlOnly {
+				// アプリ用ロールはテーブルを作れないので、以後のマイグレーションは管理者が適用する
+				env += "MIGRATE_ON_START=false\n"
+			}
+			if out == "" {
+				fmt.Print(env)
+				return nil
+			}
+			if err := os.WriteFile(out, []byte(env), 0o600); err != nil {
+				return err
+			}
+			fmt.Println("Wrote database settings to", out)
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&adminDSN, "admin-dsn", os.Getenv("BOOTSTRAP_ADMIN_DSN"), "管理者の接続文字列（例: postgres://postgres:secret@db:5432/postgres?sslmode=disable）")
+	flags.StringVar(&database, "db", config.String("DB_NAME", "logger_db"), "作るDBの名前")
+	flags.StringVar(&user, "user", config.String("DB_USER", "logger"), "作るアプリ用ロールの名前")
+	flags.StringVar(&password, "password", config.String("DB_PASSWORD", ""), "アプリ用ロールのパスワード（省略時はランダム）")
+	flags.StringVar(&host, "host", config.String("DB_HOST", "localhost"), "アプリから見たDBのホスト名（書き出す設定用）")
+	flags.BoolVar(&dmlOnly, "dml-only", false, "アプリ用ロールには読み書きだけを許し、マイグレーションはここで適用する")
+	flags.StringVar(&out, "out", "", "設定の書き出し先（省略時は標準出力）")
+	return cmd
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"go-logger/internal/archive"
+	"go-logger/internal/auth"
+	"go-logger/internal/clock"
+	"go-logger/internal/config"
+	"go-logger/internal/enrich"
+	"go-logger/internal/notify"
+	"go-logger/internal/queue"
+	"go-logger/internal/redact"
+	"go-logger/internal/store"
+	"go-logger/internal/stream"
+)
+
+// ==========================================
+// 自己診断 (`main doctor`。問い合わせの前にまずこれを実行してもらう)
+// ==========================================
+// 同じ確認を `main --check`（結果を JSON で出して終了）と STARTUP_CHECKS=true（起動前に確かめ、FAIL なら起動しない）でも行う
+
+// checkStatus : 診断1項目の結果
+type checkStatus string
+
+const (
+	checkPass checkStatus = "PASS"
+	checkWarn checkStatus = "WARN" // 動くが、意図した設定か確認したほうがよいもの
+	checkFail checkStatus = "FAIL" // このままでは起動しない・一部の機能が動かない
+	checkSkip checkStatus = "SKIP" // 設定していない、または前の項目が失敗して確かめられない
+)
+
+// checkResult : 診断1項目分
+type checkResult struct {
+	Name   string      `json:"name"`
+	Status checkStatus `json:"status"`
+	Detail string      `json:"detail,omitempty"`
+}
+
+// doctorReport : 診断の結果を順に貯める
+type doctorReport struct {
+	results []checkResult
+}
+
+func (d *doctorReport) add(name string, status checkStatus, format string, args ...any) {
+	d.results = append(d.results, checkResult{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// check : err があれば FAIL、なければ PASS
+func (d *doctorReport) check(name string, err error, ok string) {
+	if err != nil {
+		d.add(name, checkFail, "%v", err)
+		return
+	}
+	d.add(name, checkPass, "%s", ok)
+}
+
+// counts : 結果ごとの項目数
+func (d *doctorReport) counts() map[checkStatus]int {
+	counts := map[checkStatus]int{}
+	for _, r := range d.results {
+		counts[r.Status]++
+	}
+	return counts
+}
+
+// err : FAIL があればエラー
+func (d *doctorReport) err(name string) error {
+	if n := d.counts()[checkFail]; n > 0 {
+		return fmt.Errorf("%s: %d checks failed", name, n)
+	}
+	return nil
+}
+
+// print : 表にして出し、FAIL があればエラーを返す
+func (d *doctorReport) print() error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range d.results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Status, r.Name, r.Detail)
+	}
+	tw.Flush()
+	counts := d.counts()
+	fmt.Printf("\n%d passed, %d warnings, %d failed, %d skipped\n",
+		counts[checkPass], counts[checkWarn], counts[checkFail], counts[checkSkip])
+	return d.err("doctor")
+}
+
+// doctorJSON : JSON で出す時の形（デプロイの仕組みが読み取る）
+type doctorJSON struct {
+	OK       bool          `json:"ok"`
+	Passed   int           `json:"passed"`
+	Warnings int           `json:"warnings"`
+	Failed   int           `json:"failed"`
+	Skipped  int           `json:"skipped"`
+	Checks   []checkResult `json:"checks"`
+}
+
+// writeJSON : 結果を JSON で書き出す
+func (d *doctorReport) writeJSON(w io.Writer) error {
+	counts := d.counts()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doctorJSON{
+		OK: counts[checkFail] == 0, Passed: counts[checkPass], Warnings: counts[checkWarn],
+		Failed: counts[checkFail], Skipped: counts[checkSkip], Checks: d.results,
+	})
+}
+
+// runChecks : 全ての項目を順に確かめる（1項目あたり timeout まで待つ）
+func runChecks(ctx context.Context, timeout time.Duration) *doctorReport {
+	report := &doctorReport{}
+	checkConfig(report)
+	if db := checkDatabase(ctx, report, timeout); db != nil {
+		checkChannels(ctx, report, db, timeout)
+		db.Close()
+	}
+	checkNotifiers(ctx, report, timeout)
+	checkGeoIP(report)
+	checkQueueAndStream(report)
+	return report
+}
+
+// runCheck : `main --check`。ENV_FILE も読んだ上で確かめ、結果を JSON で標準出力に出す
+func runCheck(ctx context.Context, timeout time.Duration) error {
+	if path := os.Getenv("ENV_FILE"); path != "" {
+		if err := newEnvFile(path).load(); err != nil {
+			return fmt.Errorf("failed to load ENV_FILE: %w", err)
+		}
+	}
+	report := runChecks(ctx, timeout)
+	if err := report.writeJSON(os.Stdout); err != nil {
+		return err
+	}
+	return report.err("check")
+}
+
+// newDoctorCommand : `main doctor [--timeout 5s] [--json]`
+func newDoctorCommand() *cobra.Command {
+	var timeout time.Duration
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "DB・マイグレーション・通知先・GeoIP・設定を確認して結果を表示する",
+		Long: "サーバーを起動せずに、DBへの接続と権限、未適用のマイグレーション、通知先への疎通、\n" +
+			"GeoIP のDB、設定の誤りを順に確認する。通知は送らず、DBにも書き込まない。FAIL があれば終了コード 1 になる",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report := runChecks(cmd.Context(), timeout)
+			if asJSON {
+				if err := report.writeJSON(os.Stdout); err != nil {
+					return err
+				}
+				return report.err("doctor")
+			}
+			return report.print()
+		},
+	}
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Second, "1項目あたりの待ち時間の上限")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "結果を JSON で出す")
+	return cmd
+}
+
+// checkConfig : 起動時に log.Fatal になる設定と、よくある設定漏れ
+func checkConfig(report *doctorReport) {
+	_, err := auth.FromEnv()
+	report.check("config: DASHBOARD_USERS", err, "ok")
+	_, err = redact.FromEnv()
+	report.check("config: REDACT_RULES", err, "ok")
+	_, err = archive.FromEnv()
+	report.check("config: ARCHIVE_*", err, "ok")
+	_, err = tlsFromEnv()
+	report.check("config: TLS_*", err, "ok")
+
+	if config.String("ADMIN_TOKEN", "") == "" {
+		report.add("config: ADMIN_TOKEN", checkWarn, "not set: admin API and /jobs.html are disabled")
+	}
+	if config.String("DASHBOARD_USERS", "") == "" {
+		report.add("config: DASHBOARD_USERS", checkWarn, "not set: access data is publicly readable")
+	}
+	if config.String("PUBLIC_BASE_URL", "") == "" {
+		report.add("config: PUBLIC_BASE_URL", checkWarn, "not set: notifications have no links to the dashboard")
+	}
+	if config.Bool("DRY_RUN", false) {
+		report.add("config: DRY_RUN", checkWarn, "enabled: events and notifications are printed instead of being stored or sent")
+	}
+}
+
+// checkDatabase : 接続・権限・マイグレーション・レプリカ（繋がれば開いたままの接続を返す。閉じるのは呼び出し側）
+func checkDatabase(ctx context.Context, report *doctorReport, timeout time.Duration) *store.Postgres {
+	if config.String("STORE_BACKEND", "postgres") == "memory" {
+		report.add("database", checkSkip, "STORE_BACKEND=memory: data is kept in memory only")
+		return nil
+	}
+	target := fmt.Sprintf("host=%s dbname=%s user=%s",
+		config.String("DB_HOST", ""), config.String("DB_NAME", ""), config.String("DB_USER", ""))
+	db := store.NewPostgres(store.ConnStrFromEnv(), clock.System{})
+	db.UseReplica(store.ReplicaConnStrFromEnv())
+
+	connectCtx, cancel := context.WithTimeout(ctx, timeout)
+	err := db.ConnectOnce(connectCtx)
+	cancel()
+	if err != nil {
+		report.add("database", checkFail, "%s: %v", target, err)
+		report.add("database: privileges", checkSkip, "not connected")
+		report.add("database: migrations", checkSkip, "not connected")
+		report.add("database: indexes", checkSkip, "not connected")
+		return nil
+	}
+	report.add("database", checkPass, "connected to %s", target)
+
+	queryCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if missing, err := db.MissingPrivileges(queryCtx); err != nil {
+		report.add("database: privileges", checkFail, "%v", err)
+	} else if len(missing) > 0 {
+		report.add("database: privileges", checkFail, "missing %s", strings.Join(missing, ", "))
+	} else {
+		report.add("database: privileges", checkPass, "ok")
+	}
+
+	switch pending, err := db.PendingMigrations(queryCtx); {
+	case err != nil:
+		report.add("database: migrations", checkFail, "%v", err)
+	case len(pending) > 0 && config.Bool("MIGRATE_ON_START", true):
+		report.add("database: migrations", checkWarn, "%d pending (applied on start): %s", len(pending), strings.Join(pending, ", "))
+	case len(pending) > 0:
+		report.add("database: migrations", checkFail, "%d pending and MIGRATE_ON_START=false (run `main migrate`): %s", len(pending), strings.Join(pending, ", "))
+	default:
+		report.add("database: migrations", checkPass, "up to date")
+	}
+
+	if missing, err := db.MissingIndexes(queryCtx); err != nil {
+		report.add("database: indexes", checkFail, "%v", err)
+	} else if len(missing) > 0 {
+		names := make([]string, len(missing))
+		for i, idx := range missing {
+			names[i] = idx.Name
+		}
+		report.add("database: indexes", checkWarn, "missing %s (filtered queries will scan the whole table)", strings.Join(names, ", "))
+	} else {
+		report.add("database: indexes", checkPass, "ok")
+	}
+
+	if db.HasReplica() {
+		report.check("database: replica", db.PingReplica(queryCtx), "connected")
+	}
+	return db
+}
+
+// checkChannels : DBで管理する通知先 (/api/channels) のうち有効なものに届くか（メッセージは送らない）
+func checkChannels(ctx context.Context, report *doctorReport, db *store.Postgres, timeout time.Duration) {
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	channels, err := db.ListChannels(checkCtx)
+	if err != nil {
+		report.add("channels", checkFail, "%v", err)
+		return
+	}
+	for _, c := range channels {
+		if !c.Enabled {
+			continue
+		}
+		name := fmt.Sprintf("channel: %s (%s)", c.Name, c.Type)
+		n, err := notify.FromChannel(c, clock.System{})
+		if err != nil {
+			report.add(name, checkFail, "%v", err)
+			continue
+		}
+		for _, r := range notify.NewMulti(n).Check(checkCtx) {
+			report.check(name, r.Err, "reachable")
+		}
+	}
+}
+
+// checkNotifiers : 環境変数で設定した通知先に届くか（メッセージは送らない）
+// DBで管理する通知先 (/api/channels) は対象外
+func checkNotifiers(ctx context.Context, report *doctorReport, timeout time.Duration) {
+	notifiers := notify.FromEnv(clock.System{})
+	// インシデントの通知先 (PAGERDUTY_ROUTING_KEY / OPSGENIE_API_KEY)
+	incidents := notify.IncidentsFromEnv(clock.System{})
+	if notifiers.Len()+incidents.Len() == 0 {
+		report.add("notifiers", checkWarn, "none configured: alerts are only recorded")
+		return
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for _, r := range notifiers.Check(checkCtx) {
+		report.check("notifier: "+r.Name, r.Err, "reachable")
+	}
+	for _, r := range incidents.Check(checkCtx) {
+		report.check("incidents: "+r.Name, r.Err, "reachable")
+	}
+}
+
+// checkGeoIP : GEOIP_DB_PATH のファイルを開けるか
+func checkGeoIP(report *doctorReport) {
+	path := config.String("GEOIP_DB_PATH", "")
+	if path == "" {
+		report.add("geoip", checkSkip, "GEOIP_DB_PATH not set: country is not recorded")
+		return
+	}
+	geo, err := enrich.NewGeoIP(path)
+	if err != nil {
+		report.add("geoip", checkFail, "%v", err)
+		return
+	}
+	defer geo.Close()
+	report.add("geoip", checkPass, "%s (%s)", path, geo.Describe())
+}
+
+// checkQueueAndStream : 送信待ちのキュー (QUEUE_BACKEND) と Kafka / NATS の設定・接続
+func checkQueueAndStream(report *doctorReport) {
+	_, err := queue.FromEnv()
+	report.check("queue: "+config.String("QUEUE_BACKEND", "memory"), err, "ok")
+
+	if len(config.List("KAFKA_BROKERS")) == 0 && config.String("NATS_URL", "") == "" {
+		return
+	}
+	publisher, err := stream.FromEnv()
+	if err == nil {
+		publisher.Close()
+	}
+	report.check("stream", err, "ok")
+}
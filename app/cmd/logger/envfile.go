@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// ==========================================
+// 設定ファイル (ENV_FILE)
+// ==========================================
+// .env と同じ KEY=VALUE の形のファイルを環境変数として読み込む
+// 起動した時に既に値のある環境変数は上書きしない（docker の environment などが優先。空の値は未設定とみなす）
+// SIGHUP で読み直すと、ファイルで変えた・消した値を反映する
+
+// envFile : 読み込んだ設定ファイル
+type envFile struct {
+	path   string
+	loaded map[string]bool // このファイルから設定した変数
+}
+
+// newEnvFile : path のファイル（まだ読まない）
+func newEnvFile(path string) *envFile {
+	return &envFile{path: path, loaded: map[string]bool{}}
+}
+
+// load : ファイルを読み、環境変数にする（最初の読み込みの前から値のあった変数はそのまま）
+// ファイルから消えた変数は、このファイルで設定したものだけ消す
+func (f *envFile) load() error {
+	values, err := readEnvFile(f.path)
+	if err != nil {
+		return err
+	}
+	for key := range f.loaded {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+			delete(f.loaded, key)
+		}
+	}
+	for key, value := range values {
+		if os.Getenv(key) != "" && !f.loaded[key] {
+			continue
+		}
+		os.Setenv(key, value)
+		f.loaded[key] = true
+	}
+	return nil
+}
+
+// readEnvFile : KEY=VALUE の行を読む
+// 空行と # で始まる行は飛ばし、値を囲む引用符は外す
+func readEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/synth"
+)
+
+// ==========================================
+// generate : 合成トラフィックを送る
+// ==========================================
+// `main generate --server http://localhost:8081 --rate 50/s --duration 5m`
+// それらしいアクセス (GET /api/...) と構造化ログ (POST /api/logs/batch) を決まった速さで送り、
+// まとめて保存する経路の負荷試験や、データのないダッシュボードの確認に使う
+// 送り先の通知のルール (NOTIFY_LEVEL_RULES) に合うものは通知も送られるので、本番のインスタンスには向けない
+
+// generateProgressInterval : 途中の結果を表示する間隔
+const generateProgressInterval = 5 * time.Second
+
+// newGenerateCommand : `main generate [--rate 50/s] [--duration 5m] [--logs 0.2] [--server URL] [--key KEY]`
+func newGenerateCommand() *cobra.Command {
+	var (
+		rate, duration string
+		opts           synth.Options
+		sender         generateSender
+	)
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "合成したアクセスとログを動いているインスタンスへ送る（負荷試験・画面の確認用）",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			perSecond, err := synth.ParseRate(rate)
+			if err != nil {
+				return err
+			}
+			var d time.Duration
+			if duration != "" && duration != "0" {
+				if d, err = parseAge(duration); err != nil {
+					return fmt.Errorf("--duration: %w", err)
+				}
+			}
+			if sender.server == "" {
+				return fmt.Errorf("--server (or LOGGER_URL) is required")
+			}
+			sender.client = &http.Client{Timeout: 10 * time.Second}
+			sender.sem = make(chan struct{}, max(sender.concurrency, 1))
+
+			until := "until interrupted"
+			if d > 0 {
+				until = "for " + d.String()
+			}
+			fmt.Fprintf(os.Stderr, "Sending %.1f events/s to %s %s\n", perSecond, sender.server, until)
+			last := time.Now()
+			res := synth.Run(cmd.Context(), clock.System{}, synth.New(opts), perSecond, d, 0, sender.send, func(r synth.Result) {
+				if time.Since(last) >= generateProgressInterval {
+					last = time.Now()
+					fmt.Fprintf(os.Stderr, "Sent %d (%.1f/s), failed %d\n", r.Sent, r.Rate, r.Failed)
+				}
+			})
+			fmt.Printf("Sent %d events in %s (%.1f/s), failed %d\n", res.Sent, res.Elapsed.Round(time.Millisecond), res.Rate, res.Failed)
+			if sender.lastError() != "" {
+				fmt.Println("Last error:", sender.lastError())
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&rate, "rate", "50/s", "送る速さ（例: 50/s, 300/m）")
+	cmd.Flags().StringVar(&duration, "duration", "1m", "送り続ける時間（例: 5m, 1h。0 なら止めるまで）")
+	cmd.Flags().Float64Var(&opts.LogShare, "logs", 0.2, "構造化ログの割合（0〜1。残りはアクセス）")
+	cmd.Flags().IntVar(&opts.Visitors, "visitors", 500, "訪問者の数（X-Forwarded-For のアドレスの種類）")
+	cmd.Flags().Uint64Var(&opts.Seed, "seed", 0, "同じ並びを作り直す時の種（0 なら毎回違う）")
+	cmd.Flags().StringVar(&sender.server, "server", os.Getenv("LOGGER_URL"), "送り先のインスタンスのURL（例: http://localhost:8081）")
+	cmd.Flags().StringVar(&sender.key, "key", os.Getenv("LOGGER_KEY"), "送り先のプロジェクトキー（省略すると既定のプロジェクト）")
+	cmd.Flags().IntVar(&sender.concurrency, "concurrency", 16, "アクセスを同時に送る数")
+	cmd.Flags().IntVar(&sender.batch, "batch", 100, "POST /api/logs/batch 1回あたりの件数（LOG_BATCH_MAX 以下）")
+	return cmd
+}
+
+// generateSender : 合成したイベントを HTTP で送る
+type generateSender struct {
+	server      string
+	key         string
+	concurrency int
+	batch       int
+	client      *http.Client
+	sem         chan struct{}
+
+	mu      sync.Mutex
+	lastErr string
+}
+
+// send : 1回分を送り、送れなかった件数を返す（アクセスは並行に、ログはまとめて送る）
+func (g *generateSender) send(ctx context.Context, events []synth.Event) int {
+	var logs []synth.Event
+	var failed int
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, e := range events {
+		if e.Kind == synth.KindLog {
+			logs = append(logs, e)
+			continue
+		}
+		g.sem <- struct{}{}
+		wg.Add(1)
+		go func(e synth.Event) {
+			defer func() { <-g.sem; wg.Done() }()
+			if err := g.access(ctx, e); err != nil {
+				g.fail(err)
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}(e)
+	}
+	for start := 0; start < len(logs); start += max(g.batch, 1) {
+		chunk := logs[start:min(start+max(g.batch, 1), len(logs))]
+		rejected, err := g.logs(ctx, chunk)
+		if err != nil {
+			g.fail(err)
+		}
+		mu.Lock()
+		failed += rejected
+		mu.Unlock()
+	}
+	wg.Wait()
+	return failed
+}
+
+// access : アクセス1件 (GET /api/<path>?status=&duration_ms=)
+func (g *generateSender) access(ctx context.Context, e synth.Event) error {
+	q := url.Values{}
+	q.Set("status", strconv.Itoa(e.Status))
+	q.Set("duration_ms", strconv.FormatFloat(e.DurationMS, 'f', -1, 64))
+	u := strings.TrimRight(g.server, "/") + "/api" + e.Path + "?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", e.UserAgent)
+	if e.Referrer != "" {
+		req.Header.Set("Referer", e.Referrer)
+	}
+	// 送り先が手前のプロキシとして信用する接続元（ループバック・プライベート）からなら、訪問者ごとのアドレスになる
+	req.Header.Set("X-Forwarded-For", e.IP)
+	return g.do(req, nil)
+}
+
+// logs : 構造化ログをまとめて送り (POST /api/logs/batch)、受け付けられなかった件数を返す
+func (g *generateSender) logs(ctx context.Context, events []synth.Event) (int, error) {
+	items := make([]map[string]any, len(events))
+	for i, e := range events {
+		items[i] = map[string]any{"level": e.Level, "message": e.Message, "fields": e.Fields, "status": e.Status, "duration_ms": e.DurationMS}
+	}
+	body, _ := json.Marshal(items)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(g.server, "/")+"/api/logs/batch", bytes.NewReader(body))
+	if err != nil {
+		return len(events), err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", events[0].IP)
+	var resp struct {
+		Rejected int `json:"rejected"`
+	}
+	if err := g.do(req, &resp); err != nil {
+		return len(events), err
+	}
+	return resp.Rejected, nil
+}
+
+// do : キーを付けて送り、2xx 以外ならエラーにする（out があれば応答の JSON を読む）
+func (g *generateSender) do(req *http.Request, out any) error {
+	if g.key != "" {
+		req.Header.Set("X-API-Key", g.key)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("%s %s: %d %s", req.Method, req.URL.Path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// fail : 最後のエラーを覚えておく（終わった時に表示する）
+func (g *generateSender) fail(err error) {
+	g.mu.Lock()
+	g.lastErr = err.Error()
+	g.mu.Unlock()
+}
+
+// lastError : 最後のエラー（なければ空）
+func (g *generateSender) lastError() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lastErr
+}
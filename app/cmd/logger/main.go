@@ -0,0 +1,431 @@
+// Command logger : go-logger のサーバーと管理用サブコマンド
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"go-logger/internal/archive"
+	"go-logger/internal/auth"
+	"go-logger/internal/buildinfo"
+	"go-logger/internal/clock"
+	"go-logger/internal/config"
+	"go-logger/internal/coord"
+	"go-logger/internal/enrich"
+	"go-logger/internal/faults"
+	"go-logger/internal/idgen"
+	"go-logger/internal/notify"
+	"go-logger/internal/queue"
+	"go-logger/internal/redact"
+	"go-logger/internal/server"
+	"go-logger/internal/store"
+	"go-logger/internal/stream"
+	"go-logger/internal/tracing"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newRootCommand : サブコマンドの一覧（サブコマンドなしで起動した場合は serve と同じ）
+//
+//	main serve                        サーバーを起動する
+//	main migrate [status]             マイグレーションだけ適用
+//	main purge [--older-than 30d]     保存期間を過ぎたログを今すぐ削除する
+//	main tail [-f] [--server URL]     新しいログを表示する（--server なら動いているインスタンスから受け取る）
+//	main stats [--since 24h]          種別・レベルごとの件数を表示する
+//	main reindex [--target DSN]       既存イベントをエンリッチし直す
+//	main bootstrap --admin-dsn DSN    初回だけ DB・アプリ用ロール・権限を作る
+//	main doctor                       DB・通知先・GeoIP・設定を確認する
+//	main service install|uninstall    Windows サービス / macOS の launchd に登録する
+func newRootCommand() *cobra.Command {
+	var check bool
+	var checkTimeout time.Duration
+	serve := func(cmd *cobra.Command, args []string) error {
+		if check {
+			return runCheck(cmd.Context(), checkTimeout)
+		}
+		runServer(cmd.Context())
+		return nil
+	}
+	root := &cobra.Command{
+		Use:           "main",
+		Short:         "go-logger のサーバーと管理用サブコマンド",
+		Args:          cobra.NoArgs,
+		RunE:          serve,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	// --check : 起動せずに設定と依存先を確かめ、結果を JSON で出して終了する（FAIL があれば終了コード 1）
+	root.PersistentFlags().BoolVar(&check, "check", false, "起動せずに設定と依存先を確かめて終了する")
+	root.PersistentFlags().DurationVar(&checkTimeout, "check-timeout", 5*time.Second, "--check の1項目あたりの待ち時間の上限")
+	root.AddCommand(
+		&cobra.Command{Use: "serve", Short: "サーバーを起動する", Args: cobra.NoArgs, RunE: serve},
+		newMigrateCommand(),
+		newPurgeCommand(),
+		newTailCommand(),
+		newStatsCommand(),
+		newExportCommand(),
+		newGenerateCommand(),
+		newReindexCommand(),
+		newBootstrapCommand(),
+		newDoctorCommand(),
+		newServiceCommand(),
+	)
+	return root
+}
+
+// runServer : サーバーを起動し、ctx が終わるまで動かす（サービスとして動かす場合も同じ）
+func runServer(ctx context.Context) {
+	// ENV_FILE を設定すると、そのファイルの KEY=VALUE も環境変数として読み込む（SIGHUP で読み直す）
+	var settingsFile *envFile
+	if path := os.Getenv("ENV_FILE"); path != "" {
+		settingsFile = newEnvFile(path)
+		if err := settingsFile.load(); err != nil {
+			log.Fatal("Failed to load ENV_FILE:", err)
+		}
+	}
+
+	// STARTUP_CHECKS=true なら、待ち受ける前に `--check` と同じ確認をし、FAIL があれば起動しない
+	// （Blue/Green で新しい側が壊れた設定のまま切り替わらないように）
+	if config.Bool("STARTUP_CHECKS", false) {
+		report := runChecks(ctx, config.Duration("STARTUP_CHECK_TIMEOUT", 5*time.Second))
+		if err := report.err("startup checks"); err != nil {
+			report.writeJSON(os.Stderr)
+			log.Fatal(err)
+		}
+		counts := report.counts()
+		fmt.Printf("Startup checks passed (%d passed, %d warnings, %d skipped)\n", counts[checkPass], counts[checkWarn], counts[checkSkip])
+	}
+
+	// ==========================================
+	// 0. トレース設定 (OTLPエンドポイントがあれば有効化)
+	// ==========================================
+	shutdownTracer, err := tracing.Init(ctx)
+	if err != nil {
+		log.Fatal("Failed to initialize tracing:", err)
+	}
+
+	// ==========================================
+	// 1. 保存先 (STORE_BACKEND=postgres|memory)
+	// ==========================================
+	// memory なら Postgres に繋がず、全てメモリに持つ（再起動で消える。デモ・CI・フロントエンドの開発用）
+	clk := clock.System{}
+	var logStore store.Store
+	var db *store.Postgres
+	var mem *store.Memory
+	switch backend := config.String("STORE_BACKEND", "postgres"); backend {
+	case "memory":
+		mem = store.NewMemory(clk, config.Int("MEMORY_MAX_LOGS", store.DefaultMemoryMaxLogs))
+		fmt.Println("Storing everything in memory: data is lost on restart (STORE_BACKEND=memory)")
+		logStore = mem
+	case "postgres":
+		db = openPostgres(ctx, clk)
+		logStore = db
+		// CLICKHOUSE_URL を設定すると、ログは ClickHouse に保存・集計する（プロジェクトや設定は Postgres のまま）
+		if url := config.String("CLICKHOUSE_URL", ""); url != "" {
+			ch, err := store.NewClickHouse(db, url)
+			if err != nil {
+				log.Fatal("Invalid ClickHouse settings:", err)
+			}
+			if err := ch.Init(ctx); err != nil {
+				log.Fatal("Failed to set up ClickHouse:", err)
+			}
+			fmt.Println("Storing logs in ClickHouse")
+			logStore = ch
+		}
+	default:
+		log.Fatal("Unknown STORE_BACKEND (use postgres or memory): ", backend)
+	}
+
+	// ==========================================
+	// 2. サーバーの組み立て
+	// ==========================================
+	// DASHBOARD_USERS を設定するとダッシュボードと読み出しAPIにログインが必要になる
+	dashboardAuth, err := auth.FromEnv()
+	if err != nil {
+		log.Fatal("Invalid dashboard auth settings:", err)
+	}
+	if dashboardAuth == nil {
+		fmt.Println("Dashboard auth is disabled: access data is publicly readable (set DASHBOARD_USERS)")
+	}
+
+	// 通知の送信待ちと再接続中の書き込みを置く場所 (QUEUE_BACKEND=memory|disk|redis)
+	queues, err := queue.FromEnv()
+	if err != nil {
+		log.Fatal("Invalid queue settings:", err)
+	}
+	if db != nil {
+		// WAL_DIR を設定すると、再接続中の書き込みは1件ごとに fsync するファイルに退避し、復旧後に順に書き戻す
+		buffer := queues
+		if dir := config.String("WAL_DIR", ""); dir != "" {
+			buffer = queue.WALBackend{Dir: dir}
+			fmt.Println("Spooling writes to the WAL while the database is down:", dir)
+		}
+		if err := db.UseBuffer(buffer); err != nil {
+			log.Fatal("Failed to open write buffer:", err)
+		}
+	}
+	cfg := server.ConfigFromEnv()
+	notifyQueue, err := queues.Open("notifications", cfg.NotifyQueueSize)
+	if err != nil {
+		log.Fatal("Failed to open notification queue:", err)
+	}
+
+	// KAFKA_BROKERS / NATS_URL を設定すると、保存したログを流す
+	publisher, err := stream.FromEnv()
+	if err != nil {
+		log.Fatal("Failed to set up event stream:", err)
+	}
+	defer publisher.Close()
+
+	// INGEST_SOURCE=redis|nats を設定すると、Redis Stream / NATS JetStream に積まれたログも取り込む
+	consumer, err := stream.ConsumerFromEnv()
+	if err != nil {
+		log.Fatal("Failed to set up ingest consumer:", err)
+	}
+
+	// ARCHIVE_FORMAT と ARCHIVE_S3_BUCKET を設定すると、保存期間を過ぎたログを削除する前にバケットへ書き出す
+	archiver, err := archive.FromEnv()
+	if err != nil {
+		log.Fatal("Invalid archive settings:", err)
+	}
+
+	// EXPORT_S3_BUCKET を設定すると、POST /api/exports のファイルをバケットへ上げる（どのインスタンスからでも取り出せる）
+	exportBucket, err := archive.BucketFromEnv("EXPORT_S3")
+	if err != nil {
+		log.Fatal("Invalid export bucket settings:", err)
+	}
+
+	// REDACT_RULES を設定すると、閲覧範囲に応じて項目を隠してから返す・送る
+	redaction, err := redact.FromEnv()
+	if err != nil {
+		log.Fatal("Invalid redaction rules:", err)
+	}
+
+	// COORD_BACKEND=redis にすると、接続元ごとの上限 (INGEST_RATE_LIMIT) と通知の重複防止を全てのインスタンスで分け合う
+	shared, err := coord.FromEnv(clk)
+	if err != nil {
+		log.Fatal("Invalid coordination settings:", err)
+	}
+
+	// PAGERDUTY_ROUTING_KEY / OPSGENIE_API_KEY を設定すると、エラー以上とアラートルールをインシデントとしても送る
+	incidents := notify.IncidentsFromEnv(clk)
+
+	// 複数のインスタンスで同じDBを使う時、保守の定期処理（保存期間・集計・まとめなど）は
+	// advisory lock を取れた1つだけが動かす (LEADER_ELECTION=false で全てのインスタンスが動かす)
+	var elector server.Elector
+	if db != nil && config.Bool("LEADER_ELECTION", true) {
+		elector = db.LeaderLock("maintenance")
+	}
+
+	srv := server.New(cfg, server.Deps{
+		Store:     logStore,
+		Notifier:  notify.FromEnv(clk),
+		Incidents: incidents,
+		Enricher:  enrich.FromEnv(),
+		IDs:       idgen.FromEnv(),
+		Clock:     clk,
+		Auth:      dashboardAuth,
+		Queue:     notifyQueue,
+		Stream:    publisher,
+		Consumer:  consumer,
+		Archiver:  archiver,
+		Exports:   exportBucket,
+		Redact:    redaction,
+		Coord:     shared,
+		Leader:    elector,
+	})
+	// バッファから書き戻したログも含め、保存されたログをサブスクリプションへ流す
+	if db != nil {
+		db.OnInsert = srv.Publish
+		// 起動後も接続を監視し、切れたら張り直す
+		go db.Watch(ctx, config.Duration("DB_HEALTH_INTERVAL", 5*time.Second))
+	} else {
+		mem.OnInsert = srv.Publish
+	}
+	srv.Run(ctx)
+	// SIGHUP（ENV_FILE_WATCH_INTERVAL を設定すればファイルの変更）で通知先・上限・除外などを読み直す
+	go watchReload(ctx, srv, settingsFile, clk)
+
+	if faults.Enabled {
+		fmt.Println("Fault injection is compiled in (chaos build): do not use this binary in production")
+	}
+	if bi := buildinfo.Get(); bi.Commit != "" {
+		fmt.Printf("Go-Logger %s (commit %s)\n", bi.Version, bi.ShortCommit())
+	} else {
+		fmt.Println("Go-Logger", bi.Version)
+	}
+	fmt.Println("Features:", srv.Config().Features)
+	if srv.Config().DryRun {
+		fmt.Println("DRY_RUN is enabled: events and notifications are printed instead of being stored or sent")
+	}
+	if limit := srv.Config().IngestRateLimit; limit.Enabled() {
+		fmt.Printf("Limiting writes to %s per client IP (counted in %s)\n", limit, shared.Name())
+	}
+	if throttles := srv.Config().NotifyThrottles; len(throttles) > 0 {
+		fmt.Printf("Throttling new-log notifications per source: %s (counted in %s)\n", throttles, shared.Name())
+	}
+	if window := srv.Config().NotifyDedupWindow; window > 0 {
+		where := shared.Name()
+		if srv.Config().NotifyDedup == "database" {
+			where = "database"
+		}
+		fmt.Printf("Sending each notification key at most once per %s across instances (marked in %s)\n", window, where)
+	}
+	if incidents.Len() > 0 {
+		fmt.Println("Sending incidents to:", strings.Join(incidents.Names(), ", "))
+	}
+	if archiver != nil {
+		fmt.Printf("Expired events are archived as %s before deletion\n", archiver.Format())
+	}
+	if dir := srv.Config().StaticDir; dir != "" {
+		fmt.Println("Serving the dashboard from disk:", dir)
+	}
+	for _, tp := range srv.Config().TrackedPaths {
+		fmt.Printf("Tracking %s as %q\n", tp.Pattern, tp.EventType)
+	}
+
+	// サーバー起動 (全ルートをトレース付きで包む)
+	// ヘッダーを少しずつ送る遅いクライアントが接続を占有しないよう、ヘッダーの読み込みにも上限をかける
+	httpServer := &http.Server{
+		Addr:              srv.Config().Addr,
+		Handler:           srv.Handler(),
+		MaxHeaderBytes:    srv.Config().MaxHeaderBytes,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// TLS_CERT_FILE / TLS_AUTOCERT_HOSTS を設定すると HTTPS で待ち受ける
+	tlsSettings, err := tlsFromEnv()
+	if err != nil {
+		log.Fatal("Invalid TLS settings:", err)
+	}
+	go func() {
+		var err error
+		if tlsSettings != nil {
+			err = tlsSettings.serve(httpServer)
+		} else {
+			fmt.Printf("Server starting on %s%s/...\n", httpServer.Addr, srv.Config().BasePath)
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// DEBUG_ADDR を設定すると、pprof と expvar を別のポートで出す（認証なし。127.0.0.1 などにする）
+	if addr := srv.Config().DebugAddr; addr != "" {
+		debugServer := &http.Server{Addr: addr, Handler: srv.DebugHandler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			fmt.Printf("Debug endpoints (pprof, expvar) listening on %s/debug/\n", addr)
+			if err := debugServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Println("Debug server stopped:", err)
+			}
+		}()
+		defer debugServer.Close()
+	}
+
+	// シグナル受信で停止し、未送信のスパンをフラッシュする
+	<-ctx.Done()
+	fmt.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	httpServer.Shutdown(shutdownCtx)
+	if err := shutdownTracer(shutdownCtx); err != nil {
+		fmt.Println("Failed to flush traces:", err)
+	}
+}
+
+// watchReload : SIGHUP か ENV_FILE の変更で設定を読み直す（待ち受け・DBの接続・送信待ちの通知はそのまま）
+// 読み直すものは server.Reload を参照。ENV_FILE を読めなかった時は何も変えない
+func watchReload(ctx context.Context, srv *server.Server, file *envFile, clk clock.Clock) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	// Windows のサービスなど SIGHUP を送れない環境向けに、ファイルの更新日時を定期的に確かめる
+	var changed <-chan time.Time
+	var modTime time.Time
+	if interval := config.Duration("ENV_FILE_WATCH_INTERVAL", 0); file != nil && interval > 0 {
+		if info, err := os.Stat(file.path); err == nil {
+			modTime = info.ModTime()
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		changed = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			fmt.Println("Reloading configuration (SIGHUP)")
+		case <-changed:
+			info, err := os.Stat(file.path)
+			if err != nil || info.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = info.ModTime()
+			fmt.Println("Reloading configuration (ENV_FILE changed)")
+		}
+		if file != nil {
+			if err := file.load(); err != nil {
+				fmt.Println("Failed to reload ENV_FILE:", err)
+				continue
+			}
+		}
+		if err := srv.Reload(ctx, server.ConfigFromEnv(), notify.FromEnv(clk)); err != nil {
+			fmt.Println("Configuration reloaded with errors:", err)
+		}
+	}
+}
+
+// openPostgres : Postgres に接続し、未適用のマイグレーションと INDEXED_FIELDS の列を用意する（失敗したら終了する）
+func openPostgres(ctx context.Context, clk clock.Clock) *store.Postgres {
+	db := store.NewPostgres(store.ConnStrFromEnv(), clk)
+	// DB_REPLICA_DSN を設定すると、ログの一覧・集計・エクスポートはレプリカから読む
+	db.UseReplica(store.ReplicaConnStrFromEnv())
+
+	// DBが起動するまでリトライする（最大10回 / 20秒待機）
+	if err := db.Connect(ctx); err != nil {
+		log.Fatal("Failed to connect to database after retries:", err)
+	}
+
+	// スキーママイグレーション (未適用分のみ)
+	// MIGRATE_ON_START=false の場合は `main migrate` で手動適用する
+	if config.Bool("MIGRATE_ON_START", true) {
+		if err := db.Migrate(ctx); err != nil {
+			log.Fatal("Failed to apply migrations:", err)
+		}
+	}
+
+	// INDEXED_FIELDS で指定した fields のキーを生成列として取り出し、索引を張る
+	if err := db.EnsureIndexedFields(ctx, store.IndexedFieldsFromEnv()); err != nil {
+		log.Fatal("Failed to set up indexed fields:", err)
+	}
+
+	// マイグレーションで作るはずの索引がなければ知らせる（絞り込みが全件読みになるため）
+	if missing, err := db.MissingIndexes(ctx); err != nil {
+		fmt.Println("Failed to check indexes:", err)
+	} else {
+		for _, idx := range missing {
+			fmt.Printf("Missing index %s: run `%s`\n", idx.Name, idx.Create)
+		}
+	}
+	return db
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// ==========================================
+// サービスとしての登録 (Docker を使わない Windows / macOS 向け)
+// ==========================================
+// Windows はサービスマネージャー、macOS は launchd に `main service run` を登録する
+// サービスには .env が渡らないので、実行ファイルと同じ場所の go-logger.env を読み込む
+
+// serviceName : 登録する名前
+const serviceName = "go-logger"
+
+// newServiceCommand : `main service install|uninstall|run [--env-file PATH]`
+func newServiceCommand() *cobra.Command {
+	var envFile string
+	cmd := &cobra.Command{
+		Use:   "service",
+		Short: "Windows サービス / macOS の launchd に登録する",
+	}
+	cmd.PersistentFlags().StringVar(&envFile, "env-file", "", "読み込む設定ファイル（既定は実行ファイルと同じ場所の go-logger.env）")
+
+	// paths : 実行ファイルと設定ファイルの絶対パス
+	paths := func() (exe, env string, err error) {
+		if exe, err = os.Executable(); err != nil {
+			return "", "", err
+		}
+		env = envFile
+		if env == "" {
+			env = filepath.Join(filepath.Dir(exe), serviceName+".env")
+		}
+		env, err = filepath.Abs(env)
+		return exe, env, err
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "install",
+		Short: "サービスとして登録する",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exe, env, err := paths()
+			if err != nil {
+				return err
+			}
+			return installService(exe, env)
+		},
+	}, &cobra.Command{
+		Use:   "uninstall",
+		Short: "登録を解除する",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return uninstallService()
+		},
+	}, &cobra.Command{
+		Use:   "run",
+		Short: "サービスマネージャーから起動される（設定ファイルを読み込んでサーバーを動かす）",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exe, env, err := paths()
+			if err != nil {
+				return err
+			}
+			// サービスの作業ディレクトリは決まっていないので、STATIC_DIR などの相対パスが見つかるよう実行ファイルの場所に移る
+			if err := os.Chdir(filepath.Dir(exe)); err != nil {
+				return err
+			}
+			// 読み込みは runServer に任せる（SIGHUP で読み直せるように ENV_FILE として渡す）
+			if _, err := os.Stat(env); err == nil {
+				os.Setenv("ENV_FILE", env)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("load %s: %w", env, err)
+			}
+			return runService(cmd.Context(), runServer)
+		},
+	})
+	return cmd
+}
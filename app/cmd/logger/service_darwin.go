@@ -0,0 +1,103 @@
+//go:build darwin
+
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ==========================================
+// macOS の launchd
+// ==========================================
+
+// launchdLabel : launchd のジョブ名
+const launchdLabel = "jp.aliceindex." + serviceName
+
+// launchdPlistPath : root なら /Library/LaunchDaemons（起動時から）、それ以外は ~/Library/LaunchAgents（ログイン時から）
+func launchdPlistPath() (string, error) {
+	if os.Geteuid() == 0 {
+		return filepath.Join("/Library/LaunchDaemons", launchdLabel+".plist"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+// xmlEscape : plist に埋め込む文字列
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// installService : plist を書き出して launchctl で読み込む（落ちたら launchd が起動し直す）
+func installService(exe, envFile string) error {
+	path, err := launchdPlistPath()
+	if err != nil {
+		return err
+	}
+	logDir := filepath.Dir(exe)
+	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key><string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s</string>
+		<string>service</string>
+		<string>run</string>
+		<string>--env-file</string>
+		<string>%s</string>
+	</array>
+	<key>RunAtLoad</key><true/>
+	<key>KeepAlive</key><true/>
+	<key>StandardOutPath</key><string>%s</string>
+	<key>StandardErrorPath</key><string>%s</string>
+</dict>
+</plist>
+`, launchdLabel, xmlEscape(exe), xmlEscape(envFile),
+		xmlEscape(filepath.Join(logDir, serviceName+".log")), xmlEscape(filepath.Join(logDir, serviceName+".err.log")))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(plist), 0o644); err != nil {
+		return err
+	}
+	if out, err := exec.Command("launchctl", "load", "-w", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("launchctl load: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	fmt.Printf("Installed %s (settings: %s)\n", path, envFile)
+	return nil
+}
+
+// uninstallService : launchctl から外して plist を消す
+func uninstallService() error {
+	path, err := launchdPlistPath()
+	if err != nil {
+		return err
+	}
+	if out, err := exec.Command("launchctl", "unload", "-w", path).CombinedOutput(); err != nil {
+		fmt.Printf("launchctl unload: %v: %s\n", err, strings.TrimSpace(string(out)))
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	fmt.Printf("Uninstalled %s\n", path)
+	return nil
+}
+
+// runService : launchd は SIGTERM で止めるので、普段どおり動かす
+func runService(ctx context.Context, run func(context.Context)) error {
+	run(ctx)
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build !windows && !darwin
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// errNoServiceManager : Linux などは Docker か systemd のユニットで動かす
+var errNoServiceManager = errors.New("service install is only supported on Windows and macOS (use Docker or a systemd unit)")
+
+func installService(exe, envFile string) error { return errNoServiceManager }
+
+func uninstallService() error { return errNoServiceManager }
+
+// runService : 普段どおり動かす（systemd から `main service run` で go-logger.env を読ませる用）
+func runService(ctx context.Context, run func(context.Context)) error {
+	run(ctx)
+	return nil
+}
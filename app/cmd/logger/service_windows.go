@@ -0,0 +1,114 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// ==========================================
+// Windows サービス
+// ==========================================
+
+// installService : 自動起動のサービスとして登録する
+func installService(exe, envFile string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s is already installed", serviceName)
+	}
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "go-logger",
+		Description: "Access logging and notification server",
+		StartType:   mgr.StartAutomatic,
+	}, "service", "run", "--env-file", envFile)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// 落ちた場合は1分後に再起動する
+	s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: time.Minute}}, 24*60*60)
+	fmt.Printf("Installed service %s (settings: %s). Start it with: sc start %s\n", serviceName, envFile, serviceName)
+	return nil
+}
+
+// uninstallService : 登録を解除する（動いていれば先に止める）
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+	s.Control(svc.Stop)
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	fmt.Printf("Uninstalled service %s\n", serviceName)
+	return nil
+}
+
+// runService : サービスマネージャーから起動された場合は、停止の要求で ctx を終わらせる
+// コマンドプロンプトから実行した場合はそのまま動かす
+func runService(ctx context.Context, run func(context.Context)) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		run(ctx)
+		return nil
+	}
+	return svc.Run(serviceName, &windowsService{ctx: ctx, run: run})
+}
+
+// windowsService : svc.Handler
+type windowsService struct {
+	ctx context.Context
+	run func(context.Context)
+}
+
+func (ws *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	ctx, cancel := context.WithCancel(ws.ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ws.run(ctx)
+	}()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case <-done:
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+				<-done
+				return false, 0
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// tail --server : 動いているインスタンスの新着ログを受け取る
+// ==========================================
+// GraphQL の newEntries サブスクリプションを SSE (Accept: text/event-stream) で受け取る
+// 切断されたら少し待って繋ぎ直す（繋いでいない間に届いたログは表示されない）
+
+// tailReconnectDelay : 切断後に繋ぎ直すまでの時間
+const tailReconnectDelay = 3 * time.Second
+
+// tailSubscription : 表示に使う項目だけ受け取る
+const tailSubscription = `subscription($type: String, $level: String) {
+  newEntries(type: $type, level: $level) {
+    id uid projectId eventType level message userAgent ip visitorId country path referrer
+    browser os device isBot tags note fields hitCount createdAt
+  }
+}`
+
+// tailEntry : newEntries の1件（GraphQL の項目名のまま）
+type tailEntry struct {
+	ID        int       `json:"id"`
+	UID       string    `json:"uid"`
+	ProjectID int       `json:"projectId"`
+	EventType string    `json:"eventType"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	UserAgent string    `json:"userAgent"`
+	IP        string    `json:"ip"`
+	VisitorID string    `json:"visitorId"`
+	Country   string    `json:"country"`
+	Path      string    `json:"path"`
+	Referrer  string    `json:"referrer"`
+	Browser   string    `json:"browser"`
+	OS        string    `json:"os"`
+	Device    string    `json:"device"`
+	IsBot     bool      `json:"isBot"`
+	Tags      []string  `json:"tags"`
+	Note      string    `json:"note"`
+	Fields    *string   `json:"fields"`
+	HitCount  int       `json:"hitCount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (t tailEntry) entry() model.LogEntry {
+	e := model.LogEntry{
+		ID: t.ID, UID: t.UID, ProjectID: t.ProjectID, EventType: t.EventType, Level: t.Level,
+		Message: t.Message, UserAgent: t.UserAgent, IP: t.IP, VisitorID: t.VisitorID, Country: t.Country,
+		Path: t.Path, Referrer: t.Referrer, Browser: t.Browser, OS: t.OS, Device: t.Device,
+		IsBot: t.IsBot, Tags: t.Tags, Note: t.Note, HitCount: t.HitCount, CreatedAt: t.CreatedAt,
+	}
+	if t.Fields != nil {
+		e.Fields = json.RawMessage(*t.Fields)
+	}
+	return e
+}
+
+// tailRemote : 接続先の情報
+type tailRemote struct {
+	server   string // ベースパスを含むURL (http://host:8081)
+	key      string // プロジェクトキー（X-API-Key）
+	user     string // DASHBOARD_USERS のユーザー（user:password）
+	query    logQueryFlags
+	printLog func(model.LogEntry)
+}
+
+// follow : ctx が終わるまで受け取り続ける
+func (r tailRemote) follow(ctx context.Context) error {
+	for {
+		err := r.stream(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if se, ok := err.(tailStatusError); ok && se.permanent() {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Stream disconnected (%v), reconnecting in %s\n", err, tailReconnectDelay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(tailReconnectDelay):
+		}
+	}
+}
+
+// tailStatusError : 接続先が 200 以外を返した
+type tailStatusError struct {
+	status int
+	body   string
+}
+
+func (e tailStatusError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.status, e.body)
+}
+
+// permanent : 繋ぎ直しても直らない（認証・リクエストの誤り）
+func (e tailStatusError) permanent() bool {
+	return e.status >= 400 && e.status < 500 && e.status != 429
+}
+
+// stream : 1回接続し、切れるまで表示する
+func (r tailRemote) stream(ctx context.Context) error {
+	vars := map[string]any{}
+	if r.query.eventType != "" {
+		vars["type"] = r.query.eventType
+	}
+	if r.query.level != "" {
+		vars["level"] = r.query.level
+	}
+	body, _ := json.Marshal(map[string]any{"query": tailSubscription, "variables": vars})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(r.server, "/")+"/api/graphql", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if r.key != "" {
+		req.Header.Set("X-API-Key", r.key)
+	}
+	if r.user != "" {
+		name, password, _ := strings.Cut(r.user, ":")
+		req.SetBasicAuth(name, password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return tailStatusError{status: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+	}
+
+	// "event: next" の data 行が1件。エラーは {"errors": [...]} で届く
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var res struct {
+			Data struct {
+				NewEntries *tailEntry `json:"newEntries"`
+			} `json:"data"`
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal([]byte(data), &res); err != nil {
+			return fmt.Errorf("invalid event: %w", err)
+		}
+		if len(res.Errors) > 0 {
+			return tailStatusError{status: http.StatusBadRequest, body: res.Errors[0].Message}
+		}
+		if res.Data.NewEntries != nil {
+			r.printLog(res.Data.NewEntries.entry())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("stream closed")
+}
+
+// ==========================================
+// 表示
+// ==========================================
+
+// levelColors : レベルごとの ANSI 色
+var levelColors = map[string]string{
+	"debug": "\x1b[90m", // 灰
+	"info":  "\x1b[36m", // シアン
+	"warn":  "\x1b[33m", // 黄
+	"error": "\x1b[31m", // 赤
+	"fatal": "\x1b[1;41;97m",
+}
+
+const colorReset = "\x1b[0m"
+
+// useColor : 端末に出す時だけ色を付ける（NO_COLOR が設定されていれば付けない）
+func useColor(disabled bool) bool {
+	if disabled || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// entryPrinter : --filter に一致するものだけ表示する
+type entryPrinter struct {
+	filter *store.Query
+	color  bool
+}
+
+func (p entryPrinter) print(e model.LogEntry) {
+	if p.filter != nil && !p.filter.Match(&e) {
+		return
+	}
+	detail := e.Message
+	if detail == "" {
+		detail = e.UserAgent
+	}
+	level := fmt.Sprintf("%-5s", e.Level)
+	if c, ok := levelColors[e.Level]; ok && p.color {
+		level = c + level + colorReset
+	}
+	fmt.Printf("%s  #%d  %-10s %s %-15s %s  %s\n",
+		e.CreatedAt.Local().Format("2006-01-02 15:04:05"), e.ID, e.EventType, level, e.IP, e.Path, detail)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// HTTPS (nginx などのTLS終端を置かずに単体で動かす場合)
+// ==========================================
+
+// tlsSettings : HTTPS の設定（どちらも未設定なら HTTP で待ち受ける）
+//
+//	TLS_CERT_FILE / TLS_KEY_FILE  証明書と秘密鍵のファイル
+//	TLS_AUTOCERT_HOSTS            Let's Encrypt で証明書を取得するホスト名（カンマ区切り）
+//	TLS_AUTOCERT_CACHE            取得した証明書の保存先（既定 ./certs）
+//	TLS_AUTOCERT_EMAIL            期限切れなどの連絡先（任意）
+//	TLS_ADDR                      HTTPS の待ち受けアドレス（既定 :443）
+//	TLS_HTTP_ADDR                 HTTP-01 の確認と HTTPS へのリダイレクト用（autocert のみ。既定 :80、空なら使わない）
+type tlsSettings struct {
+	addr     string
+	certFile string
+	keyFile  string
+	manager  *autocert.Manager
+	httpAddr string
+}
+
+// tlsFromEnv : 環境変数から読み込む（HTTPS を使わないなら nil）
+func tlsFromEnv() (*tlsSettings, error) {
+	certFile, keyFile := config.String("TLS_CERT_FILE", ""), config.String("TLS_KEY_FILE", "")
+	hosts := config.List("TLS_AUTOCERT_HOSTS")
+	if certFile == "" && keyFile == "" && len(hosts) == 0 {
+		return nil, nil
+	}
+	t := &tlsSettings{addr: config.String("TLS_ADDR", ":443")}
+	switch {
+	case len(hosts) > 0 && (certFile != "" || keyFile != ""):
+		return nil, errors.New("set either TLS_CERT_FILE/TLS_KEY_FILE or TLS_AUTOCERT_HOSTS, not both")
+	case len(hosts) > 0:
+		t.manager = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(hosts...),
+			Cache:      autocert.DirCache(config.String("TLS_AUTOCERT_CACHE", "./certs")),
+			Email:      config.String("TLS_AUTOCERT_EMAIL", ""),
+		}
+		t.httpAddr = config.String("TLS_HTTP_ADDR", ":80")
+	case certFile == "" || keyFile == "":
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	default:
+		t.certFile, t.keyFile = certFile, keyFile
+	}
+	return t, nil
+}
+
+// serve : HTTPS で待ち受ける（autocert なら HTTP 側で証明書の確認とリダイレクトも受ける）
+func (t *tlsSettings) serve(httpServer *http.Server) error {
+	httpServer.Addr = t.addr
+	if t.manager == nil {
+		fmt.Printf("Server starting on %s (TLS)...\n", t.addr)
+		return httpServer.ListenAndServeTLS(t.certFile, t.keyFile)
+	}
+
+	httpServer.TLSConfig = t.manager.TLSConfig()
+	if t.httpAddr != "" {
+		go func() {
+			// HTTP-01 の確認以外は HTTPS へリダイレクトする
+			if err := http.ListenAndServe(t.httpAddr, t.manager.HTTPHandler(nil)); err != nil {
+				fmt.Println("ACME HTTP listener stopped:", err)
+			}
+		}()
+	}
+	fmt.Printf("Server starting on %s (TLS, autocert)...\n", t.addr)
+	return httpServer.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,191 @@
+// Package archive : 保存期間を過ぎたログを S3 互換のバケットへ書き出す (削除する前に退避する)
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+)
+
+// 書き出しの形式
+const (
+	FormatNDJSON  = "ndjson"  // 1行1件の JSON を gzip で圧縮したもの (.ndjson.gz)
+	FormatParquet = "parquet" // 列ごとに型の付いた Parquet (.parquet。DuckDB / Athena からそのまま読める)
+)
+
+// Manifest : バッチ1つ分の目録（データと同じ場所に <データのキー>.manifest.json として置く）
+type Manifest struct {
+	Table        string    `json:"table"`
+	Format       string    `json:"format"`
+	Object       string    `json:"object"`
+	Rows         int       `json:"rows"`
+	Bytes        int64     `json:"bytes"`
+	SHA256       string    `json:"sha256"`
+	FirstID      int       `json:"first_id"`
+	LastID       int       `json:"last_id"`
+	OldestAt     time.Time `json:"oldest_at"`
+	NewestAt     time.Time `json:"newest_at"`
+	ArchivedAt   time.Time `json:"archived_at"`
+	Instance     string    `json:"instance,omitempty"`
+	SchemaFields []string  `json:"schema_fields"`
+}
+
+// schemaFields : 書き出す項目（NDJSON のキーと Parquet の列は同じ名前）
+var schemaFields = []string{
+	"id", "uid", "project_id", "user_agent", "ip", "country", "path", "referrer", "event_type",
+	"level", "message", "fields", "browser", "os", "device", "is_bot", "created_at", "expires_at",
+}
+
+// Archiver : 決まった形式でバケットへ書き出す
+type Archiver struct {
+	bucket   *Bucket
+	format   string
+	instance string
+}
+
+// New : 形式を指定して作る
+func New(bucket *Bucket, format, instance string) (*Archiver, error) {
+	if format != FormatNDJSON && format != FormatParquet {
+		return nil, fmt.Errorf("unknown archive format %q (use %s or %s)", format, FormatNDJSON, FormatParquet)
+	}
+	return &Archiver{bucket: bucket, format: format, instance: instance}, nil
+}
+
+// FromEnv : ARCHIVE_FORMAT とバケットの設定があれば作る（なければ nil で、アーカイブせずに削除する）
+//
+//	ARCHIVE_FORMAT  ndjson / parquet
+//	ARCHIVE_S3_*    書き出し先のバケット（BucketFromEnv を参照）
+func FromEnv() (*Archiver, error) {
+	format := config.String("ARCHIVE_FORMAT", "")
+	if format == "" {
+		return nil, nil
+	}
+	bucket, err := BucketFromEnv("ARCHIVE_S3")
+	if err != nil {
+		return nil, err
+	}
+	if bucket == nil {
+		return nil, fmt.Errorf("ARCHIVE_FORMAT is set but ARCHIVE_S3_BUCKET is empty")
+	}
+	return New(bucket, format, config.String("INSTANCE_NAME", ""))
+}
+
+// Format : 書き出しの形式
+func (a *Archiver) Format() string { return a.format }
+
+// Archive : entries（ID順）を1つのオブジェクトとして書き出し、目録を置く
+// 目録が置けたら書き出し済みとみなす（呼び出し側はその後で行を削除する）
+func (a *Archiver) Archive(ctx context.Context, table string, entries []model.LogEntry, now time.Time) (*Manifest, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	contentType, ext := "application/gzip", ".ndjson.gz"
+	if a.format == FormatParquet {
+		contentType, ext = "application/vnd.apache.parquet", ".parquet"
+	}
+	if err := Encode(&buf, a.format, entries); err != nil {
+		return nil, fmt.Errorf("encode: %w", err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+
+	m := &Manifest{
+		Table:        table,
+		Format:       a.format,
+		Rows:         len(entries),
+		Bytes:        int64(buf.Len()),
+		SHA256:       hex.EncodeToString(sum[:]),
+		FirstID:      entries[0].ID,
+		LastID:       entries[len(entries)-1].ID,
+		OldestAt:     entries[0].CreatedAt,
+		NewestAt:     entries[0].CreatedAt,
+		ArchivedAt:   now,
+		Instance:     a.instance,
+		SchemaFields: schemaFields,
+	}
+	for _, e := range entries {
+		if e.CreatedAt.Before(m.OldestAt) {
+			m.OldestAt = e.CreatedAt
+		}
+		if e.CreatedAt.After(m.NewestAt) {
+			m.NewestAt = e.CreatedAt
+		}
+	}
+	// 例: go-logger/access_logs/2026/10/14/access_logs-1001-6000.ndjson.gz
+	m.Object = fmt.Sprintf("%s/%s/%s-%d-%d%s", table, now.Format("2006/01/02"), table, m.FirstID, m.LastID, ext)
+
+	if err := a.bucket.Put(ctx, m.Object, &buf, m.Bytes, contentType); err != nil {
+		return nil, fmt.Errorf("upload %s: %w", m.Object, err)
+	}
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.bucket.Put(ctx, m.Object+".manifest.json", bytes.NewReader(manifest), int64(len(manifest)), "application/json"); err != nil {
+		return nil, fmt.Errorf("upload manifest: %w", err)
+	}
+	return m, nil
+}
+
+// Encode : entries を形式に合わせて w へ書き出す
+func Encode(w io.Writer, format string, entries []model.LogEntry) error {
+	enc, err := NewEncoder(w, format)
+	if err != nil {
+		return err
+	}
+	if err := enc.Write(entries); err != nil {
+		enc.Close()
+		return err
+	}
+	return enc.Close()
+}
+
+// Encoder : 何回かに分けて1つのファイルへ書き出す（全件をメモリに載せずに書き出す `main export` 用）
+type Encoder struct {
+	write func(entries []model.LogEntry) error
+	close func() error
+}
+
+// NewEncoder : format (FormatNDJSON / FormatParquet) で w へ書き出す。最後に Close で書き終える
+func NewEncoder(w io.Writer, format string) (*Encoder, error) {
+	switch format {
+	case FormatNDJSON:
+		return newNDJSONEncoder(w), nil
+	case FormatParquet:
+		return newParquetEncoder(w), nil
+	}
+	return nil, fmt.Errorf("unknown archive format %q (use %s or %s)", format, FormatNDJSON, FormatParquet)
+}
+
+// Write : entries を続きに書く
+func (e *Encoder) Write(entries []model.LogEntry) error { return e.write(entries) }
+
+// Close : 書き終える（gzip の末尾・Parquet のフッターを書く）
+func (e *Encoder) Close() error { return e.close() }
+
+// newNDJSONEncoder : 1行1件の JSON を gzip で圧縮する（項目は読み出しAPIと同じ）
+func newNDJSONEncoder(w io.Writer) *Encoder {
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	return &Encoder{
+		write: func(entries []model.LogEntry) error {
+			for i := range entries {
+				if err := enc.Encode(&entries[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		close: zw.Close,
+	}
+}
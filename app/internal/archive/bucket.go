@@ -0,0 +1,109 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// S3 互換のバケット (AWS S3 / MinIO / Cloudflare R2 など)
+// ==========================================
+
+// Bucket : 書き出し先のバケットと、キーの接頭辞
+type Bucket struct {
+	client *minio.Client
+	name   string
+	prefix string
+}
+
+// BucketFromEnv : <prefix>_BUCKET が設定されていれば作る（なければ nil）
+//
+//	<prefix>_ENDPOINT    例: https://s3.ap-northeast-1.amazonaws.com, http://minio:9000（既定は AWS）
+//	<prefix>_BUCKET      バケット名
+//	<prefix>_PREFIX      キーの接頭辞（既定 go-logger）
+//	<prefix>_REGION      リージョン（省略時は自動判定）
+//	<prefix>_ACCESS_KEY / <prefix>_SECRET_KEY  認証情報（省略時は AWS_* の環境変数やインスタンスロール）
+//	<prefix>_PATH_STYLE  true ならパス形式の URL にする（MinIO など）
+func BucketFromEnv(prefix string) (*Bucket, error) {
+	name := config.String(prefix+"_BUCKET", "")
+	if name == "" {
+		return nil, nil
+	}
+	endpoint, secure := "s3.amazonaws.com", true
+	if raw := config.String(prefix+"_ENDPOINT", ""); raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid %s_ENDPOINT %q (use e.g. https://s3.example.com)", prefix, raw)
+		}
+		endpoint, secure = u.Host, u.Scheme != "http"
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{},
+	})
+	if key := config.String(prefix+"_ACCESS_KEY", ""); key != "" {
+		creds = credentials.NewStaticV4(key, config.String(prefix+"_SECRET_KEY", ""), "")
+	}
+	lookup := minio.BucketLookupAuto
+	if config.Bool(prefix+"_PATH_STYLE", false) {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        creds,
+		Secure:       secure,
+		Region:       config.String(prefix+"_REGION", ""),
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s3 client: %w", err)
+	}
+	return &Bucket{
+		client: client,
+		name:   name,
+		prefix: strings.Trim(config.String(prefix+"_PREFIX", "go-logger"), "/"),
+	}, nil
+}
+
+// key : 接頭辞を付けたキー
+func (b *Bucket) key(name string) string {
+	if b.prefix == "" {
+		return name
+	}
+	return b.prefix + "/" + name
+}
+
+// Put : オブジェクトを1つ書き込む
+func (b *Bucket) Put(ctx context.Context, name string, r io.Reader, size int64, contentType string) error {
+	_, err := b.client.PutObject(ctx, b.name, b.key(name), r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+// PresignGet : name を expiry の間だけ認証なしで取り出せる URL（filename を付ければその名前で保存させる）
+func (b *Bucket) PresignGet(ctx context.Context, name, filename string, expiry time.Duration) (string, error) {
+	params := url.Values{}
+	if filename != "" {
+		params.Set("response-content-disposition", `attachment; filename="`+filename+`"`)
+	}
+	u, err := b.client.PresignedGetObject(ctx, b.name, b.key(name), expiry, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// Remove : オブジェクトを1つ削除する
+func (b *Bucket) Remove(ctx context.Context, name string) error {
+	return b.client.RemoveObject(ctx, b.name, b.key(name), minio.RemoveObjectOptions{})
+}
@@ -0,0 +1,92 @@
+package archive
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"go-logger/internal/model"
+)
+
+// parquetRow : Parquet の1行（時刻は UTC のナノ秒、fields と headers は JSON の文字列。optional の列は空なら null）
+type parquetRow struct {
+	ID        int64      `parquet:"id"`
+	UID       string     `parquet:"uid,optional"`
+	ProjectID int32      `parquet:"project_id"`
+	UserAgent string     `parquet:"user_agent"`
+	IP        string     `parquet:"ip,optional"`
+	VisitorID string     `parquet:"visitor_id,optional"`
+	Country   string     `parquet:"country,optional,dict"`
+	Path      string     `parquet:"path"`
+	Referrer  string     `parquet:"referrer"`
+	EventType string     `parquet:"event_type,dict"`
+	Level     string     `parquet:"level,optional,dict"`
+	Message   string     `parquet:"message,optional"`
+	Fields    string     `parquet:"fields,optional,json"`
+	Browser   string     `parquet:"browser,optional,dict"`
+	OS        string     `parquet:"os,optional,dict"`
+	Device    string     `parquet:"device,optional,dict"`
+	IsBot     bool       `parquet:"is_bot"`
+	CreatedAt time.Time  `parquet:"created_at"`
+	ExpiresAt *time.Time `parquet:"expires_at"`
+	HitCount  int32      `parquet:"hit_count"`
+	LastHitAt *time.Time `parquet:"last_hit_at"`
+	Tags      []string   `parquet:"tags,list"`
+	Note      string     `parquet:"note,optional"`
+	Status    int32      `parquet:"status,optional"`
+	Duration  *float64   `parquet:"duration_ms"`
+	Headers   string     `parquet:"headers,optional,json"`
+}
+
+// newParquetEncoder : 1つの Parquet ファイルにする（zstd で圧縮。行グループの区切りは parquet-go に任せる）
+func newParquetEncoder(w io.Writer) *Encoder {
+	pw := parquet.NewGenericWriter[parquetRow](w, parquet.Compression(&parquet.Zstd))
+	return &Encoder{
+		write: func(entries []model.LogEntry) error {
+			_, err := pw.Write(parquetRows(entries))
+			return err
+		},
+		close: pw.Close,
+	}
+}
+
+// parquetRows : LogEntry を Parquet の行にする
+func parquetRows(entries []model.LogEntry) []parquetRow {
+	rows := make([]parquetRow, len(entries))
+	for i, e := range entries {
+		rows[i] = parquetRow{
+			ID:        int64(e.ID),
+			UID:       e.UID,
+			ProjectID: int32(e.ProjectID),
+			UserAgent: e.UserAgent,
+			IP:        e.IP,
+			VisitorID: e.VisitorID,
+			Country:   e.Country,
+			Path:      e.Path,
+			Referrer:  e.Referrer,
+			EventType: e.EventType,
+			Level:     e.Level,
+			Message:   e.Message,
+			Fields:    string(e.Fields),
+			Browser:   e.Browser,
+			OS:        e.OS,
+			Device:    e.Device,
+			IsBot:     e.IsBot,
+			CreatedAt: e.CreatedAt.UTC(),
+			ExpiresAt: e.ExpiresAt,
+			HitCount:  int32(max(e.HitCount, 1)),
+			LastHitAt: e.LastHitAt,
+			Tags:      e.Tags,
+			Note:      e.Note,
+			Status:    int32(e.Status),
+			Duration:  e.DurationMS,
+		}
+		if len(e.Headers) > 0 {
+			b, _ := json.Marshal(e.Headers)
+			rows[i].Headers = string(b)
+		}
+	}
+	return rows
+}
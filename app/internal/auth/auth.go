@@ -0,0 +1,85 @@
+// Package auth : ダッシュボードのログイン
+// 認証方式は Authenticator として差し替えられる（今は Basic 認証。OIDC などは後から追加できる）
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go-logger/internal/config"
+)
+
+// Authenticator : 認証方式1つ分
+type Authenticator interface {
+	Name() string
+	// Authenticate : 認証済みならユーザー名を返す
+	Authenticate(r *http.Request) (user string, ok bool)
+	// Challenge : 未認証のリクエストへの応答（Basic なら 401、OIDC ならログイン画面へのリダイレクト）
+	Challenge(w http.ResponseWriter, r *http.Request)
+}
+
+// Router : ログイン用のルートが必要な認証方式（OIDC のコールバックなど）が実装する
+// サーバーは Authenticator がこれを満たしていれば、認証の外側にルートを追加する
+type Router interface {
+	Mount(mux *http.ServeMux)
+}
+
+// userKey : 認証済みユーザー名を入れるコンテキストキー
+type userKey struct{}
+
+// User : Middleware で認証されたユーザー名（未認証・認証なしなら空）
+func User(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// WithUser : ユーザー名をコンテキストに入れる
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// Middleware : 認証できたリクエストだけを next に渡す（a が nil なら何もしない）
+func Middleware(a Authenticator, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := a.Authenticate(r)
+		if !ok {
+			a.Challenge(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+	})
+}
+
+// FromEnv : DASHBOARD_AUTH で認証方式を選ぶ（none なら nil = 認証なし）
+//
+//	DASHBOARD_AUTH    basic / none（既定: DASHBOARD_USERS があれば basic）
+//	DASHBOARD_USERS   "alice:password,bob:password"
+//	DASHBOARD_REALM   Basic 認証のレルム（既定 go-logger）
+func FromEnv() (Authenticator, error) {
+	users := config.List("DASHBOARD_USERS")
+	mode := config.String("DASHBOARD_AUTH", "")
+	if mode == "" {
+		mode = "none"
+		if len(users) > 0 {
+			mode = "basic"
+		}
+	}
+
+	switch mode {
+	case "none":
+		return nil, nil
+	case "basic":
+		b, err := ParseBasicUsers(users)
+		if err != nil {
+			return nil, err
+		}
+		b.Realm = config.String("DASHBOARD_REALM", "go-logger")
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unknown DASHBOARD_AUTH %q (use basic or none)", mode)
+	}
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ==========================================
+// Basic 認証
+// ==========================================
+
+// Basic : ユーザー名とパスワードの組で認証する
+type Basic struct {
+	Realm string
+	users map[string][32]byte // ユーザー名 → パスワードのSHA-256（長さの違いで比較時間が変わらないように）
+}
+
+// NewBasic : ユーザー名 → パスワードの組から作る
+func NewBasic(realm string, users map[string]string) *Basic {
+	b := &Basic{Realm: realm, users: map[string][32]byte{}}
+	for user, password := range users {
+		b.users[user] = sha256.Sum256([]byte(password))
+	}
+	return b
+}
+
+// ParseBasicUsers : "user:password" の一覧から作る
+func ParseBasicUsers(items []string) (*Basic, error) {
+	if len(items) == 0 {
+		return nil, errors.New("basic auth needs at least one user in DASHBOARD_USERS")
+	}
+	users := map[string]string{}
+	for _, item := range items {
+		user, password, ok := strings.Cut(item, ":")
+		if !ok || user == "" || password == "" {
+			return nil, fmt.Errorf("invalid DASHBOARD_USERS entry %q (use user:password)", user)
+		}
+		users[user] = password
+	}
+	return NewBasic("go-logger", users), nil
+}
+
+func (b *Basic) Name() string { return "basic" }
+
+func (b *Basic) Authenticate(r *http.Request) (string, bool) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	want, known := b.users[user]
+	given := sha256.Sum256([]byte(password))
+	if subtle.ConstantTimeCompare(given[:], want[:]) != 1 || !known {
+		return "", false
+	}
+	return user, true
+}
+
+func (b *Basic) Challenge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, b.Realm))
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
@@ -0,0 +1,59 @@
+// Package buildinfo : 実行ファイルのバージョン・コミット・ビルド時刻
+// ビルドの時に -ldflags で埋め込む（例: -X go-logger/internal/buildinfo.Version=v1.4.0）
+// 埋め込まなかった値は、go build が記録した VCS の情報 (vcs.revision / vcs.time はコミットの時刻) から補う
+package buildinfo
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// -ldflags "-X go-logger/internal/buildinfo.Version=... -X ...Commit=... -X ...BuildTime=2026-01-02T03:04:05Z" で埋め込む値
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = "" // RFC 3339
+)
+
+// Info : このビルドの情報
+type Info struct {
+	Version   string     `json:"version"`
+	Commit    string     `json:"commit,omitempty"`
+	BuildTime *time.Time `json:"build_time,omitempty"`
+	Modified  bool       `json:"modified,omitempty"` // コミットしていない変更のあるツリーからビルドした
+	GoVersion string     `json:"go_version"`
+}
+
+// Get : このビルドの情報（最初の呼び出しで1回だけ作る）
+var Get = sync.OnceValue(func() Info {
+	info := Info{Version: Version, Commit: Commit}
+	if t, err := time.Parse(time.RFC3339, BuildTime); err == nil {
+		info.BuildTime = &t
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.GoVersion = bi.GoVersion
+	for _, st := range bi.Settings {
+		switch st.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = st.Value
+			}
+		case "vcs.time":
+			if t, err := time.Parse(time.RFC3339, st.Value); err == nil && info.BuildTime == nil {
+				info.BuildTime = &t
+			}
+		case "vcs.modified":
+			info.Modified = st.Value == "true"
+		}
+	}
+	return info
+})
+
+// ShortCommit : コミットの先頭12文字（なければ空）
+func (i Info) ShortCommit() string {
+	return i.Commit[:min(len(i.Commit), 12)]
+}
@@ -0,0 +1,42 @@
+// Package clock : 時刻の取得元 (ハンドラ・定期処理・保持期間の判定で共通に使う)
+package clock
+
+import "time"
+
+// Clock : 現在時刻とタイマーの取得元
+// テストでは固定・手動で進める実装に差し替えられる
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	After(d time.Duration) <-chan time.Time    // d 後に1回だけ届く（time.After と同じ）
+	AfterFunc(d time.Duration, f func()) Timer // d 後に f を別の goroutine で呼ぶ（time.AfterFunc と同じ）
+}
+
+// Ticker : time.Ticker の差し替え可能な形
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// Timer : AfterFunc で予約した呼び出し（Stop で取り消す）
+type Timer interface {
+	Stop() bool
+}
+
+// System : 実際の時計。DBとアプリのタイムゾーンが違ってもずれないよう常にUTCを返す
+type System struct{}
+
+func (System) Now() time.Time { return time.Now().UTC() }
+
+func (System) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+func (System) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (System) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+type systemTicker struct{ t *time.Ticker }
+
+func (s systemTicker) C() <-chan time.Time   { return s.t.C }
+func (s systemTicker) Stop()                 { s.t.Stop() }
+func (s systemTicker) Reset(d time.Duration) { s.t.Reset(d) }
@@ -0,0 +1,69 @@
+// Package config : 環境変数の読み込みヘルパー
+// 各パッケージの ...FromEnv はここを通して設定を読む（実行中に環境変数を直接読まない）
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String : 文字列の環境変数（未設定ならデフォルト）
+func String(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// Int : 整数の環境変数（未設定・不正値ならデフォルト）
+func Int(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// Int64 : Int の64bit版（バイト数など）
+func Int64(key string, def int64) int64 {
+	if v, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// Float64 : 小数の環境変数（倍率など。未設定・不正値ならデフォルト）
+func Float64(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// Duration : "5s" 形式の環境変数（未設定・不正値ならデフォルト）
+func Duration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// Bool : "true" / "false" の環境変数（未設定・不正値ならデフォルト）
+func Bool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// List : カンマ区切りの環境変数（空の要素は除く）
+func List(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
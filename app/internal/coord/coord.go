@@ -0,0 +1,75 @@
+// Package coord : 複数のインスタンスで分け合う状態 (COORD_BACKEND=memory|redis)
+// 接続元ごとの上限と、通知を1回だけ送るための印に使う
+//
+//	memory  インスタンスごとに数える（既定。1台で動かす場合）
+//	redis   REDIS_URL に置き、プロキシの後ろの全てのインスタンスで同じ上限・印を使う
+package coord
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/config"
+)
+
+// Limit : Window の間に Count 件まで（窓ごとに数え直す）
+type Limit struct {
+	Count  int
+	Window time.Duration
+}
+
+// ParseLimit : "120/1m" の形（空なら上限なし）
+func ParseLimit(s string) (Limit, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return Limit{}, nil
+	}
+	count, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("want <count>/<duration> (e.g. 120/1m)")
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid count %q", count)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid duration %q", window)
+	}
+	return Limit{Count: n, Window: d}, nil
+}
+
+// Enabled : 上限があるか
+func (l Limit) Enabled() bool { return l.Count > 0 && l.Window > 0 }
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Count, l.Window)
+}
+
+// Store : インスタンスで分け合う状態
+type Store interface {
+	Name() string
+	// Allow : key の今の窓の件数を1つ増やし、上限以内なら true（超えていれば窓が終わるまでの時間を返す）
+	Allow(ctx context.Context, key string, limit Limit) (ok bool, retryAfter time.Duration, err error)
+	// Claim : key の印がまだなければ ttl の間付けて true を返す（ほかのインスタンスが付けていれば false）
+	// ttl が 0 以下なら印を付けずに true を返す
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// FromEnv : COORD_BACKEND（memory / redis、既定 memory）から作る
+func FromEnv(clk clock.Clock) (Store, error) {
+	switch kind := config.String("COORD_BACKEND", "memory"); kind {
+	case "memory":
+		return NewMemory(clk), nil
+	case "redis":
+		return NewRedis(config.String("REDIS_URL", "redis://localhost:6379/0"))
+	default:
+		return nil, fmt.Errorf("unknown COORD_BACKEND %q (use memory or redis)", kind)
+	}
+}
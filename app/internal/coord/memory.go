@@ -0,0 +1,88 @@
+package coord
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-logger/internal/clock"
+)
+
+// ==========================================
+// メモリ (インスタンスごと)
+// ==========================================
+
+// memorySweepEvery : 終わった窓・期限の過ぎた印を掃除する間隔（操作の回数）
+const memorySweepEvery = 1024
+
+// Memory : 窓ごとの件数と印をメモリに持つ
+type Memory struct {
+	clock clock.Clock
+
+	mu      sync.Mutex
+	windows map[string]memoryWindow
+	claims  map[string]time.Time // key → 期限
+	ops     int
+}
+
+// memoryWindow : 1つの key の今の窓
+type memoryWindow struct {
+	count int
+	ends  time.Time
+}
+
+// NewMemory : 空の状態
+func NewMemory(clk clock.Clock) *Memory {
+	return &Memory{clock: clk, windows: map[string]memoryWindow{}, claims: map[string]time.Time{}}
+}
+
+func (m *Memory) Name() string { return "memory" }
+
+func (m *Memory) Allow(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now()
+	m.sweep(now)
+	w := m.windows[key]
+	if !now.Before(w.ends) {
+		w = memoryWindow{ends: now.Add(limit.Window)}
+	}
+	w.count++
+	m.windows[key] = w
+	if w.count > limit.Count {
+		return false, w.ends.Sub(now), nil
+	}
+	return true, 0, nil
+}
+
+func (m *Memory) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return true, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now()
+	m.sweep(now)
+	if expires, ok := m.claims[key]; ok && now.Before(expires) {
+		return false, nil
+	}
+	m.claims[key] = now.Add(ttl)
+	return true, nil
+}
+
+// sweep : 一定の回数ごとに、終わった窓と期限の過ぎた印を消す（mu を持って呼ぶ）
+func (m *Memory) sweep(now time.Time) {
+	if m.ops++; m.ops%memorySweepEvery != 0 {
+		return
+	}
+	for key, w := range m.windows {
+		if !now.Before(w.ends) {
+			delete(m.windows, key)
+		}
+	}
+	for key, expires := range m.claims {
+		if !now.Before(expires) {
+			delete(m.claims, key)
+		}
+	}
+}
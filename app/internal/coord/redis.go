@@ -0,0 +1,56 @@
+package coord
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ==========================================
+// Redis (全てのインスタンスで共有する)
+// ==========================================
+// 件数は "go-logger:limit:<key>"、印は "go-logger:claim:<key>" に置き、期限で Redis が消す
+
+// redisAllowScript : 件数を増やし、窓の最初の1件なら期限を付ける（増やすと期限付けを1回の往復でまとめて行う）
+var redisAllowScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {n, redis.call("PTTL", KEYS[1])}
+`)
+
+// Redis : Redis に置く状態
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis : redis://[:password@]host:port/db の形式のURLで接続する（接続は最初の操作で確かめる）
+func NewRedis(url string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{client: redis.NewClient(opts)}, nil
+}
+
+func (r *Redis) Name() string { return "redis" }
+
+func (r *Redis) Allow(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	res, err := redisAllowScript.Run(ctx, r.client, []string{"go-logger:limit:" + key}, limit.Window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return true, 0, err
+	}
+	if res[0] > int64(limit.Count) {
+		return false, time.Duration(max(res[1], 0)) * time.Millisecond, nil
+	}
+	return true, 0, nil
+}
+
+func (r *Redis) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return true, nil
+	}
+	return r.client.SetNX(ctx, "go-logger:claim:"+key, time.Now().UTC().Format(time.RFC3339), ttl).Result()
+}
@@ -0,0 +1,108 @@
+// Package enrich : 保存前にアクセス記録へ情報を付け足すパイプライン (UA解析・GeoIP)
+package enrich
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mssola/useragent"
+	"github.com/oschwald/geoip2-golang"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+)
+
+// Enricher : パイプラインの1段
+type Enricher interface {
+	Name() string
+	Enrich(w *model.Write)
+}
+
+// Pipeline : 保存時・reindex時に順番に適用する
+type Pipeline []Enricher
+
+func (Pipeline) Name() string { return "pipeline" }
+
+// Enrich : 全てのエンリッチャーを適用する
+func (p Pipeline) Enrich(w *model.Write) {
+	for _, e := range p {
+		e.Enrich(w)
+	}
+}
+
+// FromEnv : UA解析は常に、GeoIPは GEOIP_DB_PATH があれば有効にする
+func FromEnv() Pipeline {
+	p := Pipeline{UserAgent{}}
+	if path := config.String("GEOIP_DB_PATH", ""); path != "" {
+		geo, err := NewGeoIP(path)
+		if err != nil {
+			fmt.Println("GeoIP disabled:", err)
+		} else {
+			p = append(p, geo)
+		}
+	}
+	return p
+}
+
+// UserAgent : UAからブラウザ・OS・端末種別・ボット判定を取り出す
+type UserAgent struct{}
+
+func (UserAgent) Name() string { return "user_agent" }
+
+func (UserAgent) Enrich(w *model.Write) {
+	if w.UserAgent == "" {
+		return
+	}
+	ua := useragent.New(w.UserAgent)
+	w.Browser, _ = ua.Browser()
+	w.OS = ua.OSInfo().Name
+	w.IsBot = ua.Bot()
+	switch {
+	case w.IsBot:
+		w.Device = "bot"
+	case ua.Mobile():
+		w.Device = "mobile"
+	default:
+		w.Device = "desktop"
+	}
+}
+
+// GeoIP : MaxMind形式 (GeoLite2-Country / City) のDBでIPから国コードを引く
+type GeoIP struct {
+	reader *geoip2.Reader
+}
+
+// NewGeoIP : mmdbファイルを開く
+func NewGeoIP(path string) (*GeoIP, error) {
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return &GeoIP{reader: reader}, nil
+}
+
+func (*GeoIP) Name() string { return "geoip" }
+
+func (g *GeoIP) Enrich(w *model.Write) {
+	ip := net.ParseIP(w.IP)
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() {
+		return
+	}
+	record, err := g.reader.Country(ip)
+	if err != nil {
+		return
+	}
+	w.Country = record.Country.IsoCode
+}
+
+// Describe : DBの種類と作成日（`main doctor` の表示用）
+func (g *GeoIP) Describe() string {
+	m := g.reader.Metadata()
+	return fmt.Sprintf("%s built %s", m.DatabaseType, time.Unix(int64(m.BuildEpoch), 0).UTC().Format("2006-01-02"))
+}
+
+// Close : mmdbファイルを閉じる
+func (g *GeoIP) Close() error {
+	return g.reader.Close()
+}
@@ -0,0 +1,6 @@
+//go:build !chaos
+
+package faults
+
+// Enabled : 通常のビルドでは障害を注入しない
+const Enabled = false
@@ -0,0 +1,6 @@
+//go:build chaos
+
+package faults
+
+// Enabled : chaos ビルドタグ付きでビルドした
+const Enabled = true
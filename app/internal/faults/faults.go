@@ -0,0 +1,85 @@
+// Package faults : 障害の注入 (DBの遅延・エラー、Webhookの失敗、バッファ溢れ)
+// スプールやリトライが実際に働くかを結合テストで確かめるためのもの
+// `go build -tags chaos` でビルドした時だけ有効になり、通常のビルドでは何もしない
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInjected : 注入した障害のエラー
+var ErrInjected = errors.New("injected fault")
+
+// Settings : 注入する障害（ゼロ値なら何もしない）
+type Settings struct {
+	DBLatencyMS   int     `json:"db_latency_ms"`  // DBへの書き込み・読み出しの前に待つミリ秒
+	DBErrorRate   float64 `json:"db_error_rate"`  // この割合 (0〜1) の書き込みをDB切断として扱う（バッファに入る）
+	WebhookStatus int     `json:"webhook_status"` // 0以外なら通知の送信をこのステータスで失敗させる
+	QueueFull     bool    `json:"queue_full"`     // 書き込みバッファを常に一杯として扱う
+}
+
+var current atomic.Pointer[Settings]
+
+// Current : 現在の設定
+func Current() Settings {
+	if s := current.Load(); s != nil {
+		return *s
+	}
+	return Settings{}
+}
+
+// Set : 設定を置き換える（無効なビルドではエラー）
+func Set(s Settings) error {
+	if !Enabled {
+		return fmt.Errorf("fault injection is not compiled in (build with -tags chaos)")
+	}
+	if s.DBErrorRate < 0 || s.DBErrorRate > 1 {
+		return fmt.Errorf("db_error_rate must be between 0 and 1")
+	}
+	if s.WebhookStatus != 0 && (s.WebhookStatus < 100 || s.WebhookStatus > 599) {
+		return fmt.Errorf("webhook_status must be an HTTP status code")
+	}
+	current.Store(&s)
+	return nil
+}
+
+// DB : DBを使う前に呼ぶ（遅延を入れ、エラーを注入するなら ErrInjected を返す）
+func DB(ctx context.Context) error {
+	if !Enabled {
+		return nil
+	}
+	s := Current()
+	if s.DBLatencyMS > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(s.DBLatencyMS) * time.Millisecond):
+		}
+	}
+	if s.DBErrorRate > 0 && rand.Float64() < s.DBErrorRate {
+		return fmt.Errorf("%w: database unavailable", ErrInjected)
+	}
+	return nil
+}
+
+// Webhook : 通知を送る前に呼ぶ（失敗させるならエラーを返す）
+func Webhook(host string) error {
+	if !Enabled {
+		return nil
+	}
+	if status := Current().WebhookStatus; status != 0 {
+		return fmt.Errorf("%w: %s returned %d %s", ErrInjected, host, status, http.StatusText(status))
+	}
+	return nil
+}
+
+// QueueFull : 書き込みバッファを一杯として扱うか
+func QueueFull() bool {
+	return Enabled && Current().QueueFull
+}
@@ -0,0 +1,103 @@
+// Package hll : ユニーク数の見積もり (HyperLogLog)
+// 値そのものを持たずに 4 KiB のレジスタだけで種類の数を見積もり、時間ごとのスケッチを合わせて長い期間の数を出す
+// 見積もりの標準誤差は約 1.6%（StandardError）
+package hll
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// Precision : レジスタの数を 2^Precision にする（大きいほど正確で、スケッチも大きくなる）
+const Precision = 12
+
+// registers : レジスタの数
+const registers = 1 << Precision
+
+// version : MarshalBinary の形式（先頭の1バイト）
+const version = 1
+
+// StandardError : 見積もりの標準誤差（相対値。1.04 / √レジスタ数）
+var StandardError = 1.04 / math.Sqrt(registers)
+
+// Sketch : 1つの集合のスケッチ（ゼロ値は使えないので New で作る）
+type Sketch struct {
+	regs []uint8
+}
+
+// New : 空のスケッチ
+func New() *Sketch {
+	return &Sketch{regs: make([]uint8, registers)}
+}
+
+// Add : 64ビットのハッシュ値を1つ加える（偏りのあるハッシュでもよいように混ぜ直す）
+func (s *Sketch) Add(hash uint64) {
+	h := mix(hash)
+	idx := h >> (64 - Precision)
+	rank := uint8(bits.LeadingZeros64(h<<Precision|1<<(Precision-1))) + 1
+	if rank > s.regs[idx] {
+		s.regs[idx] = rank
+	}
+}
+
+// AddString : 文字列を1つ加える
+func (s *Sketch) AddString(v string) {
+	h := fnv.New64a()
+	h.Write([]byte(v))
+	s.Add(h.Sum64())
+}
+
+// Merge : o の集合を合わせる（和集合のスケッチになる）
+func (s *Sketch) Merge(o *Sketch) {
+	for i, r := range o.regs {
+		if r > s.regs[i] {
+			s.regs[i] = r
+		}
+	}
+}
+
+// Estimate : 種類の数の見積もり（少ない間は空のレジスタの数から数える）
+func (s *Sketch) Estimate() int64 {
+	const m = float64(registers)
+	sum, zeros := 0.0, 0
+	for _, r := range s.regs {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	e := alpha * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(e))
+}
+
+// MarshalBinary : 保存用のバイト列（形式・精度・レジスタ）
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 2, 2+registers)
+	b[0], b[1] = version, Precision
+	return append(b, s.regs...), nil
+}
+
+// UnmarshalBinary : MarshalBinary のバイト列から戻す
+func (s *Sketch) UnmarshalBinary(b []byte) error {
+	if len(b) != 2+registers || b[0] != version || b[1] != Precision {
+		return errors.New("hll: unsupported sketch encoding")
+	}
+	s.regs = append(s.regs[:0], b[2:]...)
+	return nil
+}
+
+// mix : splitmix64 の仕上げ（下位のビットだけ変わるハッシュでもレジスタが偏らないようにする）
+func mix(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
@@ -0,0 +1,93 @@
+// Package i18n : APIが返すメッセージとダッシュボードの表示の翻訳 (?lang= か Accept-Language で英語・日本語を切り替える)
+package i18n
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/text/language"
+)
+
+// メッセージのキー
+const (
+	Logged             = "logged"
+	InvalidProjectKey  = "invalid_project_key"
+	InvalidRequest     = "invalid_request"
+	InvalidJSON        = "invalid_json"
+	Forbidden          = "forbidden"
+	MethodNotAllowed   = "method_not_allowed"
+	BodyTooLarge       = "body_too_large"
+	Timeout            = "timeout"
+	BatchTooLarge      = "batch_too_large"
+	InternalError      = "internal_error"
+	RateLimited        = "rate_limited"
+	DailyQuota         = "daily_quota"
+	MonthlyQuota       = "monthly_quota"
+	StorageUnavailable = "storage_unavailable"
+)
+
+// catalog : 言語ごとのメッセージ（fmt の書式。英語は必ず全てのキーを持つ）
+var catalog = map[language.Tag]map[string]string{
+	language.English: {
+		Logged:             "Logged successfully!",
+		InvalidProjectKey:  "Unauthorized: invalid or missing project key",
+		InvalidRequest:     "Invalid request: %v",
+		InvalidJSON:        "Invalid JSON: %v",
+		Forbidden:          "Forbidden",
+		MethodNotAllowed:   "method %s is not allowed (use %s)",
+		BodyTooLarge:       "request body exceeds %d bytes",
+		Timeout:            "request timed out after %s",
+		BatchTooLarge:      "batch has %d entries (max %d)",
+		InternalError:      "internal server error",
+		RateLimited:        "too many requests from this address (limit %s)",
+		DailyQuota:         "daily quota of %d requests for this key is used up",
+		MonthlyQuota:       "monthly quota of %d requests for this key is used up",
+		StorageUnavailable: "database is unavailable (%v); retry after %d seconds",
+	},
+	language.Japanese: {
+		Logged:             "記録しました",
+		InvalidProjectKey:  "認証エラー: プロジェクトキーがないか、正しくありません",
+		InvalidRequest:     "リクエストが正しくありません: %v",
+		InvalidJSON:        "JSONが正しくありません: %v",
+		Forbidden:          "このアドレスからの書き込みは許可されていません",
+		MethodNotAllowed:   "%s メソッドは使えません (%s を使ってください)",
+		BodyTooLarge:       "本文が %d バイトを超えています",
+		Timeout:            "%s 以内に処理が終わりませんでした",
+		BatchTooLarge:      "%d 件あります (1回に送れるのは %d 件まで)",
+		InternalError:      "サーバー内部でエラーが起きました",
+		RateLimited:        "この接続元からの書き込みが多すぎます (上限 %s)",
+		DailyQuota:         "このキーの1日の上限 (%d 件) に達しました",
+		MonthlyQuota:       "このキーの1か月の上限 (%d 件) に達しました",
+		StorageUnavailable: "データベースに繋がりません (%v)。%d 秒後にもう一度送ってください",
+	},
+}
+
+// Languages : 対応している言語（先頭が既定）
+var Languages = []language.Tag{language.English, language.Japanese}
+
+var matcher = language.NewMatcher(Languages)
+
+// Lang : ?lang= か Accept-Language から言語を選ぶ（?lang= を優先。対応していなければ英語）
+func Lang(r *http.Request) language.Tag {
+	_, i := language.MatchStrings(matcher, r.URL.Query().Get("lang"), r.Header.Get("Accept-Language"))
+	return Languages[i]
+}
+
+// Message : 言語を指定してメッセージを作る（訳がなければ英語）
+func Message(lang language.Tag, key string, args ...any) string {
+	format, ok := catalog[lang][key]
+	if !ok {
+		format = catalog[language.English][key]
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// T : リクエストの言語でメッセージを作り、Content-Language を付ける
+func T(w http.ResponseWriter, r *http.Request, key string, args ...any) string {
+	lang := Lang(r)
+	w.Header().Set("Content-Language", lang.String())
+	return Message(lang, key, args...)
+}
@@ -0,0 +1,181 @@
+package i18n
+
+import (
+	"fmt"
+	"maps"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// ==========================================
+// ダッシュボードの表示 (GET /api/i18n)
+// ==========================================
+// 画面の見出しやレベルの名前、時刻の書き方をサーバー側で言語ごとに持ち、
+// 日本語版と英語版のダッシュボードが同じAPIを使えるようにする（ブラウザ側で言語を判定しない）
+
+// labels : 言語ごとの画面の文言（英語は必ず全てのキーを持つ。%s などは画面側で埋める値）
+var labels = map[language.Tag]map[string]string{
+	language.English: {
+		"title":             "Access Dashboard",
+		"recent_logs":       "Recent Logs",
+		"top_referrers":     "Top Referrers (7d)",
+		"countries":         "Countries (7d)",
+		"top_pages":         "Top Pages (24h)",
+		"accesses_per_hour": "Accesses per hour",
+		"live_filter":       "Live filter (e.g. ua~curl level>=warn)",
+		"live":              "live",
+		"reconnecting":      "reconnecting…",
+		"direct":            "(direct)",
+		"none":              "(none)",
+		"col_id":            "ID",
+		"col_time":          "Time",
+		"col_ip":            "IP",
+		"col_country":       "Country",
+		"col_user_agent":    "User Agent",
+		"col_tags":          "Tags",
+		"edit_tags":         "Tags (comma separated)",
+		"sessions":          "Sessions (24h)",
+		"visitors":          "Visitors",
+		"avg_duration":      "Avg duration",
+		"bounces":           "Bounces",
+		"unique":            "Unique",
+		"returning":         "returning",
+		"latency":           "Latency",
+		"error_rate":        "Error rate",
+		"log_detail":        "Log #%s",
+		"headers":           "Headers",
+		"same_session":      "Same session",
+		"not_found":         "Log not found",
+	},
+	language.Japanese: {
+		"title":             "アクセス ダッシュボード",
+		"recent_logs":       "最近のログ",
+		"top_referrers":     "参照元の上位 (7日間)",
+		"countries":         "国別 (7日間)",
+		"top_pages":         "よく見られているページ (24時間)",
+		"accesses_per_hour": "1時間ごとのアクセス数",
+		"live_filter":       "新着の絞り込み (例: ua~curl level>=warn)",
+		"live":              "受信中",
+		"reconnecting":      "再接続しています…",
+		"direct":            "(直接)",
+		"none":              "(なし)",
+		"col_id":            "ID",
+		"col_time":          "日時",
+		"col_ip":            "IP",
+		"col_country":       "国",
+		"col_user_agent":    "ユーザーエージェント",
+		"col_tags":          "タグ",
+		"edit_tags":         "タグ (カンマ区切り)",
+		"sessions":          "セッション (24時間)",
+		"visitors":          "訪問者",
+		"avg_duration":      "平均の長さ",
+		"bounces":           "直帰",
+		"unique":            "ユニーク",
+		"returning":         "再訪問",
+		"latency":           "応答時間",
+		"error_rate":        "エラー率",
+		"log_detail":        "ログ #%s",
+		"headers":           "ヘッダー",
+		"same_session":      "同じセッション",
+		"not_found":         "ログが見つかりません",
+	},
+}
+
+// levelNames : レベルの表示名
+var levelNames = map[language.Tag]map[string]string{
+	language.English:  {"debug": "Debug", "info": "Info", "warn": "Warning", "error": "Error", "fatal": "Fatal"},
+	language.Japanese: {"debug": "デバッグ", "info": "情報", "warn": "警告", "error": "エラー", "fatal": "致命的"},
+}
+
+// Labels : 言語の画面の文言（訳のないキーは英語）
+func Labels(lang language.Tag) map[string]string {
+	out := maps.Clone(labels[language.English])
+	maps.Copy(out, labels[lang])
+	return out
+}
+
+// LevelNames : 言語のレベルの表示名（訳のないレベルは英語）
+func LevelNames(lang language.Tag) map[string]string {
+	out := maps.Clone(levelNames[language.English])
+	maps.Copy(out, levelNames[lang])
+	return out
+}
+
+// layouts : 言語ごとの時刻の書き方（time.Format の書式）
+var layouts = map[language.Tag]struct{ dateTime, date, clock string }{
+	language.English:  {"Jan 2, 2006 3:04:05 PM", "Jan 2, 2006", "3:04 PM"},
+	language.Japanese: {"2006年1月2日 15:04:05", "2006年1月2日", "15:04"},
+}
+
+// FormattedTime : 時刻1つの言語ごとの表記
+type FormattedTime struct {
+	Time     time.Time `json:"time"`
+	DateTime string    `json:"datetime"` // 日付と時刻
+	Date     string    `json:"date"`
+	Clock    string    `json:"clock"`    // 時刻だけ（グラフの軸など）
+	Relative string    `json:"relative"` // now を基準にした「3時間前」など
+}
+
+// FormatTime : t を loc の時刻にして言語の書き方で表す
+func FormatTime(lang language.Tag, t time.Time, loc *time.Location, now time.Time) FormattedTime {
+	l, ok := layouts[lang]
+	if !ok {
+		l = layouts[language.English]
+	}
+	t = t.In(loc)
+	return FormattedTime{
+		Time:     t,
+		DateTime: t.Format(l.dateTime),
+		Date:     t.Format(l.date),
+		Clock:    t.Format(l.clock),
+		Relative: relative(lang, now.Sub(t)),
+	}
+}
+
+// relative : 経過時間を「3 hours ago」「3時間前」の形にする（未来なら「in 3 hours」「3時間後」）
+func relative(lang language.Tag, d time.Duration) string {
+	future := d < 0
+	if future {
+		d = -d
+	}
+	n, unit := relativeUnit(d)
+	if lang == language.Japanese {
+		if unit == "" {
+			return "たった今"
+		}
+		ja := map[string]string{"minute": "分", "hour": "時間", "day": "日", "month": "か月", "year": "年"}[unit]
+		if future {
+			return fmt.Sprintf("%d%s後", n, ja)
+		}
+		return fmt.Sprintf("%d%s前", n, ja)
+	}
+	if unit == "" {
+		return "just now"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	if future {
+		return fmt.Sprintf("in %d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %s ago", n, unit)
+}
+
+// relativeUnit : 経過時間をいちばん大きい単位で切り捨てる（1分未満は単位なし）
+func relativeUnit(d time.Duration) (int, string) {
+	const day = 24 * time.Hour
+	switch {
+	case d < time.Minute:
+		return 0, ""
+	case d < time.Hour:
+		return int(d / time.Minute), "minute"
+	case d < day:
+		return int(d / time.Hour), "hour"
+	case d < 30*day:
+		return int(d / day), "day"
+	case d < 365*day:
+		return int(d / (30 * day)), "month"
+	}
+	return int(d / (365 * day)), "year"
+}
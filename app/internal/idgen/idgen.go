@@ -0,0 +1,70 @@
+// Package idgen : ログのID生成 (連番 / ULID / UUIDv7)
+package idgen
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+
+	"go-logger/internal/config"
+)
+
+// Generator : 書き込みごとに uid を発行する
+// 連番の id はインスタンスごとに重複するので、統合する場合は uid を使う
+type Generator interface {
+	Name() string
+	NewID(t time.Time) string // t の時刻順に並ぶ文字列（空なら uid を付けない）
+}
+
+// FromEnv : ID_STRATEGY=serial（既定）/ ulid / uuidv7
+func FromEnv() Generator {
+	return New(config.String("ID_STRATEGY", ""))
+}
+
+// New : 方式名から生成器を作る（未知の方式なら連番）
+func New(strategy string) Generator {
+	switch strategy {
+	case "", "serial":
+		return Serial{}
+	case "ulid":
+		return ULID{}
+	case "uuidv7", "uuid":
+		return UUIDv7{}
+	default:
+		fmt.Printf("Unknown ID_STRATEGY %q, using serial IDs\n", strategy)
+		return Serial{}
+	}
+}
+
+// Serial : 従来通り連番の id だけを使う
+type Serial struct{}
+
+func (Serial) Name() string           { return "serial" }
+func (Serial) NewID(time.Time) string { return "" }
+
+// ULID : 26文字のULID（同じミリ秒内でも単調増加）
+type ULID struct{}
+
+func (ULID) Name() string { return "ulid" }
+func (ULID) NewID(t time.Time) string {
+	return ulid.MustNew(ulid.Timestamp(t), ulid.DefaultEntropy()).String()
+}
+
+// UUIDv7 : 先頭48bitがミリ秒のUUIDv7
+type UUIDv7 struct{}
+
+func (UUIDv7) Name() string { return "uuidv7" }
+func (UUIDv7) NewID(t time.Time) string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return ""
+	}
+	// reindex で過去の行に付ける時も作成時刻順に並ぶよう、時刻部分を t にする
+	ms := uint64(t.UnixMilli())
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> (40 - 8*i))
+	}
+	return id.String()
+}
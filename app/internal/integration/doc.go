@@ -0,0 +1,8 @@
+// Package integration : サーバー全体を httptest.Server で動かす結合テスト（書き込み・読み出し・集計・通知）
+//
+// 既定ではメモリの保存先 (STORE_BACKEND=memory と同じ) で動かすので、go test ./... だけで確かめられる。
+// TEST_DATABASE_URL を設定すると同じテストを Postgres でも動かす（テストごとにスキーマを作って消す）
+//
+//	make test-integration            メモリと、コンテナで立てた Postgres の両方で動かす
+//	make test-integration-memory     メモリだけで動かす（Docker なし）
+package integration
@@ -0,0 +1,307 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/redact"
+)
+
+// ==========================================
+// 書き込みと読み出し
+// ==========================================
+
+// TestWriteAndRead : アクセス・ログ1件・まとめて送ったログを保存し、一覧と件数で読み戻す
+func TestWriteAndRead(t *testing.T) {
+	forEachBackend(t, nil, func(t *testing.T, h *harness) {
+		var access struct {
+			Status   int             `json:"status"`
+			DBStatus string          `json:"db_status"`
+			Entry    *model.LogEntry `json:"entry"`
+		}
+		decode(t, h.expect(t, http.StatusCreated, http.MethodGet, "/api/hello", "", "User-Agent", "integration-test"), &access)
+		if access.DBStatus != "OK" || access.Entry == nil || access.Entry.Path != "/api/hello" || access.Entry.EventType != model.EventAccess {
+			t.Fatalf("unexpected access response: %+v", access)
+		}
+
+		h.expect(t, http.StatusOK, http.MethodPost, "/api/logs", `{"level":"error","message":"boom","fields":{"order":42}}`)
+		var batch struct {
+			Accepted int `json:"accepted"`
+			Rejected int `json:"rejected"`
+		}
+		decode(t, h.expect(t, http.StatusOK, http.MethodPost, "/api/logs/batch", `[{"level":"info","message":"first"},{"level":"warn","message":"second"}]`), &batch)
+		if batch.Accepted != 2 || batch.Rejected != 0 {
+			t.Fatalf("batch: accepted %d, rejected %d", batch.Accepted, batch.Rejected)
+		}
+
+		var logs []model.LogEntry
+		h.getJSON(t, "/api/logs?type=log", &logs)
+		if len(logs) != 3 {
+			t.Fatalf("got %d logs, want 3", len(logs))
+		}
+		messages := map[string]string{}
+		for _, l := range logs {
+			messages[l.Message] = l.Level
+		}
+		for msg, level := range map[string]string{"boom": "error", "first": "info", "second": "warn"} {
+			if messages[msg] != level {
+				t.Errorf("log %q: level %q, want %q", msg, messages[msg], level)
+			}
+		}
+
+		h.getJSON(t, "/api/logs?level=error", &logs)
+		if len(logs) != 1 || logs[0].Message != "boom" || !bytes.Contains(logs[0].Fields, []byte(`"order":42`)) {
+			t.Fatalf("level=error: %+v", logs)
+		}
+
+		var count struct {
+			Count int `json:"count"`
+		}
+		h.getJSON(t, "/api/logs/count", &count)
+		if count.Count != 4 {
+			t.Fatalf("count %d, want 4", count.Count)
+		}
+	})
+}
+
+// TestInvalidWrites : 壊れた本文や知らないレベルは保存せず 400 を返す
+func TestInvalidWrites(t *testing.T) {
+	forEachBackend(t, nil, func(t *testing.T, h *harness) {
+		for _, body := range []string{`{"level":"error"`, `{"level":"loud","message":"x"}`} {
+			status, data := h.do(t, http.MethodPost, "/api/logs", body)
+			if status != http.StatusBadRequest {
+				t.Errorf("POST /api/logs %s: status %d, want 400: %s", body, status, data)
+			}
+			var apiErr struct {
+				Code string `json:"code"`
+			}
+			decode(t, data, &apiErr)
+			if apiErr.Code != "bad_request" {
+				t.Errorf("POST /api/logs %s: code %q, want bad_request", body, apiErr.Code)
+			}
+		}
+		var count struct {
+			Count int `json:"count"`
+		}
+		h.getJSON(t, "/api/logs/count", &count)
+		if count.Count != 0 {
+			t.Fatalf("count %d after rejected writes, want 0", count.Count)
+		}
+	})
+}
+
+// TestTrackAndPixel : /api/track と /pixel.gif の記録にサイト名とページが入る
+func TestTrackAndPixel(t *testing.T) {
+	forEachBackend(t, nil, func(t *testing.T, h *harness) {
+		h.expect(t, http.StatusCreated, http.MethodPost, "/api/track/Blog/posts/hello", "")
+
+		status, gif := h.do(t, http.MethodGet, "/pixel.gif?site=newsletter&path=/2026-10", "")
+		if status != http.StatusOK || !bytes.HasPrefix(gif, []byte("GIF89a")) {
+			t.Fatalf("pixel: status %d, body %q", status, gif)
+		}
+
+		var logs []model.LogEntry
+		h.getJSON(t, "/api/logs?type=pageview", &logs)
+		sites := map[string]string{}
+		for _, l := range logs {
+			var fields struct {
+				Site string `json:"site"`
+			}
+			json.Unmarshal(l.Fields, &fields)
+			sites[fields.Site] = l.Path
+		}
+		if sites["blog"] != "/posts/hello" || sites["newsletter"] != "/2026-10" {
+			t.Fatalf("pageviews by site: %v", sites)
+		}
+	})
+}
+
+// ==========================================
+// 集計
+// ==========================================
+
+// TestStats : /api/stats と /api/stats/compare が保存した件数と合う
+func TestStats(t *testing.T) {
+	forEachBackend(t, nil, func(t *testing.T, h *harness) {
+		for range 3 {
+			h.expect(t, http.StatusCreated, http.MethodGet, "/api/page", "")
+		}
+		h.expect(t, http.StatusOK, http.MethodPost, "/api/logs", `{"level":"error","message":"failed"}`)
+		h.expect(t, http.StatusOK, http.MethodPost, "/api/logs", `{"level":"info","message":"ok"}`)
+
+		var stats struct {
+			Total   int            `json:"total"`
+			ByType  map[string]int `json:"by_type"`
+			ByLevel map[string]int `json:"by_level"`
+		}
+		h.getJSON(t, "/api/stats", &stats)
+		if stats.Total != 5 || stats.ByType["access"] != 3 || stats.ByType["log"] != 2 || stats.ByLevel["error"] != 1 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+
+		var compare struct {
+			Hits   struct{ Current, Previous int } `json:"hits"`
+			Errors struct{ Current, Previous int } `json:"errors"`
+		}
+		h.getJSON(t, "/api/stats/compare?period=1h", &compare)
+		if compare.Hits.Current != 5 || compare.Hits.Previous != 0 || compare.Errors.Current != 1 {
+			t.Fatalf("unexpected comparison: %+v", compare)
+		}
+		h.getJSON(t, "/api/stats/compare?period=1h&type=access", &compare)
+		if compare.Hits.Current != 3 || compare.Errors.Current != 0 {
+			t.Fatalf("type=access comparison: %+v", compare)
+		}
+		if status, _ := h.do(t, http.MethodGet, "/api/stats/compare?period=soon", ""); status != http.StatusBadRequest {
+			t.Fatalf("bad period: status %d, want 400", status)
+		}
+	})
+}
+
+// ==========================================
+// 通知
+// ==========================================
+
+// TestNotifications : 既定のルール (log=error,*=info) で、アクセスと error のログだけが通知先へ届く
+func TestNotifications(t *testing.T) {
+	forEachBackend(t, nil, func(t *testing.T, h *harness) {
+		h.expect(t, http.StatusOK, http.MethodPost, "/api/logs", `{"level":"info","message":"quiet"}`)
+		h.expect(t, http.StatusCreated, http.MethodGet, "/api/notified", "")
+		h.expect(t, http.StatusOK, http.MethodPost, "/api/logs", `{"level":"error","message":"disk full"}`)
+
+		access := h.Notes.wait(t, "access", func(n notify.Notification) bool {
+			return n.Entry != nil && n.Entry.Path == "/api/notified"
+		})
+		if access.Entry.EventType != model.EventAccess {
+			t.Errorf("access notification event type %q", access.Entry.EventType)
+		}
+		failure := h.Notes.wait(t, "error log", func(n notify.Notification) bool {
+			return n.Entry != nil && n.Entry.Message == "disk full"
+		})
+		if failure.Level != "error" || !strings.Contains(failure.Text, "disk full") {
+			t.Errorf("error notification: level %q, text %q", failure.Level, failure.Text)
+		}
+		for _, n := range h.Notes.all() {
+			if n.Entry != nil && n.Entry.Message == "quiet" {
+				t.Errorf("info log was notified: %q", n.Text)
+			}
+		}
+	})
+}
+
+// TestNotifyRules : NOTIFY_LEVEL_RULES で種別ごとに通知を止められる
+func TestNotifyRules(t *testing.T) {
+	env := map[string]string{"NOTIFY_LEVEL_RULES": "access=off,log=warn"}
+	forEachBackend(t, env, func(t *testing.T, h *harness) {
+		h.expect(t, http.StatusCreated, http.MethodGet, "/api/silent", "")
+		h.expect(t, http.StatusOK, http.MethodPost, "/api/logs", `{"level":"warn","message":"slow query"}`)
+
+		h.Notes.wait(t, "warn log", func(n notify.Notification) bool {
+			return n.Entry != nil && n.Entry.Message == "slow query"
+		})
+		for _, n := range h.Notes.all() {
+			if n.Entry != nil && n.Entry.EventType == model.EventAccess {
+				t.Errorf("access was notified with access=off: %q", n.Text)
+			}
+		}
+	})
+}
+
+// ==========================================
+// キーとロール
+// ==========================================
+
+// TestAPIKeyRoles : viewer のキーは読めるが書けず、ingester のキーは書けるが読めない
+func TestAPIKeyRoles(t *testing.T) {
+	forEachBackend(t, nil, func(t *testing.T, h *harness) {
+		createKey := func(role string) string {
+			var key model.APIKey
+			decode(t, h.expect(t, http.StatusCreated, http.MethodPost, "/api/keys", `{"name":"`+role+`","roles":["`+role+`"]}`,
+				"Authorization", "Bearer "+adminToken), &key)
+			if key.Key == "" {
+				t.Fatalf("created %s key has no secret", role)
+			}
+			return key.Key
+		}
+		viewer, ingester := createKey("viewer"), createKey("ingester")
+
+		h.expect(t, http.StatusForbidden, http.MethodPost, "/api/logs", `{"level":"info","message":"denied"}`, "X-API-Key", viewer)
+		h.expect(t, http.StatusOK, http.MethodPost, "/api/logs", `{"level":"info","message":"allowed"}`, "X-API-Key", ingester)
+
+		var logs []model.LogEntry
+		h.getJSON(t, "/api/logs?type=log", &logs, "X-API-Key", viewer)
+		if len(logs) != 1 || logs[0].Message != "allowed" {
+			t.Fatalf("logs read with viewer key: %+v", logs)
+		}
+		h.expect(t, http.StatusForbidden, http.MethodGet, "/api/logs", "", "X-API-Key", ingester)
+	})
+}
+
+// ==========================================
+// 項目の表示ルール
+// ==========================================
+
+// TestRedaction : REDACT_RULES の項目は、一覧・検索の抜粋・通知・Kafka / NATS のどの出力でも範囲に応じて隠す
+func TestRedaction(t *testing.T) {
+	env := map[string]string{
+		"REDACT_RULES":        "ip=user,user_agent=admin,fields.email=admin",
+		"REDACT_EXPORT_SCOPE": "key",
+	}
+	forEachBackend(t, env, func(t *testing.T, h *harness) {
+		const agent = "SecretAgent/1.0"
+		h.expect(t, http.StatusCreated, http.MethodGet, "/api/checkout", "", "User-Agent", agent)
+		h.expect(t, http.StatusOK, http.MethodPost, "/api/logs", `{"level":"error","message":"payment failed","fields":{"email":"a@example.com","order":42}}`,
+			"User-Agent", agent)
+
+		// 認証なしの一覧
+		var logs []model.LogEntry
+		h.getJSON(t, "/api/logs", &logs)
+		for _, l := range logs {
+			if l.IP != redact.Placeholder || l.UserAgent != redact.Placeholder {
+				t.Errorf("public list: ip %q, user_agent %q", l.IP, l.UserAgent)
+			}
+			if bytes.Contains(l.Fields, []byte("a@example.com")) {
+				t.Errorf("public list: fields %s", l.Fields)
+			}
+		}
+
+		// 検索: User-Agent が見えない範囲には抜粋も返さない
+		var results []model.SearchResult
+		h.getJSON(t, "/api/logs/search?q=SecretAgent", &results)
+		if len(results) == 0 {
+			t.Fatal("search returned nothing")
+		}
+		for _, r := range results {
+			if r.UserAgent != redact.Placeholder || r.Highlight != "" {
+				t.Errorf("public search: user_agent %q, highlight %q", r.UserAgent, r.Highlight)
+			}
+		}
+		h.getJSON(t, "/api/logs/search?q=SecretAgent", &results, "Authorization", "Bearer "+adminToken)
+		if len(results) == 0 || !strings.Contains(results[0].Highlight, "SecretAgent") || results[0].UserAgent != agent {
+			t.Errorf("admin search: %+v", results)
+		}
+
+		// 通知は REDACT_NOTIFY_SCOPE (既定 user) の範囲
+		n := h.Notes.wait(t, "error log", func(n notify.Notification) bool {
+			return n.Entry != nil && n.Entry.Message == "payment failed"
+		})
+		if n.Entry.UserAgent != redact.Placeholder || n.Entry.IP == redact.Placeholder || bytes.Contains(n.Entry.Fields, []byte("a@example.com")) {
+			t.Errorf("notification entry: ip %q, user_agent %q, fields %s", n.Entry.IP, n.Entry.UserAgent, n.Entry.Fields)
+		}
+
+		// Kafka / NATS は REDACT_EXPORT_SCOPE の範囲
+		published := h.Published.all()
+		if len(published) != 2 {
+			t.Fatalf("published %d entries, want 2", len(published))
+		}
+		for _, e := range published {
+			if e.IP != redact.Placeholder || e.UserAgent != redact.Placeholder {
+				t.Errorf("published entry: ip %q, user_agent %q", e.IP, e.UserAgent)
+			}
+		}
+	})
+}
@@ -0,0 +1,297 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/redact"
+	"go-logger/internal/server"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// テスト用のサーバー
+// ==========================================
+// main の runServer と同じ順に組み立て、通知先と Kafka / NATS の送り先だけ送ったものを溜めるものに差し替える
+
+// adminToken : テストのサーバーの ADMIN_TOKEN
+const adminToken = "integration-admin-token"
+
+// waitTimeout : 非同期の処理（通知の送信など）を待つ上限
+const waitTimeout = 5 * time.Second
+
+// harness : 動いているサーバー1つ
+type harness struct {
+	URL       string
+	Store     store.Store
+	Notes     *recorder
+	Published *publisher // Kafka / NATS へ流したログ
+}
+
+// backends : テストを動かす保存先（postgres は TEST_DATABASE_URL を設定した時だけ）
+var backends = []string{"memory", "postgres"}
+
+// forEachBackend : 保存先ごとにサーバーを立て、サブテストとして fn を動かす
+// env はサーバーを作る前に設定する環境変数（ConfigFromEnv で読む）
+func forEachBackend(t *testing.T, env map[string]string, fn func(t *testing.T, h *harness)) {
+	t.Helper()
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			fn(t, newHarness(t, backend, env))
+		})
+	}
+}
+
+// newHarness : 保存先を用意してサーバーを起動する（テストの終わりに止めて片付ける）
+func newHarness(t *testing.T, backend string, env map[string]string) *harness {
+	t.Helper()
+	t.Setenv("ADMIN_TOKEN", adminToken)
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+
+	clk := clock.System{}
+	h := &harness{Notes: &recorder{}, Published: &publisher{}}
+	var mem *store.Memory
+	var db *store.Postgres
+	switch backend {
+	case "memory":
+		mem = store.NewMemory(clk, store.DefaultMemoryMaxLogs)
+		h.Store = mem
+	case "postgres":
+		db = openPostgres(t, clk)
+		h.Store = db
+	default:
+		t.Fatalf("unknown backend %q", backend)
+	}
+
+	redaction, err := redact.FromEnv()
+	if err != nil {
+		t.Fatalf("redaction rules: %v", err)
+	}
+	srv := server.New(server.ConfigFromEnv(), server.Deps{
+		Store:    h.Store,
+		Notifier: h.Notes,
+		Clock:    clk,
+		Stream:   h.Published,
+		Redact:   redaction,
+	})
+	if db != nil {
+		db.OnInsert = srv.Publish
+	} else {
+		mem.OnInsert = srv.Publish
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	srv.Run(ctx)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		cancel()
+	})
+	h.URL = ts.URL
+	return h
+}
+
+// openPostgres : TEST_DATABASE_URL のDBにこのテストだけのスキーマを作り、マイグレーションを適用する
+// 未設定ならテストを飛ばす。スキーマはテストの終わりに消す
+func openPostgres(t *testing.T, clk clock.Clock) *store.Postgres {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open TEST_DATABASE_URL: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := "it_" + randomHex(t, 6)
+	if _, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	db := store.NewPostgres(withSearchPath(dsn, schema), clk)
+	if err := db.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.EnsureIndexedFields(ctx, store.IndexedFieldsFromEnv()); err != nil {
+		t.Fatalf("indexed fields: %v", err)
+	}
+	return db
+}
+
+// withSearchPath : 接続文字列に search_path を足す（URL の形と key=value の形のどちらでも）
+func withSearchPath(dsn, schema string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + schema
+	}
+	return dsn + "?search_path=" + schema
+}
+
+// randomHex : n バイトの乱数の16進
+func randomHex(t *testing.T, n int) string {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// ==========================================
+// リクエストの送信
+// ==========================================
+
+// do : リクエストを送り、状態コードと本文を返す（headers は "Key", "Value" の順に並べる）
+func (h *harness) do(t *testing.T, method, path, body string, headers ...string) (int, []byte) {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, h.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("%s %s: read body: %v", method, path, err)
+	}
+	return res.StatusCode, data
+}
+
+// expect : 状態コードが want でなければテストを止める
+func (h *harness) expect(t *testing.T, want int, method, path, body string, headers ...string) []byte {
+	t.Helper()
+	status, data := h.do(t, method, path, body, headers...)
+	if status != want {
+		t.Fatalf("%s %s: status %d, want %d: %s", method, path, status, want, bytes.TrimSpace(data))
+	}
+	return data
+}
+
+// getJSON : GET して 200 の本文を v に読む
+func (h *harness) getJSON(t *testing.T, path string, v any, headers ...string) {
+	t.Helper()
+	decode(t, h.expect(t, http.StatusOK, http.MethodGet, path, "", headers...), v)
+}
+
+// decode : JSON を v に読む
+func decode(t *testing.T, data []byte, v any) {
+	t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("decode %s: %v", bytes.TrimSpace(data), err)
+	}
+}
+
+// ==========================================
+// 通知の記録
+// ==========================================
+
+// recorder : 送られた通知を溜める通知先
+type recorder struct {
+	mu    sync.Mutex
+	notes []notify.Notification
+}
+
+func (r *recorder) Name() string { return "recorder" }
+
+func (r *recorder) Notify(ctx context.Context, n notify.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notes = append(r.notes, n)
+	return nil
+}
+
+// all : これまでに送られた通知
+func (r *recorder) all() []notify.Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]notify.Notification(nil), r.notes...)
+}
+
+// wait : match に合う通知が届くまで待つ（waitTimeout を過ぎたらテストを止める）
+func (r *recorder) wait(t *testing.T, what string, match func(notify.Notification) bool) notify.Notification {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		for _, n := range r.all() {
+			if match(n) {
+				return n
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no notification for %s within %s (got %d)", what, waitTimeout, len(r.all()))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// ==========================================
+// 流したログの記録
+// ==========================================
+
+// publisher : Kafka / NATS の代わりに、流したログを溜める送り先
+type publisher struct {
+	mu      sync.Mutex
+	entries []model.LogEntry
+}
+
+func (p *publisher) Name() string { return "recorder" }
+
+func (p *publisher) Publish(ctx context.Context, e *model.LogEntry) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries = append(p.entries, *e)
+	return nil
+}
+
+func (p *publisher) Close() error { return nil }
+
+// all : これまでに流したログ
+func (p *publisher) all() []model.LogEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]model.LogEntry(nil), p.entries...)
+}
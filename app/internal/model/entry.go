@@ -0,0 +1,159 @@
+// Package model : パッケージ間で受け渡すデータの型
+package model
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"time"
+)
+
+// LogEntry : 読み出し用（DBのテーブル構造に合わせる）
+type LogEntry struct {
+	ID         int               `json:"id"`
+	UID        string            `json:"uid,omitempty"`
+	Instance   string            `json:"instance,omitempty"` // 横断検索 (?federate=true) の時だけ、どのインスタンスのログか
+	ProjectID  int               `json:"project_id"`
+	UserAgent  string            `json:"user_agent"`
+	IP         string            `json:"ip,omitempty"`
+	VisitorID  string            `json:"visitor_id,omitempty"` // VISITOR_COOKIE=true の時の匿名の訪問者ID
+	Country    string            `json:"country,omitempty"`
+	Path       string            `json:"path"`
+	Referrer   string            `json:"referrer"`
+	EventType  string            `json:"event_type"`
+	Level      string            `json:"level,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	Fields     json.RawMessage   `json:"fields,omitempty"`
+	Browser    string            `json:"browser,omitempty"`
+	OS         string            `json:"os,omitempty"`
+	Device     string            `json:"device,omitempty"`
+	IsBot      bool              `json:"is_bot"`
+	CreatedAt  time.Time         `json:"created_at"`
+	ExpiresAt  *time.Time        `json:"expires_at,omitempty"`
+	HitCount   int               `json:"hit_count"`             // COLLAPSE_WINDOW で同じアクセスをまとめた回数（まとめていなければ1）
+	LastHitAt  *time.Time        `json:"last_hit_at,omitempty"` // まとめた最後のアクセスの時刻
+	SampleRate float64           `json:"sample_rate"`           // SAMPLE_RATE で間引いて保存した時の抽出率（間引いていなければ1）
+	Tags       []string          `json:"tags,omitempty"`        // PATCH /api/logs/{id} で付けた仕分けのタグ
+	Note       string            `json:"note,omitempty"`        // 同じく仕分けのメモ
+	DeletedAt  *time.Time        `json:"deleted_at,omitempty"`  // DELETE /api/logs/{id} でゴミ箱に移した時刻
+	Status     int               `json:"status,omitempty"`      // 記録したリクエストの応答ステータス（呼び出し側が報告した時だけ）
+	DurationMS *float64          `json:"duration_ms,omitempty"` // 同じく処理にかかった時間（ミリ秒）
+	Headers    map[string]string `json:"headers,omitempty"`     // CAPTURE_HEADERS で記録したリクエストヘッダー（正規化した名前 → 値）
+}
+
+// Write : 保存するアクセス記録（DB復旧待ちの間はバッファに積まれる）
+type Write struct {
+	ID         int    // 保存後に採番される（reindex時は既存のID）
+	UID        string // ID_STRATEGY で発行（serial なら空）
+	ProjectID  int
+	UserAgent  string
+	IP         string
+	VisitorID  string // 訪問者クッキーのID（VISITOR_COOKIE=true の時だけ）
+	Path       string
+	Referrer   string
+	EventType  string
+	Level      string
+	Message    string
+	Fields     []byte // JSONオブジェクト (nilならNULL)
+	CreatedAt  time.Time
+	ExpiresAt  time.Time // ゼロなら全体の保存期間に従う
+	HitCount   int       // まとめたアクセスの回数（reindex で引き継ぐ。0 なら1）
+	LastHitAt  time.Time
+	SampleRate float64           // SAMPLE_RATE の抽出率（0 なら1。間引いていない）
+	Tags       []string          // 保存する時に付けるタグ（監視リストに一致したアクセスの flagged など）
+	Status     int               // 記録したリクエストの応答ステータス（0 なら報告なし）
+	DurationMS *float64          // 同じく処理時間のミリ秒（nil なら報告なし）
+	Headers    map[string]string // CAPTURE_HEADERS で記録するリクエストヘッダー（nil なら記録しない）
+
+	// エンリッチメントで埋まる項目
+	Browser string
+	OS      string
+	Device  string
+	IsBot   bool
+	Country string
+}
+
+// Entry : 保存した内容を読み出し用の形にする（通知などで使う）
+func (w *Write) Entry() *LogEntry {
+	e := &LogEntry{
+		ID:         w.ID,
+		UID:        w.UID,
+		ProjectID:  w.ProjectID,
+		UserAgent:  w.UserAgent,
+		IP:         w.IP,
+		VisitorID:  w.VisitorID,
+		Country:    w.Country,
+		Path:       w.Path,
+		Referrer:   w.Referrer,
+		EventType:  w.EventType,
+		Level:      w.Level,
+		Message:    w.Message,
+		Fields:     w.Fields,
+		Browser:    w.Browser,
+		OS:         w.OS,
+		Device:     w.Device,
+		IsBot:      w.IsBot,
+		CreatedAt:  w.CreatedAt,
+		HitCount:   max(w.HitCount, 1),
+		SampleRate: w.Rate(),
+		Tags:       slices.Clone(w.Tags),
+		Status:     w.Status,
+		DurationMS: w.DurationMS,
+		Headers:    maps.Clone(w.Headers),
+	}
+	if !w.LastHitAt.IsZero() {
+		lastHitAt := w.LastHitAt
+		e.LastHitAt = &lastHitAt
+	}
+	if !w.ExpiresAt.IsZero() {
+		expiresAt := w.ExpiresAt
+		e.ExpiresAt = &expiresAt
+	}
+	return e
+}
+
+// Rate : 保存する抽出率（未設定なら1）
+func (w *Write) Rate() float64 {
+	if w.SampleRate <= 0 || w.SampleRate > 1 {
+		return 1
+	}
+	return w.SampleRate
+}
+
+// WriteFromEntry : 読み出した LogEntry をエンリッチ前の書き込みに戻す（reindex用）
+func WriteFromEntry(l LogEntry) Write {
+	w := Write{
+		ID:         l.ID,
+		UID:        l.UID,
+		ProjectID:  l.ProjectID,
+		UserAgent:  l.UserAgent,
+		IP:         l.IP,
+		VisitorID:  l.VisitorID,
+		Path:       l.Path,
+		Referrer:   l.Referrer,
+		EventType:  l.EventType,
+		Level:      l.Level,
+		Message:    l.Message,
+		Fields:     l.Fields,
+		CreatedAt:  l.CreatedAt,
+		HitCount:   l.HitCount,
+		SampleRate: l.SampleRate,
+		Status:     l.Status,
+		DurationMS: l.DurationMS,
+		Headers:    l.Headers,
+	}
+	if l.ExpiresAt != nil {
+		w.ExpiresAt = *l.ExpiresAt
+	}
+	if l.LastHitAt != nil {
+		w.LastHitAt = *l.LastHitAt
+	}
+	return w
+}
+
+// SearchResult : 全文検索の結果1件（一致度と一致箇所を強調した抜粋付き）
+type SearchResult struct {
+	LogEntry
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ==========================================
+// イベント種別
+// ==========================================
+// 書き込みの時に event_type で種別を指定できる（会員登録・購入などもアクセスと同じ経路で記録する）
+// 決まった種別のほかは "custom:<名前>" の形で自由に作れる。絞り込み・ルールでは "custom:*" で全ての独自の種別に合う
+
+// 書き込みで指定できる決まった種別
+const (
+	EventAccess   = "access"
+	EventPageview = "pageview"
+	EventError    = "error"
+)
+
+// CustomEventPrefix : 独自の種別の接頭辞
+const CustomEventPrefix = "custom:"
+
+// BuiltinEventTypes : 書き込みで指定できる決まった種別
+var BuiltinEventTypes = []string{EventAccess, EventPageview, EventError}
+
+// customEventName : "custom:" の後ろの名前（英小文字・数字・"_" "-" "."、64文字まで）
+var customEventName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// NormalizeEventType : 書き込みで指定された種別の表記を揃え、未知の種別はエラーにする（空ならそのまま空を返す）
+func NormalizeEventType(eventType string) (string, error) {
+	eventType = strings.ToLower(strings.TrimSpace(eventType))
+	if eventType == "" || slices.Contains(BuiltinEventTypes, eventType) {
+		return eventType, nil
+	}
+	if name, ok := strings.CutPrefix(eventType, CustomEventPrefix); ok && customEventName.MatchString(name) {
+		return eventType, nil
+	}
+	return "", fmt.Errorf("unknown event_type %q (use %s or %s<name> with a-z, 0-9, _ . -)", eventType, strings.Join(BuiltinEventTypes, ", "), CustomEventPrefix)
+}
+
+// EventTypePrefix : 末尾が "*" の絞り込み ("custom:*" など) なら前方一致の接頭辞を返す
+func EventTypePrefix(pattern string) (string, bool) {
+	return strings.CutSuffix(pattern, "*")
+}
+
+// EventTypeMatches : 種別が絞り込みに合うか（空の絞り込みは全てに合う）
+func EventTypeMatches(pattern, eventType string) bool {
+	if pattern == "" {
+		return true
+	}
+	if prefix, ok := EventTypePrefix(pattern); ok {
+		return strings.HasPrefix(eventType, prefix)
+	}
+	return pattern == eventType
+}
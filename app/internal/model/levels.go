@@ -0,0 +1,59 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ==========================================
+// ログレベル
+// ==========================================
+
+// LevelOrder : 低い順に並べたレベル
+var LevelOrder = []string{"debug", "info", "warn", "error", "fatal"}
+
+// levelAliases : よくある別表記
+var levelAliases = map[string]string{
+	"trace":    "debug",
+	"notice":   "info",
+	"warning":  "warn",
+	"err":      "error",
+	"critical": "fatal",
+	"panic":    "fatal",
+}
+
+// DefaultLevel : level を省略した時の値
+const DefaultLevel = "info"
+
+// NormalizeLevel : 表記を揃え、未知のレベルはエラーにする
+func NormalizeLevel(level string) (string, error) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if level == "" {
+		return DefaultLevel, nil
+	}
+	if alias, ok := levelAliases[level]; ok {
+		level = alias
+	}
+	if LevelRank(level) < 0 {
+		return "", fmt.Errorf("unknown level %q (use %s)", level, strings.Join(LevelOrder, ", "))
+	}
+	return level, nil
+}
+
+// LevelRank : レベルの順位（未知なら -1）
+func LevelRank(level string) int {
+	for i, l := range LevelOrder {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// LevelsAtLeast : min 以上のレベル一覧（?level=warn の絞り込み用）
+func LevelsAtLeast(min string) []string {
+	if i := LevelRank(min); i >= 0 {
+		return LevelOrder[i:]
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Project : ログを書き込むサイト1つ分
+type Project struct {
+	ID        int            `json:"id"`
+	Name      string         `json:"name"`
+	APIKey    string         `json:"api_key,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	Privacy   ProjectPrivacy `json:"privacy"`
+	Quota     ProjectQuota   `json:"quota"`
+}
+
+// ProjectQuota : キーで受け付けるリクエスト数の上限（UTC の日・月ごと。0 なら制限しない）
+type ProjectQuota struct {
+	Daily   int64 `json:"daily"`
+	Monthly int64 `json:"monthly"`
+}
+
+// KeyUsage : キーのある日（UTC）のリクエスト数
+type KeyUsage struct {
+	ProjectID int       `json:"project_id"`
+	Day       time.Time `json:"day"`
+	Requests  int64     `json:"requests"` // 受け付けた数
+	Rejected  int64     `json:"rejected"` // 上限を超えて断った数
+}
+
+// Setting : 実行中に変えられる設定1つ（環境変数の値を上書きしたもの）
+type Setting struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RetentionTier : プロジェクト・イベント種別ごとの保存日数（/api/settings の retention_tiers）
+// 当てはまるものが複数あれば、プロジェクトの指定 → 種別の完全一致 → 前方一致 ("custom:*") の順に具体的なものを使う
+type RetentionTier struct {
+	ProjectID int    `json:"project_id,omitempty"` // 0 なら全てのプロジェクト
+	EventType string `json:"event_type,omitempty"` // 空なら全種別
+	Days      int    `json:"days"`                 // 0 なら削除しない
+}
+
+// ProjectPrivacy : 保存する前に IP・UA を加工するか（nil ならサーバーの既定に従う）
+type ProjectPrivacy struct {
+	AnonymizeIP   *bool `json:"anonymize_ip"`    // IPv4 は最後のオクテット、IPv6 は下位80ビットを0にする
+	HashUserAgent *bool `json:"hash_user_agent"` // UA を定期的に変わるソルトでハッシュにする
+}
+
+// DefaultProjectID : キーなしのリクエストが属するプロジェクト
+const DefaultProjectID = 1
+
+// ShortLink : /l/{slug} → TargetURL
+type ShortLink struct {
+	ID        int       `json:"id"`
+	ProjectID int       `json:"project_id"`
+	Slug      string    `json:"slug"`
+	TargetURL string    `json:"target_url"`
+	Clicks    int       `json:"clicks"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CheckResult : 合成監視1回分の結果
+type CheckResult struct {
+	ID        int       `json:"id"`
+	Check     string    `json:"check"`
+	Status    string    `json:"status"` // "up" または "down"
+	LatencyMS int       `json:"latency_ms"`
+	Region    string    `json:"region"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Channel : DBで管理する通知先1つ分
+type Channel struct {
+	ID        int               `json:"id"`
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`               // discord / slack / telegram / email / webhook（notify.DefaultRegistry に登録された種類）
+	URL       string            `json:"url,omitempty"`      // Discord・Slack・webhook の URL
+	Token     string            `json:"token,omitempty"`    // Telegram の Botトークン
+	ChatID    string            `json:"chat_id,omitempty"`  // Telegram の送信先チャット
+	Settings  map[string]string `json:"settings,omitempty"` // 種類ごとのその他の設定（email の host / to、webhook の secret、min_level など）
+	Enabled   bool              `json:"enabled"`
+	Rules     string            `json:"rules,omitempty"`    // NOTIFY_LEVEL_RULES と同じ形式（空なら全体のルールのみ）
+	Template  string            `json:"template,omitempty"` // 本文の Go テンプレート（{{.UserAgent}} など。空なら NOTIFY_TEMPLATE か既定の本文）
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Webhook : 新しいログを送る外部のURL1つ分
+type Webhook struct {
+	ID             int        `json:"id"`
+	Name           string     `json:"name"`
+	ProjectID      int        `json:"project_id,omitempty"` // 0 なら全てのプロジェクト
+	URL            string     `json:"url"`
+	Secret         string     `json:"secret,omitempty"`      // 署名の鍵（作成時の応答でだけ全体を返す）
+	EventTypes     []string   `json:"event_types,omitempty"` // 空なら全種別
+	MinLevel       string     `json:"min_level,omitempty"`
+	Enabled        bool       `json:"enabled"`
+	Failures       int        `json:"failures"` // 続けて届かなかった回数（届けば0に戻る）
+	LastError      string     `json:"last_error,omitempty"`
+	LastDeliveryAt *time.Time `json:"last_delivery_at,omitempty"`
+	DisabledAt     *time.Time `json:"disabled_at,omitempty"` // 失敗が続いて自動で止めた時刻
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Alert : 発生したアラート1件分（通知した内容の履歴）
+type Alert struct {
+	ID             int        `json:"id"`
+	Source         string     `json:"source"` // uptime / volume / rule / ログのイベント種別など
+	Key            string     `json:"key"`    // 同じ種類のアラートをまとめるキー（ミュートの単位）
+	Level          string     `json:"level"`
+	Title          string     `json:"title,omitempty"`
+	Text           string     `json:"text"`
+	EntryID        int        `json:"entry_id,omitempty"` // 元になったログ（なければ0）
+	FiredAt        time.Time  `json:"fired_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+}
+
+// AlertRule : DBで管理するアラートルール1つ分
+type AlertRule struct {
+	ID              int        `json:"id"`
+	Name            string     `json:"name"`
+	Kind            string     `json:"kind"` // threshold / match / heartbeat
+	ProjectID       int        `json:"project_id"`
+	EventType       string     `json:"event_type,omitempty"` // 空なら全種別
+	MinLevel        string     `json:"min_level,omitempty"`
+	Field           string     `json:"field,omitempty"`   // match: user_agent / path / message など
+	Pattern         string     `json:"pattern,omitempty"` // match: 正規表現
+	Query           string     `json:"query,omitempty"`   // 対象のログの検索式（store.ParseQuery。空なら event_type / min_level だけで絞る）
+	Threshold       int        `json:"threshold,omitempty"`
+	WindowSeconds   int        `json:"window_seconds,omitempty"`
+	CooldownSeconds int        `json:"cooldown_seconds"` // 一度通知したら、この秒数は再通知しない
+	Level           string     `json:"level"`            // 通知のレベル
+	Enabled         bool       `json:"enabled"`
+	LastFiredAt     *time.Time `json:"last_fired_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// Exclusion : 記録・通知から除外するアクセスのパターン
+type Exclusion struct {
+	ID        int       `json:"id"`
+	Field     string    `json:"field"`   // user_agent / ip / path
+	Pattern   string    `json:"pattern"` // 正規表現
+	Scope     string    `json:"scope"`   // all（保存も通知もしない）/ notify（通知だけしない）
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Watch : 監視リストの1件（一致したアクセスに flagged のタグを付け、優先度を上げて通知する）
+type Watch struct {
+	ID        int       `json:"id"`
+	Field     string    `json:"field"`   // ip / user_agent
+	Pattern   string    `json:"pattern"` // ip は IP アドレスか CIDR、user_agent は正規表現
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotifyRoute : ログの通知をどの通知先へ送るか（position の順に当て、最初に合ったものに従う）
+type NotifyRoute struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`           // 小さいものから当てる
+	Match     string    `json:"match"`              // ?query= と同じ検索式（空なら全て）
+	Action    string    `json:"action"`             // send（Channels へ送る）/ suppress（送らない）
+	Channels  []string  `json:"channels,omitempty"` // 通知先の名前（discord / slack / telegram / email、DBの通知先は slack:alerts の形）
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IPRule : 書き込みを受け付ける・拒否するアドレス範囲
+type IPRule struct {
+	ID        int       `json:"id"`
+	CIDR      string    `json:"cidr"`   // "203.0.113.0/24" や "2001:db8::/32"（単一のアドレスも可）
+	Action    string    `json:"action"` // allow / deny
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeadLetter : 再送しても処理できなかったキューの中身
+type DeadLetter struct {
+	ID        int             `json:"id"`
+	Queue     string          `json:"queue"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ExportJob の状態
+const (
+	ExportQueued  = "queued"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+// ExportJob : 非同期の書き出し (POST /api/exports)。ワーカーがファイルに書き、終わったら GET /api/exports/{id} から取り出せる
+type ExportJob struct {
+	ID         int               `json:"id"`
+	ProjectID  int               `json:"project_id"`
+	Format     string            `json:"format"`  // ndjson / parquet
+	Filters    map[string]string `json:"filters"` // GET /api/logs と同じ絞り込み（期間は作った時に from / to の時刻に直す）
+	Status     string            `json:"status"`  // queued / running / done / failed
+	Rows       int64             `json:"rows"`
+	Bytes      int64             `json:"bytes"`
+	Location   string            `json:"location,omitempty"` // disk（EXPORT_DIR）/ s3（EXPORT_S3_BUCKET）
+	Object     string            `json:"-"`                  // ファイルのパスかバケットのキー
+	Error      string            `json:"error,omitempty"`
+	Instance   string            `json:"instance,omitempty"` // 書き出したインスタンス
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time        `json:"expires_at,omitempty"` // この時刻を過ぎたらファイルと記録を消す
+}
+
+// PrivacyRequest : 本人からの開示・削除の依頼を処理した記録
+type PrivacyRequest struct {
+	ID          int       `json:"id"`
+	Action      string    `json:"action"`               // export / delete
+	SubjectHash string    `json:"subject_hash"`         // 識別子 ("ip:203.0.113.7" など) の SHA-256
+	ProjectID   int       `json:"project_id,omitempty"` // 0 なら全てのプロジェクト
+	Rows        int64     `json:"rows"`                 // 返した・削除した行数
+	Reason      string    `json:"reason,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+}
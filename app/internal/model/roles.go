@@ -0,0 +1,61 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// ロール (APIキー・ダッシュボードのユーザーに付ける)
+// ==========================================
+// viewer はログの読み出しと画面、ingester は書き込み、admin は管理API（削除・通知先の管理を含む）の全て
+// 1つのキー・ユーザーに複数付けられる（viewer と ingester の両方など）
+
+// ロールの名前
+const (
+	RoleViewer   = "viewer"
+	RoleIngester = "ingester"
+	RoleAdmin    = "admin"
+)
+
+// Roles : 付けられるロール
+var Roles = []string{RoleViewer, RoleIngester, RoleAdmin}
+
+// NormalizeRoles : ロールの表記を揃えて重複を除き、Roles の順に並べる（未知のロール・空の一覧はエラー）
+func NormalizeRoles(roles []string) ([]string, error) {
+	var out []string
+	for _, r := range roles {
+		r = strings.ToLower(strings.TrimSpace(r))
+		if !slices.Contains(Roles, r) {
+			return nil, fmt.Errorf("unknown role %q (use %s)", r, strings.Join(Roles, ", "))
+		}
+		if !slices.Contains(out, r) {
+			out = append(out, r)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("at least one role is required (%s)", strings.Join(Roles, ", "))
+	}
+	slices.SortFunc(out, func(a, b string) int { return slices.Index(Roles, a) - slices.Index(Roles, b) })
+	return out, nil
+}
+
+// APIKey : ロールを付けたAPIキー（プロジェクトのキーとは別。キーそのものは作った時だけ返し、DBにはハッシュだけを置く）
+type APIKey struct {
+	ID        int       `json:"id"`
+	ProjectID int       `json:"project_id"` // 読み書きするプロジェクト
+	Name      string    `json:"name"`
+	Roles     []string  `json:"roles"`
+	Key       string    `json:"key,omitempty"` // 作った時の応答だけ
+	KeyHash   string    `json:"-"`             // キーの SHA-256（16進）
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserRoles : ダッシュボードのユーザー（DASHBOARD_USERS の名前）に付けたロール
+type UserRoles struct {
+	User      string    `json:"user"`
+	Roles     []string  `json:"roles"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,162 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+// Stats : GET /api/stats の結果
+type Stats struct {
+	Total   int            `json:"total"`
+	Last24h int            `json:"last_24h"`
+	ByType  map[string]int `json:"by_type"`
+	ByLevel map[string]int `json:"by_level"`
+	Peers   []PeerStatus   `json:"peers,omitempty"` // ?federate=true の時だけ
+
+	Sessions *SessionStats   `json:"sessions,omitempty"`
+	Latency  *LatencyStats   `json:"latency,omitempty"`
+	Uniques  *UniqueVisitors `json:"uniques,omitempty"`
+}
+
+// SessionStats : 期間内のセッションの数と長さ
+type SessionStats struct {
+	Since                 time.Time `json:"since"`
+	GapSeconds            int       `json:"gap_seconds"` // この間隔を超えて空いたら別のセッション
+	Sessions              int       `json:"sessions"`
+	Visitors              int       `json:"visitors"`           // 訪問者IDか IP + UA で見分けた訪問者の数
+	CookieVisitors        int       `json:"cookie_visitors"`    // 訪問者クッキー (VISITOR_COOKIE=true) のユニーク訪問者
+	ReturningVisitors     int       `json:"returning_visitors"` // そのうち since より前にも来ていた訪問者
+	AvgDurationSeconds    float64   `json:"avg_duration_seconds"`
+	MedianDurationSeconds float64   `json:"median_duration_seconds"`
+	Bounces               int       `json:"bounces"` // アクセスが1回だけのセッション
+}
+
+// UniquesExact / UniquesHLL : ユニーク訪問者の数え方
+const (
+	UniquesExact = "exact" // 生のログから数えた
+	UniquesHLL   = "hll"   // HyperLogLog のスケッチから見積もった（standard_error 程度ずれる）
+)
+
+// UniqueVisitors : 期間内のユニーク訪問者の数（長い期間は見積もり）
+type UniqueVisitors struct {
+	Since         time.Time `json:"since"`
+	Visitors      int64     `json:"visitors"`
+	Method        string    `json:"method"`                   // exact / hll
+	StandardError float64   `json:"standard_error,omitempty"` // 見積もりの標準誤差（相対値。0.016 なら ±1.6%）
+	Note          string    `json:"note,omitempty"`
+}
+
+// LatencyStats : 期間内に報告された応答ステータスと処理時間（報告のないログは数えない）
+type LatencyStats struct {
+	Since     time.Time      `json:"since"`
+	Reported  int            `json:"reported"`   // ステータスを報告したログ
+	Errors    int            `json:"errors"`     // そのうち 5xx
+	ErrorRate float64        `json:"error_rate"` // errors / reported（報告がなければ0）
+	ByClass   map[string]int `json:"by_class"`   // "2xx" "4xx" などステータスの百の位ごとの件数
+	Timed     int            `json:"timed"`      // 処理時間を報告したログ
+	P50MS     float64        `json:"p50_ms"`
+	P95MS     float64        `json:"p95_ms"`
+}
+
+// StatusClass : ステータスの百の位ごとの名前（"5xx" など）
+func StatusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// Add : status のログを n 件数える（n が0なら内訳にも載せない）
+func (l *LatencyStats) Add(status, n int) {
+	if n == 0 {
+		return
+	}
+	l.Reported += n
+	l.ByClass[StatusClass(status)] += n
+	if status >= 500 {
+		l.Errors += n
+	}
+}
+
+// Finish : 件数から割合を埋める
+func (l *LatencyStats) Finish() {
+	if l.Reported > 0 {
+		l.ErrorRate = float64(l.Errors) / float64(l.Reported)
+	}
+}
+
+// Timeseries : GET /api/stats/timeseries の結果（件数0の区間も含めて古い順）
+type Timeseries struct {
+	From            time.Time   `json:"from"`
+	To              time.Time   `json:"to"`
+	Interval        string      `json:"interval"`
+	IntervalSeconds int64       `json:"interval_seconds"`
+	Points          []TimePoint `json:"points"`
+}
+
+// TimePoint : 1区間分の件数
+type TimePoint struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// PeerStatus : 横断集計での問い合わせ結果（失敗しても他のインスタンスの分は返す）
+type PeerStatus struct {
+	Name      string `json:"name"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// Bucket : 列ごとの件数の1行
+type Bucket struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// TableVolume : テーブル1つ分の行数とサイズ
+type TableVolume struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+	Bytes int64  `json:"bytes"`
+}
+
+// VolumeSample : データ量の1回分の計測結果
+type VolumeSample struct {
+	SampledAt     time.Time     `json:"sampled_at"`
+	DatabaseBytes int64         `json:"database_bytes"`
+	Tables        []TableVolume `json:"tables"`
+}
+
+// TableStorage : テーブル1つ分の行数と、データ・索引に分けたサイズ（パーティションは親のテーブルにまとめる）
+type TableStorage struct {
+	Table      string `json:"table"`
+	Rows       int64  `json:"rows"`        // 見積もり (pg_stat_user_tables.n_live_tup)
+	TableBytes int64  `json:"table_bytes"` // データと TOAST (pg_table_size)
+	IndexBytes int64  `json:"index_bytes"` // 索引 (pg_indexes_size)
+	TotalBytes int64  `json:"total_bytes"` // 合計 (pg_total_relation_size)
+	Partitions int    `json:"partitions,omitempty"`
+}
+
+// StorageUsage : 保存先の使用量（/api/admin/storage）
+type StorageUsage struct {
+	DatabaseBytes int64          `json:"database_bytes"`
+	Tables        []TableStorage `json:"tables"` // 合計サイズの大きい順
+}
+
+// Table : 指定テーブルの使用量（なければゼロ値）
+func (s StorageUsage) Table(table string) TableStorage {
+	for _, t := range s.Tables {
+		if t.Table == table {
+			return t
+		}
+	}
+	return TableStorage{Table: table}
+}
+
+// TableRows : サンプル中の指定テーブルの行数
+func (s VolumeSample) TableRows(table string) int64 {
+	for _, t := range s.Tables {
+		if t.Table == table {
+			return t.Rows
+		}
+	}
+	return 0
+}
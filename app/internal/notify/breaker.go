@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// 通知先ごとのサーキットブレーカー
+// ==========================================
+// 送信先が落ちている間は、毎回タイムアウトまで待たずにすぐ失敗にする
+// 続けて Failures 回失敗すると開き、Cooldown 後に1件だけ試しに送って、届けば閉じる
+
+// Breaker : NOTIFY_BREAKER_* の設定（Failures が0なら使わない）
+type Breaker struct {
+	Failures int
+	Cooldown time.Duration
+}
+
+// BreakerFromEnv : NOTIFY_BREAKER_FAILURES（既定5）と NOTIFY_BREAKER_COOLDOWN（既定30秒）
+func BreakerFromEnv() Breaker {
+	return Breaker{
+		Failures: config.Int("NOTIFY_BREAKER_FAILURES", 5),
+		Cooldown: config.Duration("NOTIFY_BREAKER_COOLDOWN", 30*time.Second),
+	}
+}
+
+// CircuitOpenError : ブレーカーが開いているので送らなかった（RetryAfter 後に試しに送る）
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open after repeated failures: next attempt in %s", e.RetryAfter.Round(time.Second))
+}
+
+// circuit : 1つの送信先の状態
+type circuit struct {
+	mu       sync.Mutex
+	clk      clock.Clock
+	config   Breaker
+	failures int       // 続けて失敗した回数
+	openedAt time.Time // ゼロなら閉じている
+	probing  bool      // 開いている間に試しに送っている最中
+}
+
+// circuits : 送信先ごとの状態（limiters と同じく、通知先を作り直しても引き継ぐ）
+var circuits sync.Map
+
+// circuitFor : key（送信先）の状態。設定を変えた場合は作り直す
+func circuitFor(key string, b Breaker, clk clock.Clock) *circuit {
+	if v, ok := circuits.Load(key); ok {
+		if c := v.(*circuit); c.config == b {
+			return c
+		}
+	}
+	c := &circuit{clk: clk, config: b}
+	circuits.Store(key, c)
+	return c
+}
+
+// allow : 送ってよいか（開いていれば、Cooldown 後の1件だけ試しに通す）
+func (c *circuit) allow() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.openedAt.IsZero() {
+		return nil
+	}
+	if wait := c.openedAt.Add(c.config.Cooldown).Sub(c.clk.Now()); wait > 0 || c.probing {
+		return &CircuitOpenError{RetryAfter: max(wait, time.Second)}
+	}
+	c.probing = true
+	return nil
+}
+
+// done : 送った結果を記録する
+func (c *circuit) done(name string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	probe := c.probing
+	c.probing = false
+	if err == nil {
+		if !c.openedAt.IsZero() {
+			fmt.Printf("Notifier %s recovered, closing circuit\n", name)
+		}
+		c.failures, c.openedAt = 0, time.Time{}
+		return
+	}
+	c.failures++
+	if probe || c.failures >= c.config.Failures {
+		if c.openedAt.IsZero() {
+			fmt.Printf("Notifier %s failed %d times in a row, opening circuit for %s\n", name, c.failures, c.config.Cooldown)
+		}
+		c.openedAt = c.clk.Now()
+	}
+}
+
+// release : 試しに送った結果を数えずに、次の1件を試せるようにする
+func (c *circuit) release() {
+	c.mu.Lock()
+	c.probing = false
+	c.mu.Unlock()
+}
+
+// breakerNotifier : ブレーカー付きの通知先
+type breakerNotifier struct {
+	Notifier
+	circuit *circuit
+}
+
+// WithBreaker : key（送信先）ごとのブレーカーを付ける。b.Failures が0なら付けない
+func WithBreaker(n Notifier, key string, b Breaker, clk clock.Clock) Notifier {
+	if b.Failures <= 0 {
+		return n
+	}
+	return &breakerNotifier{Notifier: n, circuit: circuitFor(key, b, clk)}
+}
+
+func (b *breakerNotifier) Notify(ctx context.Context, n Notification) error {
+	if IsDryRun(ctx) {
+		return b.Notifier.Notify(ctx, n)
+	}
+	if err := b.circuit.allow(); err != nil {
+		return err
+	}
+	err := b.Notifier.Notify(ctx, n)
+	// 送信先が上限を返した・こちらの上限で待てなかった・呼び出し側が止めた場合は、送信先の障害としては数えない
+	var limited *RateLimitedError
+	if errors.As(err, &limited) || errors.Is(err, errRateLimited) || ctx.Err() != nil {
+		b.circuit.release()
+		return err
+	}
+	b.circuit.done(b.Name(), err)
+	return err
+}
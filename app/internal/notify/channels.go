@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// DBで管理する通知先 (NOTIFY_CHANNELS も同じ形)
+// ==========================================
+
+// ChannelSettings : 通知先の設定（Settings に url / token / chat_id 列の値を足したもの。列の方が優先）
+func ChannelSettings(c model.Channel) Settings {
+	s := Settings{}
+	for k, v := range c.Settings {
+		s[k] = v
+	}
+	for k, v := range map[string]string{"url": c.URL, "token": c.Token, "chat_id": c.ChatID} {
+		if v != "" {
+			s[k] = v
+		}
+	}
+	return s
+}
+
+// FromChannel : DBの通知先から Notifier を作る（種類は DefaultRegistry から引く。必要な項目が足りなければエラー）
+// Rules があれば、そのルールに合う通知だけを送る。Template があれば、ログの付いた通知の本文をそれで作る
+// 設定の min_level より下のレベルの通知は送らない
+// 同じ送信先（URL・チャット）は環境変数の通知先とも上限 (NOTIFY_RATE_LIMITS) とブレーカーの状態を分け合う
+func FromChannel(c model.Channel, clk clock.Clock) (Notifier, error) {
+	settings := ChannelSettings(c)
+	n, key, err := DefaultRegistry.Build(c.Type, settings, clk)
+	if err != nil {
+		return nil, err
+	}
+	n = WithBreaker(WithRateLimit(n, key, RateLimitsFromEnv()[c.Type], clk), key, BreakerFromEnv(), clk)
+	// 通知先の Template がなければ、環境変数の通知先と同じ <種類>_TEMPLATE / NOTIFY_TEMPLATE
+	if c.Template != "" {
+		if _, err := ParseTemplate(c.Template); err != nil {
+			return nil, err
+		}
+		n = WithTemplate(n, c.Template)
+	} else {
+		n = WithTemplate(n, TemplateFromEnv(c.Type))
+	}
+	if min := settings.Get("min_level"); min != "" {
+		if _, err := model.NormalizeLevel(min); err != nil {
+			return nil, fmt.Errorf("min_level: %w", err)
+		}
+		n = WithMinLevel(n, min)
+	}
+
+	named := &channelNotifier{Notifier: n, name: c.Type + ":" + c.Name}
+	if strings.TrimSpace(c.Rules) != "" {
+		named.rules = ParseRules(c.Rules)
+	}
+	return named, nil
+}
+
+// ChannelsFromEnv : NOTIFY_CHANNELS（JSONの配列）の通知先
+// 例: [{"name": "ops", "type": "webhook", "settings": {"url": "https://…", "secret": "…"}, "rules": "log=warn"}]
+func ChannelsFromEnv(clk clock.Clock) []Notifier {
+	raw := config.String("NOTIFY_CHANNELS", "")
+	if raw == "" {
+		return nil
+	}
+	var channels []model.Channel
+	if err := json.Unmarshal([]byte(raw), &channels); err != nil {
+		fmt.Println("Ignoring invalid NOTIFY_CHANNELS:", err)
+		return nil
+	}
+	var list []Notifier
+	for _, c := range channels {
+		n, err := FromChannel(c, clk)
+		if err != nil {
+			fmt.Printf("Skipping NOTIFY_CHANNELS entry %q: %v\n", c.Name, err)
+			continue
+		}
+		list = append(list, n)
+	}
+	return list
+}
+
+// channelNotifier : 名前（ログ・スパン用）とルーティングルール付きの通知先
+type channelNotifier struct {
+	Notifier
+	name  string
+	rules Rules
+}
+
+func (c *channelNotifier) Name() string { return c.name }
+
+func (c *channelNotifier) Notify(ctx context.Context, n Notification) error {
+	if c.rules != nil {
+		// アクセス記録・ログ以外の通知（稼働監視・データ量）は "*" のルールに従う
+		eventType := ""
+		if n.Entry != nil {
+			eventType = n.Entry.EventType
+		}
+		if !c.rules.ShouldNotify(eventType, n.Level) {
+			return nil
+		}
+	}
+	return c.Notifier.Notify(ctx, n)
+}
@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ==========================================
+// 通知先の疎通確認 (`main doctor`。メッセージは送らない)
+// ==========================================
+
+// Checker : メッセージを送らずに届くかだけを確かめられる通知先
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckResult : 通知先1つ分の確認結果
+type CheckResult struct {
+	Name string
+	Err  error // nil なら届く
+}
+
+// Check : 全ての通知先を確かめる（Checker でない通知先は送信先に繋がるかだけを見る）
+func (m *Multi) Check(ctx context.Context) []CheckResult {
+	results := make([]CheckResult, len(m.notifiers))
+	for i, n := range m.notifiers {
+		results[i] = CheckResult{Name: n.Name(), Err: check(ctx, n)}
+	}
+	return results
+}
+
+// check : 包んでいる通知先もたどって確かめる
+func check(ctx context.Context, n Notifier) error {
+	switch v := n.(type) {
+	case Checker:
+		return v.Check(ctx)
+	case *minLevelNotifier:
+		return check(ctx, v.Notifier)
+	case *channelNotifier:
+		return check(ctx, v.Notifier)
+	case *rateLimitedNotifier:
+		return check(ctx, v.Notifier)
+	case *breakerNotifier:
+		return check(ctx, v.Notifier)
+	case *templatedNotifier:
+		return check(ctx, v.Notifier)
+	}
+	return errors.New("reachability check is not supported")
+}
+
+// Check : Webhook を GET すると、送らずに Webhook が有効かを確かめられる
+func (d *Discord) Check(ctx context.Context) error {
+	return getOK(ctx, d.webhookURL)
+}
+
+// Check : Incoming Webhook は GET できないので、Slack に TCP で繋がるかだけを見る
+func (s *Slack) Check(ctx context.Context) error {
+	return dialURL(ctx, s.webhookURL)
+}
+
+// Check : getMe でトークンが有効かを確かめる
+func (t *Telegram) Check(ctx context.Context) error {
+	err := getOK(ctx, "https://api.telegram.org/bot"+t.botToken+"/getMe")
+	// エラーメッセージにトークン入りのURLが含まれるため伏せる
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("request to Telegram API failed")
+	}
+	if err != nil {
+		return errors.New(strings.ReplaceAll(err.Error(), t.botToken, "***"))
+	}
+	return nil
+}
+
+// Check : Incoming Webhook は GET できないので、Mattermost に TCP で繋がるかだけを見る
+func (m *Mattermost) Check(ctx context.Context) error {
+	return dialURL(ctx, m.webhookURL)
+}
+
+// Check : whoami でアクセストークンが有効かを確かめる
+func (m *Matrix) Check(ctx context.Context) error {
+	return getOKWith(ctx, m.homeserver+"/_matrix/client/v3/account/whoami", func(h http.Header) {
+		h.Set("Authorization", "Bearer "+m.accessToken)
+	})
+}
+
+// Check : Events API はイベントを作らずに試せないので、TCP で繋がるかだけを見る
+func (p *PagerDuty) Check(ctx context.Context) error {
+	return dialURL(ctx, pagerDutyEventsURL)
+}
+
+// Check : インテグレーションのキーでは読み出しの API を呼べないので、TCP で繋がるかだけを見る
+func (o *Opsgenie) Check(ctx context.Context) error {
+	return dialURL(ctx, o.apiURL)
+}
+
+// Check : SMTP サーバーに TCP で繋がるかだけを見る
+func (e *Email) Check(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(e.host, e.port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// getOK : GET して 2xx 以外ならエラーにする
+func getOK(ctx context.Context, rawURL string) error {
+	return getOKWith(ctx, rawURL, nil)
+}
+
+// getOKWith : getOK に加えて、header でヘッダー（認証など）を付ける
+func getOKWith(ctx context.Context, rawURL string, header func(h http.Header)) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	if header != nil {
+		header(req.Header)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// dialURL : URL のホストに TCP で繋がるか
+func dialURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
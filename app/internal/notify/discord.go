@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-logger/internal/clock"
+)
+
+// ==========================================
+// Discord
+// ==========================================
+
+// Discord : Discord WebhookにPOSTリクエストを送る（埋め込み形式）
+type Discord struct {
+	webhookURL string
+	clock      clock.Clock
+	buttons    bool // アラートに「確認」「1時間ミュート」「詳細」のボタンを付ける
+}
+
+// discordPayload : Webhookの本文
+type discordPayload struct {
+	Content    string                `json:"content,omitempty"`
+	Embeds     []discordEmbed        `json:"embeds,omitempty"`
+	Components []DiscordComponentRow `json:"components,omitempty"`
+}
+
+// DiscordComponentRow : ボタンを並べる行 (type 1)
+type DiscordComponentRow struct {
+	Type       int                `json:"type"`
+	Components []DiscordComponent `json:"components"`
+}
+
+// DiscordComponent : ボタン (type 2)
+type DiscordComponent struct {
+	Type     int    `json:"type"`
+	Style    int    `json:"style"` // 1: 強調, 2: 通常
+	Label    string `json:"label"`
+	CustomID string `json:"custom_id"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// DiscordAlertButtons : アラートに付けるボタン（押されるとインタラクションとして custom_id が届く）
+// custom_id は "alert:<操作>:<アラートID>"（操作は ack / mute / details）
+func DiscordAlertButtons(alertID int, acknowledged bool) []DiscordComponentRow {
+	id := fmt.Sprint(alertID)
+	return []DiscordComponentRow{{Type: 1, Components: []DiscordComponent{
+		{Type: 2, Style: 1, Label: "Acknowledge", CustomID: "alert:ack:" + id, Disabled: acknowledged},
+		{Type: 2, Style: 2, Label: "Mute 1h", CustomID: "alert:mute:" + id},
+		{Type: 2, Style: 2, Label: "Show details", CustomID: "alert:details:" + id},
+	}}}
+}
+
+// discordEmbed : 埋め込み1つ分
+type discordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	Description string              `json:"description,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	Color       int                 `json:"color"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// discordLevelColors : レベルごとの埋め込みの色
+var discordLevelColors = map[string]int{
+	"debug": 0x95a5a6,
+	"info":  0x3498db,
+	"warn":  0xf39c12,
+	"error": 0xe74c3c,
+	"fatal": 0x992d22,
+}
+
+func (d *Discord) Name() string { return "discord" }
+
+// embed : 通知を埋め込みに変換する（ログがあればUA・IP・国などを項目にする）
+func (d *Discord) embed(n Notification) discordEmbed {
+	e := discordEmbed{
+		Title:     n.Title,
+		URL:       n.EntryURL,
+		Color:     discordLevelColors[n.Level],
+		Timestamp: d.clock.Now().Format(time.RFC3339),
+	}
+	if e.Title == "" {
+		e.Title = strings.ToUpper(n.Level)
+	}
+	if n.Entry == nil {
+		e.Description = n.Text
+		return e
+	}
+
+	l := n.Entry
+	e.Timestamp = l.CreatedAt.UTC().Format(time.RFC3339)
+	e.Description = l.Message
+	// アラートルールなど、ログを例として添えた通知と、テンプレートで作った本文は本文を優先する
+	if n.Source != "" || n.Templated {
+		e.Description = n.Text
+	}
+	add := func(name, value string, inline bool) {
+		if value != "" {
+			e.Fields = append(e.Fields, discordEmbedField{Name: name, Value: value, Inline: inline})
+		}
+	}
+	add("Type", l.EventType, true)
+	add("Level", l.Level, true)
+	add("Path", l.Path, true)
+	add("IP", l.IP, true)
+	add("Country", l.Country, true)
+	add("Browser", strings.Trim(l.Browser+" / "+l.OS, " /"), true)
+	add("Referrer", l.Referrer, false)
+	add("User Agent", l.UserAgent, false)
+	if l.ID != 0 {
+		add("Entry", fmt.Sprintf("#%d", l.ID), true)
+	}
+	return e
+}
+
+func (d *Discord) Notify(ctx context.Context, n Notification) error {
+	// 上限を超える部分は切り詰めて全文へのリンクを付ける
+	embed := d.embed(n)
+	fitDiscordEmbed(&embed, n.EntryURL)
+
+	// content はプッシュ通知のプレビューに使われるので件名だけ入れる
+	content, _ := truncateText(embed.Title, discordContentLimit)
+	payload := discordPayload{Content: content, Embeds: []discordEmbed{embed}}
+	if d.buttons && n.AlertID != 0 {
+		payload.Components = DiscordAlertButtons(n.AlertID, false)
+	}
+	return postJSON(ctx, d.webhookURL, payload)
+}
+
+// NewDiscord : Webhook URL を指定して作る
+func NewDiscord(webhookURL string, clk clock.Clock) *Discord {
+	return &Discord{webhookURL: webhookURL, clock: clk}
+}
+
+// EnableButtons : アラートにボタンを付ける（アプリケーションが作った Webhook が必要）
+func (d *Discord) EnableButtons() {
+	d.buttons = true
+}
+
+func init() {
+	Register("discord", func(s Settings, clk clock.Clock) (Notifier, string, error) {
+		url, err := s.requireHTTPS("discord")
+		if err != nil {
+			return nil, "", err
+		}
+		return NewDiscord(url, clk), url, nil
+	}, "url")
+}
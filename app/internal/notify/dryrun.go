@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// ==========================================
+// ドライラン (組み立てた本文を標準出力に出し、実際には送らない)
+// ==========================================
+
+type dryRunKey struct{}
+
+// WithDryRun : この ctx で送る通知をドライランにする
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun : ドライラン中か
+func IsDryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+	return dry
+}
+
+// printDryRun : 送るはずだった内容を出す（Webhook の URL はトークンを含むのでホスト名だけ）
+func printDryRun(target string, body []byte) {
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		target = u.Host
+	}
+	fmt.Printf("[dry-run] would send to %s: %s\n", target, body)
+}
+
+// ==========================================
+// 送るはずだった内容の取り出し (POST /api/channels/{id}/test?dry_run=1)
+// ==========================================
+
+// Preview : ドライランで送るはずだった1回分
+type Preview struct {
+	Target string          `json:"target"`         // 送り先のホスト名（メールは "smtp <ホスト>"）
+	Body   json.RawMessage `json:"body,omitempty"` // JSON の本文（Discord の埋め込みなど）
+	Text   string          `json:"text,omitempty"` // JSON でない本文（メールのヘッダーと HTML）
+}
+
+type previewKey struct{}
+
+// previews : ctx ごとに溜めた Preview
+type previews struct {
+	mu   sync.Mutex
+	list []Preview
+}
+
+// WithPreview : この ctx で送る通知をドライランにし、送るはずだった内容を溜める（返した関数で取り出す）
+func WithPreview(ctx context.Context) (context.Context, func() []Preview) {
+	p := &previews{}
+	ctx = context.WithValue(WithDryRun(ctx), previewKey{}, p)
+	return ctx, func() []Preview {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return append([]Preview(nil), p.list...)
+	}
+}
+
+// recordPreview : WithPreview の ctx なら送るはずだった内容を溜める
+func recordPreview(ctx context.Context, target string, body []byte) {
+	p, ok := ctx.Value(previewKey{}).(*previews)
+	if !ok {
+		return
+	}
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		target = u.Host
+	}
+	preview := Preview{Target: target}
+	if json.Valid(body) {
+		preview.Body = append(json.RawMessage(nil), body...)
+	} else {
+		preview.Text = string(body)
+	}
+	p.mu.Lock()
+	p.list = append(p.list, preview)
+	p.mu.Unlock()
+}
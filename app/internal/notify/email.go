@@ -0,0 +1,206 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/config"
+)
+
+// Email : SMTPでHTMLメールを送る
+type Email struct {
+	clock    clock.Clock
+	host     string
+	port     string
+	tlsMode  string // "starttls"（既定） / "tls"（SMTPS） / "none"
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// EmailFromEnv : SMTP_HOST と SMTP_TO が設定されていれば有効にする
+func EmailFromEnv(clk clock.Clock) (*Email, bool) {
+	host, to := config.String("SMTP_HOST", ""), config.List("SMTP_TO")
+	if host == "" || len(to) == 0 {
+		return nil, false
+	}
+	return newEmail(Settings{
+		"host":     host,
+		"port":     config.String("SMTP_PORT", ""),
+		"tls":      config.String("SMTP_TLS", ""),
+		"username": config.String("SMTP_USERNAME", ""),
+		"password": config.String("SMTP_PASSWORD", ""),
+		"from":     config.String("SMTP_FROM", ""),
+		"to":       strings.Join(to, ","),
+	}, clk), true
+}
+
+// newEmail : 設定 (host / port / tls / username / password / from / to) から作る。to はカンマ区切り
+func newEmail(s Settings, clk clock.Clock) *Email {
+	e := &Email{
+		clock:    clk,
+		host:     s.Get("host"),
+		port:     s.Get("port"),
+		tlsMode:  strings.ToLower(s.Get("tls")),
+		username: s.Get("username"),
+		password: s.Get("password"),
+		from:     s.Get("from"),
+	}
+	for _, addr := range strings.Split(s.Get("to"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			e.to = append(e.to, addr)
+		}
+	}
+	if e.tlsMode == "" {
+		e.tlsMode = "starttls"
+	}
+	if e.port == "" {
+		e.port = "587"
+		if e.tlsMode == "tls" {
+			e.port = "465"
+		}
+	}
+	if e.from == "" {
+		e.from = e.username
+	}
+	return e
+}
+
+func init() {
+	Register("email", func(s Settings, clk clock.Clock) (Notifier, string, error) {
+		if err := s.require("email", "host", "to"); err != nil {
+			return nil, "", err
+		}
+		return newEmail(s, clk), "email:" + s.Get("host"), nil
+	}, "password")
+}
+
+func (e *Email) Name() string { return "email" }
+
+// emailTemplate : 通知メールの本文
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; color: #333;">
+	<h2 style="margin-bottom: 4px;">{{.Subject}}</h2>
+	<p style="color: #888; margin-top: 0;">Level: <strong>{{.Level}}</strong> / {{.SentAt}}</p>
+	<pre style="background: #f6f8fa; padding: 12px; border-radius: 4px; white-space: pre-wrap;">{{.Text}}</pre>
+	<p style="color: #aaa; font-size: 12px;">Sent by go-logger</p>
+</body>
+</html>`))
+
+// subject : 件名（Titleがなければ本文の1行目）
+func (n Notification) subject() string {
+	if n.Title != "" {
+		return n.Title
+	}
+	line, _, _ := strings.Cut(n.Text, "\n")
+	if len([]rune(line)) > 80 {
+		line = string([]rune(line)[:80]) + "…"
+	}
+	return fmt.Sprintf("[go-logger] %s: %s", strings.ToUpper(n.Level), line)
+}
+
+func (e *Email) Notify(ctx context.Context, n Notification) error {
+	// 1. 本文を組み立てる
+	var html bytes.Buffer
+	if err := emailTemplate.Execute(&html, map[string]string{
+		"Subject": n.subject(),
+		"Level":   n.Level,
+		"Text":    n.Text,
+		"SentAt":  e.clock.Now().Format(time.RFC1123),
+	}); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.subject()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", e.clock.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n\r\n")
+	msg.Write(html.Bytes())
+	if IsDryRun(ctx) {
+		printDryRun("smtp "+e.host, []byte(fmt.Sprintf("to=%s subject=%q", strings.Join(e.to, ","), n.subject())))
+		recordPreview(ctx, "smtp "+e.host, msg.Bytes())
+		return nil
+	}
+
+	// 2. 接続して送信する
+	client, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if e.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := client.Mail(e.from); err != nil {
+		return err
+	}
+	for _, addr := range e.to {
+		if err := client.Rcpt(addr); err != nil {
+			return fmt.Errorf("smtp rcpt %s: %w", addr, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// dial : TLSの設定に合わせてSMTPサーバーに接続する
+func (e *Email) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(e.host, e.port)
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	tlsConfig := &tls.Config{ServerName: e.host}
+
+	var conn net.Conn
+	var err error
+	if e.tlsMode == "tls" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if e.tlsMode == "starttls" {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	return client, nil
+}
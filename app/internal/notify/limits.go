@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// ==========================================
+// 通知先ごとの文字数上限と切り詰め
+// ==========================================
+
+// Discord の上限 (https://discord.com/developers/docs/resources/message#embed-object-embed-limits)
+const (
+	discordContentLimit     = 2000
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+	discordFieldNameLimit   = 256
+	discordFieldValueLimit  = 1024
+	discordMaxFields        = 25
+	discordEmbedTotalLimit  = 6000
+)
+
+// telegramTextLimit : sendMessage の text の上限
+const telegramTextLimit = 4096
+
+// slackTextLimit : Incoming Webhook の text の上限（これを超えると切り詰められる）
+const slackTextLimit = 40000
+
+// mattermostTextLimit : 投稿の本文の上限（サーバーの既定の MaxPostSize）
+const mattermostTextLimit = 16383
+
+// matrixTextLimit : m.room.message の body に入れる上限（イベント全体の 65536 バイトに収まるよう控えめに）
+const matrixTextLimit = 16000
+
+// ellipsis : 切り詰めた印
+const ellipsis = "…"
+
+// truncateText : max文字（ルーン数）に収める。切り詰めたかどうかも返す
+// 上限の手前8割以降に空白や改行があれば、単語の途中で切らないようそこで切る
+func truncateText(s string, max int) (string, bool) {
+	if utf8.RuneCountInString(s) <= max {
+		return s, false
+	}
+	if max <= 0 {
+		return "", true
+	}
+	runes := []rune(s)[:max-1]
+	cut := string(runes)
+	if i := strings.LastIndexAny(cut, " \n\t"); i > 0 && utf8.RuneCountInString(cut[:i]) >= max*8/10 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \n\t") + ellipsis, true
+}
+
+// appendWithin : 本文の末尾に suffix を付ける。入りきらなければ本文側を切り詰める
+func appendWithin(s, suffix string, max int) string {
+	room := max - utf8.RuneCountInString(suffix)
+	if room <= 0 {
+		out, _ := truncateText(suffix, max)
+		return out
+	}
+	body, _ := truncateText(s, room)
+	return body + suffix
+}
+
+// fitDiscordEmbed : 埋め込みを Discord の上限に収める
+// どこかを切り詰めた場合は、説明文の末尾に全文へのリンクを付ける
+func fitDiscordEmbed(e *discordEmbed, fullURL string) {
+	truncated := false
+	fit := func(s string, max int) string {
+		out, cut := truncateText(s, max)
+		truncated = truncated || cut
+		return out
+	}
+
+	e.Title = fit(e.Title, discordTitleLimit)
+	e.Description = fit(e.Description, discordDescriptionLimit)
+	if len(e.Fields) > discordMaxFields {
+		e.Fields = e.Fields[:discordMaxFields]
+		truncated = true
+	}
+	for i := range e.Fields {
+		e.Fields[i].Name = fit(e.Fields[i].Name, discordFieldNameLimit)
+		e.Fields[i].Value = fit(e.Fields[i].Value, discordFieldValueLimit)
+	}
+
+	// 合計6000文字を超える場合は、長い項目から順に削る
+	for embedLength(e) > discordEmbedTotalLimit {
+		longest := -1
+		for i, f := range e.Fields {
+			if longest < 0 || utf8.RuneCountInString(f.Value) > utf8.RuneCountInString(e.Fields[longest].Value) {
+				longest = i
+			}
+		}
+		over := embedLength(e) - discordEmbedTotalLimit
+		if longest < 0 || utf8.RuneCountInString(e.Fields[longest].Value) <= over+len(ellipsis) {
+			e.Description = fit(e.Description, utf8.RuneCountInString(e.Description)-over)
+			break
+		}
+		e.Fields[longest].Value = fit(e.Fields[longest].Value, utf8.RuneCountInString(e.Fields[longest].Value)-over)
+	}
+
+	if truncated && fullURL != "" {
+		link := "\n\n[View full entry](" + fullURL + ")"
+		e.Description = appendWithin(e.Description, link, discordDescriptionLimit)
+		for embedLength(e) > discordEmbedTotalLimit && len(e.Fields) > 0 {
+			e.Fields = e.Fields[:len(e.Fields)-1]
+		}
+	}
+}
+
+// embedLength : 上限の計算対象になる文字数の合計
+func embedLength(e *discordEmbed) int {
+	n := utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description)
+	for _, f := range e.Fields {
+		n += utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+	}
+	return n
+}
+
+// fitPlainText : プレーンテキストの通知を上限に収め、切り詰めた場合は全文へのリンクを付ける
+func fitPlainText(text string, max int, fullURL string) string {
+	out, cut := truncateText(text, max)
+	if !cut || fullURL == "" {
+		return out
+	}
+	return appendWithin(text, "\n\nView full entry: "+fullURL, max)
+}
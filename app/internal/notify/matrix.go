@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-logger/internal/clock"
+)
+
+// ==========================================
+// Matrix
+// ==========================================
+// Client-Server API でルームに m.notice を送る（ボットの発言として扱われ、他のボットが反応しない）
+//
+//	PUT {homeserver}/_matrix/client/v3/rooms/{room_id}/send/m.room.message/{txn_id}
+//	Authorization: Bearer {access_token}
+//
+// txn_id は送り直しても同じにする（ホームサーバーが二重に投稿しない）。トークンはヘッダーにだけ入れる（エラーの URL に出ない）
+
+// Matrix : アクセストークンのユーザーとしてルームに送る（ユーザーは先にルームへ参加しておく）
+type Matrix struct {
+	homeserver  string
+	accessToken string
+	roomID      string
+}
+
+func (m *Matrix) Name() string { return "matrix" }
+
+// matrixMessage : m.room.message の本文
+type matrixMessage struct {
+	MsgType string `json:"msgtype"`
+	Body    string `json:"body"`
+}
+
+// matrixTxnPrefix, matrixTxnSeq : txn_id（起動ごとに変わる接頭辞 + 連番。再起動しても前の ID と重ならない）
+var (
+	matrixTxnPrefix = "logger." + strconv.FormatInt(time.Now().UnixNano(), 36)
+	matrixTxnSeq    atomic.Uint64
+)
+
+func (m *Matrix) Notify(ctx context.Context, n Notification) error {
+	txnID := matrixTxnPrefix + "." + strconv.FormatUint(matrixTxnSeq.Add(1), 10)
+	endpoint := m.homeserver + "/_matrix/client/v3/rooms/" + url.PathEscape(m.roomID) + "/send/m.room.message/" + txnID
+	return sendJSON(ctx, http.MethodPut, endpoint, matrixMessage{
+		MsgType: "m.notice",
+		Body:    fitPlainText(n.Text, matrixTextLimit, n.EntryURL),
+	}, func(h http.Header, body []byte) {
+		h.Set("Authorization", "Bearer "+m.accessToken)
+	})
+}
+
+// NewMatrix : ホームサーバーの URL (https://matrix.example.org)、アクセストークン、ルームID (!abc:example.org) を指定して作る
+func NewMatrix(homeserver, accessToken, roomID string) *Matrix {
+	return &Matrix{homeserver: strings.TrimRight(homeserver, "/"), accessToken: accessToken, roomID: roomID}
+}
+
+func init() {
+	Register("matrix", func(s Settings, clk clock.Clock) (Notifier, string, error) {
+		if err := s.require("matrix", "homeserver", "access_token", "room_id"); err != nil {
+			return nil, "", err
+		}
+		homeserver := s.Get("homeserver")
+		if !strings.HasPrefix(homeserver, "https://") && !strings.HasPrefix(homeserver, "http://") {
+			return nil, "", fmt.Errorf("matrix channel needs an http(s) homeserver url")
+		}
+		roomID := s.Get("room_id")
+		return NewMatrix(homeserver, s.Get("access_token"), roomID), "matrix:" + roomID, nil
+	}, "access_token")
+}
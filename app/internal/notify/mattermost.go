@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-logger/internal/clock"
+)
+
+// ==========================================
+// Mattermost
+// ==========================================
+
+// Mattermost : Incoming Webhook でチャンネルに送る（セルフホストが多いので http の URL も受け付ける）
+type Mattermost struct {
+	webhookURL string
+	username   string // 投稿者の表示名（空なら Webhook の設定のまま）
+	channel    string // 送り先のチャンネル（空なら Webhook の既定のチャンネル）
+}
+
+func (m *Mattermost) Name() string { return "mattermost" }
+
+// mattermostMessage : Incoming Webhook の本文
+type mattermostMessage struct {
+	Text     string `json:"text"`
+	Username string `json:"username,omitempty"`
+	Channel  string `json:"channel,omitempty"`
+}
+
+func (m *Mattermost) Notify(ctx context.Context, n Notification) error {
+	return postJSON(ctx, m.webhookURL, mattermostMessage{
+		Text:     fitPlainText(n.Text, mattermostTextLimit, n.EntryURL),
+		Username: m.username,
+		Channel:  m.channel,
+	})
+}
+
+// NewMattermost : Webhook URL と、上書きする表示名・チャンネル（空なら上書きしない）を指定して作る
+func NewMattermost(webhookURL, username, channel string) *Mattermost {
+	return &Mattermost{webhookURL: webhookURL, username: username, channel: channel}
+}
+
+func init() {
+	Register("mattermost", func(s Settings, clk clock.Clock) (Notifier, string, error) {
+		url := s.Get("url")
+		if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+			return nil, "", fmt.Errorf("mattermost channel needs an http(s) webhook url")
+		}
+		return NewMattermost(url, s.Get("username"), s.Get("channel")), url, nil
+	}, "url")
+}
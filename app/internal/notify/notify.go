@@ -0,0 +1,257 @@
+// Package notify : 通知 (Discord / Slack / Telegram / Mattermost / Matrix / メール / PagerDuty などの通知先を共通のインターフェースで扱う)
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/config"
+	"go-logger/internal/faults"
+	"go-logger/internal/model"
+	"go-logger/internal/tracing"
+)
+
+// Notification : 通知1件分
+type Notification struct {
+	Level     string          // debug / info / warn / error / fatal
+	Title     string          // 件名（メールやDiscordの埋め込みのタイトル。省略可）
+	Text      string          // プレーンテキストの本文
+	Entry     *model.LogEntry // 元になったログ（アクセス記録以外の通知ではnil）
+	EntryURL  string          // ダッシュボード上のこのログへのリンク（PUBLIC_BASE_URL が未設定なら空）
+	Source    string          // 発生元（uptime / volume など。ログ由来なら省略してよい）
+	Key       string          // 同じ種類のアラートをまとめるキー（ミュートの単位。省略可）
+	AlertID   int             // 履歴に記録したアラートのID（Discord のボタンに使う。記録しなければ0）
+	Templated bool            // Text をテンプレート (NOTIFY_TEMPLATE など) で作った（Discord でも本文に使う）
+	Resolved  bool            // Key のアラートが収まった（インシデントの通知先 (PagerDuty / Opsgenie) だけへ送る）
+}
+
+// Notifier : 通知先1つ分
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// ==========================================
+// 複数の通知先への送信
+// ==========================================
+
+// Multi : 全ての通知先へ順番に送る（1つ失敗しても他は送る）
+type Multi struct {
+	notifiers []Notifier
+}
+
+// NewMulti : 通知先をまとめる
+func NewMulti(notifiers ...Notifier) *Multi {
+	return &Multi{notifiers: notifiers}
+}
+
+func (m *Multi) Name() string { return "all" }
+
+// Len : 設定されている通知先の数
+func (m *Multi) Len() int { return len(m.notifiers) }
+
+// Names : 設定されている通知先の名前
+func (m *Multi) Names() []string {
+	names := make([]string, len(m.notifiers))
+	for i, n := range m.notifiers {
+		names[i] = n.Name()
+	}
+	return names
+}
+
+// Notify : 通知先ごとにスパンを作って送り、失敗はまとめて返す
+func (m *Multi) Notify(ctx context.Context, n Notification) error {
+	_, err := m.NotifyOnly(ctx, n, nil)
+	return err
+}
+
+// NotifyOnly : names に含まれる通知先だけへ送り（nil なら全て）、失敗した通知先の名前を返す
+// 再送の時に、送れた通知先へ二重に送らないために使う
+func (m *Multi) NotifyOnly(ctx context.Context, n Notification, names []string) ([]string, error) {
+	var failed []string
+	var errs []error
+	for _, notifier := range m.notifiers {
+		if names != nil && !slices.Contains(names, notifier.Name()) {
+			continue
+		}
+		spanCtx, span := tracing.Tracer.Start(ctx, notifier.Name()+".notify", trace.WithSpanKind(trace.SpanKindClient))
+		err := notifier.Notify(spanCtx, n)
+		tracing.EndSpan(span, err)
+		if err != nil {
+			fmt.Printf("Failed to send %s notification: %v\n", notifier.Name(), err)
+			failed = append(failed, notifier.Name())
+			errs = append(errs, fmt.Errorf("%s: %w", notifier.Name(), err))
+		}
+	}
+	return failed, errors.Join(errs...)
+}
+
+// FromEnv : URLやトークンが設定されている通知先だけを有効にする
+// チャット (Discord / Slack / Telegram / Mattermost / Matrix) は送信先ごとの上限 (NOTIFY_RATE_LIMITS) を守るよう間隔を空けて送り、
+// 続けて失敗する送信先はしばらく送らない (NOTIFY_BREAKER_*)。本文は NOTIFY_TEMPLATE などで変えられる
+func FromEnv(clk clock.Clock) *Multi {
+	limits := RateLimitsFromEnv()
+	breaker := BreakerFromEnv()
+	var list []Notifier
+	if url := config.String("DISCORD_WEBHOOK_URL", ""); url != "" {
+		discord := NewDiscord(url, clk)
+		// ボタンはアプリケーションが作った Webhook でしか使えないので、
+		// インタラクションを受け取る設定 (DISCORD_PUBLIC_KEY) がある時だけ付ける
+		if config.String("DISCORD_PUBLIC_KEY", "") != "" {
+			discord.EnableButtons()
+		}
+		list = append(list, WithTemplate(WithBreaker(WithRateLimit(discord, url, limits["discord"], clk), url, breaker, clk), TemplateFromEnv("discord")))
+	}
+	if url := config.String("SLACK_WEBHOOK_URL", ""); url != "" {
+		list = append(list, WithTemplate(WithBreaker(WithRateLimit(NewSlack(url), url, limits["slack"], clk), url, breaker, clk), TemplateFromEnv("slack")))
+	}
+	if token, chatID := config.String("TELEGRAM_BOT_TOKEN", ""), config.String("TELEGRAM_CHAT_ID", ""); token != "" && chatID != "" {
+		key := "telegram:" + chatID
+		list = append(list, WithTemplate(WithBreaker(WithRateLimit(NewTelegram(token, chatID), key, limits["telegram"], clk), key, breaker, clk), TemplateFromEnv("telegram")))
+	}
+	if url := config.String("MATTERMOST_WEBHOOK_URL", ""); url != "" {
+		mattermost := NewMattermost(url, config.String("MATTERMOST_USERNAME", ""), config.String("MATTERMOST_CHANNEL", ""))
+		list = append(list, WithTemplate(WithBreaker(WithRateLimit(mattermost, url, limits["mattermost"], clk), url, breaker, clk), TemplateFromEnv("mattermost")))
+	}
+	if homeserver, token, roomID := config.String("MATRIX_HOMESERVER_URL", ""), config.String("MATRIX_ACCESS_TOKEN", ""), config.String("MATRIX_ROOM_ID", ""); homeserver != "" && token != "" && roomID != "" {
+		key := "matrix:" + roomID
+		list = append(list, WithTemplate(WithBreaker(WithRateLimit(NewMatrix(homeserver, token, roomID), key, limits["matrix"], clk), key, breaker, clk), TemplateFromEnv("matrix")))
+	}
+	if email, ok := EmailFromEnv(clk); ok {
+		// メールはチャットより重いので、既定では error 以上だけ送る
+		list = append(list, WithMinLevel(WithTemplate(WithBreaker(email, "email", breaker, clk), TemplateFromEnv("email")), config.String("EMAIL_MIN_LEVEL", "error")))
+	}
+	// 種類と設定で書く通知先（DB の通知先と同じ形。登録されている種類なら何でも使える）
+	list = append(list, ChannelsFromEnv(clk)...)
+	return NewMulti(list...)
+}
+
+// IncidentsFromEnv : インシデント管理の通知先 (PagerDuty / Opsgenie)。設定されたものだけを有効にする
+// チャットの通知先とは分けて持ち、エラー以上のログと発火したアラートルールだけを送る（選ぶのはサーバー側）
+func IncidentsFromEnv(clk clock.Clock) *Multi {
+	breaker := BreakerFromEnv()
+	var list []Notifier
+	if key := config.String("PAGERDUTY_ROUTING_KEY", ""); key != "" {
+		list = append(list, WithBreaker(NewPagerDuty(key, clk), "pagerduty", breaker, clk))
+	}
+	if key := config.String("OPSGENIE_API_KEY", ""); key != "" {
+		list = append(list, WithBreaker(NewOpsgenie(config.String("OPSGENIE_API_URL", ""), key), "opsgenie", breaker, clk))
+	}
+	return NewMulti(list...)
+}
+
+// ==========================================
+// 最低レベル付きの通知先
+// ==========================================
+
+// minLevelNotifier : 指定レベル未満の通知を送らない通知先
+type minLevelNotifier struct {
+	Notifier
+	min string
+}
+
+// WithMinLevel : 通知先に最低レベルを付ける
+func WithMinLevel(n Notifier, min string) Notifier {
+	normalized, err := model.NormalizeLevel(min)
+	if err != nil {
+		fmt.Printf("Invalid minimum level for %s notifier: %v\n", n.Name(), err)
+		normalized = "error"
+	}
+	return &minLevelNotifier{Notifier: n, min: normalized}
+}
+
+func (m *minLevelNotifier) Notify(ctx context.Context, n Notification) error {
+	if model.LevelRank(n.Level) < model.LevelRank(m.min) {
+		return nil
+	}
+	return m.Notifier.Notify(ctx, n)
+}
+
+// ==========================================
+// HTTP送信の共通処理
+// ==========================================
+
+// httpClient : 通知送信用（タイムアウト5秒・トレース付き）
+var httpClient = tracing.HTTPClient(&http.Client{Timeout: 5 * time.Second})
+
+// postJSON : 構造体をJSONにしてPOSTし、2xx以外ならエラーにする
+// (文字列の組み立てではなく json.Marshal を通すので、引用符や改行を含む値でも壊れない)
+// 429 (と Retry-After 付きの 503) は指定された時間だけ待って送り直す（retryAfterMaxWait より長ければ RateLimitedError）
+func postJSON(ctx context.Context, url string, payload any) error {
+	return postJSONWith(ctx, url, payload, nil)
+}
+
+// postJSONWith : postJSON に加えて、送るたびに sign でヘッダー（署名など）を付ける
+func postJSONWith(ctx context.Context, url string, payload any, sign func(h http.Header, body []byte)) error {
+	return sendJSON(ctx, http.MethodPost, url, payload, sign)
+}
+
+// sendJSON : POST 以外のメソッドで送る（Matrix の PUT など）。送り直しと上限の扱いは postJSON と同じ
+func sendJSON(ctx context.Context, method, url string, payload any, sign func(h http.Header, body []byte)) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if IsDryRun(ctx) {
+		printDryRun(url, body)
+		recordPreview(ctx, url, body)
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		// 前の応答で使い切ったと分かっている送信先は、空くまで待ってから送る
+		if err := waitBackoff(ctx, url); err != nil {
+			return err
+		}
+		delay, err := sendOnce(ctx, method, url, body, sign)
+		if delay == 0 || attempt >= rateLimitRetries {
+			return err
+		}
+		if delay > retryAfterMaxWait {
+			return &RateLimitedError{RetryAfter: delay, Err: err}
+		}
+		fmt.Printf("Notification rate limited, retrying in %s: %v\n", delay.Round(time.Millisecond), err)
+		if err := waitBackoff(ctx, url); err != nil {
+			return err
+		}
+	}
+}
+
+// sendOnce : 1回送る。待って送り直すべき応答なら、その待ち時間も返す
+func sendOnce(ctx context.Context, method, url string, body []byte, sign func(h http.Header, body []byte)) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sign != nil {
+		sign(req.Header, body)
+	}
+	if err := faults.Webhook(req.URL.Host); err != nil {
+		return 0, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	trace.SpanFromContext(ctx).SetAttributes(tracing.Attr("http.response.status", resp.Status))
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	delay := recordRateLimit(url, req.URL.Host, resp, detail)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return delay, fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return 0, nil
+}
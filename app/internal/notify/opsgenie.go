@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ==========================================
+// Opsgenie (Alert API)
+// ==========================================
+// Key を alias にして、同じキーのアラートは Opsgenie 側で1つにまとめる（重複は count が増える）
+// Resolved の通知で alias のアラートを close する
+
+// Opsgenie の上限
+const (
+	opsgenieMessageLimit     = 130
+	opsgenieDescriptionLimit = 15000
+)
+
+// Opsgenie : API インテグレーションのキーでアラートを作る
+type Opsgenie struct {
+	apiURL string // https://api.opsgenie.com（EU は https://api.eu.opsgenie.com）
+	apiKey string
+}
+
+func (o *Opsgenie) Name() string { return "opsgenie" }
+
+// opsgenieAlert : アラートを作る本文
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Priority    string            `json:"priority"` // P1 (最も高い) 〜 P5
+	Source      string            `json:"source"`
+	Entity      string            `json:"entity,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+// opsgenieClose : アラートを閉じる本文
+type opsgenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note,omitempty"`
+}
+
+func (o *Opsgenie) Notify(ctx context.Context, n Notification) error {
+	auth := func(h http.Header, body []byte) { h.Set("Authorization", "GenieKey "+o.apiKey) }
+	if n.Resolved {
+		endpoint := o.apiURL + "/v2/alerts/" + url.PathEscape(n.Key) + "/close?identifierType=alias"
+		return postJSONWith(ctx, endpoint, opsgenieClose{Source: "go-logger", Note: n.Text}, auth)
+	}
+	alert := opsgenieAlert{
+		Message:     incidentSummary(n, opsgenieMessageLimit),
+		Alias:       n.Key,
+		Description: fitPlainText(n.Text, opsgenieDescriptionLimit, n.EntryURL),
+		Priority:    opsgeniePriority(n.Level),
+		Source:      "go-logger",
+		Entity:      n.Source,
+		Tags:        []string{n.Level},
+	}
+	if n.EntryURL != "" {
+		alert.Details = map[string]string{"entry_url": n.EntryURL}
+	}
+	if n.Entry != nil {
+		alert.Tags = append(alert.Tags, n.Entry.EventType)
+	}
+	return postJSONWith(ctx, o.apiURL+"/v2/alerts", alert, auth)
+}
+
+// opsgeniePriority : レベル → priority
+func opsgeniePriority(level string) string {
+	switch level {
+	case "fatal":
+		return "P1"
+	case "error":
+		return "P2"
+	case "warn":
+		return "P3"
+	}
+	return "P4"
+}
+
+// NewOpsgenie : API の URL（空なら US リージョン）とキーを指定して作る
+func NewOpsgenie(apiURL, apiKey string) *Opsgenie {
+	if apiURL == "" {
+		apiURL = "https://api.opsgenie.com"
+	}
+	return &Opsgenie{apiURL: strings.TrimRight(apiURL, "/"), apiKey: apiKey}
+}
@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go-logger/internal/clock"
+)
+
+// ==========================================
+// PagerDuty (Events API v2)
+// ==========================================
+// Key を dedup_key にして、同じキーのアラートは1つのインシデントにまとめる。Resolved の通知で resolve する
+
+// pagerDutyEventsURL : Events API v2 の送り先
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutySummaryLimit : payload.summary の上限
+const pagerDutySummaryLimit = 1024
+
+// PagerDuty : サービスの Integration Key (routing key) 宛てにイベントを送る
+type PagerDuty struct {
+	routingKey string
+	clock      clock.Clock
+}
+
+func (p *PagerDuty) Name() string { return "pagerduty" }
+
+// pagerDutyEvent : イベント1件（resolve では payload を省く）
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"` // trigger / resolve
+	DedupKey    string            `json:"dedup_key,omitempty"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"` // critical / error / warning / info
+	Timestamp     time.Time      `json:"timestamp"`
+	Component     string         `json:"component,omitempty"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+func (p *PagerDuty) Notify(ctx context.Context, n Notification) error {
+	if n.Resolved {
+		return postJSON(ctx, pagerDutyEventsURL, pagerDutyEvent{RoutingKey: p.routingKey, EventAction: "resolve", DedupKey: n.Key})
+	}
+	details := map[string]any{"text": n.Text}
+	if n.Entry != nil {
+		details["entry"] = n.Entry
+	}
+	event := pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "trigger",
+		DedupKey:    n.Key,
+		Payload: &pagerDutyPayload{
+			Summary:       incidentSummary(n, pagerDutySummaryLimit),
+			Source:        "go-logger",
+			Severity:      pagerDutySeverity(n.Level),
+			Timestamp:     p.clock.Now().UTC(),
+			Component:     n.Source,
+			CustomDetails: details,
+		},
+	}
+	if n.EntryURL != "" {
+		event.Links = []pagerDutyLink{{Href: n.EntryURL, Text: "View entry"}}
+	}
+	return postJSON(ctx, pagerDutyEventsURL, event)
+}
+
+// pagerDutySeverity : レベル → severity
+func pagerDutySeverity(level string) string {
+	switch level {
+	case "fatal":
+		return "critical"
+	case "error":
+		return "error"
+	case "warn":
+		return "warning"
+	}
+	return "info"
+}
+
+// NewPagerDuty : Integration Key を指定して作る
+func NewPagerDuty(routingKey string, clk clock.Clock) *PagerDuty {
+	return &PagerDuty{routingKey: routingKey, clock: clk}
+}
+
+// incidentSummary : インシデントの件名（本文の1行目、なければタイトル。ルールの理由やログのメッセージが入る）
+func incidentSummary(n Notification, max int) string {
+	summary, _, _ := strings.Cut(n.Text, "\n")
+	if strings.TrimSpace(summary) == "" {
+		summary = n.Title
+	}
+	summary, _ = truncateText(strings.TrimSpace(summary), max)
+	return summary
+}
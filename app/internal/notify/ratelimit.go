@@ -0,0 +1,180 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// 通知先ごとの送信の間隔 (トークンバケット)
+// ==========================================
+
+// RateLimit : window の間に count 件まで。一度に続けて送れるのは burst 件
+// 空きを MaxWait より長く待つ必要があれば送らずに失敗にする（通知の再送に回る）
+type RateLimit struct {
+	Count   int
+	Window  time.Duration
+	Burst   int
+	MaxWait time.Duration
+}
+
+// defaultRateLimits : 種類ごとの既定の上限（各サービスの公開されている上限より少し控えめ）
+// Discord は Webhook ごとに 2秒で5件・1分で30件、Telegram はグループへ1分で20件、Slack は1秒に1件
+// Matrix は Synapse の既定 (rc_message: 1秒に0.2件・続けて10件) に合わせる。Mattermost はサーバーの設定次第なので制限しない
+var defaultRateLimits = map[string]RateLimit{
+	"discord":  {Count: 30, Window: time.Minute, Burst: 5},
+	"slack":    {Count: 1, Window: time.Second, Burst: 3},
+	"telegram": {Count: 20, Window: time.Minute, Burst: 3},
+	"matrix":   {Count: 12, Window: time.Minute, Burst: 5},
+}
+
+// RateLimitsFromEnv : NOTIFY_RATE_LIMITS="discord=30/1m,slack=1/1s" で種類ごとの上限を変える
+// NOTIFY_RATE_BURST を指定すると、続けて送れる件数をまとめて変える。off を指定した種類は制限しない
+// 待つ時間の上限は NOTIFY_RATE_MAX_WAIT（既定1分）
+func RateLimitsFromEnv() map[string]RateLimit {
+	limits := map[string]RateLimit{}
+	for kind, l := range defaultRateLimits {
+		limits[kind] = l
+	}
+	for _, item := range config.List("NOTIFY_RATE_LIMITS") {
+		kind, value, ok := strings.Cut(item, "=")
+		kind, value = strings.TrimSpace(kind), strings.TrimSpace(value)
+		if !ok {
+			fmt.Printf("Ignoring NOTIFY_RATE_LIMITS entry %q\n", item)
+			continue
+		}
+		if value == "off" {
+			delete(limits, kind)
+			continue
+		}
+		l, err := parseRateLimit(value)
+		if err != nil {
+			fmt.Printf("Ignoring NOTIFY_RATE_LIMITS entry %q: %v\n", item, err)
+			continue
+		}
+		l.Burst = limits[kind].Burst
+		limits[kind] = l
+	}
+	burst := config.Int("NOTIFY_RATE_BURST", 0)
+	maxWait := config.Duration("NOTIFY_RATE_MAX_WAIT", time.Minute)
+	for kind, l := range limits {
+		if burst > 0 {
+			l.Burst = burst
+		}
+		l.MaxWait = maxWait
+		limits[kind] = l
+	}
+	return limits
+}
+
+// parseRateLimit : "30/1m" の形
+func parseRateLimit(s string) (RateLimit, error) {
+	count, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("want <count>/<duration>")
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid count %q", count)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid duration %q", window)
+	}
+	return RateLimit{Count: n, Window: d}, nil
+}
+
+// errRateLimited : 空きを待ちきれずに送らなかった
+var errRateLimited = errors.New("rate limited")
+
+// rateLimiter : 1つの送信先（Webhook の URL やチャット）のトークンバケット
+// 空きがなければ予約して順番を待つので、まとめて来た通知は捨てずに間隔を空けて送る
+type rateLimiter struct {
+	mu     sync.Mutex
+	clk    clock.Clock
+	limit  RateLimit
+	tokens float64 // 負の値は、待っている通知が予約した分
+	last   time.Time
+}
+
+// limiters : 送信先ごとのバケット
+// 通知先は読み直すたびに作り直すので、同じ送信先なら同じバケットを使い続ける
+var limiters sync.Map
+
+// limiterFor : key（送信先）のバケット。上限を変えた場合は作り直す
+func limiterFor(key string, limit RateLimit, clk clock.Clock) *rateLimiter {
+	if v, ok := limiters.Load(key); ok {
+		if l := v.(*rateLimiter); l.limit == limit {
+			return l
+		}
+	}
+	l := &rateLimiter{clk: clk, limit: limit, tokens: float64(max(limit.Burst, 1)), last: clk.Now()}
+	limiters.Store(key, l)
+	return l
+}
+
+// reserve : 1件分を予約し、送ってよくなるまでの時間を返す
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clk.Now()
+	perSecond := float64(l.limit.Count) / l.limit.Window.Seconds()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*perSecond, float64(max(l.limit.Burst, 1)))
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / perSecond * float64(time.Second))
+}
+
+// cancel : 予約した1件分を戻す（待たずに諦めた時）
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	l.tokens++
+	l.mu.Unlock()
+}
+
+// rateLimitedNotifier : 送る前にバケットの空きを待つ通知先
+type rateLimitedNotifier struct {
+	Notifier
+	limiter *rateLimiter
+}
+
+// WithRateLimit : key（送信先）ごとの上限を付ける。limit.Count が0なら制限しない
+func WithRateLimit(n Notifier, key string, limit RateLimit, clk clock.Clock) Notifier {
+	if limit.Count <= 0 || limit.Window <= 0 {
+		return n
+	}
+	return &rateLimitedNotifier{Notifier: n, limiter: limiterFor(key, limit, clk)}
+}
+
+func (r *rateLimitedNotifier) Notify(ctx context.Context, n Notification) error {
+	if IsDryRun(ctx) {
+		return r.Notifier.Notify(ctx, n)
+	}
+	wait := r.limiter.reserve()
+	if wait > r.limiter.limit.MaxWait {
+		r.limiter.cancel()
+		return fmt.Errorf("%w: next slot in %s", errRateLimited, wait.Round(time.Second))
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			r.limiter.cancel()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return r.Notifier.Notify(ctx, n)
+}
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"go-logger/internal/clock"
+)
+
+// ==========================================
+// 通知先の種類の登録
+// ==========================================
+// 通知先の実装は init で種類の名前と作り方を登録する（discord.go などの末尾）
+// DB・NOTIFY_CHANNELS の通知先は「種類 + 設定」で書き、FromChannel がここから作る。
+// 新しい連携を足す時は、ファイルを1つ足して Register するだけでよい（送信の流れは変えない）
+
+// Settings : 通知先の設定（種類ごとの項目。DB の url / token / chat_id 列もここに入れて渡す）
+type Settings map[string]string
+
+// Get : 前後の空白を除いた値（なければ空）
+func (s Settings) Get(key string) string { return strings.TrimSpace(s[key]) }
+
+// Factory : 設定から通知先を作る
+// key は送信先ごとの上限 (NOTIFY_RATE_LIMITS) とブレーカーの状態を分け合う単位（Webhook URL やチャットなど）
+type Factory func(s Settings, clk clock.Clock) (n Notifier, key string, err error)
+
+// registration : 1つの種類の作り方と、API で返す時に伏せる設定の項目
+type registration struct {
+	factory Factory
+	secrets []string
+}
+
+// Registry : 種類の名前 → 作り方
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]registration
+}
+
+// NewRegistry : 空の登録先
+func NewRegistry() *Registry {
+	return &Registry{types: map[string]registration{}}
+}
+
+// Register : 種類を登録する。secrets は送信の権限そのものになる項目（API・エクスポートでは伏せる）
+// 同じ名前を2回登録するのは実装の誤りなので panic する
+func (r *Registry) Register(typ string, f Factory, secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.types[typ]; ok {
+		panic(fmt.Sprintf("notify: channel type %q registered twice", typ))
+	}
+	r.types[typ] = registration{factory: f, secrets: secrets}
+}
+
+// Types : 登録されている種類（名前順）
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.types))
+	for typ := range r.types {
+		types = append(types, typ)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Secrets : typ の設定のうち伏せる項目
+func (r *Registry) Secrets(typ string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.types[typ].secrets
+}
+
+// Build : 種類と設定から通知先を作る（未知の種類・足りない設定はエラー）
+func (r *Registry) Build(typ string, s Settings, clk clock.Clock) (Notifier, string, error) {
+	r.mu.RLock()
+	reg, ok := r.types[typ]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("unknown channel type %q (use %s)", typ, strings.Join(r.Types(), ", "))
+	}
+	return reg.factory(s, clk)
+}
+
+// DefaultRegistry : 組み込みの種類の登録先（Register で足したものも含む）
+var DefaultRegistry = NewRegistry()
+
+// Register : DefaultRegistry に種類を登録する
+func Register(typ string, f Factory, secrets ...string) {
+	DefaultRegistry.Register(typ, f, secrets...)
+}
+
+// requireHTTPS : Webhook URL の設定を確かめて返す
+func (s Settings) requireHTTPS(typ string) (string, error) {
+	u := s.Get("url")
+	if !strings.HasPrefix(u, "https://") {
+		return "", fmt.Errorf("%s channel needs an https webhook url", typ)
+	}
+	return u, nil
+}
+
+// require : 必須の項目がそろっているか
+func (s Settings) require(typ string, keys ...string) error {
+	var missing []string
+	for _, k := range keys {
+		if s.Get(k) == "" {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s channel needs %s", typ, strings.Join(missing, " and "))
+	}
+	return nil
+}
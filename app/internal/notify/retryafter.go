@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// 送信先が返す上限 (429 の Retry-After と Discord の X-RateLimit-* ヘッダー)
+// ==========================================
+
+// rateLimitRetries : 429 を受けた時に送り直す回数の上限（最初の1回を含む）
+const rateLimitRetries = 3
+
+// retryAfterMaxWait : これより長く待てと言われたら、その場では待たずに通知の再送に回す
+const retryAfterMaxWait = 30 * time.Second
+
+// RateLimitedError : 送信先に上限を超えたと言われた（RetryAfter 後なら送れる）
+type RateLimitedError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limited for %s: %v", e.RetryAfter.Round(time.Second), e.Err)
+}
+
+func (e *RateLimitedError) Unwrap() error { return e.Err }
+
+// backoffs : 送信先（URL、全体の上限ならホスト）ごとの、次に送ってよい時刻
+var backoffs sync.Map
+
+// waitBackoff : rawURL かそのホストが上限に達していれば、空くまで待つ
+func waitBackoff(ctx context.Context, rawURL string) error {
+	until := backoffUntil(rawURL)
+	if host := hostOf(rawURL); host != "" {
+		if t := backoffUntil(host); t.After(until) {
+			until = t
+		}
+	}
+	wait := time.Until(until)
+	if wait <= 0 {
+		return nil
+	}
+	if wait > retryAfterMaxWait {
+		return &RateLimitedError{RetryAfter: wait, Err: fmt.Errorf("%s is rate limited", hostOf(rawURL))}
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func backoffUntil(key string) time.Time {
+	if v, ok := backoffs.Load(key); ok {
+		return v.(time.Time)
+	}
+	return time.Time{}
+}
+
+// setBackoff : key への送信を until まで止める（既により後まで止めていればそのまま）
+func setBackoff(key string, until time.Time) {
+	if until.After(backoffUntil(key)) {
+		backoffs.Store(key, until)
+	}
+}
+
+// recordRateLimit : 応答のヘッダー・本文から上限を読み取って覚え、送り直す前に待つ時間を返す（送り直さないなら0）
+// Discord は X-RateLimit-Remaining が0になると X-RateLimit-Reset-After 秒後まで送れず、
+// 全体の上限 (X-RateLimit-Global) はホストごとにかかる。Telegram は本文の parameters.retry_after で返す
+func recordRateLimit(rawURL, host string, resp *http.Response, body []byte) time.Duration {
+	key := rawURL
+	if resp.Header.Get("X-RateLimit-Global") == "true" || resp.Header.Get("X-RateLimit-Scope") == "global" {
+		key = host
+	}
+	now := time.Now()
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset := parseSeconds(resp.Header.Get("X-RateLimit-Reset-After")); reset > 0 {
+			setBackoff(key, now.Add(reset))
+		}
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	delay := retryAfter(resp.Header, body, now)
+	if delay <= 0 {
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return 0 // Retry-After のない 503 はすぐには送り直さない
+		}
+		delay = time.Second
+	}
+	setBackoff(key, now.Add(delay))
+	return delay
+}
+
+// retryAfter : Retry-After（秒数か HTTP の日時）、なければ本文の retry_after（秒）か retry_after_ms
+func retryAfter(h http.Header, body []byte, now time.Time) time.Duration {
+	if v := strings.TrimSpace(h.Get("Retry-After")); v != "" {
+		if d := parseSeconds(v); d > 0 {
+			return d
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return t.Sub(now)
+		}
+	}
+	var payload struct {
+		RetryAfter   float64 `json:"retry_after"`    // Discord
+		RetryAfterMS float64 `json:"retry_after_ms"` // Matrix (M_LIMIT_EXCEEDED)
+		Parameters   struct {
+			RetryAfter float64 `json:"retry_after"` // Telegram
+		} `json:"parameters"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		return secondsDuration(max(payload.RetryAfter, payload.Parameters.RetryAfter, payload.RetryAfterMS/1000))
+	}
+	return 0
+}
+
+// parseSeconds : "1.5" のような秒数
+func parseSeconds(s string) time.Duration {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return secondsDuration(v)
+}
+
+func secondsDuration(v float64) time.Duration {
+	if v <= 0 || math.IsNaN(v) {
+		return 0
+	}
+	// 極端な値でも溢れないよう1日で打ち切る
+	return time.Duration(math.Ceil(min(v, 86400) * float64(time.Second)))
+}
+
+// hostOf : URL のホスト部分
+func hostOf(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
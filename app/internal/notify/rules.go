@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// レベルによる通知ルーティング
+// ==========================================
+
+// DefaultRules : 構造化ログは error 以上、それ以外（アクセス記録など）は従来通り全て通知
+const DefaultRules = "log=error,*=info"
+
+// Rules : イベント種別 → 通知する最低レベル
+// NOTIFY_LEVEL_RULES="log=error,ping=warn,custom:*=info,*=info" の形式（"custom:*" は前方一致、"*" はその他全て）
+type Rules map[string]string
+
+// RulesFromEnv : 環境変数から通知ルールを読み込む
+func RulesFromEnv() Rules {
+	return ParseRules(config.String("NOTIFY_LEVEL_RULES", DefaultRules))
+}
+
+// ParseRules : "種別=レベル" のカンマ区切りを読み込む（"off" で通知しない）
+func ParseRules(spec string) Rules {
+	rules := Rules{}
+	for _, item := range strings.Split(spec, ",") {
+		eventType, level, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			continue
+		}
+		if level = strings.TrimSpace(level); level == "off" || level == "none" {
+			rules[strings.TrimSpace(eventType)] = "off"
+			continue
+		}
+		normalized, err := model.NormalizeLevel(level)
+		if err != nil {
+			fmt.Println("Ignoring notify rule:", err)
+			continue
+		}
+		rules[strings.TrimSpace(eventType)] = normalized
+	}
+	return rules
+}
+
+// ShouldNotify : このイベントを通知するかどうか（保存は常に行う）
+func (rules Rules) ShouldNotify(eventType, level string) bool {
+	min, ok := rules[eventType]
+	if !ok {
+		min, ok = rules.prefixRule(eventType)
+	}
+	if !ok {
+		if min, ok = rules["*"]; !ok {
+			return true
+		}
+	}
+	if min == "off" {
+		return false
+	}
+	return model.LevelRank(level) >= model.LevelRank(min)
+}
+
+// prefixRule : "custom:*" のような前方一致のルールのうち、最も長く一致するもの
+func (rules Rules) prefixRule(eventType string) (string, bool) {
+	min, longest := "", -1
+	for pattern, level := range rules {
+		prefix, ok := model.EventTypePrefix(pattern)
+		if ok && prefix != "" && len(prefix) > longest && strings.HasPrefix(eventType, prefix) {
+			min, longest = level, len(prefix)
+		}
+	}
+	return min, longest >= 0
+}
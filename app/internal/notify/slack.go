@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+
+	"go-logger/internal/clock"
+)
+
+// ==========================================
+// Slack
+// ==========================================
+
+// Slack : Incoming Webhook でチャンネルに送る
+type Slack struct {
+	webhookURL string
+}
+
+func (s *Slack) Name() string { return "slack" }
+
+// slackMessage : Incoming Webhook の本文（mrkdwn を使わずプレーンテキストで送る）
+type slackMessage struct {
+	Text   string `json:"text"`
+	Mrkdwn bool   `json:"mrkdwn"`
+}
+
+func (s *Slack) Notify(ctx context.Context, n Notification) error {
+	return postJSON(ctx, s.webhookURL, slackMessage{
+		Text: fitPlainText(n.Text, slackTextLimit, n.EntryURL),
+	})
+}
+
+// NewSlack : Webhook URL を指定して作る
+func NewSlack(webhookURL string) *Slack {
+	return &Slack{webhookURL: webhookURL}
+}
+
+func init() {
+	Register("slack", func(s Settings, clk clock.Clock) (Notifier, string, error) {
+		url, err := s.requireHTTPS("slack")
+		if err != nil {
+			return nil, "", err
+		}
+		return NewSlack(url), url, nil
+	}, "url")
+}
@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"go-logger/internal/clock"
+)
+
+// ==========================================
+// Telegram
+// ==========================================
+
+// Telegram : Telegram Bot API の sendMessage でチャットに送る
+type Telegram struct {
+	botToken string
+	chatID   string
+}
+
+func (t *Telegram) Name() string { return "telegram" }
+
+// telegramMessage : sendMessage の本文
+type telegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+func (t *Telegram) Notify(ctx context.Context, n Notification) error {
+	err := postJSON(ctx, "https://api.telegram.org/bot"+t.botToken+"/sendMessage", telegramMessage{
+		ChatID:                t.chatID,
+		Text:                  fitPlainText(n.Text, telegramTextLimit, n.EntryURL),
+		DisableWebPagePreview: true,
+	})
+	// エラーメッセージにトークン入りのURLが含まれるため伏せる
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("request to Telegram API failed")
+	}
+	return err
+}
+
+// NewTelegram : Botトークンと送信先チャットを指定して作る
+func NewTelegram(botToken, chatID string) *Telegram {
+	return &Telegram{botToken: botToken, chatID: chatID}
+}
+
+func init() {
+	Register("telegram", func(s Settings, clk clock.Clock) (Notifier, string, error) {
+		if err := s.require("telegram", "token", "chat_id"); err != nil {
+			return nil, "", err
+		}
+		return NewTelegram(s.Get("token"), s.Get("chat_id")), "telegram:" + s.Get("chat_id"), nil
+	}, "token")
+}
@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 通知の本文のテンプレート
+// ==========================================
+//
+//	NOTIFY_TEMPLATE='{{.Country}} から {{.Path}} へのアクセス ({{.IP}}) UA: {{.UserAgent}}'
+//
+// ログ (model.LogEntry) の項目に加え、.Level .Title .Text（既定の本文）.EntryURL が使える
+// ログの付いた通知（アクセス・構造化ログ）だけに使い、アラートやレポートの本文はそのまま送る
+
+// templateFuncs : テンプレートで使える関数
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"truncate": func(n int, s string) string {
+		if r := []rune(s); len(r) > n {
+			return string(r[:n]) + "…"
+		}
+		return s
+	},
+}
+
+// templateData : テンプレートに渡す値
+type templateData struct {
+	model.LogEntry
+	Level    string
+	Title    string
+	Text     string
+	EntryURL string
+}
+
+// ParseTemplate : 通知の本文のテンプレートを読む（空なら nil）
+func ParseTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	t, err := template.New("notification").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	// 項目名の誤りは送る時まで分からないので、空のログで一度試す
+	if err := t.Execute(&bytes.Buffer{}, templateData{}); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return t, nil
+}
+
+// TemplateFromEnv : <種類>_TEMPLATE（DISCORD_TEMPLATE など）があればそれを、なければ NOTIFY_TEMPLATE を使う
+func TemplateFromEnv(kind string) string {
+	if t := config.String(strings.ToUpper(kind)+"_TEMPLATE", ""); t != "" {
+		return t
+	}
+	return config.String("NOTIFY_TEMPLATE", "")
+}
+
+// templatedNotifier : ログの付いた通知の本文をテンプレートで作る通知先
+type templatedNotifier struct {
+	Notifier
+	tmpl *template.Template
+}
+
+// WithTemplate : 本文のテンプレートを付ける。text が空なら付けない
+// 読めないテンプレートは表示して、既定の本文のまま送る
+func WithTemplate(n Notifier, text string) Notifier {
+	t, err := ParseTemplate(text)
+	if err != nil {
+		fmt.Printf("Ignoring template for %s notifier: %v\n", n.Name(), err)
+		return n
+	}
+	if t == nil {
+		return n
+	}
+	return &templatedNotifier{Notifier: n, tmpl: t}
+}
+
+func (t *templatedNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.Entry != nil && n.Source == "" {
+		var buf bytes.Buffer
+		err := t.tmpl.Execute(&buf, templateData{LogEntry: *n.Entry, Level: n.Level, Title: n.Title, Text: n.Text, EntryURL: n.EntryURL})
+		if err != nil {
+			fmt.Printf("Failed to render %s template, using the default text: %v\n", t.Name(), err)
+		} else {
+			n.Text, n.Templated = buf.String(), true
+		}
+	}
+	return t.Notifier.Notify(ctx, n)
+}
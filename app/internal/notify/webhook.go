@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 汎用の Webhook (type=webhook)
+// ==========================================
+// 通知をそのままJSONでPOSTする（チャット以外の受け口、自前の連携用）
+// secret を設定すると、新しいログの Webhook (/api/webhooks) と同じく
+// X-Logger-Timestamp と X-Logger-Signature: sha256=HMAC-SHA256("<timestamp>.<body>") を付ける
+
+// GenericWebhook : 任意のURLへ通知を送る
+type GenericWebhook struct {
+	clock  clock.Clock
+	url    string
+	secret string
+}
+
+func (g *GenericWebhook) Name() string { return "webhook" }
+
+// genericWebhookPayload : 送る本文
+type genericWebhookPayload struct {
+	Level    string          `json:"level"`
+	Title    string          `json:"title,omitempty"`
+	Text     string          `json:"text"`
+	Source   string          `json:"source,omitempty"`
+	EntryURL string          `json:"entry_url,omitempty"`
+	Entry    *model.LogEntry `json:"entry,omitempty"`
+}
+
+func (g *GenericWebhook) Notify(ctx context.Context, n Notification) error {
+	payload := genericWebhookPayload{Level: n.Level, Title: n.Title, Text: n.Text, Source: n.Source, EntryURL: n.EntryURL, Entry: n.Entry}
+	if g.secret == "" {
+		return postJSON(ctx, g.url, payload)
+	}
+	return postJSONWith(ctx, g.url, payload, func(h http.Header, body []byte) {
+		timestamp := strconv.FormatInt(g.clock.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(g.secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		h.Set("X-Logger-Timestamp", timestamp)
+		h.Set("X-Logger-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	})
+}
+
+// NewGenericWebhook : URL と署名の鍵（空なら署名しない）を指定して作る
+func NewGenericWebhook(url, secret string, clk clock.Clock) *GenericWebhook {
+	return &GenericWebhook{clock: clk, url: url, secret: secret}
+}
+
+func init() {
+	Register("webhook", func(s Settings, clk clock.Clock) (Notifier, string, error) {
+		url := s.Get("url")
+		if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+			return nil, "", fmt.Errorf("webhook channel needs an http(s) url")
+		}
+		return NewGenericWebhook(url, s.Get("secret"), clk), url, nil
+	}, "url", "secret")
+}
@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// ディスク (1件ずつファイルにし、再起動後も残る)
+// ==========================================
+
+// DiskBackend : Dir/<名前>/ の下にキューを置く
+type DiskBackend struct {
+	Dir string
+}
+
+func (b DiskBackend) Name() string { return "disk" }
+
+// Open : ディレクトリを作り、残っている件数を数える
+func (b DiskBackend) Open(name string, limit int) (Queue, error) {
+	return OpenDisk(filepath.Join(b.Dir, name), limit)
+}
+
+// diskPollInterval : 他のプロセスが積んだ分を確認する間隔
+const diskPollInterval = 500 * time.Millisecond
+
+// Disk : ディレクトリによるキュー
+// ファイル名は積んだ時刻と連番なので、名前順が積んだ順になる
+// 一時ファイルに書いてから名前を変えるので、途中で落ちても壊れたファイルは読まない
+type Disk struct {
+	dir   string
+	limit int
+
+	mu     sync.Mutex
+	seq    uint64
+	files  []string      // 積まれているファイル（古い順）
+	signal chan struct{} // Push したら Pop を起こす
+}
+
+// OpenDisk : dir にキューを開く（前回の残りがあれば続きから取り出す）
+func OpenDisk(dir string, limit int) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	d := &Disk{dir: dir, limit: limit, signal: make(chan struct{}, 1)}
+	if err := d.scan(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// scan : ディレクトリを読み直す（呼び出し側でロックしない）
+func (d *Disk) scan() error {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".item") {
+			files = append(files, e.Name())
+		}
+	}
+	sort.Strings(files)
+	d.mu.Lock()
+	d.files = files
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *Disk) Push(ctx context.Context, item []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.limit > 0 && len(d.files) >= d.limit {
+		return ErrFull
+	}
+	d.seq++
+	name := fmt.Sprintf("%020d-%08d.item", time.Now().UnixNano(), d.seq)
+	tmp := filepath.Join(d.dir, name+".tmp")
+	if err := os.WriteFile(tmp, item, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(d.dir, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	d.files = append(d.files, name)
+	select {
+	case d.signal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (d *Disk) TryPop(ctx context.Context) ([]byte, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for len(d.files) > 0 {
+		name := d.files[0]
+		d.files = d.files[1:]
+		path := filepath.Join(d.dir, name)
+		item, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue // 他のプロセスが取り出した
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, false, err
+		}
+		return item, true, nil
+	}
+	return nil, false, nil
+}
+
+func (d *Disk) Pop(ctx context.Context) ([]byte, error) {
+	ticker := time.NewTicker(diskPollInterval)
+	defer ticker.Stop()
+	for {
+		item, ok, err := d.TryPop(ctx)
+		if err != nil || ok {
+			return item, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-d.signal:
+		case <-ticker.C:
+			if err := d.scan(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (d *Disk) Len(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.files), nil
+}
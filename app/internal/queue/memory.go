@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"context"
+)
+
+// ==========================================
+// メモリ (再起動すると消える)
+// ==========================================
+
+// MemoryBackend : プロセス内のチャネルで持つ
+type MemoryBackend struct{}
+
+func (MemoryBackend) Name() string { return "memory" }
+
+// Open : 上限 limit 件のキューを作る
+func (MemoryBackend) Open(name string, limit int) (Queue, error) {
+	return NewMemory(limit), nil
+}
+
+// Memory : チャネルによるキュー
+type Memory struct {
+	ch chan []byte
+}
+
+// NewMemory : 上限 limit 件のキューを作る
+func NewMemory(limit int) *Memory {
+	return &Memory{ch: make(chan []byte, max(limit, 1))}
+}
+
+func (m *Memory) Push(ctx context.Context, item []byte) error {
+	select {
+	case m.ch <- item:
+		return nil
+	default:
+		return ErrFull
+	}
+}
+
+func (m *Memory) Pop(ctx context.Context) ([]byte, error) {
+	select {
+	case item := <-m.ch:
+		return item, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *Memory) TryPop(ctx context.Context) ([]byte, bool, error) {
+	select {
+	case item := <-m.ch:
+		return item, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+func (m *Memory) Len(ctx context.Context) (int, error) { return len(m.ch), nil }
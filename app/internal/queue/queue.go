@@ -0,0 +1,52 @@
+// Package queue : 非同期処理のキュー (メモリ・ディスク・Redis を設定で切り替える)
+// 通知の送信待ちと、DB再接続中の書き込みの退避に使う
+//
+//	memory  再起動すると消える（既定）
+//	disk    QUEUE_DIR にファイルとして残り、再起動後に続きから処理する
+//	redis   REDIS_URL に置き、複数のインスタンスで共有できる
+//
+// 再接続中の書き込みだけは、WAL_DIR を設定すると WAL（追記して1件ごとに fsync するファイル）に置ける
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-logger/internal/config"
+)
+
+// ErrFull : キューが上限に達している
+var ErrFull = errors.New("queue is full")
+
+// Queue : 先入れ先出しのキュー1つ分（中身はJSONなどにした []byte）
+type Queue interface {
+	// Push : 末尾に積む（上限なら ErrFull）
+	Push(ctx context.Context, item []byte) error
+	// Pop : 先頭を取り出す。空なら積まれるか ctx が終わるまで待つ
+	Pop(ctx context.Context) ([]byte, error)
+	// TryPop : 先頭を取り出す。空なら待たずに ok=false を返す
+	TryPop(ctx context.Context) (item []byte, ok bool, err error)
+	// Len : 積まれている件数
+	Len(ctx context.Context) (int, error)
+}
+
+// Backend : 名前を付けてキューを開く
+type Backend interface {
+	Name() string
+	Open(name string, limit int) (Queue, error)
+}
+
+// FromEnv : QUEUE_BACKEND（memory / disk / redis、既定 memory）から作る
+func FromEnv() (Backend, error) {
+	switch kind := config.String("QUEUE_BACKEND", "memory"); kind {
+	case "memory":
+		return MemoryBackend{}, nil
+	case "disk":
+		return DiskBackend{Dir: config.String("QUEUE_DIR", "./queue")}, nil
+	case "redis":
+		return NewRedisBackend(config.String("REDIS_URL", "redis://localhost:6379/0"))
+	default:
+		return nil, fmt.Errorf("unknown QUEUE_BACKEND %q (use memory, disk or redis)", kind)
+	}
+}
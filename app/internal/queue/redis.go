@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ==========================================
+// Redis (リストをキューにし、複数のインスタンスで共有する)
+// ==========================================
+
+// RedisBackend : "go-logger:queue:<名前>" のリストにキューを置く
+type RedisBackend struct {
+	client *redis.Client
+}
+
+// NewRedisBackend : redis://[:password@]host:port/db の形式のURLで接続する
+func NewRedisBackend(url string) (*RedisBackend, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisBackend{client: redis.NewClient(opts)}, nil
+}
+
+func (b *RedisBackend) Name() string { return "redis" }
+
+// Open : リスト1つをキューとして使う（接続は最初の操作で確かめる）
+func (b *RedisBackend) Open(name string, limit int) (Queue, error) {
+	return &Redis{client: b.client, key: "go-logger:queue:" + name, limit: limit}, nil
+}
+
+// Redis : LPUSH で積み、RPOP / BRPOP で取り出すキュー
+type Redis struct {
+	client *redis.Client
+	key    string
+	limit  int
+}
+
+// redisPopTimeout : BRPOP の待ち時間（ctx の終了を確認するため、この間隔で戻る）
+const redisPopTimeout = 5 * time.Second
+
+func (q *Redis) Push(ctx context.Context, item []byte) error {
+	if q.limit > 0 {
+		n, err := q.client.LLen(ctx, q.key).Result()
+		if err != nil {
+			return err
+		}
+		if n >= int64(q.limit) {
+			return ErrFull
+		}
+	}
+	return q.client.LPush(ctx, q.key, item).Err()
+}
+
+func (q *Redis) Pop(ctx context.Context) ([]byte, error) {
+	for {
+		res, err := q.client.BRPop(ctx, redisPopTimeout, q.key).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		return []byte(res[1]), nil
+	}
+}
+
+func (q *Redis) TryPop(ctx context.Context) ([]byte, bool, error) {
+	item, err := q.client.RPop(ctx, q.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return item, true, nil
+}
+
+func (q *Redis) Len(ctx context.Context) (int, error) {
+	n, err := q.client.LLen(ctx, q.key).Result()
+	return int(n), err
+}
@@ -0,0 +1,253 @@
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ==========================================
+// WAL (1つのファイルに追記し、1件ごとに fsync する)
+// ==========================================
+// DB の再接続中の書き込みの退避用。Push が返った時点でディスクに書かれているので、その後に落ちても失われない
+//
+//	<名前>.wal     [長さ 4バイト][CRC32 4バイト][本文] を追記する
+//	<名前>.offset  次に読むレコードの位置（読み終えるたびに fsync する）
+//
+// 先頭は Peek で読み、書き戻せてから Ack で進める（失敗しても次の回に同じものから順に送る）
+// 全て読み終えたらファイルを空にする。最後のレコードが書きかけ（ヘッダーだけ・CRC 不一致）なら開く時に切り捨てる
+
+// walHeaderSize : レコードの前に付ける長さと CRC32
+const walHeaderSize = 8
+
+// walMaxRecord : 1件の上限（壊れたヘッダーで巨大な領域を読まないため）
+const walMaxRecord = 16 << 20
+
+// WALBackend : Dir/<名前>.wal にキューを置く
+type WALBackend struct {
+	Dir string
+}
+
+func (b WALBackend) Name() string { return "wal" }
+
+// Open : ファイルを開き、前回読み終えた位置から残りを数える
+func (b WALBackend) Open(name string, limit int) (Queue, error) {
+	return OpenWAL(filepath.Join(b.Dir, name), limit)
+}
+
+// Peeker : 先頭を取り除かずに読めるキュー（処理に失敗しても順序が崩れない）
+type Peeker interface {
+	// Peek : 先頭を読む。空なら ok=false
+	Peek(ctx context.Context) (item []byte, ok bool, err error)
+	// Ack : Peek で読んだ先頭を取り除く
+	Ack(ctx context.Context) error
+}
+
+// WAL : 追記するファイルによるキュー（1つのプロセスだけが開く）
+type WAL struct {
+	limit int
+
+	mu     sync.Mutex
+	log    *os.File
+	meta   *os.File
+	offset int64 // 次に読むレコードの位置
+	size   int64 // ファイルの末尾
+	count  int   // 読んでいないレコードの数
+	signal chan struct{}
+}
+
+// OpenWAL : path.wal と path.offset を開く（なければ作る）
+func OpenWAL(path string, limit int) (*WAL, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(path+".wal", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := os.OpenFile(path+".offset", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	w := &WAL{limit: limit, log: log, meta: meta, signal: make(chan struct{}, 1)}
+	if err := w.recover(); err != nil {
+		log.Close()
+		meta.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// recover : 読み終えた位置を読み、その先のレコードを数える（書きかけの末尾は切り捨てる）
+func (w *WAL) recover() error {
+	info, err := w.log.Stat()
+	if err != nil {
+		return err
+	}
+	w.size = info.Size()
+	var buf [8]byte
+	if _, err := w.meta.ReadAt(buf[:], 0); err == nil {
+		w.offset = int64(binary.BigEndian.Uint64(buf[:]))
+	} else if !errors.Is(err, io.EOF) {
+		return err
+	}
+	// 空にした直後に落ちた場合は位置が末尾を超えている
+	// 0 に戻したことも fsync しておく（古い位置が残ったまま追記し、もう一度落ちると読み始める位置がずれるため）
+	if w.offset > w.size {
+		if err := w.setOffset(0); err != nil {
+			return err
+		}
+	}
+	for pos := w.offset; pos < w.size; {
+		_, next, err := w.readAt(pos)
+		if err != nil {
+			fmt.Printf("Truncating %s at byte %d: %v\n", w.log.Name(), pos, err)
+			if err := w.log.Truncate(pos); err != nil {
+				return err
+			}
+			w.size = pos
+			return w.log.Sync()
+		}
+		w.count++
+		pos = next
+	}
+	return nil
+}
+
+// readAt : pos のレコードを読み、本文と次のレコードの位置を返す
+func (w *WAL) readAt(pos int64) ([]byte, int64, error) {
+	var header [walHeaderSize]byte
+	if _, err := w.log.ReadAt(header[:], pos); err != nil {
+		return nil, 0, fmt.Errorf("incomplete record header: %w", err)
+	}
+	n := binary.BigEndian.Uint32(header[:4])
+	if n > walMaxRecord {
+		return nil, 0, fmt.Errorf("invalid record length %d", n)
+	}
+	item := make([]byte, n)
+	if _, err := w.log.ReadAt(item, pos+walHeaderSize); err != nil {
+		return nil, 0, fmt.Errorf("incomplete record: %w", err)
+	}
+	if crc32.ChecksumIEEE(item) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, 0, errors.New("checksum mismatch")
+	}
+	return item, pos + walHeaderSize + int64(n), nil
+}
+
+// setOffset : 読み終えた位置を書いて fsync する
+func (w *WAL) setOffset(offset int64) error {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(offset))
+	if _, err := w.meta.WriteAt(buf[:], 0); err != nil {
+		return err
+	}
+	if err := w.meta.Sync(); err != nil {
+		return err
+	}
+	w.offset = offset
+	return nil
+}
+
+func (w *WAL) Push(ctx context.Context, item []byte) error {
+	if len(item) > walMaxRecord {
+		return fmt.Errorf("item of %d bytes exceeds the WAL record limit", len(item))
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.limit > 0 && w.count >= w.limit {
+		return ErrFull
+	}
+	record := make([]byte, walHeaderSize+len(item))
+	binary.BigEndian.PutUint32(record[:4], uint32(len(item)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(item))
+	copy(record[walHeaderSize:], item)
+	if _, err := w.log.Write(record); err != nil {
+		return err
+	}
+	if err := w.log.Sync(); err != nil {
+		return err
+	}
+	w.size += int64(len(record))
+	w.count++
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (w *WAL) Peek(ctx context.Context) ([]byte, bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.count == 0 {
+		return nil, false, nil
+	}
+	item, _, err := w.readAt(w.offset)
+	if err != nil {
+		return nil, false, err
+	}
+	return item, true, nil
+}
+
+func (w *WAL) Ack(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.count == 0 {
+		return nil
+	}
+	_, next, err := w.readAt(w.offset)
+	if err != nil {
+		return err
+	}
+	if err := w.setOffset(next); err != nil {
+		return err
+	}
+	w.count--
+	if w.count > 0 {
+		return nil
+	}
+	// 全て読み終えたら空にする（位置を戻す前に落ちても、開く時に末尾を超えた位置として 0 に戻る）
+	if err := w.log.Truncate(0); err != nil {
+		return err
+	}
+	if err := w.log.Sync(); err != nil {
+		return err
+	}
+	w.size = 0
+	return w.setOffset(0)
+}
+
+func (w *WAL) TryPop(ctx context.Context) ([]byte, bool, error) {
+	item, ok, err := w.Peek(ctx)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return item, true, w.Ack(ctx)
+}
+
+func (w *WAL) Pop(ctx context.Context) ([]byte, error) {
+	for {
+		item, ok, err := w.TryPop(ctx)
+		if err != nil || ok {
+			return item, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-w.signal:
+		}
+	}
+}
+
+func (w *WAL) Len(ctx context.Context) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count, nil
+}
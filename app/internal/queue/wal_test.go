@@ -0,0 +1,145 @@
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// crash : 片付けをせずにファイルだけ閉じる（プロセスが落ちた時と同じく、fsync 済みの内容だけが残る）
+func crash(t *testing.T, w *WAL) {
+	t.Helper()
+	w.log.Close()
+	w.meta.Close()
+}
+
+// reopen : 落ちたものとして閉じ、同じファイルを開き直す
+func reopen(t *testing.T, w *WAL, path string) *WAL {
+	t.Helper()
+	crash(t, w)
+	w, err := OpenWAL(path, 0)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	t.Cleanup(func() { crash(t, w) })
+	return w
+}
+
+// drain : 残っているものを全て順に読む
+func drain(t *testing.T, w *WAL) []string {
+	t.Helper()
+	var items []string
+	for {
+		item, ok, err := w.TryPop(context.Background())
+		if err != nil {
+			t.Fatalf("pop: %v", err)
+		}
+		if !ok {
+			return items
+		}
+		items = append(items, string(item))
+	}
+}
+
+func push(t *testing.T, w *WAL, items ...string) {
+	t.Helper()
+	for _, item := range items {
+		if err := w.Push(context.Background(), []byte(item)); err != nil {
+			t.Fatalf("push %q: %v", item, err)
+		}
+	}
+}
+
+// writeOffset : .offset を直接書く（Ack の途中で落ちた状態を作る）
+func writeOffset(t *testing.T, path string, offset int64) {
+	t.Helper()
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(offset))
+	if err := os.WriteFile(path+".offset", buf[:], 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestWALReopen : 開き直しても、Ack していないものは順番どおり残る
+func TestWALReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buffer")
+	w, err := OpenWAL(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push(t, w, "a", "b", "c")
+	if item, _, _ := w.TryPop(context.Background()); string(item) != "a" {
+		t.Fatalf("first pop %q", item)
+	}
+
+	w = reopen(t, w, path)
+	if n, _ := w.Len(context.Background()); n != 2 {
+		t.Fatalf("len after reopen %d, want 2", n)
+	}
+	if got := fmt.Sprint(drain(t, w)); got != "[b c]" {
+		t.Fatalf("items after reopen %s", got)
+	}
+
+	// 全て読み終えて空にした後も、追記と開き直しができる
+	push(t, w, "d")
+	w = reopen(t, w, path)
+	if got := fmt.Sprint(drain(t, w)); got != "[d]" {
+		t.Fatalf("items after emptying %s", got)
+	}
+}
+
+// TestWALCrashAfterTruncate : 空にした後、位置を 0 に戻す前に落ちても、その後の追記を取りこぼさない
+func TestWALCrashAfterTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buffer")
+	w, err := OpenWAL(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push(t, w, "first record", "second record")
+	drain(t, w)
+
+	// Ack の log.Truncate(0) と setOffset(0) の間で落ちた（古い位置が残る）
+	crash(t, w)
+	writeOffset(t, path, 40)
+	w, err = OpenWAL(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 古い位置より先まで追記してから、Ack せずにもう一度落ちる
+	push(t, w, "one", "two", "three", "four")
+	w = reopen(t, w, path)
+	if got := fmt.Sprint(drain(t, w)); got != "[one two three four]" {
+		t.Fatalf("items after second crash %s", got)
+	}
+}
+
+// TestWALTornRecord : 書きかけの末尾のレコードは開く時に切り捨て、その前までは読める
+func TestWALTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buffer")
+	w, err := OpenWAL(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push(t, w, "kept", "torn")
+	info, err := os.Stat(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	crash(t, w)
+	if err := os.Truncate(path+".wal", info.Size()-2); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err = OpenWAL(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { crash(t, w) })
+	if got := fmt.Sprint(drain(t, w)); got != "[kept]" {
+		t.Fatalf("items after torn write %s", got)
+	}
+}
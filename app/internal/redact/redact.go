@@ -0,0 +1,238 @@
+// Package redact : ログの項目ごとの表示ルール (REST・GraphQL・エクスポート・通知の全ての出力で共通に使う)
+// 項目を追加しても、出力のたびに個別に隠す処理を書かずに済むよう、ルールは LogEntry の JSON 名で指定する
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+)
+
+// Placeholder : 隠した文字列の項目に入れる値
+const Placeholder = "[redacted]"
+
+// Scope : 出力先の閲覧範囲（大きいほど多くの項目が見える）
+type Scope int
+
+const (
+	Public Scope = iota // 認証なしの閲覧（DASHBOARD_USERS が未設定の画面と読み出しAPI）
+	Key                 // プロジェクトキー付きの読み出し（他サービス・PEERS）
+	User                // ダッシュボードにログインしたユーザー
+	Admin               // ADMIN_TOKEN
+	Never               // どの出力にも出さない（ルールでだけ使う）
+)
+
+var scopeNames = []string{"public", "key", "user", "admin", "never"}
+
+func (s Scope) String() string {
+	if int(s) < len(scopeNames) {
+		return scopeNames[s]
+	}
+	return fmt.Sprintf("scope(%d)", int(s))
+}
+
+// ParseScope : "public" / "key" / "user" / "admin" / "never"
+func ParseScope(name string) (Scope, error) {
+	for i, n := range scopeNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return Scope(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown scope %q (use %s)", name, strings.Join(scopeNames, ", "))
+}
+
+// rule : 項目1つを見るのに必要な範囲
+type rule struct {
+	field string // LogEntry の JSON 名
+	key   string // field が "fields" の場合の fields のキー（空なら fields 全体）
+	index int    // LogEntry の中の位置
+	min   Scope
+}
+
+// Policy : 表示ルール一式（nil ならどの項目も隠さない）
+type Policy struct {
+	rules []rule
+
+	NotifyScope Scope // 通知・チャットコマンドの返答に使う範囲
+	ExportScope Scope // Kafka / NATS・アーカイブ・スナップショットに使う範囲
+}
+
+// entryFields : LogEntry の JSON 名 → 位置
+var entryFields = func() map[string]int {
+	fields := map[string]int{}
+	t := reflect.TypeOf(model.LogEntry{})
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}()
+
+// fixedFields : 隠すとページングや並び順が壊れる項目
+var fixedFields = map[string]bool{"id": true, "created_at": true}
+
+// Parse : "ip=user,user_agent=key,fields.email=admin,fields.token=never" の形式
+// 項目名は読み出しAPIの JSON 名（fields の中のキーは fields.<キー>）
+func Parse(spec string) (*Policy, error) {
+	p := &Policy{NotifyScope: User, ExportScope: Admin}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, scope, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid redaction rule %q (use field=scope)", item)
+		}
+		min, err := ParseScope(scope)
+		if err != nil {
+			return nil, fmt.Errorf("redaction rule %q: %w", item, err)
+		}
+		r := rule{field: strings.TrimSpace(name), min: min}
+		if field, key, ok := strings.Cut(r.field, "."); ok {
+			r.field, r.key = field, key
+			if field != "fields" || key == "" {
+				return nil, fmt.Errorf("redaction rule %q: only fields.<key> can name a nested value", item)
+			}
+		}
+		index, known := entryFields[r.field]
+		if !known {
+			return nil, fmt.Errorf("redaction rule %q: unknown field %q", item, r.field)
+		}
+		if fixedFields[r.field] {
+			return nil, fmt.Errorf("redaction rule %q: %s cannot be redacted", item, r.field)
+		}
+		r.index = index
+		p.rules = append(p.rules, r)
+	}
+	return p, nil
+}
+
+// FromEnv : 環境変数から読み込む（REDACT_RULES が未設定なら nil）
+//
+//	REDACT_RULES         項目=範囲 のカンマ区切り（例: ip=user,fields.email=admin）
+//	REDACT_NOTIFY_SCOPE  通知に使う範囲（既定 user）
+//	REDACT_EXPORT_SCOPE  エクスポートに使う範囲（既定 admin）
+func FromEnv() (*Policy, error) {
+	spec := config.String("REDACT_RULES", "")
+	if spec == "" {
+		return nil, nil
+	}
+	p, err := Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	for key, dst := range map[string]*Scope{"REDACT_NOTIFY_SCOPE": &p.NotifyScope, "REDACT_EXPORT_SCOPE": &p.ExportScope} {
+		if raw := config.String(key, ""); raw != "" {
+			if *dst, err = ParseScope(raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	}
+	return p, nil
+}
+
+// Entry : scope から見えない項目を隠した e のコピー
+func (p *Policy) Entry(e model.LogEntry, scope Scope) model.LogEntry {
+	if p == nil {
+		return e
+	}
+	v := reflect.ValueOf(&e).Elem()
+	var hiddenKeys []string
+	for _, r := range p.rules {
+		if scope >= r.min {
+			continue
+		}
+		if r.key != "" {
+			hiddenKeys = append(hiddenKeys, r.key)
+			continue
+		}
+		f := v.Field(r.index)
+		if f.Kind() == reflect.String && f.String() != "" {
+			f.SetString(Placeholder)
+		} else {
+			f.SetZero()
+		}
+	}
+	if len(hiddenKeys) > 0 && len(e.Fields) > 0 {
+		e.Fields = redactFields(e.Fields, hiddenKeys)
+	}
+	return e
+}
+
+// Entries : Entry を全件に適用する（entries をそのまま書き換える）
+func (p *Policy) Entries(entries []model.LogEntry, scope Scope) []model.LogEntry {
+	if p == nil {
+		return entries
+	}
+	for i := range entries {
+		entries[i] = p.Entry(entries[i], scope)
+	}
+	return entries
+}
+
+// Hidden : field（LogEntry の JSON 名）が scope から見えないか（集計のキーに使う列を確かめる）
+func (p *Policy) Hidden(field string, scope Scope) bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range p.rules {
+		if r.field == field && r.key == "" && scope < r.min {
+			return true
+		}
+	}
+	return false
+}
+
+// EntryPtr : nil を許す Entry（通知の Entry 用。元のログは書き換えない）
+func (p *Policy) EntryPtr(e *model.LogEntry, scope Scope) *model.LogEntry {
+	if p == nil || e == nil {
+		return e
+	}
+	redacted := p.Entry(*e, scope)
+	return &redacted
+}
+
+// redactFields : fields の中の keys の値を Placeholder にする（JSON として読めなければ全体を隠す）
+func redactFields(raw json.RawMessage, keys []string) json.RawMessage {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil
+	}
+	changed := false
+	for _, k := range keys {
+		if _, ok := obj[k]; ok {
+			obj[k] = json.RawMessage(`"` + Placeholder + `"`)
+			changed = true
+		}
+	}
+	if !changed {
+		return raw
+	}
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// scopeKey : リクエストの閲覧範囲を入れるコンテキストキー
+type scopeKey struct{}
+
+// WithScope : 閲覧範囲をコンテキストに入れる（GraphQL のリゾルバなど、リクエストを直接見られない所で使う）
+func WithScope(ctx context.Context, scope Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// ScopeFrom : WithScope で入れた範囲（なければ Public）
+func ScopeFrom(ctx context.Context) Scope {
+	scope, _ := ctx.Value(scopeKey{}).(Scope)
+	return scope
+}
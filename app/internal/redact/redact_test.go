@@ -0,0 +1,133 @@
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go-logger/internal/model"
+)
+
+// TestParse : 不正なルールは読み込まない
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: ""},
+		{spec: "ip=user, user_agent=key ,fields.email=admin,fields.token=never"},
+		{spec: "ip", wantErr: true},
+		{spec: "ip=owner", wantErr: true},
+		{spec: "hostname=user", wantErr: true},
+		{spec: "id=admin", wantErr: true},
+		{spec: "created_at=admin", wantErr: true},
+		{spec: "path.query=admin", wantErr: true},
+		{spec: "fields.=admin", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q): err = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
+
+// TestEntry : 範囲ごとに見える項目だけが残る
+func TestEntry(t *testing.T) {
+	p, err := Parse("ip=user,user_agent=key,country=admin,fields.email=admin,fields.token=never")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := model.LogEntry{
+		ID:        7,
+		IP:        "203.0.113.5",
+		UserAgent: "Mozilla/5.0",
+		Country:   "JP",
+		Path:      "/checkout",
+		Fields:    json.RawMessage(`{"email":"a@example.com","order":42,"token":"secret"}`),
+	}
+	hidden := `"` + Placeholder + `"`
+
+	tests := []struct {
+		scope                  Scope
+		ip, userAgent, country string
+		email, token           string
+	}{
+		{scope: Public, ip: Placeholder, userAgent: Placeholder, country: Placeholder, email: hidden, token: hidden},
+		{scope: Key, ip: Placeholder, userAgent: "Mozilla/5.0", country: Placeholder, email: hidden, token: hidden},
+		{scope: User, ip: "203.0.113.5", userAgent: "Mozilla/5.0", country: Placeholder, email: hidden, token: hidden},
+		{scope: Admin, ip: "203.0.113.5", userAgent: "Mozilla/5.0", country: "JP", email: `"a@example.com"`, token: hidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope.String(), func(t *testing.T) {
+			got := p.Entry(entry, tt.scope)
+			if got.IP != tt.ip || got.UserAgent != tt.userAgent || got.Country != tt.country {
+				t.Errorf("ip %q, user_agent %q, country %q", got.IP, got.UserAgent, got.Country)
+			}
+			if got.ID != entry.ID || got.Path != entry.Path {
+				t.Errorf("unredacted fields changed: id %d, path %q", got.ID, got.Path)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(got.Fields, &fields); err != nil {
+				t.Fatalf("fields %s: %v", got.Fields, err)
+			}
+			if string(fields["email"]) != tt.email || string(fields["token"]) != tt.token || string(fields["order"]) != "42" {
+				t.Errorf("fields %s", got.Fields)
+			}
+		})
+	}
+	if entry.IP != "203.0.113.5" || string(entry.Fields) != `{"email":"a@example.com","order":42,"token":"secret"}` {
+		t.Errorf("Entry modified the original: %+v", entry)
+	}
+}
+
+// TestEntryUnreadableFields : JSON として読めない fields は、キーを隠すルールがあれば全体を隠す
+func TestEntryUnreadableFields(t *testing.T) {
+	p, err := Parse("fields.email=admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := p.Entry(model.LogEntry{Fields: json.RawMessage(`not json`)}, Public)
+	if got.Fields != nil {
+		t.Errorf("fields = %s, want nil", got.Fields)
+	}
+}
+
+// TestNilPolicy : ルールがなければ何も隠さない
+func TestNilPolicy(t *testing.T) {
+	var p *Policy
+	entry := model.LogEntry{IP: "203.0.113.5"}
+	if got := p.Entry(entry, Public); got.IP != entry.IP {
+		t.Errorf("ip %q", got.IP)
+	}
+	if p.Hidden("ip", Public) {
+		t.Error("nil policy hides ip")
+	}
+	if p.EntryPtr(nil, Public) != nil {
+		t.Error("EntryPtr(nil) != nil")
+	}
+}
+
+// TestHidden : fields.<キー> のルールは列全体を隠したことにしない
+func TestHidden(t *testing.T) {
+	p, err := Parse("ip=user,fields.email=never")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		field string
+		scope Scope
+		want  bool
+	}{
+		{"ip", Public, true},
+		{"ip", Key, true},
+		{"ip", User, false},
+		{"ip", Admin, false},
+		{"fields", Public, false},
+		{"path", Public, false},
+	}
+	for _, tt := range tests {
+		if got := p.Hidden(tt.field, tt.scope); got != tt.want {
+			t.Errorf("Hidden(%q, %s) = %v, want %v", tt.field, tt.scope, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// このサーバー自身へのアクセスの記録 (ACCESS_LOG とメトリクス)
+// ==========================================
+
+var (
+	httpRequestsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "go_logger_http_requests_total",
+		Help: "HTTP requests served, by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+	httpDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "go_logger_http_request_duration_seconds",
+		Help:    "HTTP request latency, by method and route pattern.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	httpResponseBytesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "go_logger_http_response_bytes_total",
+		Help: "HTTP response body bytes written (after compression), by method and route pattern.",
+	}, []string{"method", "route"})
+)
+
+// accessLogFromEnv : ACCESS_LOG（off / text / json。既定 text）
+func accessLogFromEnv() string {
+	mode := strings.ToLower(config.String("ACCESS_LOG", "text"))
+	switch mode {
+	case "off", "text", "json":
+		return mode
+	}
+	fmt.Printf("Unknown ACCESS_LOG %q, using text\n", mode)
+	return "text"
+}
+
+// accessLogSkipFromEnv : ACCESS_LOG_SKIP（カンマ区切りのパスの接頭辞。未設定なら /metrics）
+func accessLogSkipFromEnv() []string {
+	if config.String("ACCESS_LOG_SKIP", "") == "" {
+		return []string{"/metrics"}
+	}
+	return config.List("ACCESS_LOG_SKIP")
+}
+
+// accessRecord : ACCESS_LOG=json の1行
+type accessRecord struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Route      string    `json:"route"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMS float64   `json:"duration_ms"`
+	IP         string    `json:"ip"`
+}
+
+// routeKey : ルートのパターンを外側のミドルウェアへ返すためのコンテキストのキー
+type routeKey struct{}
+
+// withRoute : 振り分け先のパターン（"GET /api/logs" など）を記録してから mux に渡す
+// メトリクスのラベルを実際のパスにすると種類が増え続けるので、パターンでまとめる
+func withRoute(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			_, *route = mux.Handler(r)
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// accessLog : 全てのルート（画面のファイルを含む）のメソッド・パス・状態コード・バイト数・処理時間を
+// メトリクスに数え、ACCESS_LOG=text / json なら1行ずつ出力する
+// ACCESS_LOG_SKIP のパス（既定 /metrics）で始まるものは出力しない（メトリクスには数える）
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := s.clock.Now()
+		route := ""
+		rec := &accessWriter{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), routeKey{}, &route)))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		elapsed := s.clock.Now().Sub(start)
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequestsCounter.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		httpDurationHistogram.WithLabelValues(r.Method, route).Observe(elapsed.Seconds())
+		httpResponseBytesCounter.WithLabelValues(r.Method, route).Add(float64(rec.bytes))
+
+		if s.cfg.AccessLog == "off" || s.cfg.AccessLog == "" {
+			return
+		}
+		for _, prefix := range s.cfg.AccessLogSkip {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				return
+			}
+		}
+		entry := accessRecord{
+			Time: start, Method: r.Method, Path: r.URL.Path, Route: route, Status: rec.status,
+			Bytes: rec.bytes, DurationMS: float64(elapsed.Microseconds()) / 1000, IP: clientIP(r),
+		}
+		if s.cfg.AccessLog == "json" {
+			line, _ := json.Marshal(entry)
+			fmt.Println(string(line))
+			return
+		}
+		fmt.Printf("access: %s %s %d %dB %s route=%q ip=%s\n",
+			entry.Method, entry.Path, entry.Status, entry.Bytes, elapsed.Round(time.Microsecond), entry.Route, entry.IP)
+	})
+}
+
+// accessWriter : 状態コードと書いたバイト数を数える
+type accessWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *accessWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *accessWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,566 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// アラートルール (DBに保存し、常に評価する)
+// ==========================================
+
+// ruleSampleSize : 通知に載せる該当ログの件数
+const ruleSampleSize = 3
+
+// ruleFields : match ルールで調べられる項目
+var ruleFields = map[string]func(e *model.LogEntry) string{
+	"user_agent": func(e *model.LogEntry) string { return e.UserAgent },
+	"path":       func(e *model.LogEntry) string { return e.Path },
+	"referrer":   func(e *model.LogEntry) string { return e.Referrer },
+	"message":    func(e *model.LogEntry) string { return e.Message },
+	"ip":         func(e *model.LogEntry) string { return e.IP },
+	"country":    func(e *model.LogEntry) string { return e.Country },
+	"browser":    func(e *model.LogEntry) string { return e.Browser },
+	"os":         func(e *model.LogEntry) string { return e.OS },
+}
+
+// compiledRule : 正規表現と検索式をコンパイル済みのルール
+type compiledRule struct {
+	model.AlertRule
+	re    *regexp.Regexp
+	query *store.Query
+}
+
+// filter : 件数を数える範囲（直近 window_seconds 秒）
+func (r compiledRule) filter(now time.Time) store.LogFilter {
+	return store.LogFilter{
+		ProjectID: r.ProjectID,
+		EventType: r.EventType,
+		MinLevel:  r.MinLevel,
+		Query:     r.query,
+		Since:     now.Add(-time.Duration(r.WindowSeconds) * time.Second),
+	}
+}
+
+// ruleState : 読み込んだルールと、ルールごとの最後の通知日時
+type ruleState struct {
+	mu     sync.Mutex
+	rules  []compiledRule
+	fired  map[int]time.Time
+	silent map[int]bool // このインスタンスが止まっていると判定した heartbeat ルール
+}
+
+// setSilent : heartbeat ルールの状態を記録し、止まっていた状態から戻ったら true を返す
+func (rs *ruleState) setSilent(id int, silent bool) (recovered bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	recovered = rs.silent[id] && !silent
+	if silent {
+		rs.silent[id] = true
+	} else {
+		delete(rs.silent, id)
+	}
+	return recovered
+}
+
+// tryFire : クールダウン中でなければ通知日時を記録して true を返す
+func (rs *ruleState) tryFire(r model.AlertRule, now time.Time) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if last, ok := rs.fired[r.ID]; ok && now.Sub(last) < time.Duration(r.CooldownSeconds)*time.Second {
+		return false
+	}
+	rs.fired[r.ID] = now
+	return true
+}
+
+// snapshot : 評価に使うルールの一覧
+func (rs *ruleState) snapshot() []compiledRule {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.rules
+}
+
+// compileRule : ルールを検証してコンパイルする
+func compileRule(r model.AlertRule) (compiledRule, error) {
+	c := compiledRule{AlertRule: r}
+	if strings.TrimSpace(r.Name) == "" {
+		return c, errors.New(`"name" is required`)
+	}
+	if r.MinLevel != "" {
+		if _, err := model.NormalizeLevel(r.MinLevel); err != nil {
+			return c, fmt.Errorf("min_level: %w", err)
+		}
+	}
+	if _, err := model.NormalizeLevel(r.Level); err != nil {
+		return c, fmt.Errorf("level: %w", err)
+	}
+	if r.CooldownSeconds < 0 {
+		return c, errors.New("cooldown_seconds must not be negative")
+	}
+	if r.Query != "" {
+		q, err := store.ParseQuery(r.Query)
+		if err != nil {
+			return c, fmt.Errorf("query: %w", err)
+		}
+		c.query = q
+	}
+	switch r.Kind {
+	case "threshold":
+		if r.Threshold <= 0 || r.WindowSeconds <= 0 {
+			return c, errors.New("threshold rules need a positive threshold and window_seconds")
+		}
+	case "heartbeat":
+		if r.WindowSeconds <= 0 {
+			return c, errors.New("heartbeat rules need a positive window_seconds")
+		}
+	case "match":
+		if _, ok := ruleFields[r.Field]; !ok {
+			return c, fmt.Errorf("unknown field %q for match rule", r.Field)
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil || r.Pattern == "" {
+			return c, fmt.Errorf("invalid pattern %q: %v", r.Pattern, err)
+		}
+		c.re = re
+	default:
+		return c, fmt.Errorf("unknown kind %q (use threshold, match or heartbeat)", r.Kind)
+	}
+	return c, nil
+}
+
+// reloadRules : 有効なルールをDBから読み直す（不正なルールは飛ばす）
+func (s *Server) reloadRules(ctx context.Context) error {
+	rules, err := s.store.ListAlertRules(ctx)
+	if err != nil {
+		return err
+	}
+	var compiled []compiledRule
+	s.rules.mu.Lock()
+	for _, r := range rules {
+		if !r.Enabled {
+			continue
+		}
+		c, err := compileRule(r)
+		if err != nil {
+			fmt.Printf("Skipping alert rule %d (%s): %v\n", r.ID, r.Name, err)
+			continue
+		}
+		// 他のインスタンスや再起動前に通知した分もクールダウンに含める
+		if r.LastFiredAt != nil && r.LastFiredAt.After(s.rules.fired[r.ID]) {
+			s.rules.fired[r.ID] = *r.LastFiredAt
+		}
+		compiled = append(compiled, c)
+	}
+	s.rules.rules = compiled
+	s.rules.mu.Unlock()
+	return nil
+}
+
+// watchRules : ルール・ミュート・除外パターン・IPルールを読み直し、件数のルールを評価する
+func (s *Server) watchRules(ctx context.Context) {
+	ticker := s.clock.NewTicker(s.cfg.RuleEvalInterval)
+	defer ticker.Stop()
+	j := s.jobs.register("rules", s.cfg.RuleEvalInterval)
+
+	for {
+		s.runJob(j, func() error { return s.evaluateRules(ctx) })
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// evaluateRules : 1回分の読み直しと評価（失敗はそれぞれログに出し、最後のものを返す）
+func (s *Server) evaluateRules(ctx context.Context) error {
+	last := s.reloadResources(ctx)
+	for _, r := range s.rules.snapshot() {
+		var err error
+		switch r.Kind {
+		case "threshold":
+			err = s.evaluateThreshold(ctx, r)
+		case "heartbeat":
+			err = s.evaluateHeartbeat(ctx, r)
+		}
+		if err != nil {
+			fmt.Printf("Failed to evaluate alert rule %q: %v\n", r.Name, err)
+			last = err
+		}
+	}
+	return last
+}
+
+// reloadResources : DBで管理するルール・ミュート・除外・設定などを読み直す（失敗はそれぞれログに出し、最後のものを返す）
+func (s *Server) reloadResources(ctx context.Context) error {
+	var last error
+	if err := s.reloadRules(ctx); err != nil {
+		fmt.Println("Failed to load alert rules:", err)
+		last = err
+	}
+	if err := s.reloadMutes(ctx); err != nil {
+		fmt.Println("Failed to load alert mutes:", err)
+		last = err
+	}
+	if err := s.reloadExclusions(ctx); err != nil {
+		fmt.Println("Failed to load exclusions:", err)
+		last = err
+	}
+	if err := s.reloadRoutes(ctx); err != nil {
+		fmt.Println("Failed to load notify routes:", err)
+		last = err
+	}
+	if err := s.reloadIPRules(ctx); err != nil {
+		fmt.Println("Failed to load IP rules:", err)
+		last = err
+	}
+	if err := s.reloadSettings(ctx); err != nil {
+		fmt.Println("Failed to load settings:", err)
+		last = err
+	}
+	if err := s.reloadWatchlist(ctx); err != nil {
+		fmt.Println("Failed to load watchlist:", err)
+		last = err
+	}
+	if err := s.reloadPrivacy(ctx); err != nil {
+		fmt.Println("Failed to load project privacy settings:", err)
+		last = err
+	}
+	if err := s.reloadRoles(ctx); err != nil {
+		fmt.Println("Failed to load roles:", err)
+		last = err
+	}
+	if err := s.reloadProjectKeys(ctx); err != nil {
+		fmt.Println("Failed to load project keys:", err)
+		last = err
+	}
+	return last
+}
+
+// evaluateThreshold : 直近 window_seconds 秒の件数が threshold を超えていれば通知する
+func (s *Server) evaluateThreshold(ctx context.Context, r compiledRule) error {
+	now := s.clock.Now()
+	window := time.Duration(r.WindowSeconds) * time.Second
+	f := r.filter(now)
+	count, err := s.store.CountLogs(ctx, f)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("rule:%d", r.ID)
+	if count <= r.Threshold {
+		s.resolveIncident(ctx, key, fmt.Sprintf("Rule %q: %d events in %s (threshold %d)", r.Name, count, window, r.Threshold))
+		return nil
+	}
+	if !s.rules.tryFire(r.AlertRule, now) {
+		s.openIncidents.extend(key, now)
+		return nil
+	}
+	// ほかのインスタンスも同じ件数を数えているので、先に印を付けたインスタンスだけが送る
+	if !s.claimOnce(ctx, key, time.Duration(r.CooldownSeconds)*time.Second) {
+		return nil
+	}
+
+	f.Limit = ruleSampleSize
+	sample, err := s.store.QueryLogs(ctx, f)
+	if err != nil {
+		return err
+	}
+	s.fireRule(ctx, r.AlertRule, fmt.Sprintf("%d events in %s (threshold %d)", count, window, r.Threshold), sample)
+	return nil
+}
+
+// matchRules : 保存されたログを match ルールに当てる（Publish から呼ばれる）
+func (s *Server) matchRules(e *model.LogEntry) {
+	for _, r := range s.rules.snapshot() {
+		if r.Kind != "match" || r.ProjectID != e.ProjectID {
+			continue
+		}
+		if !model.EventTypeMatches(r.EventType, e.EventType) {
+			continue
+		}
+		if r.MinLevel != "" && model.LevelRank(e.Level) < model.LevelRank(r.MinLevel) {
+			continue
+		}
+		if !r.re.MatchString(ruleFields[r.Field](e)) || (r.query != nil && !r.query.Match(e)) {
+			continue
+		}
+		if now := s.clock.Now(); !s.rules.tryFire(r.AlertRule, now) {
+			s.openIncidents.extend(fmt.Sprintf("rule:%d", r.ID), now)
+			continue
+		}
+		go func(r model.AlertRule) {
+			ctx := context.Background()
+			if !s.claimOnce(ctx, fmt.Sprintf("rule:%d", r.ID), time.Duration(r.CooldownSeconds)*time.Second) {
+				return
+			}
+			s.fireRule(ctx, r, fmt.Sprintf("%s matches %q", r.Field, r.Pattern), []model.LogEntry{*e})
+		}(r.AlertRule)
+	}
+}
+
+// evaluateHeartbeat : 直近 window_seconds 秒に対象のログが1件もなければ通知する（作ってから window_seconds 秒は待つ）
+// 止まっている間はクールダウンごとに通知し、またログが届いたら1回だけ知らせる
+func (s *Server) evaluateHeartbeat(ctx context.Context, r compiledRule) error {
+	now := s.clock.Now()
+	window := time.Duration(r.WindowSeconds) * time.Second
+	key := fmt.Sprintf("rule:%d", r.ID)
+	if now.Sub(r.CreatedAt) < window {
+		return nil
+	}
+	count, err := s.store.CountLogs(ctx, r.filter(now))
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		if s.rules.setSilent(r.ID, false) {
+			text := fmt.Sprintf("💓 Rule %q: logs are arriving again", r.Name)
+			s.resolveIncident(ctx, key, text)
+			if s.claimOnce(ctx, key+":recovered", window) {
+				s.notifyAll(ctx, notify.Notification{Level: "info", Title: "💓 " + r.Name, Text: text, Source: "rule", Key: key})
+			}
+		}
+		return nil
+	}
+	s.rules.setSilent(r.ID, true)
+	if !s.rules.tryFire(r.AlertRule, now) {
+		s.openIncidents.extend(key, now)
+		return nil
+	}
+	if !s.claimOnce(ctx, key, time.Duration(r.CooldownSeconds)*time.Second) {
+		return nil
+	}
+
+	// 最後に届いたログ（期間を区切らずに1件）を載せる
+	f := r.filter(now)
+	f.Since, f.Limit = time.Time{}, 1
+	sample, err := s.store.QueryLogs(ctx, f)
+	if err != nil {
+		return err
+	}
+	reason := fmt.Sprintf("no events in %s (never seen)", window)
+	if len(sample) > 0 {
+		reason = fmt.Sprintf("no events in %s (last seen %s ago)", window, now.Sub(sample[0].CreatedAt).Round(time.Second))
+	}
+	s.fireRule(ctx, r.AlertRule, reason, sample)
+	return nil
+}
+
+// fireRule : ルール名と該当ログの例を付けて通知する
+func (s *Server) fireRule(ctx context.Context, r model.AlertRule, reason string, sample []model.LogEntry) {
+	lines := []string{fmt.Sprintf("🚨 Rule %q: %s", r.Name, reason)}
+	sample = s.redact.Entries(slices.Clone(sample), s.notifyScope())
+	for _, e := range sample {
+		lines = append(lines, describeEntry(e))
+	}
+	n := notify.Notification{
+		Level:  r.Level,
+		Title:  "🚨 " + r.Name,
+		Text:   strings.Join(lines, "\n"),
+		Source: "rule",
+		Key:    fmt.Sprintf("rule:%d", r.ID),
+	}
+	if len(sample) > 0 {
+		n.Entry = &sample[0]
+	}
+	s.notifyAll(ctx, n)
+	if s.cfg.DryRun {
+		return
+	}
+
+	if err := s.store.MarkAlertRuleFired(ctx, r.ID, s.clock.Now()); err != nil {
+		fmt.Println("Failed to record alert rule firing:", err)
+	}
+}
+
+// describeEntry : 通知に載せるログ1件の要約
+func describeEntry(e model.LogEntry) string {
+	parts := []string{e.CreatedAt.Format(time.RFC3339), e.EventType}
+	for _, v := range []string{e.Level, e.Path, e.Message, e.IP, e.UserAgent} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return "• " + strings.Join(parts, " | ")
+}
+
+// ==========================================
+// アラートルールの管理API
+// ==========================================
+
+// ruleRequest : POST / PATCH の本文（PATCH では省略した項目は変えない）
+type ruleRequest struct {
+	Name            *string `json:"name"`
+	Kind            *string `json:"kind"`
+	ProjectID       *int    `json:"project_id"`
+	EventType       *string `json:"event_type"`
+	MinLevel        *string `json:"min_level"`
+	Field           *string `json:"field"`
+	Pattern         *string `json:"pattern"`
+	Query           *string `json:"query"`
+	Threshold       *int    `json:"threshold"`
+	WindowSeconds   *int    `json:"window_seconds"`
+	CooldownSeconds *int    `json:"cooldown_seconds"`
+	Level           *string `json:"level"`
+	Enabled         *bool   `json:"enabled"`
+}
+
+// apply : 指定された項目だけ r に反映する
+func (req ruleRequest) apply(r *model.AlertRule) {
+	for _, f := range []struct {
+		dst *string
+		src *string
+	}{{&r.Name, req.Name}, {&r.Kind, req.Kind}, {&r.EventType, req.EventType}, {&r.MinLevel, req.MinLevel},
+		{&r.Field, req.Field}, {&r.Pattern, req.Pattern}, {&r.Query, req.Query}, {&r.Level, req.Level}} {
+		if f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+	for _, f := range []struct {
+		dst *int
+		src *int
+	}{{&r.ProjectID, req.ProjectID}, {&r.Threshold, req.Threshold}, {&r.WindowSeconds, req.WindowSeconds}, {&r.CooldownSeconds, req.CooldownSeconds}} {
+		if f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+	if req.Enabled != nil {
+		r.Enabled = *req.Enabled
+	}
+	if r.Level != "" {
+		if normalized, err := model.NormalizeLevel(r.Level); err == nil {
+			r.Level = normalized
+		}
+	}
+}
+
+// ruleID : パスの {id}
+func ruleID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid rule id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// listRulesHandler : GET /api/rules
+func (s *Server) listRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.store.ListAlertRules(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// createRuleHandler : POST /api/rules
+// 例: {"name": "burst", "kind": "threshold", "threshold": 100, "window_seconds": 300}
+// 例: {"name": "sqlmap", "kind": "match", "field": "user_agent", "pattern": "(?i)sqlmap"}
+// 例: {"name": "nightly backup", "kind": "heartbeat", "query": "field.job:backup", "window_seconds": 90000}
+func (s *Server) createRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var req ruleRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule := model.AlertRule{ProjectID: model.DefaultProjectID, CooldownSeconds: 600, Level: "warn", Enabled: true}
+	req.apply(&rule)
+	if _, err := compileRule(rule); err != nil {
+		http.Error(w, "Invalid rule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.CreateAlertRule(r.Context(), &rule); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadRulesAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// updateRuleHandler : PATCH /api/rules/{id} {"enabled": false} のように変える項目だけ送る
+func (s *Server) updateRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := ruleID(w, r)
+	if !ok {
+		return
+	}
+	var req ruleRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule, err := s.store.AlertRuleByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	req.apply(&rule)
+	if _, err := compileRule(rule); err != nil {
+		http.Error(w, "Invalid rule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = s.store.UpdateAlertRule(r.Context(), &rule)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadRulesAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+// deleteRuleHandler : DELETE /api/rules/{id}
+func (s *Server) deleteRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := ruleID(w, r)
+	if !ok {
+		return
+	}
+	err := s.store.DeleteAlertRule(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadRulesAfterChange(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reloadRulesAfterChange : 変更をすぐ評価に反映する（失敗しても次の定期読み込みで反映される）
+func (s *Server) reloadRulesAfterChange(ctx context.Context) {
+	if err := s.reloadRules(ctx); err != nil {
+		fmt.Println("Failed to reload alert rules:", err)
+	}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+)
+
+// ==========================================
+// アラートの履歴 (JSON と iCalendar)
+// ==========================================
+
+// isAlert : 履歴に残す通知か（発生元付きの通知は全て、ログ由来は warn 以上だけ残す）
+func isAlert(n notify.Notification) bool {
+	if n.Source != "" {
+		return true
+	}
+	return model.LevelRank(n.Level) >= model.LevelRank("warn")
+}
+
+// alertKey : 同じ種類のアラートをまとめるキー（指定がなければ発生元、ログ由来なら種別とレベル）
+func alertKey(n notify.Notification) string {
+	if n.Key != "" {
+		return n.Key
+	}
+	if n.Entry != nil {
+		return "event:" + n.Entry.EventType + ":" + n.Entry.Level
+	}
+	return n.Source
+}
+
+// recordAlert : 通知した内容をアラートとして記録し、IDを返す（失敗したら0。通知は止めない）
+func (s *Server) recordAlert(ctx context.Context, n notify.Notification) int {
+	a := model.Alert{
+		Source:  n.Source,
+		Key:     alertKey(n),
+		Level:   n.Level,
+		Title:   n.Title,
+		Text:    n.Text,
+		FiredAt: s.clock.Now(),
+	}
+	if n.Entry != nil {
+		a.EntryID = n.Entry.ID
+		if a.Source == "" {
+			a.Source = n.Entry.EventType
+		}
+	}
+	if err := s.store.InsertAlert(ctx, &a); err != nil {
+		fmt.Println("Failed to record alert:", err)
+		return 0
+	}
+	return a.ID
+}
+
+// ==========================================
+// ミュート (キーごとに一定時間通知しない)
+// ==========================================
+
+// muteState : ミュート中のキーと期限
+type muteState struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// muted : key がミュート中か
+func (m *muteState) muted(key string, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return now.Before(m.until[key])
+}
+
+// set : key のミュートを手元に反映する
+func (m *muteState) set(key string, until time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.until[key] = until
+}
+
+// muteAlertKey : until まで key のアラートを通知しない
+func (s *Server) muteAlertKey(ctx context.Context, key string, until time.Time, by string) error {
+	if err := s.store.MuteAlertKey(ctx, key, until, by); err != nil {
+		return err
+	}
+	s.mutes.set(key, until)
+	return nil
+}
+
+// unmuteAlertKey : key のミュートを解除する
+func (s *Server) unmuteAlertKey(ctx context.Context, key string) error {
+	if err := s.store.UnmuteAlertKey(ctx, key); err != nil {
+		return err
+	}
+	s.mutes.mu.Lock()
+	delete(s.mutes.until, key)
+	s.mutes.mu.Unlock()
+	return nil
+}
+
+// reloadMutes : ミュートをDBから読み直す（他のインスタンスでミュートした分も反映する）
+func (s *Server) reloadMutes(ctx context.Context) error {
+	until, err := s.store.ActiveMutes(ctx, s.clock.Now())
+	if err != nil {
+		return err
+	}
+	s.mutes.mu.Lock()
+	s.mutes.until = until
+	s.mutes.mu.Unlock()
+	return nil
+}
+
+// alertsSince : ?days= （既定30日、最大366日）より後のアラート
+func (s *Server) alertsSince(r *http.Request) ([]model.Alert, error) {
+	days := 30
+	if v, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && v > 0 && v <= 366 {
+		days = v
+	}
+	limit := 100
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 1000 {
+		limit = v
+	}
+	return s.store.ListAlerts(r.Context(), s.clock.Now().AddDate(0, 0, -days), limit)
+}
+
+// alertsHandler : GET /api/alerts?days=30&limit=100
+func (s *Server) alertsHandler(w http.ResponseWriter, r *http.Request) {
+	loc, ok := s.responseLocation(w, r)
+	if !ok {
+		return
+	}
+	alerts, err := s.alertsSince(r)
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	inLocation(alerts, loc)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
+// alertsICSHandler : GET /api/alerts.ics で iCalendar (RFC 5545) のフィードとして返す
+// カレンダーアプリに URL を登録すると、アラートが発生時刻の予定として並ぶ
+func (s *Server) alertsICSHandler(w http.ResponseWriter, r *http.Request) {
+	alerts, err := s.alertsSince(r)
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	host := strings.TrimPrefix(strings.TrimPrefix(s.publicBaseURL(r), "https://"), "http://")
+	host, _, _ = strings.Cut(host, "/")
+	stamp := icsTime(s.clock.Now())
+
+	var b strings.Builder
+	icsLine(&b, "BEGIN:VCALENDAR")
+	icsLine(&b, "VERSION:2.0")
+	icsLine(&b, "PRODID:-//go-logger//alerts//EN")
+	icsLine(&b, "CALSCALE:GREGORIAN")
+	icsLine(&b, "METHOD:PUBLISH")
+	icsLine(&b, "X-WR-CALNAME:"+icsEscape(strings.TrimSpace(s.cfg.InstanceName+" alerts")))
+	for _, a := range alerts {
+		summary := a.Title
+		if summary == "" {
+			summary, _, _ = strings.Cut(a.Text, "\n")
+		}
+		icsLine(&b, "BEGIN:VEVENT")
+		icsLine(&b, fmt.Sprintf("UID:alert-%d@%s", a.ID, host))
+		icsLine(&b, "DTSTAMP:"+stamp)
+		icsLine(&b, "DTSTART:"+icsTime(a.FiredAt))
+		icsLine(&b, "DTEND:"+icsTime(a.FiredAt.Add(5*time.Minute)))
+		icsLine(&b, "SUMMARY:"+icsEscape(fmt.Sprintf("[%s] %s", strings.ToUpper(a.Level), summary)))
+		icsLine(&b, "DESCRIPTION:"+icsEscape(a.Text))
+		icsLine(&b, "CATEGORIES:"+icsEscape(a.Source))
+		if a.EntryID != 0 {
+			if u := s.entryURL(&model.LogEntry{ID: a.EntryID}); u != "" {
+				icsLine(&b, "URL:"+u)
+			}
+		}
+		icsLine(&b, "END:VEVENT")
+	}
+	icsLine(&b, "END:VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// icsTime : UTCの日時（例: 20240101T120000Z）
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icsEscape : TEXT 値のエスケープ（\ ; , と改行）
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// icsLine : 1行を CRLF 付きで書く（75オクテットを超える行は折り返す。UTF-8 の途中では切らない）
+func icsLine(b *strings.Builder, line string) {
+	max := 75
+	for len(line) > max {
+		cut := max
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		max = 74 // 続きの行は先頭の空白も数える
+	}
+	b.WriteString(line + "\r\n")
+}
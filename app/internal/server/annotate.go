@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go-logger/internal/redact"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// ログの仕分け (PATCH /api/logs/{id}。タグとメモ)
+// ==========================================
+
+// タグとメモの上限
+const (
+	maxTags       = 20
+	maxNoteLength = 2000
+)
+
+// tagPattern : タグに使える文字（小文字に揃えてから確かめる）
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:-]{0,63}$`)
+
+// annotateRequest : PATCH /api/logs/{id} の本文（省略した項目は変えない）
+type annotateRequest struct {
+	Tags       *[]string `json:"tags"`        // 全て置き換える
+	AddTags    []string  `json:"add_tags"`    // 足す
+	RemoveTags []string  `json:"remove_tags"` // 外す
+	Note       *string   `json:"note"`        // 空文字で消す
+}
+
+// normalizeTags : 小文字にして確かめる
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !tagPattern.MatchString(t) {
+			return nil, fmt.Errorf("invalid tag %q (use up to 64 of a-z, 0-9, _ . : -)", t)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// annotation : 本文を確かめて store.LogAnnotation にする
+func (req annotateRequest) annotation() (store.LogAnnotation, error) {
+	var a store.LogAnnotation
+	var err error
+	if req.Tags != nil {
+		tags, err := normalizeTags(*req.Tags)
+		if err != nil {
+			return a, err
+		}
+		a.Tags = &tags
+	}
+	if a.AddTags, err = normalizeTags(req.AddTags); err != nil {
+		return a, err
+	}
+	if a.RemoveTags, err = normalizeTags(req.RemoveTags); err != nil {
+		return a, err
+	}
+	if a.Tags != nil && len(*a.Tags) > maxTags || len(a.AddTags) > maxTags {
+		return a, fmt.Errorf("at most %d tags", maxTags)
+	}
+	if req.Note != nil {
+		note := strings.TrimSpace(*req.Note)
+		if utf8.RuneCountInString(note) > maxNoteLength {
+			return a, fmt.Errorf("note is longer than %d characters", maxNoteLength)
+		}
+		a.Note = &note
+	}
+	if a.Tags == nil && len(a.AddTags) == 0 && len(a.RemoveTags) == 0 && a.Note == nil {
+		return a, errors.New(`nothing to change (send "tags", "add_tags", "remove_tags" or "note")`)
+	}
+	return a, nil
+}
+
+// annotateLogHandler : PATCH /api/logs/{id} {"add_tags": ["pentest"], "note": "scanner from 203.0.113.5"}
+// ダッシュボードにログインした利用者 (DASHBOARD_USERS) か ADMIN_TOKEN が必要。?tag= で一覧を絞り込める
+func (s *Server) annotateLogHandler(w http.ResponseWriter, r *http.Request) {
+	scope := s.requestScope(r)
+	if scope < redact.User {
+		http.Error(w, "Login or admin token required", http.StatusForbidden)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid log id", http.StatusBadRequest)
+		return
+	}
+	loc, ok := s.responseLocation(w, r)
+	if !ok {
+		return
+	}
+	var req annotateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	a, err := req.annotation()
+	if err != nil {
+		http.Error(w, "Invalid annotation: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	e, err := s.store.AnnotateLog(r.Context(), id, a)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Log not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	// キャッシュにある古いタグを返さないよう捨てる
+	s.recent.invalidateProject(e.ProjectID)
+
+	e = s.redact.Entry(e, scope)
+	inLocation(&e, loc)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-logger/internal/config"
+	"go-logger/internal/notify"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// アクセス急増・急減の検知 (直近の件数を過去の1時間あたりの平均と比べる)
+// ==========================================
+
+// anomalyMinHistoryHours : 平均を出すのに必要な過去の時間数（導入直後の誤検知を避ける）
+const anomalyMinHistoryHours = 6
+
+// AnomalyConfig : 検知の設定
+type AnomalyConfig struct {
+	Factor        float64       // 平均の何倍（何分の1）で通知するか（0なら検知しない）
+	BaselineHours int           // 平均を取る期間
+	MinEvents     int           // これより少ない件数では通知しない（深夜などの小さな揺れを無視する）
+	EventType     string        // 対象の種別（空なら全種別）
+	Interval      time.Duration // 検知する間隔
+}
+
+// AnomalyFromEnv : ANOMALY_FACTOR を設定した時だけ有効にする
+func AnomalyFromEnv() AnomalyConfig {
+	return AnomalyConfig{
+		Factor:        config.Float64("ANOMALY_FACTOR", 0),
+		BaselineHours: config.Int("ANOMALY_BASELINE_HOURS", 7*24),
+		MinEvents:     config.Int("ANOMALY_MIN_EVENTS", 50),
+		EventType:     config.String("ANOMALY_EVENT_TYPE", ""),
+		Interval:      config.Duration("ANOMALY_INTERVAL", 5*time.Minute),
+	}
+}
+
+// anomalyState : 通知済みの状態（同じ急増を繰り返し通知しない）
+type anomalyState struct {
+	mu      sync.Mutex
+	alerted map[string]bool
+}
+
+// watchAnomalies : 定期的に全プロジェクトの直近1時間の件数を調べる
+func (s *Server) watchAnomalies(ctx context.Context) {
+	if s.cfg.Anomaly.Factor <= 1 {
+		return
+	}
+	ticker := s.clock.NewTicker(s.cfg.Anomaly.Interval)
+	defer ticker.Stop()
+	j := s.jobs.register("anomalies", s.cfg.Anomaly.Interval).leaderOnly()
+
+	for {
+		s.runJob(j, func() error {
+			projects, err := s.store.ListProjects(ctx)
+			if err != nil {
+				fmt.Println("Anomaly check failed:", err)
+				return err
+			}
+			for _, p := range projects {
+				if checkErr := s.checkAnomaly(ctx, p.ID, p.Name); checkErr != nil {
+					fmt.Printf("Anomaly check failed for project %d: %v\n", p.ID, checkErr)
+					err = checkErr
+				}
+			}
+			return err
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// checkAnomaly : 直近1時間の件数と、過去の1時間あたりの平均を比べる
+func (s *Server) checkAnomaly(ctx context.Context, projectID int, projectName string) error {
+	cfg := s.cfg.Anomaly
+	now := s.clock.Now().UTC()
+	currentHour := now.Truncate(time.Hour)
+
+	hourly, err := s.store.HourlyCounts(ctx, store.LogFilter{
+		ProjectID: projectID,
+		EventType: cfg.EventType,
+		Since:     currentHour.Add(-time.Duration(cfg.BaselineHours) * time.Hour),
+		Until:     currentHour,
+	})
+	if err != nil {
+		return err
+	}
+	baseline, hours := hourlyBaseline(hourly, currentHour)
+	if hours < anomalyMinHistoryHours {
+		return nil
+	}
+
+	current, err := s.store.CountLogs(ctx, store.LogFilter{ProjectID: projectID, EventType: cfg.EventType, Since: now.Add(-time.Hour)})
+	if err != nil {
+		return err
+	}
+
+	spike := float64(current) > baseline*cfg.Factor && current >= cfg.MinEvents
+	drop := baseline >= float64(cfg.MinEvents) && float64(current) < baseline/cfg.Factor
+	what := "events"
+	if cfg.EventType != "" {
+		what = cfg.EventType + " events"
+	}
+	s.anomalyAlert(ctx, fmt.Sprintf("%d:spike", projectID), spike, "warn",
+		fmt.Sprintf("📈 Traffic spike on %s: %d %s in the last hour (%.1f× the %.0f/hour average)",
+			projectName, current, what, float64(current)/baseline, baseline))
+	s.anomalyAlert(ctx, fmt.Sprintf("%d:drop", projectID), drop, "warn",
+		fmt.Sprintf("📉 Traffic drop on %s: %d %s in the last hour (average %.0f/hour)",
+			projectName, current, what, baseline))
+	return nil
+}
+
+// hourlyBaseline : 最初にログがあった時間から currentHour の直前までの1時間あたりの平均
+// ログがなかった時間は0件として数える
+func hourlyBaseline(hourly map[time.Time]int, currentHour time.Time) (mean float64, hours int) {
+	first := currentHour
+	total := 0
+	for hour, n := range hourly {
+		if hour.Before(first) {
+			first = hour
+		}
+		total += n
+	}
+	hours = int(currentHour.Sub(first) / time.Hour)
+	if hours == 0 {
+		return 0, 0
+	}
+	return float64(total) / float64(hours), hours
+}
+
+// anomalyAlert : 検知した時に1回だけ通知し、収まったら再び通知できる状態に戻す（インシデントは閉じる）
+func (s *Server) anomalyAlert(ctx context.Context, key string, over bool, level, text string) {
+	s.anomaly.mu.Lock()
+	already := s.anomaly.alerted[key]
+	s.anomaly.alerted[key] = over
+	s.anomaly.mu.Unlock()
+	if over && !already {
+		s.notifyAll(ctx, notify.Notification{Level: level, Title: "Traffic anomaly", Text: text, Source: "anomaly", Key: "anomaly:" + key})
+	}
+	if !over && already {
+		s.resolveIncident(ctx, "anomaly:"+key, "Traffic is back to normal")
+	}
+}
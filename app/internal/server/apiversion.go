@@ -0,0 +1,80 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ==========================================
+// APIのバージョン (/api/v1/...)
+// ==========================================
+// 今のAPIを v1 とし、/api/v1/logs は /api/logs と同じルートで処理する
+// 接頭辞のない /api/... は互換のため残し、v1 として扱う（ダッシュボードや既存の送信元はそのまま動く）
+// 互換性のない変更（ページングの包み方・項目名の変更など）は v2 として追加し、v1 の応答は変えない
+
+// currentAPIVersion : 受け付けるバージョン（/api/v1 の "1"）
+const currentAPIVersion = "1"
+
+// withAPIVersion : /api/v{n}/... の接頭辞を外してから振り分け、応答に API-Version を付ける
+// 知らないバージョンは 404 にする
+func withAPIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, "/api/")
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("API-Version", currentAPIVersion)
+		version, path, _ := strings.Cut(rest, "/")
+		if !isAPIVersion(version) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if version[1:] != currentAPIVersion {
+			http.Error(w, fmt.Sprintf("Unsupported API version %s (use /api/v%s)", version, currentAPIVersion), http.StatusNotFound)
+			return
+		}
+
+		// http.StripPrefix と同じく、URL を複製してから書き換える
+		r2 := new(http.Request)
+		*r2 = *r
+		u := *r.URL
+		r2.URL = &u
+		r2.URL.Path = "/api/" + path
+		if r.URL.RawPath != "" {
+			if raw, ok := strings.CutPrefix(r.URL.RawPath, "/api/"+version); ok {
+				r2.URL.RawPath = "/api" + raw
+			} else {
+				r2.URL.RawPath = ""
+			}
+		}
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// unversionedPath : /api/v{n}/... の接頭辞を外したパス（バージョンの付いていないパスはそのまま）
+func unversionedPath(path string) string {
+	rest, ok := strings.CutPrefix(path, "/api/")
+	if !ok {
+		return path
+	}
+	version, rest, _ := strings.Cut(rest, "/")
+	if !isAPIVersion(version) {
+		return path
+	}
+	return "/api/" + rest
+}
+
+// isAPIVersion : "v1" のような、v の後に数字が続くパスの要素か（/api/visitors などと見分ける）
+func isAPIVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// URLの接頭辞 (リバースプロキシの /go/ などの下で動かす場合)
+// ==========================================
+
+// basePathFromEnv : BASE_PATH を "/go" の形にそろえる（未設定なら空）
+func basePathFromEnv() string {
+	base := strings.Trim(config.String("BASE_PATH", ""), "/")
+	if base == "" {
+		return ""
+	}
+	return "/" + base
+}
+
+// withBasePath : BASE_PATH で始まるリクエストは接頭辞を外してから振り分ける
+// プロキシが接頭辞を外して転送する場合（nginx の proxy_pass http://app:8081/;）のために、
+// 接頭辞のないリクエストもそのまま受け付ける
+// ダッシュボードは相対パスで API を呼ぶので、"/go" は "/go/" へリダイレクトする
+func (s *Server) withBasePath(next http.Handler) http.Handler {
+	base := s.cfg.BasePath
+	if base == "" {
+		return next
+	}
+	stripped := http.StripPrefix(base, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == base:
+			target := base + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, base+"/"):
+			stripped.ServeHTTP(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
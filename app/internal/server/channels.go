@@ -0,0 +1,404 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 通知先の管理 (DBに保存し、再デプロイなしで追加・停止する)
+// ==========================================
+
+// reloadChannels : 有効な通知先をDBから読み直す（設定が不正な通知先は飛ばす）
+func (s *Server) reloadChannels(ctx context.Context) error {
+	channels, err := s.store.ListChannels(ctx)
+	if err != nil {
+		return err
+	}
+	var list []notify.Notifier
+	for _, c := range channels {
+		if !c.Enabled {
+			continue
+		}
+		n, err := notify.FromChannel(c, s.clock)
+		if err != nil {
+			fmt.Printf("Skipping notification channel %d (%s): %v\n", c.ID, c.Name, err)
+			continue
+		}
+		list = append(list, n)
+	}
+	s.channels.Store(notify.NewMulti(list...))
+	return nil
+}
+
+// watchChannels : 通知先を定期的に読み直す（他のインスタンスで変更された分も反映する）
+func (s *Server) watchChannels(ctx context.Context) {
+	ticker := s.clock.NewTicker(s.cfg.ChannelReloadInterval)
+	defer ticker.Stop()
+	j := s.jobs.register("channels", s.cfg.ChannelReloadInterval)
+
+	for {
+		s.runJob(j, func() error {
+			err := s.reloadChannels(ctx)
+			if err != nil {
+				fmt.Println("Failed to load notification channels:", err)
+			}
+			return err
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// channelRequest : POST / PATCH の本文（PATCH では省略した項目は変えない）
+type channelRequest struct {
+	Name     *string           `json:"name"`
+	Type     *string           `json:"type"`
+	URL      *string           `json:"url"`
+	Token    *string           `json:"token"`
+	ChatID   *string           `json:"chat_id"`
+	Settings map[string]string `json:"settings"` // 送ったキーだけ変える（空文字なら消す）
+	Enabled  *bool             `json:"enabled"`
+	Rules    *string           `json:"rules"`
+	Template *string           `json:"template"`
+}
+
+// apply : 指定された項目だけ c に反映する
+func (req channelRequest) apply(c *model.Channel) {
+	for _, f := range []struct {
+		dst *string
+		src *string
+	}{{&c.Name, req.Name}, {&c.Type, req.Type}, {&c.URL, req.URL}, {&c.Token, req.Token}, {&c.ChatID, req.ChatID}, {&c.Rules, req.Rules}} {
+		// GET の結果をそのまま送り返された場合、伏せた値で上書きしない
+		if f.src != nil && !strings.HasSuffix(*f.src, redacted) {
+			*f.dst = strings.TrimSpace(*f.src)
+		}
+	}
+	if req.Settings != nil {
+		settings := make(map[string]string, len(c.Settings)+len(req.Settings))
+		for k, v := range c.Settings {
+			settings[k] = v
+		}
+		for k, v := range req.Settings {
+			switch v = strings.TrimSpace(v); {
+			case strings.HasSuffix(v, redacted):
+			case v == "":
+				delete(settings, k)
+			default:
+				settings[k] = v
+			}
+		}
+		c.Settings = settings
+	}
+	if req.Enabled != nil {
+		c.Enabled = *req.Enabled
+	}
+	// テンプレートは前後の改行も本文の一部なのでそのまま
+	if req.Template != nil {
+		c.Template = *req.Template
+	}
+}
+
+// validateChannel : 保存する前に通知先として使えるか確かめる
+func (s *Server) validateChannel(c model.Channel) error {
+	if c.Name == "" {
+		return errors.New(`"name" is required`)
+	}
+	_, err := notify.FromChannel(c, s.clock)
+	return err
+}
+
+// redacted : 伏せた値の印
+const redacted = "…"
+
+// redactChannel : 返す時に Webhook URL のパスとトークンを伏せる（どちらも送信の権限そのもの）
+// settings は種類ごとに登録された項目 (email の password、webhook の secret など) を伏せる
+func redactChannel(c model.Channel) model.Channel {
+	if u, err := url.Parse(c.URL); err == nil && c.URL != "" {
+		c.URL = u.Scheme + "://" + u.Host + "/" + redacted
+	}
+	if c.Token != "" {
+		c.Token = redacted
+	}
+	if len(c.Settings) > 0 {
+		settings := make(map[string]string, len(c.Settings))
+		for k, v := range c.Settings {
+			settings[k] = v
+		}
+		for _, k := range notify.DefaultRegistry.Secrets(c.Type) {
+			if settings[k] != "" {
+				settings[k] = redacted
+			}
+		}
+		c.Settings = settings
+	}
+	return c
+}
+
+// channelID : パスの {id}
+func channelID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid channel id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// listChannelsHandler : GET /api/channels
+func (s *Server) listChannelsHandler(w http.ResponseWriter, r *http.Request) {
+	channels, err := s.store.ListChannels(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	for i := range channels {
+		channels[i] = redactChannel(channels[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channels)
+}
+
+// getChannelHandler : GET /api/channels/{id}
+func (s *Server) getChannelHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := channelID(w, r)
+	if !ok {
+		return
+	}
+	c, err := s.store.ChannelByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redactChannel(c))
+}
+
+// createChannelHandler : POST /api/channels {"name": "ops", "type": "discord", "url": "https://...", "rules": "log=warn,*=off"}
+func (s *Server) createChannelHandler(w http.ResponseWriter, r *http.Request) {
+	var req channelRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	c := model.Channel{Enabled: true}
+	req.apply(&c)
+	if err := s.validateChannel(c); err != nil {
+		http.Error(w, "Invalid channel: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.CreateChannel(r.Context(), &c); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadChannelsAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(redactChannel(c))
+}
+
+// updateChannelHandler : PATCH /api/channels/{id} {"enabled": false} のように変える項目だけ送る
+func (s *Server) updateChannelHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := channelID(w, r)
+	if !ok {
+		return
+	}
+	var req channelRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c, err := s.store.ChannelByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	req.apply(&c)
+	if err := s.validateChannel(c); err != nil {
+		http.Error(w, "Invalid channel: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = s.store.UpdateChannel(r.Context(), &c)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadChannelsAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redactChannel(c))
+}
+
+// deleteChannelHandler : DELETE /api/channels/{id}
+func (s *Server) deleteChannelHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := channelID(w, r)
+	if !ok {
+		return
+	}
+	err := s.store.DeleteChannel(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadChannelsAfterChange(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// channelTestResult : POST /api/channels/{id}/test の応答
+type channelTestResult struct {
+	Channel  string           `json:"channel"` // 種類:名前
+	DryRun   bool             `json:"dry_run"`
+	Entry    model.LogEntry   `json:"entry"`              // 通知に使った例のログ
+	Payloads []notify.Preview `json:"payloads,omitempty"` // dry_run の時、送るはずだった本文
+}
+
+// testChannelHandler : POST /api/channels/{id}/test?dry_run=1 で、例のログの通知をテンプレートどおりに組み立てて送る（dry_run なら送らずに本文を返す）
+// ?type=access|log（既定 log）と ?level=（既定はログなら error、アクセスなら info）で例のログを選ぶ。ルールと min_level・止めている間・ミュートは無視する
+// 止めている (enabled=false) 通知先も試せる。DRY_RUN=true のインスタンスでは常に dry_run になる
+func (s *Server) testChannelHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := channelID(w, r)
+	if !ok {
+		return
+	}
+	c, err := s.store.ChannelByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	n, err := s.sampleNotification(r)
+	if err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 例は必ず送りたいので、ルールと min_level は外して組み立てる
+	c.Rules = ""
+	if c.Settings != nil {
+		c.Settings = maps.Clone(c.Settings)
+		delete(c.Settings, "min_level")
+	}
+	notifier, err := notify.FromChannel(c, s.clock)
+	if err != nil {
+		http.Error(w, "Invalid channel: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	res := channelTestResult{Channel: notifier.Name(), DryRun: dryRun || s.cfg.DryRun, Entry: *n.Entry}
+	ctx, previews := r.Context(), func() []notify.Preview { return nil }
+	if res.DryRun {
+		ctx, previews = notify.WithPreview(ctx)
+	}
+	if err := notifier.Notify(ctx, n); err != nil {
+		http.Error(w, "Failed to send test notification: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	res.Payloads = previews()
+	if !res.DryRun {
+		fmt.Printf("Test notification sent to channel %d (%s)\n", c.ID, res.Channel)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// sampleNotification : 試しに送る通知（実際のアクセス・構造化ログの通知と同じ件名と本文）
+func (s *Server) sampleNotification(r *http.Request) (notify.Notification, error) {
+	kind := r.URL.Query().Get("type")
+	defaultLevel := "error"
+	if kind == "access" {
+		defaultLevel = model.DefaultLevel
+	}
+	level, err := model.NormalizeLevel(cmp.Or(r.URL.Query().Get("level"), defaultLevel))
+	if err != nil {
+		return notify.Notification{}, err
+	}
+	duration := 182.4
+	e := &model.LogEntry{
+		ProjectID:  model.DefaultProjectID,
+		UserAgent:  "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+		IP:         "203.0.113.7",
+		Country:    "JP",
+		Path:       "/api/logs",
+		Referrer:   "https://www.google.com/",
+		EventType:  logEventType,
+		Level:      level,
+		Message:    "upstream timeout on /shop/items/12 (test notification)",
+		Fields:     json.RawMessage(`{"test": true, "request_path": "/shop/items/12"}`),
+		Browser:    "Chrome",
+		OS:         "Windows",
+		Device:     "desktop",
+		CreatedAt:  s.clock.Now(),
+		HitCount:   1,
+		SampleRate: 1,
+		Status:     504,
+		DurationMS: &duration,
+	}
+	switch kind {
+	case "", "log":
+		return notify.Notification{
+			Level: e.Level,
+			Title: "📝 " + strings.ToUpper(e.Level),
+			Text:  fmt.Sprintf("📝 [%s] %s", strings.ToUpper(e.Level), e.Message),
+			Entry: e,
+		}, nil
+	case "access":
+		e.Path, e.EventType, e.Message, e.Fields, e.Status = "/api/shop/items/12", model.EventAccess, "", nil, 200
+		return notify.Notification{
+			Level: e.Level,
+			Title: "🚀 New Access Detected!",
+			Text:  fmt.Sprintf("🚀 New Access Detected! [%s] %s UA: %s", e.EventType, e.Path, e.UserAgent),
+			Entry: e,
+		}, nil
+	default:
+		return notify.Notification{}, fmt.Errorf(`"type" must be access or log, got %q`, kind)
+	}
+}
+
+// reloadChannelsAfterChange : 変更をすぐ通知に反映する（失敗しても次の定期読み込みで反映される）
+func (s *Server) reloadChannelsAfterChange(ctx context.Context) {
+	if err := s.reloadChannels(ctx); err != nil {
+		fmt.Println("Failed to reload notification channels:", err)
+	}
+}
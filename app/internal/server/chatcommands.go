@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// チャットのスラッシュコマンド (/logger stats today, /logger last 5)
+// ==========================================
+
+// chatCommandHelp : 使い方
+const chatCommandHelp = "Usage: `/logger stats [today|24h|7d|30d|all]` or `/logger last [N]` (N ≤ 20)"
+
+// chatLastMax : /logger last で返す最大件数
+const chatLastMax = 20
+
+// slackMaxSkew : Slack の署名のタイムスタンプとして受け付けるずれ（リプレイ対策）
+const slackMaxSkew = 5 * time.Minute
+
+// chatCommand : コマンドの引数を実行し、チャットに返す文を作る（Discord / Slack 共通）
+func (s *Server) chatCommand(ctx context.Context, args []string) string {
+	if len(args) == 0 {
+		return chatCommandHelp
+	}
+	switch strings.ToLower(args[0]) {
+	case "stats":
+		period := "today"
+		if len(args) > 1 {
+			period = strings.ToLower(args[1])
+		}
+		return s.chatStats(ctx, period)
+	case "last":
+		n := 5
+		if len(args) > 1 {
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v <= 0 {
+				return chatCommandHelp
+			}
+			n = min(v, chatLastMax)
+		}
+		return s.chatLast(ctx, n)
+	default:
+		return chatCommandHelp
+	}
+}
+
+// chatPeriodSince : 期間の開始（all ならゼロ）
+func (s *Server) chatPeriodSince(period string) (time.Time, bool) {
+	now := s.clock.Now().UTC()
+	switch period {
+	case "today":
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), true
+	case "24h":
+		return now.Add(-24 * time.Hour), true
+	case "7d", "week":
+		return now.AddDate(0, 0, -7), true
+	case "30d", "month":
+		return now.AddDate(0, 0, -30), true
+	case "all":
+		return time.Time{}, true
+	}
+	return time.Time{}, false
+}
+
+// chatStats : /logger stats の返答（/api/stats と同じ集計）
+func (s *Server) chatStats(ctx context.Context, period string) string {
+	since, ok := s.chatPeriodSince(period)
+	if !ok {
+		return chatCommandHelp
+	}
+	stats, err := s.store.Stats(ctx, store.LogFilter{ProjectID: model.DefaultProjectID, Since: since}, s.clock.Now().Add(-24*time.Hour))
+	if err != nil {
+		return "Database error: " + err.Error()
+	}
+
+	lines := []string{fmt.Sprintf("📊 **%d** events (%s)", stats.Total, period)}
+	if len(stats.ByType) > 0 {
+		lines = append(lines, "By type: "+formatCounts(stats.ByType))
+	}
+	if len(stats.ByLevel) > 0 {
+		lines = append(lines, "By level: "+formatCounts(stats.ByLevel))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// chatLast : /logger last N の返答
+func (s *Server) chatLast(ctx context.Context, n int) string {
+	logs, err := s.store.QueryLogs(ctx, store.LogFilter{ProjectID: model.DefaultProjectID, Limit: n})
+	if err != nil {
+		return "Database error: " + err.Error()
+	}
+	if len(logs) == 0 {
+		return "No events yet"
+	}
+	lines := []string{fmt.Sprintf("🕒 Last %d events", len(logs))}
+	for _, e := range s.redact.Entries(logs, s.notifyScope()) {
+		lines = append(lines, describeEntry(e))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatCounts : 件数の多い順に "access 120, ping 30"
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s %d", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ==========================================
+// Slack
+// ==========================================
+
+// slackCommandHandler : POST /api/slack/commands （Slack アプリの Slash Command の Request URL）
+// 署名は SLACK_SIGNING_SECRET で検証する
+func (s *Server) slackCommandHandler(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.SlackSigningSecret == "" {
+		http.Error(w, "Not Found: Slack commands are disabled (set SLACK_SIGNING_SECRET)", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 64<<10))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	if !s.validSlackSignature(r, body) {
+		http.Error(w, "Unauthorized: invalid request signature", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "Invalid form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Slack の mrkdwn は太字が *...* なので合わせる
+	text := strings.ReplaceAll(s.chatCommand(r.Context(), strings.Fields(form.Get("text"))), "**", "*")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"response_type": "ephemeral", "text": text})
+}
+
+// validSlackSignature : X-Slack-Signature = "v0=" + HMAC-SHA256("v0:<timestamp>:<body>")
+func (s *Server) validSlackSignature(r *http.Request, body []byte) bool {
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := s.clock.Now().Sub(time.Unix(sec, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(s.cfg.SlackSigningSecret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(want), []byte(r.Header.Get("X-Slack-Signature")))
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ==========================================
+// 送信元IPの取得
+// ==========================================
+
+// clientIP : リクエストの送信元IP
+// 直接の接続元がプライベート/ループバック（＝手前のnginxやDockerネットワーク）の場合のみ
+// X-Forwarded-For / X-Real-IP を信用する
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote := net.ParseIP(host)
+	if remote == nil {
+		return ""
+	}
+	if !remote.IsLoopback() && !remote.IsPrivate() {
+		return remote.String()
+	}
+
+	// X-Forwarded-For は右端が最も近いプロキシ。信用できないアドレスが出るまで遡る
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			if !ip.IsLoopback() && !ip.IsPrivate() || i == 0 {
+				return ip.String()
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return remote.String()
+}
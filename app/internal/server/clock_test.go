@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/notify"
+	"go-logger/internal/store"
+)
+
+// fakeClock : 手動で進める時計（AfterFunc の予約を溜め、fire で呼ぶ）
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	delays []time.Duration
+	funcs  []func()
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) clock.Ticker { return fakeTicker{} }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time { return make(chan time.Time) }
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) clock.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delays = append(c.delays, d)
+	c.funcs = append(c.funcs, f)
+	return fakeTimer{}
+}
+
+// fire : 予約された呼び出しを全て呼ぶ
+func (c *fakeClock) fire() {
+	c.mu.Lock()
+	funcs := c.funcs
+	c.funcs = nil
+	c.mu.Unlock()
+	for _, f := range funcs {
+		f()
+	}
+}
+
+type fakeTicker struct{}
+
+func (fakeTicker) C() <-chan time.Time { return nil }
+func (fakeTicker) Stop()               {}
+func (fakeTicker) Reset(time.Duration) {}
+
+type fakeTimer struct{}
+
+func (fakeTimer) Stop() bool { return true }
+
+// failingNotifier : 常に失敗する通知先
+type failingNotifier struct{}
+
+func (failingNotifier) Name() string { return "failing" }
+
+func (failingNotifier) Notify(ctx context.Context, n notify.Notification) error {
+	return errors.New("unreachable")
+}
+
+// TestNotifyRetryUsesClock : 送れなかった通知の送り直しは、差し替えた時計で待つ
+func TestNotifyRetryUsesClock(t *testing.T) {
+	clk := &fakeClock{now: time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)}
+	cfg := ConfigFromEnv()
+	cfg.NotifyRetryBackoff, cfg.NotifyMaxAttempts = time.Minute, 3
+	s := New(cfg, Deps{Store: store.NewMemory(clk, 100), Notifier: failingNotifier{}, Clock: clk})
+
+	s.deliverQueued(queuedNotification{Notification: notify.Notification{Level: "info", Text: "hello"}, Prepared: true})
+	if len(clk.delays) != 1 || clk.delays[0] != time.Minute {
+		t.Fatalf("scheduled retries %v, want [1m0s]", clk.delays)
+	}
+	if n, _ := s.queue.Len(context.Background()); n != 0 {
+		t.Fatalf("requeued before the clock fired: %d", n)
+	}
+	clk.fire()
+	if n, _ := s.queue.Len(context.Background()); n != 1 {
+		t.Fatalf("queued notifications after retry %d, want 1", n)
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 同じアクセスの集約 (COLLAPSE_WINDOW。再読み込みの連打などを1行にまとめ、hit_count を増やす)
+// ==========================================
+
+// collapseKey : 同じアクセスとみなす組み合わせ
+type collapseKey struct {
+	projectID int
+	eventType string
+	path      string
+	ip        string
+	userAgent string
+	visitorID string
+}
+
+// collapsedAccess : まとめる先の行
+type collapsedAccess struct {
+	id        int
+	uid       string
+	createdAt time.Time
+	lastHit   time.Time
+}
+
+// collapseState : 直近に保存したアクセス（インスタンスごと。別のインスタンスに届いた分はまとめない）
+type collapseState struct {
+	mu        sync.Mutex
+	seen      map[collapseKey]collapsedAccess
+	lastSweep time.Time
+}
+
+// collapsible : まとめてよい書き込みか（メッセージや項目のあるログは1件ずつ残す）
+func (s *Server) collapsible(lw *model.Write) bool {
+	return s.cfg.CollapseWindow > 0 && lw.Message == "" && len(lw.Fields) == 0 && lw.IP != ""
+}
+
+func collapseKeyOf(lw *model.Write) collapseKey {
+	return collapseKey{projectID: lw.ProjectID, eventType: lw.EventType, path: lw.Path, ip: lw.IP, userAgent: lw.UserAgent, visitorID: lw.VisitorID}
+}
+
+// collapseWrite : 窓の中に同じアクセスがあれば、その行の hit_count を増やして true を返す
+// lw の ID と uid はまとめた先の行のものになる
+func (s *Server) collapseWrite(ctx context.Context, lw *model.Write) bool {
+	if !s.collapsible(lw) {
+		return false
+	}
+	key := collapseKeyOf(lw)
+	now := lw.CreatedAt
+
+	s.collapse.mu.Lock()
+	prev, ok := s.collapse.seen[key]
+	if ok && now.Sub(prev.lastHit) > s.cfg.CollapseWindow {
+		delete(s.collapse.seen, key)
+		ok = false
+	}
+	s.collapse.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	hits, err := s.store.IncrementHits(ctx, prev.id, prev.createdAt, now)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			fmt.Println("Collapse Error:", err)
+		}
+		// 削除された行や、DBに繋がらない時は普通に保存する
+		s.collapse.mu.Lock()
+		delete(s.collapse.seen, key)
+		s.collapse.mu.Unlock()
+		return false
+	}
+
+	s.collapse.mu.Lock()
+	prev.lastHit = now
+	s.collapse.seen[key] = prev
+	s.collapse.mu.Unlock()
+
+	lw.ID, lw.UID = prev.id, prev.uid
+	countHit(lw)
+	s.recent.hit(lw.ProjectID, prev.id, hits, now)
+	return true
+}
+
+// rememberAccess : 保存したアクセスを覚える（窓を過ぎたものは窓ごとにまとめて忘れる）
+func (s *Server) rememberAccess(lw *model.Write) {
+	if !s.collapsible(lw) {
+		return
+	}
+	now := lw.CreatedAt
+	s.collapse.mu.Lock()
+	defer s.collapse.mu.Unlock()
+	if now.Sub(s.collapse.lastSweep) >= s.cfg.CollapseWindow {
+		for k, a := range s.collapse.seen {
+			if now.Sub(a.lastHit) > s.cfg.CollapseWindow {
+				delete(s.collapse.seen, k)
+			}
+		}
+		s.collapse.lastSweep = now
+	}
+	s.collapse.seen[collapseKeyOf(lw)] = collapsedAccess{id: lw.ID, uid: lw.UID, createdAt: lw.CreatedAt, lastHit: now}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 期間の比較 (GET /api/stats/compare?period=7d。直近の期間とその前の同じ長さの期間)
+// ==========================================
+// アクセス数・訪問者・エラーを並べ、増減の割合までサーバーで計算する（ダッシュボードと日次のまとめで使う）
+
+// compareErrorsQuery : エラーとして数えるログ（error 以上のレベルか、5xx を報告したアクセス）
+const compareErrorsQuery = "level>=error OR status>=500"
+
+// compareRange : 比べる期間1つ
+type compareRange struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+}
+
+// compareMetric : 1つの数字の今の期間と前の期間
+type compareMetric struct {
+	Current  int      `json:"current"`
+	Previous int      `json:"previous"`
+	Delta    int      `json:"delta"`   // current - previous
+	Percent  *float64 `json:"percent"` // 前の期間からの増減 (%、小数1桁)。前の期間が0なら null
+}
+
+// newCompareMetric : 差と増減の割合を埋める
+func newCompareMetric(current, previous int) compareMetric {
+	m := compareMetric{Current: current, Previous: previous, Delta: current - previous}
+	if previous != 0 {
+		pct := math.Round(float64(m.Delta)/float64(previous)*1000) / 10
+		m.Percent = &pct
+	}
+	return m
+}
+
+// periodComparison : GET /api/stats/compare の結果
+type periodComparison struct {
+	Current  compareRange  `json:"current"`
+	Previous compareRange  `json:"previous"`
+	Hits     compareMetric `json:"hits"`
+	Uniques  compareMetric `json:"uniques"` // 訪問者IDか IP + UA で見分けた訪問者（/api/stats の sessions.visitors と同じ）
+	Errors   compareMetric `json:"errors"`  // level>=error OR status>=500
+}
+
+// periodTotals : 1つの期間の数字
+type periodTotals struct {
+	hits, uniques, errors int
+}
+
+// compareHandler : GET /api/stats/compare?period=7d&type=
+// ?since=&until= (RFC3339) でも期間を指定できる（前の期間は since の直前の同じ長さ）。?level= や ?query= の絞り込みは /api/logs と同じ
+func (s *Server) compareHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		loc, ok := s.responseLocation(w, r)
+		if !ok {
+			return
+		}
+		format, ok := negotiateFormat(w, r)
+		if !ok {
+			return
+		}
+		f, err := logFilterFromQuery(r.URL.Query(), projectID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Extrapolate = s.extrapolate(r)
+		now := s.clock.Now()
+		since, until, err := timeRangeFromQuery(r.URL.Query(), now, 7*24*time.Hour)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if until.IsZero() {
+			until = now
+		}
+
+		result, err := s.comparePeriods(r.Context(), f, since.Add(-until.Sub(since)), since, until)
+		if errors.Is(err, errInvalidQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+		inLocation(&result, loc)
+
+		writeFormatted(w, format, "compare", result)
+	})
+}
+
+// comparePeriods : [since, until) と、その前の [before, since) を比べる
+func (s *Server) comparePeriods(ctx context.Context, f store.LogFilter, before, since, until time.Time) (periodComparison, error) {
+	current, err := s.periodTotals(ctx, f, since, until)
+	if err != nil {
+		return periodComparison{}, err
+	}
+	previous, err := s.periodTotals(ctx, f, before, since)
+	if err != nil {
+		return periodComparison{}, err
+	}
+	return periodComparison{
+		Current:  compareRange{Since: since, Until: until},
+		Previous: compareRange{Since: before, Until: since},
+		Hits:     newCompareMetric(current.hits, previous.hits),
+		Uniques:  newCompareMetric(current.uniques, previous.uniques),
+		Errors:   newCompareMetric(current.errors, previous.errors),
+	}, nil
+}
+
+// periodTotals : [since, until) のアクセス数・訪問者・エラーの数
+func (s *Server) periodTotals(ctx context.Context, f store.LogFilter, since, until time.Time) (periodTotals, error) {
+	f.Since, f.Until, f.Limit = since.UTC(), until.UTC(), 0
+	var t periodTotals
+	var err error
+	if t.hits, err = s.store.CountLogs(ctx, f); err != nil {
+		return t, err
+	}
+	sessions, err := s.store.SessionStats(ctx, f, s.cfg.SessionGap)
+	if err != nil {
+		return t, err
+	}
+	t.uniques = sessions.Visitors
+
+	// 検索式があれば、エラーの条件と両方に合うものを数える
+	text := compareErrorsQuery
+	if f.Query != nil {
+		text = "(" + f.Query.String() + ") AND (" + compareErrorsQuery + ")"
+	}
+	if f.Query, err = store.ParseQuery(text); err != nil {
+		return t, fmt.Errorf("%w: query: %v", errInvalidQuery, err)
+	}
+	t.errors, err = s.store.CountLogs(ctx, f)
+	return t, err
+}
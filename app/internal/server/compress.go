@@ -0,0 +1,166 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ==========================================
+// レスポンスの圧縮 (Accept-Encoding: gzip / deflate)
+// ==========================================
+
+// minCompressBytes : Content-Length がこれより小さい応答は圧縮しない（圧縮しても小さくならない）
+const minCompressBytes = 1024
+
+var (
+	gzipWriters  = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	flateWriters = sync.Pool{New: func() any { w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression); return w }}
+)
+
+// compress : COMPRESS_RESPONSES=true の時、JSON・テキスト・画面のファイルを Accept-Encoding に合わせて圧縮する
+// SSE の購読と Range の指定があるリクエストはそのまま返す
+func (s *Server) compress(next http.Handler) http.Handler {
+	if !s.cfg.CompressResponses {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		w.Header().Add("Vary", "Accept-Encoding")
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" ||
+			strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding : Accept-Encoding（q=0 は受け付けない）から gzip・deflate の順に選ぶ
+func acceptedEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q > 0
+	}
+	if gzipOK, listed := accepted["gzip"]; gzipOK || !listed && accepted["*"] {
+		return "gzip"
+	}
+	if accepted["deflate"] {
+		return "deflate"
+	}
+	return ""
+}
+
+// compressible : 圧縮すると小さくなる種類か（画像・アーカイブなどは圧縮済み）
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/x-ndjson", "application/javascript", "application/xml",
+		"application/yaml", "application/x-yaml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// compressWriter : 最初に書く時に圧縮するか決め、圧縮するなら Content-Encoding を付けて書く
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	decided  bool
+	enc      interface {
+		io.WriteCloser
+		Flush() error
+	}
+}
+
+// decide : 状態コードとヘッダーから圧縮するか決める
+func (cw *compressWriter) decide(status int) {
+	cw.decided = true
+	h := cw.Header()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || !compressible(h.Get("Content-Type")) {
+		return
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < minCompressBytes {
+		return
+	}
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", cw.encoding)
+	switch cw.encoding {
+	case "gzip":
+		gw := gzipWriters.Get().(*gzip.Writer)
+		gw.Reset(cw.ResponseWriter)
+		cw.enc = gw
+	default:
+		fw := flateWriters.Get().(*flate.Writer)
+		fw.Reset(cw.ResponseWriter)
+		cw.enc = fw
+	}
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.decided {
+		cw.decide(status)
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		// Content-Type がなければ net/http と同じく中身から判定する
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush : NDJSON の書き出しなど、少しずつ送る応答のため
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter { return cw.ResponseWriter }
+
+// close : 圧縮の残りを書き出し、使った圧縮器を戻す
+func (cw *compressWriter) close() {
+	switch enc := cw.enc.(type) {
+	case *gzip.Writer:
+		enc.Close()
+		gzipWriters.Put(enc)
+	case *flate.Writer:
+		enc.Close()
+		flateWriters.Put(enc)
+	}
+	cw.enc = nil
+}
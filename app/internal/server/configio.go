@@ -0,0 +1,404 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 設定のエクスポート・インポート (YAML。ステージング→本番の移行用)
+// ==========================================
+
+// configDocumentVersion : YAMLの形式のバージョン
+const configDocumentVersion = 1
+
+// configDocument : エクスポートするYAMLの全体
+// プロジェクト・通知先・ルール・除外パターン・IPルールはインポートできる。environment は参照用（インポートしない）
+type configDocument struct {
+	Version     int               `yaml:"version"`
+	ExportedAt  time.Time         `yaml:"exported_at"`
+	Instance    string            `yaml:"instance,omitempty"`
+	Projects    []configProject   `yaml:"projects"`
+	Channels    []configChannel   `yaml:"channels"`
+	Rules       []configRule      `yaml:"rules"`
+	Exclusions  []configExclusion `yaml:"exclusions"`
+	IPRules     []configIPRule    `yaml:"ip_rules"`
+	Environment *configEnv        `yaml:"environment,omitempty"`
+}
+
+type configProject struct {
+	Name   string `yaml:"name"`
+	APIKey string `yaml:"api_key,omitempty"` // 既定では伏せる（空か伏せた値なら、インポート先で新しく発行する）
+}
+
+type configChannel struct {
+	Name     string            `yaml:"name"`
+	Type     string            `yaml:"type"`
+	URL      string            `yaml:"url,omitempty"`
+	Token    string            `yaml:"token,omitempty"`
+	ChatID   string            `yaml:"chat_id,omitempty"`
+	Settings map[string]string `yaml:"settings,omitempty"`
+	Enabled  bool              `yaml:"enabled"`
+	Rules    string            `yaml:"rules,omitempty"`
+	Template string            `yaml:"template,omitempty"`
+}
+
+// configRule : アラートルール（プロジェクトはIDではなく名前で指す）
+type configRule struct {
+	Name            string `yaml:"name"`
+	Kind            string `yaml:"kind"`
+	Project         string `yaml:"project"`
+	EventType       string `yaml:"event_type,omitempty"`
+	MinLevel        string `yaml:"min_level,omitempty"`
+	Field           string `yaml:"field,omitempty"`
+	Pattern         string `yaml:"pattern,omitempty"`
+	Query           string `yaml:"query,omitempty"`
+	Threshold       int    `yaml:"threshold,omitempty"`
+	WindowSeconds   int    `yaml:"window_seconds,omitempty"`
+	CooldownSeconds int    `yaml:"cooldown_seconds"`
+	Level           string `yaml:"level"`
+	Enabled         bool   `yaml:"enabled"`
+}
+
+type configExclusion struct {
+	Field   string `yaml:"field"`
+	Pattern string `yaml:"pattern"`
+	Scope   string `yaml:"scope"`
+	Note    string `yaml:"note,omitempty"`
+}
+
+type configIPRule struct {
+	CIDR   string `yaml:"cidr"`
+	Action string `yaml:"action"`
+	Note   string `yaml:"note,omitempty"`
+}
+
+// configEnv : 環境変数で決まる設定（参照用）
+type configEnv struct {
+	TrackedPaths []string          `yaml:"tracked_paths"`
+	NotifyRules  map[string]string `yaml:"notify_rules"`
+	Notifiers    []string          `yaml:"notifiers"`
+	ExcludeBots  string            `yaml:"exclude_bots"`
+	Retention    string            `yaml:"retention_days"`
+}
+
+// exportConfig : 現在の設定を configDocument にする（includeSecrets が false ならキーやトークンを伏せる）
+func (s *Server) exportConfig(ctx context.Context, includeSecrets bool) (*configDocument, error) {
+	doc := &configDocument{
+		Version:    configDocumentVersion,
+		ExportedAt: s.clock.Now().UTC(),
+		Instance:   s.cfg.InstanceName,
+		Projects:   []configProject{},
+		Channels:   []configChannel{},
+		Rules:      []configRule{},
+		Exclusions: []configExclusion{},
+		IPRules:    []configIPRule{},
+	}
+
+	projects, err := s.store.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var keys map[int]string
+	if includeSecrets {
+		if keys, err = s.store.ProjectKeys(ctx); err != nil {
+			return nil, err
+		}
+	}
+	projectNames := map[int]string{}
+	for _, p := range projects {
+		projectNames[p.ID] = p.Name
+		key := redacted
+		if includeSecrets {
+			key = keys[p.ID]
+		}
+		doc.Projects = append(doc.Projects, configProject{Name: p.Name, APIKey: key})
+	}
+
+	channels, err := s.store.ListChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range channels {
+		if !includeSecrets {
+			c = redactChannel(c)
+		}
+		doc.Channels = append(doc.Channels, configChannel{
+			Name: c.Name, Type: c.Type, URL: c.URL, Token: c.Token, ChatID: c.ChatID, Settings: c.Settings, Enabled: c.Enabled, Rules: c.Rules, Template: c.Template,
+		})
+	}
+
+	rules, err := s.store.ListAlertRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		doc.Rules = append(doc.Rules, configRule{
+			Name: r.Name, Kind: r.Kind, Project: projectNames[r.ProjectID], EventType: r.EventType, MinLevel: r.MinLevel,
+			Field: r.Field, Pattern: r.Pattern, Query: r.Query, Threshold: r.Threshold, WindowSeconds: r.WindowSeconds,
+			CooldownSeconds: r.CooldownSeconds, Level: r.Level, Enabled: r.Enabled,
+		})
+	}
+
+	exclusions, err := s.store.ListExclusions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range exclusions {
+		doc.Exclusions = append(doc.Exclusions, configExclusion{Field: e.Field, Pattern: e.Pattern, Scope: e.Scope, Note: e.Note})
+	}
+
+	ipRules, err := s.store.ListIPRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range ipRules {
+		doc.IPRules = append(doc.IPRules, configIPRule{CIDR: r.CIDR, Action: r.Action, Note: r.Note})
+	}
+
+	t := s.tunables()
+	env := &configEnv{NotifyRules: t.NotifyRules, ExcludeBots: t.ExcludeBots, Retention: fmt.Sprint(t.RetentionDays)}
+	for _, tp := range s.cfg.TrackedPaths {
+		env.TrackedPaths = append(env.TrackedPaths, tp.Pattern+"="+tp.EventType)
+	}
+	if m, ok := s.envNotifier().(interface{ Names() []string }); ok {
+		env.Notifiers = m.Names()
+	}
+	doc.Environment = env
+	return doc, nil
+}
+
+// configImportReport : インポートの結果
+type configImportReport struct {
+	Created       map[string]int    `json:"created"`
+	Updated       map[string]int    `json:"updated"`
+	Skipped       []string          `json:"skipped"`
+	GeneratedKeys map[string]string `json:"generated_keys,omitempty"` // 新しく発行したプロジェクトのキー（この応答でしか返さない）
+}
+
+// importConfig : YAMLの内容を取り込む
+// 同じ名前のプロジェクト・通知先・ルールは上書きし（プロジェクトのキーは変えない）、同じ除外パターンは飛ばす
+// 1件ずつ保存するので、途中で失敗した場合はそれまでの分が反映される
+func (s *Server) importConfig(ctx context.Context, doc *configDocument) (*configImportReport, error) {
+	report := &configImportReport{Created: map[string]int{}, Updated: map[string]int{}, Skipped: []string{}}
+	if doc.Version != configDocumentVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", errInvalidQuery, doc.Version)
+	}
+
+	// プロジェクト（名前で対応付ける）
+	projects, err := s.store.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	projectIDs := map[string]int{}
+	for _, p := range projects {
+		projectIDs[p.Name] = p.ID
+	}
+	for _, p := range doc.Projects {
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			report.Skipped = append(report.Skipped, "project without name")
+			continue
+		}
+		if _, ok := projectIDs[name]; ok {
+			continue
+		}
+		key := p.APIKey
+		if key == "" || strings.HasSuffix(key, redacted) {
+			if key, err = newAPIKey(); err != nil {
+				return nil, err
+			}
+			if report.GeneratedKeys == nil {
+				report.GeneratedKeys = map[string]string{}
+			}
+			report.GeneratedKeys[name] = key
+		}
+		created, err := s.store.CreateProject(ctx, name, key)
+		if err != nil {
+			return nil, fmt.Errorf("project %q: %w", name, err)
+		}
+		projectIDs[name] = created.ID
+		report.Created["projects"]++
+	}
+
+	// 通知先（名前と種類で対応付ける。伏せた値は上書きしない）
+	channels, err := s.store.ListChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existingChannels := map[string]model.Channel{}
+	for _, c := range channels {
+		existingChannels[c.Type+"/"+c.Name] = c
+	}
+	for _, in := range doc.Channels {
+		enabled := in.Enabled
+		url, token, chatID, rules, tmpl := in.URL, in.Token, in.ChatID, in.Rules, in.Template
+		req := channelRequest{Name: &in.Name, Type: &in.Type, URL: &url, Token: &token, ChatID: &chatID, Settings: in.Settings, Enabled: &enabled, Rules: &rules, Template: &tmpl}
+		c, exists := existingChannels[in.Type+"/"+in.Name]
+		req.apply(&c)
+		if err := s.validateChannel(c); err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("channel %q: %v (export with include_secrets=true to copy secrets)", in.Name, err))
+			continue
+		}
+		if exists {
+			err = s.store.UpdateChannel(ctx, &c)
+			report.Updated["channels"]++
+		} else {
+			err = s.store.CreateChannel(ctx, &c)
+			report.Created["channels"]++
+		}
+		if err != nil {
+			return nil, fmt.Errorf("channel %q: %w", in.Name, err)
+		}
+	}
+
+	// アラートルール（名前で対応付ける）
+	rules, err := s.store.ListAlertRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existingRules := map[string]model.AlertRule{}
+	for _, r := range rules {
+		existingRules[r.Name] = r
+	}
+	for _, in := range doc.Rules {
+		projectID, ok := projectIDs[in.Project]
+		if !ok {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("rule %q: unknown project %q", in.Name, in.Project))
+			continue
+		}
+		r, exists := existingRules[in.Name]
+		r.Name, r.Kind, r.ProjectID, r.EventType, r.MinLevel = in.Name, in.Kind, projectID, in.EventType, in.MinLevel
+		r.Field, r.Pattern, r.Query, r.Threshold, r.WindowSeconds = in.Field, in.Pattern, in.Query, in.Threshold, in.WindowSeconds
+		r.CooldownSeconds, r.Level, r.Enabled = in.CooldownSeconds, in.Level, in.Enabled
+		if r.Level == "" {
+			r.Level = "warn"
+		}
+		if _, err := compileRule(r); err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("rule %q: %v", in.Name, err))
+			continue
+		}
+		if exists {
+			err = s.store.UpdateAlertRule(ctx, &r)
+			report.Updated["rules"]++
+		} else {
+			err = s.store.CreateAlertRule(ctx, &r)
+			report.Created["rules"]++
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", in.Name, err)
+		}
+	}
+
+	// 除外パターン（項目とパターンが同じものは飛ばす）
+	exclusions, err := s.store.ListExclusions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existingExclusions := map[string]bool{}
+	for _, e := range exclusions {
+		existingExclusions[e.Field+"\x00"+e.Pattern] = true
+	}
+	for _, in := range doc.Exclusions {
+		if existingExclusions[in.Field+"\x00"+in.Pattern] {
+			continue
+		}
+		e := model.Exclusion{Field: in.Field, Pattern: in.Pattern, Scope: in.Scope, Note: in.Note}
+		if e.Scope == "" {
+			e.Scope = excludeAll
+		}
+		if _, err := compileExclusion(e); err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("exclusion %q: %v", in.Pattern, err))
+			continue
+		}
+		if err := s.store.CreateExclusion(ctx, &e); err != nil {
+			return nil, fmt.Errorf("exclusion %q: %w", in.Pattern, err)
+		}
+		existingExclusions[in.Field+"\x00"+in.Pattern] = true
+		report.Created["exclusions"]++
+	}
+
+	// IPルール（範囲と動作が同じものは飛ばす）
+	ipRules, err := s.store.ListIPRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existingIPRules := map[string]bool{}
+	for _, r := range ipRules {
+		existingIPRules[r.Action+" "+r.CIDR] = true
+	}
+	for _, in := range doc.IPRules {
+		rule := model.IPRule{CIDR: in.CIDR, Action: in.Action, Note: in.Note}
+		if rule.Action == "" {
+			rule.Action = "deny"
+		}
+		c, err := compileIPRule(rule)
+		if err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("ip rule %q: %v", in.CIDR, err))
+			continue
+		}
+		rule.CIDR = c.CIDR
+		if existingIPRules[rule.Action+" "+rule.CIDR] {
+			continue
+		}
+		if err := s.store.CreateIPRule(ctx, &rule); err != nil {
+			return nil, fmt.Errorf("ip rule %q: %w", in.CIDR, err)
+		}
+		existingIPRules[rule.Action+" "+rule.CIDR] = true
+		report.Created["ip_rules"]++
+	}
+
+	s.reloadChannelsAfterChange(ctx)
+	s.reloadRulesAfterChange(ctx)
+	s.reloadExclusionsAfterChange(ctx)
+	s.reloadIPRulesAfterChange(ctx)
+	sort.Strings(report.Skipped)
+	return report, nil
+}
+
+// exportConfigHandler : GET /api/admin/config?include_secrets=true
+func (s *Server) exportConfigHandler(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.exportConfig(r.Context(), r.URL.Query().Get("include_secrets") == "true")
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Content-Disposition", `attachment; filename="go-logger-config.yaml"`)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	enc.Encode(doc)
+	enc.Close()
+}
+
+// importConfigHandler : PUT /api/admin/config （本文はエクスポートしたYAML）
+func (s *Server) importConfigHandler(w http.ResponseWriter, r *http.Request) {
+	var doc configDocument
+	if err := yaml.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&doc); err != nil {
+		http.Error(w, "Invalid YAML: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := s.importConfig(r.Context(), &doc)
+	if errors.Is(err, errInvalidQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 書き込み直後の読み出し (READ_AFTER_WRITE=true。「pingしたのに表示されない」をなくす)
+// ==========================================
+
+// writeTokenHeader : 書き込みの結果で返すトークン（レスポンスの write_token と同じ値）
+const writeTokenHeader = "X-Write-Token"
+
+// readAfterHeader : 読み出しに付けるトークン（?after= と同じ）
+const readAfterHeader = "X-Read-After"
+
+// writeToken : 書き込みに返すトークン（採番前でも決まっている uid をそのまま使う）
+// 無効なら空
+func (s *Server) writeToken(w http.ResponseWriter, uid string) string {
+	if !s.cfg.ReadAfterWrite || uid == "" {
+		return ""
+	}
+	w.Header().Set(writeTokenHeader, uid)
+	return uid
+}
+
+// readAfterToken : 読み出しに付いたトークン（無効なら空）
+func (s *Server) readAfterToken(r *http.Request) string {
+	if !s.cfg.ReadAfterWrite {
+		return ""
+	}
+	if token := r.URL.Query().Get("after"); token != "" {
+		return token
+	}
+	return r.Header.Get(readAfterHeader)
+}
+
+// awaitWrite : トークンの書き込みが読み出せるようになるまで待つ（READ_AFTER_WRITE_TIMEOUT まで）
+// DBの再接続中にバッファへ入った書き込みは、書き戻されるまで待つことになる
+// 見えるようになったらキャッシュを捨て、次の読み出しをDBから行わせる
+func (s *Server) awaitWrite(ctx context.Context, projectID int, token string) {
+	if s.recent.contains(projectID, token) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.ReadAfterWriteTimeout)
+	defer cancel()
+	delay := 20 * time.Millisecond
+	for {
+		n, err := s.store.CountLogs(ctx, store.LogFilter{ProjectID: projectID, UID: token})
+		if err == nil && n > 0 {
+			s.recent.invalidateProject(projectID)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			// 間に合わなければ今見えている分を返す
+			return
+		case <-s.clock.After(delay):
+		}
+		delay = min(delay*2, 500*time.Millisecond)
+	}
+}
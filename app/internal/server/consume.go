@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/stream"
+)
+
+// ==========================================
+// Redis Stream / NATS からの取り込み
+// ==========================================
+// 1件の形は POST /api/logs と同じ。INGEST_BATCH_SIZE 件ずつ読み、1つのトランザクションで保存してから Commit する
+// 保存できなかった分（DBの再接続中でバッファも一杯など）は Commit せず、少し待ってから読み直す
+
+// consumeRetryDelay : 読み元・DBのエラーの後に待つ時間
+const consumeRetryDelay = 5 * time.Second
+
+// consumeStream : ctx が終わるまで読み元から取り込み続ける（読み元がなければ何もしない）
+func (s *Server) consumeStream(ctx context.Context) {
+	if s.consumer == nil {
+		return
+	}
+	defer s.consumer.Close()
+	fmt.Println("Consuming logs from", s.consumer.Name())
+
+	for ctx.Err() == nil {
+		msgs, err := s.consumer.Fetch(ctx, max(s.cfg.IngestBatchSize, 1))
+		if err == nil && len(msgs) > 0 {
+			err = s.ingestMessages(ctx, msgs)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Printf("Failed to consume logs from %s: %v\n", s.consumer.Name(), err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.clock.After(consumeRetryDelay):
+			}
+		}
+	}
+}
+
+// ingestMessages : 読んだ分を保存し、保存できた（バッファに退避できた）分を Commit する
+// 形が不正なものとプロジェクトキーが不明なものは、読み直しても直らないので捨てて Commit する
+func (s *Server) ingestMessages(ctx context.Context, msgs []stream.Message) error {
+	now := s.clock.Now()
+	var done, saving []stream.Message
+	var pending []*model.Write
+	for _, m := range msgs {
+		lw, ok, err := s.messageWrite(ctx, m, now)
+		if err != nil {
+			s.consumer.Commit(ctx, msgs, 0)
+			return err
+		}
+		if !ok {
+			done = append(done, m)
+			continue
+		}
+		// 除外・ドライラン・既存の行にまとめたものは、ここで取り込み済みになる
+		if _, stored, ok := s.prepareWrite(ctx, lw); !ok {
+			if stored {
+				s.notifyLog(ctx, lw)
+			}
+			done = append(done, m)
+			continue
+		}
+		saving = append(saving, m)
+		pending = append(pending, lw)
+	}
+
+	// 先頭から n 件が保存できた（バッファに退避できた）分。残りは Commit せずに読み直す
+	result, n, saveErr := s.store.SaveLogs(ctx, pending)
+	for _, lw := range pending[:n] {
+		if _, stored := s.savedWrite(lw, result, nil); stored {
+			s.notifyLog(ctx, lw)
+		}
+	}
+	done = append(done, saving[:n]...)
+	if err := s.consumer.Commit(ctx, append(done, saving[n:]...), len(done)); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	if saveErr != nil {
+		return fmt.Errorf("save: %w", saveErr)
+	}
+	return nil
+}
+
+// messageWrite : 1件を書き込みにする。捨てるなら ok=false（DBのエラーの時だけ err）
+func (s *Server) messageWrite(ctx context.Context, m stream.Message, now time.Time) (*model.Write, bool, error) {
+	projectID := model.DefaultProjectID
+	if m.Key != "" {
+		id, err := s.projectIDByKey(ctx, m.Key)
+		if errors.Is(err, errUnknownProjectKey) {
+			fmt.Printf("Dropping consumed log %s: %v\n", m.ID, err)
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		projectID = id
+	} else if s.cfg.RequireProjectKey {
+		fmt.Printf("Dropping consumed log %s: %v\n", m.ID, errUnknownProjectKey)
+		return nil, false, nil
+	}
+	if len(m.Data) > maxLogBodyBytes {
+		fmt.Printf("Dropping consumed log %s: body exceeds %d bytes\n", m.ID, maxLogBodyBytes)
+		return nil, false, nil
+	}
+	lb, err := parseLogBody(m.Data, now)
+	if err != nil {
+		fmt.Printf("Dropping consumed log %s: %v\n", m.ID, err)
+		return nil, false, nil
+	}
+	return &model.Write{
+		ProjectID: projectID,
+		EventType: logEventType,
+		Level:     lb.Level,
+		Message:   lb.Message,
+		Fields:    lb.Fields,
+		CreatedAt: now,
+		ExpiresAt: lb.ExpiresAt,
+	}, true, nil
+}
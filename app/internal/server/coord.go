@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"go-logger/internal/config"
+	"go-logger/internal/coord"
+	"go-logger/internal/i18n"
+	"go-logger/internal/notify"
+)
+
+// ==========================================
+// 接続元ごとの書き込みの上限 (INGEST_RATE_LIMIT)
+// ==========================================
+// COORD_BACKEND=redis なら、プロキシの後ろの全てのインスタンスで合わせて数える
+
+// ingestRateLimitFromEnv : INGEST_RATE_LIMIT="120/1m"（既定は制限しない）
+func ingestRateLimitFromEnv() coord.Limit {
+	l, err := coord.ParseLimit(config.String("INGEST_RATE_LIMIT", ""))
+	if err != nil {
+		fmt.Println("Ignoring INGEST_RATE_LIMIT:", err)
+		return coord.Limit{}
+	}
+	return l
+}
+
+// rateLimit : 接続元のIPごとに INGEST_RATE_LIMIT を超えた書き込みを 429 で断る
+// 数えられない場合（Redis に繋がらないなど）は断らずに通す
+func (s *Server) rateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := s.tunables().IngestRateLimit
+		if !limit.Enabled() {
+			next(w, r)
+			return
+		}
+		ok, retryAfter, err := s.coord.Allow(r.Context(), "ingest:"+clientIP(r), limit)
+		if err != nil {
+			fmt.Println("Rate limit check failed:", err)
+		}
+		if !ok {
+			seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			writeJSONError(w, http.StatusTooManyRequests, i18n.T(w, r, i18n.RateLimited, limit))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ==========================================
+// 通知を1回だけ送る印
+// ==========================================
+// 件数のルール・まとめはどのインスタンスも同じDBを見て判断するので、印を付けられたインスタンスだけが送る
+// 印を置く場所は NOTIFY_DEDUP で選ぶ
+//
+//	coord     COORD_BACKEND の置き場所（既定。memory ならインスタンスごと、redis なら全てのインスタンスで共有）
+//	database  Postgres の notification_keys（Redis を用意しなくても、同じDBを使うインスタンスで共有）
+//
+// NOTIFY_DEDUP_WINDOW を設定すると、Key のある通知（ルール・稼働監視・データ量・インシデントの解決など）を
+// Key ごとにその期間に1回だけ送る。クールダウンが0のルールの印もこの期間は残す
+
+var notificationsDeduplicatedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "go_logger_notifications_deduplicated_total",
+	Help: "Notifications skipped because another instance already sent them, by source.",
+}, []string{"source"})
+
+// notifyDedupFromEnv : NOTIFY_DEDUP（coord / database、既定 coord。不正なら coord）
+func notifyDedupFromEnv() string {
+	switch kind := config.String("NOTIFY_DEDUP", "coord"); kind {
+	case "coord", "database":
+		return kind
+	default:
+		fmt.Printf("Ignoring NOTIFY_DEDUP: unknown backend %q (use coord or database)\n", kind)
+		return "coord"
+	}
+}
+
+// claimOnce : key の通知をこのインスタンスが送ってよいか（ttl の間にほかのインスタンスが送っていれば false）
+// ttl は NOTIFY_DEDUP_WINDOW より短くしない。印を確かめられない場合は送る（重なって届く方が、届かないよりよい）
+func (s *Server) claimOnce(ctx context.Context, key string, ttl time.Duration) bool {
+	ttl = max(ttl, s.cfg.NotifyDedupWindow)
+	var ok bool
+	var err error
+	if s.cfg.NotifyDedup == "database" {
+		if ttl <= 0 {
+			return true
+		}
+		now := s.clock.Now()
+		ok, err = s.store.ClaimNotificationKey(ctx, "notify:"+key, s.cfg.InstanceName, now, now.Add(ttl))
+	} else {
+		ok, err = s.coord.Claim(ctx, "notify:"+key, ttl)
+	}
+	if err != nil {
+		fmt.Println("Notification dedup check failed:", err)
+		return true
+	}
+	return ok
+}
+
+// dedupNotification : NOTIFY_DEDUP_WINDOW の間に同じ Key の通知をどのインスタンスも送っていなければ true
+// Key のない通知と、インスタンスごとの件数を知らせる通知（NOTIFY_THROTTLE のまとめ）はいつも送る
+func (s *Server) dedupNotification(ctx context.Context, n notify.Notification) bool {
+	if s.cfg.NotifyDedupWindow <= 0 || n.Key == "" || n.Source == "throttle" {
+		return true
+	}
+	key := "event:" + n.Key
+	if n.Resolved {
+		key += ":resolved"
+	}
+	if s.claimOnce(ctx, key, s.cfg.NotifyDedupWindow) {
+		return true
+	}
+	source := n.Source
+	if source == "" {
+		source = "log"
+	}
+	notificationsDeduplicatedCounter.WithLabelValues(source).Inc()
+	return false
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 件数 (GET /api/logs/count。ダッシュボードの見出しの数字用)
+// ==========================================
+// ログを読まずに件数だけ返す。?distinct=ip なら値の種類の数（ユニーク数）
+// ?estimate=true なら数えずに見積もる（Postgres はプランナーの見積もり、ClickHouse の種類の数は uniq）
+
+// logCount : GET /api/logs/count の結果
+type logCount struct {
+	Count    int        `json:"count"`
+	Distinct string     `json:"distinct,omitempty"`
+	Exact    bool       `json:"exact"` // false なら見積もり
+	Since    *time.Time `json:"since,omitempty"`
+	Until    *time.Time `json:"until,omitempty"`
+}
+
+// countHandler : GET /api/logs/count?from=&to=&distinct=ip&estimate=true
+// 期間は from / to (RFC3339) か ?period=7d（どれもなければ全期間）。?type= や ?query= の絞り込みは /api/logs と同じ
+// distinct には /api/stats/top の by と同じ列を使える
+func (s *Server) countHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		loc, ok := s.responseLocation(w, r)
+		if !ok {
+			return
+		}
+		format, ok := negotiateFormat(w, r)
+		if !ok {
+			return
+		}
+		query := r.URL.Query()
+		distinct := strings.ToLower(query.Get("distinct"))
+		if distinct != "" {
+			if _, ok := store.GroupByColumns[strings.ToUpper(distinct)]; !ok {
+				names := make([]string, 0, len(store.GroupByColumns))
+				for name := range store.GroupByColumns {
+					names = append(names, strings.ToLower(name))
+				}
+				sort.Strings(names)
+				http.Error(w, fmt.Sprintf(`Invalid "distinct" (use %s)`, strings.Join(names, ", ")), http.StatusBadRequest)
+				return
+			}
+			// 読み手から隠している項目は、種類の数としても返さない
+			field := distinct
+			if f, ok := topField[distinct]; ok {
+				field = f
+			}
+			if s.redact.Hidden(field, s.requestScope(r)) {
+				http.Error(w, fmt.Sprintf("%s is redacted for this reader", field), http.StatusForbidden)
+				return
+			}
+		}
+		estimate := false
+		if v := query.Get("estimate"); v != "" {
+			var err error
+			if estimate, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, `Invalid "estimate" (use true or false)`, http.StatusBadRequest)
+				return
+			}
+		}
+
+		f, err := logFilterFromQuery(query, projectID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if f.Since, f.Until, err = countRangeFromQuery(query, s.clock.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result := logCount{Distinct: distinct, Exact: true}
+		column := strings.ToUpper(distinct)
+		switch {
+		case estimate:
+			result.Count, result.Exact, err = s.store.EstimateCount(r.Context(), f, column)
+		case distinct != "":
+			result.Count, err = s.store.CountDistinct(r.Context(), f, column)
+		default:
+			f.Extrapolate = s.extrapolate(r)
+			result.Count, err = s.store.CountLogs(r.Context(), f)
+		}
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+		if !f.Since.IsZero() {
+			result.Since = &f.Since
+		}
+		if !f.Until.IsZero() {
+			result.Until = &f.Until
+		}
+		inLocation(&result, loc)
+
+		writeFormatted(w, format, "count", result)
+	})
+}
+
+// countRangeFromQuery : 期間の始まり (since / from / period) があれば timeRangeFromQuery と同じく読む
+// until / to だけならそれより前の全て、どれもなければ全期間（ゼロのまま）
+func countRangeFromQuery(query url.Values, now time.Time) (since, until time.Time, err error) {
+	for _, name := range []string{"since", "from", "period"} {
+		if query.Get(name) != "" {
+			return timeRangeFromQuery(query, now, 0)
+		}
+	}
+	for _, name := range []string{"until", "to"} {
+		if v := query.Get(name); v != "" {
+			if until, err = time.Parse(time.RFC3339, v); err != nil {
+				return since, until, fmt.Errorf("%w: %s: use RFC3339 (e.g. 2026-01-02T15:04:05Z)", errInvalidQuery, name)
+			}
+			return since, until, nil
+		}
+	}
+	return since, until, nil
+}
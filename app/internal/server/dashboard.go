@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+
+	"go-logger/internal/auth"
+	"go-logger/static"
+)
+
+// ==========================================
+// ダッシュボードの認証 (画面と読み出しAPI)
+// ==========================================
+
+// requireDashboard : Deps.Auth で認証したリクエストだけを通す（Auth が nil なら誰でも見られる）
+// 有効なプロジェクトキー付きのリクエスト（他サービス・PEERS からの読み出し）はログインなしで通す
+func (s *Server) requireDashboard(next http.Handler) http.Handler {
+	login := next
+	if s.auth != nil {
+		login = auth.Middleware(s.auth, s.requireUserPermission(permLogsRead, next))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// キーはログインの代わりになる（ingester だけのキーでは読めない）
+		if roles, ok := s.keyRoles(r); ok {
+			if !allows(roles, permLogsRead) {
+				forbidRoles(w, roles, permLogsRead)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		login.ServeHTTP(w, r)
+	})
+}
+
+// dashboardFunc : requireDashboard の HandlerFunc 版
+func (s *Server) dashboardFunc(next http.HandlerFunc) http.Handler {
+	return s.requireDashboard(next)
+}
+
+// staticFiles : 画面のファイル（STATIC_DIR があればディスクから読み、画面を作り直しながら確かめられる）
+func (s *Server) staticFiles() http.FileSystem {
+	if s.cfg.StaticDir != "" {
+		return http.Dir(s.cfg.StaticDir)
+	}
+	return http.FS(static.Files)
+}
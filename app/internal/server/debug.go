@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// プロファイルと実行時の状態 (pprof / expvar)
+// ==========================================
+// 書き込みのバッファが溜まった時などに、本番のメモリと goroutine の増え方を調べる
+//
+//	DEBUG_ENDPOINTS=true : /api/admin/debug/pprof/ と /api/admin/debug/vars（ADMIN_TOKEN が必要）
+//	DEBUG_ADDR=127.0.0.1:6060 : 別のポートで /debug/pprof/ と /debug/vars（認証なし。外から繋がらないアドレスにする）
+//
+// go tool pprof はヘッダーを付けられないので、/api/admin/... は curl で保存してから読む。直接繋ぐなら DEBUG_ADDR を使う
+//
+//	curl -H "Authorization: Bearer $ADMIN_TOKEN" -o heap.pb.gz https://host/api/admin/debug/pprof/heap
+//	go tool pprof -http : http://127.0.0.1:6060/debug/pprof/goroutine
+
+// debugPprofPrefix : 管理APIの pprof の接頭辞
+const debugPprofPrefix = "/api/admin/debug/pprof/"
+
+// runtimeStats : /debug/vars の go_logger
+type runtimeStats struct {
+	GoVersion      string  `json:"go_version"`
+	GOMAXPROCS     int     `json:"gomaxprocs"`
+	Goroutines     int     `json:"goroutines"`
+	HeapAllocBytes uint64  `json:"heap_alloc_bytes"`
+	HeapObjects    uint64  `json:"heap_objects"`
+	SysBytes       uint64  `json:"sys_bytes"`
+	NumGC          uint32  `json:"num_gc"`
+	LastGCPauseMS  float64 `json:"last_gc_pause_ms"`
+	BufferedWrites *int    `json:"buffered_writes,omitempty"` // DB の再接続を待っている書き込み（数えられなければ省く）
+	NotifyPending  *int    `json:"notify_pending,omitempty"`  // 通知の送信待ち
+	NotifyRetrying int64   `json:"notify_retrying"`
+	WebhookPending int     `json:"webhook_pending"` // Webhook の送信待ちのログ
+}
+
+// collectRuntimeStats : いまの goroutine 数・メモリとキューの長さ
+func (s *Server) collectRuntimeStats(ctx context.Context) runtimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := runtimeStats{
+		GoVersion:      runtime.Version(),
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: mem.HeapAlloc,
+		HeapObjects:    mem.HeapObjects,
+		SysBytes:       mem.Sys,
+		NumGC:          mem.NumGC,
+		LastGCPauseMS:  float64(mem.PauseNs[(mem.NumGC+255)%256]) / float64(time.Millisecond),
+		NotifyRetrying: s.notifyRetrying.Load(),
+		WebhookPending: len(s.webhooks.entries),
+	}
+	if n, err := s.store.BufferedWrites(ctx); err == nil {
+		stats.BufferedWrites = &n
+	}
+	if n, err := s.queue.Len(ctx); err == nil {
+		stats.NotifyPending = &n
+	}
+	return stats
+}
+
+// debugVarsHandler : expvar の変数 (cmdline / memstats など) に go_logger を足して返す
+func (s *Server) debugVarsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := map[string]any{}
+	expvar.Do(func(kv expvar.KeyValue) {
+		vars[kv.Key] = json.RawMessage(kv.Value.String())
+	})
+	vars["go_logger"] = s.collectRuntimeStats(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vars)
+}
+
+// debugPprofHandler : /api/admin/debug/pprof/{name} を net/http/pprof に渡す
+// pprof.Index は /debug/pprof/ の後ろをプロファイル名として読むので、パスを置き換えてから渡す
+func debugPprofHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, debugPprofPrefix)
+	switch name {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/debug/pprof/" + name
+		pprof.Index(w, r2)
+	}
+}
+
+// mountDebug : DEBUG_ENDPOINTS=true なら管理APIに pprof と expvar を足す
+func (s *Server) mountDebug(mux *http.ServeMux) {
+	if !s.cfg.DebugEndpoints {
+		return
+	}
+	mux.HandleFunc(debugPprofPrefix, s.requireAdmin(debugPprofHandler))
+	mux.HandleFunc("GET /api/admin/debug/vars", s.requireAdmin(s.debugVarsHandler))
+}
+
+// DebugHandler : DEBUG_ADDR で待ち受ける別のポート用（認証なし）
+func (s *Server) DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("GET /debug/vars", s.debugVarsHandler)
+	return mux
+}
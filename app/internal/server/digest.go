@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // alpine のイメージにはタイムゾーンのDBがないため
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 日次のまとめ (DIGEST_TIME に、前日のアクセス数・訪問者・上位のページとUAを前々日と比べて通知する)
+// ==========================================
+
+// digestTop : 上位を何件載せるか
+const digestTop = 5
+
+// DigestConfig : 日次のまとめの設定
+type DigestConfig struct {
+	Hour, Minute int            // 送る時刻（Location の時刻）
+	Location     *time.Location // nil なら送らない
+	EventType    string         // 対象の種別（空なら全種別）
+}
+
+// DigestFromEnv : DIGEST_TIME (例: 09:00) を設定した時だけ有効にする
+//
+//	DIGEST_TIME        送る時刻 (HH:MM)
+//	DIGEST_TIMEZONE    時刻と「前日」の区切りに使うタイムゾーン（既定は TIMEZONE、それもなければ UTC。例: Asia/Tokyo）
+//	DIGEST_EVENT_TYPE  対象の種別（既定は全種別）
+func DigestFromEnv() DigestConfig {
+	cfg := DigestConfig{EventType: config.String("DIGEST_EVENT_TYPE", "")}
+	raw := config.String("DIGEST_TIME", "")
+	if raw == "" {
+		return cfg
+	}
+	enabled, err := cfg.withTime(raw)
+	if err != nil {
+		fmt.Printf("Ignoring DIGEST_TIME: %v\n", err)
+		return cfg
+	}
+	return enabled
+}
+
+// withTime : 送る時刻を HH:MM に変えたもの（空なら送らない。/api/settings の digest_time からも使う）
+func (c DigestConfig) withTime(raw string) (DigestConfig, error) {
+	if raw == "" {
+		c.Location = nil
+		return c, nil
+	}
+	at, err := time.Parse("15:04", raw)
+	if err != nil {
+		return c, fmt.Errorf("invalid digest time %q (use HH:MM, e.g. 09:00)", raw)
+	}
+	loc := c.Location
+	if loc == nil {
+		if loc, err = time.LoadLocation(config.String("DIGEST_TIMEZONE", config.String("TIMEZONE", "UTC"))); err != nil {
+			return c, err
+		}
+	}
+	c.Hour, c.Minute, c.Location = at.Hour(), at.Minute(), loc
+	return c, nil
+}
+
+// timeString : 送る時刻 (HH:MM。送らなければ空)
+func (c DigestConfig) timeString() string {
+	if c.Location == nil {
+		return ""
+	}
+	return fmt.Sprintf("%02d:%02d", c.Hour, c.Minute)
+}
+
+// scheduledAt : t の日の送る時刻
+func (c DigestConfig) scheduledAt(t time.Time) time.Time {
+	local := t.In(c.Location)
+	return time.Date(local.Year(), local.Month(), local.Day(), c.Hour, c.Minute, 0, 0, c.Location)
+}
+
+// digestState : 最後に送った日（同じ日に2回送らない）
+type digestState struct {
+	mu       sync.Mutex
+	lastSent string // 2006-01-02（DIGEST_TIMEZONE の日付）
+}
+
+// watchDigest : 1分ごとに時刻を確かめ、その日の送る時刻を過ぎていれば1回だけ送る
+// 送る時刻より後に起動した日は送らない（再起動で同じまとめを送り直さないため）
+// /api/settings で時刻を変えた・有効にした日も、変えた時点で時刻を過ぎていれば送らない
+func (s *Server) watchDigest(ctx context.Context) {
+	ticker := s.clock.NewTicker(time.Minute)
+	defer ticker.Stop()
+	var j *job
+	var current DigestConfig
+	observe := func(cfg DigestConfig) {
+		if cfg == current {
+			return
+		}
+		current = cfg
+		if cfg.Location == nil {
+			return
+		}
+		if j == nil {
+			j = s.jobs.register("digest", 24*time.Hour).leaderOnly()
+		}
+		if now := s.clock.Now(); !now.Before(cfg.scheduledAt(now)) {
+			s.digest.mu.Lock()
+			s.digest.lastSent = now.In(cfg.Location).Format(time.DateOnly)
+			s.digest.mu.Unlock()
+		}
+	}
+
+	observe(s.tunables().Digest)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		cfg := s.tunables().Digest
+		observe(cfg)
+		if cfg.Location == nil {
+			continue
+		}
+		now := s.clock.Now()
+		today := now.In(cfg.Location).Format(time.DateOnly)
+		s.digest.mu.Lock()
+		due := now.After(cfg.scheduledAt(now)) && s.digest.lastSent != today
+		if due {
+			s.digest.lastSent = today
+		}
+		s.digest.mu.Unlock()
+		if !due {
+			continue
+		}
+		// 複数のインスタンスで動かしても、その日のまとめは1つのインスタンスだけが送る
+		if !s.claimOnce(ctx, "digest:"+today, 24*time.Hour) {
+			continue
+		}
+		s.runJob(j, func() error { return s.sendDigests(ctx, now) })
+	}
+}
+
+// sendDigests : 全てのプロジェクトのまとめを送る（アクセスのなかったプロジェクトは送らない）
+func (s *Server) sendDigests(ctx context.Context, now time.Time) error {
+	projects, err := s.store.ListProjects(ctx)
+	if err != nil {
+		fmt.Println("Daily digest failed:", err)
+		return err
+	}
+	for _, p := range projects {
+		text, err := s.buildDigest(ctx, p.ID, p.Name, now)
+		if err != nil {
+			fmt.Printf("Daily digest failed for project %d: %v\n", p.ID, err)
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		s.notifyAll(ctx, notify.Notification{
+			Level: "info", Title: "Daily digest", Text: text, Source: "digest", Key: fmt.Sprintf("digest:%d", p.ID),
+		})
+	}
+	return nil
+}
+
+// buildDigest : now の前日（DIGEST_TIMEZONE の0時から24時）の本文（アクセスがなければ空）
+func (s *Server) buildDigest(ctx context.Context, projectID int, projectName string, now time.Time) (string, error) {
+	cfg := s.tunables().Digest
+	local := now.In(cfg.Location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, cfg.Location)
+	yesterday, before := today.AddDate(0, 0, -1), today.AddDate(0, 0, -2)
+
+	// 前々日との比較は /api/stats/compare と同じ数え方
+	c, err := s.comparePeriods(ctx, store.LogFilter{ProjectID: projectID, EventType: cfg.EventType}, before, yesterday, today)
+	if err != nil || c.Hits.Current == 0 {
+		return "", err
+	}
+	f := store.LogFilter{ProjectID: projectID, EventType: cfg.EventType, Since: yesterday.UTC(), Until: today.UTC(), Limit: digestTop}
+	pages, err := s.store.GroupLogs(ctx, f, "PATH")
+	if err != nil {
+		return "", err
+	}
+	agents, err := s.store.GroupLogs(ctx, f, "USER_AGENT")
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📰 Daily digest for %s (%s)\n\n", projectName, yesterday.Format("2006-01-02 Mon"))
+	fmt.Fprintf(&b, "Hits: %d %s\n", c.Hits.Current, formatChange(c.Hits))
+	fmt.Fprintf(&b, "Visitors: %d %s\n", c.Uniques.Current, formatChange(c.Uniques))
+	fmt.Fprintf(&b, "Errors: %d %s\n", c.Errors.Current, formatChange(c.Errors))
+	b.WriteString(formatDigestTop("Top pages", pages))
+	b.WriteString(formatDigestTop("Top user agents", agents))
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// formatChange : 前日との差 ("(+12% vs 1000)"。前日が0なら、今日もなければ空・あれば "(new)")
+func formatChange(m compareMetric) string {
+	if m.Percent == nil {
+		if m.Current == 0 {
+			return ""
+		}
+		return "(new)"
+	}
+	return fmt.Sprintf("(%+.0f%% vs %d)", *m.Percent, m.Previous)
+}
+
+// formatDigestTop : 上位の一覧の節
+func formatDigestTop(title string, buckets []model.Bucket) string {
+	if len(buckets) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n%s:\n", title)
+	for i, bucket := range buckets {
+		fmt.Fprintf(&b, "  %d. %s (%d)\n", i+1, trendLabel(bucket.Key), bucket.Count)
+	}
+	return b.String()
+}
+
+// digestHandler : POST /api/admin/digest（設定の確認用に、今すぐ前日のまとめを送る）
+func (s *Server) digestHandler(w http.ResponseWriter, r *http.Request) {
+	if s.tunables().Digest.Location == nil {
+		http.Error(w, "Daily digest is disabled (set DIGEST_TIME or digest_time in /api/settings)", http.StatusNotFound)
+		return
+	}
+	if err := s.sendDigests(r.Context(), s.clock.Now()); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
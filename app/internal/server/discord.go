@@ -0,0 +1,253 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// Discord のインタラクション (アラートのボタンとスラッシュコマンド)
+// ==========================================
+
+// alertMuteDuration : 「Mute 1h」で通知を止める時間
+const alertMuteDuration = time.Hour
+
+// Discord のインタラクションの種類と応答の種類
+const (
+	discordPing               = 1
+	discordApplicationCommand = 2
+	discordMessageComponent   = 3
+
+	discordPong                     = 1
+	discordChannelMessageWithSource = 4
+	discordUpdateMessage            = 7
+
+	discordEphemeral = 1 << 6 // 押した人にだけ見える応答
+
+	discordMessageLimit = 2000 // 応答の content の上限
+)
+
+// discordPublicKeyFromEnv : アプリケーションの公開鍵（Developer Portal の PUBLIC KEY。未設定・不正なら nil）
+func discordPublicKeyFromEnv() ed25519.PublicKey {
+	raw := config.String("DISCORD_PUBLIC_KEY", "")
+	if raw == "" {
+		return nil
+	}
+	key, err := hex.DecodeString(raw)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		fmt.Println("Ignoring invalid DISCORD_PUBLIC_KEY: expected 64 hex characters")
+		return nil
+	}
+	return ed25519.PublicKey(key)
+}
+
+type discordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// discordInteraction : 届くインタラクションのうち使う部分
+type discordInteraction struct {
+	Type int `json:"type"`
+	Data struct {
+		CustomID string                 `json:"custom_id"` // ボタン
+		Name     string                 `json:"name"`      // スラッシュコマンド
+		Options  []discordCommandOption `json:"options"`
+	} `json:"data"`
+	Member *struct {
+		User discordUser `json:"user"`
+	} `json:"member"` // サーバー内で押された時
+	User *discordUser `json:"user"` // DMで押された時
+}
+
+// discordCommandOption : コマンドの引数（サブコマンドは Options を入れ子に持つ）
+type discordCommandOption struct {
+	Name    string                 `json:"name"`
+	Value   any                    `json:"value"`
+	Options []discordCommandOption `json:"options"`
+}
+
+// commandArgs : "/logger stats period:today" を ["stats", "today"] にする
+// 文字列の引数1つ（例: "/logger query:stats today"）で登録したコマンドにも対応する
+func (i discordInteraction) commandArgs() []string {
+	var args []string
+	var walk func(opts []discordCommandOption)
+	walk = func(opts []discordCommandOption) {
+		for _, o := range opts {
+			if o.Value == nil {
+				args = append(args, o.Name) // サブコマンド
+				walk(o.Options)
+				continue
+			}
+			args = append(args, strings.Fields(fmt.Sprint(o.Value))...)
+		}
+	}
+	walk(i.Data.Options)
+	return args
+}
+
+// username : 押した人の名前
+func (i discordInteraction) username() string {
+	if i.Member != nil {
+		return i.Member.User.Username
+	}
+	if i.User != nil {
+		return i.User.Username
+	}
+	return "unknown"
+}
+
+type discordResponse struct {
+	Type int                  `json:"type"`
+	Data *discordResponseData `json:"data,omitempty"`
+}
+
+type discordResponseData struct {
+	Content    string                       `json:"content,omitempty"`
+	Flags      int                          `json:"flags,omitempty"`
+	Components []notify.DiscordComponentRow `json:"components,omitempty"`
+}
+
+// discordInteractionsHandler : POST /api/discord/interactions （ボタンと /logger コマンド）
+// Developer Portal の INTERACTIONS ENDPOINT URL に設定する（署名は DISCORD_PUBLIC_KEY で検証）
+func (s *Server) discordInteractionsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.DiscordPublicKey == nil {
+		http.Error(w, "Not Found: Discord interactions are disabled (set DISCORD_PUBLIC_KEY)", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 64<<10))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	sig, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	timestamp := r.Header.Get("X-Signature-Timestamp")
+	if err != nil || timestamp == "" || !ed25519.Verify(s.cfg.DiscordPublicKey, append([]byte(timestamp), body...), sig) {
+		http.Error(w, "Unauthorized: invalid request signature", http.StatusUnauthorized)
+		return
+	}
+
+	var in discordInteraction
+	if err := json.Unmarshal(body, &in); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var resp discordResponse
+	switch in.Type {
+	case discordPing:
+		resp = discordResponse{Type: discordPong}
+	case discordApplicationCommand:
+		resp = discordReply(s.chatCommand(r.Context(), in.commandArgs()), true)
+	case discordMessageComponent:
+		resp = s.alertButton(r, in)
+	default:
+		resp = discordReply("Unsupported interaction", true)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// discordReply : チャンネルへの返信（ephemeral なら押した人にだけ見える。2000文字を超える分は切り詰める）
+func discordReply(content string, ephemeral bool) discordResponse {
+	if runes := []rune(content); len(runes) > discordMessageLimit {
+		content = string(runes[:discordMessageLimit-1]) + "…"
+	}
+	data := &discordResponseData{Content: content}
+	if ephemeral {
+		data.Flags = discordEphemeral
+	}
+	return discordResponse{Type: discordChannelMessageWithSource, Data: data}
+}
+
+// alertButton : "alert:<操作>:<アラートID>" のボタンを処理する
+func (s *Server) alertButton(r *http.Request, in discordInteraction) discordResponse {
+	parts := strings.Split(in.Data.CustomID, ":")
+	if len(parts) != 3 || parts[0] != "alert" {
+		return discordReply("Unknown button", true)
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return discordReply("Unknown alert", true)
+	}
+	user := in.username()
+
+	switch parts[1] {
+	case "ack":
+		a, err := s.store.AcknowledgeAlert(r.Context(), id, user, s.clock.Now())
+		if err != nil {
+			return discordAlertError(err)
+		}
+		// 元のメッセージを書き換えて、確認済みにする（ボタンも押せなくする）
+		return discordResponse{Type: discordUpdateMessage, Data: &discordResponseData{
+			Content:    fmt.Sprintf("✅ Acknowledged by %s at %s", a.AcknowledgedBy, a.AcknowledgedAt.UTC().Format("2006-01-02 15:04 UTC")),
+			Components: notify.DiscordAlertButtons(a.ID, true),
+		}}
+
+	case "mute":
+		a, err := s.store.AlertByID(r.Context(), id)
+		if err != nil {
+			return discordAlertError(err)
+		}
+		until := s.clock.Now().Add(alertMuteDuration)
+		if err := s.muteAlertKey(r.Context(), a.Key, until, user); err != nil {
+			return discordAlertError(err)
+		}
+		return discordReply(fmt.Sprintf("🔕 %s muted `%s` until %s", user, a.Key, until.UTC().Format("15:04 UTC")), false)
+
+	case "details":
+		a, err := s.store.AlertByID(r.Context(), id)
+		if err != nil {
+			return discordAlertError(err)
+		}
+		return discordReply(s.alertDetails(a), true)
+
+	default:
+		return discordReply("Unknown button", true)
+	}
+}
+
+// alertDetails : 「Show details」で返す内容
+func (s *Server) alertDetails(a model.Alert) string {
+	lines := []string{
+		fmt.Sprintf("**Alert #%d** (%s, %s)", a.ID, a.Source, strings.ToUpper(a.Level)),
+		"Key: `" + a.Key + "`",
+		"Fired: " + a.FiredAt.UTC().Format(time.RFC3339),
+	}
+	if a.AcknowledgedAt != nil {
+		lines = append(lines, fmt.Sprintf("Acknowledged by %s at %s", a.AcknowledgedBy, a.AcknowledgedAt.UTC().Format(time.RFC3339)))
+	}
+	if s.mutes.muted(a.Key, s.clock.Now()) {
+		lines = append(lines, "Currently muted")
+	}
+	if a.EntryID != 0 {
+		if u := s.entryURL(&model.LogEntry{ID: a.EntryID}); u != "" {
+			lines = append(lines, "Entry: "+u)
+		}
+	}
+	lines = append(lines, "", a.Text)
+	return strings.Join(lines, "\n")
+}
+
+// discordAlertError : アラートの操作に失敗した時の返信
+func discordAlertError(err error) discordResponse {
+	if errors.Is(err, store.ErrNotFound) {
+		return discordReply("Alert not found", true)
+	}
+	fmt.Println("Discord interaction failed:", err)
+	return discordReply("Database error: "+err.Error(), true)
+}
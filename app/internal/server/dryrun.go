@@ -0,0 +1,23 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// ドライラン (DRY_RUN=true)
+// ==========================================
+// 本番のトラフィックで設定の変更を確かめるためのモード
+// エンリッチ・除外パターン・アラートルール・通知の組み立てまでは普段どおり行い、
+// 保存するはずだったログと送るはずだった通知を標準出力に出す（DBへの保存・削除と通知の送信はしない）
+
+// dryRunWrite : 保存の代わりに書き込みを出力し、match ルールに当てる
+func (s *Server) dryRunWrite(lw *model.Write) string {
+	body, _ := json.Marshal(lw.Entry())
+	fmt.Printf("[dry-run] would store: %s\n", body)
+	s.matchRules(lw.Entry())
+	return "Dry run: not stored"
+}
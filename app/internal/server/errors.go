@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/i18n"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// エラーの応答 ({"error", "code", "status"})
+// ==========================================
+// どのエンドポイントのエラーも同じ形の JSON で返す（code は機械向けの種類で、error は Accept-Language に合わせた説明）
+// ハンドラが http.Error で返した text/plain のエラーは jsonErrors がこの形に包み直す
+// DBに繋がらない間は 503 と Retry-After を返し、再接続中にバッファ (WAL_DIR) へ退避した書き込みは 202 で受け付ける
+
+// storageRetryAfter : DBに繋がらない時に Retry-After で待たせる時間（DB_HEALTH_INTERVAL の既定と同じ）
+const storageRetryAfter = 5 * time.Second
+
+// エラーの code（状態コードから決まらないもの）
+const (
+	codeStorageUnavailable = "storage_unavailable" // DBに繋がらない（Retry-After の後に送り直せば受け付ける）
+	codeDatabaseError      = "database_error"      // DBはエラーを返した（送り直しても同じかもしれない）
+	codeTimeout            = "timeout"             // HANDLER_TIMEOUT 以内に処理が終わらなかった
+)
+
+// apiError : エラーの本文
+type apiError struct {
+	Error      string `json:"error"`
+	Code       string `json:"code"`
+	Status     int    `json:"status"`
+	RetryAfter int    `json:"retry_after,omitempty"` // 送り直すまで待つ秒数（Retry-After と同じ）
+	EntryID    int    `json:"entry_id,omitempty"`    // panic を記録したログのID（問い合わせの手がかり）
+}
+
+// errorCodes : 状態コードごとの code
+var errorCodes = map[int]string{
+	http.StatusBadRequest:                  "bad_request",
+	http.StatusUnauthorized:                "unauthorized",
+	http.StatusForbidden:                   "forbidden",
+	http.StatusNotFound:                    "not_found",
+	http.StatusMethodNotAllowed:            "method_not_allowed",
+	http.StatusConflict:                    "conflict",
+	http.StatusGone:                        "gone",
+	http.StatusPreconditionFailed:          "precondition_failed",
+	http.StatusRequestEntityTooLarge:       "payload_too_large",
+	http.StatusUnsupportedMediaType:        "unsupported_media_type",
+	http.StatusUnprocessableEntity:         "unprocessable",
+	http.StatusTooManyRequests:             "rate_limited",
+	http.StatusRequestHeaderFieldsTooLarge: "headers_too_large",
+	http.StatusInternalServerError:         "internal",
+	http.StatusNotImplemented:              "not_implemented",
+	http.StatusBadGateway:                  "bad_gateway",
+	http.StatusServiceUnavailable:          "unavailable",
+	http.StatusGatewayTimeout:              "gateway_timeout",
+}
+
+// errorCode : 状態コードの code（一覧になければ client_error / server_error）
+func errorCode(status int) string {
+	if code, ok := errorCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return "server_error"
+	}
+	return "client_error"
+}
+
+// writeJSONError : エラーをJSONで返す（code は状態コードから決める）
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeAPIError(w, apiError{Error: msg, Code: errorCode(status), Status: status})
+}
+
+// writeAPIError : エラーをJSONで返す（Retry-After を付けていれば retry_after にも入れる）
+func writeAPIError(w http.ResponseWriter, e apiError) {
+	if e.RetryAfter == 0 {
+		e.RetryAfter, _ = strconv.Atoi(w.Header().Get("Retry-After"))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(e)
+}
+
+// storageUnavailable : DBに繋がらないことによるエラーか（再接続中・切断・接続の失敗）
+func (s *Server) storageUnavailable(err error) bool {
+	if errors.Is(err, store.ErrUnavailable) || errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	if h, ok := s.store.(interface{ Healthy() bool }); ok && !h.Healthy() {
+		return true
+	}
+	var netErr *net.OpError
+	return errors.As(err, &netErr)
+}
+
+// dbError : DBのエラーを返す。繋がらないなら 503 と Retry-After、それ以外は 500
+func (s *Server) dbError(w http.ResponseWriter, r *http.Request, err error) {
+	if !s.storageUnavailable(err) {
+		writeAPIError(w, apiError{Error: "Database error: " + err.Error(), Code: codeDatabaseError, Status: http.StatusInternalServerError})
+		return
+	}
+	seconds := int(storageRetryAfter.Seconds())
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeAPIError(w, apiError{
+		Error:      i18n.T(w, r, i18n.StorageUnavailable, err, seconds),
+		Code:       codeStorageUnavailable,
+		Status:     http.StatusServiceUnavailable,
+		RetryAfter: seconds,
+	})
+}
+
+// jsonErrors : ハンドラが http.Error で返した text/plain のエラーを apiError の JSON にする
+// /api/ 以外（画面のファイル・/metrics など）と、Accept で text/plain だけを求めたクライアントにはそのまま返す
+func jsonErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		if !strings.HasPrefix(r.URL.Path, "/api/") || (strings.Contains(accept, "text/plain") && !strings.Contains(accept, "json")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ew := &errorWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		if ew.status != 0 {
+			writeAPIError(w, apiError{Error: strings.TrimSpace(ew.body.String()), Code: errorCode(ew.status), Status: ew.status})
+		}
+	})
+}
+
+// errorWriter : 4xx・5xx の text/plain の本文だけを溜め、それ以外はそのまま書く
+type errorWriter struct {
+	http.ResponseWriter
+	status  int // 溜めているエラーの状態コード（0 なら溜めていない）
+	written bool
+	body    bytes.Buffer
+}
+
+func (w *errorWriter) WriteHeader(status int) {
+	if w.written || w.status != 0 {
+		return
+	}
+	if status >= 400 && strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		w.status = status
+		return
+	}
+	w.written = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *errorWriter) Write(p []byte) (int, error) {
+	if !w.written && w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.status != 0 {
+		return w.body.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *errorWriter) Flush() {
+	if w.status != 0 {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *errorWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
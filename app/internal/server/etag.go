@@ -0,0 +1,47 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/redact"
+)
+
+// ==========================================
+// 条件付きリクエスト (ETag / If-None-Match)
+// ==========================================
+
+// logsETag : ログ一覧の ETag
+// 件数・最大id に加え、あとから変わる項目（まとめた回数・タグ・メモ）と、返し方（見える範囲・タイムゾーン・形式）から作る
+// JSON にするより軽いので、ダッシュボードが数秒ごとに読んでも変わっていなければ 304 だけで済む
+func logsETag(logs []model.LogEntry, scope redact.Scope, loc *time.Location, format string) string {
+	maxID := 0
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d|%s|%s|", scope, loc, format)
+	for _, l := range logs {
+		maxID = max(maxID, l.ID)
+		fmt.Fprintf(h, "%s%d:%d:%s:%s|", l.Instance, l.ID, l.HitCount, strings.Join(l.Tags, ","), l.Note)
+		if l.LastHitAt != nil {
+			fmt.Fprintf(h, "%d|", l.LastHitAt.UnixNano())
+		}
+	}
+	return fmt.Sprintf(`W/"%d-%d-%x"`, maxID, len(logs), h.Sum64())
+}
+
+// notModified : ETag を付け、If-None-Match が一致すれば 304 を返して true
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
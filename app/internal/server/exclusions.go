@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 記録・通知からの除外 (クローラーや死活監視のアクセス)
+// ==========================================
+
+// 除外の範囲
+const (
+	excludeAll    = "all"    // 保存も通知もしない
+	excludeNotify = "notify" // 保存だけして通知しない
+	excludeOff    = "off"
+)
+
+// exclusionFields : 除外パターンを当てられる項目
+var exclusionFields = map[string]func(w *model.Write) string{
+	"user_agent": func(w *model.Write) string { return w.UserAgent },
+	"ip":         func(w *model.Write) string { return w.IP },
+	"path":       func(w *model.Write) string { return w.Path },
+}
+
+// excludeBotsFromEnv : EXCLUDE_BOTS（off / notify / all、既定 off）
+func excludeBotsFromEnv() string {
+	switch v := config.String("EXCLUDE_BOTS", excludeOff); v {
+	case excludeOff, excludeNotify, excludeAll:
+		return v
+	default:
+		fmt.Printf("Ignoring EXCLUDE_BOTS=%q (use off, notify or all)\n", v)
+		return excludeOff
+	}
+}
+
+// compiledExclusion : 正規表現をコンパイル済みの除外パターン
+type compiledExclusion struct {
+	model.Exclusion
+	re *regexp.Regexp
+}
+
+// exclusionState : 読み込んだ除外パターン
+type exclusionState struct {
+	mu   sync.RWMutex
+	list []compiledExclusion
+}
+
+// compileExclusion : 除外パターンを検証してコンパイルする
+func compileExclusion(e model.Exclusion) (compiledExclusion, error) {
+	c := compiledExclusion{Exclusion: e}
+	if _, ok := exclusionFields[e.Field]; !ok {
+		return c, fmt.Errorf("unknown field %q (use user_agent, ip or path)", e.Field)
+	}
+	if e.Scope != excludeAll && e.Scope != excludeNotify {
+		return c, fmt.Errorf("unknown scope %q (use all or notify)", e.Scope)
+	}
+	re, err := regexp.Compile(e.Pattern)
+	if err != nil || e.Pattern == "" {
+		return c, fmt.Errorf("invalid pattern %q: %v", e.Pattern, err)
+	}
+	c.re = re
+	return c, nil
+}
+
+// exclusionScope : この書き込みの除外範囲（all / notify、除外しなければ空）
+// EXCLUDE_BOTS で、UAから判定したボットもまとめて除外できる
+func (s *Server) exclusionScope(lw *model.Write) string {
+	scope := ""
+	if bots := s.tunables().ExcludeBots; lw.IsBot && bots != excludeOff {
+		scope = bots
+	}
+	s.exclusions.mu.RLock()
+	defer s.exclusions.mu.RUnlock()
+	for _, e := range s.exclusions.list {
+		if scope == excludeAll {
+			break
+		}
+		if e.re.MatchString(exclusionFields[e.Field](lw)) {
+			scope = e.Scope
+		}
+	}
+	return scope
+}
+
+// shouldNotify : 保存した書き込みを通知するか（レベルのルールと除外パターンに従う）
+func (s *Server) shouldNotify(lw *model.Write) bool {
+	return s.tunables().NotifyRules.ShouldNotify(lw.EventType, lw.Level) && s.exclusionScope(lw) == ""
+}
+
+// reloadExclusions : 除外パターンをDBから読み直す（不正なパターンは飛ばす）
+func (s *Server) reloadExclusions(ctx context.Context) error {
+	exclusions, err := s.store.ListExclusions(ctx)
+	if err != nil {
+		return err
+	}
+	var list []compiledExclusion
+	for _, e := range exclusions {
+		c, err := compileExclusion(e)
+		if err != nil {
+			fmt.Printf("Skipping exclusion %d: %v\n", e.ID, err)
+			continue
+		}
+		list = append(list, c)
+	}
+	s.exclusions.mu.Lock()
+	s.exclusions.list = list
+	s.exclusions.mu.Unlock()
+	return nil
+}
+
+// listExclusionsHandler : GET /api/exclusions
+func (s *Server) listExclusionsHandler(w http.ResponseWriter, r *http.Request) {
+	exclusions, err := s.store.ListExclusions(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exclusions)
+}
+
+// createExclusionHandler : POST /api/exclusions {"field": "user_agent", "pattern": "(?i)googlebot", "scope": "all"}
+func (s *Server) createExclusionHandler(w http.ResponseWriter, r *http.Request) {
+	var e model.Exclusion
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&e); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if e.Scope == "" {
+		e.Scope = excludeAll
+	}
+	if _, err := compileExclusion(e); err != nil {
+		http.Error(w, "Invalid exclusion: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.CreateExclusion(r.Context(), &e); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadExclusionsAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(e)
+}
+
+// deleteExclusionHandler : DELETE /api/exclusions/{id}
+func (s *Server) deleteExclusionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid exclusion id", http.StatusBadRequest)
+		return
+	}
+	err = s.store.DeleteExclusion(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Exclusion not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadExclusionsAfterChange(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reloadExclusionsAfterChange : 変更をすぐ反映する（失敗しても次の定期読み込みで反映される）
+func (s *Server) reloadExclusionsAfterChange(ctx context.Context) {
+	if err := s.reloadExclusions(ctx); err != nil {
+		fmt.Println("Failed to reload exclusions:", err)
+	}
+}
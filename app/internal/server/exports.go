@@ -0,0 +1,366 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/archive"
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 非同期の書き出し (POST /api/exports)
+// ==========================================
+// 大量のログを HTTP の応答で返すとタイムアウトするので、書き出しを受け付けたらすぐ 202 を返し、
+// ワーカーが `logger export` と同じ形式 (Parquet / NDJSON) のファイルに書く。状態は GET /api/exports/{id} で確かめる
+// ファイルは EXPORT_DIR に置き、EXPORT_S3_BUCKET を設定すればバケットへ上げる（どのインスタンスからでも取り出せる）
+// EXPORT_TTL を過ぎたファイルと記録は消す
+
+// exportPollInterval : 待ちの書き出しを探す間隔（受け付けたインスタンスはすぐに始める）
+const exportPollInterval = 10 * time.Second
+
+// exportLinkTTL : バケットのファイルを取り出す URL の有効期間
+const exportLinkTTL = 15 * time.Minute
+
+// exportFixedParams : 書き出しでは指定できない絞り込み（件数・並び順はワーカーが決める）
+var exportFixedParams = []string{"limit", "cursor", "sort", "order"}
+
+// exportRequest : POST /api/exports の本文
+type exportRequest struct {
+	ProjectID int               `json:"project_id"`
+	Format    string            `json:"format"`  // parquet（既定）/ ndjson
+	Filters   map[string]string `json:"filters"` // GET /api/logs と同じ (type, level, query, from, to, period など)
+}
+
+// exportStatus : GET /api/exports/{id} の応答
+type exportStatus struct {
+	*model.ExportJob
+	DownloadURL string `json:"download_url,omitempty"` // 書き終えていれば取り出す URL
+}
+
+// createExportHandler : POST /api/exports {"format": "parquet", "filters": {"type": "error", "period": "30d"}}
+// 期間は受け付けた時刻で from / to に直して保存する（後から書き出しても同じ範囲になる）
+func (s *Server) createExportHandler(w http.ResponseWriter, r *http.Request) {
+	var req exportRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	job := model.ExportJob{ProjectID: req.ProjectID, Format: req.Format}
+	if job.ProjectID == 0 {
+		job.ProjectID = model.DefaultProjectID
+	}
+	if job.Format == "" {
+		job.Format = archive.FormatParquet
+	}
+	if job.Format != archive.FormatParquet && job.Format != archive.FormatNDJSON {
+		http.Error(w, fmt.Sprintf(`Invalid "format" (use %s or %s)`, archive.FormatParquet, archive.FormatNDJSON), http.StatusBadRequest)
+		return
+	}
+	filters, err := s.exportFilters(req.Filters, job.ProjectID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	job.Filters = filters
+
+	if err := s.store.CreateExportJob(r.Context(), &job); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	// このインスタンスのワーカーをすぐに起こす（忙しければ次の確認の時に取る）
+	select {
+	case s.exportWake <- struct{}{}:
+	default:
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/exports/"+strconv.Itoa(job.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(s.exportStatus(&job))
+}
+
+// exportFilters : 絞り込みを確かめ、期間 (since / from / period / until / to) を from / to の時刻に直す（to の既定は今）
+func (s *Server) exportFilters(filters map[string]string, projectID int) (map[string]string, error) {
+	query := url.Values{}
+	for k, v := range filters {
+		for _, fixed := range exportFixedParams {
+			if k == fixed {
+				return nil, fmt.Errorf(`filters.%s cannot be used for exports`, k)
+			}
+		}
+		query.Set(k, v)
+	}
+	since, until, err := countRangeFromQuery(query, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range []string{"since", "from", "period", "until", "to"} {
+		query.Del(k)
+	}
+	if !since.IsZero() {
+		query.Set("from", since.UTC().Format(time.RFC3339Nano))
+	}
+	// 終わりがなければ受け付けた時刻までにする（書き出すまでに届いたログは含めない）
+	if until.IsZero() {
+		until = s.clock.Now()
+	}
+	query.Set("to", until.UTC().Format(time.RFC3339Nano))
+	if _, err := logFilterFromQuery(query, projectID); err != nil {
+		return nil, err
+	}
+	resolved := map[string]string{}
+	for k := range query {
+		resolved[k] = query.Get(k)
+	}
+	return resolved, nil
+}
+
+// exportJob : パスの {id} の書き出し（なければ 404 を返して false）
+func (s *Server) exportJob(w http.ResponseWriter, r *http.Request) (*model.ExportJob, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid export id", http.StatusBadRequest)
+		return nil, false
+	}
+	job, err := s.store.ExportJobByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return nil, false
+	}
+	return job, true
+}
+
+// exportStatus : 書き終えていれば取り出す URL を付ける
+func (s *Server) exportStatus(job *model.ExportJob) exportStatus {
+	st := exportStatus{ExportJob: job}
+	if job.Status == model.ExportDone {
+		st.DownloadURL = strings.TrimSuffix(s.cfg.PublicBaseURL, "/") + "/api/exports/" + strconv.Itoa(job.ID) + "/download"
+	}
+	return st
+}
+
+// exportHandler : GET /api/exports/{id}
+func (s *Server) exportHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.exportJob(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.exportStatus(job))
+}
+
+// downloadExportHandler : GET /api/exports/{id}/download
+// バケットのファイルは期限付きの URL へリダイレクトし、ディスクのファイルは書き出したインスタンスがそのまま返す
+func (s *Server) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.exportJob(w, r)
+	if !ok {
+		return
+	}
+	if job.Status != model.ExportDone {
+		http.Error(w, fmt.Sprintf("Export is not ready (status %s)", job.Status), http.StatusConflict)
+		return
+	}
+	filename := filepath.Base(job.Object)
+	if job.Location == "s3" {
+		if s.exportBucket == nil {
+			http.Error(w, "Export is stored in a bucket but EXPORT_S3_BUCKET is not configured on this instance", http.StatusServiceUnavailable)
+			return
+		}
+		link, err := s.exportBucket.PresignGet(r.Context(), job.Object, filename, exportLinkTTL)
+		if err != nil {
+			http.Error(w, "Storage error: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		http.Redirect(w, r, link, http.StatusFound)
+		return
+	}
+	if job.Instance != s.cfg.InstanceName {
+		http.Error(w, fmt.Sprintf("Export file is on instance %q (set EXPORT_S3_BUCKET to share exports between instances)", job.Instance), http.StatusNotFound)
+		return
+	}
+	file, err := os.Open(job.Object)
+	if err != nil {
+		http.Error(w, "Export file is missing: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", exportContentType(job.Format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	http.ServeContent(w, r, filename, job.FinishedAt.UTC(), file)
+}
+
+// exportContentType : 形式ごとの Content-Type
+func exportContentType(format string) string {
+	if format == archive.FormatNDJSON {
+		return "application/gzip"
+	}
+	return "application/vnd.apache.parquet"
+}
+
+// exportExtension : 形式ごとのファイルの拡張子
+func exportExtension(format string) string {
+	if format == archive.FormatNDJSON {
+		return ".ndjson.gz"
+	}
+	return ".parquet"
+}
+
+// watchExports : 待ちの書き出しを1つずつ取って書き、期限を過ぎたファイルを消す（全てのインスタンスで動かす）
+func (s *Server) watchExports(ctx context.Context) {
+	// 前回の停止で書きかけのまま止まった分は、最初から書き直す
+	if n, err := s.store.RequeueExportJobs(ctx, s.cfg.InstanceName); err != nil {
+		fmt.Println("Failed to requeue interrupted exports:", err)
+	} else if n > 0 {
+		fmt.Printf("Requeued %d interrupted exports\n", n)
+	}
+	ticker := s.clock.NewTicker(exportPollInterval)
+	defer ticker.Stop()
+	j := s.jobs.register("exports", exportPollInterval)
+
+	for {
+		s.runJob(j, func() error { return s.runExports(ctx) })
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		case <-s.exportWake:
+		}
+	}
+}
+
+// runExports : 期限を過ぎた書き出しを消し、待ちがなくなるまで書き出す
+func (s *Server) runExports(ctx context.Context) error {
+	expired, err := s.store.DeleteExpiredExportJobs(ctx, s.cfg.InstanceName, s.clock.Now())
+	if err != nil {
+		return err
+	}
+	for _, job := range expired {
+		s.removeExportFile(ctx, &job)
+	}
+	for ctx.Err() == nil {
+		job, err := s.store.ClaimExportJob(ctx, s.cfg.InstanceName, s.clock.Now())
+		if err != nil || job == nil {
+			return err
+		}
+		rows, bytes, location, object, err := s.writeExport(ctx, job)
+		now := s.clock.Now()
+		expires := now.Add(s.cfg.ExportTTL)
+		job.Rows, job.Bytes, job.FinishedAt, job.ExpiresAt = rows, bytes, &now, &expires
+		job.Status, job.Location, job.Object = model.ExportDone, location, object
+		if err != nil {
+			fmt.Printf("Export %d failed: %v\n", job.ID, err)
+			job.Status, job.Error = model.ExportFailed, err.Error()
+		}
+		if err := s.store.FinishExportJob(ctx, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeExport : job の絞り込みのログを id の古い順に EXPORT_BATCH 件ずつ読み、ファイルに書く（バケットがあれば上げる）
+// REDACT_RULES の書き出しの範囲 (exportScope) で隠してから書く
+func (s *Server) writeExport(ctx context.Context, job *model.ExportJob) (rows, bytes int64, location, object string, err error) {
+	query := url.Values{}
+	for k, v := range job.Filters {
+		query.Set(k, v)
+	}
+	f, err := logFilterFromQuery(query, job.ProjectID)
+	if err != nil {
+		return 0, 0, "", "", err
+	}
+	if f.Since, f.Until, err = countRangeFromQuery(query, s.clock.Now()); err != nil {
+		return 0, 0, "", "", err
+	}
+	f.Sort, f.Ascending, f.Limit = store.SortID, true, max(s.cfg.ExportBatch, 1)
+
+	if err := os.MkdirAll(s.cfg.ExportDir, 0o755); err != nil {
+		return 0, 0, "", "", err
+	}
+	name := fmt.Sprintf("go-logger-export-%d-%s%s", job.ID, job.CreatedAt.UTC().Format("20060102T150405Z"), exportExtension(job.Format))
+	path := filepath.Join(s.cfg.ExportDir, name)
+	file, err := os.Create(path + ".part")
+	if err != nil {
+		return 0, 0, "", "", err
+	}
+	defer os.Remove(path + ".part")
+	defer file.Close()
+
+	enc, err := archive.NewEncoder(file, job.Format)
+	if err != nil {
+		return 0, 0, "", "", err
+	}
+	for {
+		logs, err := s.store.QueryLogs(ctx, f)
+		if err != nil {
+			enc.Close()
+			return rows, 0, "", "", fmt.Errorf("after %d events: %w", rows, err)
+		}
+		if len(logs) == 0 {
+			break
+		}
+		if err := enc.Write(s.redact.Entries(logs, s.exportScope())); err != nil {
+			enc.Close()
+			return rows, 0, "", "", err
+		}
+		rows += int64(len(logs))
+		f.AfterID = logs[len(logs)-1].ID
+		if len(logs) < f.Limit {
+			break
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return rows, 0, "", "", err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return rows, 0, "", "", err
+	}
+	bytes = info.Size()
+
+	if s.exportBucket != nil {
+		if _, err := file.Seek(0, 0); err != nil {
+			return rows, bytes, "", "", err
+		}
+		if err := s.exportBucket.Put(ctx, "exports/"+name, file, bytes, exportContentType(job.Format)); err != nil {
+			return rows, bytes, "", "", fmt.Errorf("upload: %w", err)
+		}
+		return rows, bytes, "s3", "exports/" + name, nil
+	}
+	if err := file.Close(); err != nil {
+		return rows, bytes, "", "", err
+	}
+	if err := os.Rename(path+".part", path); err != nil {
+		return rows, bytes, "", "", err
+	}
+	return rows, bytes, "disk", path, nil
+}
+
+// removeExportFile : 期限を過ぎた書き出しのファイルを消す（消せなくても記録は消したままにする）
+func (s *Server) removeExportFile(ctx context.Context, job *model.ExportJob) {
+	var err error
+	switch {
+	case job.Location == "s3" && s.exportBucket != nil:
+		err = s.exportBucket.Remove(ctx, job.Object)
+	case job.Location == "disk":
+		err = os.Remove(job.Object)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("Failed to remove expired export %d: %v\n", job.ID, err)
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go-logger/internal/faults"
+)
+
+// ==========================================
+// 障害の注入 (chaos ビルドタグ付きのビルドだけ。結合テスト用)
+// ==========================================
+
+// faultsHandler : GET /api/admin/faults で現在の設定、
+// PUT で {"db_latency_ms": 500, "db_error_rate": 0.5, "webhook_status": 500, "queue_full": false} に置き換え、
+// DELETE で解除する
+func (s *Server) faultsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		var settings faults.Settings
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&settings); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := faults.Set(settings); err != nil {
+			http.Error(w, "Invalid faults: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		faults.Set(faults.Settings{})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(faults.Current())
+}
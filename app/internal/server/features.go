@@ -0,0 +1,42 @@
+package server
+
+import (
+	"fmt"
+
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// 機能の切り替え (FEATURE_*=false で止める)
+// ==========================================
+// 起動時に1度だけ読む。例: 通知だけ送る (FEATURE_STORAGE=false)、保存だけする (FEATURE_NOTIFICATIONS=false)
+
+// Features : 個別に止められる機能（既定は全て有効）
+type Features struct {
+	Storage       bool // ログを保存する（止めても新着の配信・ルール・通知は行う。プロジェクトや設定は DB に置いたまま）
+	Notifications bool // 通知先へ送る（止めてもアラートの履歴には残す）
+	Dashboard     bool // 画面のファイルを配信する（読み出しAPIはそのまま）
+	Streams       bool // 新着の購読 (SSE の GraphQL サブスクリプション・/api/ws)
+}
+
+// featuresFromEnv : FEATURE_STORAGE / FEATURE_NOTIFICATIONS / FEATURE_DASHBOARD / FEATURE_STREAMS
+func featuresFromEnv() Features {
+	return Features{
+		Storage:       config.Bool("FEATURE_STORAGE", true),
+		Notifications: config.Bool("FEATURE_NOTIFICATIONS", true),
+		Dashboard:     config.Bool("FEATURE_DASHBOARD", true),
+		Streams:       config.Bool("FEATURE_STREAMS", true),
+	}
+}
+
+// String : 起動時に出す一覧 (storage=on notifications=off ...)
+func (f Features) String() string {
+	onOff := func(b bool) string {
+		if b {
+			return "on"
+		}
+		return "off"
+	}
+	return fmt.Sprintf("storage=%s notifications=%s dashboard=%s streams=%s",
+		onOff(f.Storage), onOff(f.Notifications), onOff(f.Dashboard), onOff(f.Streams))
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 横断集計 (複数サーバーで動かしているインスタンスの結果をまとめる)
+// ==========================================
+
+// Peer : 問い合わせ先のインスタンス
+type Peer struct {
+	Name string
+	URL  string // 例: https://osaka.example.com/go
+}
+
+// PeersFromEnv : 環境変数から問い合わせ先を読み込む
+// PEERS="osaka=https://osaka.example.com/go,https://other.example.com/go"（名前を省略するとホスト名）
+func PeersFromEnv() []Peer {
+	var list []Peer
+	for _, item := range config.List("PEERS") {
+		name, rawURL, ok := strings.Cut(item, "=")
+		if !ok {
+			rawURL = item
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Host == "" {
+			fmt.Printf("Ignoring peer %q: invalid URL\n", item)
+			continue
+		}
+		if !ok {
+			name = u.Host
+		}
+		list = append(list, Peer{Name: name, URL: strings.TrimRight(rawURL, "/")})
+	}
+	return list
+}
+
+// instanceNameFromEnv : 自分自身の名前（INSTANCE_NAME、未設定ならホスト名）
+func instanceNameFromEnv() string {
+	if name := config.String("INSTANCE_NAME", ""); name != "" {
+		return name
+	}
+	host, _ := os.Hostname()
+	return host
+}
+
+// federated : ?federate=true で、問い合わせ先が設定されている時だけ横断する
+func (s *Server) federated(r *http.Request) bool {
+	v := r.URL.Query().Get("federate")
+	return len(s.cfg.Peers) > 0 && (v == "true" || v == "1")
+}
+
+// fanOut : 全ての問い合わせ先に同じクエリで並行してGETし、本文を decode に渡す
+// タイムアウトは問い合わせごとに PEER_TIMEOUT で付ける
+// 問い合わせ先では横断しない（federate を外す）ので、お互いをPEERSに入れてもループしない
+// プロジェクトキーは問い合わせ先ごとに違うので、PEER_API_KEY を代わりに送る
+func (s *Server) fanOut(ctx context.Context, path string, query url.Values, decode func(peer Peer, body io.Reader) error) []model.PeerStatus {
+	q := url.Values{}
+	for k, v := range query {
+		if k != "federate" && k != "key" {
+			q[k] = v
+		}
+	}
+	statuses := make([]model.PeerStatus, len(s.cfg.Peers))
+	var wg sync.WaitGroup
+	for i, peer := range s.cfg.Peers {
+		wg.Add(1)
+		go func(i int, peer Peer) {
+			defer wg.Done()
+			start := time.Now()
+			err := s.fetchPeer(ctx, peer, path+"?"+q.Encode(), decode)
+			statuses[i] = model.PeerStatus{Name: peer.Name, OK: err == nil, LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				statuses[i].Error = err.Error()
+				fmt.Printf("Peer %s failed: %v\n", peer.Name, err)
+			}
+		}(i, peer)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// fetchPeer : 1つの問い合わせ先へのGET
+func (s *Server) fetchPeer(ctx context.Context, peer Peer, pathAndQuery string, decode func(Peer, io.Reader) error) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.PeerTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", peer.URL+pathAndQuery, nil)
+	if err != nil {
+		return err
+	}
+	if s.cfg.PeerAPIKey != "" {
+		req.Header.Set("X-API-Key", s.cfg.PeerAPIKey)
+	}
+	resp, err := s.peerClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("returned %s", resp.Status)
+	}
+	return decode(peer, resp.Body)
+}
+
+// federateLogs : 自分と問い合わせ先のログを新しい順（?order=asc なら古い順）にまとめて f.Limit 件にする
+// id はインスタンスごとに別々に振られるので、?sort= によらず created_at で並べる
+// 各問い合わせ先の結果は X-Peer-Status ヘッダーで返す
+func (s *Server) federateLogs(w http.ResponseWriter, r *http.Request, f store.LogFilter, local []model.LogEntry) []model.LogEntry {
+	self := s.cfg.InstanceName
+	for i := range local {
+		local[i].Instance = self
+	}
+
+	var mu sync.Mutex
+	merged := local
+	statuses := s.fanOut(r.Context(), "/api/logs", r.URL.Query(), func(peer Peer, body io.Reader) error {
+		var logs []model.LogEntry
+		if err := json.NewDecoder(body).Decode(&logs); err != nil {
+			return err
+		}
+		for i := range logs {
+			logs[i].Instance = peer.Name
+		}
+		mu.Lock()
+		merged = append(merged, logs...)
+		mu.Unlock()
+		return nil
+	})
+	for _, st := range statuses {
+		if st.OK {
+			w.Header().Add("X-Peer-Status", st.Name+"; ok")
+		} else {
+			w.Header().Add("X-Peer-Status", st.Name+"; error="+st.Error)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if f.Ascending {
+			return merged[i].CreatedAt.Before(merged[j].CreatedAt)
+		}
+		return merged[i].CreatedAt.After(merged[j].CreatedAt)
+	})
+	if limit := limitOrDefault(f.Limit); len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+// federateStats : 問い合わせ先の件数を足し合わせる
+func (s *Server) federateStats(r *http.Request, stats *model.Stats) {
+	var mu sync.Mutex
+	stats.Peers = s.fanOut(r.Context(), "/api/stats", r.URL.Query(), func(peer Peer, body io.Reader) error {
+		var ps model.Stats
+		if err := json.NewDecoder(body).Decode(&ps); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		stats.Total += ps.Total
+		stats.Last24h += ps.Last24h
+		for k, v := range ps.ByType {
+			stats.ByType[k] += v
+		}
+		for k, v := range ps.ByLevel {
+			stats.ByLevel[k] += v
+		}
+		return nil
+	})
+}
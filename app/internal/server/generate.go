@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/synth"
+)
+
+// ==========================================
+// 合成トラフィック (POST /api/admin/generate。SYNTHETIC_TRAFFIC=true の時だけ)
+// ==========================================
+// `main generate` と同じアクセスと構造化ログを、HTTP を通さずにこのインスタンスの保存の経路へ流す
+// エンリッチ・除外・バッファへの退避は普通の書き込みと同じで、通知だけは送らない
+// 保存した行の fields には "synthetic": true が付く（消す時は DELETE /api/logs?q=... などで絞る）
+
+// syntheticMaxDuration : 1回に流し続けられる時間の上限（止め忘れても翌日には止まる）
+const syntheticMaxDuration = 24 * time.Hour
+
+// syntheticRequest : POST /api/admin/generate の本文
+type syntheticRequest struct {
+	Rate      string   `json:"rate"`     // "50/s" "300/m" など（既定は 10/s）
+	Duration  string   `json:"duration"` // "5m" "1h" など（必須。24h まで）
+	ProjectID int      `json:"project_id"`
+	LogShare  *float64 `json:"log_share"` // 構造化ログの割合（既定は 0.2）
+	Visitors  int      `json:"visitors"`
+	Seed      uint64   `json:"seed"`
+}
+
+// syntheticRun : 流している（最後に流した）合成トラフィックの状態
+type syntheticRun struct {
+	Running    bool         `json:"running"`
+	ProjectID  int          `json:"project_id"`
+	TargetRate float64      `json:"target_rate"` // 1秒あたりの件数
+	Duration   string       `json:"duration"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	Result     synth.Result `json:"result"` // sent・failed・rate（実際に保存できた1秒あたりの件数）
+}
+
+// syntheticState : 合成トラフィックは同時に1つだけ流す
+type syntheticState struct {
+	mu     sync.Mutex
+	run    *syntheticRun
+	cancel context.CancelFunc
+}
+
+// generateHandler : POST で流し始め (202)、GET で状態を返し、DELETE で止める
+// 流している間の POST は 409
+func (s *Server) generateHandler(w http.ResponseWriter, r *http.Request) {
+	st := &s.synthetic
+	switch r.Method {
+	case http.MethodPost:
+		var req syntheticRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		run, opts, err := s.parseSyntheticRequest(r.Context(), req)
+		if err != nil {
+			http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		st.mu.Lock()
+		if st.run != nil && st.run.Running {
+			st.mu.Unlock()
+			http.Error(w, "Conflict: synthetic traffic is already running (DELETE /api/admin/generate to stop it)", http.StatusConflict)
+			return
+		}
+		// リクエストが終わっても流し続ける（トレースの値だけ引き継ぐ）
+		ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+		st.run, st.cancel = run, cancel
+		st.mu.Unlock()
+		d, _ := time.ParseDuration(run.Duration)
+		fmt.Printf("Synthetic traffic started: %.1f events/s for %s (project %d)\n", run.TargetRate, run.Duration, run.ProjectID)
+		go s.runSynthetic(ctx, run, synth.New(opts), d)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodDelete:
+		st.mu.Lock()
+		if st.cancel != nil {
+			st.cancel()
+		}
+		st.mu.Unlock()
+	}
+
+	st.mu.Lock()
+	var resp syntheticRun
+	if st.run != nil {
+		resp = *st.run
+	}
+	st.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseSyntheticRequest : 本文を確かめ、始める時の状態と作るイベントの割合にする
+func (s *Server) parseSyntheticRequest(ctx context.Context, req syntheticRequest) (*syntheticRun, synth.Options, error) {
+	if req.Rate == "" {
+		req.Rate = "10/s"
+	}
+	rate, err := synth.ParseRate(req.Rate)
+	if err != nil {
+		return nil, synth.Options{}, err
+	}
+	if req.Duration == "" {
+		return nil, synth.Options{}, fmt.Errorf(`"duration" is required (e.g. "5m")`)
+	}
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil || d <= 0 || d > syntheticMaxDuration {
+		return nil, synth.Options{}, fmt.Errorf(`"duration" must be between 1s and %s`, syntheticMaxDuration)
+	}
+	if req.ProjectID == 0 {
+		req.ProjectID = model.DefaultProjectID
+	}
+	projects, err := s.store.ListProjects(ctx)
+	if err != nil {
+		return nil, synth.Options{}, err
+	}
+	if !slices.ContainsFunc(projects, func(p model.Project) bool { return p.ID == req.ProjectID }) {
+		return nil, synth.Options{}, fmt.Errorf("project %d not found", req.ProjectID)
+	}
+	opts := synth.Options{LogShare: 0.2, Visitors: req.Visitors, Seed: req.Seed}
+	if req.LogShare != nil {
+		if *req.LogShare < 0 || *req.LogShare > 1 {
+			return nil, synth.Options{}, fmt.Errorf(`"log_share" must be between 0 and 1`)
+		}
+		opts.LogShare = *req.LogShare
+	}
+	run := &syntheticRun{Running: true, ProjectID: req.ProjectID, TargetRate: rate, Duration: d.String(), StartedAt: s.clock.Now()}
+	return run, opts, nil
+}
+
+// runSynthetic : 終わるか止められるまで流し、結果を状態に残す
+func (s *Server) runSynthetic(ctx context.Context, run *syntheticRun, gen *synth.Generator, d time.Duration) {
+	st := &s.synthetic
+	send := func(ctx context.Context, events []synth.Event) int {
+		return s.saveSynthetic(ctx, run.ProjectID, events)
+	}
+	res := synth.Run(ctx, s.clock, gen, run.TargetRate, d, 0, send, func(r synth.Result) {
+		st.mu.Lock()
+		run.Result = r
+		st.mu.Unlock()
+	})
+	finished := s.clock.Now()
+	st.mu.Lock()
+	run.Running, run.Result, run.FinishedAt = false, res, &finished
+	st.cancel()
+	st.mu.Unlock()
+	fmt.Printf("Synthetic traffic finished: sent %d, failed %d (%.1f/s)\n", res.Sent, res.Failed, res.Rate)
+}
+
+// saveSynthetic : 1回分を保存し、保存できなかった件数を返す（除外したものは失敗に数えない）
+func (s *Server) saveSynthetic(ctx context.Context, projectID int, events []synth.Event) int {
+	now := s.clock.Now()
+	var pending []*model.Write
+	failed := 0
+	for _, e := range events {
+		lw := syntheticWrite(projectID, e, now)
+		if status, _, ok := s.prepareWrite(ctx, lw); !ok {
+			if strings.HasPrefix(status, "Error") {
+				failed++
+			}
+			continue
+		}
+		pending = append(pending, lw)
+	}
+	result, n, saveErr := s.store.SaveLogs(ctx, pending)
+	for i, lw := range pending {
+		var err error
+		if i >= n {
+			err = saveErr
+		}
+		if status, _ := s.savedWrite(lw, result, err); !acceptedStatus(status) {
+			failed++
+		}
+	}
+	return failed
+}
+
+// syntheticWrite : 合成した1件を書き込みにする（アクセスは /api/<ページ>、構造化ログは /api/logs に来たものとして）
+func syntheticWrite(projectID int, e synth.Event, now time.Time) *model.Write {
+	fields, _ := json.Marshal(e.Fields)
+	duration := e.DurationMS
+	lw := &model.Write{
+		ProjectID:  projectID,
+		UserAgent:  e.UserAgent,
+		IP:         e.IP,
+		Path:       "/api" + e.Path,
+		Referrer:   e.Referrer,
+		EventType:  model.EventAccess,
+		Level:      model.DefaultLevel,
+		Fields:     fields,
+		CreatedAt:  now,
+		Status:     e.Status,
+		DurationMS: &duration,
+	}
+	if e.Kind == synth.KindLog {
+		lw.Path, lw.EventType, lw.Level, lw.Message = "/api/logs", logEventType, e.Level, e.Message
+	}
+	return lw
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 地図用の国別集計 (GeoJSON / ダッシュボードの世界地図)
+// ==========================================
+
+// geoFeatureCollection : GeoJSON (RFC 7946) の FeatureCollection
+type geoFeatureCollection struct {
+	Type     string       `json:"type"`
+	Features []geoFeature `json:"features"`
+}
+
+type geoFeature struct {
+	Type       string         `json:"type"`
+	Geometry   *geoPoint      `json:"geometry"` // 座標が分からない国は null
+	Properties geoFeatureProp `json:"properties"`
+}
+
+// geoPoint : 座標は [経度, 緯度] の順
+type geoPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoFeatureProp struct {
+	Country string `json:"country"`
+	Name    string `json:"name,omitempty"`
+	Count   int    `json:"count"`
+}
+
+// geoJSONHandler : GET /api/stats/geo.geojson?type=&level=
+// 国ごとのアクセス数を、国の代表点を座標にした Point の Feature として返す
+// （Leaflet の L.geoJSON や Mapbox の GeoJSON ソースにそのまま渡せる）
+func (s *Server) geoJSONHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		f, err := logFilterFromQuery(r.URL.Query(), projectID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Extrapolate = s.extrapolate(r)
+		f.Limit = len(countryCentroids)
+		buckets, err := s.store.GroupLogs(r.Context(), f, "COUNTRY")
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+
+		collection := geoFeatureCollection{Type: "FeatureCollection", Features: []geoFeature{}}
+		for _, b := range buckets {
+			// GeoIP で国が分からなかったアクセスは地図に置けない
+			if b.Key == "" {
+				continue
+			}
+			feature := geoFeature{
+				Type:       "Feature",
+				Properties: geoFeatureProp{Country: b.Key, Count: b.Count},
+			}
+			if c, ok := countryCentroids[strings.ToUpper(b.Key)]; ok {
+				feature.Properties.Name = c.Name
+				feature.Geometry = &geoPoint{Type: "Point", Coordinates: [2]float64{c.Lon, c.Lat}}
+			}
+			collection.Features = append(collection.Features, feature)
+		}
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		json.NewEncoder(w).Encode(collection)
+	})
+}
+
+// geoStats : GET /api/stats/geo の結果
+type geoStats struct {
+	Since     time.Time    `json:"since"`
+	Until     *time.Time   `json:"until,omitempty"`
+	Total     int          `json:"total"`
+	Unknown   int          `json:"unknown"` // GeoIP で国が分からなかったアクセス
+	Max       int          `json:"max"`     // 最も多い国の件数（色の濃さの段階に使う）
+	Countries []geoCountry `json:"countries"`
+}
+
+// geoCountry : 1か国分（件数の多い順）
+type geoCountry struct {
+	Country string   `json:"country"` // ISO 3166-1 alpha-2
+	Name    string   `json:"name,omitempty"`
+	Count   int      `json:"count"`
+	Share   float64  `json:"share"`         // 国が分かったアクセスに占める割合 (0〜1)
+	Lat     *float64 `json:"lat,omitempty"` // ?centroids=true の時だけ
+	Lon     *float64 `json:"lon,omitempty"`
+}
+
+// geoStatsHandler : GET /api/stats/geo?period=7d&type=&level=&centroids=true
+// ?since=&until= (RFC3339) でも期間を指定できる。国コードごとの件数を、世界地図の色分けに使える形で返す
+func (s *Server) geoStatsHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		loc, ok := s.responseLocation(w, r)
+		if !ok {
+			return
+		}
+		format, ok := negotiateFormat(w, r)
+		if !ok {
+			return
+		}
+		f, err := logFilterFromQuery(r.URL.Query(), projectID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Extrapolate = s.extrapolate(r)
+		if f.Since, f.Until, err = timeRangeFromQuery(r.URL.Query(), s.clock.Now(), 7*24*time.Hour); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// 国の数より多くはならないが、GeoIP が返す地域コード (EU, AP など) の分も余裕を持たせる
+		f.Limit = len(countryCentroids) + 10
+		buckets, err := s.store.GroupLogs(r.Context(), f, "COUNTRY")
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+
+		result := geoStats{Since: f.Since, Countries: []geoCountry{}}
+		if !f.Until.IsZero() {
+			result.Until = &f.Until
+		}
+		result.Countries, result.Unknown = geoCountries(buckets, r.URL.Query().Get("centroids") == "true")
+		for _, c := range result.Countries {
+			result.Total += c.Count
+			result.Max = max(result.Max, c.Count)
+		}
+		result.Total += result.Unknown
+		inLocation(&result, loc)
+
+		writeFormatted(w, format, "geo", result)
+	})
+}
+
+// geoCountries : 国の分からない分 (key が "") を外して件数を返し、残りに国名・割合（と代表点）を付ける
+func geoCountries(buckets []model.Bucket, centroids bool) ([]geoCountry, int) {
+	unknown, known := 0, 0
+	for _, b := range buckets {
+		if b.Key == "" {
+			unknown += b.Count
+		} else {
+			known += b.Count
+		}
+	}
+	countries := make([]geoCountry, 0, len(buckets))
+	for _, b := range buckets {
+		if b.Key == "" {
+			continue
+		}
+		code := strings.ToUpper(b.Key)
+		c := geoCountry{Country: code, Count: b.Count, Share: float64(b.Count) / float64(known)}
+		if centroid, ok := countryCentroids[code]; ok {
+			c.Name = centroid.Name
+			if centroids {
+				c.Lat, c.Lon = &centroid.Lat, &centroid.Lon
+			}
+		}
+		countries = append(countries, c)
+	}
+	return countries, unknown
+}
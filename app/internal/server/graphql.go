@@ -0,0 +1,451 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+
+	"go-logger/internal/model"
+	"go-logger/internal/redact"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// GraphQL API (ダッシュボードのウィジェットが必要な項目だけ取れるように)
+// ==========================================
+
+// graphqlRequest : POST /api/graphql の本文
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables"`
+	OperationName string         `json:"operationName"`
+}
+
+// graphqlProjectKey : リゾルバにプロジェクトIDを渡すためのコンテキストキー
+type graphqlProjectKey struct{}
+
+// logEntryField : LogEntry の1項目を返すフィールド
+func logEntryField(t graphql.Output, get func(l *model.LogEntry) any) *graphql.Field {
+	return &graphql.Field{Type: t, Resolve: func(p graphql.ResolveParams) (any, error) {
+		if l, ok := p.Source.(*model.LogEntry); ok {
+			return get(l), nil
+		}
+		return nil, nil
+	}}
+}
+
+var graphqlLogEntryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "LogEntry",
+	Fields: graphql.Fields{
+		"id":        logEntryField(graphql.NewNonNull(graphql.Int), func(l *model.LogEntry) any { return l.ID }),
+		"uid":       logEntryField(graphql.String, func(l *model.LogEntry) any { return l.UID }),
+		"projectId": logEntryField(graphql.NewNonNull(graphql.Int), func(l *model.LogEntry) any { return l.ProjectID }),
+		"userAgent": logEntryField(graphql.String, func(l *model.LogEntry) any { return l.UserAgent }),
+		"ip":        logEntryField(graphql.String, func(l *model.LogEntry) any { return l.IP }),
+		"visitorId": logEntryField(graphql.String, func(l *model.LogEntry) any { return l.VisitorID }),
+		"country":   logEntryField(graphql.String, func(l *model.LogEntry) any { return l.Country }),
+		"path":      logEntryField(graphql.String, func(l *model.LogEntry) any { return l.Path }),
+		"referrer":  logEntryField(graphql.String, func(l *model.LogEntry) any { return l.Referrer }),
+		"eventType": logEntryField(graphql.NewNonNull(graphql.String), func(l *model.LogEntry) any { return l.EventType }),
+		"level":     logEntryField(graphql.String, func(l *model.LogEntry) any { return l.Level }),
+		"message":   logEntryField(graphql.String, func(l *model.LogEntry) any { return l.Message }),
+		"browser":   logEntryField(graphql.String, func(l *model.LogEntry) any { return l.Browser }),
+		"os":        logEntryField(graphql.String, func(l *model.LogEntry) any { return l.OS }),
+		"device":    logEntryField(graphql.String, func(l *model.LogEntry) any { return l.Device }),
+		"isBot":     logEntryField(graphql.NewNonNull(graphql.Boolean), func(l *model.LogEntry) any { return l.IsBot }),
+		"createdAt": logEntryField(graphql.NewNonNull(graphql.DateTime), func(l *model.LogEntry) any { return l.CreatedAt }),
+		"expiresAt": logEntryField(graphql.DateTime, func(l *model.LogEntry) any {
+			if l.ExpiresAt == nil {
+				return nil
+			}
+			return *l.ExpiresAt
+		}),
+		"hitCount": logEntryField(graphql.NewNonNull(graphql.Int), func(l *model.LogEntry) any { return max(l.HitCount, 1) }),
+		"lastHitAt": logEntryField(graphql.DateTime, func(l *model.LogEntry) any {
+			if l.LastHitAt == nil {
+				return nil
+			}
+			return *l.LastHitAt
+		}),
+		"tags": logEntryField(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), func(l *model.LogEntry) any {
+			if l.Tags == nil {
+				return []string{}
+			}
+			return l.Tags
+		}),
+		"note": logEntryField(graphql.String, func(l *model.LogEntry) any { return l.Note }),
+		"status": logEntryField(graphql.Int, func(l *model.LogEntry) any {
+			if l.Status == 0 {
+				return nil
+			}
+			return l.Status
+		}),
+		"durationMs": logEntryField(graphql.Float, func(l *model.LogEntry) any {
+			if l.DurationMS == nil {
+				return nil
+			}
+			return *l.DurationMS
+		}),
+		// fields はキーが自由なのでJSON文字列のまま返す
+		"fields": logEntryField(graphql.String, func(l *model.LogEntry) any {
+			if len(l.Fields) == 0 {
+				return nil
+			}
+			return string(l.Fields)
+		}),
+		// CAPTURE_HEADERS で記録したヘッダーも JSON 文字列で返す
+		"headers": logEntryField(graphql.String, func(l *model.LogEntry) any {
+			if len(l.Headers) == 0 {
+				return nil
+			}
+			b, _ := json.Marshal(l.Headers)
+			return string(b)
+		}),
+		// field(name:) で fields の値を1つだけ取り出す
+		"field": &graphql.Field{
+			Type: graphql.String,
+			Args: graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				l, ok := p.Source.(*model.LogEntry)
+				if !ok || len(l.Fields) == 0 {
+					return nil, nil
+				}
+				var fields map[string]any
+				if err := json.Unmarshal(l.Fields, &fields); err != nil {
+					return nil, nil
+				}
+				v, ok := fields[p.Args["name"].(string)]
+				if !ok || v == nil {
+					return nil, nil
+				}
+				if s, ok := v.(string); ok {
+					return s, nil
+				}
+				b, _ := json.Marshal(v)
+				return string(b), nil
+			},
+		},
+	},
+})
+
+// graphqlLogPage : logs の返り値（nextBefore を次の before に渡すと続きが取れる）
+type graphqlLogPage struct {
+	Entries    []*model.LogEntry
+	NextBefore int
+	HasMore    bool
+}
+
+var graphqlLogPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "LogPage",
+	Fields: graphql.Fields{
+		"entries": &graphql.Field{Type: graphql.NewList(graphqlLogEntryType), Resolve: func(p graphql.ResolveParams) (any, error) {
+			return p.Source.(*graphqlLogPage).Entries, nil
+		}},
+		"nextBefore": &graphql.Field{Type: graphql.Int, Resolve: func(p graphql.ResolveParams) (any, error) {
+			if page := p.Source.(*graphqlLogPage); page.HasMore {
+				return page.NextBefore, nil
+			}
+			return nil, nil
+		}},
+		"hasMore": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Resolve: func(p graphql.ResolveParams) (any, error) {
+			return p.Source.(*graphqlLogPage).HasMore, nil
+		}},
+	},
+})
+
+var graphqlBucketType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Bucket",
+	Fields: graphql.Fields{
+		"key":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"count": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var graphqlGroupByEnum = func() *graphql.Enum {
+	values := graphql.EnumValueConfigMap{}
+	for name := range store.GroupByColumns {
+		values[name] = &graphql.EnumValueConfig{Value: name}
+	}
+	return graphql.NewEnum(graphql.EnumConfig{Name: "GroupBy", Values: values})
+}()
+
+// graphqlFilterArgs : logs / stats / newEntries 共通の絞り込み
+var graphqlFilterArgs = graphql.FieldConfigArgument{
+	"type":   &graphql.ArgumentConfig{Type: graphql.String, Description: "イベント種別"},
+	"level":  &graphql.ArgumentConfig{Type: graphql.String, Description: "このレベル以上"},
+	"search": &graphql.ArgumentConfig{Type: graphql.String, Description: "全文検索（websearch形式）"},
+	"since":  &graphql.ArgumentConfig{Type: graphql.DateTime},
+	"until":  &graphql.ArgumentConfig{Type: graphql.DateTime},
+}
+
+// withArgs : 共通の絞り込みに引数を足す
+func withArgs(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{}
+	for k, v := range graphqlFilterArgs {
+		args[k] = v
+	}
+	for k, v := range extra {
+		args[k] = v
+	}
+	return args
+}
+
+// graphqlFilter : 引数を絞り込み条件にする
+func graphqlFilter(p graphql.ResolveParams) (store.LogFilter, error) {
+	f := store.LogFilter{ProjectID: p.Context.Value(graphqlProjectKey{}).(int)}
+	f.EventType, _ = p.Args["type"].(string)
+	if level, ok := p.Args["level"].(string); ok && level != "" {
+		normalized, err := model.NormalizeLevel(level)
+		if err != nil {
+			return f, err
+		}
+		f.MinLevel = normalized
+	}
+	f.Search, _ = p.Args["search"].(string)
+	f.Since, _ = p.Args["since"].(time.Time)
+	f.Until, _ = p.Args["until"].(time.Time)
+	return f, nil
+}
+
+// graphqlQueryType : Query（リゾルバがサーバーの Store を使うのでサーバーごとに作る）
+func (s *Server) graphqlQueryType() *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			// logs(type:, level:, search:, since:, until:, before:, limit:) 新しい順
+			"logs": &graphql.Field{
+				Type: graphql.NewNonNull(graphqlLogPageType),
+				Args: withArgs(graphql.FieldConfigArgument{
+					"before": &graphql.ArgumentConfig{Type: graphql.Int, Description: "このidより古いものを返す"},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 50, Description: "最大200"},
+				}),
+				Resolve: s.resolveGraphQLLogs,
+			},
+			// stats(groupBy:, ...) 件数の多い順
+			"stats": &graphql.Field{
+				Type: graphql.NewList(graphqlBucketType),
+				Args: withArgs(graphql.FieldConfigArgument{
+					"groupBy": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphqlGroupByEnum)},
+					"limit":   &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20},
+				}),
+				Resolve: s.resolveGraphQLStats,
+			},
+			// count(...) 条件に一致する件数
+			"count": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Args: graphqlFilterArgs,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					f, err := graphqlFilter(p)
+					if err != nil {
+						return nil, err
+					}
+					return s.store.CountLogs(p.Context, f)
+				},
+			},
+		},
+	})
+}
+
+// resolveGraphQLLogs : logs のページを取得する（1件多く読んで続きがあるか判定）
+func (s *Server) resolveGraphQLLogs(p graphql.ResolveParams) (any, error) {
+	f, err := graphqlFilter(p)
+	if err != nil {
+		return nil, err
+	}
+	f.BeforeID, _ = p.Args["before"].(int)
+	limit, _ := p.Args["limit"].(int)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	f.Limit = limit + 1
+
+	logs, err := s.store.QueryLogs(p.Context, f)
+	if err != nil {
+		return nil, err
+	}
+	logs = s.redact.Entries(logs, redact.ScopeFrom(p.Context))
+	page := &graphqlLogPage{Entries: []*model.LogEntry{}}
+	for i := range logs {
+		page.Entries = append(page.Entries, &logs[i])
+	}
+	if len(page.Entries) > limit {
+		page.Entries = page.Entries[:limit]
+		page.HasMore = true
+		page.NextBefore = page.Entries[limit-1].ID
+	}
+	return page, nil
+}
+
+// resolveGraphQLStats : groupBy の列ごとの件数
+func (s *Server) resolveGraphQLStats(p graphql.ResolveParams) (any, error) {
+	f, err := graphqlFilter(p)
+	if err != nil {
+		return nil, err
+	}
+	f.Limit, _ = p.Args["limit"].(int)
+	if f.Limit <= 0 || f.Limit > 200 {
+		f.Limit = 20
+	}
+	groupBy, _ := p.Args["groupBy"].(string)
+	return s.store.GroupLogs(p.Context, f, groupBy)
+}
+
+// graphqlSubscriptionType : Subscription（サーバーのハブから新着を受け取る）
+func (s *Server) graphqlSubscriptionType() *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			// newEntries(type:, level:) 保存された新着ログを1件ずつ届ける
+			"newEntries": &graphql.Field{
+				Type: graphqlLogEntryType,
+				Args: graphql.FieldConfigArgument{
+					"type":  &graphql.ArgumentConfig{Type: graphql.String},
+					"level": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Subscribe: s.subscribeGraphQLEntries,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source, nil
+				},
+			},
+		},
+	})
+}
+
+// subscribeGraphQLEntries : ハブの新着ログを条件で絞ってサブスクリプションに流す
+func (s *Server) subscribeGraphQLEntries(p graphql.ResolveParams) (any, error) {
+	projectID := p.Context.Value(graphqlProjectKey{}).(int)
+	eventType, _ := p.Args["type"].(string)
+	minRank := -1
+	if level, ok := p.Args["level"].(string); ok && level != "" {
+		normalized, err := model.NormalizeLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		minRank = model.LevelRank(normalized)
+	}
+
+	scope := redact.ScopeFrom(p.Context)
+	entries, unsubscribe := s.hub.subscribe()
+	out := make(chan any)
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+		for {
+			select {
+			case <-p.Context.Done():
+				return
+			case e, ok := <-entries:
+				if !ok {
+					return
+				}
+				if e.ProjectID != projectID || !model.EventTypeMatches(eventType, e.EventType) || model.LevelRank(e.Level) < minRank {
+					continue
+				}
+				select {
+				case out <- s.redact.EntryPtr(e, scope):
+				case <-p.Context.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// graphqlSchema : サーバーごとのスキーマ
+func (s *Server) graphqlSchema() graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:        s.graphqlQueryType(),
+		Subscription: s.graphqlSubscriptionType(),
+	})
+	if err != nil {
+		panic("invalid GraphQL schema: " + err.Error())
+	}
+	return schema
+}
+
+// graphqlHandler : GET/POST /api/graphql
+// Accept: text/event-stream で送るとサブスクリプションをSSEで配信する
+func (s *Server) graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		var req graphqlRequest
+		if r.Method == http.MethodGet {
+			req.Query = r.URL.Query().Get("query")
+			req.OperationName = r.URL.Query().Get("operationName")
+			if v := r.URL.Query().Get("variables"); v != "" {
+				if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+					http.Error(w, "Invalid variables: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+		} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Query == "" {
+			http.Error(w, `Missing "query"`, http.StatusBadRequest)
+			return
+		}
+
+		params := graphql.Params{
+			Schema:         s.schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        redact.WithScope(context.WithValue(r.Context(), graphqlProjectKey{}, projectID), s.requestScope(r)),
+		}
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			if !s.cfg.Features.Streams {
+				http.Error(w, "Streaming is disabled (FEATURE_STREAMS=false)", http.StatusNotFound)
+				return
+			}
+			serveGraphQLSubscription(w, r, params)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(graphql.Do(params))
+	})
+}
+
+// serveGraphQLSubscription : 結果を1件ずつ "event: next" として送る
+func serveGraphQLSubscription(w http.ResponseWriter, r *http.Request, params graphql.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	results := graphql.Subscribe(params)
+	defer func() {
+		// 切断後に送信待ちで止まらないよう、閉じられるまで読み捨てる
+		go func() {
+			for range results {
+			}
+		}()
+	}()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case res, ok := <-results:
+			if !ok {
+				fmt.Fprint(w, "event: complete\ndata:\n\n")
+				flusher.Flush()
+				return
+			}
+			data, _ := json.Marshal(res)
+			fmt.Fprintf(w, "event: next\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"go-logger/internal/config"
+	"go-logger/internal/i18n"
+)
+
+// ==========================================
+// リクエストの検証 (メソッド・本文の大きさ・処理時間の上限)
+// ==========================================
+// 不正なクライアントや遅いクライアントがサーバーを占有しないようにする
+// ヘッダーの大きさは http.Server の MaxHeaderBytes で制限する（超えると431）
+
+// writeMethodsFromEnv : WRITE_METHODS（記録対象パスで受け付けるメソッド。既定 GET,POST）
+// POST だけにするとプリフェッチやクローラーのGETでは記録されなくなる
+func writeMethodsFromEnv() []string {
+	var methods []string
+	for _, m := range config.List("WRITE_METHODS") {
+		methods = append(methods, strings.ToUpper(m))
+	}
+	if len(methods) == 0 {
+		return []string{http.MethodGet, http.MethodPost}
+	}
+	return methods
+}
+
+// allowWriteMethods : 記録対象パスへの WRITE_METHODS 以外のリクエストに405を返す
+func (s *Server) allowWriteMethods(next http.HandlerFunc) http.HandlerFunc {
+	allow := strings.Join(s.cfg.WriteMethods, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(s.cfg.WriteMethods, r.Method) {
+			w.Header().Set("Allow", allow)
+			writeJSONError(w, http.StatusMethodNotAllowed, i18n.T(w, r, i18n.MethodNotAllowed, r.Method, allow))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// longRunning : 処理時間の上限をかけないリクエスト（SSE・WebSocket の購読と全件の書き出し、書き出したファイルのダウンロード、CPU プロファイル・トレース）
+// TimeoutHandler は応答を全てメモリに溜めるので、大きなファイルを返すものもここに入れる
+// harden は withAPIVersion より外で動くので、/api/v1/... も /api/... と同じに扱う
+func longRunning(r *http.Request) bool {
+	path := unversionedPath(r.URL.Path)
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream") ||
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		path == "/api/admin/snapshot" ||
+		isExportDownload(path) ||
+		strings.HasPrefix(path, debugPprofPrefix)
+}
+
+// isExportDownload : GET /api/exports/{id}/download のパスか
+func isExportDownload(path string) bool {
+	id, ok := strings.CutPrefix(path, "/api/exports/")
+	if !ok {
+		return false
+	}
+	id, ok = strings.CutSuffix(id, "/download")
+	return ok && id != "" && !strings.Contains(id, "/")
+}
+
+// harden : 全てのルートに本文の大きさ (MAX_BODY_BYTES) と処理時間 (HANDLER_TIMEOUT) の上限をかける
+// エラーのメッセージは Accept-Language で英語・日本語を切り替える
+// ハンドラごとの上限（JSONの本文は64KBなど）はこれより小さければそちらが効く
+func (s *Server) harden(next http.Handler) http.Handler {
+	// TimeoutHandler の本文は固定なので、言語ごとに作っておく
+	timed := map[language.Tag]http.Handler{}
+	for _, lang := range i18n.Languages {
+		body, _ := json.Marshal(apiError{Error: i18n.Message(lang, i18n.Timeout, s.cfg.HandlerTimeout), Code: codeTimeout, Status: http.StatusServiceUnavailable})
+		timed[lang] = http.TimeoutHandler(next, s.cfg.HandlerTimeout, string(body))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.MaxBodyBytes > 0 {
+			if r.ContentLength > s.cfg.MaxBodyBytes {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, i18n.T(w, r, i18n.BodyTooLarge, s.cfg.MaxBodyBytes))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes)
+		}
+		if s.cfg.HandlerTimeout <= 0 || longRunning(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		timed[i18n.Lang(r)].ServeHTTP(&timeoutWriter{ResponseWriter: w}, r)
+	})
+}
+
+// timeoutWriter : TimeoutHandler が返す503の本文（JSON）に Content-Type を付ける
+// ハンドラ自身のエラーは http.Error などで Content-Type が付いているのでそのまま
+type timeoutWriter struct {
+	http.ResponseWriter
+}
+
+func (w *timeoutWriter) WriteHeader(status int) {
+	if status == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
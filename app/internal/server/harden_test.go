@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestLongRunning : 大きな応答・長く続く応答のパスだけ処理時間の上限を外す
+func TestLongRunning(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/api/logs", false},
+		{"/api/admin/snapshot", true},
+		{"/api/admin/debug/pprof/profile", true},
+		{"/api/exports/12/download", true},
+		{"/api/exports/12", false},
+		{"/api/exports//download", false},
+		{"/api/exports/12/x/download", false},
+		{"/api/v1/logs", false},
+		{"/api/v1/admin/snapshot", true},
+		{"/api/v1/admin/debug/pprof/profile", true},
+		{"/api/v1/exports/12/download", true},
+		{"/api/visitors", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		if got := longRunning(r); got != tt.want {
+			t.Errorf("longRunning(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
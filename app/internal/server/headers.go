@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// リクエストヘッダーの記録 (CAPTURE_HEADERS)
+// ==========================================
+// UA だけでは調べきれない時のために、許可したヘッダーだけをログの headers (JSONB) に残す
+// 例: CAPTURE_HEADERS=Accept-Language,X-Request-Id,Traceparent
+// 記録したヘッダーは GET /api/logs/{id} の headers で見られる（REDACT_RULES=headers=admin で隠せる）
+
+// maxCapturedHeaderLen : 1つのヘッダーに記録する長さの上限（超えた分は切り詰める）
+const maxCapturedHeaderLen = 1024
+
+// credentialHeaders : 認証情報そのものなので、CAPTURE_HEADERS に書いても記録しないヘッダー
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// CaptureHeadersFromEnv : CAPTURE_HEADERS のヘッダー名（正規化し、重複と認証情報のヘッダーを除く）
+func CaptureHeadersFromEnv() []string {
+	var names []string
+	for _, item := range config.List("CAPTURE_HEADERS") {
+		name := http.CanonicalHeaderKey(item)
+		if slices.Contains(credentialHeaders, name) {
+			fmt.Printf("Ignoring captured header %q: it carries credentials\n", name)
+			continue
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// capturedHeaders : リクエストのうち CAPTURE_HEADERS のヘッダー（1つもなければ nil。同じヘッダーが複数あれば ", " でつなぐ）
+func (s *Server) capturedHeaders(r *http.Request) map[string]string {
+	var headers map[string]string
+	for _, name := range s.cfg.CaptureHeaders {
+		values := r.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		v := strings.Join(values, ", ")
+		if len(v) > maxCapturedHeaderLen {
+			v = strings.ToValidUTF8(v[:maxCapturedHeaderLen], "")
+		}
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headers[name] = v
+	}
+	return headers
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/config"
+	"go-logger/internal/i18n"
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+)
+
+// ==========================================
+// 外部からの Webhook の受け口 (POST /api/hooks/{source})
+// ==========================================
+// GitHub・Stripe などの Webhook を署名を確かめてからログとして保存し、通知のルールに従って Discord などへ流す
+// 保存するログは event_type=hook で、fields に source（受け口の名前）・event・delivery と元の本文 (payload) を持つ
+
+// hookEventType : 受け取った Webhook のイベント種別
+const hookEventType = "hook"
+
+// 受け口の種類
+const (
+	hookGitHub  = "github"  // X-Hub-Signature-256: sha256=HMAC-SHA256(body)
+	hookStripe  = "stripe"  // Stripe-Signature: t=<timestamp>,v1=HMAC-SHA256("<timestamp>.<body>")
+	hookGeneric = "generic" // X-Logger-Signature: sha256=HMAC-SHA256("<timestamp>.<body>")（送信側の Webhook と同じ形。鍵がなければ確かめない）
+)
+
+// hookMaxSkew : Stripe・汎用の署名の時刻のずれの上限（古い本文の使い回しを防ぐ）
+const hookMaxSkew = 5 * time.Minute
+
+// InboundHook : 受け口1つの設定
+type InboundHook struct {
+	Name   string // パスの {source}
+	Kind   string // github / stripe / generic
+	Secret string
+}
+
+// InboundHooksFromEnv : 環境変数から受け口を読み込む
+// INBOUND_HOOKS="github=github:<secret>,billing=stripe:whsec_...,deploy=generic:<secret>"（名前を省略すると種類と同じ名前）
+// 名前は最初の : より前の = までとする（名前に : は使えない。generic:abc= のように鍵に = を含めても名前の省略として読む）
+func InboundHooksFromEnv() map[string]InboundHook {
+	hooks := map[string]InboundHook{}
+	for _, item := range config.List("INBOUND_HOOKS") {
+		name, spec, named := strings.Cut(item, "=")
+		if colon := strings.Index(item, ":"); colon >= 0 && colon < len(name) {
+			named = false
+		}
+		if !named {
+			spec = item
+		}
+		kind, secret, _ := strings.Cut(spec, ":")
+		if !named {
+			name = kind
+		}
+		switch kind {
+		case hookGitHub, hookStripe:
+			if secret == "" {
+				fmt.Printf("Ignoring inbound hook %q: secret is required for %s\n", name, kind)
+				continue
+			}
+		case hookGeneric:
+		default:
+			fmt.Printf("Ignoring inbound hook %q: unknown kind %q (use github, stripe or generic)\n", name, kind)
+			continue
+		}
+		hooks[name] = InboundHook{Name: name, Kind: kind, Secret: secret}
+	}
+	return hooks
+}
+
+// hookEvent : 本文とヘッダーから取り出した内容
+type hookEvent struct {
+	Event    string // push / invoice.paid など
+	Delivery string // 送信側の配信ID（再送の見分け用）
+	Level    string
+	Message  string
+}
+
+// hookHandler : POST /api/hooks/{source}
+// ?key= でプロジェクトを指定できる（送信側でヘッダーを足せないことが多いため）
+func (s *Server) hookHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := s.cfg.InboundHooks[r.PathValue("source")]
+	if !ok {
+		http.Error(w, "Not Found: unknown hook source (set INBOUND_HOOKS)", http.StatusNotFound)
+		return
+	}
+	s.withProject(w, r, func(projectID int) {
+		body, err := readBody(w, r, maxLogBodyBytes)
+		if err != nil {
+			http.Error(w, i18n.T(w, r, i18n.InvalidRequest, err), http.StatusBadRequest)
+			return
+		}
+		if !s.validHookSignature(hook, r, body) {
+			http.Error(w, "Unauthorized: invalid webhook signature", http.StatusUnauthorized)
+			return
+		}
+		var payload map[string]any
+		if err := json.Unmarshal(body, &payload); err != nil {
+			http.Error(w, i18n.T(w, r, i18n.InvalidJSON, err), http.StatusBadRequest)
+			return
+		}
+		ev := parseHookEvent(hook, r, payload)
+		fields, err := json.Marshal(map[string]any{
+			"source":   hook.Name,
+			"event":    ev.Event,
+			"delivery": ev.Delivery,
+			"payload":  json.RawMessage(body),
+		})
+		if err != nil {
+			http.Error(w, i18n.T(w, r, i18n.InvalidJSON, err), http.StatusBadRequest)
+			return
+		}
+
+		lw := model.Write{
+			ProjectID: projectID,
+			UserAgent: r.UserAgent(),
+			IP:        clientIP(r),
+			Path:      r.URL.Path,
+			EventType: hookEventType,
+			Level:     ev.Level,
+			Message:   ev.Message,
+			Fields:    fields,
+			CreatedAt: s.clock.Now(),
+		}
+		status, stored, err := s.saveWrite(r.Context(), &lw)
+		if stored && s.shouldNotify(&lw) {
+			s.notifyAsync(r.Context(), notify.Notification{
+				Level: lw.Level,
+				Title: "🪝 Webhook: " + hook.Name,
+				Text:  fmt.Sprintf("🪝 [%s] %s", hook.Name, lw.Message),
+				Entry: lw.Entry(),
+			})
+		}
+
+		s.writeResult(w, r, &lw, Response{Message: i18n.T(w, r, i18n.Logged), DBStatus: status}, http.StatusOK, err)
+	})
+}
+
+// validHookSignature : 受け口の種類ごとの署名を確かめる
+func (s *Server) validHookSignature(hook InboundHook, r *http.Request, body []byte) bool {
+	switch hook.Kind {
+	case hookGitHub:
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(body)
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(want), []byte(r.Header.Get("X-Hub-Signature-256")))
+	case hookStripe:
+		// 鍵の入れ替え中は v1 が複数付くので、どれか1つが合えばよい
+		var timestamp string
+		var signatures []string
+		for _, part := range strings.Split(r.Header.Get("Stripe-Signature"), ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch k {
+			case "t":
+				timestamp = v
+			case "v1":
+				signatures = append(signatures, v)
+			}
+		}
+		if !s.freshHookTimestamp(timestamp) {
+			return false
+		}
+		want := signWebhook(hook.Secret, timestamp, body)
+		for _, sig := range signatures {
+			if hmac.Equal([]byte(want), []byte(sig)) {
+				return true
+			}
+		}
+		return false
+	default:
+		if hook.Secret == "" {
+			return true
+		}
+		timestamp := r.Header.Get(webhookTimestampHeader)
+		if !s.freshHookTimestamp(timestamp) {
+			return false
+		}
+		want := "sha256=" + signWebhook(hook.Secret, timestamp, body)
+		return hmac.Equal([]byte(want), []byte(r.Header.Get(webhookSignatureHeader)))
+	}
+}
+
+// freshHookTimestamp : 署名の時刻（UNIX秒）が hookMaxSkew 以内か
+func (s *Server) freshHookTimestamp(timestamp string) bool {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	skew := s.clock.Now().Sub(time.Unix(sec, 0))
+	return skew <= hookMaxSkew && skew >= -hookMaxSkew
+}
+
+// parseHookEvent : 本文からイベント名・レベル・一覧に出すメッセージを決める
+func parseHookEvent(hook InboundHook, r *http.Request, payload map[string]any) hookEvent {
+	ev := hookEvent{Level: model.DefaultLevel}
+	switch hook.Kind {
+	case hookGitHub:
+		// 例: "pull_request opened: owner/repo"
+		ev.Event, ev.Delivery = r.Header.Get("X-GitHub-Event"), r.Header.Get("X-GitHub-Delivery")
+		ev.Message = ev.Event
+		if action := hookString(payload, "action"); action != "" {
+			ev.Message += " " + action
+		}
+		if repo, ok := payload["repository"].(map[string]any); ok {
+			ev.Message += ": " + hookString(repo, "full_name")
+		}
+		// CI の失敗は warn にする
+		for _, key := range []string{"workflow_run", "check_run", "check_suite"} {
+			if run, ok := payload[key].(map[string]any); ok && hookString(run, "conclusion") == "failure" {
+				ev.Level = "warn"
+			}
+		}
+	case hookStripe:
+		// 例: "invoice.payment_failed (evt_...)"
+		ev.Event, ev.Delivery = hookString(payload, "type"), hookString(payload, "id")
+		ev.Message = fmt.Sprintf("%s (%s)", ev.Event, ev.Delivery)
+		if strings.Contains(ev.Event, "failed") {
+			ev.Level = "error"
+		}
+	default:
+		// 送信側で "event"・"level"・"message" を入れていれば使う
+		ev.Event, ev.Delivery = hookString(payload, "event"), r.Header.Get(webhookDeliveryHeader)
+		ev.Message = hookString(payload, "message")
+		if level, err := model.NormalizeLevel(hookString(payload, "level")); err == nil {
+			ev.Level = level
+		}
+	}
+	if ev.Message == "" {
+		ev.Message = "webhook " + hook.Name
+	}
+	return ev
+}
+
+// hookString : m[key] が文字列ならその値（なければ空）
+func hookString(m map[string]any, key string) string {
+	v, _ := m[key].(string)
+	return v
+}
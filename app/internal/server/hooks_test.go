@@ -0,0 +1,24 @@
+package server
+
+import "testing"
+
+// TestInboundHooksFromEnv : 名前は最初の : より前の = まで。鍵に = を含めても名前の省略として読む
+func TestInboundHooksFromEnv(t *testing.T) {
+	t.Setenv("INBOUND_HOOKS", "generic:abc=,billing=stripe:whsec_x=y,github,deploy=generic,ci=github:s3cret,bad=unknown:x")
+	hooks := InboundHooksFromEnv()
+
+	want := map[string]InboundHook{
+		"generic": {Name: "generic", Kind: hookGeneric, Secret: "abc="},
+		"billing": {Name: "billing", Kind: hookStripe, Secret: "whsec_x=y"},
+		"deploy":  {Name: "deploy", Kind: hookGeneric},
+		"ci":      {Name: "ci", Kind: hookGitHub, Secret: "s3cret"},
+	}
+	if len(hooks) != len(want) {
+		t.Errorf("hooks %+v", hooks)
+	}
+	for name, w := range want {
+		if got, ok := hooks[name]; !ok || got != w {
+			t.Errorf("hooks[%q] = %+v, want %+v", name, got, w)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"sync"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 新着ログの配信 (保存されたログをプロセス内の購読者に流す)
+// ==========================================
+
+// entryHub : 保存に成功したログを購読者へ配る
+type entryHub struct {
+	mu   sync.Mutex
+	subs map[chan *model.LogEntry]struct{}
+}
+
+func newEntryHub() *entryHub {
+	return &entryHub{subs: map[chan *model.LogEntry]struct{}{}}
+}
+
+// subscribe : 購読を開始する（返り値の関数で解除する）
+func (h *entryHub) subscribe() (<-chan *model.LogEntry, func()) {
+	ch := make(chan *model.LogEntry, 64)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// publish : 全ての購読者へ送る（受け取りが追いつかない購読者の分は捨てて書き込みを止めない）
+func (h *entryHub) publish(e *model.LogEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
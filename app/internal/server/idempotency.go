@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 書き込みの Idempotency-Key (通信エラー後の送り直しで二重に保存しない)
+// ==========================================
+// 同じキーで送り直されたリクエストには、保存せずに最初の応答をそのまま返す (Idempotent-Replayed: true)
+// キーはプロジェクトごとに IDEMPOTENCY_TTL（既定24時間）覚えておく
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength : キーの長さの上限
+	maxIdempotencyKeyLength = 255
+	// idempotencyLockTimeout : 処理中のキーを押さえておく時間（落ちたインスタンスのキーはこの後に引き継げる）
+	idempotencyLockTimeout = time.Minute
+	// maxIdempotentBodyBytes : 保存する応答の本文の上限（超えたら保存せず、送り直しはもう一度処理する）
+	maxIdempotentBodyBytes = 1 << 20
+)
+
+// idempotentHeaders : 送り直しにも返すヘッダー
+var idempotentHeaders = []string{"Content-Type", "Content-Language", writeTokenHeader}
+
+// idempotent : Idempotency-Key の付いた書き込みを1回だけ処理する（キーがなければそのまま）
+// 処理中に同じキーが届いたら 409、別の内容に同じキーを使ったら 422 を返す
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || s.cfg.IdempotencyTTL <= 0 || s.cfg.DryRun {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength), http.StatusBadRequest)
+			return
+		}
+		projectID, err := s.resolveProject(r.Context(), r)
+		if err != nil {
+			next(w, r) // キーの誤りなどはハンドラのエラーにする
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scoped := strconv.Itoa(projectID) + ":" + key
+		hash := requestHash(r, body)
+		now := s.clock.Now()
+		saved, claimed, err := s.store.ClaimIdempotencyKey(r.Context(), scoped, hash, now, now.Add(idempotencyLockTimeout))
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+		if !claimed {
+			switch {
+			case saved.RequestHash != "" && saved.RequestHash != hash:
+				http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+			case saved.Status == 0:
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+			default:
+				replayIdempotent(w, saved)
+			}
+			return
+		}
+
+		rec := &idempotentWriter{ResponseWriter: w}
+		next(rec, r)
+
+		// クライアントが切断していても、応答は覚えておく（送り直しに返すため）
+		ctx := context.WithoutCancel(r.Context())
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status >= 500 || rec.status == http.StatusTooManyRequests || rec.overflow {
+			// 送り直せば成功するかもしれないので、キーを外してもう一度処理できるようにする
+			if err := s.store.ReleaseIdempotencyKey(ctx, scoped); err != nil {
+				fmt.Println("Failed to release idempotency key:", err)
+			}
+			return
+		}
+		resp := store.IdempotentResponse{RequestHash: hash, Status: rec.status, Body: rec.body.Bytes(), Headers: map[string]string{}}
+		for _, h := range idempotentHeaders {
+			if v := w.Header().Get(h); v != "" {
+				resp.Headers[h] = v
+			}
+		}
+		if err := s.store.CompleteIdempotencyKey(ctx, scoped, resp, s.clock.Now().Add(s.cfg.IdempotencyTTL)); err != nil {
+			fmt.Println("Failed to save idempotent response:", err)
+		}
+	}
+}
+
+// requestHash : 同じキーで別の内容を送っていないか確かめるための、メソッド・パス・本文のハッシュ
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotentWriter : 応答の状態コードと本文を覚えておく（書いた分はそのまま返す）
+type idempotentWriter struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	overflow bool // 本文が maxIdempotentBodyBytes を超えた
+}
+
+func (w *idempotentWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *idempotentWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.overflow {
+		if w.body.Len()+len(p) > maxIdempotentBodyBytes {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *idempotentWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// replayIdempotent : 覚えておいた最初の応答を返す
+func replayIdempotent(w http.ResponseWriter, saved store.IdempotentResponse) {
+	for h, v := range saved.Headers {
+		w.Header().Set(h, v)
+	}
+	w.Header().Set(idempotencyReplayedHeader, "true")
+	w.WriteHeader(saved.Status)
+	w.Write(saved.Body)
+}
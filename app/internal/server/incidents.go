@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+)
+
+// ==========================================
+// インシデント (PagerDuty / Opsgenie)
+// ==========================================
+// チャットとは別に、エラー以上のログと発火したアラートルールだけを送る。同じキーの間は1つのインシデントにまとめ、
+// 収まったら resolve する（件数のルールが閾値を下回った・稼働監視が up に戻った・データ量や急増が収まった、
+// またはどれでもなく INCIDENT_RESOLVE_AFTER の間新しいアラートがなかった）
+// 開いているインシデントはメモリに持つので、再起動をまたいだ分は送信先で閉じる
+
+// incidentCheckInterval : 静かになったインシデントを探す間隔
+const incidentCheckInterval = time.Minute
+
+// IncidentConfig : インシデントに送る通知の選び方
+type IncidentConfig struct {
+	MinLevel     string        // このレベル以上の通知を送る（アラートルールはレベルによらず送る）
+	ResolveAfter time.Duration // この間新しいアラートがなければ resolve する（0なら時間では閉じない）
+}
+
+// IncidentsFromEnv : INCIDENT_MIN_LEVEL（既定 error）と INCIDENT_RESOLVE_AFTER（既定 30分）
+func IncidentsFromEnv() IncidentConfig {
+	level, err := model.NormalizeLevel(config.String("INCIDENT_MIN_LEVEL", "error"))
+	if err != nil {
+		fmt.Println("Invalid INCIDENT_MIN_LEVEL, using error:", err)
+		level = "error"
+	}
+	return IncidentConfig{
+		MinLevel:     level,
+		ResolveAfter: config.Duration("INCIDENT_RESOLVE_AFTER", 30*time.Minute),
+	}
+}
+
+// incidentState : 開いているインシデントのキーと、最後にアラートがあった日時
+type incidentState struct {
+	mu   sync.Mutex
+	open map[string]time.Time
+}
+
+// touch : キーのインシデントを開く（開いていれば最後の日時を進める）
+func (is *incidentState) touch(key string, now time.Time) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	is.open[key] = now
+}
+
+// extend : 開いている時だけ最後の日時を進める（クールダウン中でも続いているアラート）
+func (is *incidentState) extend(key string, now time.Time) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if _, ok := is.open[key]; ok {
+		is.open[key] = now
+	}
+}
+
+// close : 開いていたら閉じて true を返す
+func (is *incidentState) close(key string) bool {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if _, ok := is.open[key]; !ok {
+		return false
+	}
+	delete(is.open, key)
+	return true
+}
+
+// quiet : before より前から新しいアラートのないキー
+func (is *incidentState) quiet(before time.Time) []string {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	var keys []string
+	for key, last := range is.open {
+		if last.Before(before) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// isIncident : インシデントの通知先に送る通知か
+func (s *Server) isIncident(n notify.Notification) bool {
+	return n.Source == "rule" || model.LevelRank(n.Level) >= model.LevelRank(s.cfg.Incidents.MinLevel)
+}
+
+// hasIncidents : インシデントの通知先が設定されているか
+func (s *Server) hasIncidents() bool {
+	return s.incidents.Len() > 0
+}
+
+// resolveIncident : キーのインシデントが開いていれば、閉じる通知を送信待ちのキューに積む（失敗したら送り直す）
+func (s *Server) resolveIncident(ctx context.Context, key, text string) {
+	if !s.hasIncidents() || !s.openIncidents.close(key) {
+		return
+	}
+	q := queuedNotification{
+		Notification: notify.Notification{Level: "info", Title: "Resolved", Text: text, Key: key, Resolved: true},
+		Prepared:     true, // 履歴には残さない
+	}
+	if !s.dedupNotification(ctx, q.Notification) {
+		return
+	}
+	if err := s.enqueueNotification(ctx, q); err != nil {
+		fmt.Println("Dropping incident resolution:", err)
+	}
+}
+
+// watchIncidents : INCIDENT_RESOLVE_AFTER の間新しいアラートがなかったインシデントを閉じる
+func (s *Server) watchIncidents(ctx context.Context) {
+	if !s.hasIncidents() || s.cfg.Incidents.ResolveAfter <= 0 {
+		return
+	}
+	ticker := s.clock.NewTicker(incidentCheckInterval)
+	defer ticker.Stop()
+	j := s.jobs.register("incidents", incidentCheckInterval)
+
+	for {
+		s.runJob(j, func() error {
+			after := s.cfg.Incidents.ResolveAfter
+			for _, key := range s.openIncidents.quiet(s.clock.Now().Add(-after)) {
+				s.resolveIncident(ctx, key, fmt.Sprintf("No new alerts for %s", after))
+			}
+			return nil
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
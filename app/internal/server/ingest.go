@@ -0,0 +1,305 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/i18n"
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 構造化ログの取り込み (POST /api/logs)
+// ==========================================
+
+// logEventType : 構造化ログのイベント種別
+const logEventType = "log"
+
+// maxLogBodyBytes : 1リクエストあたりの本文サイズ上限
+const maxLogBodyBytes = 1 << 20
+
+// logBody : POST /api/logs の本文から取り出した値
+type logBody struct {
+	EventType string // "event_type": access / pageview / error / custom:<名前>（なければ "log"）
+	Level     string
+	Message   string
+	Fields    []byte
+	ExpiresAt time.Time // "ttl" / "expires_at" の指定（なければゼロ）
+
+	Status     int      // "status": 記録したリクエストの応答ステータス（なければ0）
+	DurationMS *float64 // "duration_ms": 同じく処理時間
+}
+
+// parseLogBody : {"event_type", "level", "message", "fields", "ttl", "status", "duration_ms"} を取り出す
+// それ以外のトップレベルのキーも fields にまとめて保存する
+func parseLogBody(body []byte, now time.Time) (logBody, error) {
+	var lb logBody
+	var raw map[string]json.RawMessage
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return lb, err
+	}
+
+	extra := map[string]json.RawMessage{}
+	if f, ok := raw["fields"]; ok && string(f) != "null" {
+		if err := json.Unmarshal(f, &extra); err != nil {
+			return lb, fmt.Errorf(`"fields" must be an object: %w`, err)
+		}
+	}
+	for k, v := range raw {
+		switch k {
+		case "event_type":
+			if err := json.Unmarshal(v, &lb.EventType); err != nil {
+				return lb, fmt.Errorf(`"event_type" must be a string`)
+			}
+		case "level":
+			if err := json.Unmarshal(v, &lb.Level); err != nil {
+				return lb, fmt.Errorf(`"level" must be a string`)
+			}
+		case "message":
+			if err := json.Unmarshal(v, &lb.Message); err != nil {
+				return lb, fmt.Errorf(`"message" must be a string`)
+			}
+		case "ttl":
+			ttl, err := parseTTL(v)
+			if err != nil {
+				return lb, err
+			}
+			lb.ExpiresAt = now.Add(ttl)
+		case "expires_at":
+			if err := json.Unmarshal(v, &lb.ExpiresAt); err != nil {
+				return lb, fmt.Errorf(`"expires_at" must be an RFC 3339 time`)
+			}
+		case "status":
+			var err error
+			if lb.Status, err = parseStatusJSON(v); err != nil {
+				return lb, err
+			}
+		case "duration_ms":
+			var err error
+			if lb.DurationMS, err = parseDurationJSON(v); err != nil {
+				return lb, err
+			}
+		case "fields":
+		default:
+			extra[k] = v
+		}
+	}
+
+	var err error
+	if lb.EventType, err = model.NormalizeEventType(lb.EventType); err != nil {
+		return lb, err
+	}
+	if lb.Level, err = model.NormalizeLevel(lb.Level); err != nil {
+		return lb, err
+	}
+	if lb.Message == "" {
+		return lb, fmt.Errorf(`"message" is required`)
+	}
+	if len(extra) > 0 {
+		if lb.Fields, err = json.Marshal(extra); err != nil {
+			return lb, err
+		}
+	}
+	return lb, nil
+}
+
+// ingestLogHandler : POST /api/logs で構造化ログを保存する
+func (s *Server) ingestLogHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		body, err := readBody(w, r, maxLogBodyBytes)
+		if err != nil {
+			http.Error(w, i18n.T(w, r, i18n.InvalidRequest, err), http.StatusBadRequest)
+			return
+		}
+		now := s.clock.Now()
+		lb, err := parseLogBody(body, now)
+		if err != nil {
+			http.Error(w, i18n.T(w, r, i18n.InvalidJSON, err), http.StatusBadRequest)
+			return
+		}
+
+		lw := s.logWrite(r, projectID, lb, now)
+		// 保存は常に行い、通知はレベルのルールに従う（既定では error 以上のみ）
+		status, stored, err := s.saveWrite(r.Context(), &lw)
+		if stored {
+			s.notifyLog(r.Context(), &lw)
+		}
+		s.writeResult(w, r, &lw, Response{Message: i18n.T(w, r, i18n.Logged), DBStatus: status}, http.StatusOK, err)
+	})
+}
+
+// logWrite : 構造化ログ1件分の書き込み
+func (s *Server) logWrite(r *http.Request, projectID int, lb logBody, now time.Time) model.Write {
+	eventType := lb.EventType
+	if eventType == "" {
+		eventType = logEventType
+	}
+	return model.Write{
+		ProjectID:  projectID,
+		UserAgent:  r.UserAgent(),
+		IP:         clientIP(r),
+		Path:       r.URL.Path,
+		Referrer:   r.Referer(),
+		EventType:  eventType,
+		Level:      lb.Level,
+		Message:    lb.Message,
+		Fields:     lb.Fields,
+		CreatedAt:  now,
+		ExpiresAt:  lb.ExpiresAt,
+		Status:     lb.Status,
+		DurationMS: lb.DurationMS,
+		Headers:    s.capturedHeaders(r),
+	}
+}
+
+// notifyLog : 保存した構造化ログを、レベルのルールに合えば通知する
+func (s *Server) notifyLog(ctx context.Context, lw *model.Write) {
+	if !s.shouldNotify(lw) {
+		return
+	}
+	s.notifyAsync(ctx, notify.Notification{
+		Level: lw.Level,
+		Title: "📝 " + strings.ToUpper(lw.Level),
+		Text:  fmt.Sprintf("📝 [%s] %s", strings.ToUpper(lw.Level), lw.Message),
+		Entry: lw.Entry(),
+	})
+}
+
+// ==========================================
+// まとめて取り込み (POST /api/logs/batch)
+// ==========================================
+// クライアントのSDKが溜めたログを1回で送る。検証を通った分を1つのトランザクションで保存し、1件ごとの結果を返す
+
+// maxBatchBodyBytes : まとめて取り込む本文のサイズ上限（MAX_BODY_BYTES のほうが小さければそちらが効く）
+const maxBatchBodyBytes = 16 << 20
+
+// batchItemResult : まとめて取り込んだ1件の結果（index は送られた配列の位置）
+type batchItemResult struct {
+	Index      int    `json:"index"`
+	DBStatus   string `json:"db_status,omitempty"`
+	Error      string `json:"error,omitempty"` // 検証に失敗した場合（保存しない）
+	WriteToken string `json:"write_token,omitempty"`
+}
+
+// batchResponse : POST /api/logs/batch の応答
+type batchResponse struct {
+	Message  string            `json:"message"`
+	Accepted int               `json:"accepted"` // 保存・バッファに入れた件数
+	Rejected int               `json:"rejected"` // 検証エラー・保存の失敗・除外の件数
+	Results  []batchItemResult `json:"results"`
+}
+
+// ingestBatchHandler : POST /api/logs/batch [{"level":"error","message":"..."}, ...]
+// 1件ずつの形は POST /api/logs と同じ。LOG_BATCH_MAX 件まで
+func (s *Server) ingestBatchHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		body, err := readBody(w, r, maxBatchBodyBytes)
+		if err != nil {
+			http.Error(w, i18n.T(w, r, i18n.InvalidRequest, err), http.StatusBadRequest)
+			return
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			http.Error(w, i18n.T(w, r, i18n.InvalidJSON, err), http.StatusBadRequest)
+			return
+		}
+		if len(items) > s.cfg.LogBatchMax {
+			http.Error(w, i18n.T(w, r, i18n.BatchTooLarge, len(items), s.cfg.LogBatchMax), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		// 1. 検証と保存の前処理（保存しないものはここで結果が決まる）
+		now := s.clock.Now()
+		results := make([]batchItemResult, len(items))
+		writes := make([]model.Write, len(items))
+		var pending []int // DBに保存する位置
+		for i, item := range items {
+			results[i].Index = i
+			if len(item) > maxLogBodyBytes {
+				results[i].Error = i18n.T(w, r, i18n.BodyTooLarge, maxLogBodyBytes)
+				continue
+			}
+			lb, err := parseLogBody(item, now)
+			if err != nil {
+				results[i].Error = i18n.T(w, r, i18n.InvalidJSON, err)
+				continue
+			}
+			writes[i] = s.logWrite(r, projectID, lb, now)
+			status, stored, ok := s.prepareWrite(r.Context(), &writes[i])
+			if !ok {
+				results[i].DBStatus = status
+				if stored {
+					s.notifyLog(r.Context(), &writes[i])
+				}
+				continue
+			}
+			pending = append(pending, i)
+		}
+
+		// 2. 残りを1つのトランザクションで保存する
+		batch := make([]*model.Write, len(pending))
+		for j, i := range pending {
+			batch[j] = &writes[i]
+		}
+		result, n, saveErr := s.store.SaveLogs(r.Context(), batch)
+		for j, i := range pending {
+			var status string
+			var stored bool
+			if j < n {
+				status, stored = s.savedWrite(&writes[i], result, nil)
+			} else {
+				status, stored = s.savedWrite(&writes[i], result, saveErr)
+			}
+			results[i].DBStatus = status
+			if stored {
+				s.notifyLog(r.Context(), &writes[i])
+			}
+		}
+
+		resp := batchResponse{Message: i18n.T(w, r, i18n.Logged), Results: results}
+		for i := range results {
+			if !acceptedStatus(results[i].DBStatus) {
+				resp.Rejected++
+				continue
+			}
+			resp.Accepted++
+			// ヘッダーには最後に受け付けた1件のトークンが残る（採番順なので、それを待てば全て読み出せる）
+			results[i].WriteToken = s.acceptedWriteToken(w, &writes[i], results[i].DBStatus)
+		}
+		// 1件も受け付けられなかったのが保存の失敗なら、1件の時と同じく 503 (Retry-After) か 500
+		// 一部でもバッファ (WAL_DIR) に退避したら 202。バッファが途中で一杯になった残りは results で分かり、Retry-After の後に送り直せる
+		if saveErr != nil && resp.Accepted == 0 {
+			s.dbError(w, r, saveErr)
+			return
+		}
+		code := http.StatusOK
+		if len(pending) > 0 && result == store.Buffered {
+			code = http.StatusAccepted
+		}
+		if saveErr != nil && s.storageUnavailable(saveErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(storageRetryAfter.Seconds())))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// readBody : 上限付きで本文を読み込む
+func readBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(http.MaxBytesReader(w, r.Body, limit)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go-logger/internal/i18n"
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// IPアドレスの許可・拒否リスト (書き込みの前に当て、拒否したら403)
+// ==========================================
+
+// compiledIPRule : 解析済みのルール
+type compiledIPRule struct {
+	model.IPRule
+	prefix netip.Prefix
+}
+
+// ipRuleState : 読み込んだルール
+type ipRuleState struct {
+	mu       sync.RWMutex
+	list     []compiledIPRule
+	hasAllow bool // allow が1件でもあれば、どれにも入らないアドレスは拒否する
+}
+
+// parseIPRange : "203.0.113.0/24" か単一のアドレスを範囲にする
+func parseIPRange(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
+
+// compileIPRule : ルールを検証して解析する（CIDR は正規化した形で書き戻す）
+func compileIPRule(r model.IPRule) (compiledIPRule, error) {
+	c := compiledIPRule{IPRule: r}
+	if r.Action != "allow" && r.Action != "deny" {
+		return c, fmt.Errorf("unknown action %q (use allow or deny)", r.Action)
+	}
+	prefix, err := parseIPRange(r.CIDR)
+	if err != nil {
+		return c, fmt.Errorf("invalid cidr %q: %v", r.CIDR, err)
+	}
+	c.prefix = prefix
+	c.CIDR = prefix.String()
+	return c, nil
+}
+
+// ipAllowed : このアドレスからの書き込みを受け付けるか
+// 一致したルールのうち最も狭い範囲のものに従う（"10.0.0.0/8 を拒否、10.1.2.3 だけ許可" のように書ける）
+// どれにも一致しなければ、allow が1件もない時だけ受け付ける
+func (st *ipRuleState) ipAllowed(ip string) bool {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	if len(st.list) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return !st.hasAllow
+	}
+	addr = addr.Unmap()
+
+	best := -1
+	allowed := !st.hasAllow
+	for _, r := range st.list {
+		if r.prefix.Contains(addr) && r.prefix.Bits() > best {
+			best = r.prefix.Bits()
+			allowed = r.Action == "allow"
+		}
+	}
+	return allowed
+}
+
+// ipFilter : 拒否されたクライアントには403を返す
+func (s *Server) ipFilter(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.ipRules.ipAllowed(clientIP(r)) {
+			http.Error(w, i18n.T(w, r, i18n.Forbidden), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// reloadIPRules : ルールをDBから読み直す（不正なルールは飛ばす）
+func (s *Server) reloadIPRules(ctx context.Context) error {
+	rules, err := s.store.ListIPRules(ctx)
+	if err != nil {
+		return err
+	}
+	var list []compiledIPRule
+	hasAllow := false
+	for _, r := range rules {
+		c, err := compileIPRule(r)
+		if err != nil {
+			fmt.Printf("Skipping IP rule %d: %v\n", r.ID, err)
+			continue
+		}
+		list = append(list, c)
+		hasAllow = hasAllow || c.Action == "allow"
+	}
+	s.ipRules.mu.Lock()
+	s.ipRules.list, s.ipRules.hasAllow = list, hasAllow
+	s.ipRules.mu.Unlock()
+	return nil
+}
+
+// listIPRulesHandler : GET /api/ip-rules
+func (s *Server) listIPRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.store.ListIPRules(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// createIPRuleHandler : POST /api/ip-rules {"cidr": "198.51.100.0/24", "action": "deny", "note": "scanner"}
+func (s *Server) createIPRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var rule model.IPRule
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&rule); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if rule.Action == "" {
+		rule.Action = "deny"
+	}
+	c, err := compileIPRule(rule)
+	if err != nil {
+		http.Error(w, "Invalid IP rule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule.CIDR = c.CIDR
+
+	if err := s.store.CreateIPRule(r.Context(), &rule); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadIPRulesAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// deleteIPRuleHandler : DELETE /api/ip-rules/{id}
+func (s *Server) deleteIPRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid IP rule id", http.StatusBadRequest)
+		return
+	}
+	err = s.store.DeleteIPRule(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "IP rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadIPRulesAfterChange(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reloadIPRulesAfterChange : 変更をすぐ反映する（失敗しても次の定期読み込みで反映される）
+func (s *Server) reloadIPRulesAfterChange(ctx context.Context) {
+	if err := s.reloadIPRules(ctx); err != nil {
+		fmt.Println("Failed to reload IP rules:", err)
+	}
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 定期処理と送信待ちのキューの管理 (/api/admin/jobs, 画面は jobs.html)
+// ==========================================
+
+// notificationQueueName : 通知の送信待ちのキューの名前（dead letter の queue 列にも入る）
+const notificationQueueName = "notifications"
+
+// jobStatus : 定期処理1つ分の状態
+type jobStatus struct {
+	Name           string     `json:"name"`
+	Interval       string     `json:"interval"`
+	Paused         bool       `json:"paused"`
+	Runs           int        `json:"runs"`
+	Failures       int        `json:"failures"`
+	LastRun        *time.Time `json:"last_run,omitempty"`
+	LastDurationMS int64      `json:"last_duration_ms"`
+	LastError      string     `json:"last_error,omitempty"`
+	LeaderOnly     bool       `json:"leader_only,omitempty"` // リーダーのインスタンスだけが動かす
+	Standby        bool       `json:"standby,omitempty"`     // リーダーではないので、いまは動かしていない
+}
+
+// job : 定期処理1つ分（watchXxx が起動時に登録する）
+type job struct {
+	mu     sync.Mutex
+	status jobStatus
+}
+
+// jobRegistry : 登録した順に並べた定期処理
+type jobRegistry struct {
+	mu    sync.Mutex
+	jobs  map[string]*job
+	order []string
+}
+
+// register : 定期処理を登録する（同じ名前なら既存のものを返す）
+func (r *jobRegistry) register(name string, interval time.Duration) *job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if j, ok := r.jobs[name]; ok {
+		return j
+	}
+	j := &job{status: jobStatus{Name: name, Interval: interval.String()}}
+	r.jobs[name] = j
+	r.order = append(r.order, name)
+	return j
+}
+
+// leaderOnly : リーダーのインスタンスだけが動かす定期処理にする
+func (j *job) leaderOnly() *job {
+	j.mu.Lock()
+	j.status.LeaderOnly = true
+	j.mu.Unlock()
+	return j
+}
+
+// get : 名前で探す
+func (r *jobRegistry) get(name string) (*job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j, ok := r.jobs[name]
+	return j, ok
+}
+
+// snapshot : 全ての定期処理の状態
+func (r *jobRegistry) snapshot() []jobStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]jobStatus, 0, len(r.order))
+	for _, name := range r.order {
+		j := r.jobs[name]
+		j.mu.Lock()
+		list = append(list, j.status)
+		j.mu.Unlock()
+	}
+	return list
+}
+
+// setPaused : 一時停止・再開する
+func (j *job) setPaused(paused bool) {
+	j.mu.Lock()
+	j.status.Paused = paused
+	j.mu.Unlock()
+}
+
+// runJob : 一時停止中でなければ fn を実行し、結果を記録する
+// リーダーだけが動かす定期処理は、リーダーでなければ飛ばす
+func (s *Server) runJob(j *job, fn func() error) {
+	j.mu.Lock()
+	paused := j.status.Paused
+	j.status.Standby = j.status.LeaderOnly && !s.isLeader()
+	standby := j.status.Standby
+	j.mu.Unlock()
+	if paused || standby {
+		return
+	}
+
+	started := s.clock.Now()
+	err := fn()
+	elapsed := s.clock.Now().Sub(started)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status.Runs++
+	j.status.LastRun = &started
+	j.status.LastDurationMS = elapsed.Milliseconds()
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+	}
+}
+
+// queueStatus : 送信待ち・書き戻し待ちのキュー1つ分の状態
+type queueStatus struct {
+	Name        string `json:"name"`
+	Pending     int    `json:"pending"`
+	Retrying    int64  `json:"retrying,omitempty"`
+	Paused      bool   `json:"paused"`
+	Workers     int    `json:"workers,omitempty"`
+	MaxAttempts int    `json:"max_attempts,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// jobsResponse : GET /api/admin/jobs の結果
+type jobsResponse struct {
+	Jobs        []jobStatus   `json:"jobs"`
+	Leader      bool          `json:"leader"` // このインスタンスが保守の定期処理を動かしているか
+	Queues      []queueStatus `json:"queues"`
+	DeadLetters int           `json:"dead_letters"`
+}
+
+// jobsHandler : GET /api/admin/jobs で定期処理・キュー・dead letter の件数を返す
+func (s *Server) jobsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	notifications := queueStatus{
+		Name:        notificationQueueName,
+		Retrying:    s.notifyRetrying.Load(),
+		Paused:      s.notifyPaused.Load(),
+		Workers:     max(s.cfg.NotifyWorkers, 1),
+		MaxAttempts: max(s.cfg.NotifyMaxAttempts, 1),
+	}
+	if n, err := s.queue.Len(ctx); err != nil {
+		notifications.Error = err.Error()
+	} else {
+		notifications.Pending = n
+	}
+	// DB の再接続を待っている書き込み（復旧すると自動で書き戻す）
+	writes := queueStatus{Name: "writes"}
+	if n, err := s.store.BufferedWrites(ctx); err != nil {
+		writes.Error = err.Error()
+	} else {
+		writes.Pending = n
+	}
+
+	letters, err := s.store.ListDeadLetters(ctx)
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobsResponse{
+		Jobs:        s.jobs.snapshot(),
+		Leader:      s.isLeader(),
+		Queues:      []queueStatus{notifications, writes},
+		DeadLetters: len(letters),
+	})
+}
+
+// jobActionHandler : POST /api/admin/jobs/{name}/{action} で定期処理を一時停止・再開する (pause / resume)
+func (s *Server) jobActionHandler(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.jobs.get(r.PathValue("name"))
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	paused, ok := pauseAction(r.PathValue("action"))
+	if !ok {
+		http.Error(w, `Invalid action: use "pause" or "resume"`, http.StatusBadRequest)
+		return
+	}
+	j.setPaused(paused)
+	s.jobsHandler(w, r)
+}
+
+// queueActionHandler : POST /api/admin/queues/notifications/{action} で通知の送信を一時停止・再開する
+// 停止中も通知はキューに積まれ、再開すると順に送られる
+func (s *Server) queueActionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("name") != notificationQueueName {
+		http.Error(w, "Queue not found", http.StatusNotFound)
+		return
+	}
+	paused, ok := pauseAction(r.PathValue("action"))
+	if !ok {
+		http.Error(w, `Invalid action: use "pause" or "resume"`, http.StatusBadRequest)
+		return
+	}
+	s.notifyPaused.Store(paused)
+	s.jobsHandler(w, r)
+}
+
+// pauseAction : "pause" / "resume" を一時停止するかどうかに変換する
+func pauseAction(action string) (paused, ok bool) {
+	switch action {
+	case "pause":
+		return true, true
+	case "resume":
+		return false, true
+	}
+	return false, false
+}
+
+// listDeadLettersHandler : GET /api/admin/dead-letters
+func (s *Server) listDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	letters, err := s.store.ListDeadLetters(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(letters)
+}
+
+// retryDeadLetterHandler : POST /api/admin/dead-letters/{id}/retry で送信待ちのキューに戻す
+// 失敗した通知先だけに送り直し、回数は数え直す
+func (s *Server) retryDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid dead letter id", http.StatusBadRequest)
+		return
+	}
+	d, err := s.store.DeadLetterByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	var q queuedNotification
+	if err := json.Unmarshal(d.Payload, &q); err != nil {
+		http.Error(w, "Invalid dead letter payload: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	q.Attempts, q.LastError = 0, ""
+	if err := s.enqueueNotification(context.WithoutCancel(r.Context()), q); err != nil {
+		http.Error(w, "Failed to queue notification: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err := s.store.DeleteDeadLetter(r.Context(), id); err != nil && !errors.Is(err, store.ErrNotFound) {
+		s.dbError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// deleteDeadLetterHandler : DELETE /api/admin/dead-letters/{id} で破棄する
+func (s *Server) deleteDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid dead letter id", http.StatusBadRequest)
+		return
+	}
+	err = s.store.DeleteDeadLetter(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
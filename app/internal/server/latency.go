@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 記録したリクエストの応答ステータスと処理時間
+// ==========================================
+// 監視しているエンドポイントの側（クライアントのミドルウェアなど）が、
+// POST /api/logs の "status" / "duration_ms"、アクセスの記録なら ?status=&duration_ms= で報告する。
+// GET /api/stats の latency で p50 / p95 と 5xx の割合を返す
+
+// checkStatus : 報告されたステータスが HTTP のステータスの範囲か
+func checkStatus(status int) error {
+	if status < 100 || status > 599 {
+		return fmt.Errorf(`"status" must be an HTTP status code (100-599)`)
+	}
+	return nil
+}
+
+// checkDuration : 報告された処理時間が0以上の有限の値か
+func checkDuration(ms float64) error {
+	if ms < 0 || math.IsNaN(ms) || math.IsInf(ms, 0) {
+		return fmt.Errorf(`"duration_ms" must be a non-negative number`)
+	}
+	return nil
+}
+
+// parseStatusJSON : 本文の "status"
+func parseStatusJSON(v json.RawMessage) (int, error) {
+	var status int
+	if err := json.Unmarshal(v, &status); err != nil {
+		return 0, fmt.Errorf(`"status" must be an integer`)
+	}
+	return status, checkStatus(status)
+}
+
+// parseDurationJSON : 本文の "duration_ms"
+func parseDurationJSON(v json.RawMessage) (*float64, error) {
+	var ms float64
+	if err := json.Unmarshal(v, &ms); err != nil {
+		return nil, fmt.Errorf(`"duration_ms" must be a number`)
+	}
+	return &ms, checkDuration(ms)
+}
+
+// reportedTiming : アクセスの記録の ?status=&duration_ms=（なければそのまま）
+func reportedTiming(r *http.Request, lw *model.Write) error {
+	q := r.URL.Query()
+	if v := q.Get("status"); v != "" {
+		status, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf(`"status" must be an integer`)
+		}
+		if err := checkStatus(status); err != nil {
+			return err
+		}
+		lw.Status = status
+	}
+	if v := q.Get("duration_ms"); v != "" {
+		ms, err := strconv.ParseFloat(v, 64)
+		if err == nil {
+			err = checkDuration(ms)
+		}
+		if err != nil {
+			return fmt.Errorf(`"duration_ms" must be a non-negative number`)
+		}
+		lw.DurationMS = &ms
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// 定期処理のリーダー
+// ==========================================
+// 保存期間の削除・集計・まとめなどの保守の定期処理は、複数のインスタンスのうちリーダーだけが動かす
+// リーダーが落ちたら、ほかのインスタンスが LEADER_CHECK_INTERVAL のうちに引き継ぐ
+// 設定の読み直しやルールの読み込みなど、インスタンスごとに持つ状態の定期処理は全てのインスタンスで動かす
+
+// Elector : リーダーを決める仕組み（store.LeaderLock。nil なら常にリーダー）
+type Elector interface {
+	// Hold : リーダーであり続けるか、リーダーになろうとする。リーダーなら true
+	Hold(ctx context.Context) (bool, error)
+	// Release : リーダーを降りる
+	Release(ctx context.Context) error
+}
+
+// leaderCheckIntervalFromEnv : LEADER_CHECK_INTERVAL（既定10秒）
+func leaderCheckIntervalFromEnv() time.Duration {
+	return config.Duration("LEADER_CHECK_INTERVAL", 10*time.Second)
+}
+
+// isLeader : 保守の定期処理を動かすインスタンスか
+func (s *Server) isLeader() bool {
+	return s.elector == nil || s.leader.Load()
+}
+
+// checkLeader : リーダーかどうかを確かめ直す（変わったら知らせる）
+func (s *Server) checkLeader(ctx context.Context) {
+	ok, err := s.elector.Hold(ctx)
+	if err != nil {
+		fmt.Println("Leader election check failed:", err)
+	}
+	if s.leader.Swap(ok) != ok {
+		if ok {
+			fmt.Println("Became the leader: running scheduled maintenance jobs on this instance")
+		} else {
+			fmt.Println("Not the leader: scheduled maintenance jobs run on another instance")
+		}
+	}
+}
+
+// watchLeader : リーダーを確かめ続け、止まる時に降りる
+func (s *Server) watchLeader(ctx context.Context) {
+	if s.elector == nil {
+		return
+	}
+	ticker := s.clock.NewTicker(s.cfg.LeaderCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			if err := s.elector.Release(releaseCtx); err != nil {
+				fmt.Println("Failed to release leadership:", err)
+			}
+			cancel()
+			return
+		case <-ticker.C():
+		}
+		s.checkLeader(ctx)
+	}
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 短縮リンク (クリックを記録してからリダイレクト)
+// ==========================================
+
+var slugPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// shortLinkHandler : GET /l/{slug} でクリックを記録し、リンク先へリダイレクトする
+func (s *Server) shortLinkHandler(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	link, err := s.store.LinkBySlug(r.Context(), slug)
+	if errors.Is(err, store.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	// 記録に失敗してもリダイレクトは止めない
+	lw := model.Write{
+		ProjectID: link.ProjectID,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+		Path:      "/l/" + slug,
+		Referrer:  r.Referer(),
+		EventType: store.LinkClickEventType,
+		CreatedAt: s.clock.Now(),
+		Headers:   s.capturedHeaders(r),
+	}
+	s.saveWrite(r.Context(), &lw)
+
+	http.Redirect(w, r, link.TargetURL, http.StatusFound)
+}
+
+// listLinksHandler : GET /api/links でリンク一覧とクリック数を返す
+func (s *Server) listLinksHandler(w http.ResponseWriter, r *http.Request) {
+	links, err := s.store.ListLinks(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+// createLinkHandler : POST /api/links {"slug": "...", "target_url": "...", "project_id": 1}
+func (s *Server) createLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var l model.ShortLink
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&l); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !slugPattern.MatchString(l.Slug) {
+		http.Error(w, `Invalid "slug": use 1-64 letters, digits, "-" or "_"`, http.StatusBadRequest)
+		return
+	}
+	if u, err := url.Parse(l.TargetURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, `Invalid "target_url": must be an absolute http(s) URL`, http.StatusBadRequest)
+		return
+	}
+	if l.ProjectID == 0 {
+		l.ProjectID = model.DefaultProjectID
+	}
+
+	if err := s.store.CreateLink(r.Context(), &l); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(l)
+}
+
+// deleteLinkHandler : DELETE /api/links/{slug}
+func (s *Server) deleteLinkHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.store.DeleteLink(r.Context(), r.PathValue("slug"))
+	if errors.Is(err, store.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ==========================================
+// 短縮リンクのQRコード生成 (名刺・ポスターなどのオフライン用)
+// ==========================================
+
+// publicBaseURL : 外部から見たこのサービスのURL（例: https://dev.aliceindex.jp/go）
+// PUBLIC_BASE_URL が未設定ならリクエストのホストと BASE_PATH から組み立てる
+func (s *Server) publicBaseURL(r *http.Request) string {
+	if base := s.cfg.PublicBaseURL; base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + s.cfg.BasePath
+}
+
+// linkQRHandler : GET /api/links/{slug}/qr?format=png|svg&size=256
+func (s *Server) linkQRHandler(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	_, err := s.store.LinkBySlug(r.Context(), slug)
+	if errors.Is(err, store.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	size := 256
+	if v, err := strconv.Atoi(r.URL.Query().Get("size")); err == nil && v >= 64 && v <= 2048 {
+		size = v
+	}
+	qr, err := qrcode.New(s.publicBaseURL(r)+"/l/"+slug, qrcode.Medium)
+	if err != nil {
+		http.Error(w, "Failed to generate QR code: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "png":
+		png, err := qr.PNG(size)
+		if err != nil {
+			http.Error(w, "Failed to encode PNG: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	case "svg":
+		w.Header().Set("Content-Type", "image/svg+xml")
+		fmt.Fprint(w, qrSVG(qr.Bitmap(), size))
+	default:
+		http.Error(w, `Invalid "format": use png or svg`, http.StatusBadRequest)
+	}
+}
+
+// qrSVG : QRのビットマップ（余白込み）をSVGに変換する
+func qrSVG(bitmap [][]bool, size int) string {
+	n := len(bitmap)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return b.String()
+}
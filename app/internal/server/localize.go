@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-logger/internal/i18n"
+)
+
+// ==========================================
+// ダッシュボードの文言と時刻の表記 (GET /api/i18n)
+// ==========================================
+
+// maxLocalizedTimes : 1回に書き直せる ?time= の数
+const maxLocalizedTimes = 200
+
+// localizedResponse : GET /api/i18n の結果
+type localizedResponse struct {
+	Lang     string               `json:"lang"`
+	Timezone string               `json:"timezone"`
+	Labels   map[string]string    `json:"labels"`
+	Levels   map[string]string    `json:"levels"`
+	Now      i18n.FormattedTime   `json:"now"`
+	Times    []i18n.FormattedTime `json:"times"` // ?time= の順
+}
+
+// i18nHandler : GET /api/i18n?lang=ja&tz=Asia/Tokyo&time=2024-01-01T00:00:00Z&time=...
+// 言語は ?lang=（なければ Accept-Language）、タイムゾーンは ?tz=（なければ TIMEZONE）
+func (s *Server) i18nHandler(w http.ResponseWriter, r *http.Request) {
+	loc, ok := s.responseLocation(w, r)
+	if !ok {
+		return
+	}
+	raw := r.URL.Query()["time"]
+	if len(raw) > maxLocalizedTimes {
+		http.Error(w, fmt.Sprintf("Too many \"time\" values (max %d)", maxLocalizedTimes), http.StatusBadRequest)
+		return
+	}
+	lang := i18n.Lang(r)
+	now := s.clock.Now()
+	resp := localizedResponse{
+		Lang:     lang.String(),
+		Timezone: loc.String(),
+		Labels:   i18n.Labels(lang),
+		Levels:   i18n.LevelNames(lang),
+		Now:      i18n.FormatTime(lang, now, loc, now),
+		Times:    make([]i18n.FormattedTime, 0, len(raw)),
+	}
+	for _, v := range raw {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid time %q (use RFC3339)", v), http.StatusBadRequest)
+			return
+		}
+		resp.Times = append(resp.Times, i18n.FormatTime(lang, t, loc, now))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", resp.Lang)
+	w.Header().Add("Vary", "Accept-Language")
+	json.NewEncoder(w).Encode(resp)
+}
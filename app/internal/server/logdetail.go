@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-logger/internal/model"
+	"go-logger/internal/redact"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// ログの詳細 (GET /api/logs/{id})
+// ==========================================
+// 通知のリンク (PUBLIC_BASE_URL/#log-123) から開いた時に、一覧に載っていない古いログでも表示できるようにする
+// ログそのものに加え、記録したヘッダー・国・同じセッションの前後のログを返す（ゴミ箱のログも返す）
+
+// sessionContextLimit : 同じセッションの前後をそれぞれ何件まで返すか
+const sessionContextLimit = 50
+
+// logDetail : GET /api/logs/{id} の応答
+type logDetail struct {
+	Entry     model.LogEntry    `json:"entry"`
+	Permalink string            `json:"permalink,omitempty"` // PUBLIC_BASE_URL が未設定なら空
+	Headers   map[string]string `json:"headers"`             // 記録したリクエストヘッダー
+	Geo       *logGeo           `json:"geo,omitempty"`       // 国が分からなければ省く
+	Session   *logSession       `json:"session,omitempty"`   // 訪問者を見分けられなければ省く（ボット、IPもIDもない）
+}
+
+// logGeo : 国コードと国名・代表点
+type logGeo struct {
+	Country string   `json:"country"`
+	Name    string   `json:"name,omitempty"`
+	Lat     *float64 `json:"lat,omitempty"`
+	Lon     *float64 `json:"lon,omitempty"`
+}
+
+// logSession : このログを含むセッション（SESSION_GAP より空かずに続いた同じ訪問者のアクセス）
+type logSession struct {
+	Key       string           `json:"key"` // visitor_id（なければ ip + ua で見分ける）
+	Entries   []model.LogEntry `json:"entries"`
+	Truncated bool             `json:"truncated"` // 前後が sessionContextLimit 件を超えて続いている
+}
+
+// logDetailHandler : GET /api/logs/{id}
+// プロジェクトキーを付けた場合はそのプロジェクトのログだけ、ログインしていなければ既定のプロジェクトのログだけ返す
+func (s *Server) logDetailHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		id, ok := logIDFromPath(w, r)
+		if !ok {
+			return
+		}
+		loc, ok := s.responseLocation(w, r)
+		if !ok {
+			return
+		}
+		scope := s.requestScope(r)
+		e, err := s.store.LogByID(r.Context(), id)
+		if err == nil && e.ProjectID != projectID && (projectKey(r) != "" || scope < redact.User) {
+			err = store.ErrNotFound
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Log not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+
+		// ヘッダーは REDACT_RULES で隠した後のログから作る（user_agent・headers を隠した時に漏らさない）
+		redacted := s.redact.Entry(e, scope)
+		detail := logDetail{Entry: redacted, Permalink: s.entryURL(&e), Headers: entryHeaders(redacted)}
+		if redacted.Country != "" && redacted.Country != redact.Placeholder {
+			code := strings.ToUpper(redacted.Country)
+			detail.Geo = &logGeo{Country: code}
+			if c, ok := countryCentroids[code]; ok {
+				detail.Geo.Name, detail.Geo.Lat, detail.Geo.Lon = c.Name, &c.Lat, &c.Lon
+			}
+		}
+		if detail.Session, err = s.entrySession(r.Context(), e); err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+
+		if detail.Session != nil {
+			detail.Session.Entries = s.redact.Entries(detail.Session.Entries, scope)
+			if s.redact.Hidden("visitor_id", scope) || s.redact.Hidden("ip", scope) || s.redact.Hidden("user_agent", scope) {
+				detail.Session.Key = redact.Placeholder
+			}
+		}
+		inLocation(&detail, loc)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(detail)
+	})
+}
+
+// entryHeaders : ログに記録したリクエストヘッダー（CAPTURE_HEADERS の分と、列として持っている User-Agent・Referer）
+func entryHeaders(e model.LogEntry) map[string]string {
+	headers := maps.Clone(e.Headers)
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	if e.UserAgent != "" {
+		headers["User-Agent"] = e.UserAgent
+	}
+	if e.Referrer != "" {
+		headers["Referer"] = e.Referrer
+	}
+	return headers
+}
+
+// entrySession : e を含むセッションのログを古い順に返す（SessionStats と同じく visitor_id、なければ ip + ua で見分ける）
+// 前後をそれぞれ sessionContextLimit 件まで取り、SESSION_GAP より空いたところで切る
+func (s *Server) entrySession(ctx context.Context, e model.LogEntry) (*logSession, error) {
+	if e.IsBot || (e.VisitorID == "" && e.IP == "") {
+		return nil, nil
+	}
+	session := &logSession{Key: "v:" + e.VisitorID}
+	expr := "visitor:" + strconv.Quote(e.VisitorID)
+	if e.VisitorID == "" {
+		session.Key = e.IP + " " + e.UserAgent
+		expr = "ip:" + strconv.Quote(e.IP) + " AND ua:" + strconv.Quote(e.UserAgent)
+	}
+	q, err := store.ParseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	f := store.LogFilter{ProjectID: e.ProjectID, Query: q, Sort: store.SortCreatedAt, Limit: sessionContextLimit + 1}
+	before := f
+	before.CursorAt, before.BeforeID = e.CreatedAt, e.ID
+	older, err := s.store.QueryLogs(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+	after := f
+	after.CursorAt, after.AfterID, after.Ascending = e.CreatedAt, e.ID, true
+	newer, err := s.store.QueryLogs(ctx, after)
+	if err != nil {
+		return nil, err
+	}
+
+	// e から前後へたどり、SESSION_GAP より空いたら別のセッション
+	session.Entries = []model.LogEntry{e}
+	prev := e.CreatedAt
+	for i, l := range older {
+		if prev.Sub(l.CreatedAt) > s.cfg.SessionGap {
+			break
+		}
+		if i == sessionContextLimit {
+			session.Truncated = true
+			break
+		}
+		session.Entries = append(session.Entries, l)
+		prev = l.CreatedAt
+	}
+	slices.Reverse(session.Entries)
+	prev = e.CreatedAt
+	for i, l := range newer {
+		if l.CreatedAt.Sub(prev) > s.cfg.SessionGap {
+			break
+		}
+		if i == sessionContextLimit {
+			session.Truncated = true
+			break
+		}
+		session.Entries = append(session.Entries, l)
+		prev = l.CreatedAt
+	}
+	return session, nil
+}
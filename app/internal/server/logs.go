@@ -0,0 +1,381 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// ログ読み出し・全文検索・集計
+// ==========================================
+
+// errInvalidQuery : クエリパラメータが不正（400を返す）
+var errInvalidQuery = errors.New("invalid query")
+
+// readHandler : 保存されたログをDBから取得して返す
+// キーで指定したプロジェクト（キーなしならデフォルト）のログだけを返す
+func (s *Server) readHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		s.readLogs(w, r, projectID)
+	})
+}
+
+// readLogs : readHandler の本体
+// 一覧が変わっていなければ If-None-Match に 304 を返す（logsETag）
+// ?federate=true なら PEERS に設定した他のインスタンスの結果もまとめて返す
+func (s *Server) readLogs(w http.ResponseWriter, r *http.Request, projectID int) {
+	loc, ok := s.responseLocation(w, r)
+	if !ok {
+		return
+	}
+	format, ok := negotiateFormat(w, r)
+	if !ok {
+		return
+	}
+	f, err := logFilterFromQuery(r.URL.Query(), projectID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if token := s.readAfterToken(r); token != "" {
+		s.awaitWrite(r.Context(), projectID, token)
+	}
+	logs, err := s.queryRecentLogs(r.Context(), f)
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	if s.federated(r) {
+		logs = s.federateLogs(w, r, f, logs)
+	} else if len(logs) > 0 && len(logs) == limitOrDefault(f.Limit) {
+		setNextCursor(w, r, f, logs[len(logs)-1])
+	}
+	scope := s.requestScope(r)
+	if notModified(w, r, logsETag(logs, scope, loc, format)) {
+		return
+	}
+	logs = s.redact.Entries(logs, scope)
+	inLocation(logs, loc)
+
+	// JSON（Accept か ?format= によっては XML・テキスト）として返す
+	writeFormatted(w, format, "logs", logs)
+}
+
+// maxLogsLimit : ?limit= の上限（超えた分はこの件数に切り詰める）
+const maxLogsLimit = 1000
+
+// logFilterFromQuery : クエリパラメータを絞り込み条件にする（既定は最新50件）
+// ?limit= で件数（最大 maxLogsLimit）、?sort=id|created_at で並べる列、?order=asc|desc で向きを変えられる
+// ?type=ping のようにイベント種別で、?level=warn で warn 以上に絞り込める
+// ?field.order_id=123 のように fields の値でも絞り込める（INDEXED_FIELDS なら索引を使う）
+// ?tag=pentest でタグの付いたログに絞り込める（複数指定すると全て付いているもの）
+// ?cursor= には前のページの X-Next-Cursor を渡す（最後に返した位置の続きから返すので、ログが増えても飛ばしや重複がない）
+// ?query=ua:~curl AND country:JP AND created_at>2024-01-01 のような検索式でも絞り込める（store.ParseQuery）
+func logFilterFromQuery(query url.Values, projectID int) (store.LogFilter, error) {
+	f := store.LogFilter{
+		ProjectID: projectID,
+		EventType: query.Get("type"),
+		UID:       query.Get("uid"),
+		Limit:     50,
+	}
+	if min := query.Get("level"); min != "" {
+		normalized, err := model.NormalizeLevel(min)
+		if err != nil {
+			return f, fmt.Errorf("%w: level: %v", errInvalidQuery, err)
+		}
+		f.MinLevel = normalized
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return f, fmt.Errorf("%w: limit: use a positive number (at most %d)", errInvalidQuery, maxLogsLimit)
+		}
+		f.Limit = min(n, maxLogsLimit)
+	}
+	switch sort := query.Get("sort"); sort {
+	case "", store.SortID:
+	case store.SortCreatedAt:
+		f.Sort = sort
+	default:
+		return f, fmt.Errorf("%w: sort: use id or created_at", errInvalidQuery)
+	}
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		f.Ascending = true
+	default:
+		return f, fmt.Errorf("%w: order: use asc or desc", errInvalidQuery)
+	}
+	if token := query.Get("cursor"); token != "" {
+		id, at, err := decodeCursor(token)
+		if err != nil {
+			return f, fmt.Errorf("%w: cursor: %v", errInvalidQuery, err)
+		}
+		if f.Sort == store.SortCreatedAt {
+			if at.IsZero() {
+				return f, fmt.Errorf("%w: cursor: does not match sort=created_at", errInvalidQuery)
+			}
+			f.CursorAt = at
+		}
+		if f.Ascending {
+			f.AfterID = id
+		} else {
+			f.BeforeID = id
+		}
+	}
+	if text := strings.TrimSpace(query.Get("query")); text != "" {
+		q, err := store.ParseQuery(text)
+		if err != nil {
+			return f, fmt.Errorf("%w: %v", errInvalidQuery, err)
+		}
+		f.Query = q
+	}
+	for _, tag := range query["tag"] {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			f.Tags = append(f.Tags, tag)
+		}
+	}
+	for key, values := range query {
+		if name, ok := strings.CutPrefix(key, "field."); ok && name != "" {
+			if f.Fields == nil {
+				f.Fields = map[string]string{}
+			}
+			f.Fields[name] = values[0]
+		}
+	}
+	return f, nil
+}
+
+// cursorPrefix : カーソルの中身の版（形を変えたら上げる）
+const cursorPrefix = "v1:"
+
+// encodeCursor : 次のページの位置（最後に返したid、created_at で並べた場合はその時刻も）を不透明なトークンにする
+func encodeCursor(id int, at time.Time) string {
+	v := cursorPrefix + strconv.Itoa(id)
+	if !at.IsZero() {
+		v += "@" + strconv.FormatInt(at.UnixNano(), 10)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(v))
+}
+
+// decodeCursor : encodeCursor で作ったトークン → 続きを返す位置（時刻がなければゼロ）
+func decodeCursor(token string) (int, time.Time, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("malformed token")
+	}
+	v, ok := strings.CutPrefix(string(raw), cursorPrefix)
+	v, nanos, hasTime := strings.Cut(v, "@")
+	id, err := strconv.Atoi(v)
+	if !ok || err != nil || id <= 0 {
+		return 0, time.Time{}, fmt.Errorf("malformed token")
+	}
+	var at time.Time
+	if hasTime {
+		n, err := strconv.ParseInt(nanos, 10, 64)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("malformed token")
+		}
+		at = time.Unix(0, n).UTC()
+	}
+	return id, at, nil
+}
+
+// setNextCursor : 続きがありそうなら X-Next-Cursor と Link: rel="next" で次のページを知らせる
+func setNextCursor(w http.ResponseWriter, r *http.Request, f store.LogFilter, last model.LogEntry) {
+	var at time.Time
+	if f.Sort == store.SortCreatedAt {
+		at = last.CreatedAt
+	}
+	token := encodeCursor(last.ID, at)
+	query := r.URL.Query()
+	query.Set("cursor", token)
+	query.Del("after")
+	w.Header().Set("X-Next-Cursor", token)
+	w.Header().Set("Link", `<?`+query.Encode()+`>; rel="next"`)
+}
+
+// timeRangeFromQuery : ?since=&until= (RFC3339。?from=&to= でもよい) か ?period=7d（直近の期間）で集計する期間を決める
+// どちらもなければ直近の defaultPeriod。until がゼロなら now まで
+func timeRangeFromQuery(query url.Values, now time.Time, defaultPeriod time.Duration) (since, until time.Time, err error) {
+	for _, p := range []struct {
+		name, alias string
+		dst         *time.Time
+	}{{"since", "from", &since}, {"until", "to", &until}} {
+		name, v := p.name, query.Get(p.name)
+		if v == "" {
+			name, v = p.alias, query.Get(p.alias)
+		}
+		if v != "" {
+			if *p.dst, err = time.Parse(time.RFC3339, v); err != nil {
+				return since, until, fmt.Errorf("%w: %s: use RFC3339 (e.g. 2026-01-02T15:04:05Z)", errInvalidQuery, name)
+			}
+		}
+	}
+	if since.IsZero() {
+		period := defaultPeriod
+		if v := query.Get("period"); v != "" {
+			if period, err = parseDurationDays(v); err != nil || period <= 0 {
+				return since, until, fmt.Errorf("%w: period: use e.g. 24h or 7d", errInvalidQuery)
+			}
+		}
+		end := now
+		if !until.IsZero() {
+			end = until
+		}
+		since = end.Add(-period)
+	}
+	if !until.IsZero() && !until.After(since) {
+		return since, until, fmt.Errorf("%w: until must be after since", errInvalidQuery)
+	}
+	return since, until, nil
+}
+
+// searchHandler : GET /api/logs/search?q=
+func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) { s.searchLogs(w, r, projectID) })
+}
+
+// searchLogs : q を websearch 形式（"語句"、OR、-除外）で検索し、一致度の高い順に返す
+// ?type= で種別を絞り込み、?limit= で件数（既定50・最大200）を変えられる
+func (s *Server) searchLogs(w http.ResponseWriter, r *http.Request, projectID int) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		http.Error(w, `Missing "q"`, http.StatusBadRequest)
+		return
+	}
+	loc, ok := s.responseLocation(w, r)
+	if !ok {
+		return
+	}
+	format, ok := negotiateFormat(w, r)
+	if !ok {
+		return
+	}
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 200 {
+		limit = v
+	}
+
+	results, err := s.store.SearchLogs(r.Context(), store.LogFilter{
+		ProjectID: projectID,
+		EventType: r.URL.Query().Get("type"),
+		Search:    q,
+		Limit:     limit,
+	})
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.redactSearchResults(results, s.requestScope(r))
+	inLocation(results, loc)
+
+	writeFormatted(w, format, "results", results)
+}
+
+// statsPeriod : ?sessions= / ?latency= の集計期間（既定は24時間、off なら集計しない）
+func statsPeriod(w http.ResponseWriter, r *http.Request, name string) (time.Duration, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 24 * time.Hour, true
+	}
+	if v == "off" {
+		return 0, true
+	}
+	d, err := parseDurationDays(v)
+	if err != nil || d <= 0 {
+		http.Error(w, `Invalid "`+name+`" (e.g. 24h or 7d)`, http.StatusBadRequest)
+		return 0, false
+	}
+	return d, true
+}
+
+// statsHandler : GET /api/stats?type=&sessions=24h&latency=24h
+// sessions には直近どれだけの期間のセッションを数えるか（既定 24h、"7d" の形も可、off なら数えない）を渡す
+// latency も同じ書き方で、報告された応答ステータスと処理時間 (p50 / p95・5xx の割合) を集計する期間
+// uniques=30d ならその期間のユニーク訪問者の数も返す（既定は数えない。集計済みの期間は HyperLogLog で見積もる）
+func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		loc, ok := s.responseLocation(w, r)
+		if !ok {
+			return
+		}
+		format, ok := negotiateFormat(w, r)
+		if !ok {
+			return
+		}
+		now := s.clock.Now()
+		period, ok := statsPeriod(w, r, "sessions")
+		if !ok {
+			return
+		}
+		latencyPeriod, ok := statsPeriod(w, r, "latency")
+		if !ok {
+			return
+		}
+		var uniquesPeriod time.Duration
+		if r.URL.Query().Get("uniques") != "" {
+			if uniquesPeriod, ok = statsPeriod(w, r, "uniques"); !ok {
+				return
+			}
+		}
+
+		f := store.LogFilter{
+			ProjectID:   projectID,
+			EventType:   r.URL.Query().Get("type"),
+			Extrapolate: s.extrapolate(r),
+		}
+		stats, err := s.store.Stats(r.Context(), f, now.Add(-24*time.Hour))
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+		if period > 0 {
+			f.Since = now.Add(-period)
+			sessions, err := s.store.SessionStats(r.Context(), f, s.cfg.SessionGap)
+			if err != nil {
+				s.dbError(w, r, err)
+				return
+			}
+			sessions.Since = f.Since
+			stats.Sessions = sessions
+		}
+		if latencyPeriod > 0 {
+			f.Since = now.Add(-latencyPeriod)
+			latency, err := s.store.LatencyStats(r.Context(), f)
+			if err != nil {
+				s.dbError(w, r, err)
+				return
+			}
+			latency.Since = f.Since
+			stats.Latency = latency
+		}
+		if uniquesPeriod > 0 {
+			f.Since = now.Add(-uniquesPeriod)
+			uniques, err := s.store.UniqueVisitors(r.Context(), f)
+			if err != nil {
+				s.dbError(w, r, err)
+				return
+			}
+			uniques.Since = f.Since
+			if uniques.Method == model.UniquesHLL {
+				uniques.Note = fmt.Sprintf("Approximate (HyperLogLog): typically within ±%.1f%% of the exact count", uniques.StandardError*100)
+			}
+			stats.Uniques = uniques
+		}
+		if s.federated(r) {
+			s.federateStats(r, stats)
+		}
+		inLocation(stats, loc)
+		writeFormatted(w, format, "stats", stats)
+	})
+}
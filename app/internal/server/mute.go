@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// 通知の一時停止 (負荷試験・デプロイの間だけ黙らせる)
+// ==========================================
+// アラートのミュートと同じ alert_mutes に保存するので、他のインスタンスにも反映され、再起動しても続く
+
+const (
+	// muteAllKey : 全ての通知を止めるミュートのキー
+	muteAllKey = "notifications:*"
+	// muteNotifierPrefix : 通知先1つだけを止めるミュートのキーの接頭辞（notifications:discord など）
+	muteNotifierPrefix = "notifications:"
+
+	defaultMuteDuration = time.Hour
+	maxMuteDuration     = 30 * 24 * time.Hour
+)
+
+// muteKey : ?channel=（通知先の名前。省略すると全て）のミュートのキー
+func muteKey(channel string) string {
+	if channel == "" {
+		return muteAllKey
+	}
+	return muteNotifierPrefix + channel
+}
+
+// muteStatus : GET / POST /api/notifications/mute の応答
+type muteStatus struct {
+	Muted      bool                 `json:"muted"`                 // 全ての通知を止めているか
+	MutedUntil *time.Time           `json:"muted_until,omitempty"` // 全ての通知を止めている期限
+	Channels   map[string]time.Time `json:"channels"`              // 通知先ごとに止めている期限
+}
+
+// muteStatus : 今のミュートの状態
+func (s *Server) muteStatus(now time.Time) muteStatus {
+	status := muteStatus{Channels: map[string]time.Time{}}
+	s.mutes.mu.Lock()
+	defer s.mutes.mu.Unlock()
+	for key, until := range s.mutes.until {
+		if !now.Before(until) {
+			continue
+		}
+		if key == muteAllKey {
+			status.Muted, status.MutedUntil = true, &until
+		} else if name, ok := strings.CutPrefix(key, muteNotifierPrefix); ok {
+			status.Channels[name] = until
+		}
+	}
+	return status
+}
+
+// notificationsMuted : 全ての通知を止めているか
+func (s *Server) notificationsMuted() bool {
+	return s.mutes.muted(muteAllKey, s.clock.Now())
+}
+
+// unmutedTargets : targets（nil なら全ての通知先）から止めている通知先を除く。止めているものがなければそのまま
+func (s *Server) unmutedTargets(targets []string) []string {
+	now := s.clock.Now()
+	names := targets
+	if names == nil {
+		names = s.notifierNames()
+	}
+	var kept []string
+	for _, name := range names {
+		if !s.mutes.muted(muteKey(name), now) {
+			kept = append(kept, name)
+		}
+	}
+	if len(kept) == len(names) {
+		return targets
+	}
+	if kept == nil {
+		kept = []string{} // 全て止めている（nil だと全てに送ってしまう）
+	}
+	return kept
+}
+
+// muteNotificationsHandler : POST /api/notifications/mute?duration=2h&channel=discord
+// duration は 30m / 2h / 1d の形（既定1時間、最長30日）。channel を省略すると全ての通知を止める
+// 止めている間の通知は送らずに捨てる（アラートの履歴には残る）
+func (s *Server) muteNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	duration := defaultMuteDuration
+	if v := r.URL.Query().Get("duration"); v != "" {
+		d, err := parseDurationDays(v)
+		if err != nil || d <= 0 || d > maxMuteDuration {
+			http.Error(w, "Invalid duration (use e.g. 30m, 2h or 1d, up to 30d)", http.StatusBadRequest)
+			return
+		}
+		duration = d
+	}
+	channel := strings.TrimSpace(r.URL.Query().Get("channel"))
+	if channel != "" && !slices.Contains(s.notifierNames(), channel) {
+		http.Error(w, fmt.Sprintf("Unknown channel %q (use one of %s)", channel, strings.Join(s.notifierNames(), ", ")), http.StatusBadRequest)
+		return
+	}
+	until := s.clock.Now().Add(duration)
+	if err := s.muteAlertKey(r.Context(), muteKey(channel), until, "api"); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	fmt.Printf("Notifications muted until %s (channel: %q)\n", until.Format(time.RFC3339), channel)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.muteStatus(s.clock.Now()))
+}
+
+// unmuteNotificationsHandler : POST /api/notifications/unmute?channel=discord（DELETE /api/notifications/mute も同じ）
+// channel を省略すると、全体と通知先ごとのミュートを全て解除する
+func (s *Server) unmuteNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	keys := []string{muteKey(strings.TrimSpace(r.URL.Query().Get("channel")))}
+	if keys[0] == muteAllKey {
+		for name := range s.muteStatus(s.clock.Now()).Channels {
+			keys = append(keys, muteKey(name))
+		}
+	}
+	for _, key := range keys {
+		if err := s.unmuteAlertKey(r.Context(), key); err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+	}
+	fmt.Println("Notifications unmuted:", strings.Join(keys, ", "))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.muteStatus(s.clock.Now()))
+}
+
+// muteStatusHandler : GET /api/notifications/mute
+func (s *Server) muteStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.muteStatus(s.clock.Now()))
+}
@@ -0,0 +1,288 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ==========================================
+// 読み出しAPIの形式 (JSON / XML / テキスト)
+// ==========================================
+// Accept: application/xml・text/plain（?format=xml / text で上書き）で JSON 以外の形でも返す
+// 中身は JSON と同じで、項目の順番もそのまま
+//   - XML  : オブジェクトのキーを要素名にし、配列の要素は <item> にする（要素名にできないキーは <entry key="...">）
+//   - text : logfmt (key=value) の1行に1件。入れ子の項目は a.b、値の配列は "," でつなぐ
+
+// 返す形式
+const (
+	formatJSON = "json"
+	formatXML  = "xml"
+	formatText = "text"
+)
+
+// acceptFormats : Accept のメディアタイプ → 形式
+var acceptFormats = map[string]string{
+	"application/json": formatJSON,
+	"application/*":    formatJSON,
+	"*/*":              formatJSON,
+	"application/xml":  formatXML,
+	"text/xml":         formatXML,
+	"text/plain":       formatText,
+	"text/*":           formatText,
+	// ブラウザで開いた場合（text/html,application/xml;q=0.9 など）は今までどおり JSON
+	"text/html": formatJSON,
+}
+
+// negotiateFormat : ?format= か Accept から返す形式を決める（どれも合わなければ JSON）
+// ?format= が不正なら 400 を返して false
+func negotiateFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	w.Header().Add("Vary", "Accept")
+	switch f := strings.ToLower(r.URL.Query().Get("format")); f {
+	case "":
+	case formatJSON, formatXML, formatText:
+		return f, true
+	default:
+		http.Error(w, fmt.Sprintf("Invalid format %q (use json, xml or text)", f), http.StatusBadRequest)
+		return "", false
+	}
+
+	type candidate struct {
+		format string
+		q      float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		format, ok := acceptFormats[strings.ToLower(strings.TrimSpace(mediaType))]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{format, q})
+		}
+	}
+	// 同じ q なら先に書いたものを選ぶ
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	if len(candidates) == 0 {
+		return formatJSON, true
+	}
+	return candidates[0].format, true
+}
+
+// writeFormatted : v を format の形で書く（XML では root を一番外側の要素名にする）
+func writeFormatted(w http.ResponseWriter, format, root string, v any) {
+	if format == formatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+		return
+	}
+
+	// JSON と同じ項目名・順番にするため、一度 JSON にしてから読み直す
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	node, err := parseJSONNode(json.NewDecoder(bytes.NewReader(b)))
+	if err != nil {
+		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch format {
+	case formatXML:
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		io.WriteString(w, xml.Header)
+		enc := xml.NewEncoder(w)
+		node.writeXML(enc, xml.StartElement{Name: xml.Name{Local: root}})
+		enc.Flush()
+		io.WriteString(w, "\n")
+	case formatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		node.writeText(w)
+	}
+}
+
+// jsonNode : 項目の順番を保ったまま読んだ JSON の値
+type jsonNode struct {
+	keys     []string    // オブジェクトのキー（children と同じ順）
+	children []*jsonNode // オブジェクトの値・配列の要素
+	object   bool
+	array    bool
+	scalar   string // 文字列・数値・真偽値（null なら null=true）
+	null     bool
+}
+
+// parseJSONNode : dec から値を1つ読む
+func parseJSONNode(dec *json.Decoder) (*jsonNode, error) {
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	n := &jsonNode{}
+	switch t := tok.(type) {
+	case json.Delim:
+		n.object, n.array = t == '{', t == '['
+		for dec.More() {
+			if n.object {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				n.keys = append(n.keys, key.(string))
+			}
+			child, err := parseJSONNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, child)
+		}
+		if _, err := dec.Token(); err != nil { // 閉じ括弧
+			return nil, err
+		}
+	case nil:
+		n.null = true
+	case string:
+		n.scalar = t
+	case json.Number:
+		n.scalar = t.String()
+	case bool:
+		n.scalar = strconv.FormatBool(t)
+	}
+	return n, nil
+}
+
+// writeXML : start の要素として書く
+func (n *jsonNode) writeXML(enc *xml.Encoder, start xml.StartElement) {
+	enc.EncodeToken(start)
+	switch {
+	case n.object:
+		for i, key := range n.keys {
+			child := xml.StartElement{Name: xml.Name{Local: key}}
+			if !isXMLName(key) {
+				child = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}}}
+			}
+			n.children[i].writeXML(enc, child)
+		}
+	case n.array:
+		for _, c := range n.children {
+			c.writeXML(enc, xml.StartElement{Name: xml.Name{Local: "item"}})
+		}
+	case !n.null:
+		enc.EncodeToken(xml.CharData(n.scalar))
+	}
+	enc.EncodeToken(start.End())
+}
+
+// isXMLName : そのまま要素名にできるか（英字か _ で始まり、英数字と - _ . だけ。xml で始まるものは予約）
+func isXMLName(s string) bool {
+	if s == "" || strings.HasPrefix(strings.ToLower(s), "xml") {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_' || unicode.IsLetter(c):
+		case i > 0 && (c == '-' || c == '.' || unicode.IsDigit(c)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// writeText : 配列なら1要素1行、それ以外は1行の logfmt で書く
+func (n *jsonNode) writeText(w io.Writer) {
+	lines := []*jsonNode{n}
+	if n.array {
+		lines = n.children
+	}
+	for _, line := range lines {
+		var pairs []string
+		if line.object {
+			line.appendPairs(&pairs, "")
+		} else {
+			pairs = append(pairs, logfmtValue(line.textValue()))
+		}
+		io.WriteString(w, strings.Join(pairs, " ")+"\n")
+	}
+}
+
+// appendPairs : オブジェクトの項目を prefix を付けた key=value にする（null の項目は書かない）
+func (n *jsonNode) appendPairs(pairs *[]string, prefix string) {
+	for i, key := range n.keys {
+		c := n.children[i]
+		switch {
+		case c.null:
+		case c.object:
+			c.appendPairs(pairs, prefix+key+".")
+		case c.array && !c.scalarArray():
+			for j, item := range c.children {
+				name := prefix + key + "." + strconv.Itoa(j)
+				if item.object {
+					item.appendPairs(pairs, name+".")
+				} else {
+					*pairs = append(*pairs, name+"="+logfmtValue(item.textValue()))
+				}
+			}
+		default:
+			*pairs = append(*pairs, prefix+key+"="+logfmtValue(c.textValue()))
+		}
+	}
+}
+
+// scalarArray : 要素が全て値（オブジェクト・配列以外）の配列か
+func (n *jsonNode) scalarArray() bool {
+	for _, c := range n.children {
+		if c.object || c.array {
+			return false
+		}
+	}
+	return true
+}
+
+// textValue : 値・値の配列を1つの文字列にする
+func (n *jsonNode) textValue() string {
+	if !n.array {
+		return n.scalar
+	}
+	values := make([]string, len(n.children))
+	for i, c := range n.children {
+		values[i] = c.scalar
+	}
+	return strings.Join(values, ",")
+}
+
+// logfmtValue : 空白・=・引用符・制御文字を含む値（と空の値）を引用符で囲む
+func logfmtValue(v string) string {
+	if v == "" || strings.ContainsFunc(v, func(c rune) bool {
+		return c == ' ' || c == '=' || c == '"' || unicode.IsControl(c) || unicode.IsSpace(c)
+	}) {
+		return strconv.Quote(v)
+	}
+	return v
+}
@@ -0,0 +1,278 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 通知のルーティング (ログごとに送る通知先を選ぶ・送らない)
+// ==========================================
+// 例: level>=error は slack:alerts へ、bot:true OR tag:known-bot は送らない、それ以外は discord へ
+
+// ルーティングの動作
+const (
+	routeSend     = "send"
+	routeSuppress = "suppress"
+)
+
+// compiledRoute : 検索式を読み込み済みのルーティング
+type compiledRoute struct {
+	model.NotifyRoute
+	query *store.Query // nil なら全てのログに合う
+}
+
+// routeState : 読み込んだルーティング（当てる順）
+type routeState struct {
+	mu   sync.RWMutex
+	list []compiledRoute
+}
+
+// compileRoute : ルーティングを検証して検索式を読む
+func compileRoute(r model.NotifyRoute) (compiledRoute, error) {
+	c := compiledRoute{NotifyRoute: r}
+	if r.Name == "" {
+		return c, errors.New(`"name" is required`)
+	}
+	switch r.Action {
+	case routeSend:
+		if len(r.Channels) == 0 {
+			return c, errors.New(`"channels" is required for send`)
+		}
+	case routeSuppress:
+	default:
+		return c, fmt.Errorf("unknown action %q (use send or suppress)", r.Action)
+	}
+	if strings.TrimSpace(r.Match) != "" {
+		q, err := store.ParseQuery(r.Match)
+		if err != nil {
+			return c, err
+		}
+		c.query = q
+	}
+	return c, nil
+}
+
+// routeNotification : ログの通知に最初に合ったルーティングを当てる
+// 送らない場合は false。送る通知先を決めた場合は q.Targets に入れる（合うものがなければ全ての通知先）
+// アラートやレポートなど、ログを例として添えただけの通知 (Source あり) には当てない
+func (s *Server) routeNotification(q *queuedNotification) bool {
+	n := q.Notification
+	if n.Entry == nil || n.Source != "" {
+		return true
+	}
+	s.routes.mu.RLock()
+	defer s.routes.mu.RUnlock()
+	for _, r := range s.routes.list {
+		if r.query != nil && !r.query.Match(n.Entry) {
+			continue
+		}
+		if r.Action == routeSuppress {
+			return false
+		}
+		q.Targets = r.Channels
+		return true
+	}
+	return true
+}
+
+// reloadRoutes : ルーティングをDBから読み直す（停止中・不正なものは飛ばす）
+func (s *Server) reloadRoutes(ctx context.Context) error {
+	routes, err := s.store.ListNotifyRoutes(ctx)
+	if err != nil {
+		return err
+	}
+	var list []compiledRoute
+	for _, r := range routes {
+		if !r.Enabled {
+			continue
+		}
+		c, err := compileRoute(r)
+		if err != nil {
+			fmt.Printf("Skipping notify route %d (%s): %v\n", r.ID, r.Name, err)
+			continue
+		}
+		list = append(list, c)
+	}
+	s.routes.mu.Lock()
+	s.routes.list = list
+	s.routes.mu.Unlock()
+	return nil
+}
+
+// reloadRoutesAfterChange : 変更をすぐ反映する（失敗しても次の定期読み込みで反映される）
+func (s *Server) reloadRoutesAfterChange(ctx context.Context) {
+	if err := s.reloadRoutes(ctx); err != nil {
+		fmt.Println("Failed to reload notify routes:", err)
+	}
+}
+
+// routeRequest : POST / PATCH の本文（PATCH では省略した項目は変えない）
+type routeRequest struct {
+	Name     *string   `json:"name"`
+	Position *int      `json:"position"`
+	Match    *string   `json:"match"`
+	Action   *string   `json:"action"`
+	Channels *[]string `json:"channels"`
+	Enabled  *bool     `json:"enabled"`
+}
+
+// apply : 指定された項目だけ r に反映する
+func (req routeRequest) apply(r *model.NotifyRoute) {
+	if req.Name != nil {
+		r.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Position != nil {
+		r.Position = *req.Position
+	}
+	if req.Match != nil {
+		r.Match = strings.TrimSpace(*req.Match)
+	}
+	if req.Action != nil {
+		r.Action = strings.ToLower(strings.TrimSpace(*req.Action))
+	}
+	if req.Channels != nil {
+		r.Channels = nil
+		for _, c := range *req.Channels {
+			if c = strings.TrimSpace(c); c != "" {
+				r.Channels = append(r.Channels, c)
+			}
+		}
+	}
+	if req.Enabled != nil {
+		r.Enabled = *req.Enabled
+	}
+}
+
+// routeID : パスの {id}
+func routeID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid route id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// listRoutesHandler : GET /api/notify-routes
+// 使える通知先の名前も known_channels で返す
+func (s *Server) listRoutesHandler(w http.ResponseWriter, r *http.Request) {
+	routes, err := s.store.ListNotifyRoutes(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Routes        []model.NotifyRoute `json:"routes"`
+		KnownChannels []string            `json:"known_channels"`
+	}{routes, s.notifierNames()})
+}
+
+// notifierNames : 環境変数とDBの通知先の名前（ルーティングの channels に書く名前）
+func (s *Server) notifierNames() []string {
+	names := []string{}
+	for _, n := range []notify.Notifier{s.envNotifier(), s.channels.Load(), s.incidents} {
+		if m, ok := n.(*notify.Multi); ok && m != nil {
+			names = append(names, m.Names()...)
+		}
+	}
+	return names
+}
+
+// createRouteHandler : POST /api/notify-routes {"name": "errors", "match": "level>=error", "channels": ["slack:alerts"]}
+func (s *Server) createRouteHandler(w http.ResponseWriter, r *http.Request) {
+	var req routeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	route := model.NotifyRoute{Action: routeSend, Enabled: true}
+	req.apply(&route)
+	if _, err := compileRoute(route); err != nil {
+		http.Error(w, "Invalid route: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.CreateNotifyRoute(r.Context(), &route); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadRoutesAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(route)
+}
+
+// updateRouteHandler : PATCH /api/notify-routes/{id}
+func (s *Server) updateRouteHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(w, r)
+	if !ok {
+		return
+	}
+	var req routeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	route, err := s.store.NotifyRouteByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Route not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	req.apply(&route)
+	if _, err := compileRoute(route); err != nil {
+		http.Error(w, "Invalid route: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = s.store.UpdateNotifyRoute(r.Context(), &route)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Route not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadRoutesAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(route)
+}
+
+// deleteRouteHandler : DELETE /api/notify-routes/{id}
+func (s *Server) deleteRouteHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(w, r)
+	if !ok {
+		return
+	}
+	err := s.store.DeleteNotifyRoute(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Route not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadRoutesAfterChange(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
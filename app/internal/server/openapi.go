@@ -0,0 +1,47 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ==========================================
+// APIの仕様 (OpenAPI 3)
+// ==========================================
+// 仕様は openapi.yaml に手で書き、バイナリに埋め込んで GET /api/openapi.json で配信する
+// 画面は static/api-docs.html (Swagger UI)。クライアントの生成には openapi.json をそのまま使える
+
+//go:embed openapi.yaml
+var openAPIYAML []byte
+
+// openAPISpec : 読み込んだ仕様（起動時に1度だけ読み、読めなければ起動しない）
+var openAPISpec = mustParseOpenAPI(openAPIYAML)
+
+// mustParseOpenAPI : YAML の仕様を JSON に変換できる形で読む
+func mustParseOpenAPI(src []byte) map[string]any {
+	var spec map[string]any
+	if err := yaml.Unmarshal(src, &spec); err != nil {
+		panic(fmt.Sprintf("server: invalid openapi.yaml: %v", err))
+	}
+	if _, err := json.Marshal(spec); err != nil {
+		panic(fmt.Sprintf("server: openapi.yaml cannot be encoded as JSON: %v", err))
+	}
+	return spec
+}
+
+// openAPIHandler : GET /api/openapi.json
+// servers は外から見た URL (PUBLIC_BASE_URL か、リクエストのホストと BASE_PATH) にする
+func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	spec := make(map[string]any, len(openAPISpec))
+	for k, v := range openAPISpec {
+		spec[k] = v
+	}
+	spec["servers"] = []map[string]string{{"url": s.publicBaseURL(r)}}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(spec)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go-logger/internal/i18n"
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 計測用の画像 (GET /pixel.gif?site=...)
+// ==========================================
+// JavaScript の動かないページやメールでも、<img src=".../pixel.gif?site=news"> を置くだけで /api/track と同じく記録する
+//
+//	<img src="https://dev.aliceindex.jp/go/pixel.gif?site=newsletter&path=/2026-10&event_type=custom:email-open&key=..." width="1" height="1" alt="">
+//
+// 記録するパスは ?path=、なければ Referer のパス（メールのように Referer がなければ /pixel.gif）
+// 毎回サーバーまで届くよう、画像そのものはメモリに持ったまま Cache-Control: no-store で返す
+
+// pixelGIF : 1x1 の透明な GIF
+var pixelGIF = []byte{
+	'G', 'I', 'F', '8', '9', 'a', 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// pixelHandler : アクセスを記録して透明な画像を返す（記録に失敗しても画像は返す）
+// サイト名・キー・?event_type= などが不正なら、設定の誤りに気付けるよう画像ではなくエラーを返す
+func (s *Server) pixelHandler(w http.ResponseWriter, r *http.Request) {
+	var site string
+	if v := r.URL.Query().Get("site"); v != "" {
+		var err error
+		if site, err = normalizeSite(v); err != nil {
+			http.Error(w, i18n.T(w, r, i18n.InvalidRequest, err), http.StatusBadRequest)
+			return
+		}
+	}
+	s.withProject(w, r, func(projectID int) {
+		lw, err := s.accessWrite(w, r, projectID, model.EventPageview)
+		if err != nil {
+			http.Error(w, i18n.T(w, r, i18n.InvalidRequest, err), http.StatusBadRequest)
+			return
+		}
+		lw.Path = pixelPath(r)
+		if site != "" {
+			lw.Fields = siteFields(site)
+		}
+		if _, err := s.recordAccess(r.Context(), &lw); err != nil {
+			fmt.Println("Failed to record pixel access:", err)
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", "image/gif")
+		h.Set("Content-Length", strconv.Itoa(len(pixelGIF)))
+		h.Set("Cache-Control", "no-store, no-cache, must-revalidate, private")
+		h.Set("Pragma", "no-cache")
+		h.Set("Expires", "0")
+		w.Write(pixelGIF)
+	})
+}
+
+// pixelPath : 記録するパス（?path=、Referer のパス、/pixel.gif の順）
+func pixelPath(r *http.Request) string {
+	if p := r.URL.Query().Get("path"); p != "" {
+		if p[0] != '/' {
+			p = "/" + p
+		}
+		return p
+	}
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Path != "" {
+		return ref.Path
+	}
+	return "/pixel.gif"
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// プライバシー (IP の切り詰め・UA のハッシュ化)
+// ==========================================
+// GDPR などのために、保存する前に個人を特定しうる値を加工する
+// 既定は PRIVACY_ANONYMIZE_IP / PRIVACY_HASH_USER_AGENT で、プロジェクトごとに PUT /api/projects/{id}/privacy で変えられる
+// 国・UA の判定（エンリッチ）は加工する前の値で行うので、集計の国・ブラウザはそのまま使える
+
+// hashedUAPrefix : ハッシュにした UA の印（ダッシュボードで元の UA と見分ける）
+const hashedUAPrefix = "sha256:"
+
+// privacyState : 読み込んだプロジェクトごとの設定
+type privacyState struct {
+	mu       sync.RWMutex
+	projects map[int]model.ProjectPrivacy
+}
+
+// privacySecretFromEnv : PRIVACY_SALT_SECRET（未設定なら起動ごとにランダム。複数台で同じ値にするには設定する）
+func privacySecretFromEnv() string {
+	if secret := config.String("PRIVACY_SALT_SECRET", ""); secret != "" {
+		return secret
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("server: failed to generate privacy salt: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// anonymizeIP : IPv4 は /24、IPv6 は /48 に切り詰める（読めないものはそのまま）
+func anonymizeIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+	return prefix.Addr().String()
+}
+
+// privacySalt : at を含む期間 (PRIVACY_SALT_ROTATION ごと) のソルト
+// 期間が変わると同じ UA でも別の値になり、長い期間をまたいで同じ人を追えない
+func (s *Server) privacySalt(at time.Time) []byte {
+	period := int64(0)
+	if s.cfg.PrivacySaltRotation > 0 {
+		period = at.UnixNano() / int64(s.cfg.PrivacySaltRotation)
+	}
+	mac := hmac.New(sha256.New, []byte(s.cfg.PrivacySecret))
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(period)))
+	return mac.Sum(nil)
+}
+
+// hashUserAgent : UA をソルト付きでハッシュにする（空ならそのまま）
+func (s *Server) hashUserAgent(ua string, at time.Time) string {
+	if ua == "" {
+		return ua
+	}
+	mac := hmac.New(sha256.New, s.privacySalt(at))
+	mac.Write([]byte(ua))
+	return hashedUAPrefix + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// projectPrivacy : プロジェクトの設定に既定を当てたもの（IP を切り詰めるか, UA をハッシュにするか）
+func (s *Server) projectPrivacy(projectID int) (anonymize, hashUA bool) {
+	anonymize, hashUA = s.cfg.AnonymizeIP, s.cfg.HashUserAgent
+	s.privacy.mu.RLock()
+	p, ok := s.privacy.projects[projectID]
+	s.privacy.mu.RUnlock()
+	if ok {
+		if p.AnonymizeIP != nil {
+			anonymize = *p.AnonymizeIP
+		}
+		if p.HashUserAgent != nil {
+			hashUA = *p.HashUserAgent
+		}
+	}
+	return anonymize, hashUA
+}
+
+// applyPrivacy : 保存する前に lw の IP・UA を設定どおりに加工する
+func (s *Server) applyPrivacy(lw *model.Write) {
+	anonymize, hashUA := s.projectPrivacy(lw.ProjectID)
+	if anonymize && lw.IP != "" {
+		lw.IP = anonymizeIP(lw.IP)
+	}
+	if hashUA {
+		lw.UserAgent = s.hashUserAgent(lw.UserAgent, lw.CreatedAt)
+	}
+}
+
+// reloadPrivacy : プロジェクトごとの設定をDBから読み直す
+func (s *Server) reloadPrivacy(ctx context.Context) error {
+	projects, err := s.store.ListProjects(ctx)
+	if err != nil {
+		return err
+	}
+	m := make(map[int]model.ProjectPrivacy, len(projects))
+	for _, p := range projects {
+		m[p.ID] = p.Privacy
+	}
+	s.privacy.mu.Lock()
+	s.privacy.projects = m
+	s.privacy.mu.Unlock()
+	return nil
+}
+
+// reloadPrivacyAfterChange : 変更をすぐ反映する（失敗しても次の定期読み込みで反映される）
+func (s *Server) reloadPrivacyAfterChange(ctx context.Context) {
+	if err := s.reloadPrivacy(ctx); err != nil {
+		fmt.Println("Failed to reload project privacy settings:", err)
+	}
+}
+
+// updateProjectPrivacyHandler : PUT /api/projects/{id}/privacy {"anonymize_ip": true, "hash_user_agent": null}
+// null・省略した項目はサーバーの既定に従う。加工するのはこれから保存するログだけ
+func (s *Server) updateProjectPrivacyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project id", http.StatusBadRequest)
+		return
+	}
+	var req model.ProjectPrivacy
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p, err := s.store.UpdateProjectPrivacy(r.Context(), id, req)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadPrivacyAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// ==========================================
+// 本人からの開示・削除の依頼 (データ主体の権利)
+// ==========================================
+// ?ip= か ?visitor_id= に合う行を返す・削除し、処理したことを privacy_requests に残す
+// IP を切り詰めて保存したプロジェクトでは、元の IP では見つからない（行から本人を特定できないため）
+// 保存期間を過ぎてアーカイブに書き出した分は対象外
+
+// privacySubject : クエリから本人の見分け方を読む（?project_id= で1プロジェクトに絞れる）
+// 記録に残す識別子のハッシュも返す
+func privacySubject(w http.ResponseWriter, r *http.Request) (store.Subject, string, bool) {
+	q := r.URL.Query()
+	var sub store.Subject
+	var identifier string
+	switch ip, visitor := strings.TrimSpace(q.Get("ip")), strings.TrimSpace(q.Get("visitor_id")); {
+	case ip != "" && visitor != "":
+		http.Error(w, "Specify either ip or visitor_id, not both", http.StatusBadRequest)
+		return sub, "", false
+	case ip != "":
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			http.Error(w, "Invalid ip: "+ip, http.StatusBadRequest)
+			return sub, "", false
+		}
+		sub.IP = addr.Unmap().String()
+		identifier = "ip:" + sub.IP
+	case visitor != "":
+		sub.VisitorID = visitor
+		identifier = "visitor_id:" + visitor
+	default:
+		http.Error(w, "ip or visitor_id is required", http.StatusBadRequest)
+		return sub, "", false
+	}
+	if v := q.Get("project_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid project_id", http.StatusBadRequest)
+			return sub, "", false
+		}
+		sub.ProjectID = id
+	}
+	sum := sha256.Sum256([]byte(identifier))
+	return sub, hex.EncodeToString(sum[:]), true
+}
+
+// privacyRequest : 処理した記録（?reason= に依頼の受付番号などを残せる）
+func (s *Server) privacyRequest(r *http.Request, action string, sub store.Subject, hash string) model.PrivacyRequest {
+	return model.PrivacyRequest{
+		Action:      action,
+		SubjectHash: hash,
+		ProjectID:   sub.ProjectID,
+		Reason:      strings.TrimSpace(r.URL.Query().Get("reason")),
+		RequestedAt: s.clock.Now(),
+	}
+}
+
+// privacyExportHandler : GET /api/privacy/export?ip=203.0.113.7&reason=ticket-123
+// 本人のログを全て返す（項目の表示ルールは当てない）
+func (s *Server) privacyExportHandler(w http.ResponseWriter, r *http.Request) {
+	sub, hash, ok := privacySubject(w, r)
+	if !ok {
+		return
+	}
+	entries, err := s.store.SubjectLogs(r.Context(), sub)
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	audit := s.privacyRequest(r, "export", sub, hash)
+	audit.Rows = int64(len(entries))
+	if err := s.store.InsertPrivacyRequest(r.Context(), &audit); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	fmt.Printf("Privacy export #%d: %d rows\n", audit.ID, audit.Rows)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="privacy-export-%d.json"`, audit.ID))
+	json.NewEncoder(w).Encode(struct {
+		Request model.PrivacyRequest `json:"request"`
+		Entries []model.LogEntry     `json:"entries"`
+	}{audit, entries})
+}
+
+// privacyDeleteHandler : POST /api/privacy/delete?ip=203.0.113.7&reason=ticket-123
+// 本人のログを全て削除し、記録（削除した行数）を返す
+func (s *Server) privacyDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	sub, hash, ok := privacySubject(w, r)
+	if !ok {
+		return
+	}
+	audit := s.privacyRequest(r, "delete", sub, hash)
+	if err := s.store.DeleteSubjectLogs(r.Context(), sub, &audit); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	if audit.Rows > 0 {
+		s.recent.invalidate()
+	}
+	fmt.Printf("Privacy delete #%d: %d rows\n", audit.ID, audit.Rows)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(audit)
+}
+
+// privacyRequestsHandler : GET /api/privacy/requests?limit=100（処理した記録）
+// ?ip= か ?visitor_id= を付けると、その識別子の記録だけ返す
+func (s *Server) privacyRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 1000 {
+		limit = v
+	}
+	var hash string
+	if r.URL.Query().Has("ip") || r.URL.Query().Has("visitor_id") {
+		var ok bool
+		if _, hash, ok = privacySubject(w, r); !ok {
+			return
+		}
+	}
+	requests, err := s.store.ListPrivacyRequests(r.Context(), hash, limit)
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
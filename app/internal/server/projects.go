@@ -0,0 +1,237 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-logger/internal/i18n"
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// プロジェクト (サイトごとのAPIキーとデータの分離)
+// ==========================================
+
+var errUnknownProjectKey = errors.New("unknown project key")
+
+// projectKey : X-API-Key ヘッダー（なければ ?key=）からキーを取り出す
+func projectKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("key")
+}
+
+// resolveProject : リクエストのキーからプロジェクトIDを決める
+// キーがなければデフォルトプロジェクト（REQUIRE_PROJECT_KEY=true なら拒否）
+func (s *Server) resolveProject(ctx context.Context, r *http.Request) (int, error) {
+	key := projectKey(r)
+	if key == "" {
+		if s.cfg.RequireProjectKey {
+			return 0, errUnknownProjectKey
+		}
+		return model.DefaultProjectID, nil
+	}
+	return s.projectIDByKey(ctx, key)
+}
+
+// projectIDByKey : キーからプロジェクトIDを引く（一度引いたキーは覚えておく）
+// ロール付きのAPIキーなら、そのキーのプロジェクト
+func (s *Server) projectIDByKey(ctx context.Context, key string) (int, error) {
+	if k, ok := s.roleKey(key); ok {
+		return k.ProjectID, nil
+	}
+	if id, ok := s.projectKeys.Load(key); ok {
+		return id.(int), nil
+	}
+
+	id, err := s.store.ProjectIDByKey(ctx, key)
+	if errors.Is(err, store.ErrNotFound) {
+		return 0, errUnknownProjectKey
+	}
+	if err != nil {
+		return 0, err
+	}
+	s.projectKeys.Store(key, id)
+	return id, nil
+}
+
+// reloadProjectKeys : キャッシュをDBのキーに合わせる（別のインスタンスで作り直した古いキーを、ここでも受け付けなくする）
+// 読み直せない間（DBの再接続中）はキャッシュをそのまま使う
+func (s *Server) reloadProjectKeys(ctx context.Context) error {
+	keys, err := s.store.ProjectKeys(ctx)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]int, len(keys))
+	for id, key := range keys {
+		current[key] = id
+	}
+	s.projectKeys.Range(func(k, v any) bool {
+		if id, ok := current[k.(string)]; !ok || id != v.(int) {
+			s.projectKeys.Delete(k)
+		}
+		return true
+	})
+	for key, id := range current {
+		s.projectKeys.Store(key, id)
+	}
+	return nil
+}
+
+// withProject : プロジェクトを解決できたらハンドラを呼ぶ
+// キーを付けたリクエストはキーの利用量として数え、上限 (PUT /api/projects/{id}/quota) を超えていれば 429 で断る
+func (s *Server) withProject(w http.ResponseWriter, r *http.Request, next func(projectID int)) {
+	projectID, err := s.resolveProject(r.Context(), r)
+	if errors.Is(err, errUnknownProjectKey) {
+		http.Error(w, i18n.T(w, r, i18n.InvalidProjectKey), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	if projectKey(r) != "" && s.meteringEnabled() {
+		if ok, retryAfter, msg := s.meterKey(w, r, projectID); !ok {
+			writeQuotaExceeded(w, retryAfter, msg)
+			return
+		}
+	}
+	next(projectID)
+}
+
+// requireAdmin : 管理API用の認証 (Authorization: Bearer <ADMIN_TOKEN> か、admin のロールのキー・ユーザー)
+// ADMIN_TOKEN が未設定なら管理APIは無効
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return s.requireRole(permAdmin, next)
+}
+
+// isAdmin : 正しい ADMIN_TOKEN が付いているか、admin のロールのキー・ユーザーか（ADMIN_TOKEN が未設定なら常に false）
+func (s *Server) isAdmin(r *http.Request) bool {
+	if s.cfg.AdminToken == "" {
+		return false
+	}
+	if s.hasAdminToken(r) {
+		return true
+	}
+	roles, ok := s.principalRoles(r)
+	return ok && allows(roles, permAdmin)
+}
+
+// hasAdminToken : 正しい ADMIN_TOKEN が付いているか（未設定なら常に false）
+func (s *Server) hasAdminToken(r *http.Request) bool {
+	token := s.cfg.AdminToken
+	if token == "" {
+		return false
+	}
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// newAPIKey : ランダムなAPIキーを作る
+func newAPIKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// listProjectsHandler : GET /api/projects （キーは返さない）
+func (s *Server) listProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	projects, err := s.store.ListProjects(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projects)
+}
+
+// createProjectHandler : POST /api/projects {"name": "..."} で作成し、発行したキーを返す
+// "privacy": {"anonymize_ip": true}、"quota": {"daily": 10000} も一緒に指定できる
+func (s *Server) createProjectHandler(w http.ResponseWriter, r *http.Request) {
+	var req model.Project
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		http.Error(w, `Invalid request: "name" is required`, http.StatusBadRequest)
+		return
+	}
+
+	key, err := newAPIKey()
+	if err != nil {
+		http.Error(w, "Failed to generate key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p, err := s.store.CreateProject(r.Context(), strings.TrimSpace(req.Name), key)
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	if req.Privacy != (model.ProjectPrivacy{}) {
+		if p, err = s.store.UpdateProjectPrivacy(r.Context(), p.ID, req.Privacy); err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+		p.APIKey = key
+		s.reloadPrivacyAfterChange(r.Context())
+	}
+	if req.Quota != (model.ProjectQuota{}) {
+		if req.Quota.Daily < 0 || req.Quota.Monthly < 0 {
+			http.Error(w, "Invalid quota: must be 0 (unlimited) or more", http.StatusBadRequest)
+			return
+		}
+		if p, err = s.store.UpdateProjectQuota(r.Context(), p.ID, req.Quota); err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+		p.APIKey = key
+		s.reloadQuotasAfterChange(r.Context())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+// rotateProjectKeyHandler : POST /api/projects/{id}/rotate でキーを再発行する
+func (s *Server) rotateProjectKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project id", http.StatusBadRequest)
+		return
+	}
+	key, err := newAPIKey()
+	if err != nil {
+		http.Error(w, "Failed to generate key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p, err := s.store.RotateProjectKey(r.Context(), id, key)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	// 古いキーをキャッシュから消す
+	s.projectKeys.Range(func(k, v any) bool {
+		if v.(int) == p.ID {
+			s.projectKeys.Delete(k)
+		}
+		return true
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/store"
+)
+
+// TestReloadProjectKeys : 別のインスタンスでキーを作り直しても、次の読み直しで古いキーを受け付けなくなる
+func TestReloadProjectKeys(t *testing.T) {
+	ctx := context.Background()
+	mem := store.NewMemory(clock.System{}, 100)
+	s := New(Config{}, Deps{Store: mem})
+	p, err := mem.CreateProject(ctx, "site", "old-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := s.projectIDByKey(ctx, "old-key"); err != nil || id != p.ID {
+		t.Fatalf("old key: id %d, err %v", id, err)
+	}
+
+	// 別のインスタンスが作り直した（このインスタンスのキャッシュには古いキーが残る）
+	if _, err := mem.RotateProjectKey(ctx, p.ID, "new-key"); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := s.projectIDByKey(ctx, "old-key"); err != nil || id != p.ID {
+		t.Fatalf("cached old key before reload: id %d, err %v", id, err)
+	}
+
+	if err := s.reloadProjectKeys(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.projectIDByKey(ctx, "old-key"); !errors.Is(err, errUnknownProjectKey) {
+		t.Errorf("old key after reload: err %v, want errUnknownProjectKey", err)
+	}
+	if id, ok := s.projectKeys.Load("new-key"); !ok || id.(int) != p.ID {
+		t.Errorf("new key is not cached after reload: %v", id)
+	}
+}
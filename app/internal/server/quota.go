@@ -0,0 +1,404 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-logger/internal/i18n"
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// キーごとの利用量と上限 (/api/keys/{id}/usage)
+// ==========================================
+// X-API-Key（?key=）を付けたリクエストをプロジェクトのキーごと・日ごと (UTC) に数え、
+// USAGE_FLUSH_INTERVAL ごとにまとめて key_usage に書く
+// PUT /api/projects/{id}/quota で1日・1か月の上限を決めると、超えたリクエストは 429 で断る
+// 上限はDBの合計（ほかのインスタンスの分を含む）とこのインスタンスのまだ書いていない分で判定するので、
+// 複数のインスタンスでは書き込みの間隔の分だけ上限を少し超えることがある
+
+// usageKey : 書き込み待ちの数の単位（プロジェクトと日）
+type usageKey struct {
+	projectID int
+	day       time.Time
+}
+
+// usageTotals : DBから読んだ合計
+type usageTotals struct {
+	day     time.Time
+	daily   int64
+	monthly int64
+}
+
+// usageState : 上限と、数えたリクエスト
+type usageState struct {
+	flushMu sync.Mutex // 書き込みを1つずつにする（同じ分を2回足さない）
+	mu      sync.Mutex
+	quotas  map[int]model.ProjectQuota
+	pending map[usageKey]*model.KeyUsage
+	totals  map[int]usageTotals
+}
+
+// utcDay : UTC の日付（0時）
+func utcDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// utcMonth : UTC の月の初日
+func utcMonth(t time.Time) time.Time {
+	y, m, _ := t.UTC().Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// meteringEnabled : キーの利用量を数えるか（USAGE_FLUSH_INTERVAL=0 とドライランでは数えない）
+func (s *Server) meteringEnabled() bool {
+	return !s.cfg.DryRun && s.cfg.UsageFlushInterval > 0
+}
+
+// meterKey : キーのリクエストを数える。上限を超えていれば数えずに false を返す
+// retryAfter は上限が戻るまで（次の日・月の始め）、msg はクライアントに返す理由
+func (s *Server) meterKey(w http.ResponseWriter, r *http.Request, projectID int) (ok bool, retryAfter time.Duration, msg string) {
+	now := s.clock.Now()
+	day, month := utcDay(now), utcMonth(now)
+
+	s.usage.mu.Lock()
+	defer s.usage.mu.Unlock()
+	daily, monthly := s.usedLocked(projectID, day, month)
+	quota := s.usage.quotas[projectID]
+	ok = true
+	switch {
+	case quota.Daily > 0 && daily >= quota.Daily:
+		ok, retryAfter, msg = false, day.AddDate(0, 0, 1).Sub(now), i18n.T(w, r, i18n.DailyQuota, quota.Daily)
+	case quota.Monthly > 0 && monthly >= quota.Monthly:
+		ok, retryAfter, msg = false, month.AddDate(0, 1, 0).Sub(now), i18n.T(w, r, i18n.MonthlyQuota, quota.Monthly)
+	}
+
+	if s.usage.pending == nil {
+		s.usage.pending = map[usageKey]*model.KeyUsage{}
+	}
+	k := usageKey{projectID: projectID, day: day}
+	u := s.usage.pending[k]
+	if u == nil {
+		u = &model.KeyUsage{ProjectID: projectID, Day: day}
+		s.usage.pending[k] = u
+	}
+	if ok {
+		u.Requests++
+	} else {
+		u.Rejected++
+	}
+	return ok, retryAfter, msg
+}
+
+// usedLocked : 今日・今月に受け付けた数（DBの合計 + 書き込み待ち。mu を持って呼ぶ）
+func (s *Server) usedLocked(projectID int, day, month time.Time) (daily, monthly int64) {
+	if t, ok := s.usage.totals[projectID]; ok {
+		if t.day.Equal(day) {
+			daily = t.daily
+		}
+		if !t.day.Before(month) {
+			monthly = t.monthly
+		}
+	}
+	for k, u := range s.usage.pending {
+		if k.projectID != projectID {
+			continue
+		}
+		if k.day.Equal(day) {
+			daily += u.Requests
+		}
+		if !k.day.Before(month) {
+			monthly += u.Requests
+		}
+	}
+	return daily, monthly
+}
+
+// flushUsage : 数えたリクエストを key_usage に書き、上限のあるキーの合計を読み直す
+// 書けなかった分は書き込み待ちに残し、次の書き込みに回す
+func (s *Server) flushUsage(ctx context.Context) error {
+	s.usage.flushMu.Lock()
+	defer s.usage.flushMu.Unlock()
+	s.usage.mu.Lock()
+	usage := make([]model.KeyUsage, 0, len(s.usage.pending))
+	for _, u := range s.usage.pending {
+		usage = append(usage, *u)
+	}
+	s.usage.mu.Unlock()
+
+	if len(usage) > 0 {
+		if err := s.store.AddKeyUsage(ctx, usage); err != nil {
+			return err
+		}
+	}
+	totals, err := s.loadUsageTotals(ctx)
+
+	// 書いた分を書き込み待ちから引き、DBの合計と入れ替える（書いている間に数えた分は残る）
+	s.usage.mu.Lock()
+	defer s.usage.mu.Unlock()
+	for _, u := range usage {
+		k := usageKey{projectID: u.ProjectID, day: u.Day}
+		cur := s.usage.pending[k]
+		cur.Requests -= u.Requests
+		cur.Rejected -= u.Rejected
+		if cur.Requests == 0 && cur.Rejected == 0 {
+			delete(s.usage.pending, k)
+		}
+	}
+	if err != nil {
+		// 読み直せなかった時は、書いた分を古い合計に足しておく
+		for _, u := range usage {
+			if t, ok := s.usage.totals[u.ProjectID]; ok {
+				if t.day.Equal(u.Day) {
+					t.daily += u.Requests
+				}
+				t.monthly += u.Requests
+				s.usage.totals[u.ProjectID] = t
+			}
+		}
+		return err
+	}
+	s.usage.totals = totals
+	return nil
+}
+
+// loadUsageTotals : 上限のあるキーの今日・今月の合計をDBから読む
+func (s *Server) loadUsageTotals(ctx context.Context) (map[int]usageTotals, error) {
+	s.usage.mu.Lock()
+	var projectIDs []int
+	for id, q := range s.usage.quotas {
+		if q.Daily > 0 || q.Monthly > 0 {
+			projectIDs = append(projectIDs, id)
+		}
+	}
+	s.usage.mu.Unlock()
+
+	now := s.clock.Now()
+	day, month := utcDay(now), utcMonth(now)
+	totals := make(map[int]usageTotals, len(projectIDs))
+	for _, id := range projectIDs {
+		usage, err := s.store.KeyUsage(ctx, id, month)
+		if err != nil {
+			return nil, err
+		}
+		t := usageTotals{day: day}
+		for _, u := range usage {
+			t.monthly += u.Requests
+			if u.Day.Equal(day) {
+				t.daily += u.Requests
+			}
+		}
+		totals[id] = t
+	}
+	return totals, nil
+}
+
+// reloadQuotas : プロジェクトごとの上限をDBから読み直す
+func (s *Server) reloadQuotas(ctx context.Context) error {
+	projects, err := s.store.ListProjects(ctx)
+	if err != nil {
+		return err
+	}
+	m := make(map[int]model.ProjectQuota, len(projects))
+	for _, p := range projects {
+		m[p.ID] = p.Quota
+	}
+	s.usage.mu.Lock()
+	s.usage.quotas = m
+	s.usage.mu.Unlock()
+	return nil
+}
+
+// watchUsage : 数えたリクエストを定期的に書き込む（止める時にも残りを書く）
+func (s *Server) watchUsage(ctx context.Context) {
+	if !s.meteringEnabled() {
+		return
+	}
+	if err := s.reloadQuotas(ctx); err != nil {
+		fmt.Println("Failed to load key quotas:", err)
+	}
+	ticker := s.clock.NewTicker(s.cfg.UsageFlushInterval)
+	defer ticker.Stop()
+	j := s.jobs.register("usage", s.cfg.UsageFlushInterval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := s.flushUsage(flushCtx); err != nil {
+				fmt.Println("Failed to write key usage:", err)
+			}
+			return
+		case <-ticker.C():
+		}
+		s.runJob(j, func() error {
+			if err := s.reloadQuotas(ctx); err != nil {
+				fmt.Println("Failed to load key quotas:", err)
+				return err
+			}
+			if err := s.flushUsage(ctx); err != nil {
+				fmt.Println("Failed to write key usage:", err)
+				return err
+			}
+			return nil
+		})
+	}
+}
+
+// writeQuotaExceeded : 上限を超えたリクエストに 429 を返す
+func writeQuotaExceeded(w http.ResponseWriter, retryAfter time.Duration, msg string) {
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeJSONError(w, http.StatusTooManyRequests, msg)
+}
+
+// ==========================================
+// 利用量・上限の管理API
+// ==========================================
+
+// usagePeriod : 今日・今月の数
+type usagePeriod struct {
+	Requests  int64  `json:"requests"`
+	Rejected  int64  `json:"rejected"`
+	Quota     int64  `json:"quota"`               // 0 なら制限しない
+	Remaining *int64 `json:"remaining,omitempty"` // 上限がある時だけ
+}
+
+// usageResponse : GET /api/keys/{id}/usage
+type usageResponse struct {
+	ProjectID int              `json:"project_id"`
+	Today     usagePeriod      `json:"today"`
+	Month     usagePeriod      `json:"month"`
+	Days      []model.KeyUsage `json:"days"`
+}
+
+// newUsagePeriod : 合計と上限から1期間分を作る
+func newUsagePeriod(requests, rejected, quota int64) usagePeriod {
+	p := usagePeriod{Requests: requests, Rejected: rejected, Quota: quota}
+	if quota > 0 {
+		remaining := max(quota-requests, 0)
+		p.Remaining = &remaining
+	}
+	return p
+}
+
+// keyUsageHandler : GET /api/keys/{id}/usage?days=30 （id はプロジェクトのID。日ごとの数は古い順、今月の分は days に関係なく数える）
+func (s *Server) keyUsageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project id", http.StatusBadRequest)
+		return
+	}
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		if days, err = strconv.Atoi(v); err != nil || days < 1 || days > 366 {
+			http.Error(w, "Invalid days (1-366)", http.StatusBadRequest)
+			return
+		}
+	}
+	projects, err := s.store.ListProjects(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	var project *model.Project
+	for i := range projects {
+		if projects[i].ID == id {
+			project = &projects[i]
+		}
+	}
+	if project == nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	// このインスタンスでまだ書いていない分も含める
+	if err := s.flushUsage(r.Context()); err != nil {
+		fmt.Println("Failed to write key usage:", err)
+	}
+	now := s.clock.Now()
+	day, month := utcDay(now), utcMonth(now)
+	from := day.AddDate(0, 0, 1-days)
+	since := from
+	if month.Before(since) {
+		since = month
+	}
+	usage, err := s.store.KeyUsage(r.Context(), id, since)
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	resp := usageResponse{ProjectID: id, Days: []model.KeyUsage{}}
+	var today, monthly model.KeyUsage
+	for _, u := range usage {
+		if u.Day.Equal(day) {
+			today = u
+		}
+		if !u.Day.Before(month) {
+			monthly.Requests += u.Requests
+			monthly.Rejected += u.Rejected
+		}
+		if !u.Day.Before(from) {
+			resp.Days = append(resp.Days, u)
+		}
+	}
+	resp.Today = newUsagePeriod(today.Requests, today.Rejected, project.Quota.Daily)
+	resp.Month = newUsagePeriod(monthly.Requests, monthly.Rejected, project.Quota.Monthly)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// updateProjectQuotaHandler : PUT /api/projects/{id}/quota {"daily": 10000, "monthly": 0} （0 なら制限しない）
+func (s *Server) updateProjectQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project id", http.StatusBadRequest)
+		return
+	}
+	var req model.ProjectQuota
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Daily < 0 || req.Monthly < 0 {
+		http.Error(w, "Invalid quota: must be 0 (unlimited) or more", http.StatusBadRequest)
+		return
+	}
+
+	p, err := s.store.UpdateProjectQuota(r.Context(), id, req)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadQuotasAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// reloadQuotasAfterChange : 変更をすぐ反映する（失敗しても次の定期読み込みで反映される）
+func (s *Server) reloadQuotasAfterChange(ctx context.Context) {
+	if err := s.reloadQuotas(ctx); err != nil {
+		fmt.Println("Failed to reload key quotas:", err)
+		return
+	}
+	if err := s.flushUsage(ctx); err != nil {
+		fmt.Println("Failed to write key usage:", err)
+	}
+}
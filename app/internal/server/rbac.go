@@ -0,0 +1,369 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go-logger/internal/auth"
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// ロールによる権限 (viewer / ingester / admin)
+// ==========================================
+// ロールはAPIキー (POST /api/keys) とダッシュボードのユーザー (PUT /api/users/{user}) に付け、エンドポイントのまとまりごとに確かめる
+// プロジェクトのキー（ロールを付けていない従来のキー）は viewer と ingester、ロールを付けていないユーザーは DASHBOARD_DEFAULT_ROLES
+// ADMIN_TOKEN は今まで通り全てを許す（管理APIは ADMIN_TOKEN を設定した時だけ有効で、admin のキー・ユーザーでも呼べる）
+
+// permission : エンドポイントのまとまり1つ分の権限
+type permission string
+
+const (
+	permLogsRead  permission = "logs:read"       // ログ・集計の読み出しと画面（ゴミ箱への移動・タグの操作を含む）
+	permLogsWrite permission = "logs:write"      // 書き込み（アクセス・構造化ログ・Webhook の受け口）
+	permChannels  permission = "channels:manage" // 通知先・Webhook・通知のルーティング・ミュート
+	permAdmin     permission = "admin"           // そのほかの管理API（プロジェクト・削除・設定・キーとロールなど）
+)
+
+// rolePermissions : ロールごとに許す権限
+var rolePermissions = map[string][]permission{
+	model.RoleViewer:   {permLogsRead},
+	model.RoleIngester: {permLogsWrite},
+	model.RoleAdmin:    {permLogsRead, permLogsWrite, permChannels, permAdmin},
+}
+
+// projectKeyRoles : プロジェクトのキーのロール（読み書きの両方）
+var projectKeyRoles = []string{model.RoleViewer, model.RoleIngester}
+
+// allows : ロールのどれかが p を許すか
+func allows(roles []string, p permission) bool {
+	return slices.ContainsFunc(roles, func(role string) bool { return slices.Contains(rolePermissions[role], p) })
+}
+
+// dashboardDefaultRolesFromEnv : DASHBOARD_DEFAULT_ROLES（ロールを付けていないユーザーのロール。既定 viewer）
+func dashboardDefaultRolesFromEnv() []string {
+	items := config.List("DASHBOARD_DEFAULT_ROLES")
+	if len(items) == 0 {
+		return []string{model.RoleViewer}
+	}
+	roles, err := model.NormalizeRoles(items)
+	if err != nil {
+		fmt.Printf("Ignoring DASHBOARD_DEFAULT_ROLES: %v\n", err)
+		return []string{model.RoleViewer}
+	}
+	return roles
+}
+
+// roleState : 読み込んだキーとユーザーのロール
+type roleState struct {
+	mu    sync.RWMutex
+	keys  map[string]model.APIKey // キーのハッシュ → キー
+	users map[string][]string     // ユーザー名 → ロール
+}
+
+// hashAPIKey : DBに置くキーのハッシュ（SHA-256 の16進）
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// reloadRoles : キーとユーザーのロールをDBから読み直す
+func (s *Server) reloadRoles(ctx context.Context) error {
+	keys, err := s.store.ListAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+	users, err := s.store.ListUserRoles(ctx)
+	if err != nil {
+		return err
+	}
+	st := &s.roles
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.keys = make(map[string]model.APIKey, len(keys))
+	for _, k := range keys {
+		st.keys[k.KeyHash] = k
+	}
+	st.users = make(map[string][]string, len(users))
+	for _, u := range users {
+		st.users[u.User] = u.Roles
+	}
+	return nil
+}
+
+// reloadRolesAfterChange : 変更をすぐ反映する（失敗しても次の定期読み込みで反映される）
+func (s *Server) reloadRolesAfterChange(ctx context.Context) {
+	if err := s.reloadRoles(ctx); err != nil {
+		fmt.Println("Failed to reload roles:", err)
+	}
+}
+
+// roleKey : ロール付きのAPIキーを引く
+func (s *Server) roleKey(key string) (model.APIKey, bool) {
+	s.roles.mu.RLock()
+	defer s.roles.mu.RUnlock()
+	k, ok := s.roles.keys[hashAPIKey(key)]
+	return k, ok
+}
+
+// keyRoles : リクエストのキーのロール（キーがない・知らないキーなら ok=false）
+func (s *Server) keyRoles(r *http.Request) ([]string, bool) {
+	key := projectKey(r)
+	if key == "" {
+		return nil, false
+	}
+	if k, ok := s.roleKey(key); ok {
+		return k.Roles, true
+	}
+	if _, err := s.projectIDByKey(r.Context(), key); err == nil {
+		return projectKeyRoles, true
+	}
+	return nil, false
+}
+
+// userRoles : ダッシュボードのユーザーのロール（付けていなければ DASHBOARD_DEFAULT_ROLES）
+func (s *Server) userRoles(user string) []string {
+	s.roles.mu.RLock()
+	roles, ok := s.roles.users[user]
+	s.roles.mu.RUnlock()
+	if !ok {
+		return s.cfg.DashboardDefaultRoles
+	}
+	return roles
+}
+
+// principalRoles : キーか、ログインしたユーザーのロール（どちらもなければ ok=false）
+// 管理APIはダッシュボードのログインの外にあるので、ここで Authenticate する
+func (s *Server) principalRoles(r *http.Request) ([]string, bool) {
+	if roles, ok := s.keyRoles(r); ok {
+		return roles, true
+	}
+	if user := auth.User(r.Context()); user != "" {
+		return s.userRoles(user), true
+	}
+	if s.auth != nil {
+		if user, ok := s.auth.Authenticate(r); ok {
+			return s.userRoles(user), true
+		}
+	}
+	return nil, false
+}
+
+// forbidRoles : ロールが p を許さない時の403
+func forbidRoles(w http.ResponseWriter, roles []string, p permission) {
+	http.Error(w, fmt.Sprintf("Forbidden: roles %s do not allow %s", strings.Join(roles, ", "), p), http.StatusForbidden)
+}
+
+// requireKeyPermission : キーを付けたリクエストは、キーのロールが p を許す時だけ通す
+// キーのないリクエストと知らないキーは next に任せる（書き込みはキーがなければ既定のプロジェクト、知らないキーは 401）
+func (s *Server) requireKeyPermission(p permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if roles, ok := s.keyRoles(r); ok && !allows(roles, p) {
+			forbidRoles(w, roles, p)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireUserPermission : ログインしたユーザーのロールが p を許す時だけ通す（auth.Middleware の内側で使う）
+func (s *Server) requireUserPermission(p permission, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := auth.User(r.Context()); user != "" {
+			if roles := s.userRoles(user); !allows(roles, p) {
+				forbidRoles(w, roles, p)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireRole : 管理API用。ADMIN_TOKEN か、ロールが p を許すキー・ユーザーだけを通す
+// ADMIN_TOKEN が未設定なら管理APIは無効
+func (s *Server) requireRole(p permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.AdminToken == "" {
+			http.Error(w, "Forbidden: admin API is disabled (set ADMIN_TOKEN)", http.StatusForbidden)
+			return
+		}
+		if s.hasAdminToken(r) {
+			next(w, r)
+			return
+		}
+		roles, ok := s.principalRoles(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !allows(roles, p) {
+			forbidRoles(w, roles, p)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ==========================================
+// キーとロールの管理API
+// ==========================================
+
+// roleInfo : GET /api/roles の1件
+type roleInfo struct {
+	Role        string       `json:"role"`
+	Permissions []permission `json:"permissions"`
+}
+
+// listRolesHandler : GET /api/roles でロールごとの権限を返す
+func (s *Server) listRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles := make([]roleInfo, len(model.Roles))
+	for i, role := range model.Roles {
+		roles[i] = roleInfo{Role: role, Permissions: rolePermissions[role]}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roles)
+}
+
+// listAPIKeysHandler : GET /api/keys （キーそのものは返さない）
+func (s *Server) listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.store.ListAPIKeys(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// createAPIKeyHandler : POST /api/keys {"name": "ci", "project_id": 2, "roles": ["ingester"]} で発行し、キーを1回だけ返す
+// project_id を省略すると既定のプロジェクト
+func (s *Server) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req model.APIKey
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	roles, err := model.NormalizeRoles(req.Roles)
+	if err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ProjectID == 0 {
+		req.ProjectID = model.DefaultProjectID
+	}
+	projects, err := s.store.ListProjects(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	if !slices.ContainsFunc(projects, func(p model.Project) bool { return p.ID == req.ProjectID }) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	key, err := newAPIKey()
+	if err != nil {
+		http.Error(w, "Failed to generate key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	k := model.APIKey{ProjectID: req.ProjectID, Name: strings.TrimSpace(req.Name), Roles: roles, KeyHash: hashAPIKey(key)}
+	if err := s.store.CreateAPIKey(r.Context(), &k); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadRolesAfterChange(r.Context())
+	fmt.Printf("API key %d created for project %d (roles %s)\n", k.ID, k.ProjectID, strings.Join(k.Roles, ", "))
+
+	k.Key = key
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(k)
+}
+
+// deleteAPIKeyHandler : DELETE /api/keys/{id}（このインスタンスではすぐ、ほかのインスタンスでは次の読み直しから使えなくなる）
+func (s *Server) deleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid key id", http.StatusBadRequest)
+		return
+	}
+	err = s.store.DeleteAPIKey(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadRolesAfterChange(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// usersResponse : GET /api/users の応答
+type usersResponse struct {
+	DefaultRoles []string          `json:"default_roles"` // ロールを付けていないユーザーのロール (DASHBOARD_DEFAULT_ROLES)
+	Users        []model.UserRoles `json:"users"`
+}
+
+// listUserRolesHandler : GET /api/users でロールを付けたユーザーの一覧
+func (s *Server) listUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	users, err := s.store.ListUserRoles(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usersResponse{DefaultRoles: s.cfg.DashboardDefaultRoles, Users: users})
+}
+
+// putUserRolesHandler : PUT /api/users/{user} {"roles": ["viewer", "admin"]} でロールを置き換える
+func (s *Server) putUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	var req model.UserRoles
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	roles, err := model.NormalizeRoles(req.Roles)
+	if err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	u := model.UserRoles{User: r.PathValue("user"), Roles: roles}
+	if err := s.store.PutUserRoles(r.Context(), &u); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadRolesAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(u)
+}
+
+// deleteUserRolesHandler : DELETE /api/users/{user} でロールを外す（DASHBOARD_DEFAULT_ROLES に戻る）
+func (s *Server) deleteUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.store.DeleteUserRoles(r.Context(), r.PathValue("user"))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "User has no roles", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadRolesAfterChange(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 最新ログのキャッシュ (RECENT_CACHE=true。ダッシュボードの定期読み込みで毎回DBを読まない)
+// ==========================================
+
+// recentCache : プロジェクトごとの最新 size 件（新しい順）
+// 保存時に Publish から足し、削除した時は全て捨てる
+// 別のインスタンスや CLI からの書き込みは見えないので、ttl ごとにDBから読み直す
+type recentCache struct {
+	mu       sync.Mutex
+	size     int
+	ttl      time.Duration
+	projects map[int]*recentProject // 捨てる時は枠ごと作り直す（読み込み中に捨てた古い結果を入れないため）
+}
+
+// recentProject : 1プロジェクト分
+type recentProject struct {
+	entries  []model.LogEntry
+	loaded   bool
+	loadedAt time.Time
+	complete bool // size 件より少ない（＝プロジェクトのログを全て持っている）
+	loading  int  // DBから読み込み中の数
+	pending  []model.LogEntry
+}
+
+// recentCacheSizeFromEnv : RECENT_CACHE=true の時だけ RECENT_CACHE_SIZE 件（既定 200）をキャッシュする
+func recentCacheSizeFromEnv() int {
+	if !config.Bool("RECENT_CACHE", false) {
+		return 0
+	}
+	return config.Int("RECENT_CACHE_SIZE", 200)
+}
+
+// newRecentCache : size が 0 なら nil（キャッシュしない）
+func newRecentCache(size int, ttl time.Duration) *recentCache {
+	if size <= 0 {
+		return nil
+	}
+	return &recentCache{size: size, ttl: ttl, projects: map[int]*recentProject{}}
+}
+
+// cacheable : 絞り込みのない最新 size 件以内の読み出しか
+func (c *recentCache) cacheable(f store.LogFilter) bool {
+	return c != nil && f.EventType == "" && f.UID == "" && f.MinLevel == "" && len(f.Fields) == 0 && len(f.Tags) == 0 && f.Query == nil &&
+		f.Search == "" && f.Since.IsZero() && f.Until.IsZero() && f.BeforeID == 0 && f.AfterID == 0 &&
+		f.Sort == "" && !f.Ascending && limitOrDefault(f.Limit) <= c.size
+}
+
+// get : キャッシュにあれば最新 limit 件の複製を返す
+func (c *recentCache) get(projectID, limit int, now time.Time) ([]model.LogEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.projects[projectID]
+	if !ok || !p.loaded {
+		return nil, false
+	}
+	if c.ttl > 0 && now.Sub(p.loadedAt) >= c.ttl {
+		p.loaded, p.entries = false, nil
+		return nil, false
+	}
+	if len(p.entries) < limit && !p.complete {
+		return nil, false
+	}
+	return slices.Clone(p.entries[:min(limit, len(p.entries))]), true
+}
+
+// load : DBから最新 size 件を読み、キャッシュに入れてから limit 件を返す
+// 読み込み中に保存されたログも取りこぼさないよう、その間は pending に貯めて合わせる
+func (c *recentCache) load(ctx context.Context, st store.Store, projectID, limit int, now time.Time) ([]model.LogEntry, error) {
+	c.mu.Lock()
+	p := c.project(projectID)
+	p.loading++
+	c.mu.Unlock()
+
+	rows, err := st.QueryLogs(ctx, store.LogFilter{ProjectID: projectID, Limit: c.size})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p.loading--
+	pending := p.pending
+	if p.loading == 0 {
+		p.pending = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.projects[projectID] != p {
+		// 読み込み中に削除があった（p はもう使われていないので、結果はキャッシュに入れない）
+		return rows[:min(limit, len(rows))], nil
+	}
+	p.complete = len(rows) < c.size
+	p.entries = slices.Clone(rows)
+	p.loaded, p.loadedAt = true, now
+	for _, e := range pending {
+		c.insert(p, e)
+	}
+	return slices.Clone(p.entries[:min(limit, len(p.entries))]), nil
+}
+
+// add : 保存したログを足す（読み込み済みでも読み込み中でもないプロジェクトは無視する）
+func (c *recentCache) add(e *model.LogEntry) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.projects[e.ProjectID]
+	if !ok {
+		return
+	}
+	if p.loaded {
+		c.insert(p, *e)
+	}
+	if p.loading > 0 && len(p.pending) < c.size {
+		p.pending = append(p.pending, *e)
+	}
+}
+
+// insert : ID の新しい順を保って1件入れ、size 件を超えた古いものを落とす
+// バッファから書き戻したログは順番が前後することがある
+func (c *recentCache) insert(p *recentProject, e model.LogEntry) {
+	i, found := slices.BinarySearchFunc(p.entries, e.ID, func(x model.LogEntry, id int) int { return id - x.ID })
+	if found {
+		return
+	}
+	if i >= c.size {
+		return
+	}
+	p.entries = slices.Insert(p.entries, i, e)
+	if len(p.entries) > c.size {
+		p.entries = p.entries[:c.size]
+	}
+}
+
+// invalidate : 全て捨てる（ログを削除した時）
+func (c *recentCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.projects = map[int]*recentProject{}
+	c.mu.Unlock()
+}
+
+// contains : uid のログをキャッシュに持っているか
+func (c *recentCache) contains(projectID int, uid string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.projects[projectID]
+	if !ok || !p.loaded {
+		return false
+	}
+	for _, e := range p.entries {
+		if e.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidateProject : 1プロジェクト分を捨てる（読み込み中のものも結果を入れない）
+func (c *recentCache) invalidateProject(projectID int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.projects, projectID)
+}
+
+// hit : キャッシュにある行の hit_count を更新する（同じアクセスをまとめた時）
+func (c *recentCache) hit(projectID, id, hits int, at time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.projects[projectID]
+	if !ok {
+		return
+	}
+	for i := range p.entries {
+		if p.entries[i].ID == id {
+			p.entries[i].HitCount = hits
+			p.entries[i].LastHitAt = &at
+			return
+		}
+	}
+}
+
+// project : プロジェクトの枠（なければ作る。c.mu を持っている間に呼ぶ）
+func (c *recentCache) project(projectID int) *recentProject {
+	p, ok := c.projects[projectID]
+	if !ok {
+		p = &recentProject{}
+		c.projects[projectID] = p
+	}
+	return p
+}
+
+// queryRecentLogs : 絞り込みのない最新の読み出しはキャッシュから、それ以外はDBから読む
+func (s *Server) queryRecentLogs(ctx context.Context, f store.LogFilter) ([]model.LogEntry, error) {
+	if !s.recent.cacheable(f) {
+		return s.store.QueryLogs(ctx, f)
+	}
+	limit := limitOrDefault(f.Limit)
+	now := s.clock.Now()
+	if logs, ok := s.recent.get(f.ProjectID, limit, now); ok {
+		return logs, nil
+	}
+	return s.recent.load(ctx, s.store, f.ProjectID, limit, now)
+}
+
+// limitOrDefault : QueryLogs と同じく 0 なら50件
+func limitOrDefault(limit int) int {
+	if limit <= 0 {
+		return 50
+	}
+	return limit
+}
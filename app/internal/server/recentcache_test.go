@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// slowStore : QueryLogs を release が閉じられるまで止める
+type slowStore struct {
+	store.Store
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *slowStore) QueryLogs(ctx context.Context, f store.LogFilter) ([]model.LogEntry, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return s.Store.QueryLogs(ctx, f)
+}
+
+// TestRecentCacheInvalidateOtherProject : 2つのプロジェクトを読み込み中に片方を捨てても、もう片方の結果はキャッシュに入る
+func TestRecentCacheInvalidateOtherProject(t *testing.T) {
+	now := time.Now()
+	st := &slowStore{Store: store.NewMemory(clock.System{}, 100), started: make(chan struct{}, 2), release: make(chan struct{})}
+	c := newRecentCache(10, 0)
+
+	done := make(chan error, 2)
+	for _, projectID := range []int{1, 2} {
+		go func() {
+			_, err := c.load(context.Background(), st, projectID, 10, now)
+			done <- err
+		}()
+	}
+	<-st.started
+	<-st.started
+	c.invalidateProject(1)
+	close(st.release)
+	for range 2 {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, ok := c.get(2, 10, now); !ok {
+		t.Error("project 2 was not cached after invalidating project 1")
+	}
+	if p := c.projects[2]; p.loading != 0 || p.pending != nil {
+		t.Errorf("project 2: loading %d, pending %d", p.loading, len(p.pending))
+	}
+}
+
+// TestRecentCacheInvalidateWhileLoading : 読み込み中に捨てたプロジェクトには、古い結果を入れない
+func TestRecentCacheInvalidateWhileLoading(t *testing.T) {
+	now := time.Now()
+	st := &slowStore{Store: store.NewMemory(clock.System{}, 100), started: make(chan struct{}, 1), release: make(chan struct{})}
+	c := newRecentCache(10, 0)
+
+	done := make(chan error)
+	go func() {
+		_, err := c.load(context.Background(), st, 1, 10, now)
+		done <- err
+	}()
+	<-st.started
+	c.invalidateProject(1)
+	close(st.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.get(1, 10, now); ok {
+		t.Error("stale load was cached after invalidating the project")
+	}
+}
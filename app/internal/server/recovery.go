@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-logger/internal/i18n"
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+)
+
+// ==========================================
+// ハンドラの panic の回復
+// ==========================================
+// panic したリクエストにはスタックトレースを出力して 500 を返し、種別 internal のログとして既定のプロジェクトに残す
+// （/api/logs?type=internal で見られる）。NOTIFY_PANICS=true（既定）ならアラートとしても通知する
+
+// internalEventType : サーバー自身のエラーを記録する種別
+const internalEventType = "internal"
+
+// panicStackLimit : 記録するスタックトレースの上限（バイト）
+const panicStackLimit = 16 << 10
+
+var handlerPanicsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "go_logger_http_panics_total",
+	Help: "Handler panics recovered, by route pattern.",
+}, []string{"route"})
+
+// recoverPanics : next の panic を回復して 500 のJSONを返す
+// 接続を切るための http.ErrAbortHandler はそのまま投げ直す
+func (s *Server) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			stack := debug.Stack()
+			route := "unmatched"
+			if p, ok := r.Context().Value(routeKey{}).(*string); ok && *p != "" {
+				route = *p
+			}
+			fmt.Printf("Panic serving %s %s: %v\n%s", r.Method, r.URL.Path, v, stack)
+			handlerPanicsCounter.WithLabelValues(route).Inc()
+
+			entryID := s.recordPanic(r, route, v, stack)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(apiError{Error: i18n.T(w, r, i18n.InternalError), Code: errorCode(http.StatusInternalServerError), Status: http.StatusInternalServerError, EntryID: entryID})
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordPanic : panic を internal のログとして保存し、通知する。保存したログのIDを返す（すぐに保存できなければ0）
+func (s *Server) recordPanic(r *http.Request, route string, v any, stack []byte) int {
+	if len(stack) > panicStackLimit {
+		stack = stack[:panicStackLimit]
+	}
+	fields := map[string]any{
+		"method": r.Method,
+		"route":  route,
+		"stack":  string(stack),
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		fields["trace_id"] = sc.TraceID().String()
+	}
+	b, _ := json.Marshal(fields)
+	lw := model.Write{
+		ProjectID: model.DefaultProjectID,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+		Path:      r.URL.Path,
+		EventType: internalEventType,
+		Level:     "error",
+		Message:   fmt.Sprintf("panic: %v", v),
+		Fields:    b,
+		CreatedAt: s.clock.Now(),
+	}
+	// リクエストの ctx は切れていることがあるので使わない
+	ctx := context.Background()
+	status, stored, _ := s.saveWrite(ctx, &lw)
+	if !acceptedStatus(status) {
+		fmt.Println("Panic was not recorded:", status)
+	}
+	if s.cfg.NotifyPanics {
+		s.notifyAsync(ctx, notify.Notification{
+			Level:  "error",
+			Title:  "💥 Panic in " + route,
+			Text:   fmt.Sprintf("💥 Panic in %s (%s %s): %v", route, r.Method, r.URL.Path, v),
+			Entry:  lw.Entry(),
+			Source: "panic",
+			Key:    "panic:" + route,
+		})
+	}
+	if !stored {
+		return 0
+	}
+	return lw.ID
+}
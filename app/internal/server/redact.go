@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+
+	"go-logger/internal/auth"
+	"go-logger/internal/model"
+	"go-logger/internal/redact"
+)
+
+// ==========================================
+// 項目の表示ルール (REDACT_RULES。出力する直前に必ずここを通す)
+// ==========================================
+
+// requestScope : リクエストの閲覧範囲（管理トークン > ログイン > プロジェクトキー > 認証なし）
+func (s *Server) requestScope(r *http.Request) redact.Scope {
+	if s.isAdmin(r) {
+		return redact.Admin
+	}
+	if auth.User(r.Context()) != "" {
+		return redact.User
+	}
+	if projectKey(r) != "" {
+		if _, err := s.resolveProject(r.Context(), r); err == nil {
+			return redact.Key
+		}
+	}
+	return redact.Public
+}
+
+// highlightFields : 検索の抜粋を作る項目（store の ts_headline・memHighlight と同じ）
+var highlightFields = []string{"message", "path", "user_agent"}
+
+// redactSearchResults : 検索結果の項目を隠す（抜粋の元になる項目のどれかが見えない範囲なら、抜粋も消す）
+func (s *Server) redactSearchResults(results []model.SearchResult, scope redact.Scope) {
+	hideHighlight := slices.ContainsFunc(highlightFields, func(field string) bool { return s.redact.Hidden(field, scope) })
+	for i := range results {
+		results[i].LogEntry = s.redact.Entry(results[i].LogEntry, scope)
+		if hideHighlight {
+			results[i].Highlight = ""
+		}
+	}
+}
+
+// notifyScope : 通知・チャットコマンドの返答に使う範囲
+func (s *Server) notifyScope() redact.Scope {
+	if s.redact == nil {
+		return redact.Admin
+	}
+	return s.redact.NotifyScope
+}
+
+// exportScope : Kafka / NATS・アーカイブ・スナップショットに使う範囲
+func (s *Server) exportScope() redact.Scope {
+	if s.redact == nil {
+		return redact.Admin
+	}
+	return s.redact.ExportScope
+}
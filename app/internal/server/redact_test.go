@@ -0,0 +1,59 @@
+package server
+
+import (
+	"testing"
+
+	"go-logger/internal/model"
+	"go-logger/internal/redact"
+)
+
+// TestRedactSearchResults : 抜粋の元（本文・パス・User-Agent）のどれかが見えない範囲には抜粋を返さない
+func TestRedactSearchResults(t *testing.T) {
+	tests := []struct {
+		rules         string
+		scope         redact.Scope
+		wantHighlight bool
+	}{
+		{rules: "", scope: redact.Public, wantHighlight: true},
+		{rules: "ip=admin", scope: redact.Public, wantHighlight: true},
+		{rules: "message=user", scope: redact.Public, wantHighlight: false},
+		{rules: "message=user", scope: redact.User, wantHighlight: true},
+		{rules: "path=key", scope: redact.Public, wantHighlight: false},
+		{rules: "path=key", scope: redact.Key, wantHighlight: true},
+		{rules: "user_agent=admin", scope: redact.User, wantHighlight: false},
+		{rules: "user_agent=admin", scope: redact.Admin, wantHighlight: true},
+		{rules: "fields.email=admin", scope: redact.Public, wantHighlight: true},
+	}
+	for _, tt := range tests {
+		p, err := redact.Parse(tt.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &Server{redact: p}
+		results := []model.SearchResult{{
+			LogEntry:  model.LogEntry{Message: "timeout", Path: "/checkout", UserAgent: "curl/8.0"},
+			Highlight: "<mark>timeout</mark> | /checkout | curl/8.0",
+		}}
+		s.redactSearchResults(results, tt.scope)
+		if got := results[0].Highlight != ""; got != tt.wantHighlight {
+			t.Errorf("rules %q at %s: highlight %q, want kept=%v", tt.rules, tt.scope, results[0].Highlight, tt.wantHighlight)
+		}
+	}
+}
+
+// TestOutputScopes : ルールがなければ通知・エクスポートは全て見え、あれば設定した範囲を使う
+func TestOutputScopes(t *testing.T) {
+	s := &Server{}
+	if s.notifyScope() != redact.Admin || s.exportScope() != redact.Admin {
+		t.Errorf("without rules: notify %s, export %s", s.notifyScope(), s.exportScope())
+	}
+	p, err := redact.Parse("ip=admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.NotifyScope, p.ExportScope = redact.Key, redact.User
+	s.redact = p
+	if s.notifyScope() != redact.Key || s.exportScope() != redact.User {
+		t.Errorf("with rules: notify %s, export %s", s.notifyScope(), s.exportScope())
+	}
+}
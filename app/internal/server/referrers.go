@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 参照元の集計 (どこからアクセスが来ているか)
+// ==========================================
+
+// referrerStats : GET /api/stats/referrers の結果
+type referrerStats struct {
+	Since   time.Time      `json:"since"`
+	Until   *time.Time     `json:"until,omitempty"`
+	Direct  int            `json:"direct"`  // 参照元のないアクセス（直接の訪問・ブックマークなど）
+	Domains []model.Bucket `json:"domains"` // 参照元のホスト名（www. を除く）
+	Pages   []model.Bucket `json:"pages"`   // 参照元のページ（クエリ・フラグメントを除く）
+}
+
+// referrersHandler : GET /api/stats/referrers?period=7d&type=&limit=20
+// ?since=&until= (RFC3339) でも期間を指定できる。?level= や ?field.x= の絞り込みは /api/logs と同じ
+func (s *Server) referrersHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		loc, ok := s.responseLocation(w, r)
+		if !ok {
+			return
+		}
+		format, ok := negotiateFormat(w, r)
+		if !ok {
+			return
+		}
+		f, err := logFilterFromQuery(r.URL.Query(), projectID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Extrapolate = s.extrapolate(r)
+		if f.Since, f.Until, err = timeRangeFromQuery(r.URL.Query(), s.clock.Now(), 7*24*time.Hour); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit := 20
+		if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 200 {
+			limit = v
+		}
+		// 参照元のないアクセスの分も1件あるので、1件多く取って外す
+		f.Limit = limit + 1
+
+		result := referrerStats{Since: f.Since}
+		if !f.Until.IsZero() {
+			result.Until = &f.Until
+		}
+		domains, err := s.store.GroupLogs(r.Context(), f, "REFERRER_DOMAIN")
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+		result.Domains, result.Direct = withoutDirect(domains, limit)
+		pages, err := s.store.GroupLogs(r.Context(), f, "REFERRER")
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+		result.Pages, _ = withoutDirect(pages, limit)
+		inLocation(&result, loc)
+
+		writeFormatted(w, format, "referrers", result)
+	})
+}
+
+// withoutDirect : 参照元のない分 (key が "") を外して件数を返し、残りを limit 件にする
+func withoutDirect(buckets []model.Bucket, limit int) ([]model.Bucket, int) {
+	direct := 0
+	out := make([]model.Bucket, 0, len(buckets))
+	for _, b := range buckets {
+		if b.Key == "" {
+			direct = b.Count
+			continue
+		}
+		out = append(out, b)
+	}
+	return out[:min(limit, len(out))], direct
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-logger/internal/notify"
+)
+
+// ==========================================
+// 設定の読み直し (SIGHUP)
+// ==========================================
+// 待ち受け・DBの接続・送信待ちの通知はそのままで、次の設定だけを新しい値に入れ替える
+//
+//	環境変数の通知先 (DISCORD_WEBHOOK_URL など)・通知のルール (NOTIFY_RULES)
+//	接続元ごとの上限 (INGEST_RATE_LIMIT)・送り元ごとの通知の上限 (NOTIFY_THROTTLE)・ボットの扱い (EXCLUDE_BOTS)
+//	保存日数・抽出率・日次のまとめの時刻（/api/settings で上書きしたものはそのまま）
+//	DBで管理する通知先・ルール・除外パターン・IPルール・監視リスト（次の定期読み込みを待たずに読み直す）
+//
+// ほかの設定（ポート・DB・キューなど）は再起動するまで変わらない
+
+// notifierRef : 差し替える通知先の入れ物（atomic.Pointer はインターフェースを直接持てないため）
+type notifierRef struct {
+	notify.Notifier
+}
+
+// envNotifier : 環境変数の通知先
+func (s *Server) envNotifier() notify.Notifier {
+	return s.notifier.Load().Notifier
+}
+
+// Reload : cfg（ConfigFromEnv で読み直したもの）と通知先を反映する
+func (s *Server) Reload(ctx context.Context, cfg Config, notifier notify.Notifier) error {
+	if cfg.NotifyRules == nil {
+		cfg.NotifyRules = notify.ParseRules(notify.DefaultRules)
+	}
+	base := tunablesFromConfig(cfg)
+	s.envTunables.Store(&base)
+	if notifier != nil {
+		s.notifier.Store(&notifierRef{notifier})
+	}
+
+	// 設定の上書き (/api/settings) は新しい環境変数の値の上に当て直す
+	last := s.reloadResources(ctx)
+	if err := s.reloadChannels(ctx); err != nil {
+		fmt.Println("Failed to load notification channels:", err)
+		last = err
+	}
+	if last != nil {
+		// 読み直せなかった分は前の値のまま。環境変数の値だけは反映しておく
+		t := *s.tunables()
+		t.NotifyRules, t.IngestRateLimit, t.NotifyThrottles, t.ExcludeBots = base.NotifyRules, base.IngestRateLimit, base.NotifyThrottles, base.ExcludeBots
+		s.settings.Store(&t)
+	}
+
+	names := "none"
+	if m, ok := notifier.(*notify.Multi); ok && m.Len() > 0 {
+		names = strings.Join(m.Names(), ", ")
+	}
+	limit := "off"
+	if base.IngestRateLimit.Enabled() {
+		limit = base.IngestRateLimit.String()
+	}
+	fmt.Printf("Configuration reloaded: notifiers=%s, ingest rate limit=%s, notify throttle=%s, exclude bots=%s\n", names, limit, base.NotifyThrottles, base.ExcludeBots)
+	return last
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+	"go-logger/internal/tracing"
+)
+
+// ==========================================
+// アクセス数のカウンターと Prometheus remote-write 送信
+// (Mimir / VictoriaMetrics などにスクレイプなしで送る)
+// ==========================================
+
+// hitsCounter : 受け付けた書き込みの数（保存・バッファ済み）
+var hitsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "go_logger_hits_total",
+	Help: "Accepted events per project and event type.",
+}, []string{"project", "event_type"})
+
+// countHit : 書き込みを受け付けた時に呼ぶ
+func countHit(w *model.Write) {
+	hitsCounter.WithLabelValues(strconv.Itoa(w.ProjectID), w.EventType).Inc()
+}
+
+// RemoteWriteConfig : REMOTE_WRITE_* の設定
+type RemoteWriteConfig struct {
+	url      string
+	metrics  *regexp.Regexp    // 送るメトリクス名
+	labels   map[string]string // 全ての系列に付けるラベル
+	username string
+	password string
+	bearer   string
+}
+
+// RemoteWriteFromEnv : REMOTE_WRITE_URL が未設定なら nil（送らない）
+//
+//	REMOTE_WRITE_URL              例: http://mimir:9009/api/v1/push
+//	REMOTE_WRITE_INTERVAL         送信間隔（既定 30s）
+//	REMOTE_WRITE_METRICS          送るメトリクス名の正規表現（既定 ^go_logger_hits_total$）
+//	REMOTE_WRITE_LABELS           追加ラベル（例: instance=tokyo,env=prod）
+//	REMOTE_WRITE_USERNAME / _PASSWORD  Basic認証
+//	REMOTE_WRITE_BEARER_TOKEN     Bearerトークン
+func RemoteWriteFromEnv() *RemoteWriteConfig {
+	cfg := &RemoteWriteConfig{
+		url:      config.String("REMOTE_WRITE_URL", ""),
+		labels:   map[string]string{},
+		username: config.String("REMOTE_WRITE_USERNAME", ""),
+		password: config.String("REMOTE_WRITE_PASSWORD", ""),
+		bearer:   config.String("REMOTE_WRITE_BEARER_TOKEN", ""),
+	}
+	if cfg.url == "" {
+		return nil
+	}
+	pattern := config.String("REMOTE_WRITE_METRICS", "^go_logger_hits_total$")
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		fmt.Printf("Invalid REMOTE_WRITE_METRICS %q: %v\n", pattern, err)
+		return nil
+	}
+	cfg.metrics = re
+	for _, item := range config.List("REMOTE_WRITE_LABELS") {
+		if name, value, ok := strings.Cut(item, "="); ok {
+			cfg.labels[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return cfg
+}
+
+// remoteWriteClient : 送信用（タイムアウトは送信ごとに付ける）
+var remoteWriteClient = tracing.HTTPClient(&http.Client{})
+
+// watchRemoteWrite : 定期的に現在の値を送る（設定がなければ何もしない）
+func (s *Server) watchRemoteWrite(ctx context.Context) {
+	cfg := s.cfg.RemoteWrite
+	if cfg == nil {
+		return
+	}
+	ticker := s.clock.NewTicker(s.cfg.RemoteWriteInterval)
+	defer ticker.Stop()
+	j := s.jobs.register("remote_write", s.cfg.RemoteWriteInterval).leaderOnly()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		s.runJob(j, func() error {
+			err := s.pushRemoteWrite(ctx, cfg)
+			if err != nil {
+				fmt.Println("Remote write failed:", err)
+			}
+			return err
+		})
+	}
+}
+
+// pushRemoteWrite : 登録済みのメトリクスを集めて1回送る
+func (s *Server) pushRemoteWrite(ctx context.Context, cfg *RemoteWriteConfig) error {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return err
+	}
+	body := encodeWriteRequest(families, cfg, s.clock.Now().UnixMilli())
+	if len(body) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", cfg.url, bytes.NewReader(snappy.Encode(nil, body)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	switch {
+	case cfg.bearer != "":
+		req.Header.Set("Authorization", "Bearer "+cfg.bearer)
+	case cfg.username != "":
+		req.SetBasicAuth(cfg.username, cfg.password)
+	}
+
+	resp, err := remoteWriteClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// encodeWriteRequest : prometheus.WriteRequest を protobuf にする
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(families []*dto.MetricFamily, cfg *RemoteWriteConfig, timestampMS int64) []byte {
+	var out []byte
+	for _, mf := range families {
+		if !cfg.metrics.MatchString(mf.GetName()) {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			var value float64
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue // ヒストグラムなどは送らない
+			}
+
+			labels := map[string]string{"__name__": mf.GetName()}
+			for k, v := range cfg.labels {
+				labels[k] = v
+			}
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			// remote-write ではラベルを名前順に並べる必要がある
+			names := make([]string, 0, len(labels))
+			for name := range labels {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			var series []byte
+			for _, name := range names {
+				var label []byte
+				label = protowire.AppendTag(label, 1, protowire.BytesType)
+				label = protowire.AppendString(label, name)
+				label = protowire.AppendTag(label, 2, protowire.BytesType)
+				label = protowire.AppendString(label, labels[name])
+				series = protowire.AppendTag(series, 1, protowire.BytesType)
+				series = protowire.AppendBytes(series, label)
+			}
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+			sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(timestampMS))
+			series = protowire.AppendTag(series, 2, protowire.BytesType)
+			series = protowire.AppendBytes(series, sample)
+
+			out = protowire.AppendTag(out, 1, protowire.BytesType)
+			out = protowire.AppendBytes(out, series)
+		}
+	}
+	return out
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 保存期間 (全体の保存日数とログごとの有効期限)
+// ==========================================
+
+// parseTTL : "ttl" の値（"90s" "12h" "7d" のような文字列か秒数）
+func parseTTL(raw json.RawMessage) (time.Duration, error) {
+	var seconds json.Number
+	if err := json.Unmarshal(raw, &seconds); err == nil {
+		if n, err := seconds.Float64(); err == nil && n > 0 {
+			return time.Duration(n * float64(time.Second)), nil
+		}
+		return 0, fmt.Errorf(`"ttl" must be positive`)
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return 0, fmt.Errorf(`"ttl" must be a duration string or a number of seconds`)
+	}
+	ttl, err := parseDurationDays(s)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf(`invalid "ttl" %q (use e.g. "30m", "12h" or "7d")`, s)
+	}
+	return ttl, nil
+}
+
+// parseDurationDays : time.ParseDuration に日数 ("7d") を加えたもの
+func parseDurationDays(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// EventTTLs : イベント種別ごとの既定の有効期限
+// EVENT_TTL="ping=24h,link_click=90d" の形式（本文で ttl を指定した場合はそちらが優先）
+type EventTTLs map[string]time.Duration
+
+// EventTTLsFromEnv : 環境変数から読み込む
+func EventTTLsFromEnv() EventTTLs {
+	ttls := EventTTLs{}
+	for _, item := range config.List("EVENT_TTL") {
+		eventType, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		ttl, err := parseDurationDays(strings.TrimSpace(value))
+		if err != nil || ttl <= 0 {
+			fmt.Printf("Ignoring EVENT_TTL entry %q\n", item)
+			continue
+		}
+		ttls[strings.TrimSpace(eventType)] = ttl
+	}
+	return ttls
+}
+
+// expiresAt : 種別の既定の有効期限（設定がなければゼロ）
+func (ttls EventTTLs) expiresAt(eventType string, createdAt time.Time) time.Time {
+	if ttl, ok := ttls[eventType]; ok {
+		return createdAt.Add(ttl)
+	}
+	return time.Time{}
+}
+
+// parseRetentionTiers : /api/settings の retention_tiers（JSON の配列）を読み、具体的なものから並べ替える
+// 同じプロジェクト・種別を2回指定したらエラーにする
+func parseRetentionTiers(value string) ([]model.RetentionTier, error) {
+	var tiers []model.RetentionTier
+	if err := json.Unmarshal([]byte(value), &tiers); err != nil {
+		return nil, fmt.Errorf(`must be a list of {"project_id", "event_type", "days"}: %w`, err)
+	}
+	seen := map[model.RetentionTier]bool{}
+	for i, t := range tiers {
+		if t.ProjectID < 0 || t.Days < 0 {
+			return nil, fmt.Errorf("tier %d: project_id and days must not be negative", i)
+		}
+		if t.ProjectID == 0 && t.EventType == "" {
+			return nil, fmt.Errorf("tier %d: set project_id or event_type (use retention_days for everything else)", i)
+		}
+		if _, ok := model.EventTypePrefix(t.EventType); ok && strings.Count(t.EventType, "*") > 1 {
+			return nil, fmt.Errorf("tier %d: event_type %q may only end with *", i, t.EventType)
+		}
+		key := model.RetentionTier{ProjectID: t.ProjectID, EventType: t.EventType}
+		if seen[key] {
+			return nil, fmt.Errorf("tier %d: duplicate project_id %d / event_type %q", i, t.ProjectID, t.EventType)
+		}
+		seen[key] = true
+	}
+	slices.SortStableFunc(tiers, func(a, b model.RetentionTier) int {
+		return cmp.Compare(tierSpecificity(b), tierSpecificity(a))
+	})
+	return tiers, nil
+}
+
+// tierSpecificity : 当てはまるものが複数ある時の優先度（プロジェクトの指定 → 種別の完全一致 → 前方一致）
+func tierSpecificity(t model.RetentionTier) int {
+	n := 0
+	if t.ProjectID != 0 {
+		n += 4
+	}
+	if prefix, ok := model.EventTypePrefix(t.EventType); ok {
+		n += 1 + min(len(prefix), 1)
+	} else if t.EventType != "" {
+		n += 3
+	}
+	return n
+}
+
+// retentionPolicy : 今の保存日数から、PurgeExpired に渡す境目を作る（olderThan は tiers に当てはまらないログの境目）
+func (s *Server) retentionPolicy(now, olderThan time.Time) store.RetentionPolicy {
+	policy := store.RetentionPolicy{OlderThan: olderThan}
+	for _, t := range s.tunables().RetentionTiers {
+		tier := store.RetentionTier{ProjectID: t.ProjectID, EventType: t.EventType}
+		if t.Days > 0 {
+			tier.OlderThan = now.AddDate(0, 0, -t.Days)
+		}
+		policy.Tiers = append(policy.Tiers, tier)
+	}
+	return policy
+}
+
+// watchRetention : 定期的に期限切れのログを削除する
+//
+//	RETENTION_DAYS      全体の保存日数（0 なら期限付きのログだけ削除。/api/settings の retention_days で上書きできる）
+//	retention_tiers     /api/settings で設定するプロジェクト・種別ごとの保存日数（例: error は1年、access は30日）
+//	RETENTION_INTERVAL  実行間隔（既定 1h）
+//	ARCHIVE_FORMAT      ndjson / parquet を指定すると、削除する前に ARCHIVE_S3_BUCKET へ書き出す
+func (s *Server) watchRetention(ctx context.Context) {
+	if s.cfg.DryRun {
+		fmt.Println("[dry-run] retention purge is disabled")
+		return
+	}
+	ticker := s.clock.NewTicker(s.cfg.RetentionInterval)
+	defer ticker.Stop()
+	j := s.jobs.register("retention", s.cfg.RetentionInterval).leaderOnly()
+
+	for {
+		s.runJob(j, func() error {
+			n, err := s.purgeExpired(ctx)
+			if err != nil {
+				fmt.Println("Retention purge failed:", err)
+			} else if n > 0 {
+				fmt.Printf("Retention purge removed %d events\n", n)
+			}
+			return err
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// watchPartitions : 起動時と1日ごとに、先の月のパーティションを作っておく
+//
+//	PARTITION_MONTHS_AHEAD  今月から何か月先まで作るか（既定 3）
+func (s *Server) watchPartitions(ctx context.Context) {
+	if s.cfg.DryRun {
+		return
+	}
+	ticker := s.clock.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	j := s.jobs.register("partitions", 24*time.Hour).leaderOnly()
+
+	for {
+		s.runJob(j, func() error {
+			created, err := s.store.EnsurePartitions(ctx, s.clock.Now(), s.cfg.PartitionMonthsAhead)
+			for _, name := range created {
+				fmt.Println("Created partition", name)
+			}
+			if err != nil {
+				fmt.Println("Creating partitions failed:", err)
+			}
+			return err
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// purgeExpired : 有効期限を過ぎたログと保存日数を過ぎたログを削除する
+func (s *Server) purgeExpired(ctx context.Context) (int64, error) {
+	// 期限を過ぎた Idempotency-Key も片付ける（失敗してもログの削除は続ける）
+	if _, err := s.store.PurgeIdempotencyKeys(ctx, s.clock.Now()); err != nil {
+		fmt.Println("Purging idempotency keys failed:", err)
+	}
+	if _, err := s.store.PurgeNotificationKeys(ctx, s.clock.Now()); err != nil {
+		fmt.Println("Purging notification keys failed:", err)
+	}
+	var olderThan time.Time
+	if days := s.tunables().RetentionDays; days > 0 {
+		olderThan = s.clock.Now().AddDate(0, 0, -days)
+	}
+	return s.Purge(ctx, olderThan)
+}
+
+// Purge : 有効期限を過ぎたログと olderThan より前のログを削除する（olderThan がゼロなら期限付きのログだけ）
+// retention_tiers に当てはまるログはそちらの保存日数に従う
+// ARCHIVE_FORMAT を設定した場合は、バケットへ書き出せたバッチだけを削除する（`main purge` からも使う）
+func (s *Server) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	now := s.clock.Now()
+	policy := s.retentionPolicy(now, olderThan)
+	var n int64
+	var err error
+	if s.archiver != nil {
+		// 書き出して削除し終えた月のパーティションは空になっているので、ここで片付ける
+		if n, err = s.archiveExpired(ctx, now, policy); err == nil {
+			_, err = s.store.DropPartitionsBefore(ctx, policy.PartitionCutoff())
+		}
+	} else {
+		n, err = s.store.PurgeExpired(ctx, now, policy)
+	}
+	if n > 0 {
+		s.recent.invalidate()
+	}
+	return n, err
+}
+
+// archiveExpired : 削除対象を ARCHIVE_BATCH_SIZE 件ずつ書き出してから削除する
+// 書き出しに失敗したバッチは残し、次の実行で書き出し直す
+func (s *Server) archiveExpired(ctx context.Context, now time.Time, policy store.RetentionPolicy) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		entries, err := s.store.ExpiredLogs(ctx, now, policy, max(s.cfg.ArchiveBatchSize, 1))
+		if err != nil {
+			return total, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		ids := make([]int, len(entries))
+		for i, e := range entries {
+			ids[i] = e.ID
+		}
+		m, err := s.archiver.Archive(ctx, "access_logs", s.redact.Entries(entries, s.exportScope()), s.clock.Now())
+		if err != nil {
+			return total, fmt.Errorf("archive: %w", err)
+		}
+		n, err := s.store.DeleteLogs(ctx, ids)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		fmt.Printf("Archived %d events to %s\n", m.Rows, m.Object)
+		if len(entries) < s.cfg.ArchiveBatchSize {
+			break
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ==========================================
+// 集計済みの件数の更新 (ROLLUP_INTERVAL を設定した場合のみ)
+// ==========================================
+
+// rollupLookback : 毎回作り直す直前の時間（バッファからの書き戻しなど、遅れて届いたログを拾う）
+const rollupLookback = 2 * time.Hour
+
+// rollupChunk : 初回に過去の分を集計する時の1回の幅（長いトランザクションを避ける）
+const rollupChunk = 7 * 24 * time.Hour
+
+// watchRollups : 起動時と ROLLUP_INTERVAL ごとに、前回の続きから直前の時間までを集計する
+func (s *Server) watchRollups(ctx context.Context) {
+	if s.cfg.RollupInterval <= 0 || s.cfg.DryRun {
+		return
+	}
+	ticker := s.clock.NewTicker(s.cfg.RollupInterval)
+	defer ticker.Stop()
+	j := s.jobs.register("rollups", s.cfg.RollupInterval).leaderOnly()
+
+	for {
+		s.runJob(j, func() error {
+			err := s.rollUp(ctx)
+			if err != nil {
+				fmt.Println("Rollup failed:", err)
+			}
+			return err
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// rollUp : 集計済みの時刻の少し前（初回はいちばん古いログ）から、今の時間の初めまでを集計する
+// 今の時間は書き込みが続くので集計せず、読み出し側が生のログから数える
+func (s *Server) rollUp(ctx context.Context) error {
+	now := s.clock.Now().UTC()
+	to := now.Truncate(time.Hour)
+	from, err := s.store.RollupWatermark(ctx)
+	if err != nil {
+		return err
+	}
+	if from.IsZero() {
+		if from, err = s.store.OldestLogTime(ctx); err != nil || from.IsZero() {
+			return err
+		}
+	} else {
+		from = from.Add(-rollupLookback)
+	}
+	// 保存期間を過ぎて削除した時間を作り直すと件数が消えてしまうので、そこより前は触らない
+	if days := s.tunables().RetentionDays; days > 0 {
+		if cutoff := now.AddDate(0, 0, -days).Truncate(time.Hour).Add(time.Hour); from.Before(cutoff) {
+			from = cutoff
+		}
+	}
+
+	for start := from.Truncate(time.Hour); start.Before(to); start = start.Add(rollupChunk) {
+		end := start.Add(rollupChunk)
+		if end.After(to) {
+			end = to
+		}
+		if err := s.store.RollUp(ctx, start, end); err != nil {
+			return fmt.Errorf("%s - %s: %w", start.Format(time.RFC3339), end.Format(time.RFC3339), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// アクセスの間引き (SAMPLE_RATE)
+// ==========================================
+// アクセスの多いサイトでは、記録対象パスへのアクセスを SAMPLE_RATE（/api/settings の sample_rate で上書きできる）の割合だけ保存する
+// 保存した行には抽出率を残し、集計 (?extrapolate=true) では 1 / 抽出率 件として数える
+// 構造化ログ (POST /api/logs) はエラーの報告なので間引かない
+
+// sampleRateFromEnv : SAMPLE_RATE（0より大きく1以下。既定1で間引かない）
+func sampleRateFromEnv() float64 {
+	rate := config.Float64("SAMPLE_RATE", 1)
+	if rate <= 0 || rate > 1 {
+		fmt.Printf("Ignoring SAMPLE_RATE %v (use a value in (0, 1])\n", rate)
+		return 1
+	}
+	return rate
+}
+
+// sampledOut : 抽出率 rate でこのアクセスを保存せずに捨てるか
+func sampledOut(rate float64) bool {
+	return rate < 1 && rand.Float64() >= rate
+}
+
+// extrapolate : 集計の件数を抽出率で割り戻すか（?extrapolate=true / false。既定は間引いている間だけ割り戻す）
+func (s *Server) extrapolate(r *http.Request) bool {
+	if v, err := strconv.ParseBool(r.URL.Query().Get("extrapolate")); err == nil {
+		return v
+	}
+	return s.tunables().SampleRate < 1
+}
@@ -0,0 +1,697 @@
+// Package server : HTTPハンドラと定期処理
+// 保存先・通知先・エンリッチメント・ID生成・時計は Deps で受け取るので、
+// テストでは偽の Store や Notifier を渡してハンドラを直接呼べる
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"go-logger/internal/archive"
+	"go-logger/internal/auth"
+	"go-logger/internal/clock"
+	"go-logger/internal/config"
+	"go-logger/internal/coord"
+	"go-logger/internal/enrich"
+	"go-logger/internal/faults"
+	"go-logger/internal/idgen"
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/queue"
+	"go-logger/internal/redact"
+	"go-logger/internal/store"
+	"go-logger/internal/stream"
+	"go-logger/internal/tracing"
+)
+
+// Config : 起動時に読み込む設定（実行中に環境変数は読まない）
+type Config struct {
+	Addr                  string   // 待ち受けアドレス
+	BasePath              string   // URLの接頭辞（"/go" など。空ならルート直下）
+	StaticDir             string   // ダッシュボードの静的ファイルをディスクから配信する場所（空なら実行ファイルに埋め込んだもの）
+	AdminToken            string   // 管理API用（空なら管理APIは無効）
+	DashboardDefaultRoles []string // ロールを付けていないダッシュボードのユーザーのロール
+	RequireProjectKey     bool     // キーなしの書き込み・読み出しを拒否する
+	UptimeIngestToken     string
+	PublicBaseURL         string   // 外部から見たURL（通知のリンク・QRコード用）
+	DryRun                bool     // 保存・通知の代わりに標準出力へ出す（設定の確認用）
+	Features              Features // 個別に止めた機能 (FEATURE_*)
+
+	LogBatchMax     int             // POST /api/logs/batch で1回に受け付ける件数の上限
+	IngestBatchSize int             // Redis Stream / NATS から1回で読んで保存する件数
+	IdempotencyTTL  time.Duration   // Idempotency-Key を覚えておく時間（0 なら使わない）
+	SampleRate      float64         // 記録対象パスへのアクセスを保存する割合（1 なら全て）
+	IngestRateLimit coord.Limit     // 接続元のIPごとの書き込みの上限（ゼロ値なら制限しない）
+	NotifyThrottles NotifyThrottles // IP・UA・プロジェクトごとの新しいログの通知の上限（NOTIFY_THROTTLE。空なら制限しない）
+
+	NotifyDedup       string        // 通知を1回だけ送る印の置き場所（coord / database）
+	NotifyDedupWindow time.Duration // 同じ Key の通知を全てのインスタンスで合わせて1回だけ送る期間（0 なら Key ごとには抑えない）
+
+	UsageFlushInterval time.Duration // キーごとの利用量を key_usage に書く間隔（0 なら数えず、上限も使わない）
+
+	AnonymizeIP         bool          // プロジェクトで指定がなければ IP を切り詰めて保存する
+	HashUserAgent       bool          // プロジェクトで指定がなければ UA をハッシュにして保存する
+	PrivacySecret       string        // UA のハッシュのソルトを作る秘密の値
+	PrivacySaltRotation time.Duration // ソルトを変える間隔（0 なら変えない）
+
+	NotifyQueueSize int // 通知の送信待ちの上限
+	NotifyWorkers   int // 通知を送る並列数
+
+	NotifyMaxAttempts  int           // 送れなかった通知を dead letter にするまでの回数
+	NotifyRetryBackoff time.Duration // 1回目の再送までの間隔（以降は倍ずつ空ける）
+	NotifyPanics       bool          // ハンドラの panic をアラートとして通知するか
+
+	WriteMethods      []string      // 記録対象パスで受け付けるメソッド
+	MaxBodyBytes      int64         // リクエスト本文の上限（0 なら制限しない）
+	MaxHeaderBytes    int           // リクエストヘッダーの上限 (http.Server に渡す)
+	HandlerTimeout    time.Duration // ハンドラの処理時間の上限（0 なら制限しない）
+	DebugEndpoints    bool          // 管理APIに pprof と expvar (/api/admin/debug/...) を出すか
+	SyntheticTraffic  bool          // 管理APIに合成トラフィックを流す POST /api/admin/generate を出すか
+	DebugAddr         string        // pprof と expvar を認証なしで出す別のアドレス（空なら出さない）
+	CompressResponses bool          // Accept-Encoding に合わせて応答を gzip / deflate で圧縮するか
+	AccessLog         string        // このサーバーへのアクセスの出力（off / text / json）
+	AccessLogSkip     []string      // このパスで始まるアクセスは出力しない
+
+	RecentCacheSize int           // /api/logs の最新ログをプロジェクトごとに何件キャッシュするか（0 ならキャッシュしない）
+	RecentCacheTTL  time.Duration // キャッシュをDBから読み直す間隔（他のインスタンスの書き込みを拾うため）
+
+	ReadAfterWrite        bool          // 書き込みにトークンを返し、?after= の読み出しで反映を待つ
+	ReadAfterWriteTimeout time.Duration // 反映を待つ上限
+
+	CollapseWindow time.Duration // 同じIP・UAの同じアクセスをこの間隔内なら1行にまとめる（0 ならまとめない）
+	SessionGap     time.Duration // 同じ訪問者のアクセスがこれ以上空いたら別のセッションとして数える
+
+	VisitorCookie       bool          // 書き込みで匿名の訪問者IDのクッキーを発行・読み取りし、visitor_id として保存する
+	VisitorCookieName   string        // クッキーの名前
+	VisitorCookieMaxAge time.Duration // クッキーの有効期間
+
+	CaptureHeaders []string // ログの headers に記録するリクエストヘッダー（CAPTURE_HEADERS。空なら記録しない）
+
+	TrackedPaths []TrackedPath
+	NotifyRules  notify.Rules
+	EventTTLs    EventTTLs
+	ExcludeBots  string // UAから判定したボットの扱い（off / notify / all）
+
+	WatchlistCooldown time.Duration // 同じ監視リスト・同じIPアドレスの通知の間隔
+	WatchlistHistory  int           // 監視リストの通知に載せる、そのIPアドレスの直近のログの件数
+
+	RetentionDays      int
+	RetentionInterval  time.Duration
+	TrashRetentionDays int // DELETE /api/logs/{id} でゴミ箱に移したログを何日後に本当に削除するか（0 なら削除しない）
+
+	ExportDir        string        // POST /api/exports のファイルを書く場所（EXPORT_S3_BUCKET があれば書いた後でバケットへ上げる）
+	ExportTTL        time.Duration // 書き出したファイルと記録を残す時間
+	ExportBatch      int           // 書き出しで1回に読む件数
+	ArchiveBatchSize int           // 削除の前にバケットへ書き出す1ファイルあたりの件数
+
+	PartitionMonthsAhead int // access_logs の月のパーティションを何か月先まで作っておくか
+
+	RollupInterval time.Duration // 時間ごと・日ごとの集計済みの件数を更新する間隔（0 なら更新しない）
+
+	VolumeSampleInterval   time.Duration
+	VolumeAlertMaxBytes    int64
+	VolumeAlertGrowthBytes int64
+
+	Peers        []Peer
+	PeerAPIKey   string
+	PeerTimeout  time.Duration
+	InstanceName string
+
+	RemoteWrite         *RemoteWriteConfig // nil なら送らない
+	RemoteWriteInterval time.Duration
+
+	Anomaly   AnomalyConfig
+	Trends    TrendConfig
+	Digest    DigestConfig
+	Incidents IncidentConfig
+
+	Location *time.Location // ?tz のない API の応答とレポートのタイムゾーン (TIMEZONE、既定 UTC)
+
+	WebhookBatchSize     int           // Webhook に1回で送る最大の件数
+	WebhookBatchInterval time.Duration // Webhook に送るまでためる時間
+	WebhookMaxFailures   int           // 続けてこの回数届かなかった Webhook を止める
+	WebhookTimeout       time.Duration // 1回の送信の待ち時間の上限
+	WebhookQueueSize     int           // 送信待ちのログの上限（超えた分は捨てる）
+
+	ChannelReloadInterval time.Duration // DBの通知先を読み直す間隔
+	LeaderCheckInterval   time.Duration // リーダーを確かめ直す間隔（リーダーが落ちてから引き継ぐまでの時間）
+	RuleEvalInterval      time.Duration // アラートルールを読み直し、件数のルールを評価する間隔
+
+	DiscordPublicKey   ed25519.PublicKey // Discord のインタラクションの署名検証用（nil なら受け付けない）
+	SlackSigningSecret string            // Slack のスラッシュコマンドの署名検証用（空なら受け付けない）
+
+	InboundHooks map[string]InboundHook // /api/hooks/{source} で受け付ける外部の Webhook（名前 → 設定）
+
+	SyslogUDPAddr string // syslog を UDP で待ち受けるアドレス（空なら待ち受けない）
+	SyslogTCPAddr string // syslog を TCP で待ち受けるアドレス（空なら待ち受けない）
+}
+
+// ConfigFromEnv : 環境変数から設定を読み込む
+func ConfigFromEnv() Config {
+	return Config{
+		Addr:                  config.String("LISTEN_ADDR", ":8081"),
+		BasePath:              basePathFromEnv(),
+		StaticDir:             config.String("STATIC_DIR", ""),
+		AdminToken:            config.String("ADMIN_TOKEN", ""),
+		DashboardDefaultRoles: dashboardDefaultRolesFromEnv(),
+		RequireProjectKey:     config.Bool("REQUIRE_PROJECT_KEY", false),
+		UptimeIngestToken:     config.String("UPTIME_INGEST_TOKEN", ""),
+		PublicBaseURL:         config.String("PUBLIC_BASE_URL", ""),
+		DryRun:                config.Bool("DRY_RUN", false),
+		Features:              featuresFromEnv(),
+
+		LogBatchMax:     config.Int("LOG_BATCH_MAX", 1000),
+		IngestBatchSize: config.Int("INGEST_BATCH_SIZE", 500),
+		IdempotencyTTL:  config.Duration("IDEMPOTENCY_TTL", 24*time.Hour),
+		SampleRate:      sampleRateFromEnv(),
+		IngestRateLimit: ingestRateLimitFromEnv(),
+		NotifyThrottles: notifyThrottlesFromEnv(),
+
+		NotifyDedup:       notifyDedupFromEnv(),
+		NotifyDedupWindow: config.Duration("NOTIFY_DEDUP_WINDOW", 0),
+
+		UsageFlushInterval: config.Duration("USAGE_FLUSH_INTERVAL", 10*time.Second),
+
+		AnonymizeIP:         config.Bool("PRIVACY_ANONYMIZE_IP", false),
+		HashUserAgent:       config.Bool("PRIVACY_HASH_USER_AGENT", false),
+		PrivacySecret:       privacySecretFromEnv(),
+		PrivacySaltRotation: config.Duration("PRIVACY_SALT_ROTATION", 24*time.Hour),
+
+		NotifyQueueSize: config.Int("NOTIFY_QUEUE_SIZE", 1000),
+		NotifyWorkers:   config.Int("NOTIFY_WORKERS", 4),
+
+		NotifyMaxAttempts:  config.Int("NOTIFY_MAX_ATTEMPTS", 3),
+		NotifyRetryBackoff: config.Duration("NOTIFY_RETRY_BACKOFF", 30*time.Second),
+		NotifyPanics:       config.Bool("NOTIFY_PANICS", true),
+
+		WriteMethods:      writeMethodsFromEnv(),
+		MaxBodyBytes:      config.Int64("MAX_BODY_BYTES", 1<<20),
+		MaxHeaderBytes:    config.Int("MAX_HEADER_BYTES", 64<<10),
+		HandlerTimeout:    config.Duration("HANDLER_TIMEOUT", 30*time.Second),
+		DebugEndpoints:    config.Bool("DEBUG_ENDPOINTS", false),
+		SyntheticTraffic:  config.Bool("SYNTHETIC_TRAFFIC", false),
+		DebugAddr:         config.String("DEBUG_ADDR", ""),
+		CompressResponses: config.Bool("COMPRESS_RESPONSES", true),
+		AccessLog:         accessLogFromEnv(),
+		AccessLogSkip:     accessLogSkipFromEnv(),
+
+		RecentCacheSize: recentCacheSizeFromEnv(),
+		RecentCacheTTL:  config.Duration("RECENT_CACHE_TTL", 30*time.Second),
+
+		ReadAfterWrite:        config.Bool("READ_AFTER_WRITE", false),
+		ReadAfterWriteTimeout: config.Duration("READ_AFTER_WRITE_TIMEOUT", 2*time.Second),
+		CollapseWindow:        config.Duration("COLLAPSE_WINDOW", 0),
+		SessionGap:            config.Duration("SESSION_GAP", 30*time.Minute),
+		VisitorCookie:         config.Bool("VISITOR_COOKIE", false),
+		VisitorCookieName:     config.String("VISITOR_COOKIE_NAME", "glv"),
+		VisitorCookieMaxAge:   time.Duration(config.Int("VISITOR_COOKIE_DAYS", 365)) * 24 * time.Hour,
+		CaptureHeaders:        CaptureHeadersFromEnv(),
+
+		TrackedPaths: TrackedPathsFromEnv(),
+		NotifyRules:  notify.RulesFromEnv(),
+		EventTTLs:    EventTTLsFromEnv(),
+		ExcludeBots:  excludeBotsFromEnv(),
+
+		WatchlistCooldown: config.Duration("WATCHLIST_COOLDOWN", 10*time.Minute),
+		WatchlistHistory:  config.Int("WATCHLIST_HISTORY", 10),
+
+		RetentionDays:      config.Int("RETENTION_DAYS", 0),
+		RetentionInterval:  config.Duration("RETENTION_INTERVAL", time.Hour),
+		TrashRetentionDays: config.Int("TRASH_RETENTION_DAYS", 30),
+
+		ExportDir:        config.String("EXPORT_DIR", "exports"),
+		ExportTTL:        config.Duration("EXPORT_TTL", 24*time.Hour),
+		ExportBatch:      config.Int("EXPORT_BATCH", 5000),
+		ArchiveBatchSize: config.Int("ARCHIVE_BATCH_SIZE", 5000),
+
+		PartitionMonthsAhead: config.Int("PARTITION_MONTHS_AHEAD", 3),
+
+		RollupInterval: config.Duration("ROLLUP_INTERVAL", 0),
+
+		VolumeSampleInterval:   config.Duration("VOLUME_SAMPLE_INTERVAL", 5*time.Minute),
+		VolumeAlertMaxBytes:    config.Int64("VOLUME_ALERT_MAX_BYTES", 0),
+		VolumeAlertGrowthBytes: config.Int64("VOLUME_ALERT_GROWTH_BYTES", 0),
+
+		Peers:        PeersFromEnv(),
+		PeerAPIKey:   config.String("PEER_API_KEY", ""),
+		PeerTimeout:  config.Duration("PEER_TIMEOUT", 3*time.Second),
+		InstanceName: instanceNameFromEnv(),
+
+		RemoteWrite:         RemoteWriteFromEnv(),
+		RemoteWriteInterval: config.Duration("REMOTE_WRITE_INTERVAL", 30*time.Second),
+
+		Anomaly:   AnomalyFromEnv(),
+		Trends:    TrendsFromEnv(),
+		Digest:    DigestFromEnv(),
+		Incidents: IncidentsFromEnv(),
+
+		Location: timezoneFromEnv(),
+
+		WebhookBatchSize:     config.Int("WEBHOOK_BATCH_SIZE", 100),
+		WebhookBatchInterval: config.Duration("WEBHOOK_BATCH_INTERVAL", 2*time.Second),
+		WebhookMaxFailures:   config.Int("WEBHOOK_MAX_FAILURES", 10),
+		WebhookTimeout:       config.Duration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookQueueSize:     config.Int("WEBHOOK_QUEUE_SIZE", 10000),
+
+		ChannelReloadInterval: config.Duration("CHANNEL_RELOAD_INTERVAL", time.Minute),
+		LeaderCheckInterval:   leaderCheckIntervalFromEnv(),
+		RuleEvalInterval:      config.Duration("RULE_EVAL_INTERVAL", 30*time.Second),
+
+		DiscordPublicKey:   discordPublicKeyFromEnv(),
+		SlackSigningSecret: config.String("SLACK_SIGNING_SECRET", ""),
+
+		InboundHooks: InboundHooksFromEnv(),
+
+		SyslogUDPAddr: config.String("SYSLOG_UDP_ADDR", ""),
+		SyslogTCPAddr: config.String("SYSLOG_TCP_ADDR", ""),
+	}
+}
+
+// Deps : サーバーが使う外部の部品（nil の項目は何もしない実装になる。Store だけは必須）
+type Deps struct {
+	Store     store.Store
+	Notifier  notify.Notifier
+	Incidents *notify.Multi // PagerDuty / Opsgenie（nil なら送らない）
+	Enricher  enrich.Enricher
+	IDs       idgen.Generator
+	Clock     clock.Clock
+	Auth      auth.Authenticator // ダッシュボードのログイン（nil なら認証なし）
+	Queue     queue.Queue        // 通知の送信待ち（nil ならメモリ上のキュー）
+	Stream    stream.Publisher   // 保存したログの送り先 (Kafka / NATS。nil なら送らない)
+	Consumer  stream.Consumer    // 取り込むログの読み元 (Redis Stream / NATS JetStream。nil なら読まない)
+	Archiver  *archive.Archiver  // 保存期間を過ぎたログの書き出し先 (nil なら書き出さずに削除する)
+	Exports   *archive.Bucket    // POST /api/exports のファイルを上げるバケット (nil なら EXPORT_DIR に置いたまま)
+	Redact    *redact.Policy     // 項目の表示ルール (nil ならどの項目も隠さない)
+	Coord     coord.Store        // インスタンスで分け合う上限・通知の印 (nil ならこのインスタンスのメモリ)
+	Leader    Elector            // 保守の定期処理を動かすインスタンスの選出 (nil なら常にこのインスタンスで動かす)
+}
+
+// Server : ハンドラと定期処理が共有する状態
+type Server struct {
+	cfg          Config
+	store        store.Store
+	notifier     atomic.Pointer[notifierRef] // 環境変数の通知先（SIGHUP で作り直す）
+	incidents    *notify.Multi
+	enricher     enrich.Enricher
+	ids          idgen.Generator
+	clock        clock.Clock
+	auth         auth.Authenticator
+	queue        queue.Queue
+	stream       stream.Publisher
+	consumer     stream.Consumer
+	archiver     *archive.Archiver
+	exportBucket *archive.Bucket
+	exportWake   chan struct{} // POST /api/exports で受け付けたらワーカーを起こす
+	redact       *redact.Policy
+	coord        coord.Store
+	elector      Elector
+
+	hub         *entryHub
+	recent      *recentCache // 最新ログのキャッシュ (nil ならキャッシュしない)
+	projectKeys sync.Map     // APIキー → プロジェクトID（DB再接続中も書き込みを受け付けるため。定期的にDBに合わせる）
+	schema      graphql.Schema
+	peerClient  *http.Client
+	volume      volumeState
+	channels    atomic.Pointer[notify.Multi] // DBで管理する通知先（環境変数の通知先とは別）
+	webhooks    webhookState
+	rules       ruleState
+	mutes       muteState
+	anomaly     anomalyState
+	trends      trendState
+	digest      digestState
+	exclusions  exclusionState
+	routes      routeState
+	ipRules     ipRuleState
+	roles       roleState
+	watchlist   watchlistState
+	collapse    collapseState
+	privacy     privacyState
+	usage       usageState
+	envTunables atomic.Pointer[tunableSettings] // 環境変数の値（SIGHUP で読み直す）
+	settings    atomic.Pointer[tunableSettings] // 環境変数の値に /api/settings の上書きを当てたもの
+
+	openIncidents incidentState  // PagerDuty / Opsgenie で開いているインシデント
+	throttled     throttleState  // NOTIFY_THROTTLE で送らなかった通知の件数
+	synthetic     syntheticState // POST /api/admin/generate で流している合成トラフィック
+
+	jobs           jobRegistry  // 定期処理の状態と一時停止 (/api/admin/jobs)
+	leader         atomic.Bool  // 保守の定期処理を動かすリーダーか (elector がある場合)
+	notifyPaused   atomic.Bool  // 通知の送信待ちのキューを一時停止している
+	notifyRetrying atomic.Int64 // 再送の間隔を空けている通知の数
+}
+
+// New : 設定と部品からサーバーを作る
+func New(cfg Config, deps Deps) *Server {
+	if deps.Store == nil {
+		panic("server: Deps.Store is required")
+	}
+	s := &Server{
+		cfg:          cfg,
+		store:        deps.Store,
+		incidents:    deps.Incidents,
+		enricher:     deps.Enricher,
+		ids:          deps.IDs,
+		clock:        deps.Clock,
+		auth:         deps.Auth,
+		queue:        deps.Queue,
+		stream:       deps.Stream,
+		consumer:     deps.Consumer,
+		archiver:     deps.Archiver,
+		exportBucket: deps.Exports,
+		exportWake:   make(chan struct{}, 1),
+		redact:       deps.Redact,
+		coord:        deps.Coord,
+		elector:      deps.Leader,
+		hub:          newEntryHub(),
+		recent:       newRecentCache(cfg.RecentCacheSize, cfg.RecentCacheTTL),
+		peerClient:   tracing.HTTPClient(&http.Client{}),
+		webhooks:     webhookState{entries: make(chan model.LogEntry, max(cfg.WebhookQueueSize, 1)), client: tracing.HTTPClient(&http.Client{})},
+		volume:       volumeState{alerted: map[string]bool{}},
+		rules:        ruleState{fired: map[int]time.Time{}, silent: map[int]bool{}},
+		mutes:        muteState{until: map[string]time.Time{}},
+		anomaly:      anomalyState{alerted: map[string]bool{}},
+		trends:       trendState{reported: map[string]time.Time{}},
+		collapse:     collapseState{seen: map[collapseKey]collapsedAccess{}},
+		jobs:         jobRegistry{jobs: map[string]*job{}},
+
+		openIncidents: incidentState{open: map[string]time.Time{}},
+	}
+	if deps.Notifier == nil {
+		deps.Notifier = notify.NewMulti()
+	}
+	s.notifier.Store(&notifierRef{deps.Notifier})
+	if s.incidents == nil {
+		s.incidents = notify.NewMulti()
+	}
+	if s.enricher == nil {
+		s.enricher = enrich.Pipeline{}
+	}
+	if s.ids == nil {
+		s.ids = idgen.Serial{}
+	}
+	if s.clock == nil {
+		s.clock = clock.System{}
+	}
+	if s.stream == nil {
+		s.stream = stream.Multi{}
+	}
+	if s.queue == nil {
+		s.queue = queue.NewMemory(s.cfg.NotifyQueueSize)
+	}
+	if s.coord == nil {
+		s.coord = coord.NewMemory(s.clock)
+	}
+	if s.cfg.NotifyRules == nil {
+		s.cfg.NotifyRules = notify.ParseRules(notify.DefaultRules)
+	}
+	base := tunablesFromConfig(s.cfg)
+	s.envTunables.Store(&base)
+	s.settings.Store(&base)
+	s.schema = s.graphqlSchema()
+	return s
+}
+
+// Config : 読み込んだ設定
+func (s *Server) Config() Config { return s.cfg }
+
+// Publish : 保存されたログを購読者（GraphQL のサブスクリプションと Kafka / NATS）へ流し、match ルールに当てる
+// store.Postgres.OnInsert に渡すと、バッファから書き戻したログも流れる
+func (s *Server) Publish(e *model.LogEntry) {
+	s.recent.add(e)
+	s.hub.publish(e)
+	if err := s.stream.Publish(context.Background(), s.redact.EntryPtr(e, s.exportScope())); err != nil {
+		fmt.Println("Failed to publish entry:", err)
+	}
+	s.matchRules(e)
+	s.notifyWatched(e)
+	s.enqueueWebhooks(e)
+}
+
+// Run : 定期処理を開始する（ctx が終わるまで動き続ける）
+func (s *Server) Run(ctx context.Context) {
+	// 保守の定期処理を動かすリーダーを決める（最初の1回は定期処理を始める前に確かめる）
+	if s.elector != nil {
+		s.checkLeader(ctx)
+	}
+	go s.watchLeader(ctx)
+	// 送信待ちの通知を送る
+	for range max(s.cfg.NotifyWorkers, 1) {
+		go s.notifyWorker(ctx)
+	}
+	// データ量（行数・サイズ）を定期的に記録する
+	go s.watchVolume(ctx)
+	// 期限切れ・保存期間切れのログを定期的に削除する
+	go s.watchRetention(ctx)
+	go s.watchTrash(ctx)
+	// キーごとの利用量を書き込む
+	go s.watchUsage(ctx)
+	// access_logs の先の月のパーティションを作る
+	go s.watchPartitions(ctx)
+	// 長い期間の集計用に、時間ごと・日ごとの件数をまとめる (ROLLUP_INTERVAL を設定した場合のみ)
+	go s.watchRollups(ctx)
+	// アクセス数を remote-write で送る (REMOTE_WRITE_URL を設定した場合のみ)
+	go s.watchRemoteWrite(ctx)
+	// DBで管理する通知先を定期的に読み直す
+	go s.watchChannels(ctx)
+	// Webhook を読み直し、新しいログをまとめて送る
+	go s.watchWebhooks(ctx)
+	go s.dispatchWebhooks(ctx)
+	// アラートルールを評価する
+	go s.watchRules(ctx)
+	// NOTIFY_THROTTLE で送らなかった通知の件数を、期間が終わったら送る
+	go s.watchThrottles(ctx)
+	// アクセスの急増・急減を検知する (ANOMALY_FACTOR を設定した場合のみ)
+	go s.watchAnomalies(ctx)
+	// 上位のUA・パス・国を前の期間と比べる (TREND_INTERVAL を設定した場合のみ)
+	go s.watchTrends(ctx)
+	// 前日のまとめを送る (DIGEST_TIME を設定した場合のみ)
+	go s.watchDigest(ctx)
+	// POST /api/exports で受け付けた書き出しをファイルに書く
+	go s.watchExports(ctx)
+	// 静かになったインシデントを閉じる (PAGERDUTY_ROUTING_KEY / OPSGENIE_API_KEY を設定した場合のみ)
+	go s.watchIncidents(ctx)
+	// syslog を受け取る (SYSLOG_UDP_ADDR / SYSLOG_TCP_ADDR を設定した場合のみ)
+	go s.listenSyslog(ctx)
+	// Redis Stream / NATS からログを取り込む (INGEST_SOURCE を設定した場合のみ)
+	go s.consumeStream(ctx)
+}
+
+// Handler : 全てのルートをトレース付きで包んだハンドラ
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	// 以下の /api/... は全て /api/v1/... でも呼べる（apiversion.go）
+
+	// A. ログ書き込み用API (curlなどでアクセスすると記録＆通知)
+	// 例: https://dev.aliceindex.jp/go/api/
+	// TRACKED_PATHS で /ping や /rss-hit なども別のイベント種別として記録できる
+	for _, tp := range s.cfg.TrackedPaths {
+		mux.HandleFunc(tp.Pattern, s.ipFilter(s.rateLimit(s.requireKeyPermission(permLogsWrite, s.allowWriteMethods(s.idempotent(s.writeHandler(tp.EventType)))))))
+	}
+
+	// ページごとの記録（後ろのパスをページとして記録する） 例: POST https://dev.aliceindex.jp/go/api/track/blog/posts/hello-world
+	mux.HandleFunc("/api/track/{site}/{path...}", s.ipFilter(s.rateLimit(s.requireKeyPermission(permLogsWrite, s.allowWriteMethods(s.idempotent(s.trackHandler))))))
+
+	// B. ログ読み出し用API (JSからfetchしてデータを取得)
+	// 例: https://dev.aliceindex.jp/go/api/logs
+	// DASHBOARD_USERS を設定すると画面と読み出しAPIはログインが必要になる
+	mux.Handle("GET /api/logs", s.dashboardFunc(s.readHandler))
+	// 全文検索 例: https://dev.aliceindex.jp/go/api/logs/search?q=timeout
+	mux.Handle("GET /api/logs/search", s.dashboardFunc(s.searchHandler))
+	// 件数・ユニーク数（行は返さない） 例: https://dev.aliceindex.jp/go/api/logs/count?period=7d&distinct=ip&estimate=true
+	mux.Handle("GET /api/logs/count", s.dashboardFunc(s.countHandler))
+	// 1件の詳細（ヘッダー・国・同じセッションの前後。通知のリンク #log-123 から開く） 例: https://dev.aliceindex.jp/go/api/logs/123
+	mux.Handle("GET /api/logs/{id}", s.dashboardFunc(s.logDetailHandler))
+	// 仕分けのタグ・メモ 例: PATCH https://dev.aliceindex.jp/go/api/logs/123 {"add_tags":["pentest"]}
+	mux.Handle("PATCH /api/logs/{id}", s.dashboardFunc(s.annotateLogHandler))
+	// ゴミ箱 例: DELETE https://dev.aliceindex.jp/go/api/logs/123 → GET /api/logs/trash → POST /api/logs/123/restore
+	mux.Handle("DELETE /api/logs/{id}", s.dashboardFunc(s.deleteLogHandler))
+	mux.Handle("GET /api/logs/trash", s.dashboardFunc(s.trashHandler))
+	mux.Handle("POST /api/logs/{id}/restore", s.dashboardFunc(s.restoreLogHandler))
+	// 種別・レベルごとの件数 (?federate=true で PEERS の分も合算)
+	mux.Handle("GET /api/stats", s.dashboardFunc(s.statsHandler))
+	// 直近の期間とその前の同じ長さの期間の比較（アクセス数・訪問者・エラーと増減の割合） 例: https://dev.aliceindex.jp/go/api/stats/compare?period=7d
+	mux.Handle("GET /api/stats/compare", s.dashboardFunc(s.compareHandler))
+	// 国別のアクセス数（世界地図の色分け用。?centroids=true で代表点も） 例: https://dev.aliceindex.jp/go/api/stats/geo?period=30d
+	mux.Handle("GET /api/stats/geo", s.dashboardFunc(s.geoStatsHandler))
+	// 国別のアクセス数 (GeoJSON。地図ライブラリやGISツールにそのまま読み込める)
+	mux.Handle("GET /api/stats/geo.geojson", s.dashboardFunc(s.geoJSONHandler))
+	// 参照元のドメイン・ページの上位 例: https://dev.aliceindex.jp/go/api/stats/referrers?period=7d
+	mux.Handle("GET /api/stats/referrers", s.dashboardFunc(s.referrersHandler))
+	// 区間ごとの件数（件数0の区間も含む） 例: https://dev.aliceindex.jp/go/api/stats/timeseries?interval=1h&period=7d&tz=Asia/Tokyo
+	mux.Handle("GET /api/stats/timeseries", s.dashboardFunc(s.timeseriesHandler))
+	// 列ごとの上位 例: https://dev.aliceindex.jp/go/api/stats/top?by=path&limit=10&period=7d
+	mux.Handle("GET /api/stats/top", s.dashboardFunc(s.topHandler))
+	// GraphQL (必要な項目だけ取得・集計・新着のサブスクリプション)
+	mux.Handle("GET /api/graphql", s.dashboardFunc(s.graphqlHandler))
+	mux.Handle("POST /api/graphql", s.dashboardFunc(s.graphqlHandler))
+	// WebSocket (検索式に一致する新着ログだけと、一定間隔の件数を送る) 例: wss://dev.aliceindex.jp/go/api/ws?filter=ua~curl
+	if s.cfg.Features.Streams {
+		mux.Handle("GET /api/ws", s.dashboardFunc(s.wsHandler))
+	}
+	// ダッシュボードの文言と時刻の表記（言語は ?lang= か Accept-Language） 例: https://dev.aliceindex.jp/go/api/i18n?lang=ja&tz=Asia/Tokyo
+	mux.HandleFunc("GET /api/i18n", s.i18nHandler)
+
+	// B'. 構造化ログ取り込みAPI (他サービスのアプリケーションログ)
+	// 例: POST https://dev.aliceindex.jp/go/api/logs {"level":"error","message":"...","fields":{...}}
+	// Idempotency-Key を付けると、送り直しても二重に保存しない
+	mux.HandleFunc("POST /api/logs", s.ipFilter(s.rateLimit(s.requireKeyPermission(permLogsWrite, s.idempotent(s.ingestLogHandler)))))
+	// まとめて取り込み 例: POST https://dev.aliceindex.jp/go/api/logs/batch [{"level":"info","message":"..."}, ...]
+	mux.HandleFunc("POST /api/logs/batch", s.ipFilter(s.rateLimit(s.requireKeyPermission(permLogsWrite, s.idempotent(s.ingestBatchHandler)))))
+
+	// B''. 外部の Webhook の受け口 (GitHub / Stripe / 汎用。INBOUND_HOOKS で設定した名前だけ)
+	// 例: POST https://dev.aliceindex.jp/go/api/hooks/github?key=<プロジェクトキー>
+	mux.HandleFunc("POST /api/hooks/{source}", s.ipFilter(s.rateLimit(s.requireKeyPermission(permLogsWrite, s.hookHandler))))
+
+	// C. 稼働監視API (外部の合成監視ツールから結果を受け取る)
+	// 例: POST https://dev.aliceindex.jp/go/api/uptime/checks
+	mux.HandleFunc("POST /api/uptime/checks", s.uptimeIngestHandler)
+	mux.Handle("GET /api/uptime", s.dashboardFunc(s.uptimeStatusHandler))
+
+	// D. プロジェクト管理API (ADMIN_TOKEN が必要)
+	mux.HandleFunc("GET /api/projects", s.requireAdmin(s.listProjectsHandler))
+	mux.HandleFunc("POST /api/projects", s.requireAdmin(s.createProjectHandler))
+	mux.HandleFunc("POST /api/projects/{id}/rotate", s.requireAdmin(s.rotateProjectKeyHandler))
+	// IP の切り詰め・UA のハッシュ化 例: PUT https://dev.aliceindex.jp/go/api/projects/2/privacy {"anonymize_ip": true}
+	mux.HandleFunc("PUT /api/projects/{id}/privacy", s.requireAdmin(s.updateProjectPrivacyHandler))
+	// キーの上限と利用量 例: PUT https://dev.aliceindex.jp/go/api/projects/2/quota {"daily": 10000}
+	mux.HandleFunc("PUT /api/projects/{id}/quota", s.requireAdmin(s.updateProjectQuotaHandler))
+	mux.HandleFunc("GET /api/keys/{id}/usage", s.requireAdmin(s.keyUsageHandler))
+	// 実行中に変えられる設定（保存日数・抽出率・日次のまとめ・通知の一時停止）例: PATCH https://dev.aliceindex.jp/go/api/settings {"sample_rate": 0.5}
+	mux.Handle("GET /api/settings", s.dashboardFunc(s.settingsHandler))
+	mux.Handle("PATCH /api/settings", s.dashboardFunc(s.updateSettingsHandler))
+	// ロール付きのAPIキーとダッシュボードのユーザーのロール 例: POST https://dev.aliceindex.jp/go/api/keys {"name": "ci", "roles": ["ingester"]}
+	mux.HandleFunc("GET /api/roles", s.requireAdmin(s.listRolesHandler))
+	mux.HandleFunc("GET /api/keys", s.requireAdmin(s.listAPIKeysHandler))
+	mux.HandleFunc("POST /api/keys", s.requireAdmin(s.createAPIKeyHandler))
+	mux.HandleFunc("DELETE /api/keys/{id}", s.requireAdmin(s.deleteAPIKeyHandler))
+	mux.HandleFunc("GET /api/users", s.requireAdmin(s.listUserRolesHandler))
+	mux.HandleFunc("PUT /api/users/{user}", s.requireAdmin(s.putUserRolesHandler))
+	mux.HandleFunc("DELETE /api/users/{user}", s.requireAdmin(s.deleteUserRolesHandler))
+	// 本人からの開示・削除の依頼 例: POST https://dev.aliceindex.jp/go/api/privacy/delete?ip=203.0.113.7&reason=ticket-123
+	mux.HandleFunc("GET /api/privacy/export", s.requireAdmin(s.privacyExportHandler))
+	mux.HandleFunc("POST /api/privacy/delete", s.requireAdmin(s.privacyDeleteHandler))
+	mux.HandleFunc("GET /api/privacy/requests", s.requireAdmin(s.privacyRequestsHandler))
+
+	// D'. 通知先の管理API (ADMIN_TOKEN か admin のロールが必要。Discord / Slack / Telegram)
+	mux.HandleFunc("GET /api/channels", s.requireRole(permChannels, s.listChannelsHandler))
+	mux.HandleFunc("POST /api/channels", s.requireRole(permChannels, s.createChannelHandler))
+	mux.HandleFunc("GET /api/channels/{id}", s.requireRole(permChannels, s.getChannelHandler))
+	mux.HandleFunc("PATCH /api/channels/{id}", s.requireRole(permChannels, s.updateChannelHandler))
+	mux.HandleFunc("DELETE /api/channels/{id}", s.requireRole(permChannels, s.deleteChannelHandler))
+	// 例のログで通知を試す（?dry_run=1 なら送らずに組み立てた本文を返す） 例: POST https://dev.aliceindex.jp/go/api/channels/3/test?dry_run=1&level=warn
+	mux.HandleFunc("POST /api/channels/{id}/test", s.requireRole(permChannels, s.testChannelHandler))
+	// 新しいログを外部へ送る Webhook (本文に HMAC-SHA256 の署名を付け、続けて失敗したら止める)
+	mux.HandleFunc("GET /api/webhooks", s.requireRole(permChannels, s.listWebhooksHandler))
+	mux.HandleFunc("POST /api/webhooks", s.requireRole(permChannels, s.createWebhookHandler))
+	mux.HandleFunc("GET /api/webhooks/{id}", s.requireRole(permChannels, s.getWebhookHandler))
+	mux.HandleFunc("PATCH /api/webhooks/{id}", s.requireRole(permChannels, s.updateWebhookHandler))
+	mux.HandleFunc("DELETE /api/webhooks/{id}", s.requireRole(permChannels, s.deleteWebhookHandler))
+
+	// D''. アラートルール (ADMIN_TOKEN が必要) とアラートの履歴 (.ics はカレンダーアプリから購読できる)
+	mux.HandleFunc("GET /api/rules", s.requireAdmin(s.listRulesHandler))
+	mux.HandleFunc("POST /api/rules", s.requireAdmin(s.createRuleHandler))
+	mux.HandleFunc("PATCH /api/rules/{id}", s.requireAdmin(s.updateRuleHandler))
+	mux.HandleFunc("DELETE /api/rules/{id}", s.requireAdmin(s.deleteRuleHandler))
+	mux.Handle("GET /api/alerts", s.dashboardFunc(s.alertsHandler))
+	mux.Handle("GET /api/alerts.ics", s.dashboardFunc(s.alertsICSHandler))
+	// Discord のアラートのボタン (確認・1時間ミュート・詳細) とスラッシュコマンド。署名で認証する
+	mux.HandleFunc("POST /api/discord/interactions", s.discordInteractionsHandler)
+	// Slack のスラッシュコマンド (/logger stats today, /logger last 5)
+	mux.HandleFunc("POST /api/slack/commands", s.slackCommandHandler)
+
+	// 記録・通知から除外するアクセス (ADMIN_TOKEN が必要。EXCLUDE_BOTS でボットもまとめて除外できる)
+	mux.HandleFunc("GET /api/exclusions", s.requireAdmin(s.listExclusionsHandler))
+	mux.HandleFunc("POST /api/exclusions", s.requireAdmin(s.createExclusionHandler))
+	mux.HandleFunc("DELETE /api/exclusions/{id}", s.requireAdmin(s.deleteExclusionHandler))
+	// 通知の一時停止 (負荷試験・デプロイの間) 例: POST https://dev.aliceindex.jp/go/api/notifications/mute?duration=2h
+	mux.HandleFunc("GET /api/notifications/mute", s.requireRole(permChannels, s.muteStatusHandler))
+	mux.HandleFunc("POST /api/notifications/mute", s.requireRole(permChannels, s.muteNotificationsHandler))
+	mux.HandleFunc("DELETE /api/notifications/mute", s.requireRole(permChannels, s.unmuteNotificationsHandler))
+	mux.HandleFunc("POST /api/notifications/unmute", s.requireRole(permChannels, s.unmuteNotificationsHandler))
+	// 通知のルーティング (検索式に合うログを決めた通知先だけへ送る・送らない) 例: {"match": "level>=error", "channels": ["slack:alerts"]}
+	mux.HandleFunc("GET /api/notify-routes", s.requireRole(permChannels, s.listRoutesHandler))
+	mux.HandleFunc("POST /api/notify-routes", s.requireRole(permChannels, s.createRouteHandler))
+	mux.HandleFunc("PATCH /api/notify-routes/{id}", s.requireRole(permChannels, s.updateRouteHandler))
+	mux.HandleFunc("DELETE /api/notify-routes/{id}", s.requireRole(permChannels, s.deleteRouteHandler))
+	// 書き込みを拒否・許可するアドレス範囲 (ADMIN_TOKEN が必要。拒否したクライアントには403)
+	mux.HandleFunc("GET /api/ip-rules", s.requireAdmin(s.listIPRulesHandler))
+	mux.HandleFunc("POST /api/ip-rules", s.requireAdmin(s.createIPRuleHandler))
+	mux.HandleFunc("DELETE /api/ip-rules/{id}", s.requireAdmin(s.deleteIPRuleHandler))
+	mux.HandleFunc("GET /api/watchlist", s.requireAdmin(s.listWatchlistHandler))
+	mux.HandleFunc("POST /api/watchlist", s.requireAdmin(s.createWatchHandler))
+	mux.HandleFunc("DELETE /api/watchlist/{id}", s.requireAdmin(s.deleteWatchHandler))
+
+	// 計測用の 1x1 の画像（JavaScript の動かないページ・メール用） 例: <img src="https://dev.aliceindex.jp/go/pixel.gif?site=newsletter">
+	mux.HandleFunc("GET /pixel.gif", s.ipFilter(s.rateLimit(s.requireKeyPermission(permLogsWrite, s.pixelHandler))))
+
+	// E. 短縮リンク (クリックを記録してからリダイレクト)
+	// 例: https://dev.aliceindex.jp/go/l/github
+	mux.HandleFunc("GET /l/{slug}", s.ipFilter(s.shortLinkHandler))
+	mux.HandleFunc("GET /api/links", s.requireAdmin(s.listLinksHandler))
+	mux.HandleFunc("POST /api/links", s.requireAdmin(s.createLinkHandler))
+	mux.HandleFunc("DELETE /api/links/{slug}", s.requireAdmin(s.deleteLinkHandler))
+	mux.HandleFunc("GET /api/links/{slug}/qr", s.requireAdmin(s.linkQRHandler))
+
+	// F. メトリクス (Prometheus形式) とデータ量の管理API
+	mux.Handle("GET /metrics", promhttp.Handler())
+	// APIの仕様 (OpenAPI 3)。Swagger UI は https://dev.aliceindex.jp/go/api-docs.html
+	mux.HandleFunc("GET /api/openapi.json", s.openAPIHandler)
+	// 動いているビルドと画面のファイルの版（画面はデプロイで版が変わったら読み直す）
+	mux.HandleFunc("GET /api/version", s.versionHandler)
+	mux.HandleFunc("GET /api/admin/volume", s.requireAdmin(s.volumeHandler))
+	mux.HandleFunc("GET /api/admin/storage", s.requireAdmin(s.storageHandler))
+	mux.HandleFunc("POST /api/exports", s.requireAdmin(s.createExportHandler))
+	mux.HandleFunc("GET /api/exports/{id}", s.requireAdmin(s.exportHandler))
+	mux.HandleFunc("GET /api/exports/{id}/download", s.requireAdmin(s.downloadExportHandler))
+	// バックアップ用の一貫したスナップショット (NDJSON)
+	mux.HandleFunc("GET /api/admin/snapshot", s.requireAdmin(s.snapshotHandler))
+	// 設定のエクスポート・インポート (YAML。別のインスタンスへ同じ設定を複製する)
+	mux.HandleFunc("GET /api/admin/config", s.requireAdmin(s.exportConfigHandler))
+	mux.HandleFunc("PUT /api/admin/config", s.requireAdmin(s.importConfigHandler))
+	// 障害の注入 (go build -tags chaos でビルドした時だけ。DBの遅延・Webhookの失敗・バッファ溢れ)
+	if faults.Enabled {
+		mux.HandleFunc("GET /api/admin/faults", s.requireAdmin(s.faultsHandler))
+		mux.HandleFunc("PUT /api/admin/faults", s.requireAdmin(s.faultsHandler))
+		mux.HandleFunc("DELETE /api/admin/faults", s.requireAdmin(s.faultsHandler))
+	}
+	// 合成トラフィック (SYNTHETIC_TRAFFIC=true の時だけ。負荷試験・画面の確認用)
+	if s.cfg.SyntheticTraffic {
+		mux.HandleFunc("GET /api/admin/generate", s.requireAdmin(s.generateHandler))
+		mux.HandleFunc("POST /api/admin/generate", s.requireAdmin(s.generateHandler))
+		mux.HandleFunc("DELETE /api/admin/generate", s.requireAdmin(s.generateHandler))
+	}
+	// 定期処理と送信待ちのキュー (一時停止・再開と、送れなかった通知の再送。画面は jobs.html)
+	mux.HandleFunc("GET /api/admin/jobs", s.requireAdmin(s.jobsHandler))
+	// 前日のまとめを今すぐ送る（DIGEST_TIME の設定の確認用）
+	mux.HandleFunc("POST /api/admin/digest", s.requireAdmin(s.digestHandler))
+	mux.HandleFunc("POST /api/admin/jobs/{name}/{action}", s.requireAdmin(s.jobActionHandler))
+	mux.HandleFunc("POST /api/admin/queues/{name}/{action}", s.requireAdmin(s.queueActionHandler))
+	mux.HandleFunc("GET /api/admin/dead-letters", s.requireAdmin(s.listDeadLettersHandler))
+	mux.HandleFunc("POST /api/admin/dead-letters/{id}/retry", s.requireAdmin(s.retryDeadLetterHandler))
+	mux.HandleFunc("DELETE /api/admin/dead-letters/{id}", s.requireAdmin(s.deleteDeadLetterHandler))
+	// プロファイルと実行時の状態 (DEBUG_ENDPOINTS=true の時だけ。別のポートで出すなら DEBUG_ADDR)
+	s.mountDebug(mux)
+
+	// G. ダッシュボード画面 (実行ファイルに埋め込んだ static フォルダのHTMLを配信。STATIC_DIR でディスクの方に差し替えられる)
+	// 例: https://dev.aliceindex.jp/go/
+	// FEATURE_DASHBOARD=false なら画面は配信しない（読み出しAPIだけ）
+	if s.cfg.Features.Dashboard {
+		mux.Handle("/", s.requireDashboard(s.staticHandler()))
+	}
+	// ログイン用のルートが必要な認証方式 (OIDC のコールバックなど) はここで追加する
+	if router, ok := s.auth.(auth.Router); ok {
+		router.Mount(mux)
+	}
+
+	// BASE_PATH の接頭辞を外し、応答を圧縮し、本文の大きさと処理時間の上限をかけ、panic を回復し、/api/v1 の接頭辞を外してから、
+	// 全ルートをアクセスの記録とトレース付きで包む
+	return tracing.Handler(s.accessLog(s.withBasePath(s.compress(s.harden(jsonErrors(s.recoverPanics(withAPIVersion(withRoute(mux)))))))))
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"go-logger/internal/coord"
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/redact"
+)
+
+// ==========================================
+// 実行中に変えられる設定 (/api/settings)
+// ==========================================
+// 保存日数（全体・プロジェクトと種別ごと）・抽出率・日次のまとめの時刻は settings テーブルで環境変数の値を上書きでき、
+// 定期の読み直し (RULE_EVAL_INTERVAL) で全てのインスタンスに反映される（再起動は要らない）
+// 通知の一時停止は /api/notifications/mute と同じ alert_mutes を読み書きする
+//
+//	GET   /api/settings  今の値（ダッシュボードにログインしていれば読める）
+//	PATCH /api/settings  {"sample_rate": 0.5, "digest_time": null}（ADMIN_TOKEN のみ。null は環境変数の値に戻す）
+//	                     {"retention_tiers": [{"event_type": "error", "days": 365}, {"event_type": "access", "days": 30}]}
+
+// 上書きできる設定のキー
+const (
+	settingRetentionDays  = "retention_days"
+	settingRetentionTiers = "retention_tiers"
+	settingSampleRate     = "sample_rate"
+	settingDigestTime     = "digest_time"
+)
+
+// settingKeys : 上書きできる設定（settings テーブルのキー）
+var settingKeys = []string{settingRetentionDays, settingRetentionTiers, settingSampleRate, settingDigestTime}
+
+// tunableSettings : 環境変数の値に settings テーブルの上書きを当てたもの
+// NotifyRules 以降は /api/settings では変えられず、SIGHUP で環境変数から読み直す（reload.go）
+type tunableSettings struct {
+	RetentionDays  int
+	RetentionTiers []model.RetentionTier // 具体的なものから並べ替えてある（retentionPolicy）
+	SampleRate     float64
+	Digest         DigestConfig
+	Overrides      []string // 上書きしている設定のキー
+
+	NotifyRules     notify.Rules
+	IngestRateLimit coord.Limit
+	NotifyThrottles NotifyThrottles
+	ExcludeBots     string
+}
+
+// tunables : 今の設定（New で環境変数の値を入れるので nil にはならない）
+func (s *Server) tunables() *tunableSettings {
+	return s.settings.Load()
+}
+
+// tunablesFromConfig : 環境変数だけから作った設定
+func tunablesFromConfig(cfg Config) tunableSettings {
+	return tunableSettings{
+		RetentionDays: cfg.RetentionDays, SampleRate: cfg.SampleRate, Digest: cfg.Digest,
+		NotifyRules: cfg.NotifyRules, IngestRateLimit: cfg.IngestRateLimit, NotifyThrottles: cfg.NotifyThrottles, ExcludeBots: cfg.ExcludeBots,
+	}
+}
+
+// apply : 1つの設定を文字列の値で上書きする（不正な値はエラー）
+func (t *tunableSettings) apply(key, value string) error {
+	switch key {
+	case settingRetentionDays:
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return fmt.Errorf("%s must be 0 (keep forever) or more days", key)
+		}
+		t.RetentionDays = days
+	case settingRetentionTiers:
+		tiers, err := parseRetentionTiers(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		t.RetentionTiers = tiers
+	case settingSampleRate:
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 || rate > 1 {
+			return fmt.Errorf("%s must be in (0, 1]", key)
+		}
+		t.SampleRate = rate
+	case settingDigestTime:
+		digest, err := t.Digest.withTime(value)
+		if err != nil {
+			return err
+		}
+		t.Digest = digest
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if !slices.Contains(t.Overrides, key) {
+		t.Overrides = append(t.Overrides, key)
+	}
+	return nil
+}
+
+// tunablesFrom : 環境変数の値に settings の上書きを当てる（不正な値は飛ばす）
+func (s *Server) tunablesFrom(settings []model.Setting) *tunableSettings {
+	t := *s.envTunables.Load()
+	t.Overrides = nil
+	for _, st := range settings {
+		if err := t.apply(st.Key, st.Value); err != nil {
+			fmt.Printf("Skipping setting %s=%q: %v\n", st.Key, st.Value, err)
+		}
+	}
+	return &t
+}
+
+// LoadSettings : settings テーブルの上書きを読み込む（`main purge` など Start を呼ばないコマンド向け）
+func (s *Server) LoadSettings(ctx context.Context) error {
+	return s.reloadSettings(ctx)
+}
+
+// reloadSettings : settings テーブルを読み直す
+func (s *Server) reloadSettings(ctx context.Context) error {
+	settings, err := s.store.ListSettings(ctx)
+	if err != nil {
+		return err
+	}
+	s.settings.Store(s.tunablesFrom(settings))
+	return nil
+}
+
+// ==========================================
+// 設定の管理API
+// ==========================================
+
+// settingsResponse : GET / PATCH /api/settings の応答
+type settingsResponse struct {
+	RetentionDays  int                   `json:"retention_days"`  // 0 なら期限付きのログだけ削除する
+	RetentionTiers []model.RetentionTier `json:"retention_tiers"` // プロジェクト・種別ごとの保存日数（当てはまらなければ retention_days）
+	SampleRate     float64               `json:"sample_rate"`
+	DigestTime     string                `json:"digest_time"` // 空なら日次のまとめを送らない
+	MutedUntil     *time.Time            `json:"muted_until"` // 全ての通知を止めている期限（止めていなければ null）
+	Overrides      []string              `json:"overrides"`   // settings テーブルで上書きしている項目（ほかは環境変数の値）
+}
+
+// settingsStatus : 今の設定
+func (s *Server) settingsStatus() settingsResponse {
+	t := s.tunables()
+	return settingsResponse{
+		RetentionDays:  t.RetentionDays,
+		RetentionTiers: append([]model.RetentionTier{}, t.RetentionTiers...),
+		SampleRate:     t.SampleRate,
+		DigestTime:     t.Digest.timeString(),
+		MutedUntil:     s.muteStatus(s.clock.Now()).MutedUntil,
+		Overrides:      append([]string{}, t.Overrides...),
+	}
+}
+
+// settingsHandler : GET /api/settings
+func (s *Server) settingsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.requestScope(r) < redact.User {
+		http.Error(w, "Login or admin token required", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.settingsStatus())
+}
+
+// settingValue : JSON の値を settings テーブルに入れる文字列にする（文字列はそのまま、数は書かれたまま）
+func settingValue(raw json.RawMessage) string {
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		return str
+	}
+	return string(bytes.TrimSpace(raw))
+}
+
+// updateSettingsHandler : PATCH /api/settings （省略した項目は変えない。null なら環境変数の値に戻す）
+// muted_until は日時（RFC 3339、30日先まで）で全ての通知を止め、null で止めるのをやめる
+func (s *Server) updateSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.requestScope(r) < redact.Admin {
+		http.Error(w, "Admin token required to change settings", http.StatusForbidden)
+		return
+	}
+	var req map[string]json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 先に全ての値を確かめ、1つでも不正なら何も変えない
+	check := *s.tunables()
+	var muteUntil *time.Time
+	_, changeMute := req["muted_until"]
+	for key, raw := range req {
+		null := bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+		if key == "muted_until" {
+			if null {
+				continue
+			}
+			var until time.Time
+			now := s.clock.Now()
+			if err := json.Unmarshal(raw, &until); err != nil || !until.After(now) || until.After(now.Add(maxMuteDuration)) {
+				http.Error(w, "Invalid settings: muted_until must be a future RFC 3339 time, up to 30 days ahead", http.StatusBadRequest)
+				return
+			}
+			muteUntil = &until
+			continue
+		}
+		if !slices.Contains(settingKeys, key) {
+			http.Error(w, fmt.Sprintf("Invalid settings: unknown setting %q", key), http.StatusBadRequest)
+			return
+		}
+		if null {
+			continue
+		}
+		if err := check.apply(key, settingValue(raw)); err != nil {
+			http.Error(w, "Invalid settings: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	for key, raw := range req {
+		if key == "muted_until" {
+			continue
+		}
+		var err error
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			err = s.store.DeleteSetting(r.Context(), key)
+		} else {
+			err = s.store.PutSetting(r.Context(), key, settingValue(raw))
+		}
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+	}
+	if changeMute {
+		var err error
+		if muteUntil != nil {
+			err = s.muteAlertKey(r.Context(), muteAllKey, *muteUntil, "settings")
+		} else {
+			err = s.unmuteAlertKey(r.Context(), muteAllKey)
+		}
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+	}
+	if err := s.reloadSettings(r.Context()); err != nil {
+		fmt.Println("Failed to reload settings:", err)
+	}
+	fmt.Println("Settings updated:", slices.Sorted(maps.Keys(req)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.settingsStatus())
+}
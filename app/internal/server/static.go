@@ -0,0 +1,85 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"go-logger/internal/buildinfo"
+	"go-logger/static"
+)
+
+// ==========================================
+// 画面のファイルのキャッシュ (Cache-Control / ETag)
+// ==========================================
+// HTML は毎回確かめさせ (no-cache)、中身のハッシュの ETag で変わっていなければ 304 だけ返す
+// ?v= に今のファイルのハッシュ (staticVersion) を付けたものは中身が変わらないので1年キャッシュさせる
+// 画面は GET /api/version の static_version が読み込んだ時と変わったら読み直す（デプロイ後に古い画面が残らない）
+
+// staticImmutable : ?v= が今の版と一致するファイルの Cache-Control
+const staticImmutable = "public, max-age=31536000, immutable"
+
+// staticAssets : 埋め込んだファイルの ETag と、全体のハッシュ（起動後に1回だけ計算する）
+type staticAssets struct {
+	etags   map[string]string // "/index.html" → `"<sha256 の先頭>"`
+	version string
+}
+
+// embeddedAssets : 埋め込んだファイルのハッシュ
+var embeddedAssets = sync.OnceValue(func() staticAssets {
+	assets := staticAssets{etags: map[string]string{}}
+	all := sha256.New()
+	// WalkDir は名前の順に辿るので、全体のハッシュはファイルの並びに左右されない
+	fs.WalkDir(static.Files, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(static.Files, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		assets.etags["/"+name] = `"` + hex.EncodeToString(sum[:8]) + `"`
+		all.Write([]byte(name))
+		all.Write(sum[:])
+		return nil
+	})
+	assets.version = hex.EncodeToString(all.Sum(nil)[:6])
+	return assets
+})
+
+// staticVersion : 画面のファイルの版（STATIC_DIR から配信している間はビルドのバージョン）
+func (s *Server) staticVersion() string {
+	if s.cfg.StaticDir != "" {
+		return "dir-" + buildinfo.Get().Version
+	}
+	return embeddedAssets().version
+}
+
+// staticHandler : 画面のファイルを Cache-Control と ETag 付きで配信する
+// STATIC_DIR から配信する時は画面を作り直しながら確かめるためなので、ETag は付けずに毎回確かめさせる（Last-Modified は付く）
+func (s *Server) staticHandler() http.Handler {
+	files := http.FileServer(s.staticFiles())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path
+		if strings.HasSuffix(name, "/") {
+			name += "index.html"
+		}
+		if v := r.URL.Query().Get("v"); v != "" && v == s.staticVersion() && s.cfg.StaticDir == "" {
+			w.Header().Set("Cache-Control", staticImmutable)
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		// FileServer は書き込む前に付けた ETag で If-None-Match を確かめる
+		if s.cfg.StaticDir == "" {
+			if etag, ok := embeddedAssets().etags[path.Clean(name)]; ok {
+				w.Header().Set("ETag", etag)
+			}
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 保存先の使用量と増加の見込み (GET /api/admin/storage)
+// ==========================================
+// ディスクがいっぱいになる前に RETENTION_DAYS を決められるよう、
+// テーブルごとのデータ・索引のサイズ、最も古いログ、今のペースで増えた場合のサイズを返す
+// 増加ペースは watchVolume のサンプル（24時間分まで）から求め、サンプルが足りない間は最も古いログからの平均を使う
+
+// storageReport : GET /api/admin/storage の応答
+type storageReport struct {
+	SampledAt     time.Time            `json:"sampled_at"`
+	DatabaseBytes int64                `json:"database_bytes"`
+	Tables        []model.TableStorage `json:"tables"`
+	Logs          storageLogs          `json:"logs"`
+	Growth        storageGrowth        `json:"growth"`
+	Projection    storageProjection    `json:"projection"`
+}
+
+// storageLogs : access_logs の行数と最も古いログ
+type storageLogs struct {
+	Rows        int64      `json:"rows"`
+	Oldest      *time.Time `json:"oldest"` // ログがなければ null
+	BytesPerRow float64    `json:"bytes_per_row"`
+}
+
+// storageGrowth : 1時間あたりの増加量
+type storageGrowth struct {
+	Basis        string  `json:"basis"` // samples（VOLUME_SAMPLE_INTERVAL のサンプル）/ lifetime（最も古いログからの平均）
+	BytesPerHour float64 `json:"bytes_per_hour"`
+	RowsPerHour  float64 `json:"rows_per_hour"`
+}
+
+// storageProjection : 今のペースで増え続けた場合のサイズ
+type storageProjection struct {
+	In7dBytes        int64      `json:"in_7d_bytes"`
+	In30dBytes       int64      `json:"in_30d_bytes"`
+	RetentionDays    int        `json:"retention_days"`
+	SteadyStateBytes *int64     `json:"steady_state_bytes"` // RETENTION_DAYS 分のログが溜まって増えなくなった時のサイズ（0 日なら null）
+	LimitBytes       int64      `json:"limit_bytes,omitempty"`
+	LimitReachedAt   *time.Time `json:"limit_reached_at"` // VOLUME_ALERT_MAX_BYTES に届く見込みの時刻（届かなければ null）
+}
+
+// storageHandler : GET /api/admin/storage
+func (s *Server) storageHandler(w http.ResponseWriter, r *http.Request) {
+	usage, err := s.store.StorageUsage(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	oldest, err := s.store.OldestLogTime(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	now := s.clock.Now().UTC()
+	logs := usage.Table("access_logs")
+	resp := storageReport{SampledAt: now, DatabaseBytes: usage.DatabaseBytes, Tables: usage.Tables, Logs: storageLogs{Rows: logs.Rows}}
+	if resp.Tables == nil {
+		resp.Tables = []model.TableStorage{}
+	}
+	if logs.Rows > 0 {
+		resp.Logs.BytesPerRow = float64(logs.TotalBytes) / float64(logs.Rows)
+	}
+	if !oldest.IsZero() {
+		resp.Logs.Oldest = &oldest
+	}
+
+	s.volume.mu.Lock()
+	history := append([]model.VolumeSample(nil), s.volume.history...)
+	s.volume.mu.Unlock()
+	g := storageGrowth{Basis: "samples"}
+	g.BytesPerHour, g.RowsPerHour = growthPerHour(history)
+	if len(history) < 2 && !oldest.IsZero() {
+		g.Basis = "lifetime"
+		// 記録を始めたばかりで1時間に満たない時は1時間として平均する（数分分のログから大きすぎる値を出さない）
+		hours := max(now.Sub(oldest).Hours(), 1)
+		g.BytesPerHour, g.RowsPerHour = float64(logs.TotalBytes)/hours, float64(logs.Rows)/hours
+	}
+	resp.Growth = g
+
+	p := storageProjection{
+		In7dBytes:     usage.DatabaseBytes + int64(g.BytesPerHour*24*7),
+		In30dBytes:    usage.DatabaseBytes + int64(g.BytesPerHour*24*30),
+		RetentionDays: s.tunables().RetentionDays,
+		LimitBytes:    s.cfg.VolumeAlertMaxBytes,
+	}
+	if p.RetentionDays > 0 {
+		// ログ以外のテーブルはそのまま、ログは保持期間分の行数 × 1行あたりのサイズ
+		steady := usage.DatabaseBytes - logs.TotalBytes + int64(g.RowsPerHour*24*float64(p.RetentionDays)*resp.Logs.BytesPerRow)
+		p.SteadyStateBytes = &steady
+	}
+	if p.LimitBytes > 0 && g.BytesPerHour > 0 && (p.SteadyStateBytes == nil || *p.SteadyStateBytes > p.LimitBytes) {
+		at := now
+		if remaining := p.LimitBytes - usage.DatabaseBytes; remaining > 0 {
+			at = now.Add(time.Duration(float64(remaining) / g.BytesPerHour * float64(time.Hour)))
+		}
+		p.LimitReachedAt = &at
+	}
+	resp.Projection = p
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
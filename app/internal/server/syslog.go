@@ -0,0 +1,339 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// syslog の受け口 (UDP / TCP)
+// ==========================================
+// ネットワーク機器や古いデーモンの syslog (RFC 3164 / RFC 5424) をそのまま受け取り、event_type=syslog のログとして保存する
+// SYSLOG_UDP_ADDR / SYSLOG_TCP_ADDR を設定した場合のみ待ち受ける（プロジェクトはデフォルトプロジェクト）
+// TCP は1行に1件（LF 区切り）と、RFC 6587 の octet-counting（"<長さ> <メッセージ>"）のどちらでも受け付ける
+
+// syslogEventType : syslog で受け取ったログのイベント種別
+const syslogEventType = "syslog"
+
+const (
+	// maxSyslogMessageBytes : 1件の上限（超えた分は切り捨てる）
+	maxSyslogMessageBytes = 64 << 10
+	// syslogIdleTimeout : TCP の接続で何も届かなければ切る時間
+	syslogIdleTimeout = 5 * time.Minute
+)
+
+// syslogFacilities : facility の番号 → 名前
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// syslogLevels : severity (0〜7) → ログのレベル
+var syslogLevels = []string{"fatal", "fatal", "fatal", "error", "warn", "info", "info", "debug"}
+
+// syslogMessage : 読み取った1件
+type syslogMessage struct {
+	Facility       int
+	Severity       int
+	Timestamp      time.Time // 書かれていなければゼロ
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData string // RFC 5424 の [id key="value"]...（そのままの文字列）
+	Message        string
+}
+
+// parseSyslog : "<PRI>" で始まる1件を RFC 5424 か RFC 3164 として読む
+// RFC 3164 の時刻には年とタイムゾーンがないので、now の年と loc で補う
+func parseSyslog(line string, now time.Time, loc *time.Location) (syslogMessage, error) {
+	var m syslogMessage
+	if loc == nil {
+		loc = time.UTC
+	}
+	line = strings.TrimRight(line, "\r\n\x00")
+	rest, ok := strings.CutPrefix(line, "<")
+	if !ok {
+		return m, errors.New(`missing "<PRI>"`)
+	}
+	priText, rest, ok := strings.Cut(rest, ">")
+	pri, err := strconv.Atoi(priText)
+	if !ok || err != nil || pri < 0 || pri > 191 {
+		return m, fmt.Errorf("invalid PRI %q", priText)
+	}
+	m.Facility, m.Severity = pri/8, pri%8
+
+	if v, ok := strings.CutPrefix(rest, "1 "); ok {
+		return m, m.parse5424(v)
+	}
+	m.parse3164(rest, now, loc)
+	return m, nil
+}
+
+// parse5424 : "VERSION " の後ろ（TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]）
+func (m *syslogMessage) parse5424(rest string) error {
+	var header [5]string
+	for i := range header {
+		var ok bool
+		header[i], rest, ok = strings.Cut(rest, " ")
+		if !ok && i < len(header)-1 {
+			return errors.New("truncated RFC 5424 header")
+		}
+	}
+	nilValue := func(v string) string {
+		if v == "-" {
+			return ""
+		}
+		return v
+	}
+	if ts := nilValue(header[0]); ts != "" {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q", ts)
+		}
+		m.Timestamp = t
+	}
+	m.Hostname, m.AppName, m.ProcID, m.MsgID = nilValue(header[1]), nilValue(header[2]), nilValue(header[3]), nilValue(header[4])
+
+	// STRUCTURED-DATA は "-" か、[...] の並び（値の中の \] と \" はエスケープ）
+	if v, ok := strings.CutPrefix(rest, "-"); ok {
+		rest = v
+	} else {
+		end, inQuote := 0, false
+	scan:
+		for end < len(rest) {
+			switch c := rest[end]; {
+			case inQuote && c == '\\':
+				end++
+			case c == '"':
+				inQuote = !inQuote
+			case !inQuote && c == ']' && (end+1 == len(rest) || rest[end+1] != '['):
+				end++
+				break scan
+			}
+			end++
+		}
+		m.StructuredData, rest = rest[:end], rest[end:]
+	}
+	rest = strings.TrimPrefix(rest, " ")
+	m.Message = strings.TrimPrefix(rest, "\ufeff")
+	return nil
+}
+
+// parse3164 : "<PRI>" の後ろ（Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG）
+// 形が崩れていれば、読めたところまで埋めて残りを本文にする
+func (m *syslogMessage) parse3164(rest string, now time.Time, loc *time.Location) {
+	if len(rest) >= len(time.Stamp) {
+		if t, err := time.ParseInLocation(time.Stamp, rest[:len(time.Stamp)], loc); err == nil {
+			local := now.In(loc)
+			t = t.AddDate(local.Year(), 0, 0)
+			// 年をまたいだ直後に届いた前の年の分
+			if t.After(local.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			m.Timestamp = t
+			rest = strings.TrimPrefix(rest[len(time.Stamp):], " ")
+			m.Hostname, rest, _ = strings.Cut(rest, " ")
+		}
+	}
+	// TAG は英数字で32文字まで。その後ろの [PID] と ": " を取り除く
+	if i := strings.IndexAny(rest, "[: "); i > 0 && i <= 32 && rest[i] != ' ' {
+		m.AppName, rest = rest[:i], rest[i:]
+		if v, ok := strings.CutPrefix(rest, "["); ok {
+			if pid, after, ok := strings.Cut(v, "]"); ok {
+				m.ProcID, rest = pid, after
+			}
+		}
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest, ":"), " ")
+	}
+	m.Message = rest
+}
+
+// write : 保存する形にする（時刻が書かれていなければ受け取った時刻）
+func (m syslogMessage) write(remoteIP string, now time.Time) model.Write {
+	fields := map[string]any{
+		"facility": syslogFacilities[m.Facility],
+		"severity": m.Severity,
+	}
+	for k, v := range map[string]string{
+		"hostname": m.Hostname, "app_name": m.AppName, "proc_id": m.ProcID,
+		"msg_id": m.MsgID, "structured_data": m.StructuredData,
+	} {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+	b, _ := json.Marshal(fields)
+
+	createdAt := now
+	if !m.Timestamp.IsZero() {
+		createdAt = m.Timestamp.UTC()
+	}
+	message := m.Message
+	if message == "" {
+		message = "(empty)"
+	}
+	return model.Write{
+		ProjectID: model.DefaultProjectID,
+		IP:        remoteIP,
+		EventType: syslogEventType,
+		Level:     syslogLevels[m.Severity],
+		Message:   message,
+		Fields:    b,
+		CreatedAt: createdAt,
+	}
+}
+
+// handleSyslog : 1件を読んで保存し、通知のルールに合えば通知する（読めないものは捨てる）
+func (s *Server) handleSyslog(ctx context.Context, line, remoteIP string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	now := s.clock.Now()
+	m, err := parseSyslog(line, now, s.cfg.Location)
+	if err != nil {
+		fmt.Printf("Dropping syslog message from %s: %v\n", remoteIP, err)
+		return
+	}
+	lw := m.write(remoteIP, now)
+	if _, stored, _ := s.saveWrite(ctx, &lw); stored {
+		s.notifyLog(ctx, &lw)
+	}
+}
+
+// listenSyslog : SYSLOG_UDP_ADDR / SYSLOG_TCP_ADDR で待ち受ける（ctx が終わるまで動き続ける）
+// 待ち受けに失敗しても HTTP のサーバーは止めない
+func (s *Server) listenSyslog(ctx context.Context) {
+	if addr := s.cfg.SyslogUDPAddr; addr != "" {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			fmt.Println("Failed to listen for syslog (udp):", err)
+		} else {
+			fmt.Println("Listening for syslog on udp", conn.LocalAddr())
+			go func() {
+				<-ctx.Done()
+				conn.Close()
+			}()
+			go s.serveSyslogUDP(ctx, conn)
+		}
+	}
+	if addr := s.cfg.SyslogTCPAddr; addr != "" {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			fmt.Println("Failed to listen for syslog (tcp):", err)
+		} else {
+			fmt.Println("Listening for syslog on tcp", ln.Addr())
+			go func() {
+				<-ctx.Done()
+				ln.Close()
+			}()
+			go s.serveSyslogTCP(ctx, ln)
+		}
+	}
+}
+
+// serveSyslogUDP : 1つのデータグラムを1件として読む
+func (s *Server) serveSyslogUDP(ctx context.Context, conn net.PacketConn) {
+	buf := make([]byte, maxSyslogMessageBytes)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Println("Syslog (udp) stopped:", err)
+			}
+			return
+		}
+		s.handleSyslog(ctx, string(buf[:n]), remoteHost(addr))
+	}
+}
+
+// serveSyslogTCP : 接続ごとに読む
+func (s *Server) serveSyslogTCP(ctx context.Context, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Println("Syslog (tcp) stopped:", err)
+			}
+			return
+		}
+		go s.serveSyslogConn(ctx, conn)
+	}
+}
+
+// serveSyslogConn : 先頭が数字なら octet-counting、そうでなければ LF 区切りで読む
+func (s *Server) serveSyslogConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	remoteIP := remoteHost(conn.RemoteAddr())
+	r := bufio.NewReaderSize(conn, maxSyslogMessageBytes)
+	for {
+		conn.SetReadDeadline(time.Now().Add(syslogIdleTimeout))
+		line, err := readSyslogFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				fmt.Printf("Closing syslog connection from %s: %v\n", remoteIP, err)
+			}
+			return
+		}
+		s.handleSyslog(ctx, line, remoteIP)
+	}
+}
+
+// readSyslogFrame : TCP から1件読む
+func readSyslogFrame(r *bufio.Reader) (string, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+	if first[0] >= '0' && first[0] <= '9' {
+		lenText, err := r.ReadString(' ')
+		if err != nil {
+			return "", err
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(lenText, " "))
+		if err != nil || n <= 0 || n > maxSyslogMessageBytes {
+			return "", fmt.Errorf("invalid frame length %q", lenText)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		// 長すぎる行は上限で切り、残りは読み捨てる
+		truncated := string(line)
+		for errors.Is(err, bufio.ErrBufferFull) {
+			_, err = r.ReadSlice('\n')
+		}
+		return truncated, nil
+	}
+	if err != nil && len(line) == 0 {
+		return "", err
+	}
+	return string(line), nil
+}
+
+// remoteHost : 送信元のアドレスからポートを除く
+func remoteHost(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-logger/internal/config"
+	"go-logger/internal/coord"
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+)
+
+// ==========================================
+// 送り元ごとの通知の上限 (NOTIFY_THROTTLE)
+// ==========================================
+// 1つのクライアントが大量にアクセスしても、同じ通知が何百通も届かないようにする
+// 例: NOTIFY_THROTTLE=ip=1/1h,project=100/1h （IPごとに1時間に1通、プロジェクトごとに1時間に100通まで）
+// 上限を超えた分は送らずに数え、期間が終わったら「ほかに N 件」の通知を1通だけ送る
+// 対象は新しいログの通知だけ（監視リスト・ルール・まとめなどの通知には使わない）
+// COORD_BACKEND=redis なら全てのインスタンスで合わせて数える（送らなかった件数はインスタンスごとに送る）
+
+// throttleFlushInterval : 期間の終わった上限を確かめる間隔
+const throttleFlushInterval = time.Minute
+
+// throttleKeys : 上限を分ける単位 → ログの値
+var throttleKeys = map[string]func(e *model.LogEntry) string{
+	"ip":      func(e *model.LogEntry) string { return e.IP },
+	"ua":      func(e *model.LogEntry) string { return e.UserAgent },
+	"project": func(e *model.LogEntry) string { return strconv.Itoa(e.ProjectID) },
+}
+
+// NotifyThrottle : 送り元ごとの通知の上限1つ
+type NotifyThrottle struct {
+	By    string // ip / ua / project
+	Limit coord.Limit
+}
+
+// NotifyThrottles : NOTIFY_THROTTLE の上限（全て満たした通知だけ送る）
+type NotifyThrottles []NotifyThrottle
+
+func (t NotifyThrottles) String() string {
+	if len(t) == 0 {
+		return "off"
+	}
+	items := make([]string, len(t))
+	for i, th := range t {
+		items[i] = th.By + "=" + th.Limit.String()
+	}
+	return strings.Join(items, ",")
+}
+
+// parseNotifyThrottles : "ip=1/1h,ua=10/1h" の形
+func parseNotifyThrottles(spec string) (NotifyThrottles, error) {
+	var throttles NotifyThrottles
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		by, limit, ok := strings.Cut(item, "=")
+		by = strings.TrimSpace(by)
+		if !ok {
+			return nil, fmt.Errorf("invalid throttle %q (use ip|ua|project=<count>/<duration>)", item)
+		}
+		if _, known := throttleKeys[by]; !known {
+			return nil, fmt.Errorf("throttle %q: unknown key %q (use ip, ua or project)", item, by)
+		}
+		l, err := coord.ParseLimit(limit)
+		if err != nil {
+			return nil, fmt.Errorf("throttle %q: %w", item, err)
+		}
+		if !l.Enabled() {
+			continue
+		}
+		throttles = append(throttles, NotifyThrottle{By: by, Limit: l})
+	}
+	return throttles, nil
+}
+
+// notifyThrottlesFromEnv : NOTIFY_THROTTLE（既定は上限なし。不正なら上限なし）
+func notifyThrottlesFromEnv() NotifyThrottles {
+	throttles, err := parseNotifyThrottles(config.String("NOTIFY_THROTTLE", ""))
+	if err != nil {
+		fmt.Println("Ignoring NOTIFY_THROTTLE:", err)
+		return nil
+	}
+	return throttles
+}
+
+// throttledSource : 上限を超えて送らなかった1つの送り元
+type throttledSource struct {
+	throttle NotifyThrottle
+	value    string
+	count    int
+	until    time.Time // 上限の期間が終わる時刻（この後に件数を送る）
+	last     string    // 最後に送らなかった通知の本文
+}
+
+// throttleState : 送らなかった件数（送り元ごと）
+type throttleState struct {
+	mu      sync.Mutex
+	sources map[string]*throttledSource
+}
+
+// add : 送らなかった通知を1件数える
+func (t *throttleState) add(key string, th NotifyThrottle, value string, until time.Time, text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sources == nil {
+		t.sources = map[string]*throttledSource{}
+	}
+	src, ok := t.sources[key]
+	if !ok {
+		src = &throttledSource{throttle: th, value: value}
+		t.sources[key] = src
+	}
+	src.count++
+	src.until = until
+	src.last = text
+}
+
+// due : 期間の終わった送り元を取り出す（古い順）
+func (t *throttleState) due(now time.Time) []throttledSource {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var due []throttledSource
+	for key, src := range t.sources {
+		if !now.Before(src.until) {
+			due = append(due, *src)
+			delete(t.sources, key)
+		}
+	}
+	slices.SortFunc(due, func(a, b throttledSource) int { return a.until.Compare(b.until) })
+	return due
+}
+
+// throttleNotification : 送り元ごとの上限以内なら true（超えていれば数えて false）
+// 数えられない場合（Redis に繋がらないなど）は送る
+func (s *Server) throttleNotification(ctx context.Context, n notify.Notification) bool {
+	if n.Entry == nil || n.Source != "" {
+		return true
+	}
+	for _, th := range s.tunables().NotifyThrottles {
+		value := throttleKeys[th.By](n.Entry)
+		if value == "" {
+			continue
+		}
+		key := "notify-throttle:" + th.By + ":" + value
+		ok, retryAfter, err := s.coord.Allow(ctx, key, th.Limit)
+		if err != nil {
+			fmt.Println("Notification throttle check failed:", err)
+			continue
+		}
+		if !ok {
+			s.throttled.add(key, th, value, s.clock.Now().Add(retryAfter), n.Text)
+			return false
+		}
+	}
+	return true
+}
+
+// watchThrottles : 期間の終わった送り元ごとに、送らなかった件数を1通にまとめて送る
+func (s *Server) watchThrottles(ctx context.Context) {
+	ticker := s.clock.NewTicker(throttleFlushInterval)
+	defer ticker.Stop()
+	j := s.jobs.register("notify-throttle", throttleFlushInterval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		s.runJob(j, func() error {
+			for _, src := range s.throttled.due(s.clock.Now()) {
+				text := fmt.Sprintf("🔕 %d more notifications from %s %s were not sent (limit %s)\nLast: %s",
+					src.count, src.throttle.By, src.value, src.throttle.Limit, src.last)
+				s.notifyAll(ctx, notify.Notification{
+					Level: "info", Title: "Notifications throttled", Text: text,
+					Source: "throttle", Key: "throttle:" + src.throttle.By + ":" + src.value,
+				})
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 区間ごとの件数 (GET /api/stats/timeseries。ダッシュボードのグラフ用)
+// ==========================================
+
+// maxTimeseriesPoints : 1回に返す区間の数の上限
+const maxTimeseriesPoints = 2000
+
+// timeseriesHandler : GET /api/stats/timeseries?interval=1h&from=&to=&type=&tz=Asia/Tokyo
+// from / to (RFC3339) を省略すると直近24時間 (?period=7d でも指定できる)。件数0の区間も含めて古い順に返す
+// interval は 1m 以上 (例: 5m, 1h, 1d)。区間の境目は tz の0時に揃える
+func (s *Server) timeseriesHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		loc, ok := s.responseLocation(w, r)
+		if !ok {
+			return
+		}
+		format, ok := negotiateFormat(w, r)
+		if !ok {
+			return
+		}
+		raw := r.URL.Query().Get("interval")
+		if raw == "" {
+			raw = "1h"
+		}
+		interval, err := parseDurationDays(raw)
+		if err != nil || interval < time.Minute || interval%time.Second != 0 {
+			http.Error(w, `Invalid "interval" (e.g. 5m, 1h or 1d; at least 1m)`, http.StatusBadRequest)
+			return
+		}
+
+		f, err := logFilterFromQuery(r.URL.Query(), projectID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Extrapolate = s.extrapolate(r)
+		now := s.clock.Now()
+		if f.Since, f.Until, err = timeRangeFromQuery(r.URL.Query(), now, 24*time.Hour); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if f.Until.IsZero() {
+			f.Until = now
+		}
+		if n := f.Until.Sub(f.Since) / interval; n > maxTimeseriesPoints {
+			http.Error(w, fmt.Sprintf("Too many points (%d, max %d): use a longer interval or a shorter range", n, maxTimeseriesPoints), http.StatusBadRequest)
+			return
+		}
+
+		origin := time.Date(2000, 1, 1, 0, 0, 0, 0, loc)
+		points, err := s.store.Timeseries(r.Context(), f, interval, origin)
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+		result := model.Timeseries{
+			From:            f.Since,
+			To:              f.Until,
+			Interval:        raw,
+			IntervalSeconds: int64(interval / time.Second),
+			Points:          points,
+		}
+		inLocation(&result, loc)
+
+		writeFormatted(w, format, "timeseries", result)
+	})
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// 返す時刻のタイムゾーン (?tz=Asia/Tokyo。DBには常に UTC の TIMESTAMPTZ で保存する)
+// ==========================================
+
+// timezoneFromEnv : TIMEZONE（既定 UTC）。?tz のない API の応答と、レポートの日付の区切りに使う
+func timezoneFromEnv() *time.Location {
+	name := config.String("TIMEZONE", "UTC")
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		fmt.Printf("Ignoring TIMEZONE=%q: %v\n", name, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// responseLocation : ?tz（なければ TIMEZONE）。不正な値なら 400 を返して false
+func (s *Server) responseLocation(w http.ResponseWriter, r *http.Request) (*time.Location, bool) {
+	name := r.URL.Query().Get("tz")
+	if name == "" {
+		if s.cfg.Location == nil {
+			return time.UTC, true
+		}
+		return s.cfg.Location, true
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid tz %q (use an IANA name such as Asia/Tokyo)", name), http.StatusBadRequest)
+		return nil, false
+	}
+	return loc, true
+}
+
+// inLocation : v（ポインタかスライス）の中の時刻を全て loc の時刻に直す（同じ時点のまま表記だけ変わる）
+// ゼロの時刻（未設定）はそのままにする
+func inLocation(v any, loc *time.Location) {
+	if loc == nil || loc == time.UTC {
+		return
+	}
+	setLocation(reflect.ValueOf(v), loc)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func setLocation(v reflect.Value, loc *time.Location) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		// *time.Time は他と共有していることがある（キャッシュなど）ので、指す先は書き換えずに差し替える
+		if v.Type().Elem() == timeType {
+			if t := v.Elem().Interface().(time.Time); v.CanSet() && !t.IsZero() {
+				t = t.In(loc)
+				v.Set(reflect.ValueOf(&t))
+			}
+			return
+		}
+		setLocation(v.Elem(), loc)
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			setLocation(v.Index(i), loc)
+		}
+	case reflect.Map:
+		// map の値は書き換えられないので、直した値を入れ直す
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			setLocation(elem, loc)
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			if t := v.Interface().(time.Time); v.CanSet() && !t.IsZero() {
+				v.Set(reflect.ValueOf(t.In(loc)))
+			}
+			return
+		}
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				setLocation(v.Field(i), loc)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 上位の一覧 (GET /api/stats/top。ダッシュボードのランキング用)
+// ==========================================
+
+// topStats : GET /api/stats/top の結果
+type topStats struct {
+	By    string         `json:"by"`
+	Since time.Time      `json:"since"`
+	Until *time.Time     `json:"until,omitempty"`
+	Items []model.Bucket `json:"items"` // 件数の多い順（値のないログは key が ""）
+}
+
+// topField : by の値 → 表示ルール (REDACT_RULES) で確かめる LogEntry の項目
+var topField = map[string]string{"referrer_domain": "referrer"}
+
+// topHandler : GET /api/stats/top?by=user_agent&limit=10&from=&to=&type=
+// by には user_agent, ip, path, country, browser, os, device, level, event_type, referrer_domain, referrer を使える
+// 期間は from / to (RFC3339)、?period=7d（既定 24h）。?level= や ?field.x= の絞り込みは /api/logs と同じ
+func (s *Server) topHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		loc, ok := s.responseLocation(w, r)
+		if !ok {
+			return
+		}
+		format, ok := negotiateFormat(w, r)
+		if !ok {
+			return
+		}
+		by := strings.ToLower(r.URL.Query().Get("by"))
+		if _, ok := store.GroupByColumns[strings.ToUpper(by)]; !ok {
+			names := make([]string, 0, len(store.GroupByColumns))
+			for name := range store.GroupByColumns {
+				names = append(names, strings.ToLower(name))
+			}
+			sort.Strings(names)
+			http.Error(w, fmt.Sprintf(`Invalid "by" (use %s)`, strings.Join(names, ", ")), http.StatusBadRequest)
+			return
+		}
+		// 読み手から隠している項目の値は、集計の結果としても返さない
+		field := by
+		if f, ok := topField[by]; ok {
+			field = f
+		}
+		if s.redact.Hidden(field, s.requestScope(r)) {
+			http.Error(w, fmt.Sprintf("%s is redacted for this reader", field), http.StatusForbidden)
+			return
+		}
+
+		f, err := logFilterFromQuery(r.URL.Query(), projectID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Extrapolate = s.extrapolate(r)
+		if f.Since, f.Until, err = timeRangeFromQuery(r.URL.Query(), s.clock.Now(), 24*time.Hour); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Limit = 10
+		if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 200 {
+			f.Limit = v
+		}
+
+		items, err := s.store.GroupLogs(r.Context(), f, strings.ToUpper(by))
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+		result := topStats{By: by, Since: f.Since, Items: items}
+		if !f.Until.IsZero() {
+			result.Until = &f.Until
+		}
+		inLocation(&result, loc)
+
+		writeFormatted(w, format, "top", result)
+	})
+}
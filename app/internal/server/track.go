@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"go-logger/internal/i18n"
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// ページごとの記録 (POST /api/track/{site}/{path...})
+// ==========================================
+// どのページにも同じ形の呼び出しを置くだけで、ページを区別して記録する（?path= を組み立てなくてよい）
+//
+//	navigator.sendBeacon("https://dev.aliceindex.jp/go/api/track/blog" + location.pathname)
+//
+// /api/track/ の後ろの1段目をサイト名 (fields.site)、残りを記録するパスにする（/api/track/blog/ はサイトのトップ "/"）
+// 種別の既定は pageview（?event_type= で変えられる）。プロジェクトは他の書き込みと同じく X-API-Key か ?key= で決まる
+
+// trackedSite : サイト名（英数字と "_" "-" "."、128文字まで。ドメイン名もそのまま使える）
+var trackedSite = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,127}$`)
+
+// normalizeSite : サイト名を小文字にして確かめる
+func normalizeSite(site string) (string, error) {
+	site = strings.ToLower(strings.TrimSpace(site))
+	if !trackedSite.MatchString(site) {
+		return "", fmt.Errorf(`invalid site %q (use letters, digits, "_", "-" or ".")`, site)
+	}
+	return site, nil
+}
+
+// siteFields : サイト名だけの fields
+func siteFields(site string) []byte {
+	fields, _ := json.Marshal(map[string]string{"site": site})
+	return fields
+}
+
+// trackHandler : /api/track/{site}/{path...} のアクセスを、後ろのパスをページとして記録する
+func (s *Server) trackHandler(w http.ResponseWriter, r *http.Request) {
+	site, err := normalizeSite(r.PathValue("site"))
+	if err != nil {
+		http.Error(w, i18n.T(w, r, i18n.InvalidRequest, err), http.StatusBadRequest)
+		return
+	}
+	s.withProject(w, r, func(projectID int) {
+		lw, err := s.accessWrite(w, r, projectID, model.EventPageview)
+		if err != nil {
+			http.Error(w, i18n.T(w, r, i18n.InvalidRequest, err), http.StatusBadRequest)
+			return
+		}
+		lw.Path, lw.Fields = "/"+r.PathValue("path"), siteFields(site)
+		status, err := s.recordAccess(r.Context(), &lw)
+		s.accessResult(w, r, &lw, status, err)
+	})
+}
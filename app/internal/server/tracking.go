@@ -0,0 +1,50 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 記録対象パスの設定
+// ==========================================
+
+// TrackedPath : 記録対象のパス（末尾が "/" ならプレフィックス一致）とイベント種別
+type TrackedPath struct {
+	Pattern   string
+	EventType string
+}
+
+// defaultTrackedPath : 従来からの書き込みAPI
+var defaultTrackedPath = TrackedPath{Pattern: "/api/", EventType: model.EventAccess}
+
+// TrackedPathsFromEnv : TRACKED_PATHS からパスとラベルの組を読み込む
+// 例: TRACKED_PATHS="/ping=ping,/rss-hit=rss,/newsletter-open=newsletter"
+// ラベルを省略した場合はパスからイベント種別を作る（"/rss-hit" → "rss-hit"）
+func TrackedPathsFromEnv() []TrackedPath {
+	paths := []TrackedPath{defaultTrackedPath}
+	seen := map[string]bool{defaultTrackedPath.Pattern: true}
+
+	for _, item := range config.List("TRACKED_PATHS") {
+		pattern, label, _ := strings.Cut(item, "=")
+		pattern = strings.TrimSpace(pattern)
+		label = strings.TrimSpace(label)
+		if !strings.HasPrefix(pattern, "/") {
+			fmt.Printf("Ignoring tracked path %q: must start with /\n", pattern)
+			continue
+		}
+		if seen[pattern] {
+			fmt.Printf("Ignoring duplicate tracked path %q\n", pattern)
+			continue
+		}
+		if label == "" {
+			label = strings.Trim(pattern, "/")
+		}
+		seen[pattern] = true
+		paths = append(paths, TrackedPath{Pattern: pattern, EventType: label})
+	}
+	return paths
+}
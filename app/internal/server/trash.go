@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/redact"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// ゴミ箱 (DELETE /api/logs/{id} → GET /api/logs/trash → POST /api/logs/{id}/restore)
+// ==========================================
+// 削除は既定でゴミ箱に移すだけにして、誤って消した証拠を戻せるようにする
+// ゴミ箱のログはほかの読み出し・集計・通知の対象から外れ、TRASH_RETENTION_DAYS を過ぎると定期処理で本当に削除する
+// ?permanent=true（ADMIN_TOKEN のみ）ならゴミ箱を通さずにすぐ削除する
+// 保存期間 (RETENTION_DAYS) と削除の依頼 (/api/privacy/delete) はゴミ箱を通さない
+
+// logIDFromPath : {id} のログID（不正なら 400 を返して false）
+func logIDFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid log id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// deleteLogHandler : DELETE /api/logs/{id}[?permanent=true]
+func (s *Server) deleteLogHandler(w http.ResponseWriter, r *http.Request) {
+	scope := s.requestScope(r)
+	if scope < redact.User {
+		http.Error(w, "Login or admin token required", http.StatusForbidden)
+		return
+	}
+	id, ok := logIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	loc, ok := s.responseLocation(w, r)
+	if !ok {
+		return
+	}
+
+	if r.URL.Query().Get("permanent") == "true" {
+		if scope < redact.Admin {
+			http.Error(w, "Admin token required to delete permanently", http.StatusForbidden)
+			return
+		}
+		n, err := s.store.DeleteLogs(r.Context(), []int{id})
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+		if n == 0 {
+			http.Error(w, "Log not found", http.StatusNotFound)
+			return
+		}
+		s.recent.invalidate()
+		fmt.Printf("Log %d deleted permanently\n", id)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	e, err := s.store.TrashLog(r.Context(), id, s.clock.Now())
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Log not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.recent.invalidateProject(e.ProjectID)
+	writeLogEntry(w, s.redact.Entry(e, scope), loc)
+}
+
+// restoreLogHandler : POST /api/logs/{id}/restore
+func (s *Server) restoreLogHandler(w http.ResponseWriter, r *http.Request) {
+	scope := s.requestScope(r)
+	if scope < redact.User {
+		http.Error(w, "Login or admin token required", http.StatusForbidden)
+		return
+	}
+	id, ok := logIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	loc, ok := s.responseLocation(w, r)
+	if !ok {
+		return
+	}
+	e, err := s.store.RestoreLog(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Log not found in trash", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.recent.invalidateProject(e.ProjectID)
+	writeLogEntry(w, s.redact.Entry(e, scope), loc)
+}
+
+// writeLogEntry : 1件のログ（伏せた後のもの）を loc の時刻で JSON で返す
+func writeLogEntry(w http.ResponseWriter, e model.LogEntry, loc *time.Location) {
+	inLocation(&e, loc)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
+// trashHandler : GET /api/logs/trash（GET /api/logs と同じ絞り込み・ページング。新しく削除したものとは限らず id の順）
+func (s *Server) trashHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		loc, ok := s.responseLocation(w, r)
+		if !ok {
+			return
+		}
+		format, ok := negotiateFormat(w, r)
+		if !ok {
+			return
+		}
+		f, err := logFilterFromQuery(r.URL.Query(), projectID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Trash = true
+		logs, err := s.store.QueryLogs(r.Context(), f)
+		if err != nil {
+			s.dbError(w, r, err)
+			return
+		}
+		if len(logs) > 0 && len(logs) == limitOrDefault(f.Limit) {
+			setNextCursor(w, r, f, logs[len(logs)-1])
+		}
+		logs = s.redact.Entries(logs, s.requestScope(r))
+		inLocation(logs, loc)
+		writeFormatted(w, format, "logs", logs)
+	})
+}
+
+// watchTrash : 1時間ごとに、TRASH_RETENTION_DAYS より前にゴミ箱に移したログを削除する（0 なら削除しない）
+func (s *Server) watchTrash(ctx context.Context) {
+	if s.cfg.DryRun || s.cfg.TrashRetentionDays <= 0 {
+		return
+	}
+	ticker := s.clock.NewTicker(time.Hour)
+	defer ticker.Stop()
+	j := s.jobs.register("trash", time.Hour).leaderOnly()
+
+	for {
+		s.runJob(j, func() error {
+			n, err := s.store.PurgeTrash(ctx, s.clock.Now().AddDate(0, 0, -s.cfg.TrashRetentionDays))
+			if err != nil {
+				fmt.Println("Emptying trash failed:", err)
+			} else if n > 0 {
+				fmt.Printf("Emptied %d events from the trash\n", n)
+			}
+			return err
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 期間ごとの上位の比較 (今週と先週の上位のUA・パス・国を比べ、新しく現れたもの・消えたものを通知する)
+// ==========================================
+
+// trendWideFactor : 上位に入ったか・消えたかを判定するために、上位の何倍まで件数を読むか
+// 11位から10位に上がっただけのものを「新しく現れた」と数えないため
+const trendWideFactor = 5
+
+// TrendConfig : 比較の設定
+type TrendConfig struct {
+	Interval   time.Duration // 比較する間隔（0なら比較しない）
+	Period     time.Duration // 比べる期間の長さ（直近 Period とその前の Period）
+	Top        int           // 上位何件を比べるか
+	MinEvents  int           // これより少ない件数の変化は通知しない
+	Dimensions []string      // 比べる列 (store.GroupByColumns の名前)
+	EventType  string        // 対象の種別（空なら全種別）
+}
+
+// TrendsFromEnv : TREND_INTERVAL を設定した時だけ有効にする
+//
+//	TREND_INTERVAL    比較する間隔（例: 24h, 7d）
+//	TREND_PERIOD      比べる期間の長さ（既定 7d）
+//	TREND_TOP         上位何件を比べるか（既定 10）
+//	TREND_MIN_EVENTS  通知する最小の件数（既定 20）
+//	TREND_DIMENSIONS  比べる列（既定 user_agent,path,country）
+func TrendsFromEnv() TrendConfig {
+	cfg := TrendConfig{
+		Top:        config.Int("TREND_TOP", 10),
+		MinEvents:  config.Int("TREND_MIN_EVENTS", 20),
+		Dimensions: []string{"USER_AGENT", "PATH", "COUNTRY"},
+		EventType:  config.String("TREND_EVENT_TYPE", ""),
+		Period:     7 * 24 * time.Hour,
+	}
+	for key, dst := range map[string]*time.Duration{"TREND_INTERVAL": &cfg.Interval, "TREND_PERIOD": &cfg.Period} {
+		raw := config.String(key, "")
+		if raw == "" {
+			continue
+		}
+		d, err := parseDurationDays(raw)
+		if err != nil || d <= 0 {
+			fmt.Printf("Ignoring %s=%q (use e.g. 24h or 7d)\n", key, raw)
+			continue
+		}
+		*dst = d
+	}
+	if dims := config.List("TREND_DIMENSIONS"); len(dims) > 0 {
+		cfg.Dimensions = nil
+		for _, d := range dims {
+			d = strings.ToUpper(d)
+			if _, ok := store.GroupByColumns[d]; !ok {
+				fmt.Printf("Ignoring unknown TREND_DIMENSIONS entry %q\n", d)
+				continue
+			}
+			cfg.Dimensions = append(cfg.Dimensions, d)
+		}
+	}
+	return cfg
+}
+
+// trendState : 通知済みの変化（期間が重なる間は同じ変化を繰り返し通知しない）
+type trendState struct {
+	mu       sync.Mutex
+	reported map[string]time.Time
+}
+
+// trendChange : 上位に現れた・上位から消えた値1つ分
+type trendChange struct {
+	Key      string
+	Count    int // 今の期間の件数
+	Previous int // 前の期間の件数
+}
+
+// watchTrends : 定期的に全プロジェクトの上位を前の期間と比べる
+func (s *Server) watchTrends(ctx context.Context) {
+	cfg := s.cfg.Trends
+	if cfg.Interval <= 0 || len(cfg.Dimensions) == 0 {
+		return
+	}
+	ticker := s.clock.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	j := s.jobs.register("trends", cfg.Interval).leaderOnly()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		s.runJob(j, func() error {
+			projects, err := s.store.ListProjects(ctx)
+			if err != nil {
+				fmt.Println("Trend comparison failed:", err)
+				return err
+			}
+			for _, p := range projects {
+				if compareErr := s.compareTrends(ctx, p.ID, p.Name); compareErr != nil {
+					fmt.Printf("Trend comparison failed for project %d: %v\n", p.ID, compareErr)
+					err = compareErr
+				}
+			}
+			return err
+		})
+	}
+}
+
+// compareTrends : 1プロジェクト分を比べ、変化があれば1件の通知にまとめて送る
+func (s *Server) compareTrends(ctx context.Context, projectID int, projectName string) error {
+	cfg := s.cfg.Trends
+	now := s.clock.Now()
+	var sections []string
+	for _, dim := range cfg.Dimensions {
+		current, err := s.store.GroupLogs(ctx, store.LogFilter{
+			ProjectID: projectID, EventType: cfg.EventType, Since: now.Add(-cfg.Period), Until: now, Limit: cfg.Top * trendWideFactor,
+		}, dim)
+		if err != nil {
+			return err
+		}
+		previous, err := s.store.GroupLogs(ctx, store.LogFilter{
+			ProjectID: projectID, EventType: cfg.EventType, Since: now.Add(-2 * cfg.Period), Until: now.Add(-cfg.Period), Limit: cfg.Top * trendWideFactor,
+		}, dim)
+		if err != nil {
+			return err
+		}
+
+		entered, left := diffTopBuckets(current, previous, cfg.Top, cfg.MinEvents)
+		entered = s.unreportedTrends(fmt.Sprintf("%d:%s:new", projectID, dim), entered, now)
+		left = s.unreportedTrends(fmt.Sprintf("%d:%s:gone", projectID, dim), left, now)
+		if section := formatTrendSection(dim, entered, left); section != "" {
+			sections = append(sections, section)
+		}
+	}
+	if len(sections) == 0 {
+		return nil
+	}
+
+	text := fmt.Sprintf("🔀 Trends on %s (last %s vs the %s before)\n\n%s",
+		projectName, formatPeriod(cfg.Period), formatPeriod(cfg.Period), strings.Join(sections, "\n\n"))
+	s.notifyAll(ctx, notify.Notification{
+		Level: "info", Title: "Trend changes", Text: text, Source: "trends", Key: fmt.Sprintf("trends:%d", projectID),
+	})
+	return nil
+}
+
+// diffTopBuckets : 今の上位 top 件に新しく入ったものと、前の上位 top 件から大きく外れたもの
+// 新しく入った = 前の期間は上位 top×trendWideFactor 件にも入っていなかった
+// 消えた = 今の期間は上位 top×trendWideFactor 件にも入っていない
+func diffTopBuckets(current, previous []model.Bucket, top, minEvents int) (entered, left []trendChange) {
+	currentCounts := bucketCounts(current)
+	previousCounts := bucketCounts(previous)
+	for _, b := range current[:min(top, len(current))] {
+		if _, seen := previousCounts[b.Key]; !seen && b.Count >= minEvents {
+			entered = append(entered, trendChange{Key: b.Key, Count: b.Count})
+		}
+	}
+	for _, b := range previous[:min(top, len(previous))] {
+		if _, seen := currentCounts[b.Key]; !seen && b.Count >= minEvents {
+			left = append(left, trendChange{Key: b.Key, Previous: b.Count})
+		}
+	}
+	return entered, left
+}
+
+// bucketCounts : 値 → 件数
+func bucketCounts(buckets []model.Bucket) map[string]int {
+	counts := make(map[string]int, len(buckets))
+	for _, b := range buckets {
+		counts[b.Key] = b.Count
+	}
+	return counts
+}
+
+// unreportedTrends : 直近 Period の間に通知していない変化だけを残し、通知済みとして覚える
+func (s *Server) unreportedTrends(prefix string, changes []trendChange, now time.Time) []trendChange {
+	s.trends.mu.Lock()
+	defer s.trends.mu.Unlock()
+	for key, at := range s.trends.reported {
+		if now.Sub(at) >= s.cfg.Trends.Period {
+			delete(s.trends.reported, key)
+		}
+	}
+	var fresh []trendChange
+	for _, c := range changes {
+		key := prefix + ":" + c.Key
+		if _, ok := s.trends.reported[key]; ok {
+			continue
+		}
+		s.trends.reported[key] = now
+		fresh = append(fresh, c)
+	}
+	return fresh
+}
+
+// formatTrendSection : 列1つ分の本文（変化がなければ空）
+func formatTrendSection(dim string, entered, left []trendChange) string {
+	if len(entered) == 0 && len(left) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(strings.ToLower(dim) + ":")
+	for _, c := range entered {
+		fmt.Fprintf(&b, "\n  🆕 %s (%d)", trendLabel(c.Key), c.Count)
+	}
+	for _, c := range left {
+		fmt.Fprintf(&b, "\n  👋 %s (was %d)", trendLabel(c.Key), c.Previous)
+	}
+	return b.String()
+}
+
+// trendLabel : 通知に載せる値（空の値と長いUAを読みやすくする）
+func trendLabel(key string) string {
+	if key == "" {
+		return "(none)"
+	}
+	if r := []rune(key); len(r) > 80 {
+		return string(r[:80]) + "…"
+	}
+	return key
+}
+
+// formatPeriod : 168h → "7d"
+func formatPeriod(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+)
+
+// ==========================================
+// 稼働監視 (外部の合成監視ツールからの結果を取り込む)
+// ==========================================
+
+// uptimeIngestHandler : POST /api/uptime/checks で監視結果を保存し、状態変化を通知する
+// UPTIME_INGEST_TOKEN が設定されていれば Authorization: Bearer <token> が必要
+func (s *Server) uptimeIngestHandler(w http.ResponseWriter, r *http.Request) {
+	if token := s.cfg.UptimeIngestToken; token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// 1. リクエストの検証
+	var c model.CheckResult
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&c); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.Status = strings.ToLower(c.Status)
+	if c.Check == "" || (c.Status != "up" && c.Status != "down") {
+		http.Error(w, `"check" and "status" ("up" or "down") are required`, http.StatusBadRequest)
+		return
+	}
+	if c.CheckedAt.IsZero() {
+		c.CheckedAt = s.clock.Now()
+	}
+	c.CheckedAt = c.CheckedAt.UTC()
+
+	// 2. 直前の状態を取得してから保存
+	prev, err := s.store.LastCheckStatus(r.Context(), c.Check, c.Region)
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	if err := s.store.InsertCheck(r.Context(), &c); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	// 3. 状態が変わった時（初回がdownの時も含む）だけ通知
+	if (prev == "" && c.Status == "down") || (prev != "" && prev != c.Status) {
+		level := "info"
+		if c.Status == "down" {
+			level = "error"
+		}
+		key := "uptime:" + c.Check + "/" + c.Region
+		s.notifyAsync(r.Context(), notify.Notification{
+			Level:  level,
+			Text:   uptimeAlertMessage(c),
+			Source: "uptime",
+			Key:    key,
+		})
+		if c.Status == "up" {
+			s.resolveIncident(r.Context(), key, uptimeAlertMessage(c))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// uptimeStatusHandler : GET /api/uptime で監視対象ごとの最新状態を返す
+func (s *Server) uptimeStatusHandler(w http.ResponseWriter, r *http.Request) {
+	loc, ok := s.responseLocation(w, r)
+	if !ok {
+		return
+	}
+	checks, err := s.store.LatestChecks(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	inLocation(checks, loc)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checks)
+}
+
+// uptimeAlertMessage : 状態変化の通知文
+func uptimeAlertMessage(c model.CheckResult) string {
+	where := c.Check
+	if c.Region != "" {
+		where += " (" + c.Region + ")"
+	}
+	if c.Status == "down" {
+		return fmt.Sprintf("🔴 %s is DOWN (latency %dms)", where, c.LatencyMS)
+	}
+	return fmt.Sprintf("🟢 %s is back UP (latency %dms)", where, c.LatencyMS)
+}
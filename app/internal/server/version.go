@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go-logger/internal/buildinfo"
+	"go-logger/internal/store"
+	"go-logger/internal/stream"
+)
+
+// ==========================================
+// ビルドの情報 (GET /api/version)
+// ==========================================
+// どの環境でどのビルドが動いているかを確かめる（デプロイの後に version / commit を比べるスクリプト向け）
+// バージョン・コミット・ビルド時刻は -ldflags で埋め込む（Dockerfile の VERSION / COMMIT / BUILD_TIME）
+
+// versionResponse : GET /api/version の応答
+type versionResponse struct {
+	buildinfo.Info
+	Instance      string          `json:"instance"`
+	Storage       string          `json:"storage"`        // postgres / clickhouse / memory
+	Features      map[string]bool `json:"features"`       // FEATURE_* の有効・無効と DRY_RUN
+	Integrations  []string        `json:"integrations"`   // 設定してある外部の連携・任意の機能
+	StaticVersion string          `json:"static_version"` // 画面のファイルの版（?v= に付けると長くキャッシュできる）
+}
+
+// storageBackend : ログの保存先の種類
+func (s *Server) storageBackend() string {
+	switch s.store.(type) {
+	case *store.Memory:
+		return "memory"
+	case *store.ClickHouse:
+		return "clickhouse"
+	case *store.Postgres:
+		return "postgres"
+	}
+	return "unknown"
+}
+
+// integrations : 設定してある任意の連携（無いものは載せない）
+func (s *Server) integrations() []string {
+	names := []string{}
+	for _, it := range []struct {
+		name string
+		on   bool
+	}{
+		{"dashboard_auth", s.auth != nil},
+		{"incidents", s.incidents != nil && s.incidents.Len() > 0},
+		{"stream_publish", publishesStream(s.stream)},
+		{"stream_consume", s.consumer != nil},
+		{"archive", s.archiver != nil},
+		{"exports_s3", s.exportBucket != nil},
+		{"shared_coord", s.coord.Name() != "memory"},
+		{"leader_election", s.elector != nil},
+	} {
+		if it.on {
+			names = append(names, it.name)
+		}
+	}
+	return names
+}
+
+// publishesStream : Kafka / NATS へ送る設定があるか（なければ New で空の stream.Multi になる）
+func publishesStream(p stream.Publisher) bool {
+	m, ok := p.(stream.Multi)
+	return !ok || len(m) > 0
+}
+
+// versionHandler : GET /api/version （認証は要らない）
+func (s *Server) versionHandler(w http.ResponseWriter, r *http.Request) {
+	f := s.cfg.Features
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(versionResponse{
+		Info:     buildinfo.Get(),
+		Instance: s.cfg.InstanceName,
+		Storage:  s.storageBackend(),
+		Features: map[string]bool{
+			"storage": f.Storage, "notifications": f.Notifications, "dashboard": f.Dashboard, "streams": f.Streams,
+			"dry_run": s.cfg.DryRun,
+		},
+		Integrations:  s.integrations(),
+		StaticVersion: s.staticVersion(),
+	})
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// ==========================================
+// 訪問者クッキー (VISITOR_COOKIE=true の時だけ。既定では個人を追えるIDは作らない)
+// ==========================================
+
+// visitorIDLength : ID の長さ（16バイトの16進数）
+const visitorIDLength = 32
+
+// validVisitorID : 自分で発行した形のIDか（書き換えられた値は使わずに発行し直す）
+func validVisitorID(id string) bool {
+	if len(id) != visitorIDLength {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// newVisitorID : 乱数だけで作る（IPやUAから作らないので、クッキーを消せば追えなくなる）
+func newVisitorID() string {
+	b := make([]byte, visitorIDLength/2)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// optedOut : ブラウザがトラッキングの拒否 (Sec-GPC / DNT) を送っているか
+func optedOut(r *http.Request) bool {
+	return r.Header.Get("Sec-GPC") == "1" || r.Header.Get("DNT") == "1"
+}
+
+// visitorID : クッキーの訪問者IDを返す（なければ発行してクッキーを付ける）
+// 無効な時とトラッキングを拒否しているブラウザには空を返し、クッキーも付けない
+func (s *Server) visitorID(w http.ResponseWriter, r *http.Request) string {
+	if !s.cfg.VisitorCookie || optedOut(r) {
+		return ""
+	}
+	if c, err := r.Cookie(s.cfg.VisitorCookieName); err == nil && validVisitorID(c.Value) {
+		return c.Value
+	}
+	id := newVisitorID()
+	path := s.cfg.BasePath
+	if path == "" {
+		path = "/"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.cfg.VisitorCookieName,
+		Value:    id,
+		Path:     path,
+		MaxAge:   int(s.cfg.VisitorCookieMaxAge / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+)
+
+// ==========================================
+// データ量の監視 (行数・ディスク使用量を定期的に記録して警告する)
+// ==========================================
+
+var (
+	tableRowsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "go_logger_table_rows",
+		Help: "Estimated live rows per table (pg_stat_user_tables.n_live_tup).",
+	}, []string{"table"})
+	tableBytesGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "go_logger_table_bytes",
+		Help: "Total on-disk size per table including indexes and TOAST (pg_total_relation_size).",
+	}, []string{"table"})
+	databaseBytesGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "go_logger_database_bytes",
+		Help: "Total on-disk size of the database (pg_database_size).",
+	})
+	databaseGrowthGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "go_logger_database_growth_bytes_per_hour",
+		Help: "Database growth rate over the sampled window.",
+	})
+)
+
+// volumeHistorySize : 保持するサンプル数（既定の5分間隔で24時間分）
+const volumeHistorySize = 288
+
+// volumeState : 計測結果の履歴と警告の状態
+type volumeState struct {
+	mu      sync.Mutex
+	history []model.VolumeSample
+	alerted map[string]bool // 同じ警告を繰り返さないための状態
+}
+
+// growthPerHour : 保持しているサンプルの最初と最後から増加量（バイト/時・行/時）を求める
+func growthPerHour(history []model.VolumeSample) (bytesPerHour, rowsPerHour float64) {
+	if len(history) < 2 {
+		return 0, 0
+	}
+	first, last := history[0], history[len(history)-1]
+	hours := last.SampledAt.Sub(first.SampledAt).Hours()
+	if hours <= 0 {
+		return 0, 0
+	}
+	bytesPerHour = float64(last.DatabaseBytes-first.DatabaseBytes) / hours
+	rowsPerHour = float64(last.TableRows("access_logs")-first.TableRows("access_logs")) / hours
+	return bytesPerHour, rowsPerHour
+}
+
+// watchVolume : 定期的にデータ量を記録し、閾値を超えたら通知する
+//
+//	VOLUME_SAMPLE_INTERVAL       計測間隔（既定 5m）
+//	VOLUME_ALERT_MAX_BYTES       DB全体のサイズ上限（バイト）
+//	VOLUME_ALERT_GROWTH_BYTES    1時間あたりの増加量の上限（バイト）
+func (s *Server) watchVolume(ctx context.Context) {
+	ticker := s.clock.NewTicker(s.cfg.VolumeSampleInterval)
+	defer ticker.Stop()
+	j := s.jobs.register("volume", s.cfg.VolumeSampleInterval).leaderOnly()
+
+	for {
+		s.runJob(j, func() error { return s.recordVolume(ctx) })
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// recordVolume : 1回分の計測・記録・警告
+func (s *Server) recordVolume(ctx context.Context) error {
+	sample, err := s.store.SampleVolume(ctx)
+	if err != nil {
+		fmt.Println("Failed to sample data volume:", err)
+		return err
+	}
+
+	s.volume.mu.Lock()
+	s.volume.history = append(s.volume.history, sample)
+	if len(s.volume.history) > volumeHistorySize {
+		s.volume.history = s.volume.history[len(s.volume.history)-volumeHistorySize:]
+	}
+	bytesPerHour, _ := growthPerHour(s.volume.history)
+	s.volume.mu.Unlock()
+
+	databaseBytesGauge.Set(float64(sample.DatabaseBytes))
+	databaseGrowthGauge.Set(bytesPerHour)
+	for _, t := range sample.Tables {
+		tableRowsGauge.WithLabelValues(t.Table).Set(float64(t.Rows))
+		tableBytesGauge.WithLabelValues(t.Table).Set(float64(t.Bytes))
+	}
+
+	if max := s.cfg.VolumeAlertMaxBytes; max > 0 {
+		s.volumeAlert(ctx, "size", sample.DatabaseBytes > max,
+			fmt.Sprintf("💾 Database size %s exceeds the limit of %s", formatBytes(sample.DatabaseBytes), formatBytes(max)))
+	}
+	if max := float64(s.cfg.VolumeAlertGrowthBytes); max > 0 {
+		s.volumeAlert(ctx, "growth", bytesPerHour > max,
+			fmt.Sprintf("📈 Database is growing by %s/hour (limit %s/hour)",
+				formatBytes(int64(bytesPerHour)), formatBytes(int64(max))))
+	}
+	return nil
+}
+
+// volumeAlert : 閾値を超えた時に1回だけ通知し、下回ったら再び通知できる状態に戻す（インシデントは閉じる）
+func (s *Server) volumeAlert(ctx context.Context, key string, over bool, text string) {
+	s.volume.mu.Lock()
+	already := s.volume.alerted[key]
+	s.volume.alerted[key] = over
+	s.volume.mu.Unlock()
+	if over && !already {
+		s.notifyAll(ctx, notify.Notification{Level: "warn", Title: "Data volume warning", Text: text, Source: "volume", Key: "volume:" + key})
+	}
+	if !over && already {
+		s.resolveIncident(ctx, "volume:"+key, "Data volume is back within the limit")
+	}
+}
+
+// formatBytes : 1536 → "1.5 KiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// volumeHandler : GET /api/admin/volume で最新の計測結果と増加ペースを返す
+func (s *Server) volumeHandler(w http.ResponseWriter, r *http.Request) {
+	s.volume.mu.Lock()
+	history := append([]model.VolumeSample(nil), s.volume.history...)
+	s.volume.mu.Unlock()
+
+	resp := map[string]any{"latest": nil, "samples": len(history)}
+	if len(history) > 0 {
+		bytesPerHour, rowsPerHour := growthPerHour(history)
+		resp["latest"] = history[len(history)-1]
+		resp["growth_bytes_per_hour"] = bytesPerHour
+		resp["growth_rows_per_hour"] = rowsPerHour
+		resp["window_start"] = history[0].SampledAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
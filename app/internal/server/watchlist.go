@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// 監視リスト (怪しいIPアドレス・UA。/api/watchlist)
+// ==========================================
+// 一致したアクセスは保存する時に flagged と watchlist:<id> のタグを付け（?tag=flagged で探せる）、
+// そのIPアドレスの直近の履歴と一緒に error の通知を送る
+// 同じ監視リスト・同じIPアドレスの通知は WATCHLIST_COOLDOWN（既定10分）に1回にする
+
+// flaggedTag : 監視リストに一致したアクセスのタグ
+const flaggedTag = "flagged"
+
+// watchTagPrefix : どの監視リストに一致したかのタグ (watchlist:<id>)
+const watchTagPrefix = "watchlist:"
+
+// compiledWatch : 解析済みの監視リスト
+type compiledWatch struct {
+	model.Watch
+	prefix netip.Prefix   // field が ip の時
+	re     *regexp.Regexp // field が user_agent の時
+}
+
+// watchlistState : 読み込んだ監視リスト
+type watchlistState struct {
+	mu   sync.RWMutex
+	list []compiledWatch
+}
+
+// compileWatch : 監視リストを検証して解析する（ip の範囲は正規化した形で書き戻す）
+func compileWatch(w model.Watch) (compiledWatch, error) {
+	c := compiledWatch{Watch: w}
+	switch w.Field {
+	case "ip":
+		prefix, err := parseIPRange(w.Pattern)
+		if err != nil {
+			return c, fmt.Errorf("invalid IP address or CIDR %q", w.Pattern)
+		}
+		c.prefix = prefix
+		c.Pattern = prefix.String()
+		if prefix.IsSingleIP() {
+			c.Pattern = prefix.Addr().String()
+		}
+	case "user_agent":
+		re, err := regexp.Compile(w.Pattern)
+		if err != nil || w.Pattern == "" {
+			return c, fmt.Errorf("invalid pattern %q: %v", w.Pattern, err)
+		}
+		c.re = re
+	default:
+		return c, fmt.Errorf("unknown field %q (use ip or user_agent)", w.Field)
+	}
+	return c, nil
+}
+
+// matches : 書き込みが一致するか
+func (c compiledWatch) matches(lw *model.Write) bool {
+	if c.re != nil {
+		return c.re.MatchString(lw.UserAgent)
+	}
+	addr, err := netip.ParseAddr(lw.IP)
+	return err == nil && c.prefix.Contains(addr.Unmap())
+}
+
+// flagWatched : 監視リストに一致したら flagged と watchlist:<id> のタグを付ける
+// IPアドレスを加工する (PRIVACY_MODE) 前の値で判定する
+func (s *Server) flagWatched(lw *model.Write) {
+	s.watchlist.mu.RLock()
+	defer s.watchlist.mu.RUnlock()
+	for _, c := range s.watchlist.list {
+		if c.matches(lw) {
+			if !slices.Contains(lw.Tags, flaggedTag) {
+				lw.Tags = append(lw.Tags, flaggedTag)
+			}
+			lw.Tags = append(lw.Tags, watchTagPrefix+strconv.Itoa(c.ID))
+		}
+	}
+}
+
+// watchesFor : タグから、一致した監視リストを探す（消された監視リストは飛ばす）
+func (s *Server) watchesFor(e *model.LogEntry) []model.Watch {
+	s.watchlist.mu.RLock()
+	defer s.watchlist.mu.RUnlock()
+	var watches []model.Watch
+	for _, tag := range e.Tags {
+		id, err := strconv.Atoi(strings.TrimPrefix(tag, watchTagPrefix))
+		if !strings.HasPrefix(tag, watchTagPrefix) || err != nil {
+			continue
+		}
+		for _, c := range s.watchlist.list {
+			if c.ID == id {
+				watches = append(watches, c.Watch)
+			}
+		}
+	}
+	return watches
+}
+
+// notifyWatched : 保存された監視リストのアクセスを通知する（Publish から呼ばれる）
+func (s *Server) notifyWatched(e *model.LogEntry) {
+	if !slices.Contains(e.Tags, flaggedTag) {
+		return
+	}
+	watches := s.watchesFor(e)
+	if len(watches) == 0 {
+		return
+	}
+	entry := *e
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for _, w := range watches {
+			if !s.claimOnce(ctx, fmt.Sprintf("watchlist:%d:%s", w.ID, entry.IP), s.cfg.WatchlistCooldown) {
+				continue
+			}
+			s.fireWatch(ctx, w, entry)
+		}
+	}()
+}
+
+// fireWatch : 一致したアクセスと、そのIPアドレスの直近の履歴を付けて通知する
+func (s *Server) fireWatch(ctx context.Context, w model.Watch, e model.LogEntry) {
+	lines := []string{fmt.Sprintf("🚩 Watchlist match: %s %q", w.Field, w.Pattern)}
+	if w.Note != "" {
+		lines = append(lines, w.Note)
+	}
+	scope := s.notifyScope()
+	lines = append(lines, describeEntry(s.redact.Entry(e, scope)))
+	if history := s.watchHistory(ctx, e); len(history) > 0 {
+		lines = append(lines, fmt.Sprintf("Recent activity from %s:", e.IP))
+		for _, h := range s.redact.Entries(history, scope) {
+			lines = append(lines, describeEntry(h))
+		}
+	}
+	redacted := s.redact.Entry(e, scope)
+	s.notifyAll(ctx, notify.Notification{
+		Level:    "error",
+		Title:    "🚩 Watchlist match",
+		Text:     strings.Join(lines, "\n"),
+		Entry:    &redacted,
+		EntryURL: s.entryURL(&e),
+		Source:   "watchlist",
+		Key:      fmt.Sprintf("watchlist:%d", w.ID),
+	})
+}
+
+// watchHistory : 同じIPアドレスからのこのアクセスより前のログ（新しい順に WATCHLIST_HISTORY 件。探せない保存先では空）
+func (s *Server) watchHistory(ctx context.Context, e model.LogEntry) []model.LogEntry {
+	if e.IP == "" || s.cfg.WatchlistHistory <= 0 {
+		return nil
+	}
+	q, err := store.ParseQuery("ip:" + strconv.Quote(e.IP))
+	if err != nil {
+		return nil
+	}
+	history, err := s.store.QueryLogs(ctx, store.LogFilter{ProjectID: e.ProjectID, Query: q, BeforeID: e.ID, Limit: s.cfg.WatchlistHistory})
+	if err != nil {
+		fmt.Println("Failed to read watchlist history:", err)
+		return nil
+	}
+	return history
+}
+
+// reloadWatchlist : 監視リストをDBから読み直す（不正なものは飛ばす）
+func (s *Server) reloadWatchlist(ctx context.Context) error {
+	watches, err := s.store.ListWatches(ctx)
+	if err != nil {
+		return err
+	}
+	var list []compiledWatch
+	for _, w := range watches {
+		c, err := compileWatch(w)
+		if err != nil {
+			fmt.Printf("Skipping watchlist entry %d: %v\n", w.ID, err)
+			continue
+		}
+		list = append(list, c)
+	}
+	s.watchlist.mu.Lock()
+	s.watchlist.list = list
+	s.watchlist.mu.Unlock()
+	return nil
+}
+
+// listWatchlistHandler : GET /api/watchlist
+func (s *Server) listWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	watches, err := s.store.ListWatches(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(watches)
+}
+
+// createWatchHandler : POST /api/watchlist {"field": "ip", "pattern": "203.0.113.0/24", "note": "scanner"}
+func (s *Server) createWatchHandler(w http.ResponseWriter, r *http.Request) {
+	var watch model.Watch
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&watch); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	c, err := compileWatch(watch)
+	if err != nil {
+		http.Error(w, "Invalid watchlist entry: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	watch.Pattern = c.Pattern
+
+	if err := s.store.CreateWatch(r.Context(), &watch); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadWatchlistAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(watch)
+}
+
+// deleteWatchHandler : DELETE /api/watchlist/{id}
+func (s *Server) deleteWatchHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid watchlist id", http.StatusBadRequest)
+		return
+	}
+	err = s.store.DeleteWatch(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Watchlist entry not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadWatchlistAfterChange(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reloadWatchlistAfterChange : 変更をすぐ反映する（失敗しても次の定期読み込みで反映される）
+func (s *Server) reloadWatchlistAfterChange(ctx context.Context) {
+	if err := s.reloadWatchlist(ctx); err != nil {
+		fmt.Println("Failed to reload watchlist:", err)
+	}
+}
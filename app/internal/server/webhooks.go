@@ -0,0 +1,490 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// Webhook (保存した新しいログを、登録した外部のURLへまとめて POST する)
+// ==========================================
+
+// 受け取る側は X-Logger-Signature の値を、secret を鍵にした
+// HMAC-SHA256("<X-Logger-Timestamp>.<本文>") の16進数と比べて確かめる
+const (
+	webhookSignatureHeader = "X-Logger-Signature"
+	webhookTimestampHeader = "X-Logger-Timestamp"
+	webhookDeliveryHeader  = "X-Logger-Delivery"
+	webhookIDHeader        = "X-Logger-Webhook-Id"
+)
+
+// webhookAttempts : 1回の送信で試す回数（それでも届かなければ失敗として数える）
+const webhookAttempts = 3
+
+// webhookPayload : 送る本文
+type webhookPayload struct {
+	WebhookID  int              `json:"webhook_id"`
+	DeliveryID string           `json:"delivery_id"`
+	SentAt     time.Time        `json:"sent_at"`
+	Entries    []model.LogEntry `json:"entries"`
+}
+
+// webhookState : 有効な Webhook と、送る前のログ
+type webhookState struct {
+	mu      sync.RWMutex
+	list    []model.Webhook
+	entries chan model.LogEntry
+	client  *http.Client
+}
+
+// webhookMatches : wh に送るログか
+func webhookMatches(wh model.Webhook, e *model.LogEntry) bool {
+	if wh.ProjectID != 0 && wh.ProjectID != e.ProjectID {
+		return false
+	}
+	if len(wh.EventTypes) > 0 && !slices.ContainsFunc(wh.EventTypes, func(t string) bool { return model.EventTypeMatches(t, e.EventType) }) {
+		return false
+	}
+	return wh.MinLevel == "" || model.LevelRank(e.Level) >= model.LevelRank(wh.MinLevel)
+}
+
+// snapshot : 有効な Webhook の一覧
+func (ws *webhookState) snapshot() []model.Webhook {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	return ws.list
+}
+
+// enqueueWebhooks : 送る先があればログを送信待ちに積む（一杯なら捨てる）
+func (s *Server) enqueueWebhooks(e *model.LogEntry) {
+	if len(s.webhooks.snapshot()) == 0 {
+		return
+	}
+	select {
+	case s.webhooks.entries <- *s.redact.EntryPtr(e, s.exportScope()):
+	default:
+		fmt.Println("Dropping webhook entry: queue full")
+	}
+}
+
+// reloadWebhooks : 有効な Webhook をDBから読み直す
+func (s *Server) reloadWebhooks(ctx context.Context) error {
+	webhooks, err := s.store.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	enabled := webhooks[:0]
+	for _, wh := range webhooks {
+		if wh.Enabled {
+			enabled = append(enabled, wh)
+		}
+	}
+	s.webhooks.mu.Lock()
+	s.webhooks.list = enabled
+	s.webhooks.mu.Unlock()
+	return nil
+}
+
+// reloadWebhooksAfterChange : 変更をすぐ反映する（失敗しても次の定期読み込みで反映される）
+func (s *Server) reloadWebhooksAfterChange(ctx context.Context) {
+	if err := s.reloadWebhooks(ctx); err != nil {
+		fmt.Println("Failed to reload webhooks:", err)
+	}
+}
+
+// watchWebhooks : Webhook を定期的に読み直す（他のインスタンスで変更された分も反映する）
+func (s *Server) watchWebhooks(ctx context.Context) {
+	ticker := s.clock.NewTicker(s.cfg.ChannelReloadInterval)
+	defer ticker.Stop()
+	j := s.jobs.register("webhooks", s.cfg.ChannelReloadInterval)
+
+	for {
+		s.runJob(j, func() error {
+			err := s.reloadWebhooks(ctx)
+			if err != nil {
+				fmt.Println("Failed to load webhooks:", err)
+			}
+			return err
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// dispatchWebhooks : 送信待ちのログを Webhook ごとにまとめ、WEBHOOK_BATCH_SIZE 件か WEBHOOK_BATCH_INTERVAL ごとに送る
+func (s *Server) dispatchWebhooks(ctx context.Context) {
+	interval, size := s.cfg.WebhookBatchInterval, s.cfg.WebhookBatchSize
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	if size <= 0 {
+		size = 100
+	}
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	pending := map[int][]model.LogEntry{}
+	hooks := map[int]model.Webhook{}
+	flush := func(id int) {
+		if batch := pending[id]; len(batch) > 0 {
+			go s.deliverWebhook(ctx, hooks[id], batch)
+		}
+		delete(pending, id)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-s.webhooks.entries:
+			for _, wh := range s.webhooks.snapshot() {
+				if !webhookMatches(wh, &e) {
+					continue
+				}
+				hooks[wh.ID] = wh
+				pending[wh.ID] = append(pending[wh.ID], e)
+				if len(pending[wh.ID]) >= size {
+					flush(wh.ID)
+				}
+			}
+		case <-ticker.C():
+			for id := range pending {
+				flush(id)
+			}
+		}
+	}
+}
+
+// deliverWebhook : 署名を付けて送り、結果を記録する（続けて失敗したら止めて通知する）
+func (s *Server) deliverWebhook(ctx context.Context, wh model.Webhook, entries []model.LogEntry) {
+	payload := webhookPayload{WebhookID: wh.ID, DeliveryID: newDeliveryID(), SentAt: s.clock.Now(), Entries: entries}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		fmt.Println("Failed to encode webhook payload:", err)
+		return
+	}
+
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if err = s.postWebhook(ctx, wh, payload, body); err == nil || ctx.Err() != nil {
+			break
+		}
+		if attempt < webhookAttempts {
+			select {
+			case <-ctx.Done():
+			case <-s.clock.After(time.Duration(attempt) * time.Second):
+			}
+		}
+	}
+	if err != nil {
+		fmt.Printf("Webhook %d (%s) delivery failed: %v\n", wh.ID, wh.Name, err)
+	}
+
+	disabled, recordErr := s.store.RecordWebhookDelivery(ctx, wh.ID, err, s.clock.Now(), s.cfg.WebhookMaxFailures)
+	if recordErr != nil {
+		if !errors.Is(recordErr, store.ErrNotFound) {
+			fmt.Println("Failed to record webhook delivery:", recordErr)
+		}
+		return
+	}
+	if disabled {
+		s.reloadWebhooksAfterChange(ctx)
+		s.notifyAll(ctx, notify.Notification{
+			Level:  "warn",
+			Title:  "Webhook disabled",
+			Text:   fmt.Sprintf("🪝 Webhook %q (%s) was disabled after %d failed deliveries: %v", wh.Name, redactWebhookURL(wh.URL), s.cfg.WebhookMaxFailures, err),
+			Source: "webhook",
+			Key:    fmt.Sprintf("webhook:%d", wh.ID),
+		})
+	}
+}
+
+// postWebhook : 1回送る（2xx 以外は失敗）
+func (s *Server) postWebhook(ctx context.Context, wh model.Webhook, payload webhookPayload, body []byte) error {
+	if s.cfg.WebhookTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.WebhookTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(payload.SentAt.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-logger-webhook")
+	req.Header.Set(webhookIDHeader, strconv.Itoa(wh.ID))
+	req.Header.Set(webhookDeliveryHeader, payload.DeliveryID)
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+signWebhook(wh.Secret, timestamp, body))
+
+	resp, err := s.webhooks.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signWebhook : HMAC-SHA256("<timestamp>.<body>") の16進数
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newDeliveryID : 送信ごとのID（受け取る側の重複の判定用）
+func newDeliveryID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newWebhookSecret : secret を省略した時に発行する鍵
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// ==========================================
+// 管理API (/api/webhooks)
+// ==========================================
+
+// webhookRequest : POST / PATCH の本文（PATCH では省略した項目は変えない）
+type webhookRequest struct {
+	Name       *string   `json:"name"`
+	ProjectID  *int      `json:"project_id"`
+	URL        *string   `json:"url"`
+	Secret     *string   `json:"secret"`
+	EventTypes *[]string `json:"event_types"`
+	MinLevel   *string   `json:"min_level"`
+	Enabled    *bool     `json:"enabled"`
+}
+
+// apply : 指定された項目だけ wh に反映する
+func (req webhookRequest) apply(wh *model.Webhook) {
+	for _, f := range []struct {
+		dst *string
+		src *string
+	}{{&wh.Name, req.Name}, {&wh.URL, req.URL}, {&wh.Secret, req.Secret}, {&wh.MinLevel, req.MinLevel}} {
+		// GET の結果をそのまま送り返された場合、伏せた値で上書きしない
+		if f.src != nil && !strings.HasSuffix(*f.src, redacted) {
+			*f.dst = strings.TrimSpace(*f.src)
+		}
+	}
+	if req.ProjectID != nil {
+		wh.ProjectID = *req.ProjectID
+	}
+	if req.EventTypes != nil {
+		wh.EventTypes = nil
+		for _, t := range *req.EventTypes {
+			if t = strings.TrimSpace(t); t != "" {
+				wh.EventTypes = append(wh.EventTypes, t)
+			}
+		}
+	}
+	if req.Enabled != nil {
+		wh.Enabled = *req.Enabled
+	}
+}
+
+// validateWebhook : 保存する前に確かめる（min_level は正規化する）
+func validateWebhook(wh *model.Webhook) error {
+	if wh.Name == "" {
+		return errors.New(`"name" is required`)
+	}
+	u, err := url.Parse(wh.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New(`"url" must be an absolute http(s) URL`)
+	}
+	if wh.Secret == "" {
+		return errors.New(`"secret" must not be empty`)
+	}
+	for _, t := range wh.EventTypes {
+		if strings.Contains(t, ",") {
+			return fmt.Errorf("invalid event type %q", t)
+		}
+	}
+	if wh.MinLevel != "" {
+		level, err := model.NormalizeLevel(wh.MinLevel)
+		if err != nil {
+			return err
+		}
+		wh.MinLevel = level
+	}
+	return nil
+}
+
+// redactWebhookURL : クエリなどにトークンを含む URL があるので、ホストまでにする
+func redactWebhookURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		return u.Scheme + "://" + u.Host + "/" + redacted
+	}
+	return redacted
+}
+
+// redactWebhook : 返す時に secret と URL のパスを伏せる
+func redactWebhook(wh model.Webhook) model.Webhook {
+	wh.URL = redactWebhookURL(wh.URL)
+	wh.Secret = redacted
+	return wh
+}
+
+// webhookID : パスの {id}
+func webhookID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid webhook id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// listWebhooksHandler : GET /api/webhooks
+func (s *Server) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := s.store.ListWebhooks(r.Context())
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	for i := range webhooks {
+		webhooks[i] = redactWebhook(webhooks[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// getWebhookHandler : GET /api/webhooks/{id}
+func (s *Server) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	wh, err := s.store.WebhookByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redactWebhook(wh))
+}
+
+// createWebhookHandler : POST /api/webhooks {"name": "crm", "url": "https://...", "project_id": 2, "event_types": ["signup"]}
+// secret を省略すると発行し、この応答でだけ返す
+func (s *Server) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	wh := model.Webhook{Enabled: true, Secret: newWebhookSecret()}
+	req.apply(&wh)
+	if err := validateWebhook(&wh); err != nil {
+		http.Error(w, "Invalid webhook: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.CreateWebhook(r.Context(), &wh); err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadWebhooksAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(wh)
+}
+
+// updateWebhookHandler : PATCH /api/webhooks/{id} {"enabled": true} のように変える項目だけ送る
+// 自動で止まった Webhook は enabled を true にすると失敗の回数が0に戻る
+func (s *Server) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	var req webhookRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	wh, err := s.store.WebhookByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	req.apply(&wh)
+	if err := validateWebhook(&wh); err != nil {
+		http.Error(w, "Invalid webhook: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = s.store.UpdateWebhook(r.Context(), &wh)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadWebhooksAfterChange(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redactWebhook(wh))
+}
+
+// deleteWebhookHandler : DELETE /api/webhooks/{id}
+func (s *Server) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	err := s.store.DeleteWebhook(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	s.reloadWebhooksAfterChange(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
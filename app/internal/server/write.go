@@ -0,0 +1,436 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"go-logger/internal/i18n"
+	"go-logger/internal/model"
+	"go-logger/internal/notify"
+	"go-logger/internal/store"
+)
+
+// Response : 書き込み完了時のメッセージ用
+type Response struct {
+	Status     int             `json:"status,omitempty"` // HTTP のステータス（エラーの apiError と同じ）
+	Message    string          `json:"message"`
+	DBStatus   string          `json:"db_status"`
+	WriteToken string          `json:"write_token,omitempty"` // READ_AFTER_WRITE=true の時、/api/logs?after= に渡すと反映を待てる
+	Entry      *model.LogEntry `json:"entry,omitempty"`       // 受け付けたログ（id・時刻・取り込んだ項目。バッファに入れた場合は id がまだない）
+}
+
+// ==========================================
+// ログ書き込み
+// ==========================================
+
+// writeHandler : アクセスをDBに保存し、通知を送る
+// eventType は記録対象パスごとのラベル（"/api/" は "access"）。?event_type= で決まった種別・custom:<名前> に変えられる
+func (s *Server) writeHandler(eventType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// X-API-Key (または ?key=) でプロジェクトを決める
+		s.withProject(w, r, func(projectID int) {
+			s.writeAccess(w, r, projectID, eventType)
+		})
+	}
+}
+
+// writeAccess : writeHandler の本体
+func (s *Server) writeAccess(w http.ResponseWriter, r *http.Request, projectID int, eventType string) {
+	lw, err := s.accessWrite(w, r, projectID, eventType)
+	if err != nil {
+		http.Error(w, i18n.T(w, r, i18n.InvalidRequest, err), http.StatusBadRequest)
+		return
+	}
+	status, err := s.recordAccess(r.Context(), &lw)
+	s.accessResult(w, r, &lw, status, err)
+}
+
+// accessResult : 3. クライアントへJSONレスポンス
+// 保存したら 201 と保存した内容を返す（既存の行にまとめた場合は 200 で内容はまとめた先、バッファに入れた場合は 202 で退避したもの）
+func (s *Server) accessResult(w http.ResponseWriter, r *http.Request, lw *model.Write, status string, err error) {
+	resp := Response{Message: i18n.T(w, r, i18n.Logged), DBStatus: status}
+	if acceptedStatus(status) {
+		resp.Entry = s.redact.EntryPtr(lw.Entry(), s.requestScope(r))
+	}
+	s.writeResult(w, r, lw, resp, http.StatusCreated, err)
+}
+
+// accessWrite : リクエストからアクセスの書き込みを作る（?status= ?duration_ms= ?event_type= が不正ならエラー）
+func (s *Server) accessWrite(w http.ResponseWriter, r *http.Request, projectID int, eventType string) (model.Write, error) {
+	lw := model.Write{
+		ProjectID: projectID,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+		VisitorID: s.visitorID(w, r),
+		Path:      r.URL.Path,
+		Referrer:  r.Referer(),
+		EventType: eventType,
+		Level:     model.DefaultLevel,
+		CreatedAt: s.clock.Now(),
+		Headers:   s.capturedHeaders(r),
+	}
+	if err := reportedTiming(r, &lw); err != nil {
+		return lw, err
+	}
+	requested, err := model.NormalizeEventType(r.URL.Query().Get("event_type"))
+	if err != nil {
+		return lw, err
+	}
+	if requested != "" {
+		lw.EventType = requested
+	}
+	return lw, nil
+}
+
+// recordAccess : アクセスを保存し、保存できたら通知する（db_status 用の文字列と、保存できなかった時のエラーを返す）
+func (s *Server) recordAccess(ctx context.Context, lw *model.Write) (string, error) {
+	// 1. DBへの書き込み (INSERT)
+	// 再接続中ならバッファに退避し、復旧後にウォッチドッグが書き戻す
+	// SAMPLE_RATE で間引く分は保存も通知もしない（クライアントには保存した時と同じく 200 を返す）
+	// 保存する行には抽出率を残し、集計で割り戻せるようにする
+	lw.SampleRate = s.tunables().SampleRate
+	if sampledOut(lw.SampleRate) {
+		return "Skipped: sampled", nil
+	}
+	status, stored, err := s.saveWrite(ctx, lw)
+	if stored && s.shouldNotify(lw) {
+		// 2. 成功したら非同期で通知 (Discord / Telegram など設定済みの通知先すべて)
+		s.notifyAsync(ctx, notify.Notification{
+			Level: lw.Level,
+			Title: "🚀 New Access Detected!",
+			Text:  fmt.Sprintf("🚀 New Access Detected! [%s] %s UA: %s", lw.EventType, lw.Path, lw.UserAgent),
+			Entry: lw.Entry(),
+		})
+	}
+	return status, err
+}
+
+// writeResult : 1件の書き込みの結果を返す（saveWrite の結果から状態コードを決める）
+// 保存したら created、バッファ (WAL_DIR) に退避したら 202、DBに繋がらず退避もできなければ 503 と Retry-After
+// 除外・既存の行にまとめた・間引いたものは 200
+func (s *Server) writeResult(w http.ResponseWriter, r *http.Request, lw *model.Write, resp Response, created int, err error) {
+	if err != nil {
+		s.dbError(w, r, err)
+		return
+	}
+	resp.WriteToken = s.acceptedWriteToken(w, lw, resp.DBStatus)
+	switch {
+	case resp.DBStatus == "OK":
+		resp.Status = created
+	case strings.HasPrefix(resp.DBStatus, "Buffered"):
+		resp.Status = http.StatusAccepted
+	default:
+		resp.Status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// saveWrite : 書き込みを保存し、db_status 用の文字列と即時に保存できたか、保存できなかった時のエラーを返す
+// エンリッチ結果と採番されたIDは lw に書き戻される（除外パターンに一致したら保存しない）
+func (s *Server) saveWrite(ctx context.Context, lw *model.Write) (string, bool, error) {
+	if status, stored, ok := s.prepareWrite(ctx, lw); !ok {
+		return status, stored, nil
+	}
+	// 再接続中ならバッファに退避し、一杯なら store.ErrBufferFull になる
+	result, err := s.store.SaveLog(ctx, lw)
+	status, stored := s.savedWrite(lw, result, err)
+	return status, stored, err
+}
+
+// prepareWrite : 保存の前処理（エンリッチ・除外・監視リスト・uid・有効期限）
+// DBに保存しない場合（除外・ドライラン・既存の行にまとめた）は ok=false で、saveWrite の結果をそのまま返す
+func (s *Server) prepareWrite(ctx context.Context, lw *model.Write) (status string, stored, ok bool) {
+	s.enricher.Enrich(lw)
+	if s.exclusionScope(lw) == excludeAll {
+		return "Skipped: excluded", false, false
+	}
+	s.flagWatched(lw)
+	// エンリッチと除外は元の値で判定し、保存する値だけ加工する
+	s.applyPrivacy(lw)
+	// バッファに入った場合も受け付けた時点の順序になるよう、先に uid を決める
+	if lw.UID == "" {
+		lw.UID = s.ids.NewID(lw.CreatedAt)
+	}
+	if lw.ExpiresAt.IsZero() {
+		lw.ExpiresAt = s.cfg.EventTTLs.expiresAt(lw.EventType, lw.CreatedAt)
+	}
+	if s.cfg.DryRun {
+		return s.dryRunWrite(lw), true, false
+	}
+	// FEATURE_STORAGE=false なら保存せず、新着の配信・ルール・Webhook と通知だけ行う
+	if !s.cfg.Features.Storage {
+		s.Publish(lw.Entry())
+		return "Skipped: storage disabled", true, false
+	}
+	if s.collapseWrite(ctx, lw) {
+		return "Collapsed", false, false
+	}
+	return "", false, true
+}
+
+// savedWrite : store.SaveLog(s) の結果から db_status 用の文字列と即時に保存できたかを決める
+func (s *Server) savedWrite(lw *model.Write, result store.SaveResult, err error) (string, bool) {
+	if err != nil {
+		return "Error: " + err.Error(), false
+	}
+	countHit(lw)
+	if result == store.Buffered {
+		return "Buffered: " + store.ErrUnavailable.Error(), false
+	}
+	s.rememberAccess(lw)
+	return "OK", true
+}
+
+// acceptedWriteToken : 保存したかバッファに入れた書き込みのトークン（保存しなかったなら空）
+// 既存の行にまとめた場合は、その行のトークンを返す
+func (s *Server) acceptedWriteToken(w http.ResponseWriter, lw *model.Write, status string) string {
+	if !acceptedStatus(status) {
+		return ""
+	}
+	return s.writeToken(w, lw.UID)
+}
+
+// acceptedStatus : saveWrite の db_status が、保存したかバッファに入れたものか
+func acceptedStatus(status string) bool {
+	return status == "OK" || status == "Collapsed" || strings.HasPrefix(status, "Buffered")
+}
+
+// ==========================================
+// 通知
+// ==========================================
+
+// queuedNotification : 送信待ちのキューに積む形（リクエストのトレースに繋げるため、スパンのIDも持つ）
+// 送れなかった通知先があれば Targets をその通知先だけにして積み直し、NOTIFY_MAX_ATTEMPTS 回で dead letter にする
+type queuedNotification struct {
+	Notification notify.Notification
+	TraceID      string   `json:",omitempty"`
+	SpanID       string   `json:",omitempty"`
+	Prepared     bool     `json:",omitempty"` // 履歴への記録とミュートの判定を済ませた
+	Attempts     int      `json:",omitempty"`
+	Targets      []string `json:",omitempty"` // 送り直す通知先（空なら全て）
+	LastError    string   `json:",omitempty"`
+}
+
+// notifyAsync : リクエストを待たせずに全ての通知先へ送る（キューに積み、notifyWorker が送る）
+// ログの通知は、通知のルーティング (/api/notify-routes) で送る通知先を絞り込む
+func (s *Server) notifyAsync(reqCtx context.Context, n notify.Notification) {
+	q := queuedNotification{Notification: n}
+	if !s.routeNotification(&q) || !s.throttleNotification(reqCtx, n) {
+		return
+	}
+	if sc := trace.SpanContextFromContext(reqCtx); sc.IsValid() {
+		q.TraceID, q.SpanID = sc.TraceID().String(), sc.SpanID().String()
+	}
+	if err := s.enqueueNotification(reqCtx, q); err != nil {
+		fmt.Println("Dropping notification:", err)
+	}
+}
+
+// enqueueNotification : 送信待ちのキューに積む
+func (s *Server) enqueueNotification(ctx context.Context, q queuedNotification) error {
+	item, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	return s.queue.Push(ctx, item)
+}
+
+// notifyWorker : キューから取り出して送る（ctx が終わるまで動き続ける）
+// キューを一時停止している間は取り出さない（積むことはできる）
+func (s *Server) notifyWorker(ctx context.Context) {
+	for {
+		if s.notifyPaused.Load() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.clock.After(time.Second):
+			}
+			continue
+		}
+		item, err := s.queue.Pop(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Println("Failed to read notification queue:", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.clock.After(time.Second):
+			}
+			continue
+		}
+		var q queuedNotification
+		if err := json.Unmarshal(item, &q); err != nil {
+			fmt.Println("Dropping unreadable notification:", err)
+			continue
+		}
+		s.deliverQueued(q)
+	}
+}
+
+// deliverQueued : 1件送り、失敗した通知先があれば間隔を空けて積み直す
+func (s *Server) deliverQueued(q queuedNotification) {
+	ctx := q.traceContext()
+	if !q.Prepared {
+		if !s.prepareNotification(ctx, &q.Notification) {
+			return
+		}
+		q.Prepared = true
+	}
+	failed, err := s.sendNotification(ctx, q.Notification, q.Targets)
+	if len(failed) == 0 {
+		return
+	}
+
+	q.Attempts++
+	q.Targets = failed
+	if err != nil {
+		q.LastError = err.Error()
+	}
+	if q.Attempts >= max(s.cfg.NotifyMaxAttempts, 1) {
+		s.deadLetter(q)
+		return
+	}
+	// 1回目は NOTIFY_RETRY_BACKOFF 後、以降は倍ずつ空ける
+	delay := s.cfg.NotifyRetryBackoff << (q.Attempts - 1)
+	// 送信先が Retry-After で待つ時間を指定していれば、それより早くは送らない（ブレーカーが開いている間も同じ）
+	var limited *notify.RateLimitedError
+	if errors.As(err, &limited) && limited.RetryAfter > delay {
+		delay = limited.RetryAfter
+	}
+	var open *notify.CircuitOpenError
+	if errors.As(err, &open) && open.RetryAfter > delay {
+		delay = open.RetryAfter
+	}
+	s.notifyRetrying.Add(1)
+	s.clock.AfterFunc(delay, func() {
+		defer s.notifyRetrying.Add(-1)
+		if err := s.enqueueNotification(context.Background(), q); err != nil {
+			fmt.Println("Failed to requeue notification:", err)
+			s.deadLetter(q)
+		}
+	})
+}
+
+// deadLetter : 送れなかった通知をDBに残す（/api/admin/dead-letters から再送できる）
+func (s *Server) deadLetter(q queuedNotification) {
+	payload, err := json.Marshal(q)
+	if err == nil {
+		err = s.store.InsertDeadLetter(context.Background(), &model.DeadLetter{
+			Queue: notificationQueueName, Payload: payload, Attempts: q.Attempts, LastError: q.LastError,
+		})
+	}
+	if err != nil {
+		fmt.Println("Dropping notification after retries:", err)
+		return
+	}
+	fmt.Printf("Notification moved to dead letters after %d attempts (%s)\n", q.Attempts, strings.Join(q.Targets, ", "))
+}
+
+// traceContext : 積んだ時のリクエストのスパンを親にする ctx
+func (q queuedNotification) traceContext() context.Context {
+	traceID, err1 := trace.TraceIDFromHex(q.TraceID)
+	spanID, err2 := trace.SpanIDFromHex(q.SpanID)
+	if err1 != nil || err2 != nil {
+		return context.Background()
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled, Remote: true})
+	return trace.ContextWithRemoteSpanContext(context.Background(), sc)
+}
+
+// notifyAll : 全ての通知先へ送る（失敗は通知先側でログに出る）
+func (s *Server) notifyAll(ctx context.Context, n notify.Notification) {
+	if s.dedupNotification(ctx, n) && s.prepareNotification(ctx, &n) {
+		s.sendNotification(ctx, n, nil)
+	}
+}
+
+// prepareNotification : リンクを付けて履歴に記録する。ミュート中・通知を止めている間 (/api/notifications/mute) なら false
+func (s *Server) prepareNotification(ctx context.Context, n *notify.Notification) bool {
+	if n.EntryURL == "" {
+		n.EntryURL = s.entryURL(n.Entry)
+	}
+	// 通知先（外部のチャット）に出してよい項目だけにする
+	n.Entry = s.redact.EntryPtr(n.Entry, s.notifyScope())
+	// 稼働監視・データ量・warn 以上のログは履歴に残す (/api/alerts, /api/alerts.ics)
+	if isAlert(*n) {
+		if !s.cfg.DryRun {
+			n.AlertID = s.recordAlert(ctx, *n)
+		}
+		// ミュート中の種類は記録だけして通知しない
+		if s.mutes.muted(alertKey(*n), s.clock.Now()) {
+			return false
+		}
+	}
+	return !s.notificationsMuted()
+}
+
+// sendNotification : targets の通知先へ送り（nil なら全て）、失敗した通知先の名前を返す
+func (s *Server) sendNotification(ctx context.Context, n notify.Notification, targets []string) ([]string, error) {
+	// FEATURE_NOTIFICATIONS=false なら送らない（送れたものとして扱い、送り直さない）
+	if !s.cfg.Features.Notifications {
+		return nil, nil
+	}
+	// ドライランでは組み立てた本文を出力するだけにする
+	if s.cfg.DryRun {
+		ctx = notify.WithDryRun(ctx)
+	}
+	// 止めている通知先 (/api/notifications/mute?channel=) には送らない
+	if targets = s.unmutedTargets(targets); targets != nil && len(targets) == 0 {
+		return nil, nil
+	}
+	var failed []string
+	var errs []error
+	send := func(notifier notify.Notifier) {
+		if m, ok := notifier.(*notify.Multi); ok {
+			f, err := m.NotifyOnly(ctx, n, targets)
+			failed, errs = append(failed, f...), append(errs, err)
+			return
+		}
+		if targets != nil && !slices.Contains(targets, notifier.Name()) {
+			return
+		}
+		if err := notifier.Notify(ctx, n); err != nil {
+			fmt.Printf("Failed to send %s notification: %v\n", notifier.Name(), err)
+			failed, errs = append(failed, notifier.Name()), append(errs, err)
+		}
+	}
+	// 収まった知らせはインシデントの通知先だけへ送る
+	if !n.Resolved {
+		send(s.envNotifier())
+		// DBで管理する通知先（/api/channels）。チャンネルごとのルールはさらに絞り込む
+		if channels := s.channels.Load(); channels != nil {
+			send(channels)
+		}
+	}
+	// PagerDuty / Opsgenie にはエラー以上のログとアラートルールだけを、キーでまとめて送る
+	if s.hasIncidents() && (n.Resolved || s.isIncident(n)) {
+		if n.Key == "" {
+			n.Key = alertKey(n)
+		}
+		if !n.Resolved {
+			s.openIncidents.touch(n.Key, s.clock.Now())
+		}
+		send(s.incidents)
+	}
+	return failed, errors.Join(errs...)
+}
+
+// entryURL : ダッシュボード上のこのログへのリンク（PUBLIC_BASE_URL が未設定なら空）
+func (s *Server) entryURL(e *model.LogEntry) string {
+	if s.cfg.PublicBaseURL == "" || e == nil || e.ID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/#log-%d", strings.TrimRight(s.cfg.PublicBaseURL, "/"), e.ID)
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"go-logger/internal/model"
+	"go-logger/internal/redact"
+	"go-logger/internal/store"
+)
+
+// ==========================================
+// WebSocket (GET /api/ws)
+// ==========================================
+// ダッシュボード向けの配信。検索式 (store.ParseQuery) に一致する新着ログだけを送り、件数は一定間隔でまとめて送る
+//
+//	接続: GET /api/ws?filter=ua~curl&stats_interval=30s
+//	クライアント → サーバー: {"filter": "level>=warn", "stats_interval": "1m"}  条件を変える（省略した項目はそのまま）
+//	サーバー → クライアント: {"type": "subscribed", "filter": "..."} / {"type": "entry", "entry": {...}}
+//	                         {"type": "stats", "stats": {...}} / {"type": "error", "error": "..."}
+
+// wsStatsInterval : 件数を送る間隔の既定値と下限
+const (
+	wsStatsInterval    = 30 * time.Second
+	wsMinStatsInterval = 5 * time.Second
+)
+
+// wsSubscription : クライアントが送る条件
+type wsSubscription struct {
+	Filter        *string `json:"filter"`
+	StatsInterval *string `json:"stats_interval"`
+}
+
+// wsMessage : サーバーが送る1通
+type wsMessage struct {
+	Type   string          `json:"type"`
+	Filter string          `json:"filter,omitempty"`
+	Entry  *model.LogEntry `json:"entry,omitempty"`
+	Stats  *model.Stats    `json:"stats,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// wsFilter : いま有効な条件
+type wsFilter struct {
+	query         *store.Query
+	statsInterval time.Duration
+}
+
+// apply : 送られた条件を反映する（不正なら元のまま）
+func (f *wsFilter) apply(sub wsSubscription) error {
+	next := *f
+	if sub.Filter != nil {
+		next.query = nil
+		if *sub.Filter != "" {
+			q, err := store.ParseQuery(*sub.Filter)
+			if err != nil {
+				return fmt.Errorf("invalid filter: %w", err)
+			}
+			next.query = q
+		}
+	}
+	if sub.StatsInterval != nil {
+		d, err := time.ParseDuration(*sub.StatsInterval)
+		if err != nil || d < wsMinStatsInterval {
+			return fmt.Errorf("invalid stats_interval (e.g. 30s, at least %s)", wsMinStatsInterval)
+		}
+		next.statsInterval = d
+	}
+	*f = next
+	return nil
+}
+
+func (f *wsFilter) String() string {
+	if f.query == nil {
+		return ""
+	}
+	return f.query.String()
+}
+
+// wsHandler : GET /api/ws
+func (s *Server) wsHandler(w http.ResponseWriter, r *http.Request) {
+	s.withProject(w, r, func(projectID int) {
+		filter := wsFilter{statsInterval: wsStatsInterval}
+		var initial wsSubscription
+		if v := r.URL.Query().Get("filter"); v != "" {
+			initial.Filter = &v
+		}
+		if v := r.URL.Query().Get("stats_interval"); v != "" {
+			initial.StatsInterval = &v
+		}
+		if err := filter.apply(initial); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		scope := s.requestScope(r)
+		websocket.Server{
+			Handshake: checkWSOrigin,
+			Handler: func(ws *websocket.Conn) {
+				s.serveWS(r.Context(), ws, projectID, scope, filter)
+			},
+		}.ServeHTTP(wsHijacker{w}, r)
+	})
+}
+
+// wsHijacker : ミドルウェアが包んだ ResponseWriter から Hijack を取り出す（websocket は Hijacker を直接求めるため）
+type wsHijacker struct {
+	http.ResponseWriter
+}
+
+func (w wsHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// checkWSOrigin : ブラウザからは同じホストのページからの接続だけ受ける（ほかのサイトにログイン中の通信を使わせない）
+// Origin を送らないクライアント (curl, websocat など) はそのまま通す
+func checkWSOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host != r.Host {
+		return fmt.Errorf("cross-origin WebSocket from %q", origin)
+	}
+	config.Origin = u
+	return nil
+}
+
+// serveWS : 切断されるまで新着と件数を送る（書き込みはこの関数だけが行う）
+func (s *Server) serveWS(ctx context.Context, ws *websocket.Conn, projectID int, scope redact.Scope, filter wsFilter) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer ws.Close()
+
+	// 受信は別の goroutine で読み、条件の変更として渡す。読めなくなったら切断とみなす
+	subs := make(chan wsSubscription)
+	go func() {
+		defer cancel()
+		for {
+			var sub wsSubscription
+			if err := websocket.JSON.Receive(ws, &sub); err != nil {
+				return
+			}
+			select {
+			case subs <- sub:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	entries, unsubscribe := s.hub.subscribe()
+	defer unsubscribe()
+
+	send := func(m wsMessage) bool {
+		ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return websocket.JSON.Send(ws, m) == nil
+	}
+	sendStats := func() bool {
+		stats, err := s.wsStats(ctx, projectID)
+		if err != nil {
+			return send(wsMessage{Type: "error", Error: "Database error: " + err.Error()})
+		}
+		return send(wsMessage{Type: "stats", Stats: stats})
+	}
+
+	if !send(wsMessage{Type: "subscribed", Filter: filter.String()}) || !sendStats() {
+		return
+	}
+	ticker := s.clock.NewTicker(filter.statsInterval)
+	defer ticker.Stop()
+	for {
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case sub := <-subs:
+			if err := filter.apply(sub); err != nil {
+				ok = send(wsMessage{Type: "error", Error: err.Error()})
+				break
+			}
+			ticker.Reset(filter.statsInterval)
+			ok = send(wsMessage{Type: "subscribed", Filter: filter.String()})
+		case e, open := <-entries:
+			if !open {
+				return
+			}
+			if e.ProjectID != projectID || (filter.query != nil && !filter.query.Match(e)) {
+				continue
+			}
+			ok = send(wsMessage{Type: "entry", Entry: s.redact.EntryPtr(e, scope)})
+		case <-ticker.C():
+			ok = sendStats()
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// wsStats : GET /api/stats と同じ件数（直近24時間のセッションを含む。数えられない保存先では省く）
+func (s *Server) wsStats(ctx context.Context, projectID int) (*model.Stats, error) {
+	now := s.clock.Now()
+	f := store.LogFilter{ProjectID: projectID}
+	stats, err := s.store.Stats(ctx, f, now.Add(-24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	f.Since = now.Add(-24 * time.Hour)
+	if sessions, err := s.store.SessionStats(ctx, f, s.cfg.SessionGap); err == nil {
+		sessions.Since = f.Since
+		stats.Sessions = sessions
+	}
+	return stats, nil
+}
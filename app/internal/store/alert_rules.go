@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// アラートルール
+// ==========================================
+
+const alertRuleColumns = "id, name, kind, project_id, event_type, min_level, field, pattern, threshold, window_seconds, cooldown_seconds, level, enabled, last_fired_at, created_at, query"
+
+func scanAlertRule(row rowScanner) (model.AlertRule, error) {
+	var r model.AlertRule
+	var lastFired sql.NullTime
+	err := row.Scan(&r.ID, &r.Name, &r.Kind, &r.ProjectID, &r.EventType, &r.MinLevel, &r.Field, &r.Pattern,
+		&r.Threshold, &r.WindowSeconds, &r.CooldownSeconds, &r.Level, &r.Enabled, &lastFired, &r.CreatedAt, &r.Query)
+	if lastFired.Valid {
+		r.LastFiredAt = &lastFired.Time
+	}
+	return r, err
+}
+
+// ListAlertRules : ルール一覧（停止中のものも含む）
+func (p *Postgres) ListAlertRules(ctx context.Context) ([]model.AlertRule, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT "+alertRuleColumns+" FROM alert_rules ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []model.AlertRule{}
+	for rows.Next() {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// AlertRuleByID : ルールを返す（なければ ErrNotFound）
+func (p *Postgres) AlertRuleByID(ctx context.Context, id int) (model.AlertRule, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	r, err := scanAlertRule(p.DB().QueryRowContext(ctx,
+		"SELECT "+alertRuleColumns+" FROM alert_rules WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrNotFound
+	}
+	return r, err
+}
+
+// CreateAlertRule : ルールを作り、ID と作成日時を r に書き戻す
+func (p *Postgres) CreateAlertRule(ctx context.Context, r *model.AlertRule) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	return p.DB().QueryRowContext(ctx,
+		`INSERT INTO alert_rules (name, kind, project_id, event_type, min_level, field, pattern, threshold, window_seconds, cooldown_seconds, level, enabled, query)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, created_at`,
+		r.Name, r.Kind, r.ProjectID, r.EventType, r.MinLevel, r.Field, r.Pattern,
+		r.Threshold, r.WindowSeconds, r.CooldownSeconds, r.Level, r.Enabled, r.Query).Scan(&r.ID, &r.CreatedAt)
+}
+
+// UpdateAlertRule : r.ID のルールを r の内容で上書きする（なければ ErrNotFound）
+func (p *Postgres) UpdateAlertRule(ctx context.Context, r *model.AlertRule) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx,
+		`UPDATE alert_rules SET name = $1, kind = $2, project_id = $3, event_type = $4, min_level = $5, field = $6, pattern = $7,
+			threshold = $8, window_seconds = $9, cooldown_seconds = $10, level = $11, enabled = $12, query = $13
+		WHERE id = $14`,
+		r.Name, r.Kind, r.ProjectID, r.EventType, r.MinLevel, r.Field, r.Pattern,
+		r.Threshold, r.WindowSeconds, r.CooldownSeconds, r.Level, r.Enabled, r.Query, r.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteAlertRule : ルールを削除する（なければ ErrNotFound）
+func (p *Postgres) DeleteAlertRule(ctx context.Context, id int) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM alert_rules WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MarkAlertRuleFired : 最後に通知した日時を記録する（再起動後もクールダウンを守るため）
+func (p *Postgres) MarkAlertRuleFired(ctx context.Context, id int, at time.Time) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	_, err := p.DB().ExecContext(ctx, "UPDATE alert_rules SET last_fired_at = $1 WHERE id = $2", at, id)
+	return err
+}
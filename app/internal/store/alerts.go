@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/tracing"
+)
+
+// ==========================================
+// アラートの履歴
+// ==========================================
+
+const alertColumns = "id, source, key, level, title, text, COALESCE(entry_id, 0), fired_at, acknowledged_at, acknowledged_by"
+
+func scanAlert(row rowScanner) (model.Alert, error) {
+	var a model.Alert
+	var acked sql.NullTime
+	err := row.Scan(&a.ID, &a.Source, &a.Key, &a.Level, &a.Title, &a.Text, &a.EntryID, &a.FiredAt, &acked, &a.AcknowledgedBy)
+	if acked.Valid {
+		a.AcknowledgedAt = &acked.Time
+	}
+	return a, err
+}
+
+// InsertAlert : アラートを記録し、ID を a に書き戻す
+func (p *Postgres) InsertAlert(ctx context.Context, a *model.Alert) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	const insertSQL = `INSERT INTO alerts (source, key, level, title, text, entry_id, fired_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	entryID := sql.NullInt64{Int64: int64(a.EntryID), Valid: a.EntryID != 0}
+	ctx, span := tracing.StartDBSpan(ctx, "INSERT", "alerts", insertSQL)
+	err := p.DB().QueryRowContext(ctx, insertSQL, a.Source, a.Key, a.Level, a.Title, a.Text, entryID, a.FiredAt).Scan(&a.ID)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+// ListAlerts : since 以降のアラートを新しい順に返す（最新100件）
+func (p *Postgres) ListAlerts(ctx context.Context, since time.Time, limit int) ([]model.Alert, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	selectSQL := "SELECT " + alertColumns + " FROM alerts WHERE fired_at >= $1 ORDER BY fired_at DESC, id DESC LIMIT $2"
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "alerts", selectSQL)
+	rows, err := p.DB().QueryContext(ctx, selectSQL, since, limitOr(limit, 100))
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []model.Alert{}
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// AlertByID : アラートを返す（なければ ErrNotFound）
+func (p *Postgres) AlertByID(ctx context.Context, id int) (model.Alert, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	a, err := scanAlert(p.DB().QueryRowContext(ctx, "SELECT "+alertColumns+" FROM alerts WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, ErrNotFound
+	}
+	return a, err
+}
+
+// AcknowledgeAlert : アラートを確認済みにする（確認済みなら最初の確認を残す。なければ ErrNotFound）
+func (p *Postgres) AcknowledgeAlert(ctx context.Context, id int, by string, at time.Time) (model.Alert, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	a, err := scanAlert(p.DB().QueryRowContext(ctx,
+		`UPDATE alerts SET acknowledged_at = COALESCE(acknowledged_at, $1),
+			acknowledged_by = CASE WHEN acknowledged_at IS NULL THEN $2 ELSE acknowledged_by END
+		WHERE id = $3 RETURNING `+alertColumns, at, by, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, ErrNotFound
+	}
+	return a, err
+}
+
+// ==========================================
+// ミュート
+// ==========================================
+
+// MuteAlertKey : until まで key のアラートを通知しない（既にミュート中なら期限を上書きする）
+func (p *Postgres) MuteAlertKey(ctx context.Context, key string, until time.Time, by string) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	_, err := p.DB().ExecContext(ctx,
+		`INSERT INTO alert_mutes (key, muted_until, muted_by) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET muted_until = EXCLUDED.muted_until, muted_by = EXCLUDED.muted_by`,
+		key, until, by)
+	return err
+}
+
+// UnmuteAlertKey : key のミュートを解除する（ミュートしていなければ何もしない）
+func (p *Postgres) UnmuteAlertKey(ctx context.Context, key string) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	_, err := p.DB().ExecContext(ctx, "DELETE FROM alert_mutes WHERE key = $1", key)
+	return err
+}
+
+// ActiveMutes : now の時点でミュート中のキーと期限（期限切れの行はついでに消す）
+func (p *Postgres) ActiveMutes(ctx context.Context, now time.Time) (map[string]time.Time, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	if _, err := p.DB().ExecContext(ctx, "DELETE FROM alert_mutes WHERE muted_until <= $1", now); err != nil {
+		return nil, err
+	}
+	rows, err := p.DB().QueryContext(ctx, "SELECT key, muted_until FROM alert_mutes")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mutes := map[string]time.Time{}
+	for rows.Next() {
+		var key string
+		var until time.Time
+		if err := rows.Scan(&key, &until); err != nil {
+			return nil, err
+		}
+		mutes[key] = until
+	}
+	return mutes, rows.Err()
+}
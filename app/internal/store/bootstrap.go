@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ==========================================
+// 初回のDB作成 (管理者の接続で、DB・アプリ用ロール・権限を用意する)
+// ==========================================
+
+// BootstrapOptions : 作るDBとロール
+type BootstrapOptions struct {
+	Database string
+	Role     string
+	Password string
+	// DMLOnly : アプリ用ロールには読み書きだけを許し、マイグレーションは管理者の接続で適用する
+	// その場合 INDEXED_FIELDS の列の追加もできないので、以後の変更は管理者の接続（main migrate）で行う
+	// （false ならアプリ用ロールに public スキーマへの CREATE を許し、起動時のマイグレーションを任せる）
+	DMLOnly bool
+}
+
+// Bootstrap : adminDSN（スーパーユーザーかCREATEDB・CREATEROLE を持つロール）で接続し、
+// ロールとDBがなければ作って権限を付ける。何度実行してもよい（既存のロールはパスワードだけ更新する）
+func Bootstrap(ctx context.Context, adminDSN string, opts BootstrapOptions) error {
+	admin, err := openDB(ctx, adminDSN)
+	if err != nil {
+		return fmt.Errorf("connect as admin: %w", err)
+	}
+	defer admin.Close()
+
+	role, database := pq.QuoteIdentifier(opts.Role), pq.QuoteIdentifier(opts.Database)
+
+	// 1. アプリ用ロール（ログインだけできる。スーパーユーザー・DB作成・ロール作成は不可）
+	var exists bool
+	if err := admin.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", opts.Role).Scan(&exists); err != nil {
+		return err
+	}
+	verb := "CREATE"
+	if exists {
+		verb = "ALTER"
+	}
+	// パスワードはパラメータにできないので、リテラルとしてエスケープする
+	if _, err := admin.ExecContext(ctx, fmt.Sprintf("%s ROLE %s WITH LOGIN NOSUPERUSER NOCREATEDB NOCREATEROLE PASSWORD %s",
+		verb, role, pq.QuoteLiteral(opts.Password))); err != nil {
+		return fmt.Errorf("%s role: %w", strings.ToLower(verb), err)
+	}
+	fmt.Printf("Role %s is ready\n", opts.Role)
+
+	// 2. DB（CREATE DATABASE はトランザクション内で実行できないので、存在を確かめてから作る）
+	if err := admin.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", opts.Database).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+database); err != nil {
+			return fmt.Errorf("create database: %w", err)
+		}
+		fmt.Printf("Created database %s\n", opts.Database)
+	}
+	if _, err := admin.ExecContext(ctx, fmt.Sprintf("REVOKE ALL ON DATABASE %s FROM PUBLIC", database)); err != nil {
+		return err
+	}
+	if _, err := admin.ExecContext(ctx, fmt.Sprintf("GRANT CONNECT, TEMPORARY ON DATABASE %s TO %s", database, role)); err != nil {
+		return err
+	}
+
+	// 3. 作ったDBの中の権限
+	target, err := openDB(ctx, withDatabase(adminDSN, opts.Database))
+	if err != nil {
+		return fmt.Errorf("connect to %s as admin: %w", opts.Database, err)
+	}
+	defer target.Close()
+
+	statements := []string{"REVOKE CREATE ON SCHEMA public FROM PUBLIC", "GRANT USAGE ON SCHEMA public TO " + role}
+	if opts.DMLOnly {
+		if err := runMigrations(ctx, target); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+		statements = append(statements,
+			"GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO "+role,
+			"GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO "+role,
+			// 管理者が後から適用するマイグレーションのテーブルにも同じ権限を付ける
+			"ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO "+role,
+			"ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO "+role,
+		)
+	} else {
+		statements = append(statements, "GRANT CREATE ON SCHEMA public TO "+role)
+	}
+	for _, stmt := range statements {
+		if _, err := target.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	fmt.Printf("Granted privileges on %s to %s\n", opts.Database, opts.Role)
+	return nil
+}
+
+// withDatabase : 接続文字列の接続先DBを差し替える（URL形式と key=value 形式のどちらも）
+func withDatabase(dsn, database string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if u, err := url.Parse(dsn); err == nil {
+			u.Path = "/" + database
+			return u.String()
+		}
+	}
+	// key=value 形式は後に書いた値が優先される
+	return dsn + " dbname='" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(database) + "'"
+}
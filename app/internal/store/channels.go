@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 通知先
+// ==========================================
+
+const channelColumns = "id, name, type, url, token, chat_id, settings, enabled, rules, template, created_at, updated_at"
+
+func scanChannel(row rowScanner) (model.Channel, error) {
+	var c model.Channel
+	var settings []byte
+	err := row.Scan(&c.ID, &c.Name, &c.Type, &c.URL, &c.Token, &c.ChatID, &settings, &c.Enabled, &c.Rules, &c.Template, &c.CreatedAt, &c.UpdatedAt)
+	if err == nil && len(settings) > 0 {
+		err = json.Unmarshal(settings, &c.Settings)
+	}
+	if len(c.Settings) == 0 {
+		c.Settings = nil
+	}
+	return c, err
+}
+
+// channelSettings : settings 列に書く値（なければ {}）
+func channelSettings(c *model.Channel) []byte {
+	if len(c.Settings) == 0 {
+		return []byte("{}")
+	}
+	b, _ := json.Marshal(c.Settings)
+	return b
+}
+
+// ListChannels : 通知先一覧（停止中のものも含む）
+func (p *Postgres) ListChannels(ctx context.Context) ([]model.Channel, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT "+channelColumns+" FROM notification_channels ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []model.Channel{}
+	for rows.Next() {
+		c, err := scanChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, c)
+	}
+	return channels, rows.Err()
+}
+
+// ChannelByID : 通知先を返す（なければ ErrNotFound）
+func (p *Postgres) ChannelByID(ctx context.Context, id int) (model.Channel, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	c, err := scanChannel(p.DB().QueryRowContext(ctx,
+		"SELECT "+channelColumns+" FROM notification_channels WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, ErrNotFound
+	}
+	return c, err
+}
+
+// CreateChannel : 通知先を作り、ID と作成日時を c に書き戻す
+func (p *Postgres) CreateChannel(ctx context.Context, c *model.Channel) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	return p.DB().QueryRowContext(ctx,
+		`INSERT INTO notification_channels (name, type, url, token, chat_id, settings, enabled, rules, template)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at, updated_at`,
+		c.Name, c.Type, c.URL, c.Token, c.ChatID, channelSettings(c), c.Enabled, c.Rules, c.Template).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+}
+
+// UpdateChannel : c.ID の通知先を c の内容で上書きする（なければ ErrNotFound）
+func (p *Postgres) UpdateChannel(ctx context.Context, c *model.Channel) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	err := p.DB().QueryRowContext(ctx,
+		`UPDATE notification_channels SET name = $1, type = $2, url = $3, token = $4, chat_id = $5, settings = $6, enabled = $7, rules = $8, template = $9, updated_at = $10
+		WHERE id = $11 RETURNING created_at, updated_at`,
+		c.Name, c.Type, c.URL, c.Token, c.ChatID, channelSettings(c), c.Enabled, c.Rules, c.Template, p.clock.Now(), c.ID).Scan(&c.CreatedAt, &c.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// DeleteChannel : 通知先を削除する（なければ ErrNotFound）
+func (p *Postgres) DeleteChannel(ctx context.Context, id int) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM notification_channels WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 処理できなかったキューの中身 (dead letters)
+// ==========================================
+
+// ListDeadLetters : 新しい順の一覧
+func (p *Postgres) ListDeadLetters(ctx context.Context) ([]model.DeadLetter, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT id, queue, payload, attempts, last_error, created_at FROM dead_letters ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := []model.DeadLetter{}
+	for rows.Next() {
+		var d model.DeadLetter
+		if err := rows.Scan(&d.ID, &d.Queue, (*[]byte)(&d.Payload), &d.Attempts, &d.LastError, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		letters = append(letters, d)
+	}
+	return letters, rows.Err()
+}
+
+// InsertDeadLetter : 保存し、ID と作成日時を d に書き戻す
+func (p *Postgres) InsertDeadLetter(ctx context.Context, d *model.DeadLetter) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	return p.DB().QueryRowContext(ctx,
+		"INSERT INTO dead_letters (queue, payload, attempts, last_error) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		d.Queue, []byte(d.Payload), d.Attempts, d.LastError).Scan(&d.ID, &d.CreatedAt)
+}
+
+// DeadLetterByID : 1件取得する（なければ ErrNotFound）
+func (p *Postgres) DeadLetterByID(ctx context.Context, id int) (*model.DeadLetter, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	var d model.DeadLetter
+	err := p.DB().QueryRowContext(ctx,
+		"SELECT id, queue, payload, attempts, last_error, created_at FROM dead_letters WHERE id = $1", id).
+		Scan(&d.ID, &d.Queue, (*[]byte)(&d.Payload), &d.Attempts, &d.LastError, &d.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// DeleteDeadLetter : 削除する（なければ ErrNotFound）
+func (p *Postgres) DeleteDeadLetter(ctx context.Context, id int) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM dead_letters WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// BufferedWrites : 再接続待ちでバッファに積まれている書き込みの件数
+func (p *Postgres) BufferedWrites(ctx context.Context) (int, error) {
+	return p.buffer.Len(ctx)
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// ==========================================
+// 起動前の自己診断 (`main doctor`。DBには何も書き込まない)
+// ==========================================
+
+// doctorTables : アプリが読み書きするテーブルと必要な権限
+var doctorTables = []string{"access_logs", "projects", "alerts", "alert_rules", "notification_channels", "dead_letters"}
+
+// PendingMigrations : 未適用のマイグレーション（schema_migrations がなければ全て）
+func (p *Postgres) PendingMigrations(ctx context.Context) ([]string, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var exists bool
+	if err := p.DB().QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, err
+	}
+	applied := map[string]bool{}
+	if exists {
+		rows, err := p.DB().QueryContext(ctx, "SELECT version FROM schema_migrations")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				return nil, err
+			}
+			applied[v] = true
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	var pending []string
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			pending = append(pending, m.Version)
+		}
+	}
+	return pending, nil
+}
+
+// MissingPrivileges : 接続しているロールに足りない権限（"access_logs: DELETE" の形）
+// まだ作られていないテーブルはマイグレーションで作るので、スキーマに CREATE できるかを見る
+func (p *Postgres) MissingPrivileges(ctx context.Context) ([]string, error) {
+	var missing []string
+	for _, table := range doctorTables {
+		var exists bool
+		if err := p.DB().QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		for _, priv := range []string{"SELECT", "INSERT", "UPDATE", "DELETE"} {
+			var ok bool
+			if err := p.DB().QueryRowContext(ctx, "SELECT has_table_privilege(current_user, $1, $2)", table, priv).Scan(&ok); err != nil {
+				return nil, err
+			}
+			if !ok {
+				missing = append(missing, fmt.Sprintf("%s: %s", table, priv))
+			}
+		}
+	}
+	var canCreate bool
+	if err := p.DB().QueryRowContext(ctx, "SELECT has_schema_privilege(current_user, current_schema(), 'CREATE')").Scan(&canCreate); err != nil {
+		return nil, err
+	}
+	if !canCreate {
+		missing = append(missing, "schema: CREATE (needed by migrations and INDEXED_FIELDS)")
+	}
+	return missing, nil
+}
+
+// PingReplica : レプリカに繋がるか（設定していなければ何もしない）
+func (p *Postgres) PingReplica(ctx context.Context) error {
+	if p.replica == nil {
+		return nil
+	}
+	p.replica.mu.RLock()
+	db := p.replica.db
+	p.replica.mu.RUnlock()
+	if db == nil {
+		return fmt.Errorf("not connected")
+	}
+	return db.PingContext(ctx)
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 除外パターン
+// ==========================================
+
+// ListExclusions : 除外パターンの一覧
+func (p *Postgres) ListExclusions(ctx context.Context) ([]model.Exclusion, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT id, field, pattern, scope, note, created_at FROM exclusions ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exclusions := []model.Exclusion{}
+	for rows.Next() {
+		var e model.Exclusion
+		if err := rows.Scan(&e.ID, &e.Field, &e.Pattern, &e.Scope, &e.Note, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		exclusions = append(exclusions, e)
+	}
+	return exclusions, rows.Err()
+}
+
+// CreateExclusion : 除外パターンを作り、ID と作成日時を e に書き戻す
+func (p *Postgres) CreateExclusion(ctx context.Context, e *model.Exclusion) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	return p.DB().QueryRowContext(ctx,
+		"INSERT INTO exclusions (field, pattern, scope, note) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		e.Field, e.Pattern, e.Scope, e.Note).Scan(&e.ID, &e.CreatedAt)
+}
+
+// DeleteExclusion : 除外パターンを削除する（なければ ErrNotFound）
+func (p *Postgres) DeleteExclusion(ctx context.Context, id int) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM exclusions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
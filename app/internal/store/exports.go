@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 非同期の書き出し (export_jobs)
+// ==========================================
+
+// exportJobColumns : export_jobs から読む列（scanExportJob と同じ順）
+const exportJobColumns = "id, project_id, format, filters, status, rows, bytes, location, object, error, instance, created_at, started_at, finished_at, expires_at"
+
+// scanExportJob : 1行を読む
+func scanExportJob(row interface{ Scan(...any) error }) (*model.ExportJob, error) {
+	var j model.ExportJob
+	var filters []byte
+	var startedAt, finishedAt, expiresAt sql.NullTime
+	if err := row.Scan(&j.ID, &j.ProjectID, &j.Format, &filters, &j.Status, &j.Rows, &j.Bytes, &j.Location, &j.Object,
+		&j.Error, &j.Instance, &j.CreatedAt, &startedAt, &finishedAt, &expiresAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(filters, &j.Filters); err != nil {
+		return nil, err
+	}
+	j.StartedAt, j.FinishedAt, j.ExpiresAt = nullTimePtr(startedAt), nullTimePtr(finishedAt), nullTimePtr(expiresAt)
+	return &j, nil
+}
+
+// nullTimePtr : NULL なら nil
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// CreateExportJob : 待ちの状態で保存し、ID と作成日時を j に書き戻す
+func (p *Postgres) CreateExportJob(ctx context.Context, j *model.ExportJob) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	filters, err := json.Marshal(j.Filters)
+	if err != nil {
+		return err
+	}
+	j.Status = model.ExportQueued
+	return p.DB().QueryRowContext(ctx,
+		"INSERT INTO export_jobs (project_id, format, filters, status) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		j.ProjectID, j.Format, string(filters), j.Status).Scan(&j.ID, &j.CreatedAt)
+}
+
+// ExportJobByID : 1件取得する（なければ ErrNotFound）
+func (p *Postgres) ExportJobByID(ctx context.Context, id int) (*model.ExportJob, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	j, err := scanExportJob(p.DB().QueryRowContext(ctx, "SELECT "+exportJobColumns+" FROM export_jobs WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return j, err
+}
+
+// ClaimExportJob : いちばん古い待ちの書き出しを instance の処理中にして返す（なければ nil）
+// 同時に取ろうとしたインスタンスは SKIP LOCKED で次の行を取る
+func (p *Postgres) ClaimExportJob(ctx context.Context, instance string, now time.Time) (*model.ExportJob, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	j, err := scanExportJob(p.DB().QueryRowContext(ctx, `
+		UPDATE export_jobs SET status = $1, instance = $2, started_at = $3
+		WHERE id = (SELECT id FROM export_jobs WHERE status = $4 ORDER BY id FOR UPDATE SKIP LOCKED LIMIT 1)
+		RETURNING `+exportJobColumns, model.ExportRunning, instance, now, model.ExportQueued))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return j, err
+}
+
+// FinishExportJob : 書き出しの結果（状態・件数・ファイル・エラー・期限）を保存する
+func (p *Postgres) FinishExportJob(ctx context.Context, j *model.ExportJob) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	_, err := p.DB().ExecContext(ctx,
+		`UPDATE export_jobs SET status = $2, rows = $3, bytes = $4, location = $5, object = $6, error = $7, finished_at = $8, expires_at = $9
+		WHERE id = $1`,
+		j.ID, j.Status, j.Rows, j.Bytes, j.Location, j.Object, j.Error, j.FinishedAt, j.ExpiresAt)
+	return err
+}
+
+// RequeueExportJobs : instance が処理中のまま止まった書き出しを待ちに戻す（起動した時に呼ぶ）
+func (p *Postgres) RequeueExportJobs(ctx context.Context, instance string) (int64, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx,
+		"UPDATE export_jobs SET status = $1, started_at = NULL WHERE status = $2 AND instance = $3",
+		model.ExportQueued, model.ExportRunning, instance)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteExpiredExportJobs : 期限を過ぎた書き出しを削除して返す（呼び出し側がファイルを消す）
+// ほかのインスタンスのディスクにあるファイルは消せないので、バケットのものと instance のものだけ
+func (p *Postgres) DeleteExpiredExportJobs(ctx context.Context, instance string, now time.Time) ([]model.ExportJob, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "DELETE FROM export_jobs WHERE expires_at <= $1 AND (location = 's3' OR instance = $2) RETURNING "+exportJobColumns, now, instance)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []model.ExportJob
+	for rows.Next() {
+		j, err := scanExportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, rows.Err()
+}
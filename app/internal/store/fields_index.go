@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// JSONBフィールドの索引付き抽出 (生成列 + インデックス)
+// ==========================================
+
+// IndexedField : fields から取り出して索引を張るキー
+type IndexedField struct {
+	Name string // fields のキー（列名は f_<name>）
+	Type string // text / bigint / numeric / boolean
+}
+
+// fieldTypeExpr : 型ごとの生成列の式（変換できない値は NULL）
+var fieldTypeExpr = map[string]string{
+	"text":    "fields->>'%s'",
+	"bigint":  "logger_try_bigint(fields->>'%s')",
+	"numeric": "logger_try_numeric(fields->>'%s')",
+	"boolean": "logger_try_boolean(fields->>'%s')",
+}
+
+var fieldNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,40}$`)
+
+// IndexedFieldsFromEnv : INDEXED_FIELDS="order_id,amount:numeric,paid:boolean" を読み込む（型省略時は text）
+func IndexedFieldsFromEnv() []IndexedField {
+	var fields []IndexedField
+	for _, item := range config.List("INDEXED_FIELDS") {
+		name, typ, _ := strings.Cut(item, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		typ = strings.ToLower(strings.TrimSpace(typ))
+		if typ == "" {
+			typ = "text"
+		}
+		if !fieldNamePattern.MatchString(name) {
+			fmt.Printf("Ignoring indexed field %q: use lowercase letters, digits and _\n", name)
+			continue
+		}
+		if _, ok := fieldTypeExpr[typ]; !ok {
+			fmt.Printf("Ignoring indexed field %q: unsupported type %q\n", name, typ)
+			continue
+		}
+		fields = append(fields, IndexedField{Name: name, Type: typ})
+	}
+	return fields
+}
+
+// EnsureIndexedFields : 生成列とインデックスがなければ作る
+// (名前は正規表現で検証済みなので、SQLに埋め込んでも安全)
+func (p *Postgres) EnsureIndexedFields(ctx context.Context, fields []IndexedField) error {
+	conn := p.DB()
+	for _, f := range fields {
+		column := "f_" + f.Name
+		expr := fmt.Sprintf(fieldTypeExpr[f.Type], f.Name)
+
+		var existingType sql.NullString
+		if err := conn.QueryRowContext(ctx,
+			`SELECT data_type FROM information_schema.columns WHERE table_name = 'access_logs' AND column_name = $1`,
+			column).Scan(&existingType); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if existingType.Valid && existingType.String != f.Type {
+			return fmt.Errorf("indexed field %s already exists as %s (drop column %s to change its type)",
+				f.Name, existingType.String, column)
+		}
+
+		if !existingType.Valid {
+			fmt.Printf("Adding indexed field %s (%s)...\n", f.Name, f.Type)
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf(
+				"ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS %s %s GENERATED ALWAYS AS (%s) STORED",
+				column, f.Type, expr)); err != nil {
+				return fmt.Errorf("add column %s: %w", column, err)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS access_logs_%s_idx ON access_logs (project_id, %s)", column, column)); err != nil {
+			return fmt.Errorf("create index on %s: %w", column, err)
+		}
+		p.indexedFields[f.Name] = f
+	}
+	return nil
+}
+
+// fieldFilter : ?field.<name>=<value> の条件（索引付きなら生成列、それ以外は fields->>key）
+func (p *Postgres) fieldFilter(b *whereBuilder, name, value string) {
+	if f, ok := p.indexedFields[name]; ok {
+		b.add(fmt.Sprintf("f_%s = ?::%s", f.Name, f.Type), value)
+		return
+	}
+	b.add("fields->>? = ?", name, value)
+}
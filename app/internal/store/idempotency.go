@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ==========================================
+// 書き込みの Idempotency-Key
+// ==========================================
+
+// IdempotentResponse : キーに保存した最初の応答（Status が0なら処理中）
+type IdempotentResponse struct {
+	RequestHash string
+	Status      int
+	Headers     map[string]string
+	Body        []byte
+}
+
+// ClaimIdempotencyKey : キーを処理中として押さえる（expiresAt まで）。押さえられたら claimed=true
+// 既に使われたキーなら、保存した応答（処理中なら Status が0）を返す。期限を過ぎたキーは押さえ直せる
+func (p *Postgres) ClaimIdempotencyKey(ctx context.Context, key, requestHash string, now, expiresAt time.Time) (resp IdempotentResponse, claimed bool, err error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	err = p.DB().QueryRowContext(ctx, `
+		INSERT INTO idempotency_keys (key, request_hash, created_at, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET request_hash = EXCLUDED.request_hash, status = NULL, headers = NULL, body = NULL,
+			created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+		RETURNING key`, key, requestHash, now, expiresAt).Scan(new(string))
+	if err == nil {
+		return resp, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return resp, false, err
+	}
+
+	var status sql.NullInt64
+	var headers []byte
+	err = p.DB().QueryRowContext(ctx, "SELECT request_hash, status, headers, body FROM idempotency_keys WHERE key = $1", key).
+		Scan(&resp.RequestHash, &status, &headers, &resp.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		// 押さえようとした間に消えた（期限切れの削除と重なった）。処理中として扱い、送り直してもらう
+		return resp, false, nil
+	}
+	if err != nil {
+		return resp, false, err
+	}
+	resp.Status = int(status.Int64)
+	if len(headers) > 0 {
+		if err := json.Unmarshal(headers, &resp.Headers); err != nil {
+			return resp, false, err
+		}
+	}
+	return resp, false, nil
+}
+
+// CompleteIdempotencyKey : 押さえたキーに応答を保存する（expiresAt まで同じ応答を返す）
+func (p *Postgres) CompleteIdempotencyKey(ctx context.Context, key string, resp IdempotentResponse, expiresAt time.Time) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	headers, err := json.Marshal(resp.Headers)
+	if err != nil {
+		return err
+	}
+	_, err = p.DB().ExecContext(ctx,
+		"UPDATE idempotency_keys SET status = $2, headers = $3, body = $4, expires_at = $5 WHERE key = $1",
+		key, resp.Status, string(headers), resp.Body, expiresAt)
+	return err
+}
+
+// ReleaseIdempotencyKey : 押さえたキーを外す（処理に失敗し、送り直しを受け付ける場合）
+func (p *Postgres) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	_, err := p.DB().ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND status IS NULL", key)
+	return err
+}
+
+// PurgeIdempotencyKeys : 期限を過ぎたキーを削除する
+func (p *Postgres) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// ==========================================
+// 索引の確認 (起動時と `main doctor`。手で消した・マイグレーションが途中で止まった索引を見つける)
+// ==========================================
+
+// ExpectedIndex : マイグレーションで作る索引1つ分
+type ExpectedIndex struct {
+	Name   string
+	Create string // 作り直す時の SQL
+}
+
+// ExpectedIndexes : マイグレーションで作る索引（INDEXED_FIELDS の索引は起動時に作るので含めない）
+var ExpectedIndexes = []ExpectedIndex{
+	{"access_logs_project_id_idx", "CREATE INDEX access_logs_project_id_idx ON access_logs (project_id, id DESC)"},
+	{"access_logs_level_idx", "CREATE INDEX access_logs_level_idx ON access_logs (project_id, level, id DESC)"},
+	{"idx_access_logs_search_vector", "CREATE INDEX idx_access_logs_search_vector ON access_logs USING GIN (search_vector)"},
+	{"idx_access_logs_uid", "CREATE UNIQUE INDEX idx_access_logs_uid ON access_logs (uid, created_at)"},
+	{"idx_access_logs_expires_at", "CREATE INDEX idx_access_logs_expires_at ON access_logs (expires_at) WHERE expires_at IS NOT NULL"},
+	{"idx_access_logs_created_at", "CREATE INDEX idx_access_logs_created_at ON access_logs (project_id, created_at DESC)"},
+	{"idx_access_logs_event_type", "CREATE INDEX idx_access_logs_event_type ON access_logs (project_id, event_type, id DESC)"},
+	{"idx_access_logs_user_agent", "CREATE INDEX idx_access_logs_user_agent ON access_logs (project_id, user_agent)"},
+	{"idx_access_logs_ip", "CREATE INDEX idx_access_logs_ip ON access_logs (ip)"},
+	{"idx_alerts_fired_at", "CREATE INDEX idx_alerts_fired_at ON alerts (fired_at DESC)"},
+	{"uptime_checks_name_region_idx", "CREATE INDEX uptime_checks_name_region_idx ON uptime_checks (check_name, region, checked_at DESC)"},
+}
+
+// MissingIndexes : ExpectedIndexes のうち、DBにない索引
+func (p *Postgres) MissingIndexes(ctx context.Context) ([]ExpectedIndex, error) {
+	var missing []ExpectedIndex
+	for _, idx := range ExpectedIndexes {
+		var exists bool
+		if err := p.DB().QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", idx.Name).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("check index %s: %w", idx.Name, err)
+		}
+		if !exists {
+			missing = append(missing, idx)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// IPアドレスの許可・拒否リスト
+// ==========================================
+
+// ListIPRules : 許可・拒否ルールの一覧
+func (p *Postgres) ListIPRules(ctx context.Context) ([]model.IPRule, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT id, cidr, action, note, created_at FROM ip_rules ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []model.IPRule{}
+	for rows.Next() {
+		var r model.IPRule
+		if err := rows.Scan(&r.ID, &r.CIDR, &r.Action, &r.Note, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// CreateIPRule : ルールを作り、ID と作成日時を r に書き戻す
+func (p *Postgres) CreateIPRule(ctx context.Context, r *model.IPRule) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	return p.DB().QueryRowContext(ctx,
+		"INSERT INTO ip_rules (cidr, action, note) VALUES ($1, $2, $3) RETURNING id, created_at",
+		r.CIDR, r.Action, r.Note).Scan(&r.ID, &r.CreatedAt)
+}
+
+// DeleteIPRule : ルールを削除する（なければ ErrNotFound）
+func (p *Postgres) DeleteIPRule(ctx context.Context, id int) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM ip_rules WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
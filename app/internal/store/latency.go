@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"strconv"
+
+	"go-logger/internal/model"
+	"go-logger/internal/tracing"
+)
+
+// ==========================================
+// 応答ステータスと処理時間 (呼び出し側が status / duration_ms を報告したログだけ数える)
+// ==========================================
+
+// statusClasses : 数えるステータスの百の位（書き込み時に 100〜599 に限っている）
+var statusClasses = []int{1, 2, 3, 4, 5}
+
+// LatencyStats : f の範囲のステータスの内訳・5xx の割合と、処理時間の p50 / p95（percentile_cont）
+func (p *Postgres) LatencyStats(ctx context.Context, f LogFilter) (*model.LatencyStats, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	b := p.logFilter(f)
+	b.add("(status IS NOT NULL OR duration_ms IS NOT NULL)")
+	selectSQL := `SELECT COUNT(duration_ms),
+		COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms), 0),
+		COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY duration_ms), 0)`
+	for _, c := range statusClasses {
+		selectSQL += ", COUNT(*) FILTER (WHERE status / 100 = " + strconv.Itoa(c) + ")"
+	}
+	selectSQL += " FROM access_logs" + b.where()
+
+	l := &model.LatencyStats{ByClass: map[string]int{}}
+	classes := make([]int, len(statusClasses))
+	dest := []any{&l.Timed, &l.P50MS, &l.P95MS}
+	for i := range classes {
+		dest = append(dest, &classes[i])
+	}
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+	err := p.ReadDB().QueryRowContext(ctx, selectSQL, b.args...).Scan(dest...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range statusClasses {
+		l.Add(c*100, classes[i])
+	}
+	l.Finish()
+	return l, nil
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"sync"
+)
+
+// ==========================================
+// リーダーの選出 (pg_try_advisory_lock)
+// ==========================================
+// 同じDBを使うインスタンスのうち、ロックを取れた1つだけが定期処理を動かす
+// ロックはセッションに付くので、1つの接続を持ち続ける。リーダーが落ちると接続が切れてロックが外れ、
+// 次に Hold したインスタンスが引き継ぐ
+
+// LeaderLock : 名前ごとの advisory lock
+type LeaderLock struct {
+	p   *Postgres
+	key int64
+
+	mu   sync.Mutex
+	conn *sql.Conn // ロックを持っている接続（持っていなければ nil）
+}
+
+// LeaderLock : name のロック（"go-logger:<name>" のハッシュを鍵にする）
+func (p *Postgres) LeaderLock(name string) *LeaderLock {
+	h := fnv.New64a()
+	h.Write([]byte("go-logger:" + name))
+	return &LeaderLock{p: p, key: int64(h.Sum64())}
+}
+
+// Hold : ロックを持っていれば接続が生きているか確かめ、持っていなければ取ろうとする。リーダーなら true
+// 接続が切れていたら（DBの再起動など）ロックは外れているので、false とエラーを返す
+func (l *LeaderLock) Hold(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err != nil {
+			l.conn.Close()
+			l.conn = nil
+			return false, err
+		}
+		return true, nil
+	}
+	conn, err := l.p.DB().Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&locked); err != nil || !locked {
+		conn.Close()
+		return false, err
+	}
+	l.conn = conn
+	return true, nil
+}
+
+// Release : ロックを外す（停止する時に呼ぶと、ほかのインスタンスがすぐに引き継げる）
+func (l *LeaderLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	_, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	l.conn.Close()
+	l.conn = nil
+	return err
+}
@@ -0,0 +1,668 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"go-logger/internal/faults"
+	"go-logger/internal/model"
+	"go-logger/internal/tracing"
+)
+
+// ==========================================
+// ログの書き込みと読み出し
+// ==========================================
+
+// logColumns : LogEntry に読み込む列（scanLogEntry と順番を揃える）
+const logColumns = `id, COALESCE(uid, ''), project_id, COALESCE(user_agent, ''), COALESCE(host(ip), ''), COALESCE(country, ''),
+	COALESCE(path, ''), COALESCE(referrer, ''), event_type,
+	COALESCE(level, ''), COALESCE(message, ''), fields, COALESCE(browser, ''), COALESCE(os, ''), COALESCE(device, ''),
+	is_bot, created_at, expires_at, hit_count, last_hit_at, COALESCE(visitor_id, ''), tags, COALESCE(note, ''), sample_rate, deleted_at,
+	COALESCE(status, 0), duration_ms, headers`
+
+// rowScanner : *sql.Row と *sql.Rows の共通部分
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanLogEntry : logColumns の1行を LogEntry に変換する
+// logColumns の後ろに追加で選択した列があれば extra に読み込む
+func scanLogEntry(row rowScanner, extra ...any) (model.LogEntry, error) {
+	var l model.LogEntry
+	var fields, headers []byte
+	dest := []any{&l.ID, &l.UID, &l.ProjectID, &l.UserAgent, &l.IP, &l.Country, &l.Path, &l.Referrer, &l.EventType,
+		&l.Level, &l.Message, &fields, &l.Browser, &l.OS, &l.Device, &l.IsBot, &l.CreatedAt, &l.ExpiresAt, &l.HitCount, &l.LastHitAt, &l.VisitorID, (*pq.StringArray)(&l.Tags), &l.Note, &l.SampleRate, &l.DeletedAt,
+		&l.Status, &l.DurationMS, &headers}
+	err := row.Scan(append(dest, extra...)...)
+	if len(fields) > 0 {
+		l.Fields = fields
+	}
+	if len(headers) > 0 && err == nil {
+		err = json.Unmarshal(headers, &l.Headers)
+	}
+	return l, err
+}
+
+// insertLogSQL : アクセス記録1件のINSERT（引数は insertLogArgs）
+const insertLogSQL = `INSERT INTO access_logs (project_id, user_agent, ip, path, referrer, event_type, level, message, fields,
+			created_at, browser, os, device, is_bot, country, uid, expires_at, visitor_id, sample_rate, tags,
+			status, duration_ms, headers)
+		VALUES ($1, $2, NULLIF($3, '')::inet, $4, NULLIF($5, ''), $6, NULLIF($7, ''), NULLIF($8, ''), $9,
+			$10, NULLIF($11, ''), NULLIF($12, ''), NULLIF($13, ''), $14, NULLIF($15, ''), NULLIF($16, ''), $17, NULLIF($18, ''), $19, $20,
+			NULLIF($21, 0), $22, $23)
+		RETURNING id`
+
+// insertLogArgs : insertLogSQL の引数
+func insertLogArgs(w *model.Write) []any {
+	return []any{w.ProjectID, w.UserAgent, w.IP, w.Path, w.Referrer, w.EventType,
+		w.Level, w.Message, jsonParam(w.Fields),
+		w.CreatedAt, w.Browser, w.OS, w.Device, w.IsBot, w.Country, w.UID, nullableTime(w.ExpiresAt), w.VisitorID, w.Rate(),
+		pq.StringArray(append([]string{}, w.Tags...)), w.Status, w.DurationMS, headersParam(w.Headers)}
+}
+
+// insertAccessLog : アクセス記録を1件INSERTし、採番されたIDを w に書き戻す（通常の書き込み・バッファの書き戻し共通）
+func (p *Postgres) insertAccessLog(ctx context.Context, w *model.Write) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	ctx, span := tracing.StartDBSpan(ctx, "INSERT", "access_logs", insertLogSQL)
+	err := p.DB().QueryRowContext(ctx, insertLogSQL, insertLogArgs(w)...).Scan(&w.ID)
+	tracing.EndSpan(span, err)
+	if err == nil && p.OnInsert != nil {
+		p.OnInsert(w.Entry())
+	}
+	return err
+}
+
+// insertAccessLogs : 複数の記録を1つのトランザクションでINSERTする（1件でも失敗したら全て保存しない）
+func (p *Postgres) insertAccessLogs(ctx context.Context, ws []*model.Write) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	ctx, span := tracing.StartDBSpan(ctx, "INSERT", "access_logs", insertLogSQL)
+	err := p.insertAccessLogsTx(ctx, ws)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		for _, w := range ws {
+			w.ID = 0 // ロールバックした分の採番は無効
+		}
+		return err
+	}
+	if p.OnInsert != nil {
+		for _, w := range ws {
+			p.OnInsert(w.Entry())
+		}
+	}
+	return nil
+}
+
+// insertAccessLogsTx : insertAccessLogs の本体
+func (p *Postgres) insertAccessLogsTx(ctx context.Context, ws []*model.Write) error {
+	tx, err := p.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, insertLogSQL)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, w := range ws {
+		if err := stmt.QueryRowContext(ctx, insertLogArgs(w)...).Scan(&w.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// QueryLogs : 条件に合うログを新しい順に返す（既定50件）
+func (p *Postgres) QueryLogs(ctx context.Context, f LogFilter) ([]model.LogEntry, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	if err := faults.DB(ctx); err != nil {
+		return nil, err
+	}
+	b := p.logFilter(f)
+	selectSQL := "SELECT " + logColumns + " FROM access_logs" + b.where() + " ORDER BY " + f.orderBy() + " LIMIT " + b.arg(limitOr(f.Limit, 50))
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+	rows, err := p.ReadDB().QueryContext(ctx, selectSQL, b.args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []model.LogEntry{}
+	for rows.Next() {
+		l, err := scanLogEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+// searchHeadlineOptions : ts_headline の設定（<mark> で囲んで短い抜粋にする）
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2"
+
+// SearchLogs : f.Search を websearch 形式（"語句"、OR、-除外）で検索し、一致度の高い順に返す
+func (p *Postgres) SearchLogs(ctx context.Context, f LogFilter) ([]model.SearchResult, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	q := f.Search
+	f.Search = ""
+	b := p.logFilter(f)
+	query := b.arg(q)
+	b.add("search_vector @@ websearch_to_tsquery('simple', " + query + ")")
+	selectSQL := "SELECT " + logColumns + ",\n" +
+		"	ts_rank(search_vector, websearch_to_tsquery('simple', " + query + ")) AS rank,\n" +
+		"	ts_headline('simple', concat_ws(' | ', message, path, user_agent), websearch_to_tsquery('simple', " + query + "), '" + searchHeadlineOptions + "')\n" +
+		"FROM access_logs" + b.where() + " ORDER BY rank DESC, id DESC LIMIT " + b.arg(limitOr(f.Limit, 50))
+
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+	rows, err := p.ReadDB().QueryContext(ctx, selectSQL, b.args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []model.SearchResult{}
+	for rows.Next() {
+		var res model.SearchResult
+		l, err := scanLogEntry(rows, &res.Rank, &res.Highlight)
+		if err != nil {
+			return nil, err
+		}
+		res.LogEntry = l
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+// CountLogs : 条件に一致する件数
+func (p *Postgres) CountLogs(ctx context.Context, f LogFilter) (int, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	b := p.logFilter(f)
+	selectSQL := "SELECT " + countExpr(f, "") + " FROM access_logs" + b.where()
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+	var n int
+	err := p.ReadDB().QueryRowContext(ctx, selectSQL, b.args...).Scan(&n)
+	tracing.EndSpan(span, err)
+	return n, err
+}
+
+// CountDistinct : 条件に一致するログの column (GroupByColumns の名前) の値の種類の数
+func (p *Postgres) CountDistinct(ctx context.Context, f LogFilter, column string) (int, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	expr, ok := GroupByColumns[column]
+	if !ok {
+		return 0, fmt.Errorf("unknown column %v", column)
+	}
+	b := p.logFilter(f)
+	selectSQL := "SELECT COUNT(DISTINCT " + expr + ") FROM access_logs" + b.where()
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+	var n int
+	err := p.ReadDB().QueryRowContext(ctx, selectSQL, b.args...).Scan(&n)
+	tracing.EndSpan(span, err)
+	return n, err
+}
+
+// EstimateCount : 件数（distinct を指定すればその列の値の種類の数）の見積もり
+// 数えずに、プランナーの見積もり（reltuples と列の統計）を EXPLAIN で読むので、件数が多くても期間によらずすぐ返る
+// ANALYZE の後に増えた分はずれるので、exact は常に false
+func (p *Postgres) EstimateCount(ctx context.Context, f LogFilter, distinct string) (int, bool, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	target := "1"
+	if distinct != "" {
+		expr, ok := GroupByColumns[distinct]
+		if !ok {
+			return 0, false, fmt.Errorf("unknown column %v", distinct)
+		}
+		target = "DISTINCT " + expr
+	}
+	b := p.logFilter(f)
+	explainSQL := "EXPLAIN (FORMAT JSON) SELECT " + target + " FROM access_logs" + b.where()
+	ctx, span := tracing.StartDBSpan(ctx, "EXPLAIN", "access_logs", explainSQL)
+	var raw []byte
+	err := p.ReadDB().QueryRowContext(ctx, explainSQL, b.args...).Scan(&raw)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return 0, false, err
+	}
+	var plans []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &plans); err != nil || len(plans) == 0 {
+		return 0, false, fmt.Errorf("unexpected EXPLAIN output: %v", err)
+	}
+	return int(math.Round(plans[0].Plan.Rows)), false, nil
+}
+
+// GroupLogs : groupBy の列ごとの件数（件数の多い順、既定20件）
+// 長い期間の種別・レベル・国・ブラウザは集計済みの件数と残りの生のログを合わせて数える
+func (p *Postgres) GroupLogs(ctx context.Context, f LogFilter, groupBy string) ([]model.Bucket, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	column, ok := GroupByColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown groupBy %v", groupBy)
+	}
+	limit := limitOr(f.Limit, 20)
+	b := p.logFilter(f)
+	var rolled map[string]int
+	if rollupColumn, ok := rollupColumns[groupBy]; ok {
+		if c, ok := p.coverage(ctx, f, time.Time{}); ok {
+			rolled = map[string]int{}
+			if err := p.rollupCounts(ctx, f, c, rollupColumn, true, func(rows *sql.Rows) error {
+				var key string
+				var n int
+				err := rows.Scan(&key, &n)
+				rolled[key] += n
+				return err
+			}); err != nil {
+				return nil, err
+			}
+			c.exclude(b)
+		}
+	}
+
+	selectSQL := "SELECT " + column + " AS key, " + countExpr(f, "") + " AS n FROM access_logs" + b.where() + " GROUP BY key"
+	if rolled == nil {
+		selectSQL += " ORDER BY n DESC, key LIMIT " + b.arg(limit)
+	}
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+	rows, err := p.ReadDB().QueryContext(ctx, selectSQL, b.args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []model.Bucket{}
+	for rows.Next() {
+		var bucket model.Bucket
+		if err := rows.Scan(&bucket.Key, &bucket.Count); err != nil {
+			return nil, err
+		}
+		if rolled != nil {
+			rolled[bucket.Key] += bucket.Count
+			continue
+		}
+		buckets = append(buckets, bucket)
+	}
+	if err := rows.Err(); err != nil || rolled == nil {
+		return buckets, err
+	}
+	for key, n := range rolled {
+		buckets = append(buckets, model.Bucket{Key: key, Count: n})
+	}
+	slices.SortFunc(buckets, func(a, b model.Bucket) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return buckets[:min(limit, len(buckets))], nil
+}
+
+// Stats : 種別×レベルごとの件数を1回のクエリで集計する（recentSince 以降の件数も数える）
+// 長い期間は recentSince より前の分を集計済みの件数から数える
+func (p *Postgres) Stats(ctx context.Context, f LogFilter, recentSince time.Time) (*model.Stats, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	stats := &model.Stats{ByType: map[string]int{}, ByLevel: map[string]int{}}
+	b := p.logFilter(f)
+	if c, ok := p.coverage(ctx, f, recentSince); ok {
+		if err := p.rollupCounts(ctx, f, c, "event_type, level", true, func(rows *sql.Rows) error {
+			var eventType, level string
+			var n int
+			if err := rows.Scan(&eventType, &level, &n); err != nil {
+				return err
+			}
+			stats.Total += n
+			stats.ByType[eventType] += n
+			if level != "" {
+				stats.ByLevel[level] += n
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		c.exclude(b)
+	}
+	recent := b.arg(recentSince)
+	selectSQL := "SELECT event_type, COALESCE(level, ''), " + countExpr(f, "") + ", " + countExpr(f, "created_at >= "+recent) +
+		" FROM access_logs" + b.where() + " GROUP BY 1, 2"
+
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+	rows, err := p.ReadDB().QueryContext(ctx, selectSQL, b.args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var eventType, level string
+		var total, n int
+		if err := rows.Scan(&eventType, &level, &total, &n); err != nil {
+			return nil, err
+		}
+		stats.Total += total
+		stats.Last24h += n
+		stats.ByType[eventType] += total
+		if level != "" {
+			stats.ByLevel[level] += total
+		}
+	}
+	return stats, rows.Err()
+}
+
+// HourlyCounts : 時間（UTCの毎時0分）ごとの件数（件数0の時間は含まない）
+// 長い期間は集計済みの時間ごとの件数と残りの生のログを合わせて数える
+func (p *Postgres) HourlyCounts(ctx context.Context, f LogFilter) (map[time.Time]int, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	counts := map[time.Time]int{}
+	b := p.logFilter(f)
+	if c, ok := p.coverage(ctx, f, time.Time{}); ok {
+		if err := p.rollupCounts(ctx, f, c, "bucket", false, func(rows *sql.Rows) error {
+			var hour time.Time
+			var n int
+			err := rows.Scan(&hour, &n)
+			counts[hour.UTC()] += n
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		c.exclude(b)
+	}
+	selectSQL := "SELECT date_trunc('hour', created_at) AS hour, " + countExpr(f, "") + " FROM access_logs" + b.where() + " GROUP BY hour"
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+	rows, err := p.ReadDB().QueryContext(ctx, selectSQL, b.args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hour time.Time
+		var n int
+		if err := rows.Scan(&hour, &n); err != nil {
+			return nil, err
+		}
+		counts[hour.UTC()] += n
+	}
+	return counts, rows.Err()
+}
+
+// countExpr : 件数を数える式（filter があればその行だけ）
+// f.Extrapolate なら抽出率で割り戻す（SAMPLE_RATE=0.1 で保存した1行は10件と数える）
+func countExpr(f LogFilter, filter string) string {
+	if filter != "" {
+		filter = " FILTER (WHERE " + filter + ")"
+	}
+	if f.Extrapolate {
+		return "COALESCE(ROUND(SUM(1 / sample_rate)" + filter + "), 0)::bigint"
+	}
+	return "COUNT(*)" + filter
+}
+
+// longTermCondition : ロールアップなど長期の集計に含める行（有効期限付きのログは含めない）
+const longTermCondition = "expires_at IS NULL"
+
+// retentionBatchSize : 1回のDELETEで消す最大件数（長いロックを避ける）
+const retentionBatchSize = 5000
+
+// PurgeExpired : 有効期限 (now) を過ぎたログと保存期間 (policy) を過ぎたログを少しずつ削除する
+// policy の境目がゼロなら期限付きのログだけ削除する
+// どの境目よりも前に終わる月はパーティションごと削除し、残りを DELETE する
+func (p *Postgres) PurgeExpired(ctx context.Context, now time.Time, policy RetentionPolicy) (int64, error) {
+	total, err := p.DropPartitionsBefore(ctx, policy.PartitionCutoff())
+	if err != nil {
+		return total, err
+	}
+
+	cond, args := expiredCondition(now, policy)
+	deleteSQL := fmt.Sprintf(
+		"DELETE FROM access_logs WHERE id IN (SELECT id FROM access_logs WHERE %s LIMIT %d)", cond, retentionBatchSize)
+
+	for {
+		opCtx, cancel := p.opContext(ctx)
+		opCtx, span := tracing.StartDBSpan(opCtx, "DELETE", "access_logs", deleteSQL)
+		res, err := p.DB().ExecContext(opCtx, deleteSQL, args...)
+		tracing.EndSpan(span, err)
+		cancel()
+		if err != nil {
+			return total, err
+		}
+		n, _ := res.RowsAffected()
+		total += n
+		if n < retentionBatchSize || ctx.Err() != nil {
+			return total, nil
+		}
+	}
+}
+
+// expiredCondition : PurgeExpired で削除する行の条件
+// プロジェクト・種別ごとの境目は CASE で先にあるものから当てる（境目がゼロなら削除しない）
+func expiredCondition(now time.Time, policy RetentionPolicy) (string, []any) {
+	var b whereBuilder
+	cond := "expires_at <= " + b.arg(now)
+	older := func(t time.Time) string {
+		if t.IsZero() {
+			return "FALSE"
+		}
+		return "created_at < " + b.arg(t)
+	}
+	if len(policy.Tiers) == 0 {
+		if !policy.OlderThan.IsZero() {
+			cond += " OR " + older(policy.OlderThan)
+		}
+		return cond, b.args
+	}
+	var cases strings.Builder
+	for _, t := range policy.Tiers {
+		var when whereBuilder
+		when.args = b.args
+		if t.ProjectID != 0 {
+			when.add("project_id = ?", t.ProjectID)
+		}
+		if t.EventType != "" {
+			when.addEventType(t.EventType)
+		}
+		if len(when.conds) == 0 {
+			when.add("TRUE")
+		}
+		b.args = when.args
+		fmt.Fprintf(&cases, " WHEN %s THEN %s", strings.Join(when.conds, " AND "), older(t.OlderThan))
+	}
+	return fmt.Sprintf("%s OR (CASE%s ELSE %s END)", cond, cases.String(), older(policy.OlderThan)), b.args
+}
+
+// ExpiredLogs : PurgeExpired で削除される行を古いIDから limit 件読む（削除の前にアーカイブするため）
+func (p *Postgres) ExpiredLogs(ctx context.Context, now time.Time, policy RetentionPolicy, limit int) ([]model.LogEntry, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	cond, args := expiredCondition(now, policy)
+	query := fmt.Sprintf("SELECT %s FROM access_logs WHERE %s ORDER BY id LIMIT %d", logColumns, cond, limit)
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", query)
+	rows, err := p.DB().QueryContext(ctx, query, args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.LogEntry{}
+	for rows.Next() {
+		l, err := scanLogEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, l)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteLogs : 指定したIDのログを削除する
+func (p *Postgres) DeleteLogs(ctx context.Context, ids []int) (int64, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	const deleteSQL = "DELETE FROM access_logs WHERE id = ANY($1)"
+	ctx, span := tracing.StartDBSpan(ctx, "DELETE", "access_logs", deleteSQL)
+	res, err := p.DB().ExecContext(ctx, deleteSQL, pq.Array(ids))
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// LogByID : id のログ（ゴミ箱にあるものも含む。なければ ErrNotFound）
+func (p *Postgres) LogByID(ctx context.Context, id int) (model.LogEntry, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	if err := faults.DB(ctx); err != nil {
+		return model.LogEntry{}, err
+	}
+	// 書き込んだ直後の通知のリンクから開かれるので、レプリカの遅れを受けないよう primary から読む
+	selectSQL := "SELECT " + logColumns + " FROM access_logs WHERE id = $1"
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+	l, err := scanLogEntry(p.DB().QueryRowContext(ctx, selectSQL, id))
+	tracing.EndSpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return l, ErrNotFound
+	}
+	return l, err
+}
+
+// TrashLog : ログをゴミ箱に移し（deleted_at を入れる）、移した後のログを返す（ないか、もうゴミ箱にあれば ErrNotFound）
+func (p *Postgres) TrashLog(ctx context.Context, id int, at time.Time) (model.LogEntry, error) {
+	return p.setDeletedAt(ctx, "UPDATE access_logs SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL RETURNING "+logColumns, id, at)
+}
+
+// RestoreLog : ゴミ箱のログを戻し、戻した後のログを返す（ゴミ箱になければ ErrNotFound）
+func (p *Postgres) RestoreLog(ctx context.Context, id int) (model.LogEntry, error) {
+	return p.setDeletedAt(ctx, "UPDATE access_logs SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING "+logColumns, id)
+}
+
+// setDeletedAt : TrashLog / RestoreLog の UPDATE を実行する
+func (p *Postgres) setDeletedAt(ctx context.Context, updateSQL string, args ...any) (model.LogEntry, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	ctx, span := tracing.StartDBSpan(ctx, "UPDATE", "access_logs", updateSQL)
+	l, err := scanLogEntry(p.DB().QueryRowContext(ctx, updateSQL, args...))
+	tracing.EndSpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return l, ErrNotFound
+	}
+	return l, err
+}
+
+// PurgeTrash : before より前にゴミ箱に移したログを少しずつ削除する
+func (p *Postgres) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	deleteSQL := fmt.Sprintf(
+		"DELETE FROM access_logs WHERE id IN (SELECT id FROM access_logs WHERE deleted_at < $1 LIMIT %d)", retentionBatchSize)
+	var total int64
+	for {
+		opCtx, cancel := p.opContext(ctx)
+		opCtx, span := tracing.StartDBSpan(opCtx, "DELETE", "access_logs", deleteSQL)
+		res, err := p.DB().ExecContext(opCtx, deleteSQL, before)
+		tracing.EndSpan(span, err)
+		cancel()
+		if err != nil {
+			return total, err
+		}
+		n, _ := res.RowsAffected()
+		total += n
+		if n < retentionBatchSize || ctx.Err() != nil {
+			return total, nil
+		}
+	}
+}
+
+// IncrementHits : まとめたアクセスの回数を1増やし、増やした後の回数を返す（なければ ErrNotFound）
+// パーティションを絞れるよう、保存した時の created_at も渡す
+func (p *Postgres) IncrementHits(ctx context.Context, id int, createdAt, at time.Time) (int, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	const updateSQL = "UPDATE access_logs SET hit_count = hit_count + 1, last_hit_at = $1 WHERE id = $2 AND created_at = $3 RETURNING hit_count"
+	ctx, span := tracing.StartDBSpan(ctx, "UPDATE", "access_logs", updateSQL)
+	var hits int
+	err := p.DB().QueryRowContext(ctx, updateSQL, at, id, createdAt).Scan(&hits)
+	tracing.EndSpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return hits, err
+}
+
+// LogAnnotation : AnnotateLog で変える項目（nil・空なら変えない）
+type LogAnnotation struct {
+	Tags       *[]string // タグを全て置き換える
+	AddTags    []string
+	RemoveTags []string
+	Note       *string // 空文字ならメモを消す
+}
+
+// apply : 読み出したログのタグとメモを変える（AnnotateLog の UPDATE と同じ結果。SQL で変えない保存先用）
+func (a LogAnnotation) apply(l *model.LogEntry) {
+	tags := l.Tags
+	if a.Tags != nil {
+		tags = *a.Tags
+	}
+	tags = append(slices.Clone(tags), a.AddTags...)
+	tags = slices.DeleteFunc(tags, func(t string) bool { return slices.Contains(a.RemoveTags, t) })
+	slices.Sort(tags)
+	l.Tags = slices.Compact(tags)
+	if a.Note != nil {
+		l.Note = *a.Note
+	}
+}
+
+// AnnotateLog : ログのタグとメモを変え、変えた後のログを返す（なければ ErrNotFound）
+// タグは重複を除いて名前順にする
+func (p *Postgres) AnnotateLog(ctx context.Context, id int, a LogAnnotation) (model.LogEntry, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	var replace any
+	if a.Tags != nil {
+		replace = pq.StringArray(append([]string{}, *a.Tags...))
+	}
+	var note any
+	if a.Note != nil {
+		note = *a.Note
+	}
+	updateSQL := `UPDATE access_logs SET
+		tags = ARRAY(SELECT DISTINCT t FROM unnest(COALESCE($2::text[], tags) || $3::text[]) AS t WHERE t <> ALL($4::text[]) ORDER BY t),
+		note = CASE WHEN $5::text IS NULL THEN note ELSE NULLIF($5::text, '') END
+		WHERE id = $1 RETURNING ` + logColumns
+	ctx, span := tracing.StartDBSpan(ctx, "UPDATE", "access_logs", updateSQL)
+	l, err := scanLogEntry(p.DB().QueryRowContext(ctx, updateSQL,
+		id, replace, pq.StringArray(append([]string{}, a.AddTags...)), pq.StringArray(append([]string{}, a.RemoveTags...)), note))
+	tracing.EndSpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return l, ErrNotFound
+	}
+	return l, err
+}
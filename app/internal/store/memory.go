@@ -0,0 +1,784 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// メモリ上の保存先 (デモ・CIの結合テスト・フロントエンドの開発用)
+// ==========================================
+// STORE_BACKEND=memory で Postgres なしに起動する。再起動すると全て消える
+// ログは新しい方から maxLogs 件だけ持ち、超えたら古いものから捨てる（プロジェクトや設定は捨てない）
+// 全文検索は語の一致だけを見る（Postgres の語幹・一致度の計算は真似しない）
+
+// DefaultMemoryMaxLogs : メモリに持つログの件数の既定値
+const DefaultMemoryMaxLogs = 100000
+
+// Memory : 全てをメモリに持つ Store
+type Memory struct {
+	// OnInsert : Postgres.OnInsert と同じ（保存したログをサブスクリプションへ流す）
+	OnInsert func(e *model.LogEntry)
+
+	clock   clock.Clock
+	maxLogs int
+
+	mu      sync.RWMutex
+	logs    []model.LogEntry // ID の古い順
+	lastIDs map[string]int   // テーブルごとの最後に採番したID
+
+	projects     []model.Project
+	keyUsage     []model.KeyUsage
+	settings     map[string]model.Setting
+	links        []model.ShortLink
+	channels     []model.Channel
+	webhooks     []model.Webhook
+	alerts       []model.Alert
+	mutes        map[string]time.Time
+	alertRules   []model.AlertRule
+	exclusions   []model.Exclusion
+	watches      []model.Watch
+	notifyRoutes []model.NotifyRoute
+	ipRules      []model.IPRule
+	apiKeys      []model.APIKey
+	userRoles    map[string]model.UserRoles
+	deadLetters  []model.DeadLetter
+	exportJobs   []model.ExportJob
+	idempotency  map[string]memIdempotencyKey
+	notifyKeys   map[string]time.Time // 通知の印 → 期限
+	privacy      []model.PrivacyRequest
+	checks       []model.CheckResult
+}
+
+var _ Store = (*Memory)(nil)
+
+// NewMemory : デフォルトプロジェクトだけがある空の保存先（maxLogs が0以下なら DefaultMemoryMaxLogs 件）
+func NewMemory(clk clock.Clock, maxLogs int) *Memory {
+	if maxLogs <= 0 {
+		maxLogs = DefaultMemoryMaxLogs
+	}
+	m := &Memory{
+		clock:       clk,
+		maxLogs:     maxLogs,
+		lastIDs:     map[string]int{},
+		mutes:       map[string]time.Time{},
+		idempotency: map[string]memIdempotencyKey{},
+		notifyKeys:  map[string]time.Time{},
+		userRoles:   map[string]model.UserRoles{},
+	}
+	m.projects = []model.Project{{ID: m.nextID("projects"), Name: "default", APIKey: memRandomKey(), CreatedAt: clk.Now()}}
+	return m
+}
+
+// nextID : テーブルの次のID（mu を持って呼ぶ）
+func (m *Memory) nextID(table string) int {
+	m.lastIDs[table]++
+	return m.lastIDs[table]
+}
+
+// memRandomKey : デフォルトプロジェクトのキー（マイグレーションの md5(random()) と同じく、使う前に差し替える前提）
+func memRandomKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ==========================================
+// ログの書き込み
+// ==========================================
+
+// SaveLog : 書き込みを保存する（採番されたIDは w に書き戻される。バッファに退避することはない）
+func (m *Memory) SaveLog(ctx context.Context, w *model.Write) (SaveResult, error) {
+	_, n, err := m.SaveLogs(ctx, []*model.Write{w})
+	if err == nil && n == 0 {
+		return 0, errors.New("nothing saved")
+	}
+	return Stored, err
+}
+
+// SaveLogs : 複数の書き込みを保存する（maxLogs を超えた分は古いログから捨てる）
+func (m *Memory) SaveLogs(ctx context.Context, ws []*model.Write) (SaveResult, int, error) {
+	m.mu.Lock()
+	entries := make([]*model.LogEntry, len(ws))
+	for i, w := range ws {
+		w.ID = m.nextID("access_logs")
+		entries[i] = w.Entry()
+		m.logs = append(m.logs, *entries[i])
+	}
+	if extra := len(m.logs) - m.maxLogs; extra > 0 {
+		m.logs = slices.Delete(m.logs, 0, extra)
+	}
+	m.mu.Unlock()
+
+	if m.OnInsert != nil {
+		for _, e := range entries {
+			m.OnInsert(e)
+		}
+	}
+	return Stored, len(ws), nil
+}
+
+// ==========================================
+// ログの読み出し
+// ==========================================
+
+// memLog : 返すログ（タグを共有しないよう複製する）
+func memLog(l model.LogEntry) model.LogEntry {
+	l.Tags = slices.Clone(l.Tags)
+	l.Headers = maps.Clone(l.Headers)
+	return l
+}
+
+// matches : LogFilter の条件に合うか（logFilter の WHERE と同じ）
+func (f LogFilter) matches(l *model.LogEntry) bool {
+	switch {
+	case l.ProjectID != f.ProjectID,
+		f.Trash != (l.DeletedAt != nil),
+		!model.EventTypeMatches(f.EventType, l.EventType),
+		f.UID != "" && l.UID != f.UID,
+		f.MinLevel != "" && !slices.Contains(model.LevelsAtLeast(f.MinLevel), l.Level),
+		!f.Since.IsZero() && l.CreatedAt.Before(f.Since),
+		!f.Until.IsZero() && !l.CreatedAt.Before(f.Until),
+		!f.inPage(l),
+		f.Query != nil && !f.Query.Match(l),
+		f.Search != "" && memSearchScore(f.Search, l) == 0:
+		return false
+	}
+	for name, value := range f.Fields {
+		if v, ok := entryField(l, name); !ok || v != value {
+			return false
+		}
+	}
+	for _, tag := range f.Tags {
+		if !slices.Contains(l.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// inPage : ページの位置 (BeforeID / AfterID / CursorAt) より先か（logFilter と同じ）
+func (f LogFilter) inPage(l *model.LogEntry) bool {
+	key := func(id int) int {
+		if f.byTimeCursor() {
+			if c := l.CreatedAt.Compare(f.CursorAt); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(l.ID, id)
+	}
+	return (f.BeforeID <= 0 || key(f.BeforeID) < 0) && (f.AfterID <= 0 || key(f.AfterID) > 0)
+}
+
+// sortLogs : orderBy と同じ順に並べる
+func sortLogs(logs []model.LogEntry, f LogFilter) {
+	slices.SortStableFunc(logs, func(a, b model.LogEntry) int {
+		c := cmp.Compare(a.ID, b.ID)
+		if f.Sort == SortCreatedAt {
+			if t := a.CreatedAt.Compare(b.CreatedAt); t != 0 {
+				c = t
+			}
+		}
+		if !f.Ascending {
+			c = -c
+		}
+		return c
+	})
+}
+
+// weight : 件数としての重み（countExpr と同じく、f.Extrapolate なら抽出率で割り戻す）
+func (f LogFilter) weight(l *model.LogEntry) float64 {
+	if f.Extrapolate && l.SampleRate > 0 {
+		return 1 / l.SampleRate
+	}
+	return 1
+}
+
+// eachLog : 条件に合うログを新しい順に fn に渡す（fn が false を返したら止める。mu.RLock を持って呼ぶ）
+func (m *Memory) eachLog(f LogFilter, fn func(l *model.LogEntry) bool) {
+	for i := len(m.logs) - 1; i >= 0; i-- {
+		if f.matches(&m.logs[i]) && !fn(&m.logs[i]) {
+			return
+		}
+	}
+}
+
+// QueryLogs : 条件に合うログを新しい順（f.Sort / f.Ascending の順）に返す（既定50件）
+func (m *Memory) QueryLogs(ctx context.Context, f LogFilter) ([]model.LogEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	limit := limitOr(f.Limit, 50)
+	logs := []model.LogEntry{}
+	if f.Ascending || f.Sort == SortCreatedAt {
+		// 保存した順（id の順）以外は全て集めて並べ替える
+		m.eachLog(f, func(l *model.LogEntry) bool {
+			logs = append(logs, memLog(*l))
+			return true
+		})
+		sortLogs(logs, f)
+		return logs[:min(limit, len(logs))], nil
+	}
+	m.eachLog(f, func(l *model.LogEntry) bool {
+		logs = append(logs, memLog(*l))
+		return len(logs) < limit
+	})
+	return logs, nil
+}
+
+// memWords : 検索用に小文字の語に分け、" 語 語 " の形にする（to_tsvector('simple', ...) の代わり）
+func memWords(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return " " + strings.Join(words, " ") + " "
+}
+
+// memSearchText : 検索の対象（search_vector と同じ列）
+func memSearchText(l *model.LogEntry) string {
+	return l.Message + " | " + l.Path + " | " + l.UserAgent
+}
+
+// memSearchScore : websearch 形式（"語句"、OR、-除外）で一致すれば、一致した語の数を返す（一致しなければ0）
+func memSearchScore(search string, l *model.LogEntry) int {
+	text := memWords(memSearchText(l))
+	score := 0
+	terms := searchTerms(search)
+	for i := 0; i < len(terms); i++ {
+		// "a OR b" は a と b のどちらか
+		group := []string{terms[i]}
+		for i+2 < len(terms) && strings.EqualFold(terms[i+1], "or") {
+			group = append(group, terms[i+2])
+			i += 2
+		}
+		matched := false
+		for _, term := range group {
+			word, negate := strings.CutPrefix(term, "-")
+			if memWords(word) == "  " {
+				continue
+			}
+			if strings.Contains(text, memWords(word)) != negate {
+				matched = true
+				if !negate {
+					score++
+				}
+			}
+		}
+		if !matched {
+			return 0
+		}
+	}
+	return max(score, 1)
+}
+
+// memHighlight : 一致した語を <mark> で囲んだ本文（ts_headline の代わり。抜粋にはしない）
+func memHighlight(search string, l *model.LogEntry) string {
+	text := strings.Join(slices.DeleteFunc([]string{l.Message, l.Path, l.UserAgent}, func(s string) bool { return s == "" }), " | ")
+	for _, term := range searchTerms(search) {
+		if strings.HasPrefix(term, "-") || strings.EqualFold(term, "or") {
+			continue
+		}
+		lower := strings.ToLower(text)
+		if i := strings.Index(lower, strings.ToLower(term)); i >= 0 && len(lower) == len(text) {
+			text = text[:i] + "<mark>" + text[i:i+len(term)] + "</mark>" + text[i+len(term):]
+		}
+	}
+	return text
+}
+
+// SearchLogs : f.Search を websearch 形式で検索し、一致した語の多い順に返す
+func (m *Memory) SearchLogs(ctx context.Context, f LogFilter) ([]model.SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	results := []model.SearchResult{}
+	m.eachLog(f, func(l *model.LogEntry) bool {
+		results = append(results, model.SearchResult{
+			LogEntry:  memLog(*l),
+			Rank:      float64(memSearchScore(f.Search, l)),
+			Highlight: memHighlight(f.Search, l),
+		})
+		return true
+	})
+	slices.SortStableFunc(results, func(a, b model.SearchResult) int {
+		if a.Rank != b.Rank {
+			return int(b.Rank - a.Rank)
+		}
+		return b.ID - a.ID
+	})
+	return results[:min(limitOr(f.Limit, 50), len(results))], nil
+}
+
+// count : 条件に合う件数を key ごとに数える（key が nil なら全体）
+func (m *Memory) count(f LogFilter, key func(l *model.LogEntry) string) map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sums := map[string]float64{}
+	m.eachLog(f, func(l *model.LogEntry) bool {
+		k := ""
+		if key != nil {
+			k = key(l)
+		}
+		sums[k] += f.weight(l)
+		return true
+	})
+	counts := make(map[string]int, len(sums))
+	for k, n := range sums {
+		counts[k] = int(math.Round(n))
+	}
+	return counts
+}
+
+// CountLogs : 条件に一致する件数
+func (m *Memory) CountLogs(ctx context.Context, f LogFilter) (int, error) {
+	return m.count(f, nil)[""], nil
+}
+
+// CountDistinct : 条件に一致するログの column (GroupByColumns の名前) の値の種類の数
+func (m *Memory) CountDistinct(ctx context.Context, f LogFilter, column string) (int, error) {
+	key, ok := memGroupByColumns[column]
+	if !ok {
+		return 0, fmt.Errorf("unknown column %v", column)
+	}
+	return len(m.count(f, key)), nil
+}
+
+// EstimateCount : メモリでは数えても速いので、正確な件数を返す
+func (m *Memory) EstimateCount(ctx context.Context, f LogFilter, distinct string) (int, bool, error) {
+	if distinct != "" {
+		n, err := m.CountDistinct(ctx, f, distinct)
+		return n, true, err
+	}
+	n, err := m.CountLogs(ctx, f)
+	return n, true, err
+}
+
+// memReferrerDomain : 参照元のホスト名（先頭の www. を除く）
+func memReferrerDomain(l *model.LogEntry) string {
+	u, err := url.Parse(l.Referrer)
+	if err != nil || u.Scheme == "" {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// memGroupByColumns : GroupByColumns のメモリ版
+var memGroupByColumns = map[string]func(l *model.LogEntry) string{
+	"EVENT_TYPE":      func(l *model.LogEntry) string { return l.EventType },
+	"LEVEL":           func(l *model.LogEntry) string { return l.Level },
+	"COUNTRY":         func(l *model.LogEntry) string { return l.Country },
+	"PATH":            func(l *model.LogEntry) string { return l.Path },
+	"USER_AGENT":      func(l *model.LogEntry) string { return l.UserAgent },
+	"IP":              func(l *model.LogEntry) string { return l.IP },
+	"BROWSER":         func(l *model.LogEntry) string { return l.Browser },
+	"OS":              func(l *model.LogEntry) string { return l.OS },
+	"DEVICE":          func(l *model.LogEntry) string { return l.Device },
+	"REFERRER_DOMAIN": memReferrerDomain,
+	"REFERRER": func(l *model.LogEntry) string {
+		page, _, _ := strings.Cut(l.Referrer, "?")
+		page, _, _ = strings.Cut(page, "#")
+		return page
+	},
+}
+
+// GroupLogs : groupBy の列ごとの件数（件数の多い順、既定20件）
+func (m *Memory) GroupLogs(ctx context.Context, f LogFilter, groupBy string) ([]model.Bucket, error) {
+	key, ok := memGroupByColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown groupBy %v", groupBy)
+	}
+	buckets := []model.Bucket{}
+	for k, n := range m.count(f, key) {
+		buckets = append(buckets, model.Bucket{Key: k, Count: n})
+	}
+	slices.SortFunc(buckets, func(a, b model.Bucket) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return buckets[:min(limitOr(f.Limit, 20), len(buckets))], nil
+}
+
+// Stats : 種別・レベルごとの件数と、recentSince 以降の件数
+func (m *Memory) Stats(ctx context.Context, f LogFilter, recentSince time.Time) (*model.Stats, error) {
+	stats := &model.Stats{ByType: map[string]int{}, ByLevel: map[string]int{}}
+	for eventType, n := range m.count(f, func(l *model.LogEntry) string { return l.EventType }) {
+		stats.ByType[eventType] = n
+		stats.Total += n
+	}
+	for level, n := range m.count(f, func(l *model.LogEntry) string { return l.Level }) {
+		if level != "" {
+			stats.ByLevel[level] = n
+		}
+	}
+	recent := f
+	if recent.Since.Before(recentSince) {
+		recent.Since = recentSince
+	}
+	stats.Last24h = m.count(recent, nil)[""]
+	return stats, nil
+}
+
+// HourlyCounts : 時間（UTCの毎時0分）ごとの件数（件数0の時間は含まない）
+func (m *Memory) HourlyCounts(ctx context.Context, f LogFilter) (map[time.Time]int, error) {
+	counts := map[time.Time]int{}
+	for k, n := range m.count(f, func(l *model.LogEntry) string { return l.CreatedAt.UTC().Truncate(time.Hour).Format(time.RFC3339) }) {
+		hour, _ := time.Parse(time.RFC3339, k)
+		counts[hour] = n
+	}
+	return counts, nil
+}
+
+// Timeseries : f.Since から f.Until までを interval ごとに区切った件数（件数0の区間も埋める）
+func (m *Memory) Timeseries(ctx context.Context, f LogFilter, interval time.Duration, origin time.Time) ([]model.TimePoint, error) {
+	if f.Since.IsZero() || f.Until.IsZero() || interval < time.Second {
+		return nil, errors.New("timeseries needs since, until and an interval of at least 1s")
+	}
+	step := int64(interval / time.Second)
+	slot := func(t time.Time) int64 {
+		return int64(math.Floor(float64(t.Unix()-origin.Unix()) / float64(step)))
+	}
+	first, last := slot(f.Since), slot(f.Until.Add(-time.Nanosecond))
+
+	counts := m.count(f, func(l *model.LogEntry) string { return fmt.Sprint(slot(l.CreatedAt)) })
+	points := make([]model.TimePoint, 0, last-first+1)
+	for s := first; s <= last; s++ {
+		points = append(points, model.TimePoint{Start: origin.Add(time.Duration(s*step) * time.Second), Count: counts[fmt.Sprint(s)]})
+	}
+	return points, nil
+}
+
+// UniqueVisitors : 期間内の訪問者を数える（見積もらずに数える）
+func (m *Memory) UniqueVisitors(ctx context.Context, f LogFilter) (*model.UniqueVisitors, error) {
+	visitors := map[string]bool{}
+	m.mu.RLock()
+	m.eachLog(f, func(l *model.LogEntry) bool {
+		if l.IsBot || (l.VisitorID == "" && l.IP == "") {
+			return true
+		}
+		if l.VisitorID != "" {
+			visitors["v:"+l.VisitorID] = true
+		} else {
+			visitors[l.IP+" "+l.UserAgent] = true
+		}
+		return true
+	})
+	m.mu.RUnlock()
+	return &model.UniqueVisitors{Visitors: int64(len(visitors)), Method: model.UniquesExact}, nil
+}
+
+// SessionStats : Postgres.SessionStats と同じ数え方を Go で行う
+func (m *Memory) SessionStats(ctx context.Context, f LogFilter, gap time.Duration) (*model.SessionStats, error) {
+	type hit struct {
+		createdAt, lastAt time.Time
+		hits              int
+	}
+	visits := map[string][]hit{}
+	visitorIDs := map[string]bool{}
+
+	m.mu.RLock()
+	m.eachLog(f, func(l *model.LogEntry) bool {
+		if l.IsBot || (l.VisitorID == "" && l.IP == "") {
+			return true
+		}
+		key := l.IP + " " + l.UserAgent
+		if l.VisitorID != "" {
+			key = "v:" + l.VisitorID
+			visitorIDs[l.VisitorID] = true
+		}
+		last := l.CreatedAt
+		if l.LastHitAt != nil {
+			last = *l.LastHitAt
+		}
+		visits[key] = append(visits[key], hit{l.CreatedAt, last, l.HitCount})
+		return true
+	})
+	returning := 0
+	if !f.Since.IsZero() {
+		before := map[string]bool{}
+		for i := range m.logs {
+			if l := &m.logs[i]; l.ProjectID == f.ProjectID && visitorIDs[l.VisitorID] && l.CreatedAt.Before(f.Since) {
+				before[l.VisitorID] = true
+			}
+		}
+		returning = len(before)
+	}
+	m.mu.RUnlock()
+
+	s := &model.SessionStats{GapSeconds: int(gap.Seconds()), Visitors: len(visits), CookieVisitors: len(visitorIDs), ReturningVisitors: returning}
+	var durations []float64
+	for _, hits := range visits {
+		slices.SortFunc(hits, func(a, b hit) int { return a.createdAt.Compare(b.createdAt) })
+		for i := 0; i < len(hits); {
+			start, end, n := hits[i].createdAt, hits[i].lastAt, hits[i].hits
+			// 前のアクセス（まとめた最後のアクセス）から gap 以内なら同じセッション
+			for i++; i < len(hits) && hits[i].createdAt.Sub(hits[i-1].lastAt) <= gap; i++ {
+				end = maxTime(end, hits[i].lastAt)
+				n += hits[i].hits
+			}
+			durations = append(durations, end.Sub(start).Seconds())
+			if n == 1 {
+				s.Bounces++
+			}
+		}
+	}
+	s.Sessions = len(durations)
+	if len(durations) > 0 {
+		slices.Sort(durations)
+		var sum float64
+		for _, d := range durations {
+			sum += d
+		}
+		s.AvgDurationSeconds = sum / float64(len(durations))
+		// percentile_cont(0.5) と同じく、偶数件なら真ん中の2つの平均
+		mid := len(durations) / 2
+		s.MedianDurationSeconds = durations[mid]
+		if len(durations)%2 == 0 {
+			s.MedianDurationSeconds = (durations[mid-1] + durations[mid]) / 2
+		}
+	}
+	return s, nil
+}
+
+// LatencyStats : Postgres.LatencyStats と同じ数え方を Go で行う
+func (m *Memory) LatencyStats(ctx context.Context, f LogFilter) (*model.LatencyStats, error) {
+	l := &model.LatencyStats{ByClass: map[string]int{}}
+	var durations []float64
+	m.mu.RLock()
+	m.eachLog(f, func(e *model.LogEntry) bool {
+		if e.Status != 0 {
+			l.Add(e.Status, 1)
+		}
+		if e.DurationMS != nil {
+			durations = append(durations, *e.DurationMS)
+		}
+		return true
+	})
+	m.mu.RUnlock()
+	slices.Sort(durations)
+	l.Timed = len(durations)
+	l.P50MS, l.P95MS = percentileCont(durations, 0.5), percentileCont(durations, 0.95)
+	l.Finish()
+	return l, nil
+}
+
+// percentileCont : 並べ替え済みの値の percentile_cont(q)（間の値は線形に補う。空なら0）
+func percentileCont(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	if lo+1 >= len(sorted) {
+		return sorted[lo]
+	}
+	return sorted[lo] + (sorted[lo+1]-sorted[lo])*(pos-float64(lo))
+}
+
+// maxTime : 遅い方の時刻
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// ==========================================
+// ログの削除・変更
+// ==========================================
+
+// deleteLogs : 条件に合うログを削除し、件数を返す
+func (m *Memory) deleteLogs(del func(l *model.LogEntry) bool) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before := len(m.logs)
+	m.logs = slices.DeleteFunc(m.logs, func(l model.LogEntry) bool { return del(&l) })
+	return int64(before - len(m.logs))
+}
+
+// expired : PurgeExpired で削除するログか（expiredCondition と同じ）
+func expired(l *model.LogEntry, now time.Time, policy RetentionPolicy) bool {
+	olderThan := policy.olderThan(l.ProjectID, l.EventType)
+	return (l.ExpiresAt != nil && !l.ExpiresAt.After(now)) || (!olderThan.IsZero() && l.CreatedAt.Before(olderThan))
+}
+
+// PurgeExpired : 有効期限 (now) を過ぎたログと保存期間 (policy) を過ぎたログを削除する
+func (m *Memory) PurgeExpired(ctx context.Context, now time.Time, policy RetentionPolicy) (int64, error) {
+	return m.deleteLogs(func(l *model.LogEntry) bool { return expired(l, now, policy) }), nil
+}
+
+// ExpiredLogs : PurgeExpired で削除される行を古いIDから limit 件読む
+func (m *Memory) ExpiredLogs(ctx context.Context, now time.Time, policy RetentionPolicy, limit int) ([]model.LogEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	logs := []model.LogEntry{}
+	for i := 0; i < len(m.logs) && len(logs) < limit; i++ {
+		if expired(&m.logs[i], now, policy) {
+			logs = append(logs, memLog(m.logs[i]))
+		}
+	}
+	return logs, nil
+}
+
+// DeleteLogs : 指定したIDのログを削除する
+func (m *Memory) DeleteLogs(ctx context.Context, ids []int) (int64, error) {
+	return m.deleteLogs(func(l *model.LogEntry) bool { return slices.Contains(ids, l.ID) }), nil
+}
+
+// logByID : ID のログ（なければ nil。mu を持って呼ぶ）
+func (m *Memory) logByID(id int) *model.LogEntry {
+	i, ok := slices.BinarySearchFunc(m.logs, id, func(l model.LogEntry, id int) int { return l.ID - id })
+	if !ok {
+		return nil
+	}
+	return &m.logs[i]
+}
+
+// IncrementHits : まとめたアクセスの回数を1増やし、増やした後の回数を返す（なければ ErrNotFound）
+func (m *Memory) IncrementHits(ctx context.Context, id int, createdAt, at time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l := m.logByID(id)
+	if l == nil || !l.CreatedAt.Equal(createdAt) {
+		return 0, ErrNotFound
+	}
+	l.HitCount++
+	l.LastHitAt = &at
+	return l.HitCount, nil
+}
+
+// LogByID : id のログ（ゴミ箱にあるものも含む。なければ ErrNotFound）
+func (m *Memory) LogByID(ctx context.Context, id int) (model.LogEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	l := m.logByID(id)
+	if l == nil {
+		return model.LogEntry{}, ErrNotFound
+	}
+	return memLog(*l), nil
+}
+
+// AnnotateLog : ログのタグとメモを変え、変えた後のログを返す（なければ ErrNotFound）
+func (m *Memory) AnnotateLog(ctx context.Context, id int, a LogAnnotation) (model.LogEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l := m.logByID(id)
+	if l == nil {
+		return model.LogEntry{}, ErrNotFound
+	}
+	a.apply(l)
+	return memLog(*l), nil
+}
+
+// TrashLog : ログをゴミ箱に移し、移した後のログを返す（ないか、もうゴミ箱にあれば ErrNotFound）
+func (m *Memory) TrashLog(ctx context.Context, id int, at time.Time) (model.LogEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l := m.logByID(id)
+	if l == nil || l.DeletedAt != nil {
+		return model.LogEntry{}, ErrNotFound
+	}
+	l.DeletedAt = &at
+	return memLog(*l), nil
+}
+
+// RestoreLog : ゴミ箱のログを戻し、戻した後のログを返す（ゴミ箱になければ ErrNotFound）
+func (m *Memory) RestoreLog(ctx context.Context, id int) (model.LogEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l := m.logByID(id)
+	if l == nil || l.DeletedAt == nil {
+		return model.LogEntry{}, ErrNotFound
+	}
+	l.DeletedAt = nil
+	return memLog(*l), nil
+}
+
+// PurgeTrash : before より前にゴミ箱に移したログを削除する
+func (m *Memory) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	return m.deleteLogs(func(l *model.LogEntry) bool { return l.DeletedAt != nil && l.DeletedAt.Before(before) }), nil
+}
+
+// EnsurePartitions : パーティションはない
+func (m *Memory) EnsurePartitions(ctx context.Context, now time.Time, monthsAhead int) ([]string, error) {
+	return nil, nil
+}
+
+// DropPartitionsBefore : パーティションはない（古いログは PurgeExpired で消える）
+func (m *Memory) DropPartitionsBefore(ctx context.Context, olderThan time.Time) (int64, error) {
+	return 0, nil
+}
+
+// RollupWatermark : 集計済みの件数は作らないので、いつもゼロ
+func (m *Memory) RollupWatermark(ctx context.Context) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+// OldestLogTime : いちばん古いログの時刻（ログがなければゼロ）
+func (m *Memory) OldestLogTime(ctx context.Context) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var oldest time.Time
+	for _, l := range m.logs {
+		if oldest.IsZero() || l.CreatedAt.Before(oldest) {
+			oldest = l.CreatedAt
+		}
+	}
+	return oldest.UTC(), nil
+}
+
+// RollUp : 生のログから直接集計するので何もしない
+func (m *Memory) RollUp(ctx context.Context, from, to time.Time) error {
+	return nil
+}
+
+// ==========================================
+// 本人からの開示・削除の依頼
+// ==========================================
+
+// matches : Subject.where と同じ条件
+func (s Subject) matches(l *model.LogEntry) bool {
+	if s.ProjectID != 0 && l.ProjectID != s.ProjectID {
+		return false
+	}
+	if s.IP != "" {
+		a, errA := netip.ParseAddr(s.IP)
+		b, errB := netip.ParseAddr(l.IP)
+		return errA == nil && errB == nil && a.Unmap() == b.Unmap()
+	}
+	return l.VisitorID == s.VisitorID
+}
+
+// SubjectLogs : 本人のログを全て古い順に返す
+func (m *Memory) SubjectLogs(ctx context.Context, sub Subject) ([]model.LogEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := []model.LogEntry{}
+	for i := range m.logs {
+		if sub.matches(&m.logs[i]) {
+			entries = append(entries, memLog(m.logs[i]))
+		}
+	}
+	return entries, nil
+}
+
+// DeleteSubjectLogs : 本人のログを削除し、記録を残す（削除した行数は audit.Rows に入る）
+func (m *Memory) DeleteSubjectLogs(ctx context.Context, sub Subject, audit *model.PrivacyRequest) error {
+	audit.Rows = m.deleteLogs(sub.matches)
+	return m.InsertPrivacyRequest(ctx, audit)
+}
@@ -0,0 +1,1126 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// メモリ上の保存先: プロジェクト・設定・履歴
+// ==========================================
+// 各テーブルは ID の古い順のスライスで持つ（件数が少ないので毎回先頭から探す）
+
+// memFind : id の行の位置（なければ -1）
+func memFind[T any](rows []T, id int, idOf func(*T) int) int {
+	return slices.IndexFunc(rows, func(r T) bool { return idOf(&r) == id })
+}
+
+// memDelete : id の行を削除する（なければ ErrNotFound）
+func memDelete[T any](rows *[]T, id int, idOf func(*T) int) error {
+	i := memFind(*rows, id, idOf)
+	if i < 0 {
+		return ErrNotFound
+	}
+	*rows = slices.Delete(*rows, i, i+1)
+	return nil
+}
+
+// ==========================================
+// プロジェクト
+// ==========================================
+
+func projectID(p *model.Project) int { return p.ID }
+
+// ProjectIDByKey : APIキーからプロジェクトIDを引く（なければ ErrNotFound）
+func (m *Memory) ProjectIDByKey(ctx context.Context, key string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, p := range m.projects {
+		if p.APIKey == key {
+			return p.ID, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+// ListProjects : プロジェクト一覧（キーは含めない）
+func (m *Memory) ListProjects(ctx context.Context) ([]model.Project, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	projects := slices.Clone(m.projects)
+	for i := range projects {
+		projects[i].APIKey = ""
+	}
+	return projects, nil
+}
+
+// ProjectKeys : プロジェクトID → APIキー（設定のエクスポート用）
+func (m *Memory) ProjectKeys(ctx context.Context) (map[int]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := map[int]string{}
+	for _, p := range m.projects {
+		keys[p.ID] = p.APIKey
+	}
+	return keys, nil
+}
+
+// CreateProject : 発行済みのキーでプロジェクトを作る（名前とキーは重複できない）
+func (m *Memory) CreateProject(ctx context.Context, name, apiKey string) (model.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.projects {
+		if p.Name == name || p.APIKey == apiKey {
+			return model.Project{}, fmt.Errorf("project %q already exists", name)
+		}
+	}
+	pr := model.Project{ID: m.nextID("projects"), Name: name, APIKey: apiKey, CreatedAt: m.clock.Now()}
+	m.projects = append(m.projects, pr)
+	return pr, nil
+}
+
+// RotateProjectKey : キーを差し替える（なければ ErrNotFound）
+func (m *Memory) RotateProjectKey(ctx context.Context, id int, apiKey string) (model.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := memFind(m.projects, id, projectID)
+	if i < 0 {
+		return model.Project{}, ErrNotFound
+	}
+	m.projects[i].APIKey = apiKey
+	pr := m.projects[i]
+	pr.Privacy = model.ProjectPrivacy{}
+	pr.Quota = model.ProjectQuota{}
+	return pr, nil
+}
+
+// UpdateProjectPrivacy : プライバシー設定を置き換える（なければ ErrNotFound）
+func (m *Memory) UpdateProjectPrivacy(ctx context.Context, id int, privacy model.ProjectPrivacy) (model.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := memFind(m.projects, id, projectID)
+	if i < 0 {
+		return model.Project{}, ErrNotFound
+	}
+	m.projects[i].Privacy = privacy
+	pr := m.projects[i]
+	pr.APIKey = ""
+	return pr, nil
+}
+
+// UpdateProjectQuota : キーの上限を置き換える（なければ ErrNotFound）
+func (m *Memory) UpdateProjectQuota(ctx context.Context, id int, quota model.ProjectQuota) (model.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := memFind(m.projects, id, projectID)
+	if i < 0 {
+		return model.Project{}, ErrNotFound
+	}
+	m.projects[i].Quota = quota
+	pr := m.projects[i]
+	pr.APIKey = ""
+	return pr, nil
+}
+
+// AddKeyUsage : 日ごとのリクエスト数を足し込む
+func (m *Memory) AddKeyUsage(ctx context.Context, usage []model.KeyUsage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range usage {
+		u.Day = usageDay(u.Day)
+		i := slices.IndexFunc(m.keyUsage, func(k model.KeyUsage) bool { return k.ProjectID == u.ProjectID && k.Day.Equal(u.Day) })
+		if i < 0 {
+			m.keyUsage = append(m.keyUsage, u)
+			continue
+		}
+		m.keyUsage[i].Requests += u.Requests
+		m.keyUsage[i].Rejected += u.Rejected
+	}
+	return nil
+}
+
+// KeyUsage : since（UTC の日付）以降の日ごとのリクエスト数（古い順）
+func (m *Memory) KeyUsage(ctx context.Context, projectID int, since time.Time) ([]model.KeyUsage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	since = usageDay(since)
+	usage := []model.KeyUsage{}
+	for _, u := range m.keyUsage {
+		if u.ProjectID == projectID && !u.Day.Before(since) {
+			usage = append(usage, u)
+		}
+	}
+	slices.SortFunc(usage, func(a, b model.KeyUsage) int { return a.Day.Compare(b.Day) })
+	return usage, nil
+}
+
+// usageDay : UTC の日付（0時）
+func usageDay(t time.Time) time.Time {
+	y, mo, d := t.UTC().Date()
+	return time.Date(y, mo, d, 0, 0, 0, 0, time.UTC)
+}
+
+// ==========================================
+// 短縮リンク
+// ==========================================
+
+// LinkBySlug : slug のリンクを返す（なければ ErrNotFound）
+func (m *Memory) LinkBySlug(ctx context.Context, slug string) (model.ShortLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := slices.IndexFunc(m.links, func(l model.ShortLink) bool { return l.Slug == slug })
+	if i < 0 {
+		return model.ShortLink{Slug: slug}, ErrNotFound
+	}
+	return m.links[i], nil
+}
+
+// ListLinks : リンク一覧とクリック数
+func (m *Memory) ListLinks(ctx context.Context) ([]model.ShortLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	links := slices.Clone(m.links)
+	if links == nil {
+		links = []model.ShortLink{}
+	}
+	for i := range links {
+		for _, l := range m.logs {
+			if l.ProjectID == links[i].ProjectID && l.EventType == LinkClickEventType && l.Path == "/l/"+links[i].Slug {
+				links[i].Clicks++
+			}
+		}
+	}
+	return links, nil
+}
+
+// CreateLink : リンクを作り、ID と作成日時を l に書き戻す（slug は重複できない）
+func (m *Memory) CreateLink(ctx context.Context, l *model.ShortLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if slices.ContainsFunc(m.links, func(x model.ShortLink) bool { return x.Slug == l.Slug }) {
+		return fmt.Errorf("link %q already exists", l.Slug)
+	}
+	l.ID, l.CreatedAt = m.nextID("short_links"), m.clock.Now()
+	m.links = append(m.links, *l)
+	return nil
+}
+
+// DeleteLink : リンクを削除する（なければ ErrNotFound）
+func (m *Memory) DeleteLink(ctx context.Context, slug string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before := len(m.links)
+	m.links = slices.DeleteFunc(m.links, func(l model.ShortLink) bool { return l.Slug == slug })
+	if len(m.links) == before {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ==========================================
+// 通知先
+// ==========================================
+
+func channelID(c *model.Channel) int { return c.ID }
+
+// ListChannels : 通知先一覧（停止中のものも含む）
+func (m *Memory) ListChannels(ctx context.Context) ([]model.Channel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]model.Channel{}, m.channels...), nil
+}
+
+// ChannelByID : 通知先を返す（なければ ErrNotFound）
+func (m *Memory) ChannelByID(ctx context.Context, id int) (model.Channel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if i := memFind(m.channels, id, channelID); i >= 0 {
+		return m.channels[i], nil
+	}
+	return model.Channel{}, ErrNotFound
+}
+
+// CreateChannel : 通知先を作り、ID と作成日時を c に書き戻す
+func (m *Memory) CreateChannel(ctx context.Context, c *model.Channel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now()
+	c.ID, c.CreatedAt, c.UpdatedAt = m.nextID("notification_channels"), now, now
+	m.channels = append(m.channels, *c)
+	return nil
+}
+
+// UpdateChannel : c.ID の通知先を c の内容で上書きする（なければ ErrNotFound）
+func (m *Memory) UpdateChannel(ctx context.Context, c *model.Channel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := memFind(m.channels, c.ID, channelID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	c.CreatedAt, c.UpdatedAt = m.channels[i].CreatedAt, m.clock.Now()
+	m.channels[i] = *c
+	return nil
+}
+
+// DeleteChannel : 通知先を削除する（なければ ErrNotFound）
+func (m *Memory) DeleteChannel(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return memDelete(&m.channels, id, channelID)
+}
+
+// ==========================================
+// Webhook
+// ==========================================
+
+func webhookID(wh *model.Webhook) int { return wh.ID }
+
+// memWebhook : 返す Webhook（種別を共有しないよう複製する）
+func memWebhook(wh model.Webhook) model.Webhook {
+	wh.EventTypes = slices.Clone(wh.EventTypes)
+	return wh
+}
+
+// ListWebhooks : Webhook の一覧（停止中のものも含む）
+func (m *Memory) ListWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	webhooks := []model.Webhook{}
+	for _, wh := range m.webhooks {
+		webhooks = append(webhooks, memWebhook(wh))
+	}
+	return webhooks, nil
+}
+
+// WebhookByID : Webhook を返す（なければ ErrNotFound）
+func (m *Memory) WebhookByID(ctx context.Context, id int) (model.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if i := memFind(m.webhooks, id, webhookID); i >= 0 {
+		return memWebhook(m.webhooks[i]), nil
+	}
+	return model.Webhook{}, ErrNotFound
+}
+
+// CreateWebhook : Webhook を作り、ID と作成日時を wh に書き戻す
+func (m *Memory) CreateWebhook(ctx context.Context, wh *model.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now()
+	wh.ID, wh.CreatedAt, wh.UpdatedAt = m.nextID("webhooks"), now, now
+	m.webhooks = append(m.webhooks, memWebhook(*wh))
+	return nil
+}
+
+// UpdateWebhook : wh.ID の Webhook を wh の内容で上書きする（なければ ErrNotFound）
+// 有効にした時は失敗の回数を0に戻す
+func (m *Memory) UpdateWebhook(ctx context.Context, wh *model.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := memFind(m.webhooks, wh.ID, webhookID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	old := m.webhooks[i]
+	wh.Failures, wh.LastError, wh.LastDeliveryAt, wh.DisabledAt = old.Failures, old.LastError, old.LastDeliveryAt, old.DisabledAt
+	if wh.Enabled && !old.Enabled {
+		wh.Failures = 0
+	}
+	if wh.Enabled {
+		wh.DisabledAt = nil
+	}
+	wh.CreatedAt, wh.UpdatedAt = old.CreatedAt, m.clock.Now()
+	m.webhooks[i] = memWebhook(*wh)
+	return nil
+}
+
+// DeleteWebhook : Webhook を削除する（なければ ErrNotFound）
+func (m *Memory) DeleteWebhook(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return memDelete(&m.webhooks, id, webhookID)
+}
+
+// RecordWebhookDelivery : 送った結果を記録する（届けば失敗の回数を0に戻す）
+// 続けて maxFailures 回失敗したら止め、止めた時だけ true を返す
+func (m *Memory) RecordWebhookDelivery(ctx context.Context, id int, deliveryErr error, at time.Time, maxFailures int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := memFind(m.webhooks, id, webhookID)
+	if i < 0 {
+		if deliveryErr == nil {
+			return false, nil
+		}
+		return false, ErrNotFound
+	}
+	wh := &m.webhooks[i]
+	if deliveryErr == nil {
+		wh.Failures, wh.LastError, wh.LastDeliveryAt = 0, "", &at
+		return false, nil
+	}
+	wh.Failures++
+	wh.LastError = deliveryErr.Error()
+	if wh.Enabled && wh.Failures >= maxFailures {
+		wh.Enabled, wh.DisabledAt = false, &at
+		return true, nil
+	}
+	return false, nil
+}
+
+// ==========================================
+// アラートの履歴とミュート
+// ==========================================
+
+func alertID(a *model.Alert) int { return a.ID }
+
+// InsertAlert : アラートを記録し、ID を a に書き戻す
+func (m *Memory) InsertAlert(ctx context.Context, a *model.Alert) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a.ID = m.nextID("alerts")
+	m.alerts = append(m.alerts, *a)
+	return nil
+}
+
+// ListAlerts : since 以降のアラートを新しい順に返す（最新100件）
+func (m *Memory) ListAlerts(ctx context.Context, since time.Time, limit int) ([]model.Alert, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	alerts := []model.Alert{}
+	for _, a := range m.alerts {
+		if !a.FiredAt.Before(since) {
+			alerts = append(alerts, a)
+		}
+	}
+	slices.SortFunc(alerts, func(a, b model.Alert) int {
+		if c := b.FiredAt.Compare(a.FiredAt); c != 0 {
+			return c
+		}
+		return b.ID - a.ID
+	})
+	return alerts[:min(limitOr(limit, 100), len(alerts))], nil
+}
+
+// AlertByID : アラートを返す（なければ ErrNotFound）
+func (m *Memory) AlertByID(ctx context.Context, id int) (model.Alert, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if i := memFind(m.alerts, id, alertID); i >= 0 {
+		return m.alerts[i], nil
+	}
+	return model.Alert{}, ErrNotFound
+}
+
+// AcknowledgeAlert : アラートを確認済みにする（確認済みなら最初の確認を残す。なければ ErrNotFound）
+func (m *Memory) AcknowledgeAlert(ctx context.Context, id int, by string, at time.Time) (model.Alert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := memFind(m.alerts, id, alertID)
+	if i < 0 {
+		return model.Alert{}, ErrNotFound
+	}
+	if a := &m.alerts[i]; a.AcknowledgedAt == nil {
+		a.AcknowledgedAt, a.AcknowledgedBy = &at, by
+	}
+	return m.alerts[i], nil
+}
+
+// MuteAlertKey : until まで key のアラートを通知しない（既にミュート中なら期限を上書きする）
+func (m *Memory) MuteAlertKey(ctx context.Context, key string, until time.Time, by string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mutes[key] = until
+	return nil
+}
+
+// UnmuteAlertKey : key のミュートを解除する（ミュートしていなければ何もしない）
+func (m *Memory) UnmuteAlertKey(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.mutes, key)
+	return nil
+}
+
+// ActiveMutes : now の時点でミュート中のキーと期限（期限切れのものはついでに消す）
+func (m *Memory) ActiveMutes(ctx context.Context, now time.Time) (map[string]time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mutes := map[string]time.Time{}
+	for key, until := range m.mutes {
+		if !until.After(now) {
+			delete(m.mutes, key)
+			continue
+		}
+		mutes[key] = until
+	}
+	return mutes, nil
+}
+
+// ==========================================
+// アラートルール
+// ==========================================
+
+func alertRuleID(r *model.AlertRule) int { return r.ID }
+
+// ListAlertRules : ルール一覧（停止中のものも含む）
+func (m *Memory) ListAlertRules(ctx context.Context) ([]model.AlertRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]model.AlertRule{}, m.alertRules...), nil
+}
+
+// AlertRuleByID : ルールを返す（なければ ErrNotFound）
+func (m *Memory) AlertRuleByID(ctx context.Context, id int) (model.AlertRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if i := memFind(m.alertRules, id, alertRuleID); i >= 0 {
+		return m.alertRules[i], nil
+	}
+	return model.AlertRule{}, ErrNotFound
+}
+
+// CreateAlertRule : ルールを作り、ID と作成日時を r に書き戻す
+func (m *Memory) CreateAlertRule(ctx context.Context, r *model.AlertRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r.ID, r.CreatedAt, r.LastFiredAt = m.nextID("alert_rules"), m.clock.Now(), nil
+	m.alertRules = append(m.alertRules, *r)
+	return nil
+}
+
+// UpdateAlertRule : r.ID のルールを r の内容で上書きする（最後に通知した日時と作成日時は残す。なければ ErrNotFound）
+func (m *Memory) UpdateAlertRule(ctx context.Context, r *model.AlertRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := memFind(m.alertRules, r.ID, alertRuleID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	old := m.alertRules[i]
+	updated := *r
+	updated.LastFiredAt, updated.CreatedAt = old.LastFiredAt, old.CreatedAt
+	m.alertRules[i] = updated
+	return nil
+}
+
+// DeleteAlertRule : ルールを削除する（なければ ErrNotFound）
+func (m *Memory) DeleteAlertRule(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return memDelete(&m.alertRules, id, alertRuleID)
+}
+
+// MarkAlertRuleFired : 最後に通知した日時を記録する
+func (m *Memory) MarkAlertRuleFired(ctx context.Context, id int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := memFind(m.alertRules, id, alertRuleID); i >= 0 {
+		m.alertRules[i].LastFiredAt = &at
+	}
+	return nil
+}
+
+// ==========================================
+// 除外パターン・監視リスト・通知のルーティング・IPアドレスの許可・拒否リスト
+// ==========================================
+
+func exclusionID(e *model.Exclusion) int     { return e.ID }
+func notifyRouteID(r *model.NotifyRoute) int { return r.ID }
+func ipRuleID(r *model.IPRule) int           { return r.ID }
+
+// ListExclusions : 除外パターンの一覧
+func (m *Memory) ListExclusions(ctx context.Context) ([]model.Exclusion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]model.Exclusion{}, m.exclusions...), nil
+}
+
+// CreateExclusion : 除外パターンを作り、ID と作成日時を e に書き戻す
+func (m *Memory) CreateExclusion(ctx context.Context, e *model.Exclusion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.ID, e.CreatedAt = m.nextID("exclusions"), m.clock.Now()
+	m.exclusions = append(m.exclusions, *e)
+	return nil
+}
+
+// DeleteExclusion : 除外パターンを削除する（なければ ErrNotFound）
+func (m *Memory) DeleteExclusion(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return memDelete(&m.exclusions, id, exclusionID)
+}
+
+func watchID(w *model.Watch) int { return w.ID }
+
+// ListWatches : 監視リストの一覧
+func (m *Memory) ListWatches(ctx context.Context) ([]model.Watch, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]model.Watch{}, m.watches...), nil
+}
+
+// CreateWatch : 監視リストに足し、ID と作成日時を w に書き戻す
+func (m *Memory) CreateWatch(ctx context.Context, w *model.Watch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.ID, w.CreatedAt = m.nextID("watchlist"), m.clock.Now()
+	m.watches = append(m.watches, *w)
+	return nil
+}
+
+// DeleteWatch : 監視リストから外す（なければ ErrNotFound）
+func (m *Memory) DeleteWatch(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return memDelete(&m.watches, id, watchID)
+}
+
+// ListSettings : 上書きしている設定の一覧（キーの順）
+func (m *Memory) ListSettings(ctx context.Context) ([]model.Setting, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	settings := []model.Setting{}
+	for _, st := range m.settings {
+		settings = append(settings, st)
+	}
+	slices.SortFunc(settings, func(a, b model.Setting) int { return strings.Compare(a.Key, b.Key) })
+	return settings, nil
+}
+
+// PutSetting : 設定を上書きする
+func (m *Memory) PutSetting(ctx context.Context, key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.settings == nil {
+		m.settings = map[string]model.Setting{}
+	}
+	m.settings[key] = model.Setting{Key: key, Value: value, UpdatedAt: m.clock.Now()}
+	return nil
+}
+
+// DeleteSetting : 上書きをやめて環境変数の値に戻す
+func (m *Memory) DeleteSetting(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.settings, key)
+	return nil
+}
+
+// memNotifyRoute : 返すルーティング（通知先を共有しないよう複製する）
+func memNotifyRoute(r model.NotifyRoute) model.NotifyRoute {
+	r.Channels = slices.Clone(r.Channels)
+	return r
+}
+
+// ListNotifyRoutes : ルーティングの一覧（当てる順。停止中のものも含む）
+func (m *Memory) ListNotifyRoutes(ctx context.Context) ([]model.NotifyRoute, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	routes := []model.NotifyRoute{}
+	for _, r := range m.notifyRoutes {
+		routes = append(routes, memNotifyRoute(r))
+	}
+	slices.SortStableFunc(routes, func(a, b model.NotifyRoute) int { return a.Position - b.Position })
+	return routes, nil
+}
+
+// NotifyRouteByID : ルーティングを返す（なければ ErrNotFound）
+func (m *Memory) NotifyRouteByID(ctx context.Context, id int) (model.NotifyRoute, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if i := memFind(m.notifyRoutes, id, notifyRouteID); i >= 0 {
+		return memNotifyRoute(m.notifyRoutes[i]), nil
+	}
+	return model.NotifyRoute{}, ErrNotFound
+}
+
+// CreateNotifyRoute : ルーティングを作り、ID と作成日時を r に書き戻す
+func (m *Memory) CreateNotifyRoute(ctx context.Context, r *model.NotifyRoute) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now()
+	r.ID, r.CreatedAt, r.UpdatedAt = m.nextID("notify_routes"), now, now
+	m.notifyRoutes = append(m.notifyRoutes, memNotifyRoute(*r))
+	return nil
+}
+
+// UpdateNotifyRoute : r.ID のルーティングを r の内容で上書きする（なければ ErrNotFound）
+func (m *Memory) UpdateNotifyRoute(ctx context.Context, r *model.NotifyRoute) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := memFind(m.notifyRoutes, r.ID, notifyRouteID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.CreatedAt, r.UpdatedAt = m.notifyRoutes[i].CreatedAt, m.clock.Now()
+	m.notifyRoutes[i] = memNotifyRoute(*r)
+	return nil
+}
+
+// DeleteNotifyRoute : ルーティングを削除する（なければ ErrNotFound）
+func (m *Memory) DeleteNotifyRoute(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return memDelete(&m.notifyRoutes, id, notifyRouteID)
+}
+
+// ListIPRules : 許可・拒否ルールの一覧
+func (m *Memory) ListIPRules(ctx context.Context) ([]model.IPRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]model.IPRule{}, m.ipRules...), nil
+}
+
+// CreateIPRule : ルールを作り、ID と作成日時を r に書き戻す
+func (m *Memory) CreateIPRule(ctx context.Context, r *model.IPRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r.ID, r.CreatedAt = m.nextID("ip_rules"), m.clock.Now()
+	m.ipRules = append(m.ipRules, *r)
+	return nil
+}
+
+// DeleteIPRule : ルールを削除する（なければ ErrNotFound）
+func (m *Memory) DeleteIPRule(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return memDelete(&m.ipRules, id, ipRuleID)
+}
+
+// ==========================================
+// ロール
+// ==========================================
+
+func apiKeyID(k *model.APIKey) int { return k.ID }
+
+// ListAPIKeys : ロール付きのAPIキーの一覧
+func (m *Memory) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]model.APIKey{}, m.apiKeys...), nil
+}
+
+// CreateAPIKey : キーを登録し、ID と作成日時を k に書き戻す（同じハッシュは登録できない）
+func (m *Memory) CreateAPIKey(ctx context.Context, k *model.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if slices.ContainsFunc(m.apiKeys, func(e model.APIKey) bool { return e.KeyHash == k.KeyHash }) {
+		return fmt.Errorf("api key already exists")
+	}
+	k.ID, k.CreatedAt = m.nextID("api_keys"), m.clock.Now()
+	stored := *k
+	stored.Key = ""
+	m.apiKeys = append(m.apiKeys, stored)
+	return nil
+}
+
+// DeleteAPIKey : キーを削除する（なければ ErrNotFound）
+func (m *Memory) DeleteAPIKey(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return memDelete(&m.apiKeys, id, apiKeyID)
+}
+
+// ListUserRoles : ロールを付けたユーザーの一覧（名前の順）
+func (m *Memory) ListUserRoles(ctx context.Context) ([]model.UserRoles, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	users := []model.UserRoles{}
+	for _, u := range m.userRoles {
+		users = append(users, u)
+	}
+	slices.SortFunc(users, func(a, b model.UserRoles) int { return strings.Compare(a.User, b.User) })
+	return users, nil
+}
+
+// PutUserRoles : ユーザーのロールを置き換え（なければ作り）、更新日時を u に書き戻す
+func (m *Memory) PutUserRoles(ctx context.Context, u *model.UserRoles) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u.UpdatedAt = m.clock.Now()
+	m.userRoles[u.User] = *u
+	return nil
+}
+
+// DeleteUserRoles : ユーザーのロールを外す（なければ ErrNotFound）
+func (m *Memory) DeleteUserRoles(ctx context.Context, user string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.userRoles[user]; !ok {
+		return ErrNotFound
+	}
+	delete(m.userRoles, user)
+	return nil
+}
+
+// ==========================================
+// 処理できなかったキューの中身と、再接続待ちの書き込み
+// ==========================================
+
+func deadLetterID(d *model.DeadLetter) int { return d.ID }
+
+// ListDeadLetters : 新しい順の一覧
+func (m *Memory) ListDeadLetters(ctx context.Context) ([]model.DeadLetter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	letters := append([]model.DeadLetter{}, m.deadLetters...)
+	slices.Reverse(letters)
+	return letters, nil
+}
+
+// InsertDeadLetter : 保存し、ID と作成日時を d に書き戻す
+func (m *Memory) InsertDeadLetter(ctx context.Context, d *model.DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d.ID, d.CreatedAt = m.nextID("dead_letters"), m.clock.Now()
+	m.deadLetters = append(m.deadLetters, *d)
+	return nil
+}
+
+// DeadLetterByID : 1件取得する（なければ ErrNotFound）
+func (m *Memory) DeadLetterByID(ctx context.Context, id int) (*model.DeadLetter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := memFind(m.deadLetters, id, deadLetterID)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	d := m.deadLetters[i]
+	return &d, nil
+}
+
+// DeleteDeadLetter : 削除する（なければ ErrNotFound）
+func (m *Memory) DeleteDeadLetter(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return memDelete(&m.deadLetters, id, deadLetterID)
+}
+
+// BufferedWrites : 再接続することはないので、いつも0
+func (m *Memory) BufferedWrites(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+// ==========================================
+// 非同期の書き出し
+// ==========================================
+
+func exportJobID(j *model.ExportJob) int { return j.ID }
+
+// CreateExportJob : 待ちの状態で保存し、ID と作成日時を j に書き戻す
+func (m *Memory) CreateExportJob(ctx context.Context, j *model.ExportJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j.ID, j.Status, j.CreatedAt = m.nextID("export_jobs"), model.ExportQueued, m.clock.Now()
+	j.Filters = maps.Clone(j.Filters)
+	m.exportJobs = append(m.exportJobs, *j)
+	return nil
+}
+
+// ExportJobByID : 1件取得する（なければ ErrNotFound）
+func (m *Memory) ExportJobByID(ctx context.Context, id int) (*model.ExportJob, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := memFind(m.exportJobs, id, exportJobID)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	j := m.exportJobs[i]
+	return &j, nil
+}
+
+// ClaimExportJob : いちばん古い待ちの書き出しを instance の処理中にして返す（なければ nil）
+func (m *Memory) ClaimExportJob(ctx context.Context, instance string, now time.Time) (*model.ExportJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.exportJobs {
+		if j := &m.exportJobs[i]; j.Status == model.ExportQueued {
+			j.Status, j.Instance, j.StartedAt = model.ExportRunning, instance, &now
+			claimed := *j
+			return &claimed, nil
+		}
+	}
+	return nil, nil
+}
+
+// FinishExportJob : 書き出しの結果（状態・件数・ファイル・エラー・期限）を保存する
+func (m *Memory) FinishExportJob(ctx context.Context, j *model.ExportJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := memFind(m.exportJobs, j.ID, exportJobID); i >= 0 {
+		m.exportJobs[i] = *j
+	}
+	return nil
+}
+
+// RequeueExportJobs : instance が処理中のまま止まった書き出しを待ちに戻す
+func (m *Memory) RequeueExportJobs(ctx context.Context, instance string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for i := range m.exportJobs {
+		if j := &m.exportJobs[i]; j.Status == model.ExportRunning && j.Instance == instance {
+			j.Status, j.StartedAt = model.ExportQueued, nil
+			n++
+		}
+	}
+	return n, nil
+}
+
+// DeleteExpiredExportJobs : 期限を過ぎた書き出しを削除して返す（呼び出し側がファイルを消す）
+func (m *Memory) DeleteExpiredExportJobs(ctx context.Context, instance string, now time.Time) ([]model.ExportJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var expired []model.ExportJob
+	m.exportJobs = slices.DeleteFunc(m.exportJobs, func(j model.ExportJob) bool {
+		if j.ExpiresAt == nil || j.ExpiresAt.After(now) || (j.Location != "s3" && j.Instance != instance) {
+			return false
+		}
+		expired = append(expired, j)
+		return true
+	})
+	return expired, nil
+}
+
+// ==========================================
+// 書き込みの Idempotency-Key
+// ==========================================
+
+// memIdempotencyKey : キー1つ分
+type memIdempotencyKey struct {
+	resp      IdempotentResponse
+	expiresAt time.Time
+}
+
+// ClaimIdempotencyKey : キーを処理中として押さえる（expiresAt まで）。押さえられたら claimed=true
+// 既に使われたキーなら、保存した応答（処理中なら Status が0）を返す。期限を過ぎたキーは押さえ直せる
+func (m *Memory) ClaimIdempotencyKey(ctx context.Context, key, requestHash string, now, expiresAt time.Time) (IdempotentResponse, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.idempotency[key]; ok && k.expiresAt.After(now) {
+		return k.resp, false, nil
+	}
+	m.idempotency[key] = memIdempotencyKey{resp: IdempotentResponse{RequestHash: requestHash}, expiresAt: expiresAt}
+	return IdempotentResponse{}, true, nil
+}
+
+// CompleteIdempotencyKey : 押さえたキーに応答を保存する（expiresAt まで同じ応答を返す）
+func (m *Memory) CompleteIdempotencyKey(ctx context.Context, key string, resp IdempotentResponse, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.idempotency[key]
+	if !ok {
+		return nil
+	}
+	resp.RequestHash = k.resp.RequestHash
+	m.idempotency[key] = memIdempotencyKey{resp: resp, expiresAt: expiresAt}
+	return nil
+}
+
+// ReleaseIdempotencyKey : 押さえたキーを外す（処理に失敗し、送り直しを受け付ける場合）
+func (m *Memory) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.idempotency[key]; ok && k.resp.Status == 0 {
+		delete(m.idempotency, key)
+	}
+	return nil
+}
+
+// ClaimNotificationKey : key の印がまだない（期限を過ぎた）なら expiresAt まで付けて true を返す
+func (m *Memory) ClaimNotificationKey(ctx context.Context, key, claimedBy string, now, expiresAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if exp, ok := m.notifyKeys[key]; ok && exp.After(now) {
+		return false, nil
+	}
+	m.notifyKeys[key] = expiresAt
+	return true, nil
+}
+
+// PurgeNotificationKeys : 期限を過ぎた印を削除する
+func (m *Memory) PurgeNotificationKeys(ctx context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for key, exp := range m.notifyKeys {
+		if !exp.After(now) {
+			delete(m.notifyKeys, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// PurgeIdempotencyKeys : 期限を過ぎたキーを削除する
+func (m *Memory) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for key, k := range m.idempotency {
+		if !k.expiresAt.After(now) {
+			delete(m.idempotency, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ==========================================
+// 本人からの依頼の記録
+// ==========================================
+
+// InsertPrivacyRequest : 依頼を処理した記録を残し、ID を r に書き戻す
+func (m *Memory) InsertPrivacyRequest(ctx context.Context, r *model.PrivacyRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r.ID = m.nextID("privacy_requests")
+	m.privacy = append(m.privacy, *r)
+	return nil
+}
+
+// ListPrivacyRequests : 依頼の記録を新しい順に返す（subjectHash が空でなければその識別子の分だけ。最新100件）
+func (m *Memory) ListPrivacyRequests(ctx context.Context, subjectHash string, limit int) ([]model.PrivacyRequest, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	requests := []model.PrivacyRequest{}
+	for _, r := range m.privacy {
+		if subjectHash == "" || r.SubjectHash == subjectHash {
+			requests = append(requests, r)
+		}
+	}
+	slices.SortFunc(requests, func(a, b model.PrivacyRequest) int {
+		if c := b.RequestedAt.Compare(a.RequestedAt); c != 0 {
+			return c
+		}
+		return b.ID - a.ID
+	})
+	return requests[:min(limitOr(limit, 100), len(requests))], nil
+}
+
+// ==========================================
+// 稼働監視
+// ==========================================
+
+// InsertCheck : 監視結果を保存し、ID を c に書き戻す
+func (m *Memory) InsertCheck(ctx context.Context, c *model.CheckResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c.ID = m.nextID("uptime_checks")
+	m.checks = append(m.checks, *c)
+	return nil
+}
+
+// latestChecks : 監視対象・リージョンごとの最新の結果（mu を持って呼ぶ）
+func (m *Memory) latestChecks() map[[2]string]model.CheckResult {
+	latest := map[[2]string]model.CheckResult{}
+	for _, c := range m.checks {
+		key := [2]string{c.Check, c.Region}
+		if prev, ok := latest[key]; !ok || !c.CheckedAt.Before(prev.CheckedAt) {
+			latest[key] = c
+		}
+	}
+	return latest
+}
+
+// LastCheckStatus : 同じ監視対象・リージョンの直前の状態（初回なら空）
+func (m *Memory) LastCheckStatus(ctx context.Context, check, region string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.latestChecks()[[2]string{check, region}].Status, nil
+}
+
+// LatestChecks : 監視対象ごとの最新状態
+func (m *Memory) LatestChecks(ctx context.Context) ([]model.CheckResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	checks := []model.CheckResult{}
+	for _, c := range m.latestChecks() {
+		checks = append(checks, c)
+	}
+	slices.SortFunc(checks, func(a, b model.CheckResult) int {
+		if c := strings.Compare(a.Check, b.Check); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Region, b.Region)
+	})
+	return checks, nil
+}
+
+// ==========================================
+// 管理
+// ==========================================
+
+// Snapshot : 全テーブルの全行を w に書き出す（書き出す間は書き込みを止めるので、全テーブルが同じ時点の内容になる）
+func (m *Memory) Snapshot(ctx context.Context, w SnapshotWriter) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := w.Begin(m.clock.Now(), snapshotTables); err != nil {
+		return err
+	}
+	tables := map[string]func(yield func(row any) error) error{
+		"projects":              memRows(m.projects),
+		"short_links":           memRows(m.links),
+		"notification_channels": memRows(m.channels),
+		"alert_rules":           memRows(m.alertRules),
+		"exclusions":            memRows(m.exclusions),
+		"ip_rules":              memRows(m.ipRules),
+		"watchlist":             memRows(m.watches),
+		"dead_letters":          memRows(m.deadLetters),
+		"uptime_checks":         memRows(m.checks),
+		"access_logs":           memRows(m.logs),
+		"alerts":                memRows(m.alerts),
+	}
+	for _, table := range snapshotTables {
+		if err := tables[table](func(row any) error { return w.Row(table, row) }); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// memRows : スライスの各行を yield に渡す関数
+func memRows[T any](rows []T) func(yield func(row any) error) error {
+	return func(yield func(row any) error) error {
+		for _, r := range rows {
+			if err := yield(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// StorageUsage : テーブルごとの行数（容量は数えない）
+func (m *Memory) StorageUsage(ctx context.Context) (model.StorageUsage, error) {
+	s, err := m.SampleVolume(ctx)
+	u := model.StorageUsage{}
+	for _, t := range s.Tables {
+		u.Tables = append(u.Tables, model.TableStorage{Table: t.Table, Rows: t.Rows})
+	}
+	return u, err
+}
+
+// SampleVolume : テーブルごとの行数（容量は数えない）
+func (m *Memory) SampleVolume(ctx context.Context) (model.VolumeSample, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	counts := map[string]int{
+		"projects": len(m.projects), "short_links": len(m.links), "notification_channels": len(m.channels),
+		"alert_rules": len(m.alertRules), "exclusions": len(m.exclusions), "ip_rules": len(m.ipRules), "watchlist": len(m.watches),
+		"dead_letters": len(m.deadLetters), "uptime_checks": len(m.checks), "access_logs": len(m.logs), "alerts": len(m.alerts),
+	}
+	s := model.VolumeSample{SampledAt: m.clock.Now()}
+	for _, table := range snapshotTables {
+		s.Tables = append(s.Tables, model.TableVolume{Table: table, Rows: int64(counts[table])})
+	}
+	return s, nil
+}
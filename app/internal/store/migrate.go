@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// ==========================================
+// スキーママイグレーション (migrations/*.sql を順番に適用)
+// ==========================================
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID : 複数インスタンスが同時に適用しないためのアドバイザリーロックID
+const migrationLockID = 727274
+
+// Migration : 1ファイル分のマイグレーション
+type Migration struct {
+	Version string // ファイル名（"0001_create_access_logs"）
+	SQL     string
+}
+
+// loadMigrations : 埋め込まれたSQLファイルをファイル名順に返す
+func loadMigrations() ([]Migration, error) {
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var migrations []Migration
+	for _, name := range names {
+		body, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		version := strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+		migrations = append(migrations, Migration{Version: version, SQL: string(body)})
+	}
+	return migrations, nil
+}
+
+// appliedMigrations : 適用済みバージョンの一覧
+func appliedMigrations(ctx context.Context, conn *sql.DB) (map[string]bool, error) {
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version TEXT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+// Migrate : 未適用のマイグレーションを適用する
+func (p *Postgres) Migrate(ctx context.Context) error {
+	return runMigrations(ctx, p.DB())
+}
+
+// MigrationStatus : 各マイグレーションの適用状況を表示する
+func (p *Postgres) MigrationStatus(ctx context.Context) error {
+	return printMigrationStatus(ctx, p.DB())
+}
+
+// runMigrations : 未適用のマイグレーションを1つずつトランザクションで適用する
+func runMigrations(ctx context.Context, conn *sql.DB) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return fmt.Errorf("load migrations: %w", err)
+	}
+
+	// セッション単位のロックなので、同じコネクションで取得・解放する
+	lockConn, err := conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer lockConn.Close()
+	if _, err := lockConn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer lockConn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("read schema_migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		fmt.Println("Applying migration:", m.Version)
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s: %w", m.Version, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", m.Version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %s: %w", m.Version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %s: %w", m.Version, err)
+		}
+	}
+	return nil
+}
+
+// printMigrationStatus : 各マイグレーションの適用状況を表示する
+func printMigrationStatus(ctx context.Context, conn *sql.DB) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		mark := "pending"
+		if applied[m.Version] {
+			mark = "applied"
+		}
+		fmt.Printf("%-8s %s\n", mark, m.Version)
+	}
+	return nil
+}
@@ -0,0 +1,6 @@
+-- アクセスログ本体 (既存環境では作成済みのためIF NOT EXISTS)
+CREATE TABLE IF NOT EXISTS access_logs (
+	id SERIAL PRIMARY KEY,
+	user_agent TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- 記録対象パスごとのイベント種別
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS path TEXT;
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS event_type TEXT NOT NULL DEFAULT 'access';
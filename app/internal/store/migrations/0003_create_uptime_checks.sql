@@ -0,0 +1,10 @@
+-- 合成監視の結果
+CREATE TABLE IF NOT EXISTS uptime_checks (
+	id SERIAL PRIMARY KEY,
+	check_name TEXT NOT NULL,
+	status TEXT NOT NULL,
+	latency_ms INTEGER,
+	region TEXT NOT NULL DEFAULT '',
+	checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS uptime_checks_name_region_idx ON uptime_checks (check_name, region, checked_at DESC);
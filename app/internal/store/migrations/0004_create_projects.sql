@@ -0,0 +1,16 @@
+-- サイトごとのプロジェクトとAPIキー
+CREATE TABLE IF NOT EXISTS projects (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	api_key TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 既存のログはすべてデフォルトプロジェクト (id=1) に属する
+INSERT INTO projects (id, name, api_key)
+VALUES (1, 'default', md5(random()::text || clock_timestamp()::text))
+ON CONFLICT DO NOTHING;
+SELECT setval('projects_id_seq', GREATEST((SELECT MAX(id) FROM projects), 1));
+
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS project_id INTEGER NOT NULL DEFAULT 1 REFERENCES projects (id);
+CREATE INDEX IF NOT EXISTS access_logs_project_id_idx ON access_logs (project_id, id DESC);
@@ -0,0 +1,11 @@
+-- 短縮リンク (/l/{slug} → target_url)
+CREATE TABLE IF NOT EXISTS short_links (
+	id SERIAL PRIMARY KEY,
+	project_id INTEGER NOT NULL DEFAULT 1 REFERENCES projects (id),
+	slug TEXT NOT NULL UNIQUE,
+	target_url TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- クリック元を記録するためのリファラー
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS referrer TEXT;
@@ -0,0 +1,4 @@
+-- POST /api/logs で受け取るアプリケーションログ用
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS level TEXT;
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS message TEXT;
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS fields JSONB;
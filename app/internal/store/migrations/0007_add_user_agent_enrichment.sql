@@ -0,0 +1,5 @@
+-- UA解析によるブラウザ・OS・端末種別・ボット判定
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS browser TEXT;
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS os TEXT;
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS device TEXT;
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,4 @@
+-- レベル未設定の既存行（アクセス記録）は info として扱う
+UPDATE access_logs SET level = 'info' WHERE level IS NULL;
+ALTER TABLE access_logs ALTER COLUMN level SET DEFAULT 'info';
+CREATE INDEX IF NOT EXISTS access_logs_level_idx ON access_logs (project_id, level, id DESC);
@@ -0,0 +1,24 @@
+-- JSONBフィールドの生成列用: 変換できない値は NULL にする (生成列にはIMMUTABLEな関数が必要)
+CREATE OR REPLACE FUNCTION logger_try_numeric(v TEXT) RETURNS NUMERIC AS $$
+BEGIN
+	RETURN v::NUMERIC;
+EXCEPTION WHEN others THEN
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION logger_try_bigint(v TEXT) RETURNS BIGINT AS $$
+BEGIN
+	RETURN v::BIGINT;
+EXCEPTION WHEN others THEN
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION logger_try_boolean(v TEXT) RETURNS BOOLEAN AS $$
+BEGIN
+	RETURN v::BOOLEAN;
+EXCEPTION WHEN others THEN
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
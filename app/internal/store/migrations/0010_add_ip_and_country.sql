@@ -0,0 +1,3 @@
+-- 送信元IPとGeoIPによる国コード
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS ip INET;
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS country TEXT;
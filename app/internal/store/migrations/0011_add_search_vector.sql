@@ -0,0 +1,9 @@
+-- 全文検索用の tsvector 生成列と GIN インデックス
+-- UA・パス・メッセージは日本語や記号混じりなので、語幹処理をしない 'simple' 設定にする
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', COALESCE(message, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(path, '')), 'B') ||
+        setweight(to_tsvector('simple', COALESCE(user_agent, '')), 'C')
+    ) STORED;
+CREATE INDEX IF NOT EXISTS idx_access_logs_search_vector ON access_logs USING GIN (search_vector);
@@ -0,0 +1,4 @@
+-- 複数インスタンスのデータを統合しても衝突しない、時刻順に並ぶID (ULID / UUIDv7)
+-- 連番の id はそのまま主キーとして残し、ID_STRATEGY が serial 以外の時に uid を付ける
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS uid TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_access_logs_uid ON access_logs (uid);
@@ -0,0 +1,3 @@
+-- ログごとの有効期限 (期限を過ぎたものは全体の保存期間より先に削除する)
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_access_logs_expires_at ON access_logs (expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,13 @@
+-- 通知先 (環境変数の通知先に加えて、再デプロイなしで追加・停止できる)
+CREATE TABLE IF NOT EXISTS notification_channels (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	type TEXT NOT NULL,
+	url TEXT NOT NULL DEFAULT '',
+	token TEXT NOT NULL DEFAULT '',
+	chat_id TEXT NOT NULL DEFAULT '',
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	rules TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
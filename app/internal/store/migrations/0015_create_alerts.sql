@@ -0,0 +1,12 @@
+-- 発生したアラート（稼働監視・データ量・warn 以上のログなど）の履歴
+CREATE TABLE IF NOT EXISTS alerts (
+	id SERIAL PRIMARY KEY,
+	source TEXT NOT NULL,
+	level TEXT NOT NULL,
+	title TEXT NOT NULL DEFAULT '',
+	text TEXT NOT NULL DEFAULT '',
+	entry_id INTEGER,
+	fired_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_fired_at ON alerts (fired_at DESC);
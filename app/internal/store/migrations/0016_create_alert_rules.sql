@@ -0,0 +1,20 @@
+-- アラートルール
+-- threshold: window_seconds 秒間の件数が threshold を超えたら通知
+-- match:     保存されたログの field が pattern（正規表現）に一致したら通知
+CREATE TABLE IF NOT EXISTS alert_rules (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	project_id INTEGER NOT NULL DEFAULT 1 REFERENCES projects (id),
+	event_type TEXT NOT NULL DEFAULT '',
+	min_level TEXT NOT NULL DEFAULT '',
+	field TEXT NOT NULL DEFAULT '',
+	pattern TEXT NOT NULL DEFAULT '',
+	threshold INTEGER NOT NULL DEFAULT 0,
+	window_seconds INTEGER NOT NULL DEFAULT 0,
+	cooldown_seconds INTEGER NOT NULL DEFAULT 600,
+	level TEXT NOT NULL DEFAULT 'warn',
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	last_fired_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
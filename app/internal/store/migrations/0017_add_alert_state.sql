@@ -0,0 +1,11 @@
+-- アラートの確認状態と、同じ種類のアラートをまとめるキー
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS key TEXT NOT NULL DEFAULT '';
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS acknowledged_at TIMESTAMP;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS acknowledged_by TEXT NOT NULL DEFAULT '';
+
+-- キーごとのミュート (muted_until までは通知しない)
+CREATE TABLE IF NOT EXISTS alert_mutes (
+	key TEXT PRIMARY KEY,
+	muted_until TIMESTAMP NOT NULL,
+	muted_by TEXT NOT NULL DEFAULT ''
+);
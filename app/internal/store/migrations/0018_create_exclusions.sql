@@ -0,0 +1,10 @@
+-- 記録・通知から除外するアクセス (検索エンジンのクローラーや死活監視など)
+-- scope = 'all' なら保存も通知もしない、'notify' なら保存だけして通知しない
+CREATE TABLE IF NOT EXISTS exclusions (
+	id SERIAL PRIMARY KEY,
+	field TEXT NOT NULL,
+	pattern TEXT NOT NULL,
+	scope TEXT NOT NULL DEFAULT 'all',
+	note TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
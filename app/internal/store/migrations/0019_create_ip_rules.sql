@@ -0,0 +1,9 @@
+-- 書き込みを受け付ける・拒否するクライアントのアドレス範囲 (CIDR)
+-- action = 'deny' なら403を返す。'allow' が1件でもあれば、どの allow にも入らないアドレスも拒否する
+CREATE TABLE IF NOT EXISTS ip_rules (
+	id SERIAL PRIMARY KEY,
+	cidr TEXT NOT NULL,
+	action TEXT NOT NULL DEFAULT 'deny',
+	note TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,9 @@
+-- 再送しても送れなかった通知 (/api/admin/dead-letters から再送・破棄する)
+CREATE TABLE IF NOT EXISTS dead_letters (
+	id SERIAL PRIMARY KEY,
+	queue TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
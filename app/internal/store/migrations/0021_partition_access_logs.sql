@@ -0,0 +1,51 @@
+-- access_logs を created_at の月ごとのパーティションに分ける (保存期間を過ぎた月はパーティションごと DROP する)
+-- 既存の行は新しいテーブルへ移す。どの月にも入らない行は access_logs_default に入る
+-- 先の月のパーティションは起動時と1日ごとに作る (store.EnsurePartitions)
+-- 主キーと uid の一意制約にはパーティションキーの created_at を含める必要がある
+DO $$
+DECLARE
+	m DATE;
+	last_month DATE;
+	cols TEXT;
+BEGIN
+	IF (SELECT relkind FROM pg_class WHERE oid = 'access_logs'::regclass) = 'p' THEN
+		RETURN;
+	END IF;
+
+	ALTER TABLE access_logs RENAME TO access_logs_legacy;
+	ALTER SEQUENCE access_logs_id_seq OWNED BY NONE;
+
+	-- 生成列 (search_vector と INDEXED_FIELDS の f_*) と既定値を引き継ぐ
+	CREATE TABLE access_logs (LIKE access_logs_legacy INCLUDING DEFAULTS INCLUDING GENERATED)
+		PARTITION BY RANGE (created_at);
+	ALTER TABLE access_logs ALTER COLUMN created_at SET NOT NULL;
+	ALTER TABLE access_logs ADD PRIMARY KEY (id, created_at);
+	ALTER TABLE access_logs ADD FOREIGN KEY (project_id) REFERENCES projects (id);
+	ALTER SEQUENCE access_logs_id_seq OWNED BY access_logs.id;
+	CREATE TABLE access_logs_default PARTITION OF access_logs DEFAULT;
+
+	-- 既存の行がある月 (最大5年前まで) から来月まで
+	m := date_trunc('month', GREATEST(
+		COALESCE((SELECT MIN(created_at) FROM access_logs_legacy), CURRENT_TIMESTAMP),
+		CURRENT_TIMESTAMP - INTERVAL '5 years'));
+	last_month := date_trunc('month', CURRENT_TIMESTAMP + INTERVAL '1 month');
+	WHILE m <= last_month LOOP
+		EXECUTE format('CREATE TABLE %I PARTITION OF access_logs FOR VALUES FROM (%L) TO (%L)',
+			'access_logs_' || to_char(m, 'YYYY_MM'), m, (m + INTERVAL '1 month')::date);
+		m := m + INTERVAL '1 month';
+	END LOOP;
+
+	UPDATE access_logs_legacy SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+	SELECT string_agg(quote_ident(column_name), ', ' ORDER BY ordinal_position) INTO cols
+	FROM information_schema.columns
+	WHERE table_schema = current_schema() AND table_name = 'access_logs_legacy' AND is_generated = 'NEVER';
+	EXECUTE format('INSERT INTO access_logs (%1$s) SELECT %1$s FROM access_logs_legacy', cols);
+	DROP TABLE access_logs_legacy;
+
+	CREATE INDEX access_logs_project_id_idx ON access_logs (project_id, id DESC);
+	CREATE INDEX access_logs_level_idx ON access_logs (project_id, level, id DESC);
+	CREATE INDEX idx_access_logs_search_vector ON access_logs USING GIN (search_vector);
+	CREATE UNIQUE INDEX idx_access_logs_uid ON access_logs (uid, created_at);
+	CREATE INDEX idx_access_logs_expires_at ON access_logs (expires_at) WHERE expires_at IS NOT NULL;
+END
+$$;
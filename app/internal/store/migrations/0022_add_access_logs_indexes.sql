@@ -0,0 +1,5 @@
+-- 期間・種別・UA・IPの絞り込みで全件を読まないための索引 (一覧は store.ExpectedIndexes と揃える)
+CREATE INDEX IF NOT EXISTS idx_access_logs_created_at ON access_logs (project_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_access_logs_event_type ON access_logs (project_id, event_type, id DESC);
+CREATE INDEX IF NOT EXISTS idx_access_logs_user_agent ON access_logs (project_id, user_agent);
+CREATE INDEX IF NOT EXISTS idx_access_logs_ip ON access_logs (ip);
@@ -0,0 +1,3 @@
+-- 同じアクセスの繰り返し (COLLAPSE_WINDOW) を1行にまとめた時の回数と最後のアクセス時刻
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS hit_count INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS last_hit_at TIMESTAMP;
@@ -0,0 +1,3 @@
+-- 訪問者クッキー (VISITOR_COOKIE=true) の匿名ID。ユニーク訪問者・再訪問者の集計に使う
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS visitor_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_access_logs_visitor_id ON access_logs (project_id, visitor_id, created_at) WHERE visitor_id IS NOT NULL;
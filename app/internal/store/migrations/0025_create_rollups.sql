@@ -0,0 +1,29 @@
+-- 集計済みの件数 (ROLLUP_INTERVAL のジョブが作る)。長い期間の集計は生のログを読まずにここから数える
+-- 値のない列は '' にする（主キーに NULL を入れないため）。有効期限付きのログは含めない
+CREATE TABLE IF NOT EXISTS access_rollups_hourly (
+	bucket TIMESTAMP NOT NULL,
+	project_id INTEGER NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+	event_type TEXT NOT NULL,
+	level TEXT NOT NULL DEFAULT '',
+	country TEXT NOT NULL DEFAULT '',
+	browser TEXT NOT NULL DEFAULT '',
+	hits BIGINT NOT NULL,
+	PRIMARY KEY (project_id, bucket, event_type, level, country, browser)
+);
+
+CREATE TABLE IF NOT EXISTS access_rollups_daily (
+	bucket TIMESTAMP NOT NULL,
+	project_id INTEGER NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+	event_type TEXT NOT NULL,
+	level TEXT NOT NULL DEFAULT '',
+	country TEXT NOT NULL DEFAULT '',
+	browser TEXT NOT NULL DEFAULT '',
+	hits BIGINT NOT NULL,
+	PRIMARY KEY (project_id, bucket, event_type, level, country, browser)
+);
+
+-- どこまで集計したか（rolled_until より前の時間は集計済み）
+CREATE TABLE IF NOT EXISTS rollup_state (
+	name TEXT PRIMARY KEY,
+	rolled_until TIMESTAMP NOT NULL
+);
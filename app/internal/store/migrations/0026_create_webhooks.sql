@@ -0,0 +1,18 @@
+-- 新しいログを外部のURLへ送る Webhook（本文は secret で HMAC-SHA256 の署名を付ける）
+-- 続けて max_failures 回届かなかったら enabled を false にして止める
+CREATE TABLE IF NOT EXISTS webhooks (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	project_id INTEGER REFERENCES projects (id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	event_types TEXT NOT NULL DEFAULT '',
+	min_level TEXT NOT NULL DEFAULT '',
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	failures INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	last_delivery_at TIMESTAMP,
+	disabled_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
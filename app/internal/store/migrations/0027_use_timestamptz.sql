@@ -0,0 +1,89 @@
+-- 時刻の列を TIMESTAMP から TIMESTAMPTZ にする (接続のタイムゾーンに関わらず同じ時点として読み書きする)
+-- これまでの値は接続の timezone=UTC で書いていたので UTC として読み替える
+-- access_logs.created_at はパーティションキーで型を変えられないため、0021 と同じく作り直して移す
+DO $$
+DECLARE
+	col RECORD;
+	m DATE;
+	last_month DATE;
+	cols TEXT;
+	vals TEXT;
+BEGIN
+	-- access_logs 以外のテーブル
+	FOR col IN
+		SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND data_type = 'timestamp without time zone'
+			AND table_name NOT LIKE 'access\_logs%'
+			AND table_name IN (SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE')
+	LOOP
+		EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE TIMESTAMPTZ USING %I AT TIME ZONE ''UTC''',
+			col.table_name, col.column_name, col.column_name);
+	END LOOP;
+
+	IF (SELECT data_type FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'access_logs' AND column_name = 'created_at') = 'timestamp with time zone' THEN
+		RETURN;
+	END IF;
+
+	ALTER TABLE access_logs RENAME TO access_logs_legacy;
+	ALTER SEQUENCE access_logs_id_seq OWNED BY NONE;
+	-- 索引・パーティションの名前を新しいテーブルで使うので、古い方は外しておく
+	FOR col IN SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = 'access_logs_legacy' AND indexname <> 'access_logs_pkey' LOOP
+		EXECUTE format('DROP INDEX %I', col.indexname);
+	END LOOP;
+	ALTER TABLE access_logs_legacy DROP CONSTRAINT access_logs_pkey;
+	FOR col IN SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid WHERE i.inhparent = 'access_logs_legacy'::regclass LOOP
+		EXECUTE format('ALTER TABLE %I RENAME TO %I', col.relname, col.relname || '_legacy');
+	END LOOP;
+
+	-- 生成列と既定値を引き継ぎ、時刻の列だけ型を変えた形を作ってからパーティションテーブルにする
+	CREATE TABLE access_logs_shape (LIKE access_logs_legacy INCLUDING DEFAULTS INCLUDING GENERATED);
+	FOR col IN
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'access_logs_shape' AND data_type = 'timestamp without time zone'
+	LOOP
+		EXECUTE format('ALTER TABLE access_logs_shape ALTER COLUMN %I TYPE TIMESTAMPTZ USING %I AT TIME ZONE ''UTC''',
+			col.column_name, col.column_name);
+	END LOOP;
+	CREATE TABLE access_logs (LIKE access_logs_shape INCLUDING DEFAULTS INCLUDING GENERATED)
+		PARTITION BY RANGE (created_at);
+	DROP TABLE access_logs_shape;
+	ALTER TABLE access_logs ALTER COLUMN created_at SET NOT NULL;
+	ALTER TABLE access_logs ADD PRIMARY KEY (id, created_at);
+	ALTER TABLE access_logs ADD FOREIGN KEY (project_id) REFERENCES projects (id);
+	ALTER SEQUENCE access_logs_id_seq OWNED BY access_logs.id;
+	CREATE TABLE access_logs_default PARTITION OF access_logs DEFAULT;
+
+	-- 既存の行がある月 (最大5年前まで) から来月まで (UTC の月の区切り)
+	m := date_trunc('month', GREATEST(
+		COALESCE((SELECT MIN(created_at) FROM access_logs_legacy), CURRENT_TIMESTAMP AT TIME ZONE 'UTC'),
+		(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') - INTERVAL '5 years'));
+	last_month := date_trunc('month', (CURRENT_TIMESTAMP AT TIME ZONE 'UTC') + INTERVAL '1 month');
+	WHILE m <= last_month LOOP
+		EXECUTE format('CREATE TABLE %I PARTITION OF access_logs FOR VALUES FROM (%L) TO (%L)',
+			'access_logs_' || to_char(m, 'YYYY_MM'), m::timestamp AT TIME ZONE 'UTC', (m + INTERVAL '1 month')::timestamp AT TIME ZONE 'UTC');
+		m := m + INTERVAL '1 month';
+	END LOOP;
+
+	SELECT string_agg(quote_ident(column_name), ', ' ORDER BY ordinal_position),
+		string_agg(CASE WHEN data_type = 'timestamp without time zone' THEN quote_ident(column_name) || ' AT TIME ZONE ''UTC'''
+			ELSE quote_ident(column_name) END, ', ' ORDER BY ordinal_position)
+	INTO cols, vals
+	FROM information_schema.columns
+	WHERE table_schema = current_schema() AND table_name = 'access_logs_legacy' AND is_generated = 'NEVER';
+	EXECUTE format('INSERT INTO access_logs (%s) SELECT %s FROM access_logs_legacy', cols, vals);
+	DROP TABLE access_logs_legacy;
+
+	-- 0021・0022・0024 の索引 (INDEXED_FIELDS の索引は起動時に作り直す)
+	CREATE INDEX access_logs_project_id_idx ON access_logs (project_id, id DESC);
+	CREATE INDEX access_logs_level_idx ON access_logs (project_id, level, id DESC);
+	CREATE INDEX idx_access_logs_search_vector ON access_logs USING GIN (search_vector);
+	CREATE UNIQUE INDEX idx_access_logs_uid ON access_logs (uid, created_at);
+	CREATE INDEX idx_access_logs_expires_at ON access_logs (expires_at) WHERE expires_at IS NOT NULL;
+	CREATE INDEX idx_access_logs_created_at ON access_logs (project_id, created_at DESC);
+	CREATE INDEX idx_access_logs_event_type ON access_logs (project_id, event_type, id DESC);
+	CREATE INDEX idx_access_logs_user_agent ON access_logs (project_id, user_agent);
+	CREATE INDEX idx_access_logs_ip ON access_logs (ip);
+	CREATE INDEX idx_access_logs_visitor_id ON access_logs (project_id, visitor_id, created_at) WHERE visitor_id IS NOT NULL;
+END
+$$;
@@ -0,0 +1,4 @@
+-- ログの仕分け (PATCH /api/logs/{id})。タグ (pentest, known-bot など) とメモ
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS note TEXT;
+CREATE INDEX IF NOT EXISTS idx_access_logs_tags ON access_logs USING GIN (tags) WHERE tags <> '{}';
@@ -0,0 +1,2 @@
+-- 通知先ごとの本文のテンプレート (Go の text/template。空なら NOTIFY_TEMPLATE か既定の本文)
+ALTER TABLE notification_channels ADD COLUMN IF NOT EXISTS template TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+-- 通知のルーティング。position の順に match（検索式）を当て、最初に合ったものに従う
+-- action が send なら channels の通知先だけへ送り、suppress なら送らない。どれにも合わなければ全ての通知先へ送る
+CREATE TABLE IF NOT EXISTS notify_routes (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	position INTEGER NOT NULL DEFAULT 0,
+	match TEXT NOT NULL DEFAULT '',
+	action TEXT NOT NULL DEFAULT 'send',
+	channels TEXT[] NOT NULL DEFAULT '{}',
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
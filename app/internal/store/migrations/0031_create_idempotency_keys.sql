@@ -0,0 +1,13 @@
+-- 書き込みの Idempotency-Key。同じキーで送り直されたリクエストには、保存せずに最初の応答を返す
+-- key は「プロジェクトID:キー」。status が NULL の行は処理中で、expires_at を過ぎたら別のリクエストが引き継げる
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key TEXT PRIMARY KEY,
+	request_hash TEXT NOT NULL,
+	status INTEGER,
+	headers JSONB,
+	body BYTEA,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,8 @@
+-- SAMPLE_RATE で間引いて保存したアクセスの抽出率 (1行が 1 / sample_rate 件を表す)。間引いていなければ1
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS sample_rate DOUBLE PRECISION NOT NULL DEFAULT 1;
+
+-- 集計済みの件数にも、抽出率で割り戻した件数を持たせる（?extrapolate=true の集計用）
+ALTER TABLE access_rollups_hourly ADD COLUMN IF NOT EXISTS estimated_hits DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE access_rollups_daily ADD COLUMN IF NOT EXISTS estimated_hits DOUBLE PRECISION NOT NULL DEFAULT 0;
+UPDATE access_rollups_hourly SET estimated_hits = hits WHERE estimated_hits = 0;
+UPDATE access_rollups_daily SET estimated_hits = hits WHERE estimated_hits = 0;
@@ -0,0 +1,3 @@
+-- プロジェクトごとのプライバシー設定。NULL ならサーバーの既定 (PRIVACY_ANONYMIZE_IP / PRIVACY_HASH_USER_AGENT) に従う
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS anonymize_ip BOOLEAN;
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS hash_user_agent BOOLEAN;
@@ -0,0 +1,13 @@
+-- 本人からの開示・削除の依頼の記録 (/api/privacy/export・/api/privacy/delete)
+-- 識別子 (IP など) はそのまま残さず、SHA-256 だけを残す（後から同じ識別子の依頼を処理したか確かめられる）
+CREATE TABLE IF NOT EXISTS privacy_requests (
+    id SERIAL PRIMARY KEY,
+    action TEXT NOT NULL,
+    subject_hash TEXT NOT NULL,
+    project_id INTEGER,
+    rows BIGINT NOT NULL DEFAULT 0,
+    reason TEXT NOT NULL DEFAULT '',
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_privacy_requests_subject ON privacy_requests (subject_hash);
@@ -0,0 +1,2 @@
+-- 通知先の種類ごとの設定（email の host / to、webhook の secret など。url / token / chat_id は列のまま）
+ALTER TABLE notification_channels ADD COLUMN IF NOT EXISTS settings JSONB NOT NULL DEFAULT '{}';
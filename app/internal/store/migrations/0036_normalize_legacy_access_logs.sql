@@ -0,0 +1,90 @@
+-- 0001 より前から手で作っていた access_logs の列をそろえる
+-- 同じ名前の列が既にあると 0002〜0010 の ADD COLUMN IF NOT EXISTS は何もしないため、
+-- ip が TEXT のまま・path が VARCHAR のまま・project_id / level に NULL や別表記が残る、といった環境がある
+-- 型が合っている列・値が入っている行には何もしない（作り直さずにその場で変える）
+-- 変換できない値は NULL / 既定値にし、元の値は fields の legacy_<列名> に残す
+
+-- ip の文字列を INET にする（"203.0.113.5:443" や "[2001:db8::1]:443" のポートは除く。読めなければ NULL）
+CREATE OR REPLACE FUNCTION logger_parse_ip(v TEXT) RETURNS INET AS $$
+BEGIN
+	v := btrim(v);
+	IF v = '' OR v = '-' THEN
+		RETURN NULL;
+	END IF;
+	IF v ~ '^\[.*\]:[0-9]+$' THEN
+		v := substring(v FROM '^\[(.*)\]:[0-9]+$');
+	ELSIF v ~ '^[0-9.]+:[0-9]+$' THEN
+		v := split_part(v, ':', 1);
+	END IF;
+	RETURN v::INET;
+EXCEPTION WHEN others THEN
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+DO $$
+DECLARE
+	col RECORD;
+	vector_dropped BOOLEAN := false;
+BEGIN
+	-- ip: INET 以外（TEXT / VARCHAR）で作られていたら変換する
+	IF (SELECT data_type FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'access_logs' AND column_name = 'ip') <> 'inet' THEN
+		UPDATE access_logs SET fields = COALESCE(fields, '{}'::jsonb) || jsonb_build_object('legacy_ip', ip::text)
+		WHERE ip IS NOT NULL AND logger_parse_ip(ip::text) IS NULL AND btrim(ip::text) NOT IN ('', '-');
+		ALTER TABLE access_logs ALTER COLUMN ip TYPE INET USING logger_parse_ip(ip::text);
+	END IF;
+
+	-- 文字列の列: VARCHAR(n) / CHAR(n) を TEXT にする（長さの上限で書き込みが失敗しないように）
+	-- search_vector は path / message / user_agent から作る生成列で、元の列の型を変えられないため作り直す
+	FOR col IN
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'access_logs'
+			AND column_name IN ('path', 'referrer', 'level', 'message', 'user_agent', 'event_type', 'country')
+			AND data_type IN ('character varying', 'character')
+	LOOP
+		IF NOT vector_dropped THEN
+			ALTER TABLE access_logs DROP COLUMN IF EXISTS search_vector;
+			vector_dropped := true;
+		END IF;
+		EXECUTE format('ALTER TABLE access_logs ALTER COLUMN %I TYPE TEXT', col.column_name);
+	END LOOP;
+	IF vector_dropped THEN
+		-- 0011 と同じ定義
+		ALTER TABLE access_logs ADD COLUMN search_vector TSVECTOR
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('simple', COALESCE(message, '')), 'A') ||
+				setweight(to_tsvector('simple', COALESCE(path, '')), 'B') ||
+				setweight(to_tsvector('simple', COALESCE(user_agent, '')), 'C')
+			) STORED;
+		CREATE INDEX IF NOT EXISTS idx_access_logs_search_vector ON access_logs USING GIN (search_vector);
+	END IF;
+END
+$$;
+
+-- project_id: NULL の行はデフォルトプロジェクト (id=1) に入れる（0004 と同じ扱い）
+UPDATE access_logs SET project_id = 1 WHERE project_id IS NULL;
+ALTER TABLE access_logs ALTER COLUMN project_id SET DEFAULT 1;
+ALTER TABLE access_logs ALTER COLUMN project_id SET NOT NULL;
+
+-- event_type: NULL の行はアクセス記録として扱う（0002 と同じ）
+UPDATE access_logs SET event_type = 'access' WHERE event_type IS NULL;
+ALTER TABLE access_logs ALTER COLUMN event_type SET DEFAULT 'access';
+ALTER TABLE access_logs ALTER COLUMN event_type SET NOT NULL;
+
+-- level: POST /api/logs と同じ表記にそろえる (model.NormalizeLevel)
+-- 大文字・別表記 (WARNING / err など) は読み替え、未知のレベルは info にして元の値を legacy_level に残す
+UPDATE access_logs SET level = 'info' WHERE level IS NULL OR btrim(level) = '';
+UPDATE access_logs SET level = CASE lower(btrim(level))
+		WHEN 'trace' THEN 'debug'
+		WHEN 'notice' THEN 'info'
+		WHEN 'warning' THEN 'warn'
+		WHEN 'err' THEN 'error'
+		WHEN 'critical' THEN 'fatal'
+		WHEN 'panic' THEN 'fatal'
+		ELSE lower(btrim(level))
+	END
+WHERE level NOT IN ('debug', 'info', 'warn', 'error', 'fatal');
+UPDATE access_logs SET fields = COALESCE(fields, '{}'::jsonb) || jsonb_build_object('legacy_level', level), level = 'info'
+WHERE level NOT IN ('debug', 'info', 'warn', 'error', 'fatal');
+ALTER TABLE access_logs ALTER COLUMN level SET DEFAULT 'info';
@@ -0,0 +1,4 @@
+-- ゴミ箱 (DELETE /api/logs/{id} は削除した時刻を入れるだけ。POST /api/logs/{id}/restore で戻す)
+-- TRASH_RETENTION_DAYS を過ぎたものは定期処理で本当に削除する
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_access_logs_deleted_at ON access_logs (deleted_at) WHERE deleted_at IS NOT NULL;
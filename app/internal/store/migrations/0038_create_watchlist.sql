@@ -0,0 +1,9 @@
+-- 監視リスト（一致したアクセスに flagged のタグを付け、そのIPの直近の履歴と一緒に通知する）
+-- field: ip（IPアドレスか CIDR）/ user_agent（正規表現）
+CREATE TABLE IF NOT EXISTS watchlist (
+	id SERIAL PRIMARY KEY,
+	field TEXT NOT NULL,
+	pattern TEXT NOT NULL,
+	note TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,12 @@
+-- プロジェクトのキーごとの上限（0 なら制限しない）と、日ごとのリクエスト数
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS daily_quota BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS monthly_quota BIGINT NOT NULL DEFAULT 0;
+
+-- requests: 受け付けたリクエスト、rejected: 上限を超えて 429 で断ったリクエスト（日付は UTC）
+CREATE TABLE IF NOT EXISTS key_usage (
+	project_id INT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	day DATE NOT NULL,
+	requests BIGINT NOT NULL DEFAULT 0,
+	rejected BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (project_id, day)
+);
@@ -0,0 +1,6 @@
+-- 実行中に変えられる設定（/api/settings）。行のない項目は環境変数の値を使う
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- 記録したリクエストの応答ステータスと処理時間（呼び出し側・クライアントが報告した時だけ入る）
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS status SMALLINT;
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS duration_ms DOUBLE PRECISION;
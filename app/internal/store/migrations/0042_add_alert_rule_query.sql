@@ -0,0 +1,3 @@
+-- アラートルールの対象を検索式 (store.ParseQuery) で絞る。空なら event_type / min_level だけ
+-- heartbeat: window_seconds 秒間に対象のログが1件もなければ通知（定期的にログを出すはずの処理が止まったことに気付く）
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS query TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- CAPTURE_HEADERS で記録したリクエストヘッダー（{"Accept-Language": "ja", ...}。設定していなければ NULL）
+ALTER TABLE access_logs ADD COLUMN IF NOT EXISTS headers JSONB;
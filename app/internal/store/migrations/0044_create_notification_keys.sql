@@ -0,0 +1,10 @@
+-- 通知を1回だけ送るための印 (NOTIFY_DEDUP=database)。同じDBを使う全てのインスタンスで分け合う
+-- key の印があり expires_at を過ぎていなければ、ほかのインスタンスが送ったので送らない
+CREATE TABLE IF NOT EXISTS notification_keys (
+	key TEXT PRIMARY KEY,
+	claimed_by TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_keys_expires_at ON notification_keys (expires_at);
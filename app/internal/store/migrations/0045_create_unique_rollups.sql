@@ -0,0 +1,15 @@
+-- ユニーク訪問者のスケッチ (HyperLogLog。ROLLUP_INTERVAL のジョブが access_rollups_* と一緒に作る)
+-- 訪問者は SessionStats と同じく visitor_id、なければ IP + UA で見分ける。ボットと有効期限付きのログは含めない
+CREATE TABLE IF NOT EXISTS access_uniques_hourly (
+	bucket TIMESTAMP NOT NULL,
+	project_id INTEGER NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+	sketch BYTEA NOT NULL,
+	PRIMARY KEY (project_id, bucket)
+);
+
+CREATE TABLE IF NOT EXISTS access_uniques_daily (
+	bucket TIMESTAMP NOT NULL,
+	project_id INTEGER NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+	sketch BYTEA NOT NULL,
+	PRIMARY KEY (project_id, bucket)
+);
@@ -0,0 +1,21 @@
+-- 非同期の書き出し (POST /api/exports)。どのインスタンスのワーカーも status = 'queued' の行を1つずつ取って書き出す
+-- location が disk のファイルは書き出したインスタンス (instance) の EXPORT_DIR にあり、s3 ならバケットのキー
+CREATE TABLE IF NOT EXISTS export_jobs (
+	id SERIAL PRIMARY KEY,
+	project_id INTEGER NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+	format TEXT NOT NULL,
+	filters JSONB NOT NULL DEFAULT '{}',
+	status TEXT NOT NULL DEFAULT 'queued',
+	rows BIGINT NOT NULL DEFAULT 0,
+	bytes BIGINT NOT NULL DEFAULT 0,
+	location TEXT NOT NULL DEFAULT '',
+	object TEXT NOT NULL DEFAULT '',
+	error TEXT NOT NULL DEFAULT '',
+	instance TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	started_at TIMESTAMPTZ,
+	finished_at TIMESTAMPTZ,
+	expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_jobs_queued ON export_jobs (id) WHERE status = 'queued';
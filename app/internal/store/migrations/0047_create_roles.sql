@@ -0,0 +1,16 @@
+-- ロールを付けたAPIキー (POST /api/keys)。キーそのものは置かず、SHA-256 の16進だけを置く
+CREATE TABLE IF NOT EXISTS api_keys (
+	id SERIAL PRIMARY KEY,
+	project_id INTEGER NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+	name TEXT NOT NULL DEFAULT '',
+	key_hash TEXT NOT NULL UNIQUE,
+	roles TEXT[] NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ダッシュボードのユーザー (DASHBOARD_USERS の名前) のロール (PUT /api/users/{user})。行のないユーザーは DASHBOARD_DEFAULT_ROLES
+CREATE TABLE IF NOT EXISTS user_roles (
+	username TEXT PRIMARY KEY,
+	roles TEXT[] NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
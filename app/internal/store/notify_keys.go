@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ==========================================
+// 通知を1回だけ送る印 (NOTIFY_DEDUP=database)
+// ==========================================
+// Redis を用意しなくても、同じDBを使うインスタンスの間で同じ通知が重ならないようにする
+
+// ClaimNotificationKey : key の印がまだない（期限を過ぎた）なら expiresAt まで付けて true を返す
+// 同時に付けようとしたインスタンスのうち1つだけが true になる
+func (p *Postgres) ClaimNotificationKey(ctx context.Context, key, claimedBy string, now, expiresAt time.Time) (bool, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	err := p.DB().QueryRowContext(ctx, `
+		INSERT INTO notification_keys (key, claimed_by, created_at, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET claimed_by = EXCLUDED.claimed_by, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE notification_keys.expires_at <= EXCLUDED.created_at
+		RETURNING key`, key, claimedBy, now, expiresAt).Scan(new(string))
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// PurgeNotificationKeys : 期限を過ぎた印を削除する
+func (p *Postgres) PurgeNotificationKeys(ctx context.Context, now time.Time) (int64, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM notification_keys WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 通知のルーティング
+// ==========================================
+
+const notifyRouteColumns = "id, name, position, match, action, channels, enabled, created_at, updated_at"
+
+func scanNotifyRoute(row rowScanner) (model.NotifyRoute, error) {
+	var r model.NotifyRoute
+	err := row.Scan(&r.ID, &r.Name, &r.Position, &r.Match, &r.Action, (*pq.StringArray)(&r.Channels), &r.Enabled, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// ListNotifyRoutes : ルーティングの一覧（当てる順。停止中のものも含む）
+func (p *Postgres) ListNotifyRoutes(ctx context.Context) ([]model.NotifyRoute, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT "+notifyRouteColumns+" FROM notify_routes ORDER BY position, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	routes := []model.NotifyRoute{}
+	for rows.Next() {
+		r, err := scanNotifyRoute(rows)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, r)
+	}
+	return routes, rows.Err()
+}
+
+// NotifyRouteByID : ルーティングを返す（なければ ErrNotFound）
+func (p *Postgres) NotifyRouteByID(ctx context.Context, id int) (model.NotifyRoute, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	r, err := scanNotifyRoute(p.DB().QueryRowContext(ctx,
+		"SELECT "+notifyRouteColumns+" FROM notify_routes WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrNotFound
+	}
+	return r, err
+}
+
+// CreateNotifyRoute : ルーティングを作り、ID と作成日時を r に書き戻す
+func (p *Postgres) CreateNotifyRoute(ctx context.Context, r *model.NotifyRoute) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	return p.DB().QueryRowContext(ctx,
+		`INSERT INTO notify_routes (name, position, match, action, channels, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at, updated_at`,
+		r.Name, r.Position, r.Match, r.Action, pq.StringArray(r.Channels), r.Enabled).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// UpdateNotifyRoute : r.ID のルーティングを r の内容で上書きする（なければ ErrNotFound）
+func (p *Postgres) UpdateNotifyRoute(ctx context.Context, r *model.NotifyRoute) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	err := p.DB().QueryRowContext(ctx,
+		`UPDATE notify_routes SET name = $1, position = $2, match = $3, action = $4, channels = $5, enabled = $6, updated_at = $7
+		WHERE id = $8 RETURNING created_at, updated_at`,
+		r.Name, r.Position, r.Match, r.Action, pq.StringArray(r.Channels), r.Enabled, p.clock.Now(), r.ID).Scan(&r.CreatedAt, &r.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// DeleteNotifyRoute : ルーティングを削除する（なければ ErrNotFound）
+func (p *Postgres) DeleteNotifyRoute(ctx context.Context, id int) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM notify_routes WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-logger/internal/tracing"
+)
+
+// ==========================================
+// access_logs の月ごとのパーティション (0021_partition_access_logs.sql)
+// ==========================================
+
+// partitionLockID : 複数のインスタンスが同時にパーティションを作らないためのロック
+const partitionLockID = 727274
+
+// partitionName : access_logs_2026_01 の形（月の初日を渡す）
+func partitionName(month time.Time) string {
+	return "access_logs_" + month.Format("2006_01")
+}
+
+// monthStart : その月の初日 (UTC)
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// partitioned : access_logs がパーティションテーブルか（マイグレーション前なら false）
+func (p *Postgres) partitioned(ctx context.Context) (bool, error) {
+	var kind sql.NullString
+	err := p.DB().QueryRowContext(ctx, "SELECT relkind::text FROM pg_class WHERE oid = to_regclass('access_logs')").Scan(&kind)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return kind.String == "p", err
+}
+
+// EnsurePartitions : 今月から monthsAhead か月先までのパーティションがなければ作り、作った名前を返す
+// access_logs_default にその月の行が入っていれば、作ったパーティションへ移す
+func (p *Postgres) EnsurePartitions(ctx context.Context, now time.Time, monthsAhead int) ([]string, error) {
+	if ok, err := p.partitioned(ctx); err != nil || !ok {
+		return nil, err
+	}
+	var created []string
+	month := monthStart(now)
+	for i := 0; i <= monthsAhead; i++ {
+		ok, err := p.createPartition(ctx, month)
+		if err != nil {
+			return created, fmt.Errorf("%s: %w", partitionName(month), err)
+		}
+		if ok {
+			created = append(created, partitionName(month))
+		}
+		month = month.AddDate(0, 1, 0)
+	}
+	return created, nil
+}
+
+// createPartition : 1か月分のパーティションを作る（既にあれば false）
+func (p *Postgres) createPartition(ctx context.Context, month time.Time) (bool, error) {
+	name := partitionName(month)
+	from, to := month.Format("2006-01-02"), month.AddDate(0, 1, 0).Format("2006-01-02")
+
+	tx, err := p.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", partitionLockID); err != nil {
+		return false, err
+	}
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", name).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	// 既定のパーティションに同じ月の行があると作れないので、一時テーブルへ退避してから戻す
+	var stray int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM access_logs_default WHERE created_at >= $1 AND created_at < $2", from, to).Scan(&stray); err != nil {
+		return false, err
+	}
+	var columns string
+	if stray > 0 {
+		if columns, err = storedColumns(ctx, tx); err != nil {
+			return false, err
+		}
+		// CREATE TABLE AS にはパラメータを渡せないので、日付はここで組み立てた値を埋め込む
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"CREATE TEMP TABLE access_logs_moving ON COMMIT DROP AS SELECT %s FROM access_logs_default WHERE created_at >= '%s' AND created_at < '%s'",
+			columns, from, to)); err != nil {
+			return false, err
+		}
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM access_logs_default WHERE created_at >= $1 AND created_at < $2", from, to); err != nil {
+			return false, err
+		}
+	}
+	createSQL := fmt.Sprintf("CREATE TABLE %s PARTITION OF access_logs FOR VALUES FROM ('%s') TO ('%s')", name, from, to)
+	ctx, span := tracing.StartDBSpan(ctx, "CREATE", "access_logs", createSQL)
+	_, err = tx.ExecContext(ctx, createSQL)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return false, err
+	}
+	if stray > 0 {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO access_logs (%s) SELECT %s FROM access_logs_moving", columns, columns)); err != nil {
+			return false, err
+		}
+		fmt.Printf("Moved %d events from access_logs_default to %s\n", stray, name)
+	}
+	return true, tx.Commit()
+}
+
+// storedColumns : 生成列以外の列（INSERT で値を渡せる列）
+func storedColumns(ctx context.Context, tx *sql.Tx) (string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'access_logs' AND is_generated = 'NEVER'
+		ORDER BY ordinal_position`)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return "", err
+		}
+		columns = append(columns, `"`+c+`"`)
+	}
+	return strings.Join(columns, ", "), rows.Err()
+}
+
+// DropPartitionsBefore : 月の終わりが olderThan 以前のパーティションを丸ごと削除し、消えた行数（統計からの概算）を返す
+// 行を1件ずつ DELETE するより軽く、テーブルも膨らまない
+func (p *Postgres) DropPartitionsBefore(ctx context.Context, olderThan time.Time) (int64, error) {
+	if olderThan.IsZero() {
+		return 0, nil
+	}
+	if ok, err := p.partitioned(ctx); err != nil || !ok {
+		return 0, err
+	}
+	rows, err := p.DB().QueryContext(ctx, `SELECT c.relname, GREATEST(c.reltuples, 0)::bigint
+		FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'access_logs'::regclass ORDER BY c.relname`)
+	if err != nil {
+		return 0, err
+	}
+	type partition struct {
+		name string
+		rows int64
+	}
+	var expired []partition
+	for rows.Next() {
+		var part partition
+		if err := rows.Scan(&part.name, &part.rows); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		month, err := time.Parse("2006_01", strings.TrimPrefix(part.name, "access_logs_"))
+		if err != nil {
+			continue // access_logs_default
+		}
+		if !month.AddDate(0, 1, 0).After(olderThan) {
+			expired = append(expired, part)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, part := range expired {
+		dropSQL := "DROP TABLE " + part.name
+		ctx, span := tracing.StartDBSpan(ctx, "DROP", "access_logs", dropSQL)
+		_, err := p.DB().ExecContext(ctx, dropSQL)
+		tracing.EndSpan(span, err)
+		if err != nil {
+			return total, fmt.Errorf("drop %s: %w", part.name, err)
+		}
+		fmt.Printf("Dropped partition %s (~%d events)\n", part.name, part.rows)
+		total += part.rows
+	}
+	return total, nil
+}
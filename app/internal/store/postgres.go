@@ -0,0 +1,386 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"go-logger/internal/clock"
+	"go-logger/internal/config"
+	"go-logger/internal/faults"
+	"go-logger/internal/model"
+	"go-logger/internal/queue"
+)
+
+// ==========================================
+// DB接続の管理 (起動時リトライ + 実行中のウォッチドッグ)
+// ==========================================
+
+// Postgres : PostgreSQL に保存する Store
+type Postgres struct {
+	connStr string
+	clock   clock.Clock
+
+	mu      sync.RWMutex
+	db      *sql.DB
+	healthy atomic.Bool
+
+	replica *replica // 読み出し用 (UseReplica。nil ならプライマリから読む)
+
+	buffer      queue.Queue // 再接続中の書き込み（QUEUE_BACKEND で、再起動後も残るディスクや Redis にできる）
+	bufferLimit int
+
+	indexedFields map[string]IndexedField
+
+	queryTimeout time.Duration // 1回のDB操作の上限 (DB_QUERY_TIMEOUT。0なら呼び出し側の ctx だけ)
+
+	// OnInsert : 保存に成功した時に呼ばれる（バッファからの書き戻しも含む）。Watch の開始前に設定する
+	OnInsert func(*model.LogEntry)
+}
+
+var _ Store = (*Postgres)(nil)
+
+// ConnStrFromEnv : 環境変数から接続文字列を組み立てる
+// セッションのタイムゾーンをUTCに固定し、アプリ側 (clock.Now) と揃える
+// DB_CONNECT_TIMEOUT（既定5秒）で、応答しないDBへの接続を待ち続けないようにする
+func ConnStrFromEnv() string {
+	connectTimeout := max(int(math.Ceil(config.Duration("DB_CONNECT_TIMEOUT", 5*time.Second).Seconds())), 1)
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=disable timezone=UTC connect_timeout=%d",
+		config.String("DB_HOST", ""), config.String("DB_USER", ""), config.String("DB_PASSWORD", ""), config.String("DB_NAME", ""), connectTimeout)
+}
+
+// NewPostgres : 接続前の Store を作る（Connect で接続する）
+func NewPostgres(connStr string, clk clock.Clock) *Postgres {
+	limit := config.Int("DB_WRITE_BUFFER_SIZE", 1000)
+	return &Postgres{
+		connStr:       connStr,
+		clock:         clk,
+		buffer:        queue.NewMemory(limit),
+		bufferLimit:   limit,
+		indexedFields: map[string]IndexedField{},
+		queryTimeout:  config.Duration("DB_QUERY_TIMEOUT", 10*time.Second),
+	}
+}
+
+// opContext : 1回のDB操作に使う ctx（queryTimeout で打ち切る）
+// クライアントが切断した場合は呼び出し側の ctx で、DBが応答しない場合はこちらで止まり、待つゴルーチンが溜まらない
+func (p *Postgres) opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.queryTimeout)
+}
+
+// UseBuffer : 再接続中の書き込みを退避するキューを差し替える（書き込みを受け付ける前に呼ぶ）
+func (p *Postgres) UseBuffer(b queue.Backend) error {
+	q, err := b.Open("writes", p.bufferLimit)
+	if err != nil {
+		return err
+	}
+	p.buffer = q
+	return nil
+}
+
+// DB : 現在の接続プールを返す（再接続で差し替わるため必ずこれ経由で使う）
+func (p *Postgres) DB() *sql.DB {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.db
+}
+
+// Healthy : 直近の確認でDBに繋がっていたか
+func (p *Postgres) Healthy() bool { return p.healthy.Load() }
+
+// Close : 接続プールを閉じる
+func (p *Postgres) Close() error {
+	if p.replica != nil {
+		p.replica.close()
+	}
+	if db := p.DB(); db != nil {
+		return db.Close()
+	}
+	return nil
+}
+
+// openDB : プールを作成し、Pingが通るまで待つ
+func openDB(ctx context.Context, connStr string) (*sql.DB, error) {
+	conn, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	if err := conn.PingContext(pingCtx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Connect : DBが起動するまでリトライする（最大10回 / 20秒待機）
+func (p *Postgres) Connect(ctx context.Context) error {
+	var err error
+	for i := 0; i < 10; i++ {
+		fmt.Println("Connecting to database...")
+		var conn *sql.DB
+		if conn, err = openDB(ctx, p.connStr); err == nil {
+			fmt.Println("Success: Connected to Database!")
+			p.swap(conn)
+			p.connectReplica(ctx)
+			return nil
+		}
+		fmt.Printf("Waiting for database... (Attempt %d/10)\n", i+1)
+		time.Sleep(2 * time.Second)
+	}
+	return err
+}
+
+// ConnectOnce : リトライせずに1回だけ接続する（`main doctor` など、すぐ結果が欲しい時）
+func (p *Postgres) ConnectOnce(ctx context.Context) error {
+	conn, err := openDB(ctx, p.connStr)
+	if err != nil {
+		return err
+	}
+	p.swap(conn)
+	p.connectReplica(ctx)
+	return nil
+}
+
+// swap : 新しいプールに差し替え、古いプールを閉じる
+func (p *Postgres) swap(conn *sql.DB) {
+	p.mu.Lock()
+	old := p.db
+	p.db = conn
+	p.mu.Unlock()
+	p.healthy.Store(true)
+	if old != nil {
+		old.Close()
+	}
+}
+
+// Watch : 定期的にPingし、失敗したらプールを作り直す
+// 再接続中の書き込みはバッファに退避し、復旧後にまとめて書き戻す
+func (p *Postgres) Watch(ctx context.Context, interval time.Duration) {
+	ticker := p.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		p.watchReplica(ctx)
+
+		pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		err := p.DB().PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			p.healthy.Store(true)
+			p.flushBuffer(ctx)
+			continue
+		}
+
+		if p.healthy.Swap(false) {
+			fmt.Println("Database connection lost:", err)
+		}
+		conn, err := openDB(ctx, p.connStr)
+		if err != nil {
+			fmt.Println("Reconnecting to database failed:", err)
+			continue
+		}
+		p.swap(conn)
+		fmt.Println("Success: Reconnected to Database!")
+		p.flushBuffer(ctx)
+	}
+}
+
+// checkAfterError : 書き込みエラー時に、原因が接続断かどうかを確認する
+func (p *Postgres) checkAfterError(ctx context.Context) bool {
+	pingCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := p.DB().PingContext(pingCtx); err != nil {
+		p.healthy.Store(false)
+		return false
+	}
+	return true
+}
+
+// ==========================================
+// 再接続中の書き込みバッファ
+// ==========================================
+
+// bufferWrite : バッファに積む。上限を超えたら ErrBufferFull を返して拒否する
+func (p *Postgres) bufferWrite(ctx context.Context, w model.Write) error {
+	if faults.QueueFull() {
+		return ErrBufferFull
+	}
+	item, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	err = p.buffer.Push(ctx, item)
+	if errors.Is(err, queue.ErrFull) {
+		return ErrBufferFull
+	}
+	return err
+}
+
+// flushBuffer : 溜まった書き込みを古い順にDBへ書き戻す
+// 先頭を読むだけにできるバッファ (WAL_DIR) では、書き戻せてから取り除くので、失敗しても順序が崩れない
+func (p *Postgres) flushBuffer(ctx context.Context) {
+	flushed := 0
+	defer func() {
+		if flushed > 0 {
+			fmt.Printf("Flushed %d buffered writes\n", flushed)
+		}
+	}()
+
+	peeker, inOrder := p.buffer.(queue.Peeker)
+	for {
+		var item []byte
+		var ok bool
+		var err error
+		if inOrder {
+			item, ok, err = peeker.Peek(ctx)
+		} else {
+			item, ok, err = p.buffer.TryPop(ctx)
+		}
+		if err != nil {
+			fmt.Println("Failed to read buffered writes:", err)
+			return
+		}
+		if !ok {
+			return
+		}
+		var w model.Write
+		if err := json.Unmarshal(item, &w); err != nil {
+			fmt.Println("Dropping unreadable buffered write:", err)
+			if inOrder {
+				if err := peeker.Ack(ctx); err != nil {
+					fmt.Println("Failed to read buffered writes:", err)
+					return
+				}
+			}
+			continue
+		}
+		if err := p.insertAccessLog(ctx, &w); err != nil {
+			fmt.Println("Failed to flush buffered writes:", err)
+			if inOrder {
+				// 先頭に残したまま、次の回に同じものから書き戻す
+				return
+			}
+			// 再度失敗したらバッファに戻す（末尾に戻るが、uid は受け付けた時点で決まっている）
+			if err := p.buffer.Push(ctx, item); err != nil {
+				fmt.Println("Dropping buffered write:", err)
+			}
+			return
+		}
+		flushed++
+		if inOrder {
+			if err := peeker.Ack(ctx); err != nil {
+				// 取り除けなければ次の回に同じものをもう一度書くことになるので止める
+				fmt.Println("Failed to remove flushed write from buffer:", err)
+				return
+			}
+		}
+	}
+}
+
+// SaveLog : 書き込みを保存する（採番されたIDは w に書き戻される）
+// 再接続中ならバッファに退避し、復旧後に Watch が書き戻す（結果はエラーがない時だけ意味を持つ）
+func (p *Postgres) SaveLog(ctx context.Context, w *model.Write) (SaveResult, error) {
+	var err error
+	if p.healthy.Load() {
+		if faultErr := faults.DB(ctx); faultErr != nil {
+			// 注入した障害は切断と同じ扱いにしてバッファへ回す
+			err = ErrUnavailable
+		} else if err = p.insertAccessLog(ctx, w); err != nil && !p.checkAfterError(ctx) {
+			err = ErrUnavailable
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	switch {
+	case errors.Is(err, ErrUnavailable):
+		if bufErr := p.bufferWrite(ctx, *w); bufErr != nil {
+			fmt.Println("DB Insert Rejected: write buffer full")
+			return 0, bufErr
+		}
+		return Buffered, nil
+	case err != nil:
+		fmt.Println("DB Insert Error:", err)
+		return 0, err
+	}
+	return Stored, nil
+}
+
+// SaveLogs : 複数の書き込みを1つのトランザクションで保存する（採番されたIDは各 w に書き戻される）
+// 保存できた・バッファに退避できた件数を n で返す。再接続中にバッファが一杯になったら、n 件目以降は err で拒否する
+func (p *Postgres) SaveLogs(ctx context.Context, ws []*model.Write) (result SaveResult, n int, err error) {
+	if len(ws) == 0 {
+		return Stored, 0, nil
+	}
+	if p.healthy.Load() {
+		if faultErr := faults.DB(ctx); faultErr != nil {
+			err = ErrUnavailable
+		} else if err = p.insertAccessLogs(ctx, ws); err != nil && !p.checkAfterError(ctx) {
+			err = ErrUnavailable
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	switch {
+	case errors.Is(err, ErrUnavailable):
+		for i, w := range ws {
+			if bufErr := p.bufferWrite(ctx, *w); bufErr != nil {
+				fmt.Printf("DB Insert Rejected: write buffer full (%d of %d writes buffered)\n", i, len(ws))
+				return Buffered, i, bufErr
+			}
+		}
+		return Buffered, len(ws), nil
+	case err != nil:
+		fmt.Println("DB Batch Insert Error:", err)
+		return 0, 0, err
+	}
+	return Stored, len(ws), nil
+}
+
+// ==========================================
+// 値の変換
+// ==========================================
+
+// jsonParam : JSONB列へのパラメータ（lib/pq は []byte を bytea として送るため文字列にする）
+func jsonParam(b []byte) any {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
+
+// headersParam : 記録したヘッダーを JSONB として渡す（なければ NULL）
+func headersParam(h map[string]string) any {
+	if len(h) == 0 {
+		return nil
+	}
+	b, _ := json.Marshal(h)
+	return string(b)
+}
+
+// nullableTime : ゼロ値をNULLとして渡す
+func nullableTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
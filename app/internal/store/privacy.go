@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go-logger/internal/model"
+	"go-logger/internal/tracing"
+)
+
+// ==========================================
+// 本人からの開示・削除の依頼
+// ==========================================
+
+// Subject : 依頼した本人のログの見分け方（IP か訪問者IDのどちらか）
+type Subject struct {
+	ProjectID int // 0 なら全てのプロジェクト
+	IP        string
+	VisitorID string
+}
+
+// where : Subject に合う行の条件
+func (s Subject) where() (string, []any) {
+	var cond string
+	var args []any
+	if s.IP != "" {
+		cond, args = "ip = $1::inet", []any{s.IP}
+	} else {
+		cond, args = "visitor_id = $1", []any{s.VisitorID}
+	}
+	if s.ProjectID != 0 {
+		args = append(args, s.ProjectID)
+		cond += fmt.Sprintf(" AND project_id = $%d", len(args))
+	}
+	return cond, args
+}
+
+// SubjectLogs : 本人のログを全て古い順に返す
+func (p *Postgres) SubjectLogs(ctx context.Context, sub Subject) ([]model.LogEntry, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	cond, args := sub.where()
+	query := fmt.Sprintf("SELECT %s FROM access_logs WHERE %s ORDER BY id", logColumns, cond)
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", query)
+	rows, err := p.DB().QueryContext(ctx, query, args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.LogEntry{}
+	for rows.Next() {
+		l, err := scanLogEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, l)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteSubjectLogs : 本人のログを削除し、同じトランザクションで記録を残す（削除した行数は audit.Rows に入る）
+func (p *Postgres) DeleteSubjectLogs(ctx context.Context, sub Subject, audit *model.PrivacyRequest) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	tx, err := p.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cond, args := sub.where()
+	deleteSQL := "DELETE FROM access_logs WHERE " + cond
+	ctx, span := tracing.StartDBSpan(ctx, "DELETE", "access_logs", deleteSQL)
+	res, err := tx.ExecContext(ctx, deleteSQL, args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return err
+	}
+	if audit.Rows, err = res.RowsAffected(); err != nil {
+		return err
+	}
+	if err := insertPrivacyRequest(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertPrivacyRequest : 依頼を処理した記録を残し、ID を r に書き戻す
+func (p *Postgres) InsertPrivacyRequest(ctx context.Context, r *model.PrivacyRequest) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	return insertPrivacyRequest(ctx, p.DB(), r)
+}
+
+// rowQuerier : *sql.DB と *sql.Tx の共通部分
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insertPrivacyRequest : InsertPrivacyRequest の本体（トランザクションの中からも使う）
+func insertPrivacyRequest(ctx context.Context, db rowQuerier, r *model.PrivacyRequest) error {
+	const insertSQL = `INSERT INTO privacy_requests (action, subject_hash, project_id, rows, reason, requested_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	projectID := sql.NullInt64{Int64: int64(r.ProjectID), Valid: r.ProjectID != 0}
+	ctx, span := tracing.StartDBSpan(ctx, "INSERT", "privacy_requests", insertSQL)
+	err := db.QueryRowContext(ctx, insertSQL, r.Action, r.SubjectHash, projectID, r.Rows, r.Reason, r.RequestedAt).Scan(&r.ID)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+// ListPrivacyRequests : 依頼の記録を新しい順に返す（subjectHash が空でなければその識別子の分だけ。最新100件）
+func (p *Postgres) ListPrivacyRequests(ctx context.Context, subjectHash string, limit int) ([]model.PrivacyRequest, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	const selectSQL = `SELECT id, action, subject_hash, COALESCE(project_id, 0), rows, reason, requested_at
+		FROM privacy_requests WHERE $1 = '' OR subject_hash = $1 ORDER BY requested_at DESC, id DESC LIMIT $2`
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "privacy_requests", selectSQL)
+	rows, err := p.DB().QueryContext(ctx, selectSQL, subjectHash, limitOr(limit, 100))
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []model.PrivacyRequest{}
+	for rows.Next() {
+		var r model.PrivacyRequest
+		if err := rows.Scan(&r.ID, &r.Action, &r.SubjectHash, &r.ProjectID, &r.Rows, &r.Reason, &r.RequestedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+	return requests, rows.Err()
+}
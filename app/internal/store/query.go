@@ -0,0 +1,671 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 検索式 (?query=ua:~curl AND country:JP AND created_at>2024-01-01)
+// ==========================================
+//
+//	項目:値      一致（値に * を含めばワイルドカード）   ua:curl/8.0  path:/blog/*
+//	項目:~値     部分一致（大文字小文字を区別しない）     ua:~bot
+//	項目!:値     一致しない / 項目!~値 部分一致しない
+//	= != ~ は : !: :~ の別名                               ua~curl  level!=debug
+//	項目>値 項目>=値 項目<値 項目<=値                      created_at>=2024-01-01  level>=warn  hits>3  status>=500
+//	AND / OR / NOT と (…)。AND は省略できる。-項目:値 は NOT と同じ。空白を含む値は "…" で囲む
+//
+// 値はプレースホルダで渡すので、SQL として解釈されることはない
+
+// maxQueryLength, maxQueryTerms : 1つの検索式の上限
+const (
+	maxQueryLength = 1000
+	maxQueryTerms  = 50
+)
+
+// queryKind : 項目の値の種類（使える演算子と値の読み方が決まる）
+type queryKind int
+
+const (
+	queryText queryKind = iota
+	queryTime
+	queryNumber
+	queryLevel
+	queryBool
+	queryIP
+	queryTag
+	queryField // field.<キー>
+)
+
+// queryColumn : 検索式で使える項目
+type queryColumn struct {
+	expr string
+	kind queryKind
+}
+
+// queryColumns : 項目名（別名を含む）→ 列
+var queryColumns = map[string]queryColumn{
+	"ua":         {"user_agent", queryText},
+	"user_agent": {"user_agent", queryText},
+	"ip":         {"ip", queryIP},
+	"country":    {"country", queryText},
+	"path":       {"path", queryText},
+	"ref":        {"referrer", queryText},
+	"referrer":   {"referrer", queryText},
+	"type":       {"event_type", queryText},
+	"event_type": {"event_type", queryText},
+	"level":      {"level", queryLevel},
+	"msg":        {"message", queryText},
+	"message":    {"message", queryText},
+	"browser":    {"browser", queryText},
+	"os":         {"os", queryText},
+	"device":     {"device", queryText},
+	"bot":        {"is_bot", queryBool},
+	"is_bot":     {"is_bot", queryBool},
+	"uid":        {"uid", queryText},
+	"visitor":    {"visitor_id", queryText},
+	"visitor_id": {"visitor_id", queryText},
+	"note":       {"note", queryText},
+	"tag":        {"tags", queryTag},
+	"hits":       {"hit_count", queryNumber},
+	"hit_count":  {"hit_count", queryNumber},
+	"id":         {"id", queryNumber},
+	"status":     {"status", queryNumber},
+	"duration":   {"duration_ms", queryNumber},
+	"time":       {"created_at", queryTime},
+	"created_at": {"created_at", queryTime},
+}
+
+// Query : ParseQuery で読んだ検索式（LogFilter.Query に入れると WHERE に足す）
+type Query struct {
+	text string
+	root queryNode
+}
+
+func (q *Query) String() string { return q.text }
+
+// Match : 検索式にログが合うか（DBを通さずに判定する。通知のルーティングなど）
+func (q *Query) Match(e *model.LogEntry) bool { return q.root.match(e) }
+
+// queryNode : 検索式の木の1ノード
+type queryNode interface {
+	sql(b *whereBuilder) string
+	match(e *model.LogEntry) bool
+}
+
+type queryAnd struct{ left, right queryNode }
+
+func (n queryAnd) sql(b *whereBuilder) string {
+	return "(" + n.left.sql(b) + " AND " + n.right.sql(b) + ")"
+}
+
+func (n queryAnd) match(e *model.LogEntry) bool { return n.left.match(e) && n.right.match(e) }
+
+type queryOr struct{ left, right queryNode }
+
+func (n queryOr) match(e *model.LogEntry) bool { return n.left.match(e) || n.right.match(e) }
+
+func (n queryOr) sql(b *whereBuilder) string {
+	return "(" + n.left.sql(b) + " OR " + n.right.sql(b) + ")"
+}
+
+type queryNot struct{ node queryNode }
+
+func (n queryNot) match(e *model.LogEntry) bool { return !n.node.match(e) }
+
+func (n queryNot) sql(b *whereBuilder) string {
+	return "NOT COALESCE(" + n.node.sql(b) + ", false)"
+}
+
+// queryTerm : 項目 演算子 値 の1つ（値は読み取って型を揃えたもの）
+type queryTerm struct {
+	column queryColumn
+	key    string // field.<キー> のキー
+	op     string // ":" ":~" "!:" "!~" ">" ">=" "<" "<="
+	value  any
+	until  time.Time // created_at:2024-01-01 のように日付で一致させる時の翌日
+	raw    string    // 書かれた値（Match で使う）
+	levels []string  // level の条件に合うレベル
+}
+
+func (t queryTerm) sql(b *whereBuilder) string {
+	expr := t.column.expr
+	if t.column.kind == queryField {
+		expr = "fields->>" + b.arg(t.key)
+	}
+	switch t.column.kind {
+	case queryText, queryField:
+		if t.column.kind == queryField && t.op != ":" && t.op != "!:" && t.op != ":~" && t.op != "!~" {
+			return "logger_try_numeric(" + expr + ") " + t.op + " " + b.arg(t.value)
+		}
+		pattern, like := t.value.(likePattern)
+		switch {
+		case t.op == ":" && like:
+			return expr + " ILIKE " + b.arg(string(pattern))
+		case t.op == ":":
+			return expr + " = " + b.arg(t.value)
+		case t.op == "!:" && like:
+			return "COALESCE(" + expr + ", '') NOT ILIKE " + b.arg(string(pattern))
+		case t.op == "!:":
+			return expr + " IS DISTINCT FROM " + b.arg(t.value)
+		case t.op == ":~":
+			return expr + " ILIKE " + b.arg(string(pattern))
+		default: // "!~"
+			return "COALESCE(" + expr + ", '') NOT ILIKE " + b.arg(string(pattern))
+		}
+	case queryTime:
+		if !t.until.IsZero() {
+			in := "(" + expr + " >= " + b.arg(t.value) + " AND " + expr + " < " + b.arg(t.until) + ")"
+			if t.op == "!:" {
+				return "NOT " + in
+			}
+			return in
+		}
+		return expr + " " + sqlOp(t.op) + " " + b.arg(t.value)
+	case queryLevel:
+		return "level = ANY(" + b.arg(t.value) + ")"
+	case queryIP:
+		prefix := t.value.(netip.Prefix)
+		in := expr + " <<= " + b.arg(prefix.String()) + "::inet"
+		if t.op == "!:" {
+			return "NOT COALESCE(" + in + ", false)"
+		}
+		return in
+	case queryTag:
+		in := "tags @> ARRAY[" + b.arg(t.value) + "]::text[]"
+		if t.op == "!:" {
+			return "NOT " + in
+		}
+		return in
+	}
+	// queryNumber, queryBool
+	return expr + " " + sqlOp(t.op) + " " + b.arg(t.value)
+}
+
+// entryText : 文字列の列のログ上の値
+var entryText = map[string]func(e *model.LogEntry) string{
+	"user_agent": func(e *model.LogEntry) string { return e.UserAgent },
+	"country":    func(e *model.LogEntry) string { return e.Country },
+	"path":       func(e *model.LogEntry) string { return e.Path },
+	"referrer":   func(e *model.LogEntry) string { return e.Referrer },
+	"event_type": func(e *model.LogEntry) string { return e.EventType },
+	"message":    func(e *model.LogEntry) string { return e.Message },
+	"browser":    func(e *model.LogEntry) string { return e.Browser },
+	"os":         func(e *model.LogEntry) string { return e.OS },
+	"device":     func(e *model.LogEntry) string { return e.Device },
+	"uid":        func(e *model.LogEntry) string { return e.UID },
+	"visitor_id": func(e *model.LogEntry) string { return e.VisitorID },
+	"note":       func(e *model.LogEntry) string { return e.Note },
+}
+
+// entryField : fields->>key と同じ値（文字列はそのまま、それ以外は JSON の表記。なければ false）
+func entryField(e *model.LogEntry, key string) (string, bool) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(e.Fields, &fields) != nil {
+		return "", false
+	}
+	raw, ok := fields[key]
+	if !ok || string(raw) == "null" {
+		return "", false
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s, true
+	}
+	return string(raw), true
+}
+
+func (t queryTerm) match(e *model.LogEntry) bool {
+	switch t.column.kind {
+	case queryText, queryField:
+		var v string
+		if t.column.kind == queryField {
+			var ok bool
+			if v, ok = entryField(e, t.key); !ok && t.op != "!:" && t.op != "!~" {
+				return false
+			}
+		} else {
+			v = entryText[t.column.expr](e)
+		}
+		switch t.op {
+		case ":", "!:":
+			eq := v == t.raw
+			if strings.Contains(t.raw, "*") {
+				eq = globMatch(t.raw, v)
+			}
+			return eq == (t.op == ":")
+		case ":~", "!~":
+			// SQL の ILIKE '%値%' と同じく、値の * もワイルドカードにする
+			return globMatch("*"+t.raw+"*", v) == (t.op == ":~")
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		return err == nil && compare(n, t.value.(float64), t.op)
+	case queryTime:
+		at := e.CreatedAt
+		if !t.until.IsZero() {
+			in := !at.Before(t.value.(time.Time)) && at.Before(t.until)
+			return in == (t.op == ":")
+		}
+		return compare(float64(at.UnixNano()), float64(t.value.(time.Time).UnixNano()), t.op)
+	case queryNumber:
+		v := float64(e.HitCount)
+		switch t.column.expr {
+		case "id":
+			v = float64(e.ID)
+		case "status":
+			// 報告のないログ (NULL) は SQL と同じく !: にだけ一致する
+			if e.Status == 0 {
+				return t.op == "!:"
+			}
+			v = float64(e.Status)
+		case "duration_ms":
+			if e.DurationMS == nil {
+				return t.op == "!:"
+			}
+			v = *e.DurationMS
+		}
+		return compare(v, float64(t.value.(int64)), t.op)
+	case queryBool:
+		return (e.IsBot == t.value.(bool)) == (t.op == ":")
+	case queryLevel:
+		return slices.Contains(t.levels, e.Level)
+	case queryIP:
+		addr, err := netip.ParseAddr(e.IP)
+		in := err == nil && t.value.(netip.Prefix).Contains(addr.Unmap())
+		return in == (t.op == ":")
+	case queryTag:
+		return slices.Contains(e.Tags, t.value.(string)) == (t.op == ":")
+	}
+	return false
+}
+
+// compare : 数値を演算子で比べる
+func compare(a, b float64, op string) bool {
+	switch op {
+	case ":":
+		return a == b
+	case "!:":
+		return a != b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+// globMatch : * を任意の文字列として、大文字小文字を区別せずに比べる（ILIKE と同じ）
+func globMatch(pattern, s string) bool {
+	parts := strings.Split(strings.ToLower(pattern), "*")
+	s = strings.ToLower(s)
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 {
+			return strings.HasSuffix(s, part)
+		}
+		idx := strings.Index(s, part)
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(part):]
+	}
+	return s == ""
+}
+
+// sqlOp : 検索式の演算子 → SQL の比較演算子
+func sqlOp(op string) string {
+	switch op {
+	case ":":
+		return "="
+	case "!:":
+		return "IS DISTINCT FROM"
+	}
+	return op
+}
+
+// likePattern : ILIKE に渡す形にした値（% と _ はエスケープ済み）
+type likePattern string
+
+// escapeLike : ILIKE の特殊文字をエスケープし、* を % にする
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	return r.Replace(s)
+}
+
+// ParseQuery : 検索式を読む（書式の誤りは位置付きのエラー）
+func ParseQuery(text string) (*Query, error) {
+	if len(text) > maxQueryLength {
+		return nil, fmt.Errorf("query is longer than %d bytes", maxQueryLength)
+	}
+	p := &queryParser{src: []rune(text)}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", string(p.src[p.pos]))
+	}
+	if root == nil {
+		return nil, fmt.Errorf("query is empty")
+	}
+	return &Query{text: text, root: root}, nil
+}
+
+// queryParser : 検索式を先頭から読む
+type queryParser struct {
+	src   []rune
+	pos   int
+	terms int
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("query: "+format+" at position %d", append(args, p.pos+1)...)
+}
+
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// keyword : 次が AND / OR / NOT なら読み進めて true
+func (p *queryParser) keyword(word string) bool {
+	p.skipSpace()
+	end := p.pos + len(word)
+	if end > len(p.src) || !strings.EqualFold(string(p.src[p.pos:end]), word) {
+		return false
+	}
+	if end < len(p.src) && !unicode.IsSpace(p.src[end]) && p.src[end] != '(' {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if right == nil {
+			return nil, p.errorf("missing condition after OR")
+		}
+		left = queryOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil || left == nil {
+		return left, err
+	}
+	for {
+		explicit := p.keyword("AND")
+		p.skipSpace()
+		if !explicit && (p.pos >= len(p.src) || p.src[p.pos] == ')' || p.peekKeyword("OR")) {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if right == nil {
+			return nil, p.errorf("missing condition after AND")
+		}
+		left = queryAnd{left, right}
+	}
+}
+
+// peekKeyword : 読み進めずに次が word か確かめる
+func (p *queryParser) peekKeyword(word string) bool {
+	pos := p.pos
+	ok := p.keyword(word)
+	p.pos = pos
+	return ok
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] == ')' {
+		return nil, nil
+	}
+	negate := p.keyword("NOT")
+	if !negate && p.src[p.pos] == '-' {
+		negate = true
+		p.pos++
+	}
+	if negate {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			return nil, p.errorf("missing condition after NOT")
+		}
+		return queryNot{node}, nil
+	}
+	if p.src[p.pos] == '(' {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+			return nil, p.errorf("missing )")
+		}
+		if node == nil {
+			return nil, p.errorf("empty ()")
+		}
+		p.pos++
+		return node, nil
+	}
+	return p.parseTerm()
+}
+
+// queryOps : 長いものから順に試す
+var queryOps = []string{":~", "!~", "!:", "!=", ">=", "<=", ":", "=", "~", ">", "<"}
+
+func (p *queryParser) parseTerm() (queryNode, error) {
+	if p.terms++; p.terms > maxQueryTerms {
+		return nil, p.errorf("too many conditions (max %d)", maxQueryTerms)
+	}
+	start := p.pos
+	for p.pos < len(p.src) && (unicode.IsLetter(p.src[p.pos]) || unicode.IsDigit(p.src[p.pos]) || p.src[p.pos] == '_' || p.src[p.pos] == '.') {
+		p.pos++
+	}
+	name := strings.ToLower(string(p.src[start:p.pos]))
+	if name == "" {
+		return nil, p.errorf("expected a field name")
+	}
+	op := ""
+	for _, candidate := range queryOps {
+		if strings.HasPrefix(string(p.src[p.pos:]), candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		p.pos = start
+		return nil, p.errorf("expected field:value after %q", name)
+	}
+	p.pos += len([]rune(op))
+	switch op { // 別名
+	case "=":
+		op = ":"
+	case "!=":
+		op = "!:"
+	case "~":
+		op = ":~"
+	}
+	raw, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	term := queryTerm{op: op, raw: raw}
+	if key, ok := strings.CutPrefix(name, "field."); ok && key != "" {
+		term.column, term.key = queryColumn{kind: queryField}, key
+	} else if term.column, ok = queryColumns[name]; !ok {
+		p.pos = start
+		return nil, p.errorf("unknown field %q", name)
+	}
+	if err := term.setValue(raw); err != nil {
+		p.pos = start
+		return nil, p.errorf("%s: %v", name, err)
+	}
+	return term, nil
+}
+
+// parseValue : "…"（\" と \\ でエスケープ）か、空白・) までの値
+func (p *queryParser) parseValue() (string, error) {
+	if p.pos < len(p.src) && p.src[p.pos] == '"' {
+		p.pos++
+		var sb strings.Builder
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			p.pos++
+			switch {
+			case c == '\\' && p.pos < len(p.src):
+				sb.WriteRune(p.src[p.pos])
+				p.pos++
+			case c == '"':
+				return sb.String(), nil
+			default:
+				sb.WriteRune(c)
+			}
+		}
+		return "", p.errorf("missing closing quote")
+	}
+	start := p.pos
+	for p.pos < len(p.src) && !unicode.IsSpace(p.src[p.pos]) && p.src[p.pos] != ')' {
+		p.pos++
+	}
+	if start == p.pos {
+		return "", p.errorf("missing value")
+	}
+	return string(p.src[start:p.pos]), nil
+}
+
+// setValue : 項目の種類に合わせて値を読み、演算子が使えるか確かめる
+func (t *queryTerm) setValue(raw string) error {
+	ordered := t.op == ">" || t.op == ">=" || t.op == "<" || t.op == "<="
+	switch t.column.kind {
+	case queryText, queryField:
+		if ordered {
+			if t.column.kind == queryText {
+				return fmt.Errorf("%s is not supported (use : :~ !: !~)", t.op)
+			}
+			n, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return fmt.Errorf("%s needs a number", t.op)
+			}
+			t.value = n
+			return nil
+		}
+		switch {
+		case t.op == ":~" || t.op == "!~":
+			t.value = likePattern("%" + escapeLike(raw) + "%")
+		case strings.Contains(raw, "*"):
+			t.value = likePattern(escapeLike(raw))
+		default:
+			t.value = raw
+		}
+	case queryTime:
+		if t.op == ":~" || t.op == "!~" {
+			return fmt.Errorf("%s is not supported", t.op)
+		}
+		if day, err := time.Parse("2006-01-02", raw); err == nil {
+			t.value = day
+			if t.op == ":" || t.op == "!:" {
+				t.until = day.AddDate(0, 0, 1)
+			}
+			return nil
+		}
+		at, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return fmt.Errorf("use 2006-01-02 or RFC3339")
+		}
+		t.value = at
+	case queryNumber:
+		if t.op == ":~" || t.op == "!~" {
+			return fmt.Errorf("%s is not supported", t.op)
+		}
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("needs an integer")
+		}
+		t.value = n
+	case queryBool:
+		if t.op != ":" && t.op != "!:" {
+			return fmt.Errorf("%s is not supported (use : or !:)", t.op)
+		}
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("use true or false")
+		}
+		t.value = v
+	case queryLevel:
+		level, err := model.NormalizeLevel(raw)
+		if err != nil {
+			return err
+		}
+		rank := model.LevelRank(level)
+		var levels []string
+		for i, l := range model.LevelOrder {
+			if t.op == ":" && i == rank || t.op == "!:" && i != rank || t.op == ">" && i > rank ||
+				t.op == ">=" && i >= rank || t.op == "<" && i < rank || t.op == "<=" && i <= rank {
+				levels = append(levels, l)
+			}
+		}
+		if t.op == ":~" || t.op == "!~" {
+			return fmt.Errorf("%s is not supported", t.op)
+		}
+		t.levels = levels
+		t.value = pq.Array(levels)
+	case queryIP:
+		if t.op != ":" && t.op != "!:" {
+			return fmt.Errorf("%s is not supported (use : or !: with an address or CIDR)", t.op)
+		}
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(raw)
+			if addrErr != nil {
+				return fmt.Errorf("use an address or CIDR (e.g. 203.0.113.0/24)")
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		t.value = prefix.Masked()
+	case queryTag:
+		if t.op != ":" && t.op != "!:" {
+			return fmt.Errorf("%s is not supported (use : or !:)", t.op)
+		}
+		t.value = strings.ToLower(raw)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go-logger/internal/model"
+)
+
+// ilike : PostgreSQL の ILIKE と同じ規則で比べる（% は任意の文字列、_ は1文字、\ はエスケープ）
+func ilike(pattern, s string) bool {
+	var re strings.Builder
+	re.WriteString("(?is)^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '\\' && i+1 < len(runes):
+			i++
+			re.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case c == '%':
+			re.WriteString(".*")
+		case c == '_':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.MustCompile(re.String()).MatchString(s)
+}
+
+// sqlMatch : 文字列の項目1つの SQL の条件を、ILIKE / = の意味どおりに評価する（NULL は空文字として扱う）
+func sqlMatch(t *testing.T, term queryTerm, v string) bool {
+	t.Helper()
+	var b whereBuilder
+	cond := term.sql(&b)
+	value := b.args[len(b.args)-1]
+	switch {
+	case strings.Contains(cond, " NOT ILIKE "):
+		return !ilike(value.(string), v)
+	case strings.Contains(cond, " ILIKE "):
+		return ilike(value.(string), v)
+	case strings.Contains(cond, " IS DISTINCT FROM "):
+		return v != value.(string)
+	case strings.Contains(cond, " = "):
+		return v == value.(string)
+	}
+	t.Fatalf("unexpected condition %s", cond)
+	return false
+}
+
+// TestQueryTextMatchesSQL : 文字列の比較は、DBを通す検索と Match（ws のフィルタ・通知のルーティング）で結果が同じ
+func TestQueryTextMatchesSQL(t *testing.T) {
+	tests := []struct {
+		query string
+		ua    string
+		want  bool
+	}{
+		{query: "ua:~curl", ua: "curl/8.0", want: true},
+		{query: "ua:~CURL", ua: "curl/8.0", want: true},
+		{query: "ua~curl", ua: "Mozilla/5.0", want: false},
+		{query: "ua:~cu*l", ua: "curl/8.0", want: true},
+		{query: "ua:~cu*l", ua: "cu*l", want: true},
+		{query: "ua:~a*b", ua: "xxAzzBxx", want: true},
+		{query: "ua:~a*b", ua: "ba", want: false},
+		{query: "ua:~*", ua: "", want: true},
+		{query: "ua!~cu*l", ua: "curl/8.0", want: false},
+		{query: "ua!~cu*l", ua: "wget", want: true},
+		{query: "ua:~100%", ua: "100% bot", want: true},
+		{query: "ua:~100%", ua: "1000 bot", want: false},
+		{query: "ua:~a_b", ua: "a_b", want: true},
+		{query: "ua:~a_b", ua: "axb", want: false},
+		{query: `ua:~a\b`, ua: `a\b`, want: true},
+		{query: "ua:curl/*", ua: "CURL/8.0", want: true},
+		{query: "ua:curl/*", ua: "xcurl/8.0", want: false},
+		{query: "ua:curl", ua: "curl", want: true},
+		{query: "ua:curl", ua: "Curl", want: false},
+		{query: "ua!:curl/*", ua: "curl/8.0", want: false},
+		{query: "ua!:curl", ua: "wget", want: true},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", tt.query, err)
+		}
+		term, ok := q.root.(queryTerm)
+		if !ok {
+			t.Fatalf("ParseQuery(%q): root is %T", tt.query, q.root)
+		}
+		e := &model.LogEntry{UserAgent: tt.ua}
+		if got := q.Match(e); got != tt.want {
+			t.Errorf("%q Match(%q) = %v, want %v", tt.query, tt.ua, got, tt.want)
+		}
+		if got := sqlMatch(t, term, tt.ua); got != tt.want {
+			t.Errorf("%q SQL on %q = %v, want %v", tt.query, tt.ua, got, tt.want)
+		}
+	}
+}
+
+// TestQueryFieldMatchesSQL : field.<キー> も同じ。キーのないログは !: と !~ にだけ合う（SQL の COALESCE と同じ）
+func TestQueryFieldMatchesSQL(t *testing.T) {
+	tests := []struct {
+		query  string
+		fields string
+		want   bool
+	}{
+		{query: "field.plan:~pre*um", fields: `{"plan":"Premium"}`, want: true},
+		{query: "field.plan:~pro", fields: `{"plan":"free"}`, want: false},
+		{query: "field.plan:~pro", fields: `{}`, want: false},
+		{query: "field.plan!~pro", fields: `{}`, want: true},
+		{query: "field.plan!~pro", fields: `{"plan":"pro"}`, want: false},
+		{query: "field.order:~4*", fields: `{"order":42}`, want: true},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", tt.query, err)
+		}
+		e := &model.LogEntry{Fields: json.RawMessage(tt.fields)}
+		if got := q.Match(e); got != tt.want {
+			t.Errorf("%q Match(%s) = %v, want %v", tt.query, tt.fields, got, tt.want)
+		}
+		v, _ := entryField(e, q.root.(queryTerm).key)
+		if got := sqlMatch(t, q.root.(queryTerm), v); got != tt.want {
+			t.Errorf("%q SQL on %s = %v, want %v", tt.query, tt.fields, got, tt.want)
+		}
+	}
+}
+
+// TestParseQuery : 書式の誤り・使えない演算子はエラーになる
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr bool
+	}{
+		{query: "ua:~curl AND country:JP AND created_at>2024-01-01"},
+		{query: "(level>=warn OR status>=500) -bot:true"},
+		{query: `msg:"timed out"`},
+		{query: "ua>curl", wantErr: true},
+		{query: "hits:~3", wantErr: true},
+		{query: "bot>true", wantErr: true},
+		{query: "nosuch:1", wantErr: true},
+		{query: `msg:"open`, wantErr: true},
+		{query: "ua:", wantErr: true},
+		{query: "()", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.query)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseQuery(%q): err = %v, wantErr %v", tt.query, err, tt.wantErr)
+		}
+	}
+}
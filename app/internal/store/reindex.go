@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// reindex: 既存のイベントを変換し直して書き込む
+// ==========================================
+
+// Open : リトライせずに1回だけ接続する（reindex の書き込み先など）
+func (p *Postgres) Open(ctx context.Context) error {
+	conn, err := openDB(ctx, p.connStr)
+	if err != nil {
+		return err
+	}
+	p.swap(conn)
+	return nil
+}
+
+// Reindex : source の全イベントを id 順に読み、transform を通して dest に書き込む
+// dest が source と別のDBならスキーマを揃えてからプロジェクトをコピーする
+func Reindex(ctx context.Context, source, dest *Postgres, batch int, transform func(*model.Write)) error {
+	if dest != source {
+		if err := dest.Migrate(ctx); err != nil {
+			return fmt.Errorf("migrate target: %w", err)
+		}
+		if err := copyProjects(ctx, source.DB(), dest.DB()); err != nil {
+			return fmt.Errorf("copy projects: %w", err)
+		}
+	}
+
+	// id順にバッチで読み込み、変換して書き込む
+	total := 0
+	lastID := 0
+	for {
+		rows, err := source.DB().QueryContext(ctx,
+			"SELECT "+logColumns+" FROM access_logs WHERE id > $1 ORDER BY id LIMIT $2", lastID, batch)
+		if err != nil {
+			return err
+		}
+		var entries []model.LogEntry
+		for rows.Next() {
+			l, err := scanLogEntry(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			entries = append(entries, l)
+		}
+		rows.Close()
+		if len(entries) == 0 {
+			break
+		}
+
+		tx, err := dest.DB().BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, l := range entries {
+			w := model.WriteFromEntry(l)
+			transform(&w)
+			if err := upsertAccessLog(ctx, tx, w); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("write id %d: %w", l.ID, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		total += len(entries)
+		lastID = entries[len(entries)-1].ID
+		fmt.Printf("Reindexed %d events (last id %d)\n", total, lastID)
+	}
+
+	// 別のDBにidを指定して入れた場合はシーケンスを追いつかせる
+	if _, err := dest.DB().ExecContext(ctx,
+		"SELECT setval('access_logs_id_seq', GREATEST((SELECT MAX(id) FROM access_logs), 1))"); err != nil {
+		return fmt.Errorf("update sequence: %w", err)
+	}
+	fmt.Printf("Reindex complete: %d events\n", total)
+	return nil
+}
+
+// upsertAccessLog : idを保ったまま書き込み、既存の行はエンリッチ項目だけ更新する
+func upsertAccessLog(ctx context.Context, tx *sql.Tx, w model.Write) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO access_logs (id, project_id, user_agent, ip, path, referrer, event_type, level, message, fields, created_at,
+			browser, os, device, is_bot, country, uid, expires_at, hit_count, last_hit_at, visitor_id, sample_rate,
+			status, duration_ms, headers)
+		VALUES ($1, $2, $3, NULLIF($4, '')::inet, $5, NULLIF($6, ''), $7, NULLIF($8, ''), NULLIF($9, ''), $10, $11,
+			NULLIF($12, ''), NULLIF($13, ''), NULLIF($14, ''), $15, NULLIF($16, ''), NULLIF($17, ''), $18, GREATEST($19, 1), $20, NULLIF($21, ''), $22,
+			NULLIF($23, 0), $24, $25)
+		ON CONFLICT (id, created_at) DO UPDATE SET
+			browser = EXCLUDED.browser, os = EXCLUDED.os, device = EXCLUDED.device, is_bot = EXCLUDED.is_bot,
+			country = EXCLUDED.country, uid = COALESCE(access_logs.uid, EXCLUDED.uid)`,
+		w.ID, w.ProjectID, w.UserAgent, w.IP, w.Path, w.Referrer, w.EventType, w.Level, w.Message,
+		jsonParam(w.Fields), w.CreatedAt,
+		w.Browser, w.OS, w.Device, w.IsBot, w.Country, w.UID, nullableTime(w.ExpiresAt), w.HitCount, nullableTime(w.LastHitAt), w.VisitorID, w.Rate(),
+		w.Status, w.DurationMS, headersParam(w.Headers))
+	return err
+}
+
+// copyProjects : 外部キーのため、書き込み先にプロジェクトを揃える
+func copyProjects(ctx context.Context, source, dest *sql.DB) error {
+	rows, err := source.QueryContext(ctx, "SELECT id, name, api_key, created_at FROM projects ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p model.Project
+		if err := rows.Scan(&p.ID, &p.Name, &p.APIKey, &p.CreatedAt); err != nil {
+			return err
+		}
+		if _, err := dest.ExecContext(ctx,
+			"INSERT INTO projects (id, name, api_key, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (id) DO NOTHING",
+			p.ID, p.Name, p.APIKey, p.CreatedAt); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = dest.ExecContext(ctx, "SELECT setval('projects_id_seq', GREATEST((SELECT MAX(id) FROM projects), 1))")
+	return err
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// 読み出し専用のレプリカ (DB_REPLICA_DSN。ダッシュボード・集計・エクスポートの読み込みを書き込みと分ける)
+// ==========================================
+
+// replica : レプリカの接続（切れている間はプライマリから読む）
+type replica struct {
+	connStr string
+
+	mu      sync.RWMutex
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// ReplicaConnStrFromEnv : DB_REPLICA_DSN（未設定なら空）
+// key=value 形式でも URL 形式でも、セッションのタイムゾーンをプライマリと同じUTCにする
+func ReplicaConnStrFromEnv() string {
+	dsn := config.String("DB_REPLICA_DSN", "")
+	if dsn == "" || strings.Contains(dsn, "timezone=") {
+		return dsn
+	}
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("timezone", "UTC")
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " timezone=UTC"
+}
+
+// UseReplica : 読み出しにレプリカを使う（Connect の前に呼ぶ。空なら何もしない）
+func (p *Postgres) UseReplica(connStr string) {
+	if connStr == "" {
+		return
+	}
+	p.replica = &replica{connStr: connStr}
+}
+
+// HasReplica : レプリカを設定しているか
+func (p *Postgres) HasReplica() bool { return p.replica != nil }
+
+// ReadDB : 読み出し用の接続プール（レプリカが繋がっていればレプリカ、なければプライマリ）
+// 書き込み直後の行はレプリカの遅れの分だけ見えないことがある
+func (p *Postgres) ReadDB() *sql.DB {
+	if r := p.replica; r != nil && r.healthy.Load() {
+		r.mu.RLock()
+		db := r.db
+		r.mu.RUnlock()
+		if db != nil {
+			return db
+		}
+	}
+	return p.DB()
+}
+
+// connectReplica : レプリカに繋ぐ（失敗しても起動は止めず、Watch で繋ぎ直す）
+func (p *Postgres) connectReplica(ctx context.Context) {
+	r := p.replica
+	if r == nil {
+		return
+	}
+	conn, err := openDB(ctx, r.connStr)
+	if err != nil {
+		fmt.Println("Failed to connect to read replica (reading from primary):", err)
+		return
+	}
+	r.swap(conn)
+	fmt.Println("Success: Connected to read replica!")
+}
+
+// watchReplica : Watch の1回分。Ping が通らなければプライマリから読むように切り替え、繋ぎ直す
+func (p *Postgres) watchReplica(ctx context.Context) {
+	r := p.replica
+	if r == nil {
+		return
+	}
+	r.mu.RLock()
+	db := r.db
+	r.mu.RUnlock()
+	if db != nil {
+		pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		err := db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			r.healthy.Store(true)
+			return
+		}
+		if r.healthy.Swap(false) {
+			fmt.Println("Read replica connection lost (reading from primary):", err)
+		}
+	}
+	conn, err := openDB(ctx, r.connStr)
+	if err != nil {
+		return
+	}
+	r.swap(conn)
+	fmt.Println("Success: Reconnected to read replica!")
+}
+
+// swap : 新しいプールに差し替え、古いプールを閉じる
+func (r *replica) swap(conn *sql.DB) {
+	r.mu.Lock()
+	old := r.db
+	r.db = conn
+	r.mu.Unlock()
+	r.healthy.Store(true)
+	if old != nil {
+		old.Close()
+	}
+}
+
+// close : プールを閉じる
+func (r *replica) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.db != nil {
+		r.db.Close()
+		r.db = nil
+	}
+	r.healthy.Store(false)
+}
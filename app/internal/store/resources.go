@@ -0,0 +1,290 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/tracing"
+)
+
+// ==========================================
+// プロジェクト
+// ==========================================
+
+// ProjectIDByKey : APIキーからプロジェクトIDを引く（なければ ErrNotFound）
+func (p *Postgres) ProjectIDByKey(ctx context.Context, key string) (int, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	var id int
+	err := p.DB().QueryRowContext(ctx, "SELECT id FROM projects WHERE api_key = $1", key).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return id, err
+}
+
+// ListProjects : プロジェクト一覧（キーは含めない）
+func (p *Postgres) ListProjects(ctx context.Context) ([]model.Project, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT id, name, created_at, anonymize_ip, hash_user_agent, daily_quota, monthly_quota FROM projects ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []model.Project{}
+	for rows.Next() {
+		var pr model.Project
+		if err := rows.Scan(&pr.ID, &pr.Name, &pr.CreatedAt, &pr.Privacy.AnonymizeIP, &pr.Privacy.HashUserAgent, &pr.Quota.Daily, &pr.Quota.Monthly); err != nil {
+			return nil, err
+		}
+		projects = append(projects, pr)
+	}
+	return projects, rows.Err()
+}
+
+// ProjectKeys : プロジェクトID → APIキー（設定のエクスポート用）
+func (p *Postgres) ProjectKeys(ctx context.Context) (map[int]string, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT id, api_key FROM projects WHERE api_key IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := map[int]string{}
+	for rows.Next() {
+		var id int
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			return nil, err
+		}
+		keys[id] = key
+	}
+	return keys, rows.Err()
+}
+
+// CreateProject : 発行済みのキーでプロジェクトを作る
+func (p *Postgres) CreateProject(ctx context.Context, name, apiKey string) (model.Project, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	pr := model.Project{Name: name, APIKey: apiKey}
+	err := p.DB().QueryRowContext(ctx,
+		"INSERT INTO projects (name, api_key) VALUES ($1, $2) RETURNING id, created_at",
+		name, apiKey).Scan(&pr.ID, &pr.CreatedAt)
+	return pr, err
+}
+
+// RotateProjectKey : キーを差し替える（なければ ErrNotFound）
+func (p *Postgres) RotateProjectKey(ctx context.Context, id int, apiKey string) (model.Project, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	var pr model.Project
+	err := p.DB().QueryRowContext(ctx,
+		"UPDATE projects SET api_key = $1 WHERE id = $2 RETURNING id, name, api_key, created_at",
+		apiKey, id).Scan(&pr.ID, &pr.Name, &pr.APIKey, &pr.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return pr, ErrNotFound
+	}
+	return pr, err
+}
+
+// UpdateProjectPrivacy : プライバシー設定を置き換える（なければ ErrNotFound）
+func (p *Postgres) UpdateProjectPrivacy(ctx context.Context, id int, privacy model.ProjectPrivacy) (model.Project, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	var pr model.Project
+	err := p.DB().QueryRowContext(ctx,
+		"UPDATE projects SET anonymize_ip = $1, hash_user_agent = $2 WHERE id = $3 RETURNING id, name, created_at, anonymize_ip, hash_user_agent, daily_quota, monthly_quota",
+		privacy.AnonymizeIP, privacy.HashUserAgent, id).Scan(&pr.ID, &pr.Name, &pr.CreatedAt, &pr.Privacy.AnonymizeIP, &pr.Privacy.HashUserAgent, &pr.Quota.Daily, &pr.Quota.Monthly)
+	if errors.Is(err, sql.ErrNoRows) {
+		return pr, ErrNotFound
+	}
+	return pr, err
+}
+
+// UpdateProjectQuota : キーの上限を置き換える（なければ ErrNotFound）
+func (p *Postgres) UpdateProjectQuota(ctx context.Context, id int, quota model.ProjectQuota) (model.Project, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	var pr model.Project
+	err := p.DB().QueryRowContext(ctx,
+		"UPDATE projects SET daily_quota = $1, monthly_quota = $2 WHERE id = $3 RETURNING id, name, created_at, anonymize_ip, hash_user_agent, daily_quota, monthly_quota",
+		quota.Daily, quota.Monthly, id).Scan(&pr.ID, &pr.Name, &pr.CreatedAt, &pr.Privacy.AnonymizeIP, &pr.Privacy.HashUserAgent, &pr.Quota.Daily, &pr.Quota.Monthly)
+	if errors.Is(err, sql.ErrNoRows) {
+		return pr, ErrNotFound
+	}
+	return pr, err
+}
+
+// AddKeyUsage : 日ごとのリクエスト数を足し込む（各インスタンスが数えた分をまとめて書く）
+func (p *Postgres) AddKeyUsage(ctx context.Context, usage []model.KeyUsage) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	tx, err := p.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, u := range usage {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO key_usage (project_id, day, requests, rejected) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (project_id, day) DO UPDATE SET requests = key_usage.requests + EXCLUDED.requests, rejected = key_usage.rejected + EXCLUDED.rejected`,
+			u.ProjectID, u.Day.UTC().Format(time.DateOnly), u.Requests, u.Rejected); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// KeyUsage : since（UTC の日付）以降の日ごとのリクエスト数（古い順）
+func (p *Postgres) KeyUsage(ctx context.Context, projectID int, since time.Time) ([]model.KeyUsage, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx,
+		"SELECT project_id, day, requests, rejected FROM key_usage WHERE project_id = $1 AND day >= $2 ORDER BY day",
+		projectID, since.UTC().Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []model.KeyUsage{}
+	for rows.Next() {
+		var u model.KeyUsage
+		if err := rows.Scan(&u.ProjectID, &u.Day, &u.Requests, &u.Rejected); err != nil {
+			return nil, err
+		}
+		u.Day = u.Day.UTC()
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// ==========================================
+// 短縮リンク
+// ==========================================
+
+// LinkClickEventType : クリックを記録する時のイベント種別
+const LinkClickEventType = "link_click"
+
+// LinkBySlug : slug のリンクを返す（なければ ErrNotFound）
+func (p *Postgres) LinkBySlug(ctx context.Context, slug string) (model.ShortLink, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	l := model.ShortLink{Slug: slug}
+	err := p.DB().QueryRowContext(ctx,
+		"SELECT id, project_id, target_url, created_at FROM short_links WHERE slug = $1", slug).
+		Scan(&l.ID, &l.ProjectID, &l.TargetURL, &l.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return l, ErrNotFound
+	}
+	return l, err
+}
+
+// ListLinks : リンク一覧とクリック数
+func (p *Postgres) ListLinks(ctx context.Context) ([]model.ShortLink, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, `
+		SELECT l.id, l.project_id, l.slug, l.target_url, l.created_at,
+			(SELECT COUNT(*) FROM access_logs a
+				WHERE a.project_id = l.project_id AND a.event_type = $1 AND a.path = '/l/' || l.slug)
+		FROM short_links l ORDER BY l.id`, LinkClickEventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []model.ShortLink{}
+	for rows.Next() {
+		var l model.ShortLink
+		if err := rows.Scan(&l.ID, &l.ProjectID, &l.Slug, &l.TargetURL, &l.CreatedAt, &l.Clicks); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// CreateLink : リンクを作り、ID と作成日時を l に書き戻す
+func (p *Postgres) CreateLink(ctx context.Context, l *model.ShortLink) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	return p.DB().QueryRowContext(ctx,
+		"INSERT INTO short_links (project_id, slug, target_url) VALUES ($1, $2, $3) RETURNING id, created_at",
+		l.ProjectID, l.Slug, l.TargetURL).Scan(&l.ID, &l.CreatedAt)
+}
+
+// DeleteLink : リンクを削除する（なければ ErrNotFound）
+func (p *Postgres) DeleteLink(ctx context.Context, slug string) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM short_links WHERE slug = $1", slug)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ==========================================
+// 稼働監視
+// ==========================================
+
+// InsertCheck : 監視結果を保存し、ID を c に書き戻す
+func (p *Postgres) InsertCheck(ctx context.Context, c *model.CheckResult) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	const insertSQL = `INSERT INTO uptime_checks (check_name, status, latency_ms, region, checked_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	ctx, span := tracing.StartDBSpan(ctx, "INSERT", "uptime_checks", insertSQL)
+	err := p.DB().QueryRowContext(ctx, insertSQL, c.Check, c.Status, c.LatencyMS, c.Region, c.CheckedAt).Scan(&c.ID)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+// LastCheckStatus : 同じ監視対象・リージョンの直前の状態（初回なら空）
+func (p *Postgres) LastCheckStatus(ctx context.Context, check, region string) (string, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	var status string
+	err := p.DB().QueryRowContext(ctx,
+		"SELECT status FROM uptime_checks WHERE check_name = $1 AND region = $2 ORDER BY checked_at DESC LIMIT 1",
+		check, region).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return status, err
+}
+
+// LatestChecks : 監視対象ごとの最新状態
+func (p *Postgres) LatestChecks(ctx context.Context) ([]model.CheckResult, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	const selectSQL = `SELECT DISTINCT ON (check_name, region) id, check_name, status, COALESCE(latency_ms, 0), region, checked_at
+		FROM uptime_checks ORDER BY check_name, region, checked_at DESC`
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "uptime_checks", selectSQL)
+	rows, err := p.DB().QueryContext(ctx, selectSQL)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checks := []model.CheckResult{}
+	for rows.Next() {
+		var c model.CheckResult
+		if err := rows.Scan(&c.ID, &c.Check, &c.Status, &c.LatencyMS, &c.Region, &c.CheckedAt); err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
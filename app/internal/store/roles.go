@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// ロール（ロール付きのAPIキーとダッシュボードのユーザーのロール）
+// ==========================================
+
+// ListAPIKeys : ロール付きのAPIキーの一覧（キーのハッシュも含む。応答には出さない）
+func (p *Postgres) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT id, project_id, name, key_hash, roles, created_at FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []model.APIKey{}
+	for rows.Next() {
+		var k model.APIKey
+		if err := rows.Scan(&k.ID, &k.ProjectID, &k.Name, &k.KeyHash, (*pq.StringArray)(&k.Roles), &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// CreateAPIKey : キー (KeyHash) を登録し、ID と作成日時を k に書き戻す
+func (p *Postgres) CreateAPIKey(ctx context.Context, k *model.APIKey) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	return p.DB().QueryRowContext(ctx,
+		"INSERT INTO api_keys (project_id, name, key_hash, roles) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		k.ProjectID, k.Name, k.KeyHash, pq.StringArray(k.Roles)).Scan(&k.ID, &k.CreatedAt)
+}
+
+// DeleteAPIKey : キーを削除する（なければ ErrNotFound）
+func (p *Postgres) DeleteAPIKey(ctx context.Context, id int) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM api_keys WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListUserRoles : ロールを付けたユーザーの一覧
+func (p *Postgres) ListUserRoles(ctx context.Context) ([]model.UserRoles, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT username, roles, updated_at FROM user_roles ORDER BY username")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []model.UserRoles{}
+	for rows.Next() {
+		var u model.UserRoles
+		if err := rows.Scan(&u.User, (*pq.StringArray)(&u.Roles), &u.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// PutUserRoles : ユーザーのロールを置き換え（なければ作り）、更新日時を u に書き戻す
+func (p *Postgres) PutUserRoles(ctx context.Context, u *model.UserRoles) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	return p.DB().QueryRowContext(ctx, `INSERT INTO user_roles (username, roles) VALUES ($1, $2)
+		ON CONFLICT (username) DO UPDATE SET roles = EXCLUDED.roles, updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at`, u.User, pq.StringArray(u.Roles)).Scan(&u.UpdatedAt)
+}
+
+// DeleteUserRoles : ユーザーのロールを外す（DASHBOARD_DEFAULT_ROLES に戻る。なければ ErrNotFound）
+func (p *Postgres) DeleteUserRoles(ctx context.Context, user string) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM user_roles WHERE username = $1", user)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
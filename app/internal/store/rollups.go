@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"go-logger/internal/model"
+	"go-logger/internal/tracing"
+)
+
+// ==========================================
+// 集計済みの件数 (0025_create_rollups.sql)
+// 時間ごと・日ごとの件数をプロジェクト × 種別 × レベル × 国 × ブラウザで持ち、長い期間の集計で生のログを読まずに済ませる
+// ==========================================
+
+// rollupName : rollup_state の行
+const rollupName = "hourly"
+
+// minRollupRange : これより短い期間は生のログから数える
+const minRollupRange = 24 * time.Hour
+
+// rollupColumns : GroupLogs で集計済みの件数から数えられる列
+var rollupColumns = map[string]string{
+	"EVENT_TYPE": "event_type",
+	"LEVEL":      "level",
+	"COUNTRY":    "country",
+	"BROWSER":    "browser",
+}
+
+// RollupWatermark : どこまで集計したか（まだ集計していなければゼロ）
+// 集計済みの件数と同じDBから読む（レプリカなら、レプリカに届いている集計の範囲）
+func (p *Postgres) RollupWatermark(ctx context.Context) (time.Time, error) {
+	var t time.Time
+	err := p.ReadDB().QueryRowContext(ctx, "SELECT rolled_until FROM rollup_state WHERE name = $1", rollupName).Scan(&t)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return t.UTC(), err
+}
+
+// OldestLogTime : いちばん古いログの時刻（ログがなければゼロ）
+func (p *Postgres) OldestLogTime(ctx context.Context) (time.Time, error) {
+	var t sql.NullTime
+	err := p.DB().QueryRowContext(ctx, "SELECT MIN(created_at) FROM access_logs").Scan(&t)
+	return t.Time.UTC(), err
+}
+
+// RollUp : [from, to) の時間ごとの件数を生のログから作り直し、その期間を含む日の件数も作り直す
+// ユニーク訪問者のスケッチ (rollUpUniques) も同じ範囲で作り直す
+// 終わったら to までを集計済みにする（時刻は毎時0分に切り捨てる）
+func (p *Postgres) RollUp(ctx context.Context, from, to time.Time) error {
+	from, to = from.UTC().Truncate(time.Hour), to.UTC().Truncate(time.Hour)
+	if !to.After(from) {
+		return nil
+	}
+	dayFrom, dayTo := from.Truncate(24*time.Hour), ceilDay(to)
+
+	tx, err := p.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	steps := []struct {
+		op, table, query string
+		args             []any
+	}{
+		{"DELETE", "access_rollups_hourly", "DELETE FROM access_rollups_hourly WHERE bucket >= $1 AND bucket < $2", []any{from, to}},
+		{"INSERT", "access_rollups_hourly", `INSERT INTO access_rollups_hourly (bucket, project_id, event_type, level, country, browser, hits, estimated_hits)
+			SELECT date_trunc('hour', created_at), project_id, event_type, COALESCE(level, ''), COALESCE(country, ''), COALESCE(browser, ''), COUNT(*), SUM(1 / sample_rate)
+			FROM access_logs WHERE created_at >= $1 AND created_at < $2 AND deleted_at IS NULL AND ` + longTermCondition + `
+			GROUP BY 1, 2, 3, 4, 5, 6`, []any{from, to}},
+		{"DELETE", "access_rollups_daily", "DELETE FROM access_rollups_daily WHERE bucket >= $1 AND bucket < $2", []any{dayFrom, dayTo}},
+		{"INSERT", "access_rollups_daily", `INSERT INTO access_rollups_daily (bucket, project_id, event_type, level, country, browser, hits, estimated_hits)
+			SELECT date_trunc('day', bucket), project_id, event_type, level, country, browser, SUM(hits), SUM(estimated_hits)
+			FROM access_rollups_hourly WHERE bucket >= $1 AND bucket < $2
+			GROUP BY 1, 2, 3, 4, 5, 6`, []any{dayFrom, dayTo}},
+		{"INSERT", "rollup_state", `INSERT INTO rollup_state (name, rolled_until) VALUES ($1, $2)
+			ON CONFLICT (name) DO UPDATE SET rolled_until = GREATEST(rollup_state.rolled_until, EXCLUDED.rolled_until)`, []any{rollupName, to}},
+	}
+	for _, step := range steps {
+		stepCtx, span := tracing.StartDBSpan(ctx, step.op, step.table, step.query)
+		_, err := tx.ExecContext(stepCtx, step.query, step.args...)
+		tracing.EndSpan(span, err)
+		if err != nil {
+			return err
+		}
+	}
+	if err := rollUpUniques(ctx, tx, from, to, dayFrom, dayTo); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ceilDay : 日の初め (UTC) に切り上げる
+func ceilDay(t time.Time) time.Time {
+	day := t.Truncate(24 * time.Hour)
+	if day.Before(t) {
+		day = day.Add(24 * time.Hour)
+	}
+	return day
+}
+
+// ceilHour : 毎時0分に切り上げる
+func ceilHour(t time.Time) time.Time {
+	hour := t.Truncate(time.Hour)
+	if hour.Before(t) {
+		hour = hour.Add(time.Hour)
+	}
+	return hour
+}
+
+// rollupCoverage : 集計済みの件数で数える範囲 [from, until)（from がゼロなら最初から）
+type rollupCoverage struct {
+	from, until time.Time
+}
+
+// coverage : f の期間のうち集計済みの件数で数えられる範囲（使えなければ false）
+// 集計済みの列以外で絞り込む場合と、期間が minRollupRange より短い場合は使わない
+// rawSince 以降（直近の件数を別に数える場合など）は生のログから数える
+func (p *Postgres) coverage(ctx context.Context, f LogFilter, rawSince time.Time) (rollupCoverage, bool) {
+	var c rollupCoverage
+	if f.UID != "" || len(f.Fields) > 0 || len(f.Tags) > 0 || f.Query != nil || f.Search != "" || f.BeforeID > 0 || f.AfterID > 0 {
+		return c, false
+	}
+	// 集計していない・マイグレーション前の場合は生のログから数える
+	watermark, err := p.RollupWatermark(ctx)
+	if err != nil || watermark.IsZero() {
+		return c, false
+	}
+	c.until = watermark
+	if !rawSince.IsZero() && rawSince.UTC().Truncate(time.Hour).Before(c.until) {
+		c.until = rawSince.UTC().Truncate(time.Hour)
+	}
+	if !f.Until.IsZero() && f.Until.UTC().Truncate(time.Hour).Before(c.until) {
+		c.until = f.Until.UTC().Truncate(time.Hour)
+	}
+	if !f.Since.IsZero() {
+		c.from = ceilHour(f.Since.UTC())
+		if c.until.Sub(c.from) < minRollupRange {
+			return c, false
+		}
+	}
+	return c, true
+}
+
+// exclude : 集計済みの件数で数えた分を生のログの条件から除く
+// 集計に含めない有効期限付きのログは範囲内でも生のログから数える
+func (c rollupCoverage) exclude(b *whereBuilder) {
+	if c.from.IsZero() {
+		b.add("(created_at >= ? OR expires_at IS NOT NULL)", c.until)
+		return
+	}
+	b.add("(created_at < ? OR created_at >= ? OR expires_at IS NOT NULL)", c.from, c.until)
+}
+
+// rollupCounts : 範囲 c の集計済みの件数を keys の列ごとに合計し、1行ずつ each に渡す（件数は最後の列）
+// daily なら丸1日の分は日ごとの表から、端の時間は時間ごとの表から読む
+func (p *Postgres) rollupCounts(ctx context.Context, f LogFilter, c rollupCoverage, keys string, daily bool, each func(rows *sql.Rows) error) error {
+	var b whereBuilder
+	b.add("project_id = ?", f.ProjectID)
+	if f.EventType != "" {
+		b.addEventType(f.EventType)
+	}
+	if f.MinLevel != "" {
+		b.add("level = ANY(?)", pq.Array(model.LevelsAtLeast(f.MinLevel)))
+	}
+	filter := strings.Join(b.conds, " AND ")
+	from, until := "", b.arg(c.until)
+	if !c.from.IsZero() {
+		from = b.arg(c.from)
+	}
+
+	var parts []string
+	dayFrom, dayUntil := ceilDay(c.from), c.until.Truncate(24*time.Hour)
+	if daily && (c.from.IsZero() || dayFrom.Before(dayUntil)) {
+		dayUntilArg := b.arg(dayUntil)
+		days := "bucket < " + dayUntilArg
+		edges := "(bucket >= " + dayUntilArg + " AND bucket < " + until + ")"
+		if !c.from.IsZero() {
+			dayFromArg := b.arg(dayFrom)
+			days += " AND bucket >= " + dayFromArg
+			edges = "((bucket >= " + from + " AND bucket < " + dayFromArg + ") OR " + edges + ")"
+		}
+		parts = append(parts,
+			"SELECT * FROM access_rollups_daily WHERE "+filter+" AND "+days,
+			"SELECT * FROM access_rollups_hourly WHERE "+filter+" AND "+edges)
+	} else {
+		hours := "bucket < " + until
+		if !c.from.IsZero() {
+			hours += " AND bucket >= " + from
+		}
+		parts = append(parts, "SELECT * FROM access_rollups_hourly WHERE "+filter+" AND "+hours)
+	}
+	hits := "SUM(hits)::bigint"
+	if f.Extrapolate {
+		hits = "ROUND(SUM(estimated_hits))::bigint"
+	}
+	selectSQL := "SELECT " + keys + ", " + hits + " FROM (" + strings.Join(parts, " UNION ALL ") + ") r GROUP BY " + keys
+
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_rollups", selectSQL)
+	rows, err := p.ReadDB().QueryContext(ctx, selectSQL, b.args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := each(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
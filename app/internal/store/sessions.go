@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/tracing"
+)
+
+// ==========================================
+// セッション (同じ訪問者のアクセスを、間隔が gap 以内なら1つのセッションにまとめる)
+// ==========================================
+
+// visitorKey : 訪問者の見分け方（訪問者クッキーのIDがあればそれ、なければ IP + UA）
+const visitorKey = "COALESCE('v:' || visitor_id, host(ip) || ' ' || COALESCE(user_agent, ''))"
+
+// SessionStats : f の範囲のアクセスを訪問者ごとに並べ、前のアクセスから gap を超えたら新しいセッションとして数える
+// ボットと、IP も訪問者IDもないログは含めない。まとめたアクセス (hit_count) は last_hit_at までを長さに含める
+// f.Since があれば、それより前にも来ていた訪問者IDを再訪問者として数える
+func (p *Postgres) SessionStats(ctx context.Context, f LogFilter, gap time.Duration) (*model.SessionStats, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	b := p.logFilter(f)
+	b.add("is_bot IS NOT TRUE")
+	b.add("(visitor_id IS NOT NULL OR ip IS NOT NULL)")
+	gapArg := b.arg(fmt.Sprintf("%d seconds", int64(gap.Seconds())))
+	returning := "0"
+	if !f.Since.IsZero() {
+		returning = `(SELECT COUNT(DISTINCT visitor_id) FROM numbered WHERE visitor_id IS NOT NULL AND EXISTS (
+			SELECT 1 FROM access_logs o WHERE o.project_id = ` + b.arg(f.ProjectID) + ` AND o.visitor_id = numbered.visitor_id
+				AND o.created_at < ` + b.arg(f.Since) + `))`
+	}
+	selectSQL := `WITH hits AS (
+		SELECT ` + visitorKey + ` AS visitor, visitor_id, created_at, COALESCE(last_hit_at, created_at) AS last_at, hit_count,
+			CASE WHEN LAG(created_at) OVER w IS NULL
+				OR created_at - LAG(COALESCE(last_hit_at, created_at)) OVER w > ` + gapArg + `::interval
+			THEN 1 ELSE 0 END AS starts
+		FROM access_logs` + b.where() + `
+		WINDOW w AS (PARTITION BY ` + visitorKey + ` ORDER BY created_at)
+	), numbered AS (
+		SELECT visitor, visitor_id, created_at, last_at, hit_count,
+			SUM(starts) OVER (PARTITION BY visitor ORDER BY created_at) AS session
+		FROM hits
+	), sessions AS (
+		SELECT visitor, MAX(visitor_id) AS visitor_id, EXTRACT(EPOCH FROM MAX(last_at) - MIN(created_at))::float8 AS seconds,
+			SUM(hit_count) AS hits
+		FROM numbered GROUP BY visitor, session
+	)
+	SELECT COUNT(*), COUNT(DISTINCT visitor), COUNT(DISTINCT visitor_id), ` + returning + `, COALESCE(AVG(seconds), 0),
+		COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY seconds), 0), COUNT(*) FILTER (WHERE hits = 1)
+	FROM sessions`
+
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+	s := &model.SessionStats{GapSeconds: int(gap.Seconds())}
+	err := p.ReadDB().QueryRowContext(ctx, selectSQL, b.args...).Scan(
+		&s.Sessions, &s.Visitors, &s.CookieVisitors, &s.ReturningVisitors,
+		&s.AvgDurationSeconds, &s.MedianDurationSeconds, &s.Bounces)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 実行中に変えられる設定
+// ==========================================
+
+// ListSettings : 上書きしている設定の一覧
+func (p *Postgres) ListSettings(ctx context.Context) ([]model.Setting, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT key, value, updated_at FROM settings ORDER BY key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := []model.Setting{}
+	for rows.Next() {
+		var st model.Setting
+		if err := rows.Scan(&st.Key, &st.Value, &st.UpdatedAt); err != nil {
+			return nil, err
+		}
+		settings = append(settings, st)
+	}
+	return settings, rows.Err()
+}
+
+// PutSetting : 設定を上書きする
+func (p *Postgres) PutSetting(ctx context.Context, key, value string) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	_, err := p.DB().ExecContext(ctx, `INSERT INTO settings (key, value) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = CURRENT_TIMESTAMP`, key, value)
+	return err
+}
+
+// DeleteSetting : 上書きをやめて環境変数の値に戻す（上書きしていなくてもエラーにしない）
+func (p *Postgres) DeleteSetting(ctx context.Context, key string) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	_, err := p.DB().ExecContext(ctx, "DELETE FROM settings WHERE key = $1", key)
+	return err
+}
@@ -0,0 +1,231 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 整合性のあるスナップショット (バックアップ用)
+// ==========================================
+
+// snapshotTables : 出力するテーブル（外部キーの親から順に）
+var snapshotTables = []string{"projects", "short_links", "notification_channels", "alert_rules", "exclusions", "ip_rules", "watchlist", "dead_letters", "uptime_checks", "access_logs", "alerts"}
+
+// Snapshot : 全テーブルの全行を w に書き出す
+// REPEATABLE READ の読み取り専用トランザクション内で読むので、出力中に書き込みがあっても
+// 全テーブルが同じ時点の内容になる（逐次読み出しのエクスポートとは違い、途中の書き込みが混ざらない）
+func (p *Postgres) Snapshot(ctx context.Context, w SnapshotWriter) error {
+	tx, err := p.ReadDB().BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// スナップショットの時点は最初のクエリで決まるので、時刻もトランザクション内で取る
+	var at time.Time
+	if err := tx.QueryRowContext(ctx, "SELECT now()").Scan(&at); err != nil {
+		return err
+	}
+	if err := w.Begin(at.UTC(), snapshotTables); err != nil {
+		return err
+	}
+
+	// projects（復元に使えるようAPIキーも含める）
+	if err := eachRow(ctx, tx, "SELECT id, name, api_key, created_at FROM projects ORDER BY id", func(rows *sql.Rows) error {
+		var pr model.Project
+		if err := rows.Scan(&pr.ID, &pr.Name, &pr.APIKey, &pr.CreatedAt); err != nil {
+			return err
+		}
+		return w.Row("projects", pr)
+	}); err != nil {
+		return fmt.Errorf("projects: %w", err)
+	}
+
+	// short_links
+	if err := eachRow(ctx, tx, "SELECT id, project_id, slug, target_url, created_at FROM short_links ORDER BY id", func(rows *sql.Rows) error {
+		var l model.ShortLink
+		if err := rows.Scan(&l.ID, &l.ProjectID, &l.Slug, &l.TargetURL, &l.CreatedAt); err != nil {
+			return err
+		}
+		return w.Row("short_links", l)
+	}); err != nil {
+		return fmt.Errorf("short_links: %w", err)
+	}
+
+	// notification_channels（復元に使えるよう Webhook URL やトークンも含める）
+	if err := eachRow(ctx, tx, "SELECT "+channelColumns+" FROM notification_channels ORDER BY id", func(rows *sql.Rows) error {
+		c, err := scanChannel(rows)
+		if err != nil {
+			return err
+		}
+		return w.Row("notification_channels", c)
+	}); err != nil {
+		return fmt.Errorf("notification_channels: %w", err)
+	}
+
+	// alert_rules
+	if err := eachRow(ctx, tx, "SELECT "+alertRuleColumns+" FROM alert_rules ORDER BY id", func(rows *sql.Rows) error {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			return err
+		}
+		return w.Row("alert_rules", r)
+	}); err != nil {
+		return fmt.Errorf("alert_rules: %w", err)
+	}
+
+	// exclusions
+	if err := eachRow(ctx, tx, "SELECT id, field, pattern, scope, note, created_at FROM exclusions ORDER BY id", func(rows *sql.Rows) error {
+		var e model.Exclusion
+		if err := rows.Scan(&e.ID, &e.Field, &e.Pattern, &e.Scope, &e.Note, &e.CreatedAt); err != nil {
+			return err
+		}
+		return w.Row("exclusions", e)
+	}); err != nil {
+		return fmt.Errorf("exclusions: %w", err)
+	}
+
+	// ip_rules
+	if err := eachRow(ctx, tx, "SELECT id, cidr, action, note, created_at FROM ip_rules ORDER BY id", func(rows *sql.Rows) error {
+		var r model.IPRule
+		if err := rows.Scan(&r.ID, &r.CIDR, &r.Action, &r.Note, &r.CreatedAt); err != nil {
+			return err
+		}
+		return w.Row("ip_rules", r)
+	}); err != nil {
+		return fmt.Errorf("ip_rules: %w", err)
+	}
+
+	// watchlist
+	if err := eachRow(ctx, tx, "SELECT id, field, pattern, note, created_at FROM watchlist ORDER BY id", func(rows *sql.Rows) error {
+		var wt model.Watch
+		if err := rows.Scan(&wt.ID, &wt.Field, &wt.Pattern, &wt.Note, &wt.CreatedAt); err != nil {
+			return err
+		}
+		return w.Row("watchlist", wt)
+	}); err != nil {
+		return fmt.Errorf("watchlist: %w", err)
+	}
+
+	// dead_letters
+	if err := eachRow(ctx, tx, "SELECT id, queue, payload, attempts, last_error, created_at FROM dead_letters ORDER BY id", func(rows *sql.Rows) error {
+		var d model.DeadLetter
+		if err := rows.Scan(&d.ID, &d.Queue, (*[]byte)(&d.Payload), &d.Attempts, &d.LastError, &d.CreatedAt); err != nil {
+			return err
+		}
+		return w.Row("dead_letters", d)
+	}); err != nil {
+		return fmt.Errorf("dead_letters: %w", err)
+	}
+
+	// uptime_checks
+	if err := eachRow(ctx, tx, "SELECT id, check_name, status, COALESCE(latency_ms, 0), region, checked_at FROM uptime_checks ORDER BY id", func(rows *sql.Rows) error {
+		var c model.CheckResult
+		if err := rows.Scan(&c.ID, &c.Check, &c.Status, &c.LatencyMS, &c.Region, &c.CheckedAt); err != nil {
+			return err
+		}
+		return w.Row("uptime_checks", c)
+	}); err != nil {
+		return fmt.Errorf("uptime_checks: %w", err)
+	}
+
+	// access_logs
+	if err := eachRow(ctx, tx, "SELECT "+logColumns+" FROM access_logs ORDER BY id", func(rows *sql.Rows) error {
+		l, err := scanLogEntry(rows)
+		if err != nil {
+			return err
+		}
+		return w.Row("access_logs", l)
+	}); err != nil {
+		return fmt.Errorf("access_logs: %w", err)
+	}
+
+	// alerts
+	if err := eachRow(ctx, tx, "SELECT "+alertColumns+" FROM alerts ORDER BY id", func(rows *sql.Rows) error {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return err
+		}
+		return w.Row("alerts", a)
+	}); err != nil {
+		return fmt.Errorf("alerts: %w", err)
+	}
+	return nil
+}
+
+// eachRow : クエリ結果の各行に fn を呼ぶ
+func eachRow(ctx context.Context, tx *sql.Tx, query string, fn func(*sql.Rows) error) error {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ==========================================
+// データ量の計測
+// ==========================================
+
+// StorageUsage : テーブルごとの行数と、データ・索引のサイズ（access_logs の月ごとのパーティションは access_logs にまとめる）
+func (p *Postgres) StorageUsage(ctx context.Context) (model.StorageUsage, error) {
+	var u model.StorageUsage
+	if err := p.DB().QueryRowContext(ctx, "SELECT pg_database_size(current_database())").Scan(&u.DatabaseBytes); err != nil {
+		return u, err
+	}
+
+	rows, err := p.DB().QueryContext(ctx, `
+		SELECT COALESCE(parent.relname, s.relname), SUM(s.n_live_tup)::bigint,
+			SUM(pg_table_size(s.relid))::bigint, SUM(pg_indexes_size(s.relid))::bigint, SUM(pg_total_relation_size(s.relid))::bigint,
+			COUNT(i.inhparent)
+		FROM pg_stat_user_tables s
+		LEFT JOIN pg_inherits i ON i.inhrelid = s.relid
+		LEFT JOIN pg_class parent ON parent.oid = i.inhparent
+		GROUP BY 1 ORDER BY 5 DESC`)
+	if err != nil {
+		return u, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t model.TableStorage
+		if err := rows.Scan(&t.Table, &t.Rows, &t.TableBytes, &t.IndexBytes, &t.TotalBytes, &t.Partitions); err != nil {
+			return u, err
+		}
+		u.Tables = append(u.Tables, t)
+	}
+	return u, rows.Err()
+}
+
+// SampleVolume : 現在の行数・サイズを取得する
+func (p *Postgres) SampleVolume(ctx context.Context) (model.VolumeSample, error) {
+	s := model.VolumeSample{SampledAt: p.clock.Now()}
+	if err := p.DB().QueryRowContext(ctx, "SELECT pg_database_size(current_database())").Scan(&s.DatabaseBytes); err != nil {
+		return s, err
+	}
+
+	rows, err := p.DB().QueryContext(ctx, `
+		SELECT relname, n_live_tup, pg_total_relation_size(relid)
+		FROM pg_stat_user_tables ORDER BY pg_total_relation_size(relid) DESC`)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t model.TableVolume
+		if err := rows.Scan(&t.Table, &t.Rows, &t.Bytes); err != nil {
+			return s, err
+		}
+		s.Tables = append(s.Tables, t)
+	}
+	return s, rows.Err()
+}
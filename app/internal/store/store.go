@@ -0,0 +1,275 @@
+// Package store : ログ・プロジェクト・短縮リンク・監視結果の保存先
+// ハンドラはこのインターフェース越しに読み書きするので、テストでは偽の実装に差し替えられる
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-logger/internal/model"
+)
+
+var (
+	// ErrNotFound : 該当する行がない
+	ErrNotFound = errors.New("not found")
+	// ErrUnavailable : 再接続中で書き込みをすぐには保存できない
+	ErrUnavailable = errors.New("database is reconnecting")
+	// ErrBufferFull : 再接続中かつバッファが一杯で書き込みを受け付けられない
+	ErrBufferFull = fmt.Errorf("%w (write buffer full)", ErrUnavailable)
+)
+
+// SaveResult : SaveLog の結果
+type SaveResult int
+
+const (
+	Stored   SaveResult = iota // 保存済み（IDが採番されている）
+	Buffered                   // 再接続中のためバッファに退避した（復旧後に書き戻す）
+)
+
+// RetentionPolicy : PurgeExpired で保存期間を過ぎたとみなす境目
+type RetentionPolicy struct {
+	OlderThan time.Time       // Tiers に当てはまらないログの境目（ゼロなら期限付きのログだけ削除する）
+	Tiers     []RetentionTier // 先にあるものを優先する
+}
+
+// RetentionTier : プロジェクト・種別ごとの境目
+type RetentionTier struct {
+	ProjectID int       // 0 なら全てのプロジェクト
+	EventType string    // 空なら全種別（"custom:*" のように末尾が * なら前方一致）
+	OlderThan time.Time // ゼロなら削除しない
+}
+
+// olderThan : ログに当てはまる境目
+func (p RetentionPolicy) olderThan(projectID int, eventType string) time.Time {
+	for _, t := range p.Tiers {
+		if (t.ProjectID == 0 || t.ProjectID == projectID) && model.EventTypeMatches(t.EventType, eventType) {
+			return t.OlderThan
+		}
+	}
+	return p.OlderThan
+}
+
+// PartitionCutoff : 丸ごと削除してよいパーティションの境目（どの境目よりも前。削除しないものがあればゼロ）
+func (p RetentionPolicy) PartitionCutoff() time.Time {
+	cutoff := p.OlderThan
+	for _, t := range p.Tiers {
+		if t.OlderThan.IsZero() || cutoff.IsZero() {
+			return time.Time{}
+		}
+		if t.OlderThan.Before(cutoff) {
+			cutoff = t.OlderThan
+		}
+	}
+	return cutoff
+}
+
+// LogFilter : ログの絞り込み条件（ゼロ値の項目は条件にしない）
+type LogFilter struct {
+	ProjectID int
+	EventType string
+	UID       string
+	MinLevel  string            // 正規化済みのレベル（このレベル以上）
+	Fields    map[string]string // fields のキー → 値
+	Search    string            // 全文検索（websearch形式）
+	Since     time.Time
+	Until     time.Time
+	Tags      []string  // 全てのタグが付いているもの
+	Query     *Query    // 検索式（ParseQuery）
+	BeforeID  int       // このidより古いもの
+	AfterID   int       // このidより新しいもの（古い順のページング）
+	CursorAt  time.Time // Sort が created_at の時のページの位置（BeforeID / AfterID と組にして (created_at, id) で比べる）
+	Limit     int       // 0なら既定の件数
+	Sort      string    // QueryLogs で並べる列（SortID / SortCreatedAt、空なら id）
+	Ascending bool      // QueryLogs で古い順に返す（既定は新しい順）
+	Trash     bool      // ゴミ箱のログだけ（既定はゴミ箱のログを除く）
+
+	Extrapolate bool // 件数を抽出率で割り戻す（SAMPLE_RATE で間引いて保存した分を推定する）
+}
+
+// QueryLogs で並べる列 (LogFilter.Sort)
+const (
+	SortID        = "id"
+	SortCreatedAt = "created_at" // 同じ時刻は id の順
+)
+
+// SnapshotWriter : Snapshot の出力先
+type SnapshotWriter interface {
+	Begin(at time.Time, tables []string) error
+	Row(table string, row any) error
+}
+
+// Store : 保存先の操作一式
+type Store interface {
+	// ログ
+	SaveLog(ctx context.Context, w *model.Write) (SaveResult, error)
+	SaveLogs(ctx context.Context, ws []*model.Write) (result SaveResult, n int, err error)
+	QueryLogs(ctx context.Context, f LogFilter) ([]model.LogEntry, error)
+	SearchLogs(ctx context.Context, f LogFilter) ([]model.SearchResult, error)
+	CountLogs(ctx context.Context, f LogFilter) (int, error)
+	CountDistinct(ctx context.Context, f LogFilter, column string) (int, error)
+	EstimateCount(ctx context.Context, f LogFilter, distinct string) (n int, exact bool, err error)
+	GroupLogs(ctx context.Context, f LogFilter, groupBy string) ([]model.Bucket, error)
+	HourlyCounts(ctx context.Context, f LogFilter) (map[time.Time]int, error)
+	Stats(ctx context.Context, f LogFilter, recentSince time.Time) (*model.Stats, error)
+	SessionStats(ctx context.Context, f LogFilter, gap time.Duration) (*model.SessionStats, error)
+	UniqueVisitors(ctx context.Context, f LogFilter) (*model.UniqueVisitors, error)
+	LatencyStats(ctx context.Context, f LogFilter) (*model.LatencyStats, error)
+	Timeseries(ctx context.Context, f LogFilter, interval time.Duration, origin time.Time) ([]model.TimePoint, error)
+	PurgeExpired(ctx context.Context, now time.Time, policy RetentionPolicy) (int64, error)
+	ExpiredLogs(ctx context.Context, now time.Time, policy RetentionPolicy, limit int) ([]model.LogEntry, error)
+	DeleteLogs(ctx context.Context, ids []int) (int64, error)
+	LogByID(ctx context.Context, id int) (model.LogEntry, error)
+	TrashLog(ctx context.Context, id int, at time.Time) (model.LogEntry, error)
+	RestoreLog(ctx context.Context, id int) (model.LogEntry, error)
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+	IncrementHits(ctx context.Context, id int, createdAt, at time.Time) (int, error)
+	AnnotateLog(ctx context.Context, id int, a LogAnnotation) (model.LogEntry, error)
+	EnsurePartitions(ctx context.Context, now time.Time, monthsAhead int) ([]string, error)
+	DropPartitionsBefore(ctx context.Context, olderThan time.Time) (int64, error)
+	RollupWatermark(ctx context.Context) (time.Time, error)
+	OldestLogTime(ctx context.Context) (time.Time, error)
+	RollUp(ctx context.Context, from, to time.Time) error
+
+	// プロジェクト
+	ProjectIDByKey(ctx context.Context, key string) (int, error)
+	ListProjects(ctx context.Context) ([]model.Project, error)
+	ProjectKeys(ctx context.Context) (map[int]string, error)
+	CreateProject(ctx context.Context, name, apiKey string) (model.Project, error)
+	RotateProjectKey(ctx context.Context, id int, apiKey string) (model.Project, error)
+	UpdateProjectPrivacy(ctx context.Context, id int, privacy model.ProjectPrivacy) (model.Project, error)
+	UpdateProjectQuota(ctx context.Context, id int, quota model.ProjectQuota) (model.Project, error)
+	AddKeyUsage(ctx context.Context, usage []model.KeyUsage) error
+	KeyUsage(ctx context.Context, projectID int, since time.Time) ([]model.KeyUsage, error)
+
+	// 短縮リンク
+	LinkBySlug(ctx context.Context, slug string) (model.ShortLink, error)
+	ListLinks(ctx context.Context) ([]model.ShortLink, error)
+	CreateLink(ctx context.Context, l *model.ShortLink) error
+	DeleteLink(ctx context.Context, slug string) error
+
+	// 通知先
+	ListChannels(ctx context.Context) ([]model.Channel, error)
+	ChannelByID(ctx context.Context, id int) (model.Channel, error)
+	CreateChannel(ctx context.Context, c *model.Channel) error
+	UpdateChannel(ctx context.Context, c *model.Channel) error
+	DeleteChannel(ctx context.Context, id int) error
+
+	// Webhook
+	ListWebhooks(ctx context.Context) ([]model.Webhook, error)
+	WebhookByID(ctx context.Context, id int) (model.Webhook, error)
+	CreateWebhook(ctx context.Context, wh *model.Webhook) error
+	UpdateWebhook(ctx context.Context, wh *model.Webhook) error
+	DeleteWebhook(ctx context.Context, id int) error
+	RecordWebhookDelivery(ctx context.Context, id int, deliveryErr error, at time.Time, maxFailures int) (bool, error)
+
+	// アラートの履歴
+	InsertAlert(ctx context.Context, a *model.Alert) error
+	ListAlerts(ctx context.Context, since time.Time, limit int) ([]model.Alert, error)
+	AlertByID(ctx context.Context, id int) (model.Alert, error)
+	AcknowledgeAlert(ctx context.Context, id int, by string, at time.Time) (model.Alert, error)
+	MuteAlertKey(ctx context.Context, key string, until time.Time, by string) error
+	UnmuteAlertKey(ctx context.Context, key string) error
+	ActiveMutes(ctx context.Context, now time.Time) (map[string]time.Time, error)
+
+	// アラートルール
+	ListAlertRules(ctx context.Context) ([]model.AlertRule, error)
+	AlertRuleByID(ctx context.Context, id int) (model.AlertRule, error)
+	CreateAlertRule(ctx context.Context, r *model.AlertRule) error
+	UpdateAlertRule(ctx context.Context, r *model.AlertRule) error
+	DeleteAlertRule(ctx context.Context, id int) error
+	MarkAlertRuleFired(ctx context.Context, id int, at time.Time) error
+
+	// 除外パターン
+	ListExclusions(ctx context.Context) ([]model.Exclusion, error)
+	CreateExclusion(ctx context.Context, e *model.Exclusion) error
+	DeleteExclusion(ctx context.Context, id int) error
+
+	// 監視リスト
+	ListWatches(ctx context.Context) ([]model.Watch, error)
+	CreateWatch(ctx context.Context, w *model.Watch) error
+	DeleteWatch(ctx context.Context, id int) error
+
+	// 通知のルーティング
+	ListNotifyRoutes(ctx context.Context) ([]model.NotifyRoute, error)
+	NotifyRouteByID(ctx context.Context, id int) (model.NotifyRoute, error)
+	CreateNotifyRoute(ctx context.Context, r *model.NotifyRoute) error
+	UpdateNotifyRoute(ctx context.Context, r *model.NotifyRoute) error
+	DeleteNotifyRoute(ctx context.Context, id int) error
+
+	// 実行中に変えられる設定
+	ListSettings(ctx context.Context) ([]model.Setting, error)
+	PutSetting(ctx context.Context, key, value string) error
+	DeleteSetting(ctx context.Context, key string) error
+
+	// IPアドレスの許可・拒否リスト
+	ListIPRules(ctx context.Context) ([]model.IPRule, error)
+	CreateIPRule(ctx context.Context, r *model.IPRule) error
+	DeleteIPRule(ctx context.Context, id int) error
+
+	// ロール（ロール付きのAPIキーとダッシュボードのユーザーのロール）
+	ListAPIKeys(ctx context.Context) ([]model.APIKey, error)
+	CreateAPIKey(ctx context.Context, k *model.APIKey) error
+	DeleteAPIKey(ctx context.Context, id int) error
+	ListUserRoles(ctx context.Context) ([]model.UserRoles, error)
+	PutUserRoles(ctx context.Context, u *model.UserRoles) error
+	DeleteUserRoles(ctx context.Context, user string) error
+
+	// 処理できなかったキューの中身と、再接続待ちの書き込み
+	ListDeadLetters(ctx context.Context) ([]model.DeadLetter, error)
+	InsertDeadLetter(ctx context.Context, d *model.DeadLetter) error
+	DeadLetterByID(ctx context.Context, id int) (*model.DeadLetter, error)
+	DeleteDeadLetter(ctx context.Context, id int) error
+	BufferedWrites(ctx context.Context) (int, error)
+
+	// 非同期の書き出し
+	CreateExportJob(ctx context.Context, j *model.ExportJob) error
+	ExportJobByID(ctx context.Context, id int) (*model.ExportJob, error)
+	ClaimExportJob(ctx context.Context, instance string, now time.Time) (*model.ExportJob, error)
+	FinishExportJob(ctx context.Context, j *model.ExportJob) error
+	RequeueExportJobs(ctx context.Context, instance string) (int64, error)
+	DeleteExpiredExportJobs(ctx context.Context, instance string, now time.Time) ([]model.ExportJob, error)
+
+	// 書き込みの Idempotency-Key
+	ClaimIdempotencyKey(ctx context.Context, key, requestHash string, now, expiresAt time.Time) (resp IdempotentResponse, claimed bool, err error)
+	CompleteIdempotencyKey(ctx context.Context, key string, resp IdempotentResponse, expiresAt time.Time) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+
+	// 通知を1回だけ送る印 (NOTIFY_DEDUP=database)
+	ClaimNotificationKey(ctx context.Context, key, claimedBy string, now, expiresAt time.Time) (bool, error)
+	PurgeNotificationKeys(ctx context.Context, now time.Time) (int64, error)
+
+	// 本人からの開示・削除の依頼
+	SubjectLogs(ctx context.Context, sub Subject) ([]model.LogEntry, error)
+	DeleteSubjectLogs(ctx context.Context, sub Subject, audit *model.PrivacyRequest) error
+	InsertPrivacyRequest(ctx context.Context, r *model.PrivacyRequest) error
+	ListPrivacyRequests(ctx context.Context, subjectHash string, limit int) ([]model.PrivacyRequest, error)
+
+	// 稼働監視
+	InsertCheck(ctx context.Context, c *model.CheckResult) error
+	LastCheckStatus(ctx context.Context, check, region string) (string, error)
+	LatestChecks(ctx context.Context) ([]model.CheckResult, error)
+
+	// 管理
+	Snapshot(ctx context.Context, w SnapshotWriter) error
+	SampleVolume(ctx context.Context) (model.VolumeSample, error)
+	StorageUsage(ctx context.Context) (model.StorageUsage, error)
+}
+
+// GroupByColumns : GroupLogs で集計できる列
+var GroupByColumns = map[string]string{
+	"EVENT_TYPE": "event_type",
+	"LEVEL":      "COALESCE(level, '')",
+	"COUNTRY":    "COALESCE(country, '')",
+	"PATH":       "COALESCE(path, '')",
+	"USER_AGENT": "COALESCE(user_agent, '')",
+	"IP":         "COALESCE(host(ip), '')",
+	"BROWSER":    "COALESCE(browser, '')",
+	"OS":         "COALESCE(os, '')",
+	"DEVICE":     "COALESCE(device, '')",
+	// 参照元のホスト名（先頭の www. を除く）と、クエリ・フラグメントを除いたページ。参照元のないアクセスは ""
+	"REFERRER_DOMAIN": `COALESCE(regexp_replace(lower(substring(referrer from '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^@/]*@)?([^/:?#]+)')), '^www\.', ''), '')`,
+	"REFERRER":        "COALESCE(split_part(split_part(referrer, '?', 1), '#', 1), '')",
+}
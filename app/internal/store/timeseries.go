@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"go-logger/internal/model"
+	"go-logger/internal/tracing"
+)
+
+// ==========================================
+// 区間ごとの件数 (グラフ用。件数0の区間も埋めて返す)
+// ==========================================
+
+// Timeseries : f.Since から f.Until までを interval ごとに区切った件数
+// 区間の境目は origin から interval ずつ進めた時刻（origin を現地の0時にすると、日ごとの区間が現地の日付になる）
+func (p *Postgres) Timeseries(ctx context.Context, f LogFilter, interval time.Duration, origin time.Time) ([]model.TimePoint, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	if f.Since.IsZero() || f.Until.IsZero() || interval < time.Second {
+		return nil, errors.New("timeseries needs since, until and an interval of at least 1s")
+	}
+	step := int64(interval / time.Second)
+	slot := func(t time.Time) int64 {
+		return int64(math.Floor(float64(t.Unix()-origin.Unix()) / float64(step)))
+	}
+	first, last := slot(f.Since), slot(f.Until.Add(-time.Nanosecond))
+
+	b := p.logFilter(f)
+	originArg, stepArg := b.arg(origin.Unix()), b.arg(step)
+	selectSQL := `WITH counts AS (
+		SELECT floor((extract(epoch FROM created_at) - ` + originArg + `) / ` + stepArg + `)::bigint AS slot, ` + countExpr(f, "") + ` AS n
+		FROM access_logs` + b.where() + ` GROUP BY slot
+	)
+	SELECT s.slot, COALESCE(c.n, 0) FROM generate_series(` + b.arg(first) + `::bigint, ` + b.arg(last) + `::bigint) AS s(slot)
+	LEFT JOIN counts c ON c.slot = s.slot ORDER BY s.slot`
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+	rows, err := p.ReadDB().QueryContext(ctx, selectSQL, b.args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := make([]model.TimePoint, 0, last-first+1)
+	for rows.Next() {
+		var s int64
+		var n int
+		if err := rows.Scan(&s, &n); err != nil {
+			return nil, err
+		}
+		points = append(points, model.TimePoint{Start: origin.Add(time.Duration(s*step) * time.Second), Count: n})
+	}
+	return points, rows.Err()
+}
@@ -0,0 +1,216 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go-logger/internal/hll"
+	"go-logger/internal/model"
+	"go-logger/internal/tracing"
+)
+
+// ==========================================
+// ユニーク訪問者の見積もり (0045_create_unique_rollups.sql)
+// ==========================================
+// 長い期間の COUNT(DISTINCT) は重いので、集計のジョブが時間ごと・日ごとの HyperLogLog のスケッチを作っておき、
+// 読み出す時はスケッチを合わせて見積もる（集計していない端の時間は生のログのハッシュを加える）
+
+// visitorHash : 訪問者のハッシュ（スケッチに加える値）
+const visitorHash = "hashtextextended(" + visitorKey + ", 0)"
+
+// visitorCondition : ユニーク訪問者として数えるログ（SessionStats と同じ）
+const visitorCondition = "is_bot IS NOT TRUE AND (visitor_id IS NOT NULL OR ip IS NOT NULL)"
+
+// uniqueBucket : スケッチ1つ分の時間とプロジェクト
+type uniqueBucket struct {
+	bucket    time.Time
+	projectID int
+}
+
+// rollUpUniques : RollUp のトランザクションの中で [from, to) の時間ごとのスケッチを作り直し、[dayFrom, dayTo) の日ごとのスケッチを合わせ直す
+func rollUpUniques(ctx context.Context, tx *sql.Tx, from, to, dayFrom, dayTo time.Time) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM access_uniques_hourly WHERE bucket >= $1 AND bucket < $2", from, to); err != nil {
+		return err
+	}
+	const hashSQL = `SELECT date_trunc('hour', created_at), project_id, ` + visitorHash + `
+		FROM access_logs WHERE created_at >= $1 AND created_at < $2 AND deleted_at IS NULL AND ` + longTermCondition + ` AND ` + visitorCondition + `
+		GROUP BY 1, 2, 3`
+	hourly := map[uniqueBucket]*hll.Sketch{}
+	err := eachSketchRow(ctx, tx, hashSQL, []any{from, to}, func(key uniqueBucket, rows *sql.Rows) error {
+		var hash int64
+		if err := rows.Scan(&key.bucket, &key.projectID, &hash); err != nil {
+			return err
+		}
+		sketchFor(hourly, key).Add(uint64(hash))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := insertSketches(ctx, tx, "access_uniques_hourly", hourly); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM access_uniques_daily WHERE bucket >= $1 AND bucket < $2", dayFrom, dayTo); err != nil {
+		return err
+	}
+	daily := map[uniqueBucket]*hll.Sketch{}
+	err = eachSketchRow(ctx, tx, "SELECT date_trunc('day', bucket), project_id, sketch FROM access_uniques_hourly WHERE bucket >= $1 AND bucket < $2",
+		[]any{dayFrom, dayTo}, func(key uniqueBucket, rows *sql.Rows) error {
+			var b []byte
+			if err := rows.Scan(&key.bucket, &key.projectID, &b); err != nil {
+				return err
+			}
+			return mergeSketch(sketchFor(daily, key), b)
+		})
+	if err != nil {
+		return err
+	}
+	return insertSketches(ctx, tx, "access_uniques_daily", daily)
+}
+
+// eachSketchRow : query の結果を1行ずつ each に渡す
+func eachSketchRow(ctx context.Context, tx *sql.Tx, query string, args []any, each func(key uniqueBucket, rows *sql.Rows) error) error {
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_uniques", query)
+	rows, err := tx.QueryContext(ctx, query, args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := each(uniqueBucket{}, rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// sketchFor : key のスケッチ（なければ作る）
+func sketchFor(sketches map[uniqueBucket]*hll.Sketch, key uniqueBucket) *hll.Sketch {
+	s, ok := sketches[key]
+	if !ok {
+		s = hll.New()
+		sketches[key] = s
+	}
+	return s
+}
+
+// mergeSketch : 保存したスケッチ b を s に合わせる
+func mergeSketch(s *hll.Sketch, b []byte) error {
+	var o hll.Sketch
+	if err := o.UnmarshalBinary(b); err != nil {
+		return err
+	}
+	s.Merge(&o)
+	return nil
+}
+
+// insertSketches : スケッチを table に書く
+func insertSketches(ctx context.Context, tx *sql.Tx, table string, sketches map[uniqueBucket]*hll.Sketch) error {
+	for key, s := range sketches {
+		b, _ := s.MarshalBinary()
+		if _, err := tx.ExecContext(ctx, "INSERT INTO "+table+" (bucket, project_id, sketch) VALUES ($1, $2, $3)", key.bucket, key.projectID, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UniqueVisitors : f の期間のユニーク訪問者の数
+// プロジェクトと期間だけで絞り、期間が集計済みの範囲にかかる場合はスケッチから見積もる（それ以外は数える）
+func (p *Postgres) UniqueVisitors(ctx context.Context, f LogFilter) (*model.UniqueVisitors, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	b := p.logFilter(f)
+	b.add(visitorCondition)
+
+	c, ok := p.coverage(ctx, f, time.Time{})
+	if !ok || f.EventType != "" || f.MinLevel != "" || f.Trash {
+		selectSQL := "SELECT COUNT(DISTINCT " + visitorKey + ") FROM access_logs" + b.where()
+		ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+		u := &model.UniqueVisitors{Method: model.UniquesExact}
+		err := p.ReadDB().QueryRowContext(ctx, selectSQL, b.args...).Scan(&u.Visitors)
+		tracing.EndSpan(span, err)
+		return u, err
+	}
+
+	sketch := hll.New()
+	for _, r := range c.sketchRanges() {
+		query, args := "SELECT sketch FROM "+r.table+" WHERE project_id = $1 AND bucket < $2", []any{f.ProjectID, r.until}
+		if !r.from.IsZero() {
+			query, args = query+" AND bucket >= $3", append(args, r.from)
+		}
+		if err := p.mergeSketches(ctx, sketch, query, args); err != nil {
+			return nil, err
+		}
+	}
+	// 集計していない端の時間と、スケッチに含めない有効期限付きのログは生のログから加える
+	c.exclude(b)
+	selectSQL := "SELECT DISTINCT " + visitorHash + " FROM access_logs" + b.where()
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_logs", selectSQL)
+	rows, err := p.ReadDB().QueryContext(ctx, selectSQL, b.args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash int64
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		sketch.Add(uint64(hash))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &model.UniqueVisitors{Visitors: sketch.Estimate(), Method: model.UniquesHLL, StandardError: hll.StandardError}, nil
+}
+
+// mergeSketches : query で読んだスケッチを全て s に合わせる
+func (p *Postgres) mergeSketches(ctx context.Context, s *hll.Sketch, query string, args []any) error {
+	ctx, span := tracing.StartDBSpan(ctx, "SELECT", "access_uniques", query)
+	rows, err := p.ReadDB().QueryContext(ctx, query, args...)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b []byte
+		if err := rows.Scan(&b); err != nil {
+			return err
+		}
+		if err := mergeSketch(s, b); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// sketchRange : スケッチを読む表と範囲 [from, until)（from がゼロなら最初から）
+type sketchRange struct {
+	table       string
+	from, until time.Time
+}
+
+// sketchRanges : 範囲 c のうち、丸1日の分は日ごとの表、端の時間は時間ごとの表から読む
+func (c rollupCoverage) sketchRanges() []sketchRange {
+	dayFrom, dayUntil := ceilDay(c.from), c.until.Truncate(24*time.Hour)
+	if c.from.IsZero() {
+		return []sketchRange{
+			{"access_uniques_daily", time.Time{}, dayUntil},
+			{"access_uniques_hourly", dayUntil, c.until},
+		}
+	}
+	if !dayFrom.Before(dayUntil) {
+		return []sketchRange{{"access_uniques_hourly", c.from, c.until}}
+	}
+	return []sketchRange{
+		{"access_uniques_hourly", c.from, dayFrom},
+		{"access_uniques_daily", dayFrom, dayUntil},
+		{"access_uniques_hourly", dayUntil, c.until},
+	}
+}
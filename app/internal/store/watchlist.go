@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// 監視リスト (怪しいIPアドレス・UA)
+// ==========================================
+
+// ListWatches : 監視リストの一覧
+func (p *Postgres) ListWatches(ctx context.Context) ([]model.Watch, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT id, field, pattern, note, created_at FROM watchlist ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	watches := []model.Watch{}
+	for rows.Next() {
+		var w model.Watch
+		if err := rows.Scan(&w.ID, &w.Field, &w.Pattern, &w.Note, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		watches = append(watches, w)
+	}
+	return watches, rows.Err()
+}
+
+// CreateWatch : 監視リストに足し、ID と作成日時を w に書き戻す
+func (p *Postgres) CreateWatch(ctx context.Context, w *model.Watch) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	return p.DB().QueryRowContext(ctx,
+		"INSERT INTO watchlist (field, pattern, note) VALUES ($1, $2, $3) RETURNING id, created_at",
+		w.Field, w.Pattern, w.Note).Scan(&w.ID, &w.CreatedAt)
+}
+
+// DeleteWatch : 監視リストから外す（なければ ErrNotFound）
+func (p *Postgres) DeleteWatch(ctx context.Context, id int) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM watchlist WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// Webhook
+// ==========================================
+
+const webhookColumns = `id, name, COALESCE(project_id, 0), url, secret, event_types, min_level, enabled,
+	failures, last_error, last_delivery_at, disabled_at, created_at, updated_at`
+
+func scanWebhook(row rowScanner) (model.Webhook, error) {
+	var wh model.Webhook
+	var eventTypes string
+	err := row.Scan(&wh.ID, &wh.Name, &wh.ProjectID, &wh.URL, &wh.Secret, &eventTypes, &wh.MinLevel, &wh.Enabled,
+		&wh.Failures, &wh.LastError, &wh.LastDeliveryAt, &wh.DisabledAt, &wh.CreatedAt, &wh.UpdatedAt)
+	if eventTypes != "" {
+		wh.EventTypes = strings.Split(eventTypes, ",")
+	}
+	return wh, err
+}
+
+// nullableProjectID : 0 は全てのプロジェクト (NULL)
+func nullableProjectID(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// ListWebhooks : Webhook の一覧（停止中のものも含む）
+func (p *Postgres) ListWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	rows, err := p.DB().QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []model.Webhook{}
+	for rows.Next() {
+		wh, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, wh)
+	}
+	return webhooks, rows.Err()
+}
+
+// WebhookByID : Webhook を返す（なければ ErrNotFound）
+func (p *Postgres) WebhookByID(ctx context.Context, id int) (model.Webhook, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	wh, err := scanWebhook(p.DB().QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return wh, ErrNotFound
+	}
+	return wh, err
+}
+
+// CreateWebhook : Webhook を作り、ID と作成日時を wh に書き戻す
+func (p *Postgres) CreateWebhook(ctx context.Context, wh *model.Webhook) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	return p.DB().QueryRowContext(ctx,
+		`INSERT INTO webhooks (name, project_id, url, secret, event_types, min_level, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at`,
+		wh.Name, nullableProjectID(wh.ProjectID), wh.URL, wh.Secret, strings.Join(wh.EventTypes, ","), wh.MinLevel, wh.Enabled,
+	).Scan(&wh.ID, &wh.CreatedAt, &wh.UpdatedAt)
+}
+
+// UpdateWebhook : wh.ID の Webhook を wh の内容で上書きする（なければ ErrNotFound）
+// 有効にした時は失敗の回数を0に戻す
+func (p *Postgres) UpdateWebhook(ctx context.Context, wh *model.Webhook) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	err := p.DB().QueryRowContext(ctx,
+		`UPDATE webhooks SET name = $1, project_id = $2, url = $3, secret = $4, event_types = $5, min_level = $6, enabled = $7,
+			failures = CASE WHEN $7 AND NOT enabled THEN 0 ELSE failures END,
+			disabled_at = CASE WHEN $7 THEN NULL ELSE disabled_at END,
+			updated_at = $8
+		WHERE id = $9 RETURNING failures, disabled_at, created_at, updated_at`,
+		wh.Name, nullableProjectID(wh.ProjectID), wh.URL, wh.Secret, strings.Join(wh.EventTypes, ","), wh.MinLevel, wh.Enabled,
+		p.clock.Now(), wh.ID).Scan(&wh.Failures, &wh.DisabledAt, &wh.CreatedAt, &wh.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// DeleteWebhook : Webhook を削除する（なければ ErrNotFound）
+func (p *Postgres) DeleteWebhook(ctx context.Context, id int) error {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	res, err := p.DB().ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordWebhookDelivery : 送った結果を記録する（届けば失敗の回数を0に戻す）
+// 続けて maxFailures 回失敗したら止め、止めた時だけ true を返す
+func (p *Postgres) RecordWebhookDelivery(ctx context.Context, id int, deliveryErr error, at time.Time, maxFailures int) (bool, error) {
+	ctx, cancel := p.opContext(ctx)
+	defer cancel()
+	if deliveryErr == nil {
+		_, err := p.DB().ExecContext(ctx,
+			"UPDATE webhooks SET failures = 0, last_error = '', last_delivery_at = $1 WHERE id = $2", at, id)
+		return false, err
+	}
+	var disabled bool
+	err := p.DB().QueryRowContext(ctx,
+		`UPDATE webhooks w SET failures = w.failures + 1, last_error = $1,
+			enabled = w.enabled AND w.failures + 1 < $2,
+			disabled_at = CASE WHEN w.enabled AND w.failures + 1 >= $2 THEN $3 ELSE w.disabled_at END
+		FROM (SELECT enabled FROM webhooks WHERE id = $4) old
+		WHERE w.id = $4 RETURNING old.enabled AND NOT w.enabled`,
+		deliveryErr.Error(), maxFailures, at, id).Scan(&disabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrNotFound
+	}
+	return disabled, err
+}
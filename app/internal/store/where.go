@@ -0,0 +1,128 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"go-logger/internal/model"
+)
+
+// whereBuilder : 動的なWHERE句とパラメータを組み立てる
+// 条件の "?" は追加順に $1, $2 ... へ置き換わる
+type whereBuilder struct {
+	conds []string
+	args  []any
+}
+
+// add : 条件を1つ追加する
+func (b *whereBuilder) add(cond string, args ...any) {
+	for _, a := range args {
+		b.args = append(b.args, a)
+		cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(b.args)), 1)
+	}
+	b.conds = append(b.conds, cond)
+}
+
+// arg : 条件以外（LIMIT など）で使うパラメータを追加し、プレースホルダを返す
+func (b *whereBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// addEventType : event_type の条件を追加する（"custom:*" のように末尾が * なら前方一致）
+func (b *whereBuilder) addEventType(eventType string) {
+	if prefix, ok := model.EventTypePrefix(eventType); ok {
+		b.add("event_type LIKE ?", escapeLike(prefix)+"%")
+		return
+	}
+	b.add("event_type = ?", eventType)
+}
+
+// where : " WHERE a AND b"（条件がなければ空）
+func (b *whereBuilder) where() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conds, " AND ")
+}
+
+// logFilter : LogFilter を access_logs の条件にする
+func (p *Postgres) logFilter(f LogFilter) *whereBuilder {
+	var b whereBuilder
+	b.add("project_id = ?", f.ProjectID)
+	if f.Trash {
+		b.add("deleted_at IS NOT NULL")
+	} else {
+		b.add("deleted_at IS NULL")
+	}
+	if f.EventType != "" {
+		b.addEventType(f.EventType)
+	}
+	if f.UID != "" {
+		b.add("uid = ?", f.UID)
+	}
+	if f.MinLevel != "" {
+		b.add("level = ANY(?)", pq.Array(model.LevelsAtLeast(f.MinLevel)))
+	}
+	for name, value := range f.Fields {
+		p.fieldFilter(&b, name, value)
+	}
+	if len(f.Tags) > 0 {
+		b.add("tags @> ?", pq.Array(f.Tags))
+	}
+	if f.Query != nil {
+		b.conds = append(b.conds, f.Query.root.sql(&b))
+	}
+	if f.Search != "" {
+		b.add("search_vector @@ websearch_to_tsquery('simple', ?)", f.Search)
+	}
+	if !f.Since.IsZero() {
+		b.add("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		b.add("created_at < ?", f.Until)
+	}
+	if f.byTimeCursor() {
+		if f.BeforeID > 0 {
+			b.add("(created_at, id) < (?, ?)", f.CursorAt, f.BeforeID)
+		}
+		if f.AfterID > 0 {
+			b.add("(created_at, id) > (?, ?)", f.CursorAt, f.AfterID)
+		}
+	} else {
+		if f.BeforeID > 0 {
+			b.add("id < ?", f.BeforeID)
+		}
+		if f.AfterID > 0 {
+			b.add("id > ?", f.AfterID)
+		}
+	}
+	return &b
+}
+
+// byTimeCursor : ページの位置を (created_at, id) で比べるか（created_at で並べた一覧の続き）
+func (f LogFilter) byTimeCursor() bool {
+	return f.Sort == SortCreatedAt && !f.CursorAt.IsZero()
+}
+
+// orderBy : QueryLogs の ORDER BY（created_at で並べる場合も同じ時刻は id の順にして、ページの境目を決める）
+func (f LogFilter) orderBy() string {
+	dir := " DESC"
+	if f.Ascending {
+		dir = " ASC"
+	}
+	if f.Sort == SortCreatedAt {
+		return "created_at" + dir + ", id" + dir
+	}
+	return "id" + dir
+}
+
+// limitOr : 件数の指定がなければ既定値
+func limitOr(limit, def int) int {
+	if limit <= 0 {
+		return def
+	}
+	return limit
+}
@@ -0,0 +1,84 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go-logger/internal/config"
+)
+
+// ==========================================
+// 取り込み (Redis Stream / NATS JetStream からログを読む)
+// ==========================================
+// HTTP を通さずにログを送れるようにする。読んだ分は保存してから Commit するので、
+// ロガーが止まっている間に積まれた分や、保存できなかった分は後でもう一度届く
+
+// Message : 取り込む1件
+type Message struct {
+	ID   string // 読み元でのID（ログに出す用）
+	Data []byte // POST /api/logs と同じ形の JSON
+	Key  string // プロジェクトキー（空ならデフォルトプロジェクト）
+
+	ack func(ctx context.Context) error
+	nak func(ctx context.Context) error
+}
+
+// Consumer : 取り込むログの読み元
+type Consumer interface {
+	Name() string
+	// Fetch : 最大 max 件読む。届いていなければ少し待ち、それでもなければ空で返す
+	Fetch(ctx context.Context, max int) ([]Message, error)
+	// Commit : Fetch で読んだうち先頭の n 件を取り込み済みにし、残りはもう一度届くようにする
+	Commit(ctx context.Context, msgs []Message, n int) error
+	Close() error
+}
+
+// commitMessages : ack / nak を持つ Message の Commit
+func commitMessages(ctx context.Context, msgs []Message, n int) error {
+	for i, m := range msgs {
+		f := m.ack
+		if i >= n {
+			f = m.nak
+		}
+		if f == nil {
+			continue
+		}
+		if err := f(ctx); err != nil {
+			return fmt.Errorf("%s: %w", m.ID, err)
+		}
+	}
+	return nil
+}
+
+// ConsumerFromEnv : INGEST_SOURCE（redis / nats）を設定した場合だけ読み元を作る（未設定なら nil）
+//
+//	redis  INGEST_REDIS_URL（既定 REDIS_URL）の INGEST_REDIS_STREAM（既定 go-logger.ingest）を
+//	       コンシューマーグループ INGEST_GROUP（既定 go-logger）で読む。複数のインスタンスで分けて読める
+//	nats   INGEST_NATS_URL（既定 NATS_URL）の JetStream で INGEST_NATS_SUBJECT（既定 go-logger.ingest）を
+//	       INGEST_GROUP を名前にした durable consumer で読む（ストリームがなければ作る）
+func ConsumerFromEnv() (Consumer, error) {
+	group := config.String("INGEST_GROUP", "go-logger")
+	switch source := config.String("INGEST_SOURCE", ""); source {
+	case "":
+		return nil, nil
+	case "redis":
+		url := config.String("INGEST_REDIS_URL", config.String("REDIS_URL", "redis://localhost:6379/0"))
+		return NewRedisConsumer(url, config.String("INGEST_REDIS_STREAM", "go-logger.ingest"), group, consumerName())
+	case "nats":
+		url := config.String("INGEST_NATS_URL", config.String("NATS_URL", "nats://localhost:4222"))
+		return NewNATSConsumer(url, config.String("INGEST_NATS_SUBJECT", "go-logger.ingest"), group)
+	default:
+		return nil, fmt.Errorf("unknown INGEST_SOURCE %q (use redis or nats)", source)
+	}
+}
+
+// consumerName : グループの中でのこのインスタンスの名前（INSTANCE_NAME、未設定ならホスト名）
+// 再起動しても同じ名前になるので、前回取り込み切れなかった分を続きから読める
+func consumerName() string {
+	if name := config.String("INSTANCE_NAME", ""); name != "" {
+		return name
+	}
+	host, _ := os.Hostname()
+	return host
+}
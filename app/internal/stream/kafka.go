@@ -0,0 +1,57 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// Kafka
+// ==========================================
+
+// Kafka : トピックへ JSON で送る（キーはプロジェクトIDなので、プロジェクトごとの順序は保たれる）
+type Kafka struct {
+	writer *kafka.Writer
+}
+
+// NewKafka : 非同期でまとめて送る Writer を作る（送信の失敗はログに出す）
+func NewKafka(brokers []string, topic string) *Kafka {
+	return &Kafka{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: 100 * time.Millisecond,
+		Async:        true,
+		Completion: func(messages []kafka.Message, err error) {
+			if err != nil {
+				fmt.Printf("Failed to publish %d entries to Kafka: %v\n", len(messages), err)
+			}
+		},
+	}}
+}
+
+func (k *Kafka) Name() string { return "kafka" }
+
+func (k *Kafka) Publish(ctx context.Context, e *model.LogEntry) error {
+	value, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return k.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(strconv.Itoa(e.ProjectID)),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "event_type", Value: []byte(e.EventType)},
+			{Key: "level", Value: []byte(e.Level)},
+		},
+	})
+}
+
+func (k *Kafka) Close() error { return k.writer.Close() }
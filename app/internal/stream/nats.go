@@ -0,0 +1,150 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"go-logger/internal/model"
+)
+
+// ==========================================
+// NATS
+// ==========================================
+
+// NATS : "<subject>.<イベント種別>" へ JSON で送る（購読側は go-logger.logs.> のように絞り込める）
+type NATS struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewNATS : 接続する（切れたら自動で再接続し、その間の分はクライアントが溜めておく）
+func NewNATS(url, subject string) (*NATS, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("go-logger"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			fmt.Println("Disconnected from NATS:", err)
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &NATS{conn: conn, subject: subject}, nil
+}
+
+func (n *NATS) Name() string { return "nats" }
+
+// subjectToken : サブジェクトの区切りやワイルドカードにならない文字だけにする
+var subjectToken = strings.NewReplacer(".", "_", " ", "_", "*", "_", ">", "_")
+
+func (n *NATS) Publish(ctx context.Context, e *model.LogEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	eventType := subjectToken.Replace(e.EventType)
+	if eventType == "" {
+		eventType = "unknown"
+	}
+	return n.conn.Publish(n.subject+"."+eventType, data)
+}
+
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
+
+// ==========================================
+// NATS JetStream (取り込み)
+// ==========================================
+
+// natsFetchWait : 届くまで待つ時間
+const natsFetchWait = 5 * time.Second
+
+// NATSConsumer : JetStream の durable consumer で読む（メッセージの本文が POST /api/logs と同じ形の JSON）
+// プロジェクトキーはヘッダーの X-API-Key に入れる（任意）
+//
+//	nats pub go-logger.ingest '{"level":"error","message":"..."}'
+type NATSConsumer struct {
+	conn    *nats.Conn
+	sub     *nats.Subscription
+	subject string
+}
+
+// NewNATSConsumer : 接続し、subject を受けるストリームがなければ作る
+// 同じ durable の名前で読むインスタンス同士は、メッセージを分けて受け取る
+func NewNATSConsumer(url, subject, durable string) (*NATSConsumer, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("go-logger"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			fmt.Println("Disconnected from NATS:", err)
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := js.StreamNameBySubject(subject); errors.Is(err, nats.ErrNoMatchingStream) {
+		name := strings.ToUpper(strings.NewReplacer(".", "_", "*", "_", ">", "_", "-", "_").Replace(subject))
+		_, err = js.AddStream(&nats.StreamConfig{Name: name, Subjects: []string{subject}})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("create stream %s: %w", name, err)
+		}
+		fmt.Printf("Created JetStream stream %s for %s\n", name, subject)
+	} else if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	sub, err := js.PullSubscribe(subject, subjectToken.Replace(durable))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &NATSConsumer{conn: conn, sub: sub, subject: subject}, nil
+}
+
+func (c *NATSConsumer) Name() string { return "nats:" + c.subject }
+
+func (c *NATSConsumer) Fetch(ctx context.Context, max int) ([]Message, error) {
+	batch, err := c.sub.Fetch(max, nats.MaxWait(natsFetchWait))
+	if errors.Is(err, nats.ErrTimeout) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]Message, len(batch))
+	for i, m := range batch {
+		msgs[i] = Message{
+			Data: m.Data,
+			Key:  m.Header.Get("X-API-Key"),
+			ack:  func(context.Context) error { return m.Ack() },
+			// 保存できなかった分は少し置いてから届け直してもらう
+			nak: func(context.Context) error { return m.NakWithDelay(natsFetchWait) },
+		}
+		if meta, err := m.Metadata(); err == nil {
+			msgs[i].ID = strconv.FormatUint(meta.Sequence.Stream, 10)
+		}
+	}
+	return msgs, nil
+}
+
+func (c *NATSConsumer) Commit(ctx context.Context, msgs []Message, n int) error {
+	return commitMessages(ctx, msgs, n)
+}
+
+func (c *NATSConsumer) Close() error {
+	return c.conn.Drain()
+}
@@ -0,0 +1,105 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ==========================================
+// Redis Stream (取り込み)
+// ==========================================
+
+// redisBlockTimeout : XREADGROUP で届くまで待つ時間
+const redisBlockTimeout = 5 * time.Second
+
+// RedisConsumer : コンシューマーグループで Redis Stream を読む
+// エントリーの "data" に POST /api/logs と同じ形の JSON、"key" にプロジェクトキー（任意）を入れる
+//
+//	XADD go-logger.ingest * data '{"level":"error","message":"..."}'
+type RedisConsumer struct {
+	client   *redis.Client
+	stream   string
+	group    string
+	consumer string
+
+	grouped bool // グループを作った（既にあった）
+	pending bool // 自分宛てで未了の分を先に読み直す（起動時と、Commit しきれなかった後）
+}
+
+// NewRedisConsumer : redis://[:password@]host:port/db の形式のURLで接続する（グループは最初の Fetch で作る）
+func NewRedisConsumer(url, stream, group, consumer string) (*RedisConsumer, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisConsumer{client: redis.NewClient(opts), stream: stream, group: group, consumer: consumer, pending: true}, nil
+}
+
+func (c *RedisConsumer) Name() string { return "redis:" + c.stream }
+
+func (c *RedisConsumer) Fetch(ctx context.Context, max int) ([]Message, error) {
+	if !c.grouped {
+		err := c.client.XGroupCreateMkStream(ctx, c.stream, c.group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return nil, err
+		}
+		c.grouped = true
+	}
+
+	// "0" は自分に配られたまま未了の分、">" はまだ誰にも配られていない分
+	start, block := ">", redisBlockTimeout
+	if c.pending {
+		start, block = "0", -1
+	}
+	res, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    c.group,
+		Consumer: c.consumer,
+		Streams:  []string{c.stream, start},
+		Count:    int64(max),
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []Message
+	for _, s := range res {
+		for _, x := range s.Messages {
+			id := x.ID
+			data, _ := x.Values["data"].(string)
+			key, _ := x.Values["key"].(string)
+			msgs = append(msgs, Message{
+				ID:   id,
+				Data: []byte(data),
+				Key:  key,
+				ack: func(ctx context.Context) error {
+					return c.client.XAck(ctx, c.stream, c.group, id).Err()
+				},
+			})
+		}
+	}
+	if c.pending && len(msgs) == 0 {
+		c.pending = false
+		return c.Fetch(ctx, max)
+	}
+	return msgs, nil
+}
+
+// Commit : 残りは未了のまま残り、次の Fetch で読み直す
+func (c *RedisConsumer) Commit(ctx context.Context, msgs []Message, n int) error {
+	if n < len(msgs) {
+		c.pending = true
+	}
+	return commitMessages(ctx, msgs, n)
+}
+
+func (c *RedisConsumer) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,63 @@
+// Package stream : 保存したログを Kafka / NATS へ流す (分析基盤が読み出しAPIをポーリングせずに済むように)
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-logger/internal/config"
+	"go-logger/internal/model"
+)
+
+// Publisher : 送り先1つ分
+type Publisher interface {
+	Name() string
+	// Publish : 1件送る（送信はまとめて非同期に行い、ここでは待たない）
+	Publish(ctx context.Context, e *model.LogEntry) error
+	// Close : 溜まっている分を送ってから閉じる
+	Close() error
+}
+
+// Multi : 全ての送り先へ送る
+type Multi []Publisher
+
+func (m Multi) Name() string { return "all" }
+
+func (m Multi) Publish(ctx context.Context, e *model.LogEntry) error {
+	var errs []error
+	for _, p := range m {
+		if err := p.Publish(ctx, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m Multi) Close() error {
+	var errs []error
+	for _, p := range m {
+		errs = append(errs, p.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// FromEnv : 設定されている送り先だけを有効にする（どれもなければ空の Multi）
+//
+//	KAFKA_BROKERS  ブローカー（カンマ区切り）。KAFKA_TOPIC（既定 go-logger.logs）へ送る
+//	NATS_URL       NATS のURL。NATS_SUBJECT（既定 go-logger.logs）.<イベント種別> へ送る
+func FromEnv() (Multi, error) {
+	var list Multi
+	if brokers := config.List("KAFKA_BROKERS"); len(brokers) > 0 {
+		list = append(list, NewKafka(brokers, config.String("KAFKA_TOPIC", "go-logger.logs")))
+	}
+	if url := config.String("NATS_URL", ""); url != "" {
+		n, err := NewNATS(url, config.String("NATS_SUBJECT", "go-logger.logs"))
+		if err != nil {
+			list.Close()
+			return nil, fmt.Errorf("nats: %w", err)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
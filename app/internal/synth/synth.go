@@ -0,0 +1,239 @@
+// Package synth : 負荷試験・画面の確認用の合成トラフィック
+// それらしいアクセス（ページ・ブラウザ・参照元・訪問者）と構造化ログ（レベル・メッセージ・応答時間）を作り、決まった速さで送る
+// `main generate`（別のインスタンスへ HTTP で送る）と POST /api/admin/generate（自分の保存の経路に流す）で使う
+package synth
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-logger/internal/clock"
+)
+
+// 合成したイベントの種類
+const (
+	KindAccess = "access" // アクセス記録 (GET /api/...)
+	KindLog    = "log"    // 構造化ログ (POST /api/logs)
+)
+
+// MaxRate : 1秒あたりの件数の上限（間違えて桁を増やしても送り先を止めないように）
+const MaxRate = 10000
+
+// Event : 合成した1件
+type Event struct {
+	Kind       string
+	Path       string // KindAccess: "/shop/items/12" など（/api の後ろ）
+	UserAgent  string
+	Referrer   string
+	IP         string // 訪問者ごとに決まったアドレス（同じ訪問者は同じセッションに見える）
+	Level      string // KindLog
+	Message    string // KindLog
+	Fields     map[string]any
+	Status     int
+	DurationMS float64
+}
+
+// weighted : 重み付きの候補
+type weighted struct {
+	value  string
+	weight int
+}
+
+// pick : 重みに従って1つ選ぶ
+func pick(r *rand.Rand, items []weighted) string {
+	total := 0
+	for _, it := range items {
+		total += it.weight
+	}
+	n := r.IntN(total)
+	for _, it := range items {
+		if n -= it.weight; n < 0 {
+			return it.value
+		}
+	}
+	return items[len(items)-1].value
+}
+
+var (
+	pages = []weighted{
+		{"/", 30}, {"/blog", 12}, {"/blog/hello-world", 8}, {"/blog/go-generics", 6}, {"/pricing", 8},
+		{"/docs", 10}, {"/docs/getting-started", 7}, {"/shop/items/%d", 12}, {"/cart", 4}, {"/login", 3},
+	}
+	userAgents = []weighted{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", 35},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15", 15},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", 20},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36", 12},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0", 8},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", 5},
+		{"curl/8.5.0", 3},
+		{"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", 2},
+	}
+	referrers = []weighted{
+		{"", 40}, {"https://www.google.com/", 30}, {"https://t.co/", 8}, {"https://news.ycombinator.com/", 6},
+		{"https://www.bing.com/", 6}, {"https://github.com/", 5}, {"https://duckduckgo.com/", 5},
+	}
+	levels   = []weighted{{"debug", 10}, {"info", 60}, {"warn", 20}, {"error", 9}, {"fatal", 1}}
+	messages = map[string][]string{
+		"debug": {"cache miss for %s", "retrying request to %s"},
+		"info":  {"user signed in", "order completed", "rendered %s", "job finished"},
+		"warn":  {"slow query on %s", "rate limit close to the quota", "deprecated parameter used on %s"},
+		"error": {"upstream timeout on %s", "payment declined", "failed to render %s"},
+		"fatal": {"database connection lost", "out of memory"},
+	}
+	statuses = []weighted{{"200", 86}, {"304", 5}, {"404", 5}, {"500", 2}, {"503", 1}, {"302", 1}}
+)
+
+// Options : 作るイベントの割合など
+type Options struct {
+	LogShare float64 // 構造化ログの割合（0〜1。残りはアクセス）
+	Visitors int     // 訪問者の数（IPアドレスの種類。少ないほど同じ訪問者が何度も来る）
+	Seed     uint64  // 0 なら毎回違う並び
+}
+
+// Generator : イベントを1件ずつ作る（同じ Seed なら同じ並び。並行には使わない）
+type Generator struct {
+	opts Options
+	r    *rand.Rand
+}
+
+// New : Visitors の既定値 500 を補って作る（LogShare が 0〜1 の外なら 0.2）
+func New(opts Options) *Generator {
+	if opts.LogShare < 0 || opts.LogShare > 1 {
+		opts.LogShare = 0.2
+	}
+	if opts.Visitors <= 0 {
+		opts.Visitors = 500
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &Generator{opts: opts, r: rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))}
+}
+
+// Next : 次の1件
+func (g *Generator) Next() Event {
+	r := g.r
+	visitor := r.IntN(g.opts.Visitors)
+	path := pick(r, pages)
+	if strings.Contains(path, "%d") {
+		path = fmt.Sprintf(path, 1+r.IntN(200))
+	}
+	e := Event{
+		Kind:      KindAccess,
+		Path:      path,
+		UserAgent: pick(r, userAgents),
+		Referrer:  pick(r, referrers),
+		IP:        visitorIP(visitor),
+		Fields:    map[string]any{"synthetic": true},
+		// 応答時間は対数正規分布（中央値およそ 40ms、たまに数秒）
+		DurationMS: math.Round(math.Exp(3.7+0.9*r.NormFloat64())*10) / 10,
+	}
+	e.Status, _ = strconv.Atoi(pick(r, statuses))
+	if r.Float64() < g.opts.LogShare {
+		e.Kind = KindLog
+		e.Level = pick(r, levels)
+		msgs := messages[e.Level]
+		e.Message = msgs[r.IntN(len(msgs))]
+		if strings.Contains(e.Message, "%s") {
+			e.Message = fmt.Sprintf(e.Message, path)
+		}
+		e.Fields["request_path"] = path
+		if e.Level == "error" || e.Level == "fatal" {
+			e.Status = 500
+		}
+	}
+	return e
+}
+
+// testNets : 文書用のアドレス範囲 (RFC 5737)
+var testNets = []string{"192.0.2", "198.51.100", "203.0.113"}
+
+// visitorIP : 訪問者ごとの文書用のアドレス（IPv4 の3つの範囲を使い切ったら IPv6 の 2001:db8::/32）
+func visitorIP(visitor int) string {
+	if visitor < len(testNets)*254 {
+		return fmt.Sprintf("%s.%d", testNets[visitor/254], 1+visitor%254)
+	}
+	return fmt.Sprintf("2001:db8::%x", visitor)
+}
+
+// ParseRate : "50/s" "300/m" "1000/h" か "50"（1秒あたり）を1秒あたりの件数にする
+func ParseRate(s string) (float64, error) {
+	num, unit, _ := strings.Cut(strings.TrimSpace(s), "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid rate %q (use e.g. 50/s, 300/m or 1000/h)", s)
+	}
+	per := map[string]float64{"": 1, "s": 1, "m": 60, "h": 3600}
+	d, ok := per[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid rate unit %q (use /s, /m or /h)", unit)
+	}
+	if rate := n / d; rate <= MaxRate {
+		return rate, nil
+	}
+	return 0, fmt.Errorf("rate %q is above %d/s", s, MaxRate)
+}
+
+// tickInterval : 送る間隔（この間に溜まった分をまとめて1回で送る）
+const tickInterval = 100 * time.Millisecond
+
+// Result : 送り終えた（または止めた）時の結果
+type Result struct {
+	Sent    int64         `json:"sent"`
+	Failed  int64         `json:"failed"`
+	Elapsed time.Duration `json:"-"`
+	Rate    float64       `json:"rate"` // 実際に送れた1秒あたりの件数
+}
+
+// Run : rate 件/秒で duration の間（ゼロなら ctx が終わるまで）イベントを作り、send に渡す
+// send が間に合わない分は次の回にまとめる（1回に batch 件まで。送り先が遅ければ実際の速さは rate を下回る）
+// send は送れなかった件数を返す。progress があれば回ごとに途中の結果を渡す
+func Run(ctx context.Context, clk clock.Clock, gen *Generator, rate float64, duration time.Duration, batch int, send func(ctx context.Context, events []Event) int, progress func(Result)) Result {
+	if batch <= 0 {
+		batch = 500
+	}
+	start := clk.Now()
+	ticker := clk.NewTicker(tickInterval)
+	defer ticker.Stop()
+	var res Result
+	for {
+		now := clk.Now()
+		elapsed := now.Sub(start)
+		if duration > 0 && elapsed >= duration {
+			elapsed = duration
+		}
+		due := int64(rate*elapsed.Seconds()) - res.Sent - res.Failed
+		for due > 0 && ctx.Err() == nil {
+			n := int(min(due, int64(batch)))
+			events := make([]Event, n)
+			for i := range events {
+				events[i] = gen.Next()
+			}
+			failed := send(ctx, events)
+			res.Failed += int64(failed)
+			res.Sent += int64(n - failed)
+			due -= int64(n)
+		}
+		res.Elapsed = clk.Now().Sub(start)
+		if secs := res.Elapsed.Seconds(); secs > 0 {
+			res.Rate = float64(res.Sent) / secs
+		}
+		if progress != nil {
+			progress(res)
+		}
+		if ctx.Err() != nil || (duration > 0 && elapsed >= duration) {
+			return res
+		}
+		select {
+		case <-ctx.Done():
+		case <-ticker.C():
+		}
+	}
+}
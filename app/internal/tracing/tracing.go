@@ -0,0 +1,102 @@
+// Package tracing : OpenTelemetry のトレース設定とスパンのヘルパー
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer : アプリ全体で使うトレーサー（未設定時はNo-op）
+var Tracer = otel.Tracer("go-logger")
+
+// Init : OTLPエクスポーターを環境変数から設定する
+// OTEL_EXPORTER_OTLP_ENDPOINT (または ..._TRACES_ENDPOINT) が未設定ならトレースは無効
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return noop, nil
+	}
+
+	// エンドポイント・ヘッダー等は OTEL_EXPORTER_OTLP_* から自動で読み込まれる
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return noop, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME / OTEL_RESOURCE_ATTRIBUTES があればそちらを優先
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName("go-logger")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return noop, fmt.Errorf("create resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	fmt.Println("Tracing enabled: exporting spans via OTLP")
+	return tp.Shutdown, nil
+}
+
+// Handler : HTTPハンドラ全体をスパンで包む（スパン名は "METHOD /path"）
+func Handler(h http.Handler) http.Handler {
+	return otelhttp.NewHandler(h, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	)
+}
+
+// HTTPClient : 外部呼び出し（Webhookなど）用のトレース付きHTTPクライアント
+func HTTPClient(c *http.Client) *http.Client {
+	c.Transport = otelhttp.NewTransport(http.DefaultTransport)
+	return c
+}
+
+// StartDBSpan : DB操作1回分のスパンを開始する
+func StartDBSpan(ctx context.Context, operation, table, statement string) (context.Context, trace.Span) {
+	return Tracer.Start(ctx, "db."+operation+" "+table,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBCollectionName(table),
+			semconv.DBQueryText(statement),
+		),
+	)
+}
+
+// EndSpan : エラーがあればスパンに記録してから終了する
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Attr : 文字列属性のショートカット
+func Attr(key, value string) attribute.KeyValue {
+	return attribute.String(key, value)
+}
@@ -0,0 +1,10 @@
+// Package static : ダッシュボードの画面（実行ファイルに埋め込み、1つのファイルだけで配布できるようにする）
+// ファイルを足したら下の go:embed のパターンにも加える（.go は配信しない）
+package static
+
+import "embed"
+
+// Files : 埋め込んだ画面のファイル（STATIC_DIR を設定するとディスクの方を配信する）
+//
+//go:embed *.html
+var Files embed.FS